@@ -42,7 +42,7 @@ Only b should have a cache miss
   \xe2\x80\xa2 Packages in scope: a (esc)
   \xe2\x80\xa2 Running build in 1 packages (esc)
   \xe2\x80\xa2 Remote caching disabled (esc)
-  a:build: cache hit, replaying output [0-9a-f]+ (re)
+  a:build: cache hit (local), replaying output [0-9a-f]+ (re)
   a:build: 
   a:build: > build
   a:build: > echo 'building'
@@ -50,7 +50,7 @@ Only b should have a cache miss
   a:build: building
   
    Tasks:    1 successful, 1 total
-  Cached:    1 cached, 1 total
+  Cached:    1 cached (1 local, 0 remote), 1 total
     Time:\s*[\.0-9]+m?s >>> FULL TURBO (re)
   
 
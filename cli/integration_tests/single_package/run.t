@@ -20,13 +20,13 @@ Run a second time, verify caching works because there is a config
   $ ${TURBO} run build --single-package
   \xe2\x80\xa2 Running build (esc)
   \xe2\x80\xa2 Remote caching disabled (esc)
-  build: cache hit, replaying output a958ae6162e8d71a
+  build: cache hit (local), replaying output a958ae6162e8d71a
   build: 
   build: > build
   build: > echo 'building' > foo
   build: 
   
    Tasks:    1 successful, 1 total
-  Cached:    1 cached, 1 total
+  Cached:    1 cached (1 local, 0 remote), 1 total
     Time:\s*[\.0-9]+m?s >>> FULL TURBO (re)
   
\ No newline at end of file
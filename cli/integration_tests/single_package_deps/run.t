@@ -25,18 +25,18 @@ Run a second time, verify caching works because there is a config
   $ ${TURBO} run test --single-package
   \xe2\x80\xa2 Running test (esc)
   \xe2\x80\xa2 Remote caching disabled (esc)
-  build: cache hit, replaying output 0d6f9a04cadfa475
+  build: cache hit (local), replaying output 0d6f9a04cadfa475
   build: 
   build: > build
   build: > echo 'building' > foo
   build: 
-  test: cache hit, replaying output 638b08eb4b64eb68
+  test: cache hit (local), replaying output 638b08eb4b64eb68
   test: 
   test: > test
   test: > [[ ( -f foo ) && $(cat foo) == 'building' ]]
   test: 
   
    Tasks:    2 successful, 2 total
-  Cached:    2 cached, 2 total
+  Cached:    2 cached (2 local, 0 remote), 2 total
     Time:\s*[\.0-9]+m?s >>> FULL TURBO (re)
   
\ No newline at end of file
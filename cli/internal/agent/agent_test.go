@@ -0,0 +1,86 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/packagemanager"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"gotest.tools/v3/assert"
+)
+
+// fakePackageManager stands in for `npm run build` in packages/web: it reads the
+// output of a dependency and writes an output of its own
+const fakePackageManager = `#!/bin/sh
+echo "running $2"
+echo "warning" >&2
+mkdir -p dist
+cat ../lib/dist/lib.txt > dist/web.txt
+`
+
+func writeFile(t *testing.T, path turbopath.AbsoluteSystemPath, contents string, mode os.FileMode) {
+	t.Helper()
+	assert.NilError(t, path.EnsureDir())
+	assert.NilError(t, path.WriteFile([]byte(contents), mode))
+}
+
+func TestRemoteExecution(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake package manager is a shell script")
+	}
+	agentRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	writeFile(t, agentRoot.UntypedJoin("packages", "web", "package.json"), `{"name":"web","scripts":{"build":"build"}}`, 0644)
+	packageManagerPath := fs.AbsoluteSystemPathFromUpstream(t.TempDir()).UntypedJoin("pm")
+	writeFile(t, packageManagerPath, fakePackageManager, 0755)
+	server := httptest.NewServer(NewServer(agentRoot, &packagemanager.PackageManager{Command: packageManagerPath.ToString()}, "secret", 2, hclog.NewNullLogger()))
+	defer server.Close()
+
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	writeFile(t, repoRoot.UntypedJoin("packages", "lib", "dist", "lib.txt"), "hello from lib\n", 0644)
+	inputs, err := Pack(repoRoot, fs.TaskOutputs{Inclusions: []string{"packages/lib/dist/**"}})
+	assert.NilError(t, err, "Pack")
+
+	pool, err := NewPool([]string{server.URL + "/"}, "secret")
+	assert.NilError(t, err, "NewPool")
+	req := &TaskRequest{
+		TaskID:  "web#build",
+		Hash:    "abc",
+		Dir:     "packages/web",
+		Script:  "build",
+		Outputs: fs.TaskOutputs{Inclusions: []string{"packages/web/dist/**"}},
+		Inputs:  inputs,
+	}
+	var stdout, stderr bytes.Buffer
+	result, err := pool.Run(context.Background(), req, &stdout, &stderr)
+	assert.NilError(t, err, "Run")
+	assert.Equal(t, result.ExitCode, 0)
+	assert.Equal(t, result.Agent, server.URL)
+	assert.Equal(t, stdout.String(), "running build\n")
+	assert.Equal(t, stderr.String(), "warning\n")
+
+	_, err = Unpack(repoRoot, result.Outputs)
+	assert.NilError(t, err, "Unpack")
+	output, err := repoRoot.UntypedJoin("packages", "web", "dist", "web.txt").ReadFile()
+	assert.NilError(t, err, "reading restored output")
+	assert.Equal(t, string(output), "hello from lib\n")
+
+	req.Script = "lint"
+	_, err = pool.Run(context.Background(), req, &stdout, &stderr)
+	assert.ErrorContains(t, err, `packages/web has no "lint" script`)
+
+	req.Dir = "../outside"
+	_, err = pool.Run(context.Background(), req, &stdout, &stderr)
+	assert.ErrorContains(t, err, "outside of the repository")
+
+	pool, err = NewPool([]string{server.URL}, "wrong")
+	assert.NilError(t, err, "NewPool")
+	_, err = pool.Run(context.Background(), req, &stdout, &stderr)
+	assert.Assert(t, strings.Contains(err.Error(), "401"), "expected an authentication failure, got %v", err)
+}
@@ -0,0 +1,138 @@
+package agent
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// _maxEventSize is the longest line of a response, which is usually a result
+// event carrying the outputs of a task
+const _maxEventSize = 1024 * 1024 * 1024
+
+// Result is the outcome of running a task on an agent
+type Result struct {
+	// Agent is the URL of the agent that ran the task
+	Agent    string
+	ExitCode int
+	// Outputs is an artifact of the task's outputs, to be restored with Unpack
+	Outputs []byte
+}
+
+// Pool dispatches tasks to the least busy of a set of agents
+type Pool struct {
+	client *http.Client
+	token  string
+	mu     sync.Mutex
+	agents []string
+	busy   []int
+}
+
+// NewPool creates a Pool for the agents at the given URLs
+func NewPool(urls []string, token string) (*Pool, error) {
+	if len(urls) == 0 {
+		return nil, errors.New("no agents were given")
+	}
+	agents := make([]string, len(urls))
+	for i, rawURL := range urls {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return nil, err
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return nil, fmt.Errorf("%v is not an http or https URL", rawURL)
+		}
+		agents[i] = strings.TrimSuffix(rawURL, "/")
+	}
+	return &Pool{
+		client: &http.Client{},
+		token:  token,
+		agents: agents,
+		busy:   make([]int, len(agents)),
+	}, nil
+}
+
+// acquire picks the agent running the fewest tasks
+func (p *Pool) acquire() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	best := 0
+	for i, busy := range p.busy {
+		if busy < p.busy[best] {
+			best = i
+		}
+	}
+	p.busy[best]++
+	return best
+}
+
+func (p *Pool) release(i int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.busy[i]--
+}
+
+// Run executes a task on an agent, writing its output to stdout and stderr as it
+// arrives. An error means the task could not be run, not that it failed.
+func (p *Pool) Run(ctx context.Context, req *TaskRequest, stdout io.Writer, stderr io.Writer) (*Result, error) {
+	i := p.acquire()
+	defer p.release(i)
+	agent := p.agents[i]
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, agent+_tasksPath, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+p.token)
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("agent %v: %w", agent, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		message, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("agent %v responded with %v: %v", agent, resp.Status, strings.TrimSpace(string(message)))
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), _maxEventSize)
+	for scanner.Scan() {
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return nil, fmt.Errorf("agent %v sent an invalid event: %w", agent, err)
+		}
+		switch event.Type {
+		case EventOutput:
+			w := stdout
+			if event.Stream == "stderr" {
+				w = stderr
+			}
+			if _, err := io.WriteString(w, event.Data); err != nil {
+				return nil, err
+			}
+		case EventResult:
+			if event.Error != "" {
+				return nil, fmt.Errorf("agent %v: %v", agent, event.Error)
+			}
+			return &Result{Agent: agent, ExitCode: event.ExitCode, Outputs: event.Outputs}, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("agent %v: %w", agent, err)
+	}
+	return nil, fmt.Errorf("agent %v closed the connection before the task finished", agent)
+}
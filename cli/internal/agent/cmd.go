@@ -0,0 +1,86 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"runtime"
+
+	"github.com/spf13/cobra"
+	"github.com/vercel/turbo/cli/internal/cmdutil"
+	"github.com/vercel/turbo/cli/internal/errcode"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/packagemanager"
+	"github.com/vercel/turbo/cli/internal/signals"
+)
+
+type opts struct {
+	listen      string
+	concurrency int
+}
+
+// GetCmd returns the agent subcommand for use with cobra
+func GetCmd(helper *cmdutil.Helper, signalWatcher *signals.Watcher) *cobra.Command {
+	opts := &opts{}
+	cmd := &cobra.Command{
+		Use:   "agent [<flags>]",
+		Short: "Execute tasks for `turbo run` on another machine (experimental)",
+		Long: `Execute tasks for ` + "`turbo run --experimental-remote-agents`" + ` on another machine.
+
+The agent must run in a checkout of the repository at the same commit, with
+dependencies installed. It only runs scripts from its own package.json files.
+Requests must carry the token in ` + TokenEnvVar + `, which must be set.`,
+		Args:                  cobra.NoArgs,
+		SilenceUsage:          true,
+		SilenceErrors:         true,
+		DisableFlagsInUseLine: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, err := helper.GetCmdBase(cmd.Flags())
+			if err != nil {
+				return err
+			}
+			if err := serve(base, opts, signalWatcher); err != nil {
+				base.LogError("agent failed: %w", err)
+				return err
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&opts.listen, "listen", "127.0.0.1:9876", "The address to listen for tasks on")
+	cmd.Flags().IntVar(&opts.concurrency, "concurrency", runtime.NumCPU(), "The number of tasks to run at once")
+	return cmd
+}
+
+func serve(base *cmdutil.CmdBase, opts *opts, signalWatcher *signals.Watcher) error {
+	token := os.Getenv(TokenEnvVar)
+	if token == "" {
+		return errcode.Wrap(errcode.InvalidArguments, fmt.Errorf("%v must be set, so that only your runs can execute tasks", TokenEnvVar))
+	}
+	if opts.concurrency < 1 {
+		return errcode.Wrap(errcode.InvalidArguments, fmt.Errorf("--concurrency must be at least 1"))
+	}
+	rootPackageJSON, err := fs.ReadPackageJSON(base.RepoRoot.UntypedJoin("package.json"))
+	if err != nil {
+		return errcode.Wrap(errcode.InvalidPackageJSON, fmt.Errorf("failed to read package.json: %w", err))
+	}
+	packageManager, err := packagemanager.GetPackageManager(base.RepoRoot, rootPackageJSON)
+	if err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", opts.listen)
+	if err != nil {
+		return err
+	}
+	server := &http.Server{
+		Handler: NewServer(base.RepoRoot, packageManager, token, opts.concurrency, base.Logger.Named("agent")),
+	}
+	signalWatcher.AddOnClose(func() { _ = server.Close() })
+	base.UI.Info(fmt.Sprintf("• Running tasks for %v on http://%v", base.RepoRoot, listener.Addr()))
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
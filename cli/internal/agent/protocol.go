@@ -0,0 +1,121 @@
+// Package agent implements remote execution of tasks. An agent is a `turbo agent`
+// process running in a checkout of the repository on another machine. `turbo run`
+// sends it the tasks it would otherwise execute locally, along with the outputs of
+// their dependencies, and the agent streams back their logs followed by their outputs.
+//
+// The protocol is a single HTTP request per task: a TaskRequest is POSTed as JSON to
+// /v1/tasks, and the response is NDJSON, a line for each Event.
+package agent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/vercel/turbo/cli/internal/cacheitem"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/globby"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+// TokenEnvVar is the environment variable holding the token that authenticates
+// `turbo run` to agents. Agents refuse to start without it.
+const TokenEnvVar = "TURBO_AGENT_TOKEN"
+
+const _tasksPath = "/v1/tasks"
+
+// TaskRequest asks an agent to execute a task
+type TaskRequest struct {
+	TaskID string `json:"taskId"`
+	Hash   string `json:"hash"`
+	// Dir is the directory of the task's workspace, relative to the root of the repository
+	Dir string `json:"dir"`
+	// Script is the name of the script in the workspace's package.json
+	Script string   `json:"script"`
+	Args   []string `json:"args,omitempty"`
+	// Outputs are the repo-relative globs of the files to send back
+	Outputs fs.TaskOutputs `json:"outputs"`
+	// Inputs is an artifact of the outputs of the task's dependencies, which is
+	// restored before the script runs
+	Inputs []byte `json:"inputs,omitempty"`
+}
+
+// Types of Event
+const (
+	EventOutput = "output"
+	EventResult = "result"
+)
+
+// Event is a line of the response to a TaskRequest. Output events carry the task's
+// output as it is written, and the final result event carries its outcome.
+type Event struct {
+	Type string `json:"type"`
+	// Stream is "stdout" or "stderr", and Data is the output written to it, for output events
+	Stream string `json:"stream,omitempty"`
+	Data   string `json:"data,omitempty"`
+	// ExitCode, Error, and Outputs are set for result events. Error is set if the
+	// script could not be run at all, and Outputs is an artifact of the files
+	// matching the requested outputs, if the script succeeded.
+	ExitCode int    `json:"exitCode"`
+	Error    string `json:"error,omitempty"`
+	Outputs  []byte `json:"outputs,omitempty"`
+}
+
+// Pack returns an artifact of the files under anchor that match the given globs,
+// in the same format as the cache
+func Pack(anchor turbopath.AbsoluteSystemPath, globs fs.TaskOutputs) ([]byte, error) {
+	files, err := globby.GlobAll(anchor.ToString(), globs.Inclusions, globs.Exclusions)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, nil
+	}
+	dir, err := os.MkdirTemp("", "turbo-agent")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	path := turbopath.AbsoluteSystemPathFromUpstream(filepath.Join(dir, "artifact.tar.zst"))
+	item, err := cacheitem.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		relativePath, err := anchor.RelativePathString(file)
+		if err != nil {
+			_ = item.Close()
+			return nil, err
+		}
+		if err := item.AddFile(anchor, turbopath.AnchoredSystemPathFromUpstream(relativePath)); err != nil {
+			_ = item.Close()
+			return nil, fmt.Errorf("failed to add %v: %w", relativePath, err)
+		}
+	}
+	if err := item.Close(); err != nil {
+		return nil, err
+	}
+	return path.ReadFile()
+}
+
+// Unpack restores an artifact created by Pack under anchor
+func Unpack(anchor turbopath.AbsoluteSystemPath, artifact []byte) ([]turbopath.AnchoredSystemPath, error) {
+	if len(artifact) == 0 {
+		return nil, nil
+	}
+	dir, err := os.MkdirTemp("", "turbo-agent")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	path := turbopath.AbsoluteSystemPathFromUpstream(filepath.Join(dir, "artifact.tar.zst"))
+	if err := path.WriteFile(artifact, 0644); err != nil {
+		return nil, err
+	}
+	item, err := cacheitem.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = item.Close() }()
+	return item.Restore(anchor)
+}
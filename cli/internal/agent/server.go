@@ -0,0 +1,170 @@
+package agent
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/packagemanager"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// Server executes tasks for `turbo run` in a checkout of the repository. It only
+// runs scripts from the package.json files of its own checkout.
+type Server struct {
+	repoRoot       turbopath.AbsoluteSystemPath
+	packageManager *packagemanager.PackageManager
+	token          string
+	logger         hclog.Logger
+	semaphore      util.Semaphore
+	// mu serializes restoring the inputs of tasks, which may share files
+	mu sync.Mutex
+}
+
+// NewServer creates a Server that runs up to concurrency tasks at a time
+func NewServer(repoRoot turbopath.AbsoluteSystemPath, packageManager *packagemanager.PackageManager, token string, concurrency int, logger hclog.Logger) *Server {
+	return &Server{
+		repoRoot:       repoRoot,
+		packageManager: packageManager,
+		token:          token,
+		logger:         logger,
+		semaphore:      util.NewSemaphore(concurrency),
+	}
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != _tasksPath {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+		return
+	}
+	var req TaskRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid task request: %v", err), http.StatusBadRequest)
+		return
+	}
+	dir, err := s.resolveDir(req.Dir)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.semaphore.Acquire()
+	defer s.semaphore.Release()
+	s.logger.Info("running task", "task", req.TaskID, "hash", req.Hash)
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	stream := &eventStream{encoder: json.NewEncoder(w)}
+	if flusher, ok := w.(http.Flusher); ok {
+		stream.flusher = flusher
+	}
+	result := s.run(&req, dir, stream)
+	s.logger.Info("finished task", "task", req.TaskID, "exitCode", result.ExitCode, "error", result.Error)
+	stream.send(result)
+}
+
+// resolveDir returns the absolute path of a workspace directory, making sure it is
+// inside the repository
+func (s *Server) resolveDir(dir string) (turbopath.AbsoluteSystemPath, error) {
+	if filepath.IsAbs(dir) {
+		return "", fmt.Errorf("workspace directory %v must be relative to the repository", dir)
+	}
+	cleaned := filepath.Clean(dir)
+	if cleaned == ".." || strings.HasPrefix(cleaned, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("workspace directory %v is outside of the repository", dir)
+	}
+	resolved := s.repoRoot.UntypedJoin(cleaned)
+	if contained, err := s.repoRoot.ContainsPath(resolved); err != nil || !contained {
+		return "", fmt.Errorf("workspace directory %v is outside of the repository", dir)
+	}
+	return resolved, nil
+}
+
+// run restores the task's inputs, runs its script, and packs its outputs
+func (s *Server) run(req *TaskRequest, dir turbopath.AbsoluteSystemPath, stream *eventStream) *Event {
+	pkg, err := fs.ReadPackageJSON(dir.UntypedJoin("package.json"))
+	if err != nil {
+		return &Event{Type: EventResult, ExitCode: 1, Error: fmt.Sprintf("failed to read package.json: %v", err)}
+	}
+	if _, ok := pkg.Scripts[req.Script]; !ok {
+		return &Event{Type: EventResult, ExitCode: 1, Error: fmt.Sprintf("%v has no %q script", req.Dir, req.Script)}
+	}
+	s.mu.Lock()
+	_, err = Unpack(s.repoRoot, req.Inputs)
+	s.mu.Unlock()
+	if err != nil {
+		return &Event{Type: EventResult, ExitCode: 1, Error: fmt.Sprintf("failed to restore the outputs of dependencies: %v", err)}
+	}
+
+	args := append([]string{"run"}, req.Script)
+	if len(req.Args) > 0 {
+		args = append(args, s.packageManager.ArgSeparator...)
+		args = append(args, req.Args...)
+	}
+	cmd := exec.Command(s.packageManager.Command, args...)
+	cmd.Dir = dir.ToString()
+	cmd.Env = append(os.Environ(), fmt.Sprintf("TURBO_HASH=%v", req.Hash))
+	cmd.Stdout = &streamWriter{stream: stream, name: "stdout"}
+	cmd.Stderr = &streamWriter{stream: stream, name: "stderr"}
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return &Event{Type: EventResult, ExitCode: exitErr.ExitCode()}
+		}
+		return &Event{Type: EventResult, ExitCode: 1, Error: err.Error()}
+	}
+
+	outputs, err := Pack(s.repoRoot, req.Outputs)
+	if err != nil {
+		return &Event{Type: EventResult, ExitCode: 1, Error: fmt.Sprintf("failed to collect outputs: %v", err)}
+	}
+	return &Event{Type: EventResult, Outputs: outputs}
+}
+
+// eventStream writes events to a response as NDJSON, flushing after each one
+type eventStream struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+	flusher http.Flusher
+}
+
+func (es *eventStream) send(event *Event) {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	// If the client has gone away there's nobody to tell
+	_ = es.encoder.Encode(event)
+	if es.flusher != nil {
+		es.flusher.Flush()
+	}
+}
+
+// streamWriter sends everything written to it as output events
+type streamWriter struct {
+	stream *eventStream
+	name   string
+}
+
+// Write implements io.Writer.Write
+func (sw *streamWriter) Write(p []byte) (int, error) {
+	sw.stream.send(&Event{Type: EventOutput, Stream: sw.name, Data: string(p)})
+	return len(p), nil
+}
@@ -0,0 +1,178 @@
+package bench
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/vercel/turbo/cli/internal/context"
+	"github.com/vercel/turbo/cli/internal/core"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/taskhash"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// Phase identifies a measured portion of turbo's startup work
+type Phase string
+
+const (
+	// PhasePackageGraph measures reading workspaces and constructing the package graph
+	PhasePackageGraph Phase = "package-graph"
+	// PhaseTaskGraph measures constructing the task graph from the pipeline
+	PhaseTaskGraph Phase = "task-graph"
+	// PhaseFileHashing measures hashing the input files of every package-task
+	PhaseFileHashing Phase = "file-hashing"
+	// PhaseScheduling measures walking the task graph with a no-op visitor
+	PhaseScheduling Phase = "scheduling"
+)
+
+// Phases lists every measured phase, in the order they run
+var Phases = []Phase{PhasePackageGraph, PhaseTaskGraph, PhaseFileHashing, PhaseScheduling}
+
+// Result holds the timings of a single measured phase across iterations
+type Result struct {
+	Phase   Phase           `json:"phase"`
+	Samples []time.Duration `json:"samples"`
+}
+
+// Min returns the fastest sample
+func (r *Result) Min() time.Duration {
+	return r.sorted()[0]
+}
+
+// Median returns the median sample
+func (r *Result) Median() time.Duration {
+	sorted := r.sorted()
+	return sorted[len(sorted)/2]
+}
+
+// Max returns the slowest sample
+func (r *Result) Max() time.Duration {
+	sorted := r.sorted()
+	return sorted[len(sorted)-1]
+}
+
+func (r *Result) sorted() []time.Duration {
+	sorted := make([]time.Duration, len(r.Samples))
+	copy(sorted, r.Samples)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted
+}
+
+// state carries the outputs of each phase into the next
+type state struct {
+	repoRoot turbopath.AbsoluteSystemPath
+	pkgGraph *context.Context
+	pipeline fs.Pipeline
+	// hashExclude are the globs of files that aren't hashed
+	hashExclude []string
+	engine      *core.Engine
+	workers     int
+}
+
+// Run measures each phase against the monorepo at repoRoot the given number of times
+func Run(repoRoot turbopath.AbsoluteSystemPath, iterations int, workers int) ([]*Result, error) {
+	if iterations < 1 {
+		return nil, errors.New("at least one iteration is required")
+	}
+	results := make([]*Result, len(Phases))
+	for i, phase := range Phases {
+		results[i] = &Result{Phase: phase}
+	}
+	for i := 0; i < iterations; i++ {
+		s := &state{repoRoot: repoRoot, workers: workers}
+		for j, phase := range Phases {
+			start := time.Now()
+			if err := s.run(phase); err != nil {
+				return nil, err
+			}
+			results[j].Samples = append(results[j].Samples, time.Since(start))
+		}
+	}
+	return results, nil
+}
+
+func (s *state) run(phase Phase) error {
+	switch phase {
+	case PhasePackageGraph:
+		return s.buildPackageGraph()
+	case PhaseTaskGraph:
+		return s.buildTaskGraph()
+	case PhaseFileHashing:
+		return s.hashFiles()
+	case PhaseScheduling:
+		return s.schedule()
+	}
+	return errors.New("unknown phase " + string(phase))
+}
+
+func (s *state) buildPackageGraph() error {
+	rootPackageJSON, err := fs.ReadPackageJSON(s.repoRoot.UntypedJoin("package.json"))
+	if err != nil {
+		return err
+	}
+	turboJSON, err := fs.LoadTurboConfig(s.repoRoot, rootPackageJSON, false)
+	if err != nil {
+		return err
+	}
+	pkgGraph, turboJSON, err := context.BuildPackageGraphWithConfig(s.repoRoot, rootPackageJSON, turboJSON)
+	if err != nil {
+		var warnings *context.Warnings
+		if !errors.As(err, &warnings) {
+			return err
+		}
+	}
+	s.pkgGraph = pkgGraph
+	s.pipeline = turboJSON.Pipeline
+	s.hashExclude = turboJSON.HashExclude
+	return nil
+}
+
+func (s *state) buildTaskGraph() error {
+	engine := core.NewEngine(&s.pkgGraph.TopologicalGraph)
+	for taskName, taskDefinition := range s.pipeline {
+		deps := make(util.Set)
+		for _, dependency := range taskDefinition.TaskDependencies {
+			deps.Add(dependency)
+		}
+		topoDeps := make(util.Set)
+		for _, dependency := range taskDefinition.TopologicalDependencies {
+			topoDeps.Add(dependency)
+		}
+		engine.AddTask(&core.Task{
+			Name:     taskName,
+			Deps:     deps,
+			TopoDeps: topoDeps,
+		})
+	}
+	taskNames := make([]string, 0, len(s.pipeline))
+	for taskName := range s.pipeline {
+		taskNames = append(taskNames, taskName)
+	}
+	if err := engine.Prepare(&core.EngineExecutionOptions{
+		Packages:  s.pkgGraph.PackageNames,
+		TaskNames: taskNames,
+	}); err != nil {
+		return err
+	}
+	s.engine = engine
+	return nil
+}
+
+func (s *state) hashFiles() error {
+	tracker := taskhash.NewTracker(s.pkgGraph.RootNode, "", s.pipeline, s.pkgGraph.PackageInfos, "", s.hashExclude)
+	return tracker.CalculateFileHashes(s.engine.TaskGraph.Vertices(), s.workers, s.repoRoot)
+}
+
+func (s *state) schedule() error {
+	errs := s.engine.Execute(func(taskID string) error {
+		return nil
+	}, core.ExecOpts{
+		Concurrency: s.workers,
+	})
+	if len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package bench
+
+import (
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"gotest.tools/v3/assert"
+)
+
+var _smallSpec = Spec{
+	Packages:        10,
+	DepsPerPackage:  3,
+	FilesPerPackage: 2,
+	Seed:            1,
+}
+
+func TestGenerateAndRun(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	assert.NilError(t, Generate(repoRoot, _smallSpec), "Generate")
+
+	results, err := Run(repoRoot, 2, 4)
+	assert.NilError(t, err, "Run")
+	assert.Equal(t, len(results), len(Phases))
+	for _, result := range results {
+		assert.Equal(t, len(result.Samples), 2)
+		assert.Assert(t, result.Min() <= result.Max())
+	}
+}
+
+func TestGenerateIsDeterministic(t *testing.T) {
+	first := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	second := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	assert.NilError(t, Generate(first, _smallSpec), "Generate")
+	assert.NilError(t, Generate(second, _smallSpec), "Generate")
+
+	for _, name := range Names(_smallSpec) {
+		a, err := first.UntypedJoin("packages", name, "package.json").ReadFile()
+		assert.NilError(t, err, "ReadFile")
+		b, err := second.UntypedJoin("packages", name, "package.json").ReadFile()
+		assert.NilError(t, err, "ReadFile")
+		assert.Equal(t, string(a), string(b))
+	}
+}
+
+func benchmarkPhase(b *testing.B, spec Spec, phase Phase) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(b.TempDir())
+	if err := Generate(repoRoot, spec); err != nil {
+		b.Fatalf("failed to generate monorepo: %v", err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		s := &state{repoRoot: repoRoot, workers: 10}
+		for _, p := range Phases {
+			if p == phase {
+				break
+			}
+			if err := s.run(p); err != nil {
+				b.Fatalf("failed to prepare %v: %v", p, err)
+			}
+		}
+		b.StartTimer()
+		if err := s.run(phase); err != nil {
+			b.Fatalf("%v failed: %v", phase, err)
+		}
+	}
+}
+
+func BenchmarkPackageGraph(b *testing.B) {
+	benchmarkPhase(b, DefaultSpec, PhasePackageGraph)
+}
+
+func BenchmarkTaskGraph(b *testing.B) {
+	benchmarkPhase(b, DefaultSpec, PhaseTaskGraph)
+}
+
+func BenchmarkFileHashing(b *testing.B) {
+	benchmarkPhase(b, DefaultSpec, PhaseFileHashing)
+}
+
+func BenchmarkScheduling(b *testing.B) {
+	benchmarkPhase(b, DefaultSpec, PhaseScheduling)
+}
@@ -0,0 +1,107 @@
+package bench
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/vercel/turbo/cli/internal/cmdutil"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+type opts struct {
+	spec       Spec
+	iterations int
+	workers    int
+	outDir     string
+	json       bool
+}
+
+func addFlags(opts *opts, flags *pflag.FlagSet) {
+	flags.IntVar(&opts.spec.Packages, "packages", DefaultSpec.Packages, "Number of workspaces in the synthetic monorepo")
+	flags.IntVar(&opts.spec.DepsPerPackage, "deps", DefaultSpec.DepsPerPackage, "Maximum number of internal dependencies per workspace")
+	flags.IntVar(&opts.spec.FilesPerPackage, "files", DefaultSpec.FilesPerPackage, "Number of source files per workspace")
+	flags.Int64Var(&opts.spec.Seed, "seed", DefaultSpec.Seed, "Seed used to generate the synthetic monorepo")
+	flags.IntVar(&opts.iterations, "iterations", 5, "Number of times to measure each phase")
+	flags.IntVar(&opts.workers, "workers", 10, "Number of workers used for hashing and scheduling")
+	flags.StringVar(&opts.outDir, "out-dir", "", "Generate the synthetic monorepo here and keep it, instead of using a temporary directory")
+	flags.BoolVar(&opts.json, "json", false, "Output results as JSON")
+}
+
+// GetCmd returns the hidden bench command, used to measure engine performance
+func GetCmd(helper *cmdutil.Helper) *cobra.Command {
+	opts := &opts{}
+	cmd := &cobra.Command{
+		Use:                   "bench [<flags>]",
+		Short:                 "Measure turbo's engine performance against a synthetic monorepo",
+		Hidden:                true,
+		SilenceUsage:          true,
+		SilenceErrors:         true,
+		DisableFlagsInUseLine: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, err := helper.GetCmdBase(cmd.Flags())
+			if err != nil {
+				return err
+			}
+			if err := run(base, opts); err != nil {
+				base.LogError("bench failed: %w", err)
+				return err
+			}
+			return nil
+		},
+	}
+	addFlags(opts, cmd.Flags())
+	return cmd
+}
+
+func run(base *cmdutil.CmdBase, opts *opts) error {
+	var repoRoot turbopath.AbsoluteSystemPath
+	if opts.outDir != "" {
+		repoRoot = fs.ResolveUnknownPath(base.RepoRoot, opts.outDir)
+	} else {
+		tempDir, err := os.MkdirTemp("", "turbo-bench")
+		if err != nil {
+			return err
+		}
+		repoRoot = fs.AbsoluteSystemPathFromUpstream(tempDir)
+		defer func() { _ = repoRoot.RemoveAll() }()
+	}
+	generateStart := time.Now()
+	if err := Generate(repoRoot, opts.spec); err != nil {
+		return errors.Wrap(err, "failed to generate synthetic monorepo")
+	}
+	base.Logger.Debug("generated synthetic monorepo", "path", repoRoot, "duration", time.Since(generateStart))
+
+	results, err := Run(repoRoot, opts.iterations, opts.workers)
+	if err != nil {
+		return err
+	}
+	if opts.json {
+		bytes, err := json.MarshalIndent(&struct {
+			Spec       Spec      `json:"spec"`
+			Iterations int       `json:"iterations"`
+			Results    []*Result `json:"results"`
+		}{opts.spec, opts.iterations, results}, "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "failed to render JSON")
+		}
+		base.UI.Output(string(bytes))
+		return nil
+	}
+
+	base.UI.Output(util.Sprintf("${BOLD}%v packages, up to %v deps each, %v files each, %v iterations${RESET}",
+		opts.spec.Packages, opts.spec.DepsPerPackage, opts.spec.FilesPerPackage, opts.iterations))
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Phase\tMin\tMedian\tMax\t")
+	for _, result := range results {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t\n", result.Phase, result.Min(), result.Median(), result.Max())
+	}
+	return w.Flush()
+}
@@ -0,0 +1,140 @@
+// Package bench generates synthetic monorepos and measures how long turbo's
+// engine takes to construct graphs, hash inputs, and schedule tasks against them.
+// It backs the hidden `turbo bench` command as well as the Go benchmarks in this
+// package, so that performance regressions can be caught before release.
+package bench
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"sort"
+
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+// Spec describes the shape of a synthetic monorepo
+type Spec struct {
+	// Packages is the number of workspaces to generate
+	Packages int
+	// DepsPerPackage is the maximum number of internal dependencies for each workspace
+	DepsPerPackage int
+	// FilesPerPackage is the number of source files to generate in each workspace
+	FilesPerPackage int
+	// Seed drives the pseudo-random selection of dependencies, so that the same
+	// Spec always produces the same repository
+	Seed int64
+}
+
+// DefaultSpec is a medium-sized monorepo, roughly matching a typical production repository
+var DefaultSpec = Spec{
+	Packages:        100,
+	DepsPerPackage:  5,
+	FilesPerPackage: 20,
+	Seed:            1,
+}
+
+// packageName returns the name of the nth synthetic workspace
+func packageName(n int) string {
+	return fmt.Sprintf("pkg-%04d", n)
+}
+
+// Generate writes a synthetic npm workspaces monorepo described by spec into repoRoot.
+// Workspaces only ever depend on workspaces with a lower index, so the resulting graph
+// is always acyclic.
+func Generate(repoRoot turbopath.AbsoluteSystemPath, spec Spec) error {
+	if spec.Packages < 1 {
+		return fmt.Errorf("a synthetic monorepo needs at least one package, got %v", spec.Packages)
+	}
+	rootPackageJSON := map[string]interface{}{
+		"name":           "synthetic-monorepo",
+		"version":        "0.0.0",
+		"private":        true,
+		"packageManager": "npm@8.19.2",
+		"workspaces":     []string{"packages/*"},
+	}
+	if err := writeJSON(repoRoot.UntypedJoin("package.json"), rootPackageJSON); err != nil {
+		return err
+	}
+	lockfile := map[string]interface{}{
+		"name":            "synthetic-monorepo",
+		"lockfileVersion": 2,
+		"requires":        true,
+		"packages":        map[string]interface{}{},
+	}
+	if err := writeJSON(repoRoot.UntypedJoin("package-lock.json"), lockfile); err != nil {
+		return err
+	}
+	turboJSON := map[string]interface{}{
+		"pipeline": map[string]interface{}{
+			"build": map[string]interface{}{
+				"dependsOn": []string{"^build"},
+				"outputs":   []string{"dist/**"},
+			},
+			"test": map[string]interface{}{
+				"dependsOn": []string{"build"},
+				"outputs":   []string{},
+			},
+		},
+	}
+	if err := writeJSON(repoRoot.UntypedJoin("turbo.json"), turboJSON); err != nil {
+		return err
+	}
+
+	random := rand.New(rand.NewSource(spec.Seed))
+	for i := 0; i < spec.Packages; i++ {
+		deps := make(map[string]string)
+		if i > 0 {
+			numDeps := random.Intn(spec.DepsPerPackage + 1)
+			for j := 0; j < numDeps; j++ {
+				deps[packageName(random.Intn(i))] = "*"
+			}
+		}
+		name := packageName(i)
+		pkgDir := repoRoot.UntypedJoin("packages", name)
+		packageJSON := map[string]interface{}{
+			"name":         name,
+			"version":      "1.0.0",
+			"dependencies": deps,
+			"scripts": map[string]string{
+				"build": "echo building",
+				"test":  "echo testing",
+			},
+		}
+		if err := writeJSON(pkgDir.UntypedJoin("package.json"), packageJSON); err != nil {
+			return err
+		}
+		for f := 0; f < spec.FilesPerPackage; f++ {
+			file := pkgDir.UntypedJoin("src", fmt.Sprintf("file-%04d.js", f))
+			if err := file.EnsureDir(); err != nil {
+				return err
+			}
+			contents := fmt.Sprintf("export const value = %d;\n", random.Int())
+			if err := file.WriteFile([]byte(contents), 0644); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Names returns the sorted workspace names that Generate creates for spec
+func Names(spec Spec) []string {
+	names := make([]string, spec.Packages)
+	for i := 0; i < spec.Packages; i++ {
+		names[i] = packageName(i)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func writeJSON(path turbopath.AbsoluteSystemPath, contents interface{}) error {
+	bytes, err := json.MarshalIndent(contents, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := path.EnsureDir(); err != nil {
+		return err
+	}
+	return path.WriteFile(bytes, 0644)
+}
@@ -0,0 +1,219 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// _azureStorageResource is the resource, or scope without its /.default suffix,
+// of tokens for Azure Storage
+const _azureStorageResource = "https://storage.azure.com"
+
+// azureToken is a Microsoft Entra ID access token
+type azureToken struct {
+	AccessToken string
+	// Expires is zero for tokens that don't expire
+	Expires time.Time
+}
+
+// expired returns true if the token expires within the next five minutes
+func (t *azureToken) expired(now time.Time) bool {
+	return !t.Expires.IsZero() && now.Add(5*time.Minute).After(t.Expires)
+}
+
+// errNoAzureCredentials is returned by a credential source that has nothing to
+// offer, so that the next source is tried
+var errNoAzureCredentials = errors.New("no Azure credentials")
+
+// azureCredentialProvider gets tokens for Azure Storage the way the Azure SDKs'
+// DefaultAzureCredential does, for the sources that apply to CI: a workload
+// identity, whose federated token is in AZURE_FEDERATED_TOKEN_FILE, and a managed
+// identity, from App Service or the instance metadata service. Tokens are cached
+// until shortly before they expire.
+type azureCredentialProvider struct {
+	getenv     func(string) string
+	httpClient *http.Client
+	now        func() time.Time
+
+	mu     sync.Mutex
+	cached *azureToken
+}
+
+func newAzureCredentialProvider() *azureCredentialProvider {
+	return &azureCredentialProvider{
+		getenv:     os.Getenv,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		now:        time.Now,
+	}
+}
+
+// get returns a valid token, fetching a new one if the cached one is missing or
+// about to expire
+func (p *azureCredentialProvider) get(ctx context.Context) (*azureToken, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cached != nil && !p.cached.expired(p.now()) {
+		return p.cached, nil
+	}
+	sources := []func(context.Context) (*azureToken, error){
+		p.fromWorkloadIdentity,
+		p.fromAppService,
+		p.fromInstanceMetadata,
+	}
+	for _, source := range sources {
+		token, err := source(ctx)
+		if errors.Is(err, errNoAzureCredentials) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		p.cached = token
+		return token, nil
+	}
+	return nil, errors.New("no Azure credentials were found. Set AZURE_STORAGE_SAS_TOKEN, or use a managed or workload identity")
+}
+
+// fromWorkloadIdentity exchanges the federated token of an AKS workload identity,
+// or of any OIDC issuer trusted by the app registration, for a token
+func (p *azureCredentialProvider) fromWorkloadIdentity(ctx context.Context) (*azureToken, error) {
+	tokenFile := p.getenv("AZURE_FEDERATED_TOKEN_FILE")
+	clientID := p.getenv("AZURE_CLIENT_ID")
+	tenantID := p.getenv("AZURE_TENANT_ID")
+	if tokenFile == "" || clientID == "" || tenantID == "" {
+		return nil, errNoAzureCredentials
+	}
+	assertion, err := ioutil.ReadFile(tokenFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read AZURE_FEDERATED_TOKEN_FILE: %w", err)
+	}
+	authorityHost := strings.TrimSuffix(p.getenv("AZURE_AUTHORITY_HOST"), "/")
+	if authorityHost == "" {
+		authorityHost = "https://login.microsoftonline.com"
+	}
+	form := url.Values{
+		"grant_type":            {"client_credentials"},
+		"client_id":             {clientID},
+		"client_assertion_type": {"urn:ietf:params:oauth:client-assertion-type:jwt-bearer"},
+		"client_assertion":      {strings.TrimSpace(string(assertion))},
+		"scope":                 {_azureStorageResource + "/.default"},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("%v/%v/oauth2/v2.0/token", authorityHost, url.PathEscape(tenantID)), strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	body, err := p.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get a token for workload identity %v: %w", clientID, err)
+	}
+	return parseAzureToken(body, p.now())
+}
+
+// fromAppService gets a token for the managed identity of an App Service,
+// Functions or Container Apps environment
+func (p *azureCredentialProvider) fromAppService(ctx context.Context) (*azureToken, error) {
+	endpoint := p.getenv("IDENTITY_ENDPOINT")
+	header := p.getenv("IDENTITY_HEADER")
+	if endpoint == "" || header == "" {
+		return nil, errNoAzureCredentials
+	}
+	query := url.Values{"api-version": {"2019-08-01"}, "resource": {_azureStorageResource}}
+	if clientID := p.getenv("AZURE_CLIENT_ID"); clientID != "" {
+		query.Set("client_id", clientID)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-IDENTITY-HEADER", header)
+	body, err := p.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get a token for the managed identity: %w", err)
+	}
+	return parseAzureToken(body, p.now())
+}
+
+// fromInstanceMetadata gets a token for the managed identity of a virtual machine,
+// such as a self-hosted Azure Pipelines agent, from the instance metadata service
+func (p *azureCredentialProvider) fromInstanceMetadata(ctx context.Context) (*azureToken, error) {
+	endpoint := strings.TrimSuffix(p.getenv("AZURE_POD_IDENTITY_AUTHORITY_HOST"), "/")
+	if endpoint == "" {
+		endpoint = "http://169.254.169.254"
+	}
+	// Off Azure the metadata service doesn't answer, so don't wait long for it
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	query := url.Values{"api-version": {"2018-02-01"}, "resource": {_azureStorageResource}}
+	if clientID := p.getenv("AZURE_CLIENT_ID"); clientID != "" {
+		query.Set("client_id", clientID)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"/metadata/identity/oauth2/token?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Metadata", "true")
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, errNoAzureCredentials
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusBadRequest && strings.Contains(string(body), "Identity not found"):
+		// The virtual machine has no managed identity
+		return nil, errNoAzureCredentials
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return nil, fmt.Errorf("failed to get a token for the managed identity: the metadata service responded with %v: %v", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return parseAzureToken(body, p.now())
+}
+
+// parseAzureToken parses a token response. Managed identity endpoints send
+// expires_in and expires_on as strings, and Entra ID sends expires_in as a number.
+func parseAzureToken(data []byte, now time.Time) (*azureToken, error) {
+	var raw struct {
+		AccessToken string      `json:"access_token"`
+		ExpiresIn   json.Number `json:"expires_in"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid token: %w", err)
+	}
+	if raw.AccessToken == "" {
+		return nil, errors.New("invalid token: access_token is required")
+	}
+	token := &azureToken{AccessToken: raw.AccessToken}
+	if seconds, err := strconv.Atoi(raw.ExpiresIn.String()); err == nil && seconds > 0 {
+		token.Expires = now.Add(time.Duration(seconds) * time.Second)
+	}
+	return token, nil
+}
+
+// do sends req and returns the response body, or an error if the status isn't 2xx
+func (p *azureCredentialProvider) do(req *http.Request) ([]byte, error) {
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("%v responded with %v: %v", req.URL.Host, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
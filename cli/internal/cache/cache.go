@@ -19,9 +19,9 @@ import (
 
 // Cache is abstracted way to cache/fetch previously run tasks
 type Cache interface {
-	// Fetch returns true if there is a cache it. It is expected to move files
-	// into their correct position as a side effect
-	Fetch(anchor turbopath.AbsoluteSystemPath, hash string, files []string) (bool, []turbopath.AnchoredSystemPath, int, error)
+	// Fetch returns the cache that had the artifact, if any. It is expected to move
+	// files into their correct position as a side effect
+	Fetch(anchor turbopath.AbsoluteSystemPath, hash string, files []string) (ItemStatus, []turbopath.AnchoredSystemPath, int, error)
 	Exists(hash string) (ItemStatus, error)
 	// Put caches files for a given hash
 	Put(anchor turbopath.AbsoluteSystemPath, hash string, duration int, files []turbopath.AnchoredSystemPath) error
@@ -37,6 +37,28 @@ type ItemStatus struct {
 	Remote bool `json:"remote"`
 }
 
+// Sources of a cache hit, as reported by ItemStatus.Source
+const (
+	CacheSourceLocal  = "local"
+	CacheSourceRemote = "remote"
+)
+
+// Hit returns true if the artifact is in any cache
+func (s ItemStatus) Hit() bool {
+	return s.Local || s.Remote
+}
+
+// Source returns the cache that an artifact is, or would be, restored from. Local
+// artifacts are preferred, as they are when fetching. It is empty for a miss.
+func (s ItemStatus) Source() string {
+	if s.Local {
+		return CacheSourceLocal
+	} else if s.Remote {
+		return CacheSourceRemote
+	}
+	return ""
+}
+
 const cacheEventHit = "HIT"
 const cacheEventMiss = "MISS"
 
@@ -57,42 +79,69 @@ func DefaultLocation(repoRoot turbopath.AbsoluteSystemPath) turbopath.AbsoluteSy
 // the but CLI continues to try to use it.
 type OnCacheRemoved = func(cache Cache, err error)
 
+// compatibleHashSchema returns whether an artifact recorded as having been hashed with
+// the given version of the hash schema can be restored. Artifacts that don't record
+// one, with version 0, were uploaded by clients that don't report it.
+func compatibleHashSchema(version int) bool {
+	return version == 0 || version == fs.HashSchemaVersion
+}
+
 // ErrNoCachesEnabled is returned when both the filesystem and http cache are unavailable
 var ErrNoCachesEnabled = errors.New("no caches are enabled")
 
 // Opts holds configuration options for the cache
 // TODO(gsoltis): further refactor this into fs cache opts and http cache opts
 type Opts struct {
-	OverrideDir     string
-	SkipRemote      bool
-	SkipFilesystem  bool
-	Workers         int
+	OverrideDir    string
+	SkipRemote     bool
+	SkipFilesystem bool
+	// RemoteReadOnly restores artifacts from the remote cache without uploading any
+	RemoteReadOnly bool
+	Workers        int
+	// MaxSize is the size, in bytes, that the filesystem cache is kept under by
+	// evicting the least recently used artifacts, or zero for no limit
+	MaxSize         int64
 	RemoteCacheOpts fs.RemoteCacheOptions
+	// Transfers, if not nil, counts the bytes moved to and from the remote cache
+	Transfers *TransferStats
 }
 
 // resolveCacheDir calculates the location turbo should use to cache artifacts,
-// based on the options supplied by the user.
+// based on the options supplied by the user. If the default location is not
+// writable, for instance because node_modules is on a read-only mount, a
+// directory in the user's cache directory is used instead.
 func (o *Opts) resolveCacheDir(repoRoot turbopath.AbsoluteSystemPath) turbopath.AbsoluteSystemPath {
 	if o.OverrideDir != "" {
 		return fs.ResolveUnknownPath(repoRoot, o.OverrideDir)
 	}
-	return DefaultLocation(repoRoot)
+	dir, _ := fs.WritableDir(repoRoot, DefaultLocation(repoRoot))
+	return dir
 }
 
 var _remoteOnlyHelp = `Ignore the local filesystem cache for all tasks. Only
 allow reading and caching artifacts using the remote cache.`
 
+var _remoteReadOnlyHelp = `Restore artifacts from the remote cache, but never upload
+any, so that only trusted machines publish artifacts. The
+local filesystem cache is still written.`
+
+var _cacheMaxSizeHelp = `Keep the filesystem cache under a size, such as 5GB, by
+evicting the least recently used artifacts as new ones are
+written. Pinned artifacts are never evicted.`
+
 // AddFlags adds cache-related flags to the given FlagSet
 func AddFlags(opts *Opts, flags *pflag.FlagSet) {
-	// skipping remote caching not currently a flag
 	flags.BoolVar(&opts.SkipFilesystem, "remote-only", false, _remoteOnlyHelp)
+	flags.BoolVar(&opts.SkipRemote, "no-remote", false, "Ignore the remote cache for all tasks, and only use the local filesystem cache.")
+	flags.BoolVar(&opts.RemoteReadOnly, "remote-read-only", false, _remoteReadOnlyHelp)
 	flags.StringVar(&opts.OverrideDir, "cache-dir", "", "Override the filesystem cache directory.")
 	flags.IntVar(&opts.Workers, "cache-workers", 10, "Set the number of concurrent cache operations")
+	flags.Var(&byteSizeValue{&opts.MaxSize}, "cache-max-size", _cacheMaxSizeHelp)
 }
 
 // New creates a new cache
-func New(opts Opts, repoRoot turbopath.AbsoluteSystemPath, client client, recorder analytics.Recorder, onCacheRemoved OnCacheRemoved) (Cache, error) {
-	c, err := newSyncCache(opts, repoRoot, client, recorder, onCacheRemoved)
+func New(opts Opts, repoRoot turbopath.AbsoluteSystemPath, client client, recorder analytics.Recorder, onCacheRemoved OnCacheRemoved, onArtifactConflict OnArtifactConflict) (Cache, error) {
+	c, err := newSyncCache(opts, repoRoot, client, recorder, onCacheRemoved, onArtifactConflict)
 	if err != nil && !errors.Is(err, ErrNoCachesEnabled) {
 		return nil, err
 	}
@@ -103,7 +152,7 @@ func New(opts Opts, repoRoot turbopath.AbsoluteSystemPath, client client, record
 }
 
 // newSyncCache can return an error with a usable noopCache.
-func newSyncCache(opts Opts, repoRoot turbopath.AbsoluteSystemPath, client client, recorder analytics.Recorder, onCacheRemoved OnCacheRemoved) (Cache, error) {
+func newSyncCache(opts Opts, repoRoot turbopath.AbsoluteSystemPath, client client, recorder analytics.Recorder, onCacheRemoved OnCacheRemoved, onArtifactConflict OnArtifactConflict) (Cache, error) {
 	// Check to see if the user has turned off particular cache implementations.
 	useFsCache := !opts.SkipFilesystem
 	useHTTPCache := !opts.SkipRemote
@@ -130,7 +179,14 @@ func newSyncCache(opts Opts, repoRoot turbopath.AbsoluteSystemPath, client clien
 	}
 
 	if useHTTPCache {
-		implementation := newHTTPCache(opts, client, recorder)
+		remote, err := remoteClient(opts, client)
+		if err != nil {
+			return nil, err
+		}
+		var implementation Cache = newHTTPCache(opts, remote, recorder, onArtifactConflict)
+		if opts.RemoteReadOnly {
+			implementation = newReadOnlyCache(implementation)
+		}
 		cacheImplementations = append(cacheImplementations, implementation)
 	}
 
@@ -178,7 +234,21 @@ func (mplex *cacheMultiplexer) Put(anchor turbopath.AbsoluteSystemPath, key stri
 
 type cacheRemoval struct {
 	cache Cache
-	err   *util.CacheDisabledError
+	err   error
+}
+
+// removalError returns the error to report if err means that a cache can't be used
+// for the rest of the run: either the Remote Cache has been disabled, or so many
+// requests to it have failed that turbo has stopped sending them.
+func removalError(err error) error {
+	cd := &util.CacheDisabledError{}
+	if errors.As(err, &cd) {
+		return cd
+	}
+	if errors.Is(err, util.ErrTooManyFailures) {
+		return errors.New("too many requests to it failed, continuing with the local cache only")
+	}
+	return nil
 }
 
 // storeUntil stores artifacts into higher priority caches than the given one.
@@ -198,11 +268,10 @@ func (mplex *cacheMultiplexer) storeUntil(anchor turbopath.AbsoluteSystemPath, k
 		g.Go(func() error {
 			err := c.Put(anchor, key, duration, files)
 			if err != nil {
-				cd := &util.CacheDisabledError{}
-				if errors.As(err, &cd) {
+				if removeErr := removalError(err); removeErr != nil {
 					toRemove[i] = &cacheRemoval{
 						cache: c,
-						err:   cd,
+						err:   removeErr,
 					}
 					// we don't want this to cancel other cache actions
 					return nil
@@ -241,7 +310,7 @@ func (mplex *cacheMultiplexer) removeCache(removal *cacheRemoval) {
 	}
 }
 
-func (mplex *cacheMultiplexer) Fetch(anchor turbopath.AbsoluteSystemPath, key string, files []string) (bool, []turbopath.AnchoredSystemPath, int, error) {
+func (mplex *cacheMultiplexer) Fetch(anchor turbopath.AbsoluteSystemPath, key string, files []string) (ItemStatus, []turbopath.AnchoredSystemPath, int, error) {
 	// Make a shallow copy of the caches, since storeUntil can call removeCache
 	mplex.mu.RLock()
 	caches := make([]Cache, len(mplex.caches))
@@ -251,13 +320,12 @@ func (mplex *cacheMultiplexer) Fetch(anchor turbopath.AbsoluteSystemPath, key st
 	// Retrieve from caches sequentially; if we did them simultaneously we could
 	// easily write the same file from two goroutines at once.
 	for i, cache := range caches {
-		ok, actualFiles, duration, err := cache.Fetch(anchor, key, files)
+		itemStatus, actualFiles, duration, err := cache.Fetch(anchor, key, files)
 		if err != nil {
-			cd := &util.CacheDisabledError{}
-			if errors.As(err, &cd) {
+			if removeErr := removalError(err); removeErr != nil {
 				mplex.removeCache(&cacheRemoval{
 					cache: cache,
-					err:   cd,
+					err:   removeErr,
 				})
 			}
 			// We're ignoring the error in the else case, since with this cache
@@ -265,23 +333,35 @@ func (mplex *cacheMultiplexer) Fetch(anchor turbopath.AbsoluteSystemPath, key st
 			// the operation. Future work that plumbs UI / Logging into the cache system
 			// should probably log this at least.
 		}
-		if ok {
+		if itemStatus.Hit() {
 			// Store this into other caches. We can ignore errors here because we know
 			// we have previously successfully stored in a higher-priority cache, and so the overall
 			// result is a success at fetching. Storing in lower-priority caches is an optimization.
 			_ = mplex.storeUntil(anchor, key, duration, actualFiles, i)
-			return ok, actualFiles, duration, err
+			return itemStatus, actualFiles, duration, err
 		}
 	}
 
-	return false, nil, 0, nil
+	return ItemStatus{}, nil, 0, nil
 }
 
 func (mplex *cacheMultiplexer) Exists(target string) (ItemStatus, error) {
+	mplex.mu.RLock()
+	caches := make([]Cache, len(mplex.caches))
+	copy(caches, mplex.caches)
+	mplex.mu.RUnlock()
+
 	syncCacheState := ItemStatus{}
-	for _, cache := range mplex.caches {
+	for _, cache := range caches {
 		itemStatus, err := cache.Exists(target)
 		if err != nil {
+			if removeErr := removalError(err); removeErr != nil {
+				mplex.removeCache(&cacheRemoval{
+					cache: cache,
+					err:   removeErr,
+				})
+				continue
+			}
 			return syncCacheState, err
 		}
 		syncCacheState.Local = syncCacheState.Local || itemStatus.Local
@@ -0,0 +1,94 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// _azureStorageVersion is the version of the Blob Storage REST API, which must
+// be at least 2017-11-09 for requests authorized with tokens
+const _azureStorageVersion = "2021-08-06"
+
+// azureStore stores artifacts as block blobs in an Azure Blob Storage container
+type azureStore struct {
+	objectStore
+	credentials *azureCredentialProvider
+	account     string
+	container   string
+	prefix      string
+	// sasToken, if set, authorizes requests in place of a token from credentials
+	sasToken string
+	// endpoint is set for Azurite and other endpoints than the account's default
+	endpoint *url.URL
+}
+
+var _ client = &azureStore{}
+
+// newAzureStore returns a store for a URL of the form
+// azblob://<account>/<container>/<prefix>. A different endpoint for the account,
+// such as Azurite's, can be given as the "endpoint" query parameter. Requests are
+// authorized with the SAS token in AZURE_STORAGE_SAS_TOKEN, if it's set.
+func newAzureStore(rawURL string) (*azureStore, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Azure Blob Storage URL %q: %w", rawURL, err)
+	}
+	container, prefix, _ := strings.Cut(strings.TrimPrefix(u.Path, "/"), "/")
+	if u.Scheme != "azblob" || u.Host == "" || container == "" {
+		return nil, fmt.Errorf("invalid Azure Blob Storage URL %q, expected azblob://<account>/<container>/<prefix>", rawURL)
+	}
+	credentials := newAzureCredentialProvider()
+	store := &azureStore{
+		credentials: credentials,
+		account:     u.Host,
+		container:   container,
+		prefix:      strings.Trim(prefix, "/"),
+		sasToken:    strings.TrimPrefix(credentials.getenv("AZURE_STORAGE_SAS_TOKEN"), "?"),
+	}
+	store.objectStore = objectStore{
+		httpClient:     &http.Client{Transport: newStorageTransport()},
+		location:       fmt.Sprintf("azblob://%v/%v/%v", store.account, store.container, store.prefix),
+		objectURL:      store.blobURL,
+		authorize:      store.authorize,
+		metadataPrefix: "X-Ms-Meta-",
+		putHeader:      http.Header{"X-Ms-Blob-Type": []string{"BlockBlob"}, "If-None-Match": []string{"*"}},
+	}
+	if endpoint := u.Query().Get("endpoint"); endpoint != "" {
+		store.endpoint, err = url.Parse(endpoint)
+		if err != nil || store.endpoint.Host == "" {
+			return nil, fmt.Errorf("invalid Azure Blob Storage endpoint %q", endpoint)
+		}
+	}
+	return store, nil
+}
+
+// blobURL returns the URL of the blob holding the artifact for hash
+func (s *azureStore) blobURL(hash string) *url.URL {
+	key := path.Join(s.prefix, hash+".tar.zst")
+	u := url.URL{Scheme: "https", Host: s.account + ".blob.core.windows.net"}
+	if s.endpoint != nil {
+		u = *s.endpoint
+	}
+	u.Path = path.Join("/", u.Path, s.container, key)
+	u.RawQuery = s.sasToken
+	return &u
+}
+
+// authorize adds a Microsoft Entra ID token to a request, unless the SAS token in
+// its URL authorizes it
+func (s *azureStore) authorize(ctx context.Context, req *http.Request, _ []byte) error {
+	req.Header.Set("X-Ms-Version", _azureStorageVersion)
+	if s.sasToken != "" {
+		return nil
+	}
+	token, err := s.credentials.get(ctx)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	return nil
+}
@@ -0,0 +1,287 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/util"
+	"github.com/vercel/turbo/cli/pkg/artifacts"
+	"gotest.tools/v3/assert"
+)
+
+// isolateAzureConfig keeps tests from using the Azure identity of the machine
+// they run on
+func isolateAzureConfig(t *testing.T) {
+	t.Helper()
+	for _, name := range []string{
+		"AZURE_STORAGE_SAS_TOKEN", "AZURE_FEDERATED_TOKEN_FILE", "AZURE_CLIENT_ID", "AZURE_TENANT_ID",
+		"AZURE_AUTHORITY_HOST", "IDENTITY_ENDPOINT", "IDENTITY_HEADER", _envCache,
+	} {
+		t.Setenv(name, "")
+	}
+	// Nothing listens on a closed listener's address
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NilError(t, err)
+	t.Setenv("AZURE_POD_IDENTITY_AUTHORITY_HOST", "http://"+listener.Addr().String())
+	assert.NilError(t, listener.Close())
+}
+
+// fakeAzureBlob is an Azure Blob Storage account that keeps blobs in memory, and
+// accepts requests with its SAS token or its access token
+type fakeAzureBlob struct {
+	sasToken string
+	token    string
+	mu       sync.Mutex
+	blobs    map[string][]byte
+	headers  map[string]http.Header
+}
+
+func (f *fakeAzureBlob) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	authorized := r.URL.RawQuery == f.sasToken
+	if f.sasToken == "" {
+		authorized = r.Header.Get("Authorization") == "Bearer "+f.token && r.Header.Get("X-Ms-Version") >= "2017-11-09"
+	}
+	if !authorized {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`<?xml version="1.0" encoding="utf-8"?><Error><Code>AuthenticationFailed</Code><Message>Server failed to authenticate the request.</Message></Error>`))
+		return
+	}
+	body, _ := ioutil.ReadAll(r.Body)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		if r.Header.Get("X-Ms-Blob-Type") != "BlockBlob" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if _, ok := f.blobs[r.URL.Path]; ok && r.Header.Get("If-None-Match") == "*" {
+			w.WriteHeader(http.StatusConflict)
+			_, _ = w.Write([]byte(`<?xml version="1.0" encoding="utf-8"?><Error><Code>BlobAlreadyExists</Code><Message>The specified blob already exists.</Message></Error>`))
+			return
+		}
+		f.blobs[r.URL.Path] = body
+		f.headers[r.URL.Path] = r.Header.Clone()
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodGet, http.MethodHead:
+		blob, ok := f.blobs[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		for name, values := range f.headers[r.URL.Path] {
+			if strings.HasPrefix(name, "X-Ms-Meta-") {
+				w.Header()[name] = values
+			}
+		}
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(blob)
+		}
+	}
+}
+
+func newFakeAzureBlob(t *testing.T) (*fakeAzureBlob, *httptest.Server) {
+	isolateAzureConfig(t)
+	f := &fakeAzureBlob{
+		blobs:   make(map[string][]byte),
+		headers: make(map[string]http.Header),
+	}
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+	return f, server
+}
+
+func TestAzureStore(t *testing.T) {
+	f, server := newFakeAzureBlob(t)
+	f.sasToken = "sv=2022-11-02&sp=rcw&sig=abc"
+	t.Setenv("AZURE_STORAGE_SAS_TOKEN", "?"+f.sasToken)
+	store, err := newAzureStore(fmt.Sprintf("azblob://devstoreaccount1/turbo/cache?endpoint=%v/devstoreaccount1", server.URL))
+	assert.NilError(t, err, "newAzureStore")
+	ctx := context.Background()
+
+	exists, err := store.ArtifactExists(ctx, "the-hash")
+	assert.NilError(t, err, "ArtifactExists")
+	assert.Assert(t, !exists)
+	_, err = store.FetchArtifact(ctx, "the-hash")
+	assert.ErrorIs(t, err, artifacts.ErrNotFound)
+	_, err = store.ArtifactMetadata(ctx, "the-hash")
+	assert.ErrorIs(t, err, artifacts.ErrNotFound)
+
+	metadata := artifacts.Metadata{Duration: 1200, Tag: "the-tag", Digest: "sha256:abc", KeyID: "key-1", PlaintextDigest: "sha256:def", HashSchema: 1}
+	assert.NilError(t, store.PutArtifact(ctx, "the-hash", strings.NewReader("contents"), metadata), "PutArtifact")
+	assert.DeepEqual(t, f.blobs["/devstoreaccount1/turbo/cache/the-hash.tar.zst"], []byte("contents"))
+	// Blobs are only created, never replaced
+	err = store.PutArtifact(ctx, "the-hash", strings.NewReader("other contents"), metadata)
+	assert.ErrorIs(t, err, artifacts.ErrExists)
+	assert.DeepEqual(t, f.blobs["/devstoreaccount1/turbo/cache/the-hash.tar.zst"], []byte("contents"))
+
+	exists, err = store.ArtifactExists(ctx, "the-hash")
+	assert.NilError(t, err, "ArtifactExists")
+	assert.Assert(t, exists)
+	headMetadata, err := store.ArtifactMetadata(ctx, "the-hash")
+	assert.NilError(t, err, "ArtifactMetadata")
+	assert.DeepEqual(t, *headMetadata, metadata)
+	artifact, err := store.FetchArtifact(ctx, "the-hash")
+	assert.NilError(t, err, "FetchArtifact")
+	defer func() { _ = artifact.Body.Close() }()
+	assert.DeepEqual(t, artifact.Metadata, metadata)
+	contents, err := ioutil.ReadAll(artifact.Body)
+	assert.NilError(t, err)
+	assert.Equal(t, string(contents), "contents")
+
+	// A SAS token that isn't accepted means Azure can't be used for the rest of the run
+	f.sasToken = "sv=2022-11-02&sp=rcw&sig=rotated"
+	_, err = store.FetchArtifact(ctx, "the-hash")
+	cd := &util.CacheDisabledError{}
+	assert.Assert(t, errors.As(err, &cd), "expected a CacheDisabledError, got %v", err)
+	assert.ErrorContains(t, err, "Server failed to authenticate the request")
+}
+
+func TestAzureStoreTooManyFailures(t *testing.T) {
+	_, server := newFakeAzureBlob(t)
+	t.Setenv("AZURE_STORAGE_SAS_TOKEN", "sig=abc")
+	store, err := newAzureStore(fmt.Sprintf("azblob://account/turbo?endpoint=%v", server.URL))
+	assert.NilError(t, err, "newAzureStore")
+	server.Close()
+	for i := 0; i < int(_maxObjectStoreFailCount); i++ {
+		_, err = store.ArtifactExists(context.Background(), "the-hash")
+		assert.Assert(t, err != nil && !errors.Is(err, util.ErrTooManyFailures))
+	}
+	_, err = store.ArtifactExists(context.Background(), "the-hash")
+	assert.ErrorIs(t, err, util.ErrTooManyFailures)
+}
+
+func TestAzureHTTPCache(t *testing.T) {
+	f, server := newFakeAzureBlob(t)
+	f.token = "managed-identity-token"
+	identity := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, r.Header.Get("X-IDENTITY-HEADER"), "the-header")
+		assert.Equal(t, r.URL.Query().Get("resource"), _azureStorageResource)
+		_, _ = w.Write([]byte(`{"access_token":"managed-identity-token","expires_in":"86399","token_type":"Bearer"}`))
+	}))
+	defer identity.Close()
+	t.Setenv("IDENTITY_ENDPOINT", identity.URL)
+	t.Setenv("IDENTITY_HEADER", "the-header")
+	t.Setenv(_envCache, fmt.Sprintf("azblob://account/turbo?endpoint=%v", server.URL))
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	file := turbopath.AnchoredUnixPath("dist/index.js").ToSystemPath()
+	path := file.RestoreAnchor(repoRoot)
+	assert.NilError(t, path.EnsureDir())
+	assert.NilError(t, path.WriteFile([]byte("console.log('hello');"), 0644))
+
+	opts := Opts{SkipFilesystem: true}
+	c, err := newSyncCache(opts, repoRoot, &errorResp{err: errors.New("not linked")}, &nullRecorder{}, func(_ Cache, err error) {
+		t.Errorf("Azure Blob Storage was removed: %v", err)
+	}, nil)
+	assert.NilError(t, err, "newSyncCache")
+	mplex := c.(*cacheMultiplexer)
+	mplex.caches[0].(*httpCache).repoRoot = repoRoot
+
+	assert.NilError(t, c.Put(repoRoot, "the-hash", 100, []turbopath.AnchoredSystemPath{file}), "Put")
+	assert.NilError(t, path.Remove())
+	status, files, duration, err := c.Fetch(repoRoot, "the-hash", nil)
+	assert.NilError(t, err, "Fetch")
+	assert.Assert(t, status.Remote)
+	assert.Equal(t, duration, 100)
+	assert.Equal(t, len(files), 1)
+	contents, err := path.ReadFile()
+	assert.NilError(t, err, "ReadFile")
+	assert.Equal(t, string(contents), "console.log('hello');")
+}
+
+func TestNewAzureStore(t *testing.T) {
+	isolateAzureConfig(t)
+	_, err := newAzureStore("azblob://account")
+	assert.ErrorContains(t, err, "invalid Azure Blob Storage URL")
+	store, err := newAzureStore("azblob://account/container/prefix/")
+	assert.NilError(t, err, "newAzureStore")
+	assert.Equal(t, store.blobURL("abc").String(), "https://account.blob.core.windows.net/container/prefix/abc.tar.zst")
+	t.Setenv("AZURE_STORAGE_SAS_TOKEN", "sv=2022-11-02&sig=abc")
+	store, err = newAzureStore("azblob://account/container")
+	assert.NilError(t, err, "newAzureStore")
+	assert.Equal(t, store.blobURL("abc").String(), "https://account.blob.core.windows.net/container/abc.tar.zst?sv=2022-11-02&sig=abc")
+
+	c, err := remoteClient(Opts{RemoteCacheOpts: fs.RemoteCacheOptions{Provider: "azblob://account/container"}}, nil)
+	assert.NilError(t, err, "remoteClient")
+	_, ok := c.(*azureStore)
+	assert.Assert(t, ok, "expected an azureStore, got %T", c)
+
+	_, err = newAzureCredentialProvider().get(context.Background())
+	assert.ErrorContains(t, err, "no Azure credentials were found")
+}
+
+func TestAzureCredentialsWorkloadIdentity(t *testing.T) {
+	isolateAzureConfig(t)
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		assert.Equal(t, r.URL.Path, "/the-tenant/oauth2/v2.0/token")
+		assert.NilError(t, r.ParseForm())
+		assert.Equal(t, r.PostForm.Get("client_id"), "the-client")
+		assert.Equal(t, r.PostForm.Get("client_assertion"), "the-federated-token")
+		assert.Equal(t, r.PostForm.Get("scope"), "https://storage.azure.com/.default")
+		_, _ = w.Write([]byte(`{"token_type":"Bearer","expires_in":3599,"access_token":"workload-token"}`))
+	}))
+	defer server.Close()
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	assert.NilError(t, ioutil.WriteFile(tokenFile, []byte("the-federated-token\n"), 0600))
+	t.Setenv("AZURE_FEDERATED_TOKEN_FILE", tokenFile)
+	t.Setenv("AZURE_CLIENT_ID", "the-client")
+	t.Setenv("AZURE_TENANT_ID", "the-tenant")
+	t.Setenv("AZURE_AUTHORITY_HOST", server.URL+"/")
+
+	provider := newAzureCredentialProvider()
+	token, err := provider.get(context.Background())
+	assert.NilError(t, err)
+	assert.Equal(t, token.AccessToken, "workload-token")
+
+	// Tokens are cached until shortly before they expire
+	_, err = provider.get(context.Background())
+	assert.NilError(t, err)
+	assert.Equal(t, requests, 1)
+	provider.now = func() time.Time { return token.Expires.Add(-time.Minute) }
+	_, err = provider.get(context.Background())
+	assert.NilError(t, err)
+	assert.Equal(t, requests, 2)
+}
+
+func TestAzureCredentialsInstanceMetadata(t *testing.T) {
+	isolateAzureConfig(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata") != "true" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		assert.Equal(t, r.URL.Path, "/metadata/identity/oauth2/token")
+		if r.URL.Query().Get("client_id") != "user-assigned" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":"invalid_request","error_description":"Identity not found"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"access_token":"vm-token","expires_in":"86399","expires_on":"1700000000","resource":"https://storage.azure.com","token_type":"Bearer"}`))
+	}))
+	defer server.Close()
+	t.Setenv("AZURE_POD_IDENTITY_AUTHORITY_HOST", server.URL)
+
+	// A virtual machine without the identity has no credentials
+	_, err := newAzureCredentialProvider().get(context.Background())
+	assert.ErrorContains(t, err, "no Azure credentials were found")
+
+	t.Setenv("AZURE_CLIENT_ID", "user-assigned")
+	token, err := newAzureCredentialProvider().get(context.Background())
+	assert.NilError(t, err)
+	assert.Equal(t, token.AccessToken, "vm-token")
+	assert.Assert(t, !token.Expires.IsZero())
+}
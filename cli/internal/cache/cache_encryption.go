@@ -0,0 +1,196 @@
+package cache
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// _encryptionKeySize is the size of an AES-256 key
+const _encryptionKeySize = 32
+
+// artifactEncryption encrypts artifacts with AES-256-GCM before they're uploaded to
+// the Remote Cache, and decrypts them when they're downloaded. Each artifact records
+// the ID of the key that encrypted it, so that keys can be rotated: the previous keys
+// still decrypt the artifacts that were uploaded before the rotation.
+type artifactEncryption struct {
+	enabled bool
+
+	once     sync.Once
+	keys     *encryptionKeys
+	errLoad  error
+	getenv   func(string) string
+	runShell func(command string) ([]byte, error)
+}
+
+// encryptionKeys are the current key, which encrypts uploads, and every key that
+// can decrypt downloads, by ID
+type encryptionKeys struct {
+	currentID string
+	byID      map[string][]byte
+}
+
+func newArtifactEncryption(enabled bool) *artifactEncryption {
+	return &artifactEncryption{
+		enabled:  enabled,
+		getenv:   os.Getenv,
+		runShell: runKeyCommand,
+	}
+}
+
+func (ae *artifactEncryption) isEnabled() bool {
+	return ae != nil && ae.enabled
+}
+
+// loadKeys reads the keys once. The current key is TURBO_REMOTE_CACHE_ENCRYPTION_KEY,
+// or else the output of TURBO_REMOTE_CACHE_ENCRYPTION_KEY_COMMAND, which can fetch it
+// from a key management service. TURBO_REMOTE_CACHE_ENCRYPTION_PREVIOUS_KEYS is a
+// comma-separated list of keys that were rotated out.
+func (ae *artifactEncryption) loadKeys() (*encryptionKeys, error) {
+	ae.once.Do(func() {
+		ae.keys, ae.errLoad = ae.readKeys()
+	})
+	return ae.keys, ae.errLoad
+}
+
+func (ae *artifactEncryption) readKeys() (*encryptionKeys, error) {
+	encoded := ae.getenv("TURBO_REMOTE_CACHE_ENCRYPTION_KEY")
+	if encoded == "" {
+		if command := ae.getenv("TURBO_REMOTE_CACHE_ENCRYPTION_KEY_COMMAND"); command != "" {
+			out, err := ae.runShell(command)
+			if err != nil {
+				return nil, err
+			}
+			encoded = string(out)
+		}
+	}
+	if strings.TrimSpace(encoded) == "" {
+		return nil, errors.New("encryption key not found. You must specify a key in the TURBO_REMOTE_CACHE_ENCRYPTION_KEY environment variable, or a command that prints one in TURBO_REMOTE_CACHE_ENCRYPTION_KEY_COMMAND")
+	}
+	current, err := decodeEncryptionKey(encoded)
+	if err != nil {
+		return nil, err
+	}
+	keys := &encryptionKeys{
+		currentID: encryptionKeyID(current),
+		byID:      map[string][]byte{encryptionKeyID(current): current},
+	}
+	if previous := ae.getenv("TURBO_REMOTE_CACHE_ENCRYPTION_PREVIOUS_KEYS"); previous != "" {
+		for _, encoded := range strings.Split(previous, ",") {
+			if strings.TrimSpace(encoded) == "" {
+				continue
+			}
+			key, err := decodeEncryptionKey(encoded)
+			if err != nil {
+				return nil, fmt.Errorf("invalid key in TURBO_REMOTE_CACHE_ENCRYPTION_PREVIOUS_KEYS: %w", err)
+			}
+			keys.byID[encryptionKeyID(key)] = key
+		}
+	}
+	return keys, nil
+}
+
+// decodeEncryptionKey decodes a base64-encoded 256-bit key
+func decodeEncryptionKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("encryption key must be base64-encoded: %w", err)
+	}
+	if len(key) != _encryptionKeySize {
+		return nil, fmt.Errorf("encryption key must be %v bytes, got %v", _encryptionKeySize, len(key))
+	}
+	return key, nil
+}
+
+// encryptionKeyID identifies a key by a fingerprint, which is safe to store alongside
+// the artifacts it encrypts
+func encryptionKeyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+// encrypt seals an artifact with the current key. The artifact's hash is
+// authenticated along with it, so that an artifact can't be served for another hash.
+// The result is the nonce followed by the ciphertext.
+func (ae *artifactEncryption) encrypt(hash string, plaintext []byte) ([]byte, string, error) {
+	keys, err := ae.loadKeys()
+	if err != nil {
+		return nil, "", err
+	}
+	aead, err := newAEAD(keys.byID[keys.currentID])
+	if err != nil {
+		return nil, "", err
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, "", err
+	}
+	return aead.Seal(nonce, nonce, plaintext, []byte(hash)), keys.currentID, nil
+}
+
+// decrypt opens an artifact that was encrypted with the key keyID. If the Remote
+// Cache didn't keep the key ID, each key is tried.
+func (ae *artifactEncryption) decrypt(hash string, ciphertext []byte, keyID string) ([]byte, error) {
+	keys, err := ae.loadKeys()
+	if err != nil {
+		return nil, err
+	}
+	candidates := keys.byID
+	if keyID != "" {
+		key, ok := keys.byID[keyID]
+		if !ok {
+			return nil, fmt.Errorf("artifact is encrypted with key %v, which is not configured", keyID)
+		}
+		candidates = map[string][]byte{keyID: key}
+	}
+	for _, key := range candidates {
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, err
+		}
+		if len(ciphertext) < aead.NonceSize() {
+			return nil, errors.New("artifact is too short to be encrypted")
+		}
+		nonce, sealed := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+		if plaintext, err := aead.Open(nil, nonce, sealed, []byte(hash)); err == nil {
+			return plaintext, nil
+		}
+	}
+	return nil, errors.New("artifact cannot be decrypted, it was modified or encrypted with another key")
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// runKeyCommand runs the command that prints the encryption key
+func runKeyCommand(command string) ([]byte, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("encryption key command \"%v\" failed: %w\n%v", command, err, stderr.String())
+	}
+	return out, nil
+}
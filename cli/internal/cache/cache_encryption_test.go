@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func testEncryptionKey(b byte) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, _encryptionKeySize))
+}
+
+func newTestEncryption(env map[string]string) *artifactEncryption {
+	ae := newArtifactEncryption(true)
+	ae.getenv = func(name string) string { return env[name] }
+	return ae
+}
+
+func TestArtifactEncryption(t *testing.T) {
+	ae := newTestEncryption(map[string]string{"TURBO_REMOTE_CACHE_ENCRYPTION_KEY": testEncryptionKey(1)})
+	plaintext := []byte("some artifact")
+
+	ciphertext, keyID, err := ae.encrypt("the-hash", plaintext)
+	assert.NilError(t, err, "encrypt")
+	assert.Equal(t, keyID, encryptionKeyID(bytes.Repeat([]byte{1}, _encryptionKeySize)))
+	assert.Assert(t, !bytes.Contains(ciphertext, plaintext))
+	again, _, err := ae.encrypt("the-hash", plaintext)
+	assert.NilError(t, err, "encrypt")
+	assert.Assert(t, !bytes.Equal(ciphertext, again), "expected a new nonce for each encryption")
+
+	decrypted, err := ae.decrypt("the-hash", ciphertext, keyID)
+	assert.NilError(t, err, "decrypt")
+	assert.DeepEqual(t, decrypted, plaintext)
+	// The Remote Cache may not keep the key ID
+	decrypted, err = ae.decrypt("the-hash", ciphertext, "")
+	assert.NilError(t, err, "decrypt")
+	assert.DeepEqual(t, decrypted, plaintext)
+
+	_, err = ae.decrypt("another-hash", ciphertext, keyID)
+	assert.ErrorContains(t, err, "cannot be decrypted")
+	tampered := append([]byte{}, ciphertext...)
+	tampered[len(tampered)-1] ^= 1
+	_, err = ae.decrypt("the-hash", tampered, keyID)
+	assert.ErrorContains(t, err, "cannot be decrypted")
+	_, err = ae.decrypt("the-hash", ciphertext, "unknown")
+	assert.ErrorContains(t, err, "encrypted with key unknown, which is not configured")
+}
+
+func TestArtifactEncryptionRotation(t *testing.T) {
+	old := newTestEncryption(map[string]string{"TURBO_REMOTE_CACHE_ENCRYPTION_KEY": testEncryptionKey(1)})
+	ciphertext, oldID, err := old.encrypt("the-hash", []byte("some artifact"))
+	assert.NilError(t, err, "encrypt")
+
+	rotated := newTestEncryption(map[string]string{
+		"TURBO_REMOTE_CACHE_ENCRYPTION_KEY":           testEncryptionKey(2),
+		"TURBO_REMOTE_CACHE_ENCRYPTION_PREVIOUS_KEYS": testEncryptionKey(3) + "," + testEncryptionKey(1),
+	})
+	decrypted, err := rotated.decrypt("the-hash", ciphertext, oldID)
+	assert.NilError(t, err, "decrypt")
+	assert.Equal(t, string(decrypted), "some artifact")
+	_, newID, err := rotated.encrypt("the-hash", []byte("some artifact"))
+	assert.NilError(t, err, "encrypt")
+	assert.Assert(t, newID != oldID)
+
+	_, err = old.decrypt("the-hash", ciphertext, newID)
+	assert.ErrorContains(t, err, "not configured")
+}
+
+func TestArtifactEncryptionKeys(t *testing.T) {
+	ae := newTestEncryption(map[string]string{"TURBO_REMOTE_CACHE_ENCRYPTION_KEY_COMMAND": "kms decrypt"})
+	var commands []string
+	ae.runShell = func(command string) ([]byte, error) {
+		commands = append(commands, command)
+		return []byte(testEncryptionKey(1) + "\n"), nil
+	}
+	_, keyID, err := ae.encrypt("the-hash", []byte("some artifact"))
+	assert.NilError(t, err, "encrypt")
+	assert.Equal(t, keyID, encryptionKeyID(bytes.Repeat([]byte{1}, _encryptionKeySize)))
+	_, _, err = ae.encrypt("another-hash", []byte("some artifact"))
+	assert.NilError(t, err, "encrypt")
+	assert.DeepEqual(t, commands, []string{"kms decrypt"})
+
+	ae = newTestEncryption(map[string]string{"TURBO_REMOTE_CACHE_ENCRYPTION_KEY_COMMAND": "kms decrypt"})
+	ae.runShell = func(command string) ([]byte, error) {
+		return nil, errors.New("access denied")
+	}
+	_, _, err = ae.encrypt("the-hash", nil)
+	assert.ErrorContains(t, err, "access denied")
+
+	cases := map[string]map[string]string{
+		"encryption key not found": {},
+		"must be base64-encoded":   {"TURBO_REMOTE_CACHE_ENCRYPTION_KEY": "not base64!"},
+		"must be 32 bytes, got 5":  {"TURBO_REMOTE_CACHE_ENCRYPTION_KEY": base64.StdEncoding.EncodeToString([]byte("short"))},
+		"invalid key in TURBO_REMOTE_CACHE_ENCRYPTION_PREVIOUS_KEYS": {
+			"TURBO_REMOTE_CACHE_ENCRYPTION_KEY":           testEncryptionKey(1),
+			"TURBO_REMOTE_CACHE_ENCRYPTION_PREVIOUS_KEYS": "c2hvcnQ=",
+		},
+	}
+	for expected, env := range cases {
+		_, _, err := newTestEncryption(env).encrypt("the-hash", nil)
+		assert.ErrorContains(t, err, expected)
+	}
+}
@@ -6,18 +6,28 @@
 package cache
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"os"
+	"sync"
+	"time"
 
 	"github.com/vercel/turbo/cli/internal/analytics"
 	"github.com/vercel/turbo/cli/internal/cacheitem"
+	"github.com/vercel/turbo/cli/internal/fs"
 	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/util"
 )
 
 // fsCache is a local filesystem cache
 type fsCache struct {
 	cacheDirectory turbopath.AbsoluteSystemPath
 	recorder       analytics.Recorder
+	// maxSize, if set, is the size that the cache is kept under after each Put
+	maxSize int64
+	// evictMu keeps concurrent Puts from evicting the same artifacts
+	evictMu sync.Mutex
 }
 
 // newFsCache creates a new filesystem cache
@@ -29,11 +39,12 @@ func newFsCache(opts Opts, recorder analytics.Recorder, repoRoot turbopath.Absol
 	return &fsCache{
 		cacheDirectory: cacheDir,
 		recorder:       recorder,
+		maxSize:        opts.MaxSize,
 	}, nil
 }
 
-// Fetch returns true if items are cached. It moves them into position as a side effect.
-func (f *fsCache) Fetch(anchor turbopath.AbsoluteSystemPath, hash string, _unusedOutputGlobs []string) (bool, []turbopath.AnchoredSystemPath, int, error) {
+// Fetch returns a local ItemStatus if items are cached. It moves them into position as a side effect.
+func (f *fsCache) Fetch(anchor turbopath.AbsoluteSystemPath, hash string, _unusedOutputGlobs []string) (ItemStatus, []turbopath.AnchoredSystemPath, int, error) {
 	uncompressedCachePath := f.cacheDirectory.UntypedJoin(hash + ".tar")
 	compressedCachePath := f.cacheDirectory.UntypedJoin(hash + ".tar.zst")
 
@@ -45,33 +56,48 @@ func (f *fsCache) Fetch(anchor turbopath.AbsoluteSystemPath, hash string, _unuse
 	} else {
 		// It's not in the cache, bail now
 		f.logFetch(false, hash, 0)
-		return false, nil, 0, nil
+		return ItemStatus{}, nil, 0, nil
 	}
 
 	cacheItem, openErr := cacheitem.Open(actualCachePath)
 	if openErr != nil {
-		return false, nil, 0, openErr
+		return ItemStatus{}, nil, 0, openErr
 	}
 
-	restoredFiles, restoreErr := cacheItem.Restore(anchor)
-	if restoreErr != nil {
+	meta, err := ReadCacheMetaFile(f.cacheDirectory.UntypedJoin(hash + "-meta.json"))
+	if err != nil {
 		_ = cacheItem.Close()
-		return false, nil, 0, restoreErr
+		return ItemStatus{}, nil, 0, fmt.Errorf("error reading cache metadata: %w", err)
+	}
+	if !compatibleHashSchema(meta.HashSchema) {
+		_ = cacheItem.Close()
+		// The same hash means something else to the version of turbo that cached it
+		f.logFetch(false, hash, 0)
+		return ItemStatus{}, nil, 0, nil
 	}
 
-	meta, err := ReadCacheMetaFile(f.cacheDirectory.UntypedJoin(hash + "-meta.json"))
-	if err != nil {
+	restoredFiles, restoreErr := cacheItem.Restore(anchor)
+	if restoreErr != nil {
 		_ = cacheItem.Close()
-		return false, nil, 0, fmt.Errorf("error reading cache metadata: %w", err)
+		return ItemStatus{}, nil, 0, restoreErr
 	}
+
 	f.logFetch(true, hash, meta.Duration)
+	// The modification time of the archive records when it was last used, for eviction
+	now := time.Now()
+	_ = os.Chtimes(actualCachePath.ToString(), now, now)
 
 	// Wait to see what happens with close.
 	closeErr := cacheItem.Close()
 	if closeErr != nil {
-		return false, restoredFiles, 0, closeErr
+		return ItemStatus{}, restoredFiles, 0, closeErr
 	}
-	return true, restoredFiles, meta.Duration, nil
+	return ItemStatus{Local: true}, restoredFiles, meta.Duration, nil
+}
+
+// LocalArtifactExists returns true if the filesystem cache in cacheDir has an artifact for hash
+func LocalArtifactExists(cacheDir turbopath.AbsoluteSystemPath, hash string) bool {
+	return cacheDir.UntypedJoin(hash+".tar").FileExists() || cacheDir.UntypedJoin(hash+".tar.zst").FileExists()
 }
 
 func (f *fsCache) Exists(hash string) (ItemStatus, error) {
@@ -111,22 +137,37 @@ func (f *fsCache) Put(anchor turbopath.AbsoluteSystemPath, hash string, duration
 	for _, file := range files {
 		err := cacheItem.AddFile(anchor, file)
 		if err != nil {
-			_ = cacheItem.Close()
+			// Don't leave behind an artifact with only some of the outputs
+			_ = cacheItem.Discard()
 			return err
 		}
 	}
 
 	writeErr := WriteCacheMetaFile(f.cacheDirectory.UntypedJoin(hash+"-meta.json"), &CacheMetadata{
-		Duration: duration,
-		Hash:     hash,
+		Duration:   duration,
+		Hash:       hash,
+		HashSchema: fs.HashSchemaVersion,
 	})
 
 	if writeErr != nil {
-		_ = cacheItem.Close()
+		_ = cacheItem.Discard()
 		return writeErr
 	}
 
-	return cacheItem.Close()
+	// The archive is moved into place last, so that an artifact is only found once
+	// its metadata is in place too
+	if err := cacheItem.Close(); err != nil {
+		_ = cacheItem.Discard()
+		return err
+	}
+	if f.maxSize > 0 {
+		f.evictMu.Lock()
+		defer f.evictMu.Unlock()
+		if _, err := GC(f.cacheDirectory, GCOpts{MaxSize: f.maxSize, keep: hash}, time.Now()); err != nil {
+			return fmt.Errorf("failed to evict artifacts to keep the cache under %v: %w", util.FormatByteSize(f.maxSize), err)
+		}
+	}
+	return nil
 }
 
 func (f *fsCache) Clean(anchor turbopath.AbsoluteSystemPath) {
@@ -144,6 +185,9 @@ func (f *fsCache) Shutdown() {}
 type CacheMetadata struct {
 	Hash     string `json:"hash"`
 	Duration int    `json:"duration"`
+	// HashSchema is the version of the hash schema that Hash was calculated with,
+	// or 0 for artifacts from versions of turbo that didn't record it
+	HashSchema int `json:"hashSchema,omitempty"`
 }
 
 // WriteCacheMetaFile writes cache metadata file at a path
@@ -152,7 +196,7 @@ func WriteCacheMetaFile(path turbopath.AbsoluteSystemPath, config *CacheMetadata
 	if marshalErr != nil {
 		return marshalErr
 	}
-	writeFilErr := path.WriteFile(jsonBytes, 0644)
+	writeFilErr := fs.WriteFileAtomic(path, bytes.NewReader(jsonBytes), 0644)
 	if writeFilErr != nil {
 		return writeFilErr
 	}
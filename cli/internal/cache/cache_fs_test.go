@@ -6,6 +6,7 @@ import (
 
 	"github.com/vercel/turbo/cli/internal/analytics"
 	"github.com/vercel/turbo/cli/internal/cacheitem"
+	"github.com/vercel/turbo/cli/internal/fs"
 	"github.com/vercel/turbo/cli/internal/turbopath"
 	"gotest.tools/v3/assert"
 )
@@ -216,10 +217,10 @@ func TestFetch(t *testing.T) {
 
 	outputDir := turbopath.AbsoluteSystemPath(t.TempDir())
 	dstOutputPath := "some-package"
-	hit, files, _, err := cache.Fetch(outputDir, "the-hash", []string{})
+	itemStatus, files, _, err := cache.Fetch(outputDir, "the-hash", []string{})
 	assert.NilError(t, err, "Fetch")
-	if !hit {
-		t.Error("Fetch got false, want true")
+	if itemStatus.Source() != CacheSourceLocal {
+		t.Errorf("Fetch source got %q, want %q", itemStatus.Source(), CacheSourceLocal)
 	}
 	if len(files) != len(inputFiles) {
 		t.Errorf("len(files) got %v, want %v", len(files), len(inputFiles))
@@ -250,3 +251,27 @@ func TestFetch(t *testing.T) {
 	assert.NilError(t, circleReadlinkErr, "Circle Readlink")
 	assert.Equal(t, circleTarget, srcCircleLinkTarget.ToString())
 }
+
+func TestFetchRejectsOtherHashSchemas(t *testing.T) {
+	repoRoot := turbopath.AbsoluteSystemPath(t.TempDir())
+	cacheDir := turbopath.AbsoluteSystemPath(t.TempDir())
+	file := turbopath.AnchoredUnixPath("dist/index.js").ToSystemPath()
+	path := file.RestoreAnchor(repoRoot)
+	assert.NilError(t, path.EnsureDir(), "EnsureDir")
+	assert.NilError(t, path.WriteFile([]byte("hello"), 0644), "WriteFile")
+
+	cache := &fsCache{cacheDirectory: cacheDir, recorder: &dummyRecorder{}}
+	assert.NilError(t, cache.Put(repoRoot, "the-hash", 0, []turbopath.AnchoredSystemPath{file}), "Put")
+	meta, err := ReadCacheMetaFile(cacheDir.UntypedJoin("the-hash-meta.json"))
+	assert.NilError(t, err, "ReadCacheMetaFile")
+	assert.Equal(t, meta.HashSchema, fs.HashSchemaVersion)
+
+	meta.HashSchema = fs.HashSchemaVersion + 1
+	assert.NilError(t, WriteCacheMetaFile(cacheDir.UntypedJoin("the-hash-meta.json"), meta), "WriteCacheMetaFile")
+	outputDir := turbopath.AbsoluteSystemPath(t.TempDir())
+	itemStatus, files, _, err := cache.Fetch(outputDir, "the-hash", nil)
+	assert.NilError(t, err, "Fetch")
+	assert.Equal(t, itemStatus.Local, false)
+	assert.Equal(t, len(files), 0)
+	assert.Assert(t, !file.RestoreAnchor(outputDir).FileExists(), "nothing is restored")
+}
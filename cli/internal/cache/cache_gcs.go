@@ -0,0 +1,76 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// gcsStore stores artifacts as objects in a Google Cloud Storage bucket, using its
+// XML API
+type gcsStore struct {
+	objectStore
+	credentials *gcpCredentialProvider
+	bucket      string
+	prefix      string
+	// endpoint is set for emulators and private endpoints
+	endpoint *url.URL
+}
+
+var _ client = &gcsStore{}
+
+// newGCSStore returns a store for a URL of the form gs://<bucket>/<prefix>. A
+// different endpoint can be given as the "endpoint" query parameter.
+func newGCSStore(rawURL string) (*gcsStore, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Cloud Storage URL %q: %w", rawURL, err)
+	}
+	if u.Scheme != "gs" || u.Host == "" {
+		return nil, fmt.Errorf("invalid Cloud Storage URL %q, expected gs://<bucket>/<prefix>", rawURL)
+	}
+	store := &gcsStore{
+		credentials: newGCPCredentialProvider(),
+		bucket:      u.Host,
+		prefix:      strings.Trim(u.Path, "/"),
+	}
+	store.objectStore = objectStore{
+		httpClient:     &http.Client{Transport: newStorageTransport()},
+		location:       fmt.Sprintf("gs://%v/%v", store.bucket, store.prefix),
+		objectURL:      store.objectURL,
+		authorize:      store.authorize,
+		metadataPrefix: "X-Goog-Meta-",
+		putHeader:      http.Header{"X-Goog-If-Generation-Match": []string{"0"}},
+	}
+	if endpoint := u.Query().Get("endpoint"); endpoint != "" {
+		store.endpoint, err = url.Parse(endpoint)
+		if err != nil || store.endpoint.Host == "" {
+			return nil, fmt.Errorf("invalid Cloud Storage endpoint %q", endpoint)
+		}
+	}
+	return store, nil
+}
+
+// objectURL returns the URL of the object holding the artifact for hash
+func (s *gcsStore) objectURL(hash string) *url.URL {
+	key := path.Join(s.prefix, hash+".tar.zst")
+	u := url.URL{Scheme: "https", Host: "storage.googleapis.com"}
+	if s.endpoint != nil {
+		u = *s.endpoint
+	}
+	u.Path = path.Join("/", u.Path, s.bucket, key)
+	return &u
+}
+
+// authorize adds an OAuth 2.0 access token to a request
+func (s *gcsStore) authorize(ctx context.Context, req *http.Request, _ []byte) error {
+	token, err := s.credentials.get(ctx)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	return nil
+}
@@ -0,0 +1,366 @@
+package cache
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/util"
+	"github.com/vercel/turbo/cli/pkg/artifacts"
+	"gotest.tools/v3/assert"
+)
+
+// isolateGCPConfig keeps tests from using the Google Cloud credentials of whoever
+// runs them, or the metadata server of the machine they run on
+func isolateGCPConfig(t *testing.T) {
+	t.Helper()
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", "")
+	t.Setenv("CLOUDSDK_CONFIG", t.TempDir())
+	t.Setenv(_envCache, "")
+	// Nothing listens on a closed listener's address
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NilError(t, err)
+	t.Setenv("GCE_METADATA_HOST", listener.Addr().String())
+	assert.NilError(t, listener.Close())
+}
+
+// fakeGCS is a Cloud Storage bucket that keeps objects in memory, and rejects
+// requests without its access token
+type fakeGCS struct {
+	token   string
+	mu      sync.Mutex
+	objects map[string][]byte
+	headers map[string]http.Header
+}
+
+func (f *fakeGCS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer "+f.token {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`<Error><Code>AuthenticationRequired</Code><Message>Authentication required.</Message></Error>`))
+		return
+	}
+	body, _ := ioutil.ReadAll(r.Body)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		if _, ok := f.objects[r.URL.Path]; ok && r.Header.Get("X-Goog-If-Generation-Match") == "0" {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			_, _ = w.Write([]byte(`<Error><Code>PreconditionFailed</Code><Message>At least one of the pre-conditions you specified did not hold.</Message></Error>`))
+			return
+		}
+		f.objects[r.URL.Path] = body
+		f.headers[r.URL.Path] = r.Header.Clone()
+	case http.MethodGet, http.MethodHead:
+		object, ok := f.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		for name, values := range f.headers[r.URL.Path] {
+			if strings.HasPrefix(name, "X-Goog-Meta-") {
+				w.Header()[name] = values
+			}
+		}
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(object)
+		}
+	}
+}
+
+// newFakeTokenServer returns an OAuth token endpoint that hands out token for
+// assertions signed with key by the service account email
+func newFakeTokenServer(t *testing.T, key *rsa.PrivateKey, email string, token string) (*httptest.Server, *int) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		assert.NilError(t, r.ParseForm())
+		assert.Equal(t, r.PostForm.Get("grant_type"), "urn:ietf:params:oauth:grant-type:jwt-bearer")
+		parts := strings.Split(r.PostForm.Get("assertion"), ".")
+		assert.Equal(t, len(parts), 3)
+		digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+		assert.NilError(t, err)
+		if rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		claims, err := base64.RawURLEncoding.DecodeString(parts[1])
+		assert.NilError(t, err)
+		var parsed map[string]interface{}
+		assert.NilError(t, json.Unmarshal(claims, &parsed))
+		assert.Equal(t, parsed["iss"], email)
+		assert.Equal(t, parsed["scope"], _gcsScope)
+		fmt.Fprintf(w, `{"access_token":%q,"expires_in":3599,"token_type":"Bearer"}`, token)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+// writeServiceAccountKey writes a service account key file for key, and sets
+// GOOGLE_APPLICATION_CREDENTIALS to it
+func writeServiceAccountKey(t *testing.T, key *rsa.PrivateKey, email string, tokenURI string) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.NilError(t, err)
+	contents, err := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"client_email":   email,
+		"private_key_id": "key-id",
+		"private_key":    string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"token_uri":      tokenURI,
+	})
+	assert.NilError(t, err)
+	path := filepath.Join(t.TempDir(), "key.json")
+	assert.NilError(t, ioutil.WriteFile(path, contents, 0600))
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", path)
+}
+
+func newFakeGCS(t *testing.T) (*fakeGCS, *httptest.Server) {
+	isolateGCPConfig(t)
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NilError(t, err)
+	tokenServer, _ := newFakeTokenServer(t, key, "ci@project.iam.gserviceaccount.com", "the-token")
+	writeServiceAccountKey(t, key, "ci@project.iam.gserviceaccount.com", tokenServer.URL)
+	f := &fakeGCS{
+		token:   "the-token",
+		objects: make(map[string][]byte),
+		headers: make(map[string]http.Header),
+	}
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+	return f, server
+}
+
+func TestGCSStore(t *testing.T) {
+	f, server := newFakeGCS(t)
+	store, err := newGCSStore(fmt.Sprintf("gs://my-bucket/turbo/cache?endpoint=%v", server.URL))
+	assert.NilError(t, err, "newGCSStore")
+	ctx := context.Background()
+
+	exists, err := store.ArtifactExists(ctx, "the-hash")
+	assert.NilError(t, err, "ArtifactExists")
+	assert.Assert(t, !exists)
+	_, err = store.FetchArtifact(ctx, "the-hash")
+	assert.ErrorIs(t, err, artifacts.ErrNotFound)
+	_, err = store.ArtifactMetadata(ctx, "the-hash")
+	assert.ErrorIs(t, err, artifacts.ErrNotFound)
+
+	metadata := artifacts.Metadata{Duration: 1200, Tag: "the-tag", Digest: "sha256:abc", KeyID: "key-1", PlaintextDigest: "sha256:def", HashSchema: 1}
+	assert.NilError(t, store.PutArtifact(ctx, "the-hash", strings.NewReader("contents"), metadata), "PutArtifact")
+	assert.DeepEqual(t, f.objects["/my-bucket/turbo/cache/the-hash.tar.zst"], []byte("contents"))
+	// Objects are only created, never replaced
+	err = store.PutArtifact(ctx, "the-hash", strings.NewReader("other contents"), metadata)
+	assert.ErrorIs(t, err, artifacts.ErrExists)
+	assert.DeepEqual(t, f.objects["/my-bucket/turbo/cache/the-hash.tar.zst"], []byte("contents"))
+
+	exists, err = store.ArtifactExists(ctx, "the-hash")
+	assert.NilError(t, err, "ArtifactExists")
+	assert.Assert(t, exists)
+	headMetadata, err := store.ArtifactMetadata(ctx, "the-hash")
+	assert.NilError(t, err, "ArtifactMetadata")
+	assert.DeepEqual(t, *headMetadata, metadata)
+	artifact, err := store.FetchArtifact(ctx, "the-hash")
+	assert.NilError(t, err, "FetchArtifact")
+	defer func() { _ = artifact.Body.Close() }()
+	assert.DeepEqual(t, artifact.Metadata, metadata)
+	contents, err := ioutil.ReadAll(artifact.Body)
+	assert.NilError(t, err)
+	assert.Equal(t, string(contents), "contents")
+
+	// A token that isn't accepted means Cloud Storage can't be used for the rest of the run
+	f.token = "another-token"
+	_, err = store.FetchArtifact(ctx, "the-hash")
+	cd := &util.CacheDisabledError{}
+	assert.Assert(t, errors.As(err, &cd), "expected a CacheDisabledError, got %v", err)
+	assert.ErrorContains(t, err, "Authentication required")
+}
+
+func TestGCSStoreTooManyFailures(t *testing.T) {
+	_, server := newFakeGCS(t)
+	store, err := newGCSStore(fmt.Sprintf("gs://my-bucket?endpoint=%v", server.URL))
+	assert.NilError(t, err, "newGCSStore")
+	server.Close()
+	for i := 0; i < int(_maxObjectStoreFailCount); i++ {
+		_, err = store.ArtifactExists(context.Background(), "the-hash")
+		assert.Assert(t, err != nil && !errors.Is(err, util.ErrTooManyFailures))
+	}
+	_, err = store.ArtifactExists(context.Background(), "the-hash")
+	assert.ErrorIs(t, err, util.ErrTooManyFailures)
+}
+
+func TestGCSHTTPCache(t *testing.T) {
+	_, server := newFakeGCS(t)
+	t.Setenv(_envCache, fmt.Sprintf("gs://my-bucket/turbo?endpoint=%v", server.URL))
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	file := turbopath.AnchoredUnixPath("dist/index.js").ToSystemPath()
+	path := file.RestoreAnchor(repoRoot)
+	assert.NilError(t, path.EnsureDir())
+	assert.NilError(t, path.WriteFile([]byte("console.log('hello');"), 0644))
+
+	opts := Opts{SkipFilesystem: true}
+	c, err := newSyncCache(opts, repoRoot, &errorResp{err: errors.New("not linked")}, &nullRecorder{}, func(_ Cache, err error) {
+		t.Errorf("Cloud Storage was removed: %v", err)
+	}, nil)
+	assert.NilError(t, err, "newSyncCache")
+	mplex := c.(*cacheMultiplexer)
+	mplex.caches[0].(*httpCache).repoRoot = repoRoot
+
+	assert.NilError(t, c.Put(repoRoot, "the-hash", 100, []turbopath.AnchoredSystemPath{file}), "Put")
+	assert.NilError(t, path.Remove())
+	status, files, duration, err := c.Fetch(repoRoot, "the-hash", nil)
+	assert.NilError(t, err, "Fetch")
+	assert.Assert(t, status.Remote)
+	assert.Equal(t, duration, 100)
+	assert.Equal(t, len(files), 1)
+	contents, err := path.ReadFile()
+	assert.NilError(t, err, "ReadFile")
+	assert.Equal(t, string(contents), "console.log('hello');")
+}
+
+func TestNewGCSStore(t *testing.T) {
+	isolateGCPConfig(t)
+	_, err := newGCSStore("gs:///prefix")
+	assert.ErrorContains(t, err, "invalid Cloud Storage URL")
+	store, err := newGCSStore("gs://my-bucket/prefix/")
+	assert.NilError(t, err, "newGCSStore")
+	assert.Equal(t, store.objectURL("abc").String(), "https://storage.googleapis.com/my-bucket/prefix/abc.tar.zst")
+
+	c, err := remoteClient(Opts{RemoteCacheOpts: fs.RemoteCacheOptions{Provider: "gs://my-bucket"}}, nil)
+	assert.NilError(t, err, "remoteClient")
+	_, ok := c.(*gcsStore)
+	assert.Assert(t, ok, "expected a gcsStore, got %T", c)
+
+	_, err = newGCPCredentialProvider().get(context.Background())
+	assert.ErrorContains(t, err, "no Google Cloud credentials were found")
+}
+
+func TestGCPCredentialsServiceAccount(t *testing.T) {
+	isolateGCPConfig(t)
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NilError(t, err)
+	tokenServer, requests := newFakeTokenServer(t, key, "ci@project.iam.gserviceaccount.com", "sa-token")
+	writeServiceAccountKey(t, key, "ci@project.iam.gserviceaccount.com", tokenServer.URL)
+
+	provider := newGCPCredentialProvider()
+	token, err := provider.get(context.Background())
+	assert.NilError(t, err)
+	assert.Equal(t, token.AccessToken, "sa-token")
+
+	// Tokens are cached until shortly before they expire
+	_, err = provider.get(context.Background())
+	assert.NilError(t, err)
+	assert.Equal(t, *requests, 1)
+	provider.now = func() time.Time { return token.Expires.Add(-time.Minute) }
+	_, err = provider.get(context.Background())
+	assert.NilError(t, err)
+	assert.Equal(t, *requests, 2)
+
+	// A key that isn't the service account's is rejected
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NilError(t, err)
+	writeServiceAccountKey(t, otherKey, "ci@project.iam.gserviceaccount.com", tokenServer.URL)
+	_, err = newGCPCredentialProvider().get(context.Background())
+	assert.ErrorContains(t, err, "invalid_grant")
+}
+
+func TestGCPCredentialsWellKnownFile(t *testing.T) {
+	isolateGCPConfig(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NilError(t, r.ParseForm())
+		assert.Equal(t, r.PostForm.Get("grant_type"), "refresh_token")
+		assert.Equal(t, r.PostForm.Get("refresh_token"), "the-refresh-token")
+		_, _ = w.Write([]byte(`{"access_token":"user-token","expires_in":3599}`))
+	}))
+	defer server.Close()
+	dir := t.TempDir()
+	t.Setenv("CLOUDSDK_CONFIG", dir)
+	assert.NilError(t, ioutil.WriteFile(filepath.Join(dir, "application_default_credentials.json"), []byte(fmt.Sprintf(
+		`{"type":"authorized_user","client_id":"id","client_secret":"secret","refresh_token":"the-refresh-token","token_uri":%q}`,
+		server.URL,
+	)), 0600))
+
+	token, err := newGCPCredentialProvider().get(context.Background())
+	assert.NilError(t, err)
+	assert.Equal(t, token.AccessToken, "user-token")
+}
+
+func TestGCPCredentialsExternalAccount(t *testing.T) {
+	isolateGCPConfig(t)
+	expires := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/oidc":
+			assert.Equal(t, r.Header.Get("Authorization"), "Bearer ci-request-token")
+			_, _ = w.Write([]byte(`{"value":"the-oidc-token"}`))
+		case "/sts":
+			assert.NilError(t, r.ParseForm())
+			assert.Equal(t, r.PostForm.Get("subject_token"), "the-oidc-token")
+			assert.Equal(t, r.PostForm.Get("audience"), "//iam.googleapis.com/projects/1/locations/global/workloadIdentityPools/ci/providers/oidc")
+			_, _ = w.Write([]byte(`{"access_token":"federated-token","expires_in":3600}`))
+		case "/impersonate":
+			assert.Equal(t, r.Header.Get("Authorization"), "Bearer federated-token")
+			fmt.Fprintf(w, `{"accessToken":"impersonated-token","expireTime":%q}`, expires.Format(time.RFC3339))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	path := filepath.Join(t.TempDir(), "external.json")
+	assert.NilError(t, ioutil.WriteFile(path, []byte(fmt.Sprintf(`{
+  "type": "external_account",
+  "audience": "//iam.googleapis.com/projects/1/locations/global/workloadIdentityPools/ci/providers/oidc",
+  "subject_token_type": "urn:ietf:params:oauth:token-type:jwt",
+  "token_url": "%[1]v/sts",
+  "service_account_impersonation_url": "%[1]v/impersonate",
+  "credential_source": {
+    "url": "%[1]v/oidc",
+    "headers": {"Authorization": "Bearer ci-request-token"},
+    "format": {"type": "json", "subject_token_field_name": "value"}
+  }
+}`, server.URL)), 0600))
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", path)
+
+	token, err := newGCPCredentialProvider().get(context.Background())
+	assert.NilError(t, err)
+	assert.DeepEqual(t, token, &gcpToken{AccessToken: "impersonated-token", Expires: expires})
+}
+
+func TestGCPCredentialsMetadataServer(t *testing.T) {
+	isolateGCPConfig(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Metadata-Flavor", "Google")
+		if r.Header.Get("Metadata-Flavor") != "Google" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		assert.Equal(t, r.URL.Path, "/computeMetadata/v1/instance/service-accounts/default/token")
+		assert.Equal(t, r.URL.Query().Get("scopes"), _gcsScope)
+		_, _ = w.Write([]byte(`{"access_token":"workload-token","expires_in":3599,"token_type":"Bearer"}`))
+	}))
+	defer server.Close()
+	t.Setenv("GCE_METADATA_HOST", strings.TrimPrefix(server.URL, "http://"))
+
+	token, err := newGCPCredentialProvider().get(context.Background())
+	assert.NilError(t, err)
+	assert.Equal(t, token.AccessToken, "workload-token")
+}
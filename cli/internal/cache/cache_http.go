@@ -6,12 +6,12 @@ package cache
 import (
 	"archive/tar"
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	log "log"
-	"net/http"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -20,14 +20,15 @@ import (
 	"github.com/DataDog/zstd"
 
 	"github.com/vercel/turbo/cli/internal/analytics"
+	"github.com/vercel/turbo/cli/internal/cacheitem"
+	"github.com/vercel/turbo/cli/internal/fs"
 	"github.com/vercel/turbo/cli/internal/tarpatch"
 	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/pkg/artifacts"
 )
 
 type client interface {
-	PutArtifact(hash string, body []byte, duration int, tag string) error
-	FetchArtifact(hash string) (*http.Response, error)
-	ArtifactExists(hash string) (*http.Response, error)
+	artifacts.API
 	GetTeamID() string
 }
 
@@ -37,7 +38,10 @@ type httpCache struct {
 	requestLimiter limiter
 	recorder       analytics.Recorder
 	signerVerifier *ArtifactSignatureAuthentication
+	encryption     *artifactEncryption
 	repoRoot       turbopath.AbsoluteSystemPath
+	onConflict     OnArtifactConflict
+	transfers      *TransferStats
 }
 
 type limiter chan struct{}
@@ -71,14 +75,54 @@ func (cache *httpCache) Put(anchor turbopath.AbsoluteSystemPath, hash string, du
 	if err != nil {
 		return fmt.Errorf("failed to store files in HTTP cache: %w", err)
 	}
+	uploadBody := artifactBody
+	keyID := ""
+	if cache.encryption.isEnabled() {
+		uploadBody, keyID, err = cache.encryption.encrypt(hash, artifactBody)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt files for HTTP cache: %w", err)
+		}
+	}
+	// Artifacts are immutable: never replace a different artifact for the same hash.
+	// Each encryption uses a new nonce, so only the digest of the plaintext is the
+	// same for every upload of the same contents.
+	digest := contentDigest(uploadBody)
+	plaintextDigest := ""
+	if cache.encryption.isEnabled() {
+		plaintextDigest = contentDigest(artifactBody)
+	}
+	skip, conflict, err := cache.checkConflict(hash, artifactBody, digest, plaintextDigest)
+	if err != nil {
+		log.Printf("[WARNING] Could not check the HTTP cache for an existing artifact for %v: %v", hash, err)
+	} else if conflict != nil && cache.onConflict != nil {
+		cache.onConflict(conflict)
+	}
+	if skip {
+		return nil
+	}
 	tag := ""
 	if cache.signerVerifier.isEnabled() {
-		tag, err = cache.signerVerifier.generateTag(hash, artifactBody)
+		tag, err = cache.signerVerifier.generateTag(hash, uploadBody)
 		if err != nil {
 			return fmt.Errorf("failed to store files in HTTP cache: %w", err)
 		}
 	}
-	return cache.client.PutArtifact(hash, artifactBody, duration, tag)
+	err = cache.client.PutArtifact(context.Background(), hash, bytes.NewReader(uploadBody), artifacts.Metadata{
+		Duration:        duration,
+		Tag:             tag,
+		Digest:          digest,
+		KeyID:           keyID,
+		PlaintextDigest: plaintextDigest,
+		HashSchema:      fs.HashSchemaVersion,
+	})
+	if errors.Is(err, artifacts.ErrExists) {
+		// Another upload created it since it was checked for, and it's kept
+		return nil
+	} else if err != nil {
+		return err
+	}
+	cache.transfers.addUploaded(int64(len(uploadBody)))
+	return nil
 }
 
 // write writes a series of files into the given Writer.
@@ -110,6 +154,9 @@ func (cache *httpCache) storeFile(tw *tar.Writer, repoRelativePath turbopath.Anc
 		if err != nil {
 			return err
 		}
+		if cacheitem.IsAbsoluteLinkTarget(target) {
+			return fmt.Errorf("%v -> %v: %w", repoRelativePath, target, cacheitem.ErrAbsoluteLinkTarget)
+		}
 	}
 	hdr, err := tarpatch.FileInfoHeader(repoRelativePath.ToUnixPath(), info, filepath.ToSlash(target))
 	if err != nil {
@@ -143,16 +190,16 @@ func (cache *httpCache) storeFile(tw *tar.Writer, repoRelativePath turbopath.Anc
 	return err
 }
 
-func (cache *httpCache) Fetch(anchor turbopath.AbsoluteSystemPath, key string, _unusedOutputGlobs []string) (bool, []turbopath.AnchoredSystemPath, int, error) {
+func (cache *httpCache) Fetch(anchor turbopath.AbsoluteSystemPath, key string, _unusedOutputGlobs []string) (ItemStatus, []turbopath.AnchoredSystemPath, int, error) {
 	cache.requestLimiter.acquire()
 	defer cache.requestLimiter.release()
 	hit, files, duration, err := cache.retrieve(key)
 	if err != nil {
 		// TODO: analytics event?
-		return false, files, duration, fmt.Errorf("failed to retrieve files from HTTP cache: %w", err)
+		return ItemStatus{}, files, duration, fmt.Errorf("failed to retrieve files from HTTP cache: %w", err)
 	}
 	cache.logFetch(hit, key, duration)
-	return hit, files, duration, err
+	return ItemStatus{Remote: hit}, files, duration, err
 }
 
 func (cache *httpCache) Exists(key string) (ItemStatus, error) {
@@ -182,52 +229,40 @@ func (cache *httpCache) logFetch(hit bool, hash string, duration int) {
 }
 
 func (cache *httpCache) exists(hash string) (bool, error) {
-	resp, err := cache.client.ArtifactExists(hash)
-	if err != nil {
+	exists, err := cache.client.ArtifactExists(context.Background(), hash)
+	var apiErr *artifacts.APIError
+	if errors.As(err, &apiErr) {
+		return false, fmt.Errorf("%s", strconv.Itoa(apiErr.StatusCode))
+	} else if err != nil {
 		return false, nil
 	}
-
-	defer func() { err = resp.Body.Close() }()
-
-	if resp.StatusCode == http.StatusNotFound {
-		return false, nil
-	} else if resp.StatusCode != http.StatusOK {
-		return false, fmt.Errorf("%s", strconv.Itoa(resp.StatusCode))
-	}
-	return true, err
+	return exists, nil
 }
 
 func (cache *httpCache) retrieve(hash string) (bool, []turbopath.AnchoredSystemPath, int, error) {
-	resp, err := cache.client.FetchArtifact(hash)
-	if err != nil {
+	artifact, err := cache.client.FetchArtifact(context.Background(), hash)
+	if errors.Is(err, artifacts.ErrNotFound) {
+		return false, nil, 0, nil // doesn't exist - not an error
+	} else if err != nil {
 		return false, nil, 0, err
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode == http.StatusNotFound {
-		return false, nil, 0, nil // doesn't exist - not an error
-	} else if resp.StatusCode != http.StatusOK {
-		b, _ := ioutil.ReadAll(resp.Body)
-		return false, nil, 0, fmt.Errorf("%s", string(b))
-	}
-	// If present, extract the duration from the response.
-	duration := 0
-	if resp.Header.Get("x-artifact-duration") != "" {
-		intVar, err := strconv.Atoi(resp.Header.Get("x-artifact-duration"))
-		if err != nil {
-			return false, nil, 0, fmt.Errorf("invalid x-artifact-duration header: %w", err)
-		}
-		duration = intVar
+	defer func() { _ = artifact.Body.Close() }()
+	if !compatibleHashSchema(artifact.HashSchema) {
+		// The same hash means something else to the client that uploaded it
+		return false, nil, 0, nil
 	}
-	var tarReader io.Reader
+	duration := artifact.Duration
+	// Not every store checks the digest as the artifact downloads
+	body := cache.transfers.countDownload(artifacts.VerifyDigest(artifact.Body, artifact.Digest))
+	var tarReader io.Reader = body
 
-	defer func() { _ = resp.Body.Close() }()
 	if cache.signerVerifier.isEnabled() {
-		expectedTag := resp.Header.Get("x-artifact-tag")
+		expectedTag := artifact.Tag
 		if expectedTag == "" {
 			// If the verifier is enabled all incoming artifact downloads must have a signature
 			return false, nil, 0, errors.New("artifact verification failed: Downloaded artifact is missing required x-artifact-tag header")
 		}
-		b, err := ioutil.ReadAll(resp.Body)
+		b, err := ioutil.ReadAll(tarReader)
 		if err != nil {
 			return false, nil, 0, fmt.Errorf("artifact verification failed: %w", err)
 		}
@@ -241,9 +276,20 @@ func (cache *httpCache) retrieve(hash string) (bool, []turbopath.AnchoredSystemP
 		}
 		// The artifact has been verified and the body can be read and untarred
 		tarReader = bytes.NewReader(b)
-	} else {
-		tarReader = resp.Body
 	}
+	if cache.encryption.isEnabled() {
+		b, err := ioutil.ReadAll(tarReader)
+		if err != nil {
+			return false, nil, 0, fmt.Errorf("artifact decryption failed: %w", err)
+		}
+		b, err = cache.encryption.decrypt(hash, b, artifact.KeyID)
+		if err != nil {
+			return false, nil, 0, fmt.Errorf("artifact decryption failed: %w", err)
+		}
+		tarReader = bytes.NewReader(b)
+	}
+	// The archive is extracted while it downloads, and restoreTar reads to the end of
+	// the download, where its digest is checked, before moving any file into place
 	files, err := restoreTar(cache.repoRoot, tarReader)
 	if err != nil {
 		return false, nil, 0, err
@@ -255,7 +301,32 @@ func (cache *httpCache) retrieve(hash string) (bool, []turbopath.AnchoredSystemP
 // restored. In the future, these should likely be repo-relative system paths
 // so that they are suitable for being fed into cache.Put for other caches.
 // For now, I think this is working because windows also accepts /-delimited paths.
+// The archive is extracted to a staging directory, and its files are moved into
+// root once all of them are complete and reader has been read to its end, so a
+// partial download, or one that fails its digest check at the end, changes nothing
+// in root.
 func restoreTar(root turbopath.AbsoluteSystemPath, reader io.Reader) ([]turbopath.AnchoredSystemPath, error) {
+	staging, err := fs.CreateStagingDir(root)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = staging.RemoveAll() }()
+
+	files, err := extractTar(staging, reader)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(ioutil.Discard, reader); err != nil {
+		return nil, err
+	}
+	if err := fs.MoveStaged(staging, root); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// extractTar extracts a zstd-compressed tar to root
+func extractTar(root turbopath.AbsoluteSystemPath, reader io.Reader) ([]turbopath.AnchoredSystemPath, error) {
 	files := []turbopath.AnchoredSystemPath{}
 	missingLinks := []*tar.Header{}
 	zr := zstd.NewReader(reader)
@@ -301,6 +372,7 @@ func restoreTar(root turbopath.AbsoluteSystemPath, reader io.Reader) ([]turbopat
 			if f, err := filename.OpenFile(os.O_WRONLY|os.O_TRUNC|os.O_CREATE, os.FileMode(hdr.Mode)); err != nil {
 				return nil, err
 			} else if _, err := io.Copy(f, tr); err != nil {
+				_ = f.Close()
 				return nil, err
 			} else if err := f.Close(); err != nil {
 				return nil, err
@@ -327,7 +399,9 @@ func restoreSymlink(root turbopath.AbsoluteSystemPath, hdr *tar.Header, allowNon
 		return err
 	}
 
-	// TODO: check if this is an absolute path, or if we even care
+	if cacheitem.IsAbsoluteLinkTarget(hdr.Linkname) {
+		return cacheitem.ErrAbsoluteLinkTarget
+	}
 	linkTarget := linkFilename.Dir().UntypedJoin(relativeLinkTarget)
 	if _, err := linkTarget.Lstat(); err != nil {
 		if os.IsNotExist(err) {
@@ -359,17 +433,20 @@ func (cache *httpCache) CleanAll() {
 
 func (cache *httpCache) Shutdown() {}
 
-func newHTTPCache(opts Opts, client client, recorder analytics.Recorder) *httpCache {
+func newHTTPCache(opts Opts, client client, recorder analytics.Recorder, onConflict OnArtifactConflict) *httpCache {
 	return &httpCache{
 		writable:       true,
 		client:         client,
 		requestLimiter: make(limiter, 20),
 		recorder:       recorder,
+		onConflict:     onConflict,
+		transfers:      opts.Transfers,
 		signerVerifier: &ArtifactSignatureAuthentication{
 			// TODO(Gaspar): this should use RemoteCacheOptions.TeamId once we start
 			// enforcing team restrictions for repositories.
 			teamId:  client.GetTeamID(),
 			enabled: opts.RemoteCacheOpts.Signature,
 		},
+		encryption: newArtifactEncryption(opts.RemoteCacheOpts.Encryption),
 	}
 }
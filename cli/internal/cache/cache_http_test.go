@@ -3,15 +3,20 @@ package cache
 import (
 	"archive/tar"
 	"bytes"
+	"context"
 	"errors"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"testing"
 
 	"github.com/DataDog/zstd"
 
+	"github.com/vercel/turbo/cli/internal/cacheitem"
 	"github.com/vercel/turbo/cli/internal/fs"
 	"github.com/vercel/turbo/cli/internal/turbopath"
 	"github.com/vercel/turbo/cli/internal/util"
+	"github.com/vercel/turbo/cli/pkg/artifacts"
 	"gotest.tools/v3/assert"
 )
 
@@ -19,15 +24,19 @@ type errorResp struct {
 	err error
 }
 
-func (sr *errorResp) PutArtifact(hash string, body []byte, duration int, tag string) error {
+func (sr *errorResp) PutArtifact(ctx context.Context, hash string, body io.Reader, metadata artifacts.Metadata) error {
 	return sr.err
 }
 
-func (sr *errorResp) FetchArtifact(hash string) (*http.Response, error) {
+func (sr *errorResp) FetchArtifact(ctx context.Context, hash string) (*artifacts.Artifact, error) {
 	return nil, sr.err
 }
 
-func (sr *errorResp) ArtifactExists(hash string) (*http.Response, error) {
+func (sr *errorResp) ArtifactExists(ctx context.Context, hash string) (bool, error) {
+	return false, sr.err
+}
+
+func (sr *errorResp) ArtifactMetadata(ctx context.Context, hash string) (*artifacts.Metadata, error) {
 	return nil, sr.err
 }
 
@@ -240,6 +249,473 @@ func TestRestoreInvalidTar(t *testing.T) {
 	assert.Equal(t, string(contents), string(expectedContents), "expected to not overwrite file")
 }
 
+func TestRestoreInterruptedTar(t *testing.T) {
+	root := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	extraFile := root.UntypedJoin("extra-file")
+	assert.NilError(t, extraFile.WriteFile([]byte("before"), 0644), "WriteFile")
+
+	// An archive whose first file arrives before the download is cut off
+	tarBuf := &bytes.Buffer{}
+	tw := tar.NewWriter(tarBuf)
+	assert.NilError(t, tw.WriteHeader(&tar.Header{Name: "my-pkg/", Mode: int64(0755), Typeflag: tar.TypeDir}), "WriteHeader")
+	assert.NilError(t, tw.WriteHeader(&tar.Header{Name: "my-pkg/some-file", Mode: int64(0644), Typeflag: tar.TypeReg, Size: 4}), "WriteHeader")
+	_, err := tw.Write([]byte("file"))
+	assert.NilError(t, err, "Write")
+	assert.NilError(t, tw.Flush(), "Flush")
+	received, err := zstd.Compress(nil, tarBuf.Bytes())
+	assert.NilError(t, err, "Compress")
+
+	// A download that stops partway through restores none of the archive
+	body := &failAtEnd{Reader: bytes.NewReader(received), err: errors.New("connection reset")}
+	_, err = restoreTar(root, body)
+	assert.ErrorContains(t, err, "connection reset")
+
+	contents, err := extraFile.ReadFile()
+	assert.NilError(t, err, "ReadFile")
+	assert.Equal(t, string(contents), "before")
+	assert.Assert(t, !root.UntypedJoin("my-pkg").Exists(), "no file of the archive is restored")
+}
+
+func TestAbsoluteLinkTargets(t *testing.T) {
+	root := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	link := root.UntypedJoin("escape")
+	assert.NilError(t, link.Symlink("/etc/passwd"), "Symlink")
+
+	cache := &httpCache{repoRoot: root}
+	tw := tar.NewWriter(&bytes.Buffer{})
+	err := cache.storeFile(tw, turbopath.AnchoredSystemPath("escape"))
+	assert.ErrorIs(t, err, cacheitem.ErrAbsoluteLinkTarget)
+
+	buf := &bytes.Buffer{}
+	zw := zstd.NewWriter(buf)
+	tw = tar.NewWriter(zw)
+	assert.NilError(t, tw.WriteHeader(&tar.Header{
+		Name:     "escape",
+		Mode:     int64(0644),
+		Typeflag: tar.TypeSymlink,
+		Linkname: "/etc/passwd",
+	}), "WriteHeader")
+	assert.NilError(t, tw.Close(), "Close")
+	assert.NilError(t, zw.Close(), "Close")
+
+	restoreRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	_, err = restoreTar(restoreRoot, buf)
+	assert.ErrorIs(t, err, cacheitem.ErrAbsoluteLinkTarget)
+	assert.Assert(t, !restoreRoot.UntypedJoin("escape").Exists(), "the link is not restored")
+}
+
 // Note that testing Put will require mocking the filesystem and is not currently the most
 // interesting test. The current implementation directly returns the error from PutArtifact.
 // We should still add the test once feasible to avoid future breakage.
+
+// memoryClient is a Remote Cache that keeps artifacts in memory, and like older
+// Remote Caches, does not report their digests
+type memoryClient struct {
+	artifacts map[string][]byte
+	// fetches and metadataRequests count the requests for existing artifacts
+	fetches          int
+	metadataRequests int
+}
+
+func (mc *memoryClient) PutArtifact(ctx context.Context, hash string, body io.Reader, metadata artifacts.Metadata) error {
+	b, err := ioutil.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	mc.artifacts[hash] = b
+	return nil
+}
+
+func (mc *memoryClient) FetchArtifact(ctx context.Context, hash string) (*artifacts.Artifact, error) {
+	b, ok := mc.artifacts[hash]
+	if !ok {
+		return nil, artifacts.ErrNotFound
+	}
+	mc.fetches++
+	return &artifacts.Artifact{Body: ioutil.NopCloser(bytes.NewReader(b))}, nil
+}
+
+func (mc *memoryClient) ArtifactExists(ctx context.Context, hash string) (bool, error) {
+	_, ok := mc.artifacts[hash]
+	return ok, nil
+}
+
+func (mc *memoryClient) ArtifactMetadata(ctx context.Context, hash string) (*artifacts.Metadata, error) {
+	if _, ok := mc.artifacts[hash]; !ok {
+		return nil, artifacts.ErrNotFound
+	}
+	mc.metadataRequests++
+	return &artifacts.Metadata{}, nil
+}
+
+func (mc *memoryClient) GetTeamID() string {
+	return ""
+}
+
+func TestPutArtifactConflict(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	files := []turbopath.AnchoredSystemPath{
+		turbopath.AnchoredUnixPath("dist/index.js").ToSystemPath(),
+		turbopath.AnchoredUnixPath("dist/build-id").ToSystemPath(),
+	}
+	write := func(buildID string) {
+		for _, file := range files {
+			contents := "console.log('hello');"
+			if file.ToUnixPath() == "dist/build-id" {
+				contents = buildID
+			}
+			path := file.RestoreAnchor(repoRoot)
+			assert.NilError(t, path.EnsureDir())
+			assert.NilError(t, path.WriteFile([]byte(contents), 0644))
+		}
+	}
+	var conflicts []*ArtifactConflictError
+	client := &memoryClient{artifacts: make(map[string][]byte)}
+	cache := newHTTPCache(Opts{}, client, &nullRecorder{}, func(err *ArtifactConflictError) {
+		conflicts = append(conflicts, err)
+	})
+	cache.repoRoot = repoRoot
+
+	write("1")
+	assert.NilError(t, cache.Put(repoRoot, "some-hash", 100, files))
+	original := client.artifacts["some-hash"]
+	assert.Assert(t, len(original) > 0)
+
+	// Uploading the same contents again is not a conflict
+	assert.NilError(t, cache.Put(repoRoot, "some-hash", 100, files))
+	assert.Equal(t, len(conflicts), 0)
+
+	write("2")
+	assert.NilError(t, cache.Put(repoRoot, "some-hash", 100, files))
+	assert.Equal(t, len(conflicts), 1)
+	conflict := conflicts[0]
+	assert.Equal(t, conflict.Hash, "some-hash")
+	assert.Equal(t, conflict.ExistingDigest, contentDigest(original))
+	assert.Assert(t, conflict.UploadedDigest != conflict.ExistingDigest)
+	assert.DeepEqual(t, conflict.ChangedFiles, []string{"dist/build-id"})
+	assert.DeepEqual(t, client.artifacts["some-hash"], original)
+}
+
+func TestPutArtifactEncrypted(t *testing.T) {
+	t.Setenv("TURBO_REMOTE_CACHE_ENCRYPTION_KEY", testEncryptionKey(1))
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	file := turbopath.AnchoredUnixPath("dist/index.js").ToSystemPath()
+	path := file.RestoreAnchor(repoRoot)
+	assert.NilError(t, path.EnsureDir())
+	assert.NilError(t, path.WriteFile([]byte("const secret = 'hunter2';"), 0644))
+
+	var conflicts []*ArtifactConflictError
+	client := &memoryClient{artifacts: make(map[string][]byte)}
+	opts := Opts{}
+	opts.RemoteCacheOpts.Encryption = true
+	cache := newHTTPCache(opts, client, &nullRecorder{}, func(err *ArtifactConflictError) {
+		conflicts = append(conflicts, err)
+	})
+	cache.repoRoot = repoRoot
+
+	assert.NilError(t, cache.Put(repoRoot, "some-hash", 100, []turbopath.AnchoredSystemPath{file}))
+	stored := client.artifacts["some-hash"]
+	_, err := restoreTar(repoRoot.UntypedJoin("unencrypted"), bytes.NewReader(stored))
+	assert.Assert(t, err != nil, "expected the stored artifact to be encrypted")
+
+	// Uploading the same contents again is not a conflict, though the ciphertext differs
+	assert.NilError(t, cache.Put(repoRoot, "some-hash", 100, []turbopath.AnchoredSystemPath{file}))
+	assert.Equal(t, len(conflicts), 0)
+	assert.DeepEqual(t, client.artifacts["some-hash"], stored)
+
+	assert.NilError(t, path.Remove())
+	status, files, _, err := cache.Fetch(repoRoot, "some-hash", nil)
+	assert.NilError(t, err, "Fetch")
+	assert.Assert(t, status.Remote)
+	assert.Equal(t, len(files), 1)
+	contents, err := path.ReadFile()
+	assert.NilError(t, err, "ReadFile")
+	assert.Equal(t, string(contents), "const secret = 'hunter2';")
+
+	t.Setenv("TURBO_REMOTE_CACHE_ENCRYPTION_KEY", testEncryptionKey(2))
+	cache = newHTTPCache(opts, client, &nullRecorder{}, nil)
+	cache.repoRoot = repoRoot
+	_, _, _, err = cache.Fetch(repoRoot, "some-hash", nil)
+	assert.ErrorContains(t, err, "cannot be decrypted")
+}
+
+// failAtEnd reads like its Reader, but fails where it would end, as the body of a
+// download whose digest doesn't match does
+type failAtEnd struct {
+	io.Reader
+	err error
+}
+
+func (f *failAtEnd) Read(p []byte) (int, error) {
+	n, err := f.Reader.Read(p)
+	if err == io.EOF {
+		return n, f.err
+	}
+	return n, err
+}
+
+// streamingClient serves a single artifact with a body that fails at its end
+type streamingClient struct {
+	errorResp
+	body []byte
+}
+
+func (sc *streamingClient) FetchArtifact(ctx context.Context, hash string) (*artifacts.Artifact, error) {
+	body := &failAtEnd{Reader: bytes.NewReader(sc.body), err: errors.New("downloaded artifact has digest sha256:123, expected sha256:456")}
+	return &artifacts.Artifact{Body: ioutil.NopCloser(body)}, nil
+}
+
+func TestFetchChecksEndOfDownload(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	cache := newHTTPCache(Opts{}, &streamingClient{body: makeValidTar(t).Bytes()}, &nullRecorder{}, nil)
+	cache.repoRoot = repoRoot
+
+	// The archive is complete before the end of the download, which is still read,
+	// and none of it is restored when the digest doesn't match
+	status, files, _, err := cache.Fetch(repoRoot, "some-hash", nil)
+	assert.ErrorContains(t, err, "downloaded artifact has digest")
+	assert.Assert(t, !status.Hit())
+	assert.Equal(t, len(files), 0)
+	assert.Assert(t, !repoRoot.UntypedJoin("extra-file").Exists(), "no file of the archive is restored")
+	assert.Assert(t, !repoRoot.UntypedJoin("my-pkg").Exists(), "no file of the archive is restored")
+}
+
+func TestHTTPCacheCountsTransfers(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	file := turbopath.AnchoredUnixPath("dist/index.js").ToSystemPath()
+	path := file.RestoreAnchor(repoRoot)
+	assert.NilError(t, path.EnsureDir())
+	assert.NilError(t, path.WriteFile([]byte("console.log('hello');"), 0644))
+
+	client := &memoryClient{artifacts: make(map[string][]byte)}
+	opts := Opts{Transfers: &TransferStats{}}
+	cache := newHTTPCache(opts, client, &nullRecorder{}, nil)
+	cache.repoRoot = repoRoot
+
+	assert.NilError(t, cache.Put(repoRoot, "some-hash", 100, []turbopath.AnchoredSystemPath{file}))
+	size := int64(len(client.artifacts["some-hash"]))
+	assert.Equal(t, opts.Transfers.Uploaded(), size)
+	assert.Equal(t, opts.Transfers.Downloaded(), int64(0))
+
+	status, _, _, err := cache.Fetch(repoRoot, "some-hash", nil)
+	assert.NilError(t, err, "Fetch")
+	assert.Assert(t, status.Remote)
+	assert.Equal(t, opts.Transfers.Downloaded(), size)
+
+	// A miss downloads nothing
+	_, _, _, err = cache.Fetch(repoRoot, "other-hash", nil)
+	assert.NilError(t, err, "Fetch")
+	assert.Equal(t, opts.Transfers.Downloaded(), size)
+}
+
+// metadataClient is a memoryClient that also keeps the metadata of artifacts
+type metadataClient struct {
+	*memoryClient
+	metadata map[string]artifacts.Metadata
+}
+
+func (mc *metadataClient) PutArtifact(ctx context.Context, hash string, body io.Reader, metadata artifacts.Metadata) error {
+	mc.metadata[hash] = metadata
+	return mc.memoryClient.PutArtifact(ctx, hash, body, metadata)
+}
+
+func (mc *metadataClient) ArtifactMetadata(ctx context.Context, hash string) (*artifacts.Metadata, error) {
+	if _, err := mc.memoryClient.ArtifactMetadata(ctx, hash); err != nil {
+		return nil, err
+	}
+	metadata := mc.metadata[hash]
+	return &metadata, nil
+}
+
+func (mc *metadataClient) FetchArtifact(ctx context.Context, hash string) (*artifacts.Artifact, error) {
+	artifact, err := mc.memoryClient.FetchArtifact(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	artifact.Metadata = mc.metadata[hash]
+	return artifact, nil
+}
+
+func TestPutArtifactConflictChecksDigest(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	file := turbopath.AnchoredUnixPath("dist/index.js").ToSystemPath()
+	path := file.RestoreAnchor(repoRoot)
+	assert.NilError(t, path.EnsureDir())
+	assert.NilError(t, path.WriteFile([]byte("console.log('hello');"), 0644))
+
+	client := &metadataClient{memoryClient: &memoryClient{artifacts: make(map[string][]byte)}, metadata: make(map[string]artifacts.Metadata)}
+	cache := newHTTPCache(Opts{}, client, &nullRecorder{}, nil)
+	cache.repoRoot = repoRoot
+
+	// Without an artifact, and with one that has the same digest, nothing is downloaded
+	assert.NilError(t, cache.Put(repoRoot, "some-hash", 100, []turbopath.AnchoredSystemPath{file}))
+	assert.NilError(t, cache.Put(repoRoot, "some-hash", 100, []turbopath.AnchoredSystemPath{file}))
+	assert.Equal(t, client.metadataRequests, 1)
+	assert.Equal(t, client.fetches, 0)
+
+	// A differing artifact is downloaded to find the files that differ
+	assert.NilError(t, path.WriteFile([]byte("console.log('changed');"), 0644))
+	assert.NilError(t, cache.Put(repoRoot, "some-hash", 100, []turbopath.AnchoredSystemPath{file}))
+	assert.Equal(t, client.fetches, 1)
+}
+
+func TestPutArtifactEncryptedChecksPlaintextDigest(t *testing.T) {
+	t.Setenv("TURBO_REMOTE_CACHE_ENCRYPTION_KEY", testEncryptionKey(1))
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	file := turbopath.AnchoredUnixPath("dist/index.js").ToSystemPath()
+	path := file.RestoreAnchor(repoRoot)
+	assert.NilError(t, path.EnsureDir())
+	assert.NilError(t, path.WriteFile([]byte("console.log('hello');"), 0644))
+
+	var conflicts []*ArtifactConflictError
+	client := &metadataClient{memoryClient: &memoryClient{artifacts: make(map[string][]byte)}, metadata: make(map[string]artifacts.Metadata)}
+	opts := Opts{}
+	opts.RemoteCacheOpts.Encryption = true
+	cache := newHTTPCache(opts, client, &nullRecorder{}, func(err *ArtifactConflictError) {
+		conflicts = append(conflicts, err)
+	})
+	cache.repoRoot = repoRoot
+
+	// The ciphertexts differ, but the same contents aren't downloaded to compare them
+	assert.NilError(t, cache.Put(repoRoot, "some-hash", 100, []turbopath.AnchoredSystemPath{file}))
+	plaintextDigest := client.metadata["some-hash"].PlaintextDigest
+	assert.Assert(t, plaintextDigest != "")
+	assert.Assert(t, plaintextDigest != client.metadata["some-hash"].Digest)
+	assert.NilError(t, cache.Put(repoRoot, "some-hash", 100, []turbopath.AnchoredSystemPath{file}))
+	assert.Equal(t, client.fetches, 0)
+
+	// Differing contents are, and reported with the digests of the contents
+	assert.NilError(t, path.WriteFile([]byte("console.log('changed');"), 0644))
+	assert.NilError(t, cache.Put(repoRoot, "some-hash", 100, []turbopath.AnchoredSystemPath{file}))
+	assert.Equal(t, client.fetches, 1)
+	assert.Equal(t, len(conflicts), 1)
+	assert.Equal(t, conflicts[0].ExistingDigest, plaintextDigest)
+	assert.Assert(t, conflicts[0].UploadedDigest != plaintextDigest)
+	assert.DeepEqual(t, conflicts[0].ChangedFiles, []string{"dist/index.js"})
+}
+
+// unavailableMetadataClient is a memoryClient that can't report whether it has an
+// artifact, as with a proxy that doesn't allow HEAD requests
+type unavailableMetadataClient struct {
+	*memoryClient
+}
+
+func (mc *unavailableMetadataClient) ArtifactMetadata(ctx context.Context, hash string) (*artifacts.Metadata, error) {
+	return nil, &artifacts.APIError{StatusCode: http.StatusMethodNotAllowed}
+}
+
+func TestPutArtifactMetadataUnavailable(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	file := turbopath.AnchoredUnixPath("dist/index.js").ToSystemPath()
+	path := file.RestoreAnchor(repoRoot)
+	assert.NilError(t, path.EnsureDir())
+	assert.NilError(t, path.WriteFile([]byte("console.log('hello');"), 0644))
+
+	client := &unavailableMetadataClient{memoryClient: &memoryClient{artifacts: make(map[string][]byte)}}
+	cache := newHTTPCache(Opts{}, client, &nullRecorder{}, nil)
+	cache.repoRoot = repoRoot
+
+	// Not knowing about an existing artifact doesn't stop the upload
+	assert.NilError(t, cache.Put(repoRoot, "some-hash", 100, []turbopath.AnchoredSystemPath{file}))
+	assert.Assert(t, len(client.artifacts["some-hash"]) > 0)
+}
+
+// createOnlyClient is a memoryClient that only creates artifacts, and, like a
+// store that another upload reached first, doesn't report them when checked for
+type createOnlyClient struct {
+	*memoryClient
+}
+
+func (mc *createOnlyClient) ArtifactMetadata(ctx context.Context, hash string) (*artifacts.Metadata, error) {
+	return nil, artifacts.ErrNotFound
+}
+
+func (mc *createOnlyClient) PutArtifact(ctx context.Context, hash string, body io.Reader, metadata artifacts.Metadata) error {
+	if _, ok := mc.artifacts[hash]; ok {
+		return artifacts.ErrExists
+	}
+	return mc.memoryClient.PutArtifact(ctx, hash, body, metadata)
+}
+
+func TestPutArtifactExists(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	file := turbopath.AnchoredUnixPath("dist/index.js").ToSystemPath()
+	path := file.RestoreAnchor(repoRoot)
+	assert.NilError(t, path.EnsureDir())
+	assert.NilError(t, path.WriteFile([]byte("console.log('hello');"), 0644))
+
+	client := &createOnlyClient{memoryClient: &memoryClient{artifacts: make(map[string][]byte)}}
+	opts := Opts{Transfers: &TransferStats{}}
+	cache := newHTTPCache(opts, client, &nullRecorder{}, nil)
+	cache.repoRoot = repoRoot
+
+	assert.NilError(t, cache.Put(repoRoot, "some-hash", 100, []turbopath.AnchoredSystemPath{file}))
+	original := client.artifacts["some-hash"]
+	uploaded := opts.Transfers.Uploaded()
+
+	// Losing the race to upload an artifact isn't an error, and the first one is kept
+	assert.NilError(t, path.WriteFile([]byte("console.log('changed');"), 0644))
+	assert.NilError(t, cache.Put(repoRoot, "some-hash", 100, []turbopath.AnchoredSystemPath{file}))
+	assert.DeepEqual(t, client.artifacts["some-hash"], original)
+	assert.Equal(t, opts.Transfers.Uploaded(), uploaded)
+}
+
+func TestHTTPCacheHashSchema(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	file := turbopath.AnchoredUnixPath("dist/index.js").ToSystemPath()
+	path := file.RestoreAnchor(repoRoot)
+	assert.NilError(t, path.EnsureDir())
+	assert.NilError(t, path.WriteFile([]byte("console.log('hello');"), 0644))
+
+	client := &metadataClient{memoryClient: &memoryClient{artifacts: make(map[string][]byte)}, metadata: make(map[string]artifacts.Metadata)}
+	cache := newHTTPCache(Opts{}, client, &nullRecorder{}, nil)
+	cache.repoRoot = repoRoot
+
+	assert.NilError(t, cache.Put(repoRoot, "some-hash", 100, []turbopath.AnchoredSystemPath{file}))
+	assert.Equal(t, client.metadata["some-hash"].HashSchema, fs.HashSchemaVersion)
+
+	for _, tt := range []struct {
+		hashSchema int
+		hit        bool
+	}{
+		{hashSchema: fs.HashSchemaVersion, hit: true},
+		// Uploaded by a client that doesn't report the hash schema
+		{hashSchema: 0, hit: true},
+		{hashSchema: fs.HashSchemaVersion + 1, hit: false},
+	} {
+		metadata := client.metadata["some-hash"]
+		metadata.HashSchema = tt.hashSchema
+		client.metadata["some-hash"] = metadata
+		assert.NilError(t, path.Remove())
+
+		status, files, _, err := cache.Fetch(repoRoot, "some-hash", nil)
+		assert.NilError(t, err, "Fetch")
+		assert.Equal(t, status.Remote, tt.hit, "hash schema %v", tt.hashSchema)
+		assert.Equal(t, len(files) > 0, tt.hit)
+		assert.Equal(t, path.FileExists(), tt.hit)
+		if !tt.hit {
+			assert.NilError(t, path.WriteFile([]byte("console.log('hello');"), 0644))
+		}
+	}
+}
+
+func TestReadOnlyRemoteCache(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	file := turbopath.AnchoredUnixPath("dist/index.js").ToSystemPath()
+	assert.NilError(t, file.RestoreAnchor(repoRoot).EnsureDir())
+	assert.NilError(t, file.RestoreAnchor(repoRoot).WriteFile([]byte("console.log('hello');"), 0644))
+	client := &memoryClient{artifacts: make(map[string][]byte)}
+	remote := newHTTPCache(Opts{}, client, &nullRecorder{}, nil)
+	remote.repoRoot = repoRoot
+	assert.NilError(t, remote.Put(repoRoot, "published", 100, []turbopath.AnchoredSystemPath{file}))
+
+	cache := newReadOnlyCache(remote)
+	assert.NilError(t, cache.Put(repoRoot, "unpublished", 100, []turbopath.AnchoredSystemPath{file}))
+	_, ok := client.artifacts["unpublished"]
+	assert.Assert(t, !ok, "a read-only cache uploaded an artifact")
+
+	status, restored, _, err := cache.Fetch(repoRoot, "published", nil)
+	assert.NilError(t, err, "Fetch")
+	assert.Assert(t, status.Remote)
+	assert.DeepEqual(t, restored, []turbopath.AnchoredSystemPath{file})
+}
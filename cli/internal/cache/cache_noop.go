@@ -11,8 +11,8 @@ func newNoopCache() *noopCache {
 func (c *noopCache) Put(anchor turbopath.AbsoluteSystemPath, key string, duration int, files []turbopath.AnchoredSystemPath) error {
 	return nil
 }
-func (c *noopCache) Fetch(anchor turbopath.AbsoluteSystemPath, key string, files []string) (bool, []turbopath.AnchoredSystemPath, int, error) {
-	return false, nil, 0, nil
+func (c *noopCache) Fetch(anchor turbopath.AbsoluteSystemPath, key string, files []string) (ItemStatus, []turbopath.AnchoredSystemPath, int, error) {
+	return ItemStatus{}, nil, 0, nil
 }
 func (c *noopCache) Exists(key string) (ItemStatus, error) {
 	return ItemStatus{}, nil
@@ -0,0 +1,152 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// _envCache names the storage for remote artifacts, overriding remoteCache.provider
+// in turbo.json
+const _envCache = "TURBO_CACHE"
+
+// RemoteProvider returns the URL of the storage that holds remote artifacts in
+// place of the Remote Cache API, such as "s3://bucket/prefix", "gs://bucket/prefix"
+// or "azblob://account/container/prefix", or "" if the Remote Cache API is used
+func (o *Opts) RemoteProvider() string {
+	if provider := os.Getenv(_envCache); provider != "" {
+		return provider
+	}
+	return o.RemoteCacheOpts.Provider
+}
+
+// remoteClient returns the client that stores remote artifacts: the Remote Cache
+// API, unless a provider is configured
+func remoteClient(opts Opts, apiClient client) (client, error) {
+	provider := opts.RemoteProvider()
+	if provider == "" {
+		return apiClient, nil
+	}
+	if strings.HasPrefix(provider, "s3://") {
+		return newS3Store(provider)
+	}
+	if strings.HasPrefix(provider, "gs://") {
+		return newGCSStore(provider)
+	}
+	if strings.HasPrefix(provider, "azblob://") {
+		return newAzureStore(provider)
+	}
+	return nil, fmt.Errorf("unsupported remote cache provider %q, expected s3://<bucket>/<prefix>, gs://<bucket>/<prefix> or azblob://<account>/<container>/<prefix>", provider)
+}
+
+// s3Store stores artifacts as objects in an S3 bucket, or in a service with an
+// S3-compatible API
+type s3Store struct {
+	objectStore
+	credentials *awsCredentialProvider
+	bucket      string
+	prefix      string
+	region      string
+	// endpoint is set for S3-compatible services, which are addressed path-style
+	endpoint *url.URL
+	now      func() time.Time
+}
+
+var _ client = &s3Store{}
+
+// newS3Store returns a store for a URL of the form s3://<bucket>/<prefix>. The
+// region and, for S3-compatible services, the endpoint can be given as the
+// "region" and "endpoint" query parameters, and otherwise come from the same
+// environment variables and configuration files as the AWS CLI.
+func newS3Store(rawURL string) (*s3Store, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid S3 URL %q: %w", rawURL, err)
+	}
+	if u.Scheme != "s3" || u.Host == "" {
+		return nil, fmt.Errorf("invalid S3 URL %q, expected s3://<bucket>/<prefix>", rawURL)
+	}
+	credentials := newAWSCredentialProvider()
+	store := &s3Store{
+		credentials: credentials,
+		bucket:      u.Host,
+		prefix:      strings.Trim(u.Path, "/"),
+		region:      u.Query().Get("region"),
+		now:         time.Now,
+	}
+	store.objectStore = objectStore{
+		httpClient:     &http.Client{Transport: newStorageTransport()},
+		location:       fmt.Sprintf("s3://%v/%v", store.bucket, store.prefix),
+		objectURL:      store.objectURL,
+		authorize:      store.authorize,
+		metadataPrefix: "X-Amz-Meta-",
+		putHeader:      http.Header{"If-None-Match": []string{"*"}},
+	}
+	endpoint := u.Query().Get("endpoint")
+	if endpoint == "" {
+		endpoint = credentials.getenv("AWS_ENDPOINT_URL_S3")
+	}
+	if endpoint == "" {
+		endpoint = credentials.getenv("AWS_ENDPOINT_URL")
+	}
+	if endpoint != "" {
+		store.endpoint, err = url.Parse(endpoint)
+		if err != nil || store.endpoint.Host == "" {
+			return nil, fmt.Errorf("invalid S3 endpoint %q", endpoint)
+		}
+	}
+	if store.region == "" {
+		store.region = awsRegion(credentials.getenv, credentials.profileSettings())
+	}
+	if store.region == "" {
+		if store.endpoint == nil {
+			return nil, fmt.Errorf("no AWS region is configured for %v. Set AWS_REGION, or add ?region=<region> to the URL", rawURL)
+		}
+		// S3-compatible services generally ignore the region, but it's part of the signature
+		store.region = "us-east-1"
+	}
+	return store, nil
+}
+
+func newStorageTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// Bound the wait for a response, but not the transfer, since artifacts can be large
+	transport.ResponseHeaderTimeout = 30 * time.Second
+	return transport
+}
+
+// objectURL returns the URL of the object holding the artifact for hash
+func (s *s3Store) objectURL(hash string) *url.URL {
+	key := path.Join(s.prefix, hash+".tar.zst")
+	if s.endpoint != nil {
+		u := *s.endpoint
+		u.Path = path.Join("/", u.Path, s.bucket, key)
+		return &u
+	}
+	// Bucket names with dots don't match the wildcard certificate of virtual-hosted
+	// URLs, so they're addressed path-style
+	if strings.Contains(s.bucket, ".") {
+		return &url.URL{Scheme: "https", Host: fmt.Sprintf("s3.%v.amazonaws.com", s.region), Path: "/" + s.bucket + "/" + key}
+	}
+	return &url.URL{Scheme: "https", Host: fmt.Sprintf("%v.s3.%v.amazonaws.com", s.bucket, s.region), Path: "/" + key}
+}
+
+// authorize signs a request with Signature Version 4
+func (s *s3Store) authorize(ctx context.Context, req *http.Request, body []byte) error {
+	creds, err := s.credentials.get(ctx)
+	if err != nil {
+		return err
+	}
+	req.URL.RawPath = awsURIEncode(req.URL.Path, false)
+	payloadHash := _emptyPayloadHash
+	if len(body) > 0 {
+		payloadHash = sha256Hex(body)
+	}
+	signV4(req, creds, s.region, "s3", payloadHash, s.now())
+	return nil
+}
@@ -1,8 +1,11 @@
 package cache
 
 import (
-	"net/http"
+	"context"
+	"fmt"
+	"io"
 	"reflect"
+	"strings"
 	"sync/atomic"
 	"testing"
 
@@ -10,23 +13,24 @@ import (
 	"github.com/vercel/turbo/cli/internal/fs"
 	"github.com/vercel/turbo/cli/internal/turbopath"
 	"github.com/vercel/turbo/cli/internal/util"
+	"github.com/vercel/turbo/cli/pkg/artifacts"
 )
 
 type testCache struct {
-	disabledErr *util.CacheDisabledError
+	disabledErr error
 	entries     map[string][]turbopath.AnchoredSystemPath
 }
 
-func (tc *testCache) Fetch(anchor turbopath.AbsoluteSystemPath, hash string, files []string) (bool, []turbopath.AnchoredSystemPath, int, error) {
+func (tc *testCache) Fetch(anchor turbopath.AbsoluteSystemPath, hash string, files []string) (ItemStatus, []turbopath.AnchoredSystemPath, int, error) {
 	if tc.disabledErr != nil {
-		return false, nil, 0, tc.disabledErr
+		return ItemStatus{}, nil, 0, tc.disabledErr
 	}
 	foundFiles, ok := tc.entries[hash]
 	if ok {
 		duration := 5
-		return true, foundFiles, duration, nil
+		return ItemStatus{Local: true}, foundFiles, duration, nil
 	}
-	return false, nil, 0, nil
+	return ItemStatus{}, nil, 0, nil
 }
 
 func (tc *testCache) Exists(hash string) (ItemStatus, error) {
@@ -106,11 +110,11 @@ func TestPutCachingDisabled(t *testing.T) {
 	mplex.mu.RUnlock()
 
 	// subsequent Fetch should still work
-	hit, _, _, err := mplex.Fetch("unused-target", "some-hash", []string{"unused", "files"})
+	itemStatus, _, _, err := mplex.Fetch("unused-target", "some-hash", []string{"unused", "files"})
 	if err != nil {
 		t.Errorf("got error fetching files: %v", err)
 	}
-	if !hit {
+	if !itemStatus.Hit() {
 		t.Error("failed to find previously stored files")
 	}
 
@@ -155,11 +159,15 @@ func TestExists(t *testing.T) {
 type fakeClient struct{}
 
 // FetchArtifact implements client
-func (*fakeClient) FetchArtifact(hash string) (*http.Response, error) {
+func (*fakeClient) FetchArtifact(ctx context.Context, hash string) (*artifacts.Artifact, error) {
 	panic("unimplemented")
 }
 
-func (*fakeClient) ArtifactExists(hash string) (*http.Response, error) {
+func (*fakeClient) ArtifactExists(ctx context.Context, hash string) (bool, error) {
+	panic("unimplemented")
+}
+
+func (*fakeClient) ArtifactMetadata(ctx context.Context, hash string) (*artifacts.Metadata, error) {
 	panic("unimplemented")
 }
 
@@ -169,7 +177,7 @@ func (*fakeClient) GetTeamID() string {
 }
 
 // PutArtifact implements client
-func (*fakeClient) PutArtifact(hash string, body []byte, duration int, tag string) error {
+func (*fakeClient) PutArtifact(ctx context.Context, hash string, body io.Reader, metadata artifacts.Metadata) error {
 	panic("unimplemented")
 }
 
@@ -191,12 +199,12 @@ func TestFetchCachingDisabled(t *testing.T) {
 		},
 	}
 
-	hit, _, _, err := mplex.Fetch("unused-target", "some-hash", []string{"unused", "files"})
+	itemStatus, _, _, err := mplex.Fetch("unused-target", "some-hash", []string{"unused", "files"})
 	if err != nil {
 		// don't leak the cache removal
 		t.Errorf("Fetch got error %v, want <nil>", err)
 	}
-	if hit {
+	if itemStatus.Hit() {
 		t.Error("hit on empty cache, expected miss")
 	}
 
@@ -217,6 +225,69 @@ func TestFetchCachingDisabled(t *testing.T) {
 	mplex.mu.RUnlock()
 }
 
+func TestRemoteCacheUnreachable(t *testing.T) {
+	unreachableCache := &testCache{
+		disabledErr: fmt.Errorf("failed to fetch artifact: %w", util.ErrTooManyFailures),
+	}
+	localCache := newEnabledCache()
+	var removed []error
+	mplex := &cacheMultiplexer{
+		caches: []Cache{localCache, unreachableCache},
+		onCacheRemoved: func(cache Cache, err error) {
+			if cache != unreachableCache {
+				t.Errorf("removed %v, expected the unreachable cache", cache)
+			}
+			removed = append(removed, err)
+		},
+	}
+
+	itemStatus, _, _, err := mplex.Fetch("unused-target", "some-hash", nil)
+	if err != nil {
+		t.Errorf("Fetch got error %v, want <nil>", err)
+	}
+	if itemStatus.Hit() {
+		t.Error("hit on empty cache, expected miss")
+	}
+	if len(removed) != 1 || !strings.Contains(removed[0].Error(), "continuing with the local cache only") {
+		t.Errorf("removed with %v, expected the unreachable cache to be removed once", removed)
+	}
+
+	// the local cache keeps working
+	if err := mplex.Put("unused-target", "some-hash", 5, []turbopath.AnchoredSystemPath{"a-file"}); err != nil {
+		t.Errorf("Put got error %v, want <nil>", err)
+	}
+	itemStatus, err = mplex.Exists("some-hash")
+	if err != nil {
+		t.Errorf("Exists got error %v, want <nil>", err)
+	}
+	if !itemStatus.Local || itemStatus.Remote {
+		t.Errorf("Exists got %v, want a local hit", itemStatus)
+	}
+	if len(removed) != 1 {
+		t.Errorf("removes count: %v, want 1", len(removed))
+	}
+}
+
+func TestItemStatusSource(t *testing.T) {
+	testCases := []struct {
+		status ItemStatus
+		want   string
+	}{
+		{ItemStatus{}, ""},
+		{ItemStatus{Local: true}, CacheSourceLocal},
+		{ItemStatus{Remote: true}, CacheSourceRemote},
+		{ItemStatus{Local: true, Remote: true}, CacheSourceLocal},
+	}
+	for _, tc := range testCases {
+		if got := tc.status.Source(); got != tc.want {
+			t.Errorf("%+v.Source() got %q, want %q", tc.status, got, tc.want)
+		}
+		if got := tc.status.Hit(); got != (tc.want != "") {
+			t.Errorf("%+v.Hit() got %v, want %v", tc.status, got, tc.want != "")
+		}
+	}
+}
+
 type nullRecorder struct{}
 
 func (nullRecorder) LogEvent(analytics.EventPayload) {}
@@ -292,10 +363,26 @@ func TestNew(t *testing.T) {
 				caches: []Cache{&fsCache{}, &httpCache{}},
 			},
 		},
+		{
+			name: "With a read-only remote cache, new returns an fsCache and a readOnlyCache",
+			args: args{
+				opts: Opts{
+					RemoteReadOnly: true,
+					RemoteCacheOpts: fs.RemoteCacheOptions{
+						Signature: true,
+					},
+				},
+				recorder:       &nullRecorder{},
+				onCacheRemoved: func(Cache, error) {},
+			},
+			want: &cacheMultiplexer{
+				caches: []Cache{&fsCache{}, &readOnlyCache{}},
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := New(tt.args.opts, repoRoot, &tt.args.client, tt.args.recorder, tt.args.onCacheRemoved)
+			got, err := New(tt.args.opts, repoRoot, &tt.args.client, tt.args.recorder, tt.args.onCacheRemoved, nil)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("New() error = %v, wantErr %v", err, tt.wantErr)
 				return
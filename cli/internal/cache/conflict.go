@@ -0,0 +1,180 @@
+package cache
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/DataDog/zstd"
+	"github.com/vercel/turbo/cli/pkg/artifacts"
+)
+
+// _maxConflictFiles is the number of differing files listed in a conflict diagnostic
+const _maxConflictFiles = 10
+
+// ArtifactConflictError is reported when an artifact is uploaded for a hash that
+// the Remote Cache already has a different artifact for. Artifacts are immutable,
+// so the existing artifact is kept. Differing contents for the same hash mean that
+// the hash does not capture every input of the task, or that the task's outputs
+// are not deterministic.
+type ArtifactConflictError struct {
+	Hash           string
+	ExistingDigest string
+	UploadedDigest string
+	// ChangedFiles lists the files whose contents differ between the artifacts,
+	// if the existing artifact could be compared
+	ChangedFiles []string
+}
+
+// Error implements error.Error
+func (e *ArtifactConflictError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "the Remote Cache already has a different artifact for %v, keeping it instead of uploading\n", e.Hash)
+	fmt.Fprintf(&b, "  existing digest: %v\n", e.ExistingDigest)
+	fmt.Fprintf(&b, "  uploaded digest: %v\n", e.UploadedDigest)
+	if len(e.ChangedFiles) > 0 {
+		b.WriteString("  differing files:\n")
+		for i, file := range e.ChangedFiles {
+			if i == _maxConflictFiles {
+				fmt.Fprintf(&b, "    ...and %v more\n", len(e.ChangedFiles)-i)
+				break
+			}
+			fmt.Fprintf(&b, "    %v\n", file)
+		}
+	}
+	b.WriteString("The task hash does not capture all of the task's inputs, or its outputs are not deterministic.")
+	return b.String()
+}
+
+// OnArtifactConflict defines a callback that the cache system calls when an upload
+// is refused because the Remote Cache has a different artifact for the same hash.
+type OnArtifactConflict = func(err *ArtifactConflictError)
+
+// contentDigest returns the digest of an artifact body, as reported in artifact metadata
+func contentDigest(body []byte) string {
+	sum := sha256.Sum256(body)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// checkConflict compares the artifact about to be uploaded for hash against the
+// one the Remote Cache already has, if any. body is the artifact before it's
+// encrypted, digest is the digest of the upload, and plaintextDigest is the digest
+// of body if the upload is encrypted. It returns true if the upload should be
+// skipped, along with a conflict if the existing artifact's contents differ.
+// The existing artifact is only downloaded if neither digest matches, or its
+// digests aren't reported, to find which of its files differ. If there is an
+// error, the upload is only skipped if the existing artifact is known to exist.
+func (cache *httpCache) checkConflict(hash string, body []byte, digest string, plaintextDigest string) (bool, *ArtifactConflictError, error) {
+	metadata, err := cache.client.ArtifactMetadata(context.Background(), hash)
+	if errors.Is(err, artifacts.ErrNotFound) {
+		return false, nil, nil
+	} else if err != nil {
+		return false, nil, err
+	}
+	if metadata.Digest == digest || (plaintextDigest != "" && metadata.PlaintextDigest == plaintextDigest) {
+		return true, nil, nil
+	}
+	artifact, err := cache.client.FetchArtifact(context.Background(), hash)
+	if errors.Is(err, artifacts.ErrNotFound) {
+		// It was removed since, so there is nothing to conflict with
+		return false, nil, nil
+	} else if err != nil {
+		return true, nil, err
+	}
+	defer func() { _ = artifact.Body.Close() }()
+	existing, err := ioutil.ReadAll(artifact.Body)
+	if err != nil {
+		return true, nil, err
+	}
+	existingDigest := artifact.Digest
+	if existingDigest == "" {
+		existingDigest = contentDigest(existing)
+		if existingDigest == digest {
+			return true, nil, nil
+		}
+	}
+	uploadedDigest := digest
+	if cache.encryption.isEnabled() {
+		existing, err = cache.encryption.decrypt(hash, existing, artifact.KeyID)
+		if err == nil {
+			// Report the digests of the contents, since the ciphertexts always differ
+			existingDigest = contentDigest(existing)
+			uploadedDigest = plaintextDigest
+		}
+	}
+	var changed []string
+	if err == nil {
+		changed, err = diffArtifacts(existing, body)
+	}
+	if err != nil {
+		// The existing artifact can't be read, but it still must not be overwritten
+		changed = nil
+	} else if len(changed) == 0 {
+		// Only the encoding differs, e.g. because another version of turbo compressed it,
+		// or because it was uploaded by a version of turbo that didn't record its plaintext digest
+		return true, nil, nil
+	}
+	return true, &ArtifactConflictError{
+		Hash:           hash,
+		ExistingDigest: existingDigest,
+		UploadedDigest: uploadedDigest,
+		ChangedFiles:   changed,
+	}, nil
+}
+
+// diffArtifacts returns the sorted names of the entries that differ between two
+// artifacts, including entries that are only in one of them
+func diffArtifacts(a []byte, b []byte) ([]string, error) {
+	aEntries, err := artifactEntries(a)
+	if err != nil {
+		return nil, err
+	}
+	bEntries, err := artifactEntries(b)
+	if err != nil {
+		return nil, err
+	}
+	var changed []string
+	for name, digest := range aEntries {
+		if bEntries[name] != digest {
+			changed = append(changed, name)
+		}
+	}
+	for name := range bEntries {
+		if _, ok := aEntries[name]; !ok {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	return changed, nil
+}
+
+// artifactEntries maps the name of each entry in an artifact to a digest of its
+// type, mode, link target and contents
+func artifactEntries(body []byte) (map[string]string, error) {
+	zr := zstd.NewReader(bytes.NewReader(body))
+	defer func() { _ = zr.Close() }()
+	tr := tar.NewReader(zr)
+	entries := make(map[string]string)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return entries, nil
+		} else if err != nil {
+			return nil, err
+		}
+		h := sha256.New()
+		fmt.Fprintf(h, "%v %o %v\n", hdr.Typeflag, hdr.Mode, hdr.Linkname)
+		if _, err := io.Copy(h, tr); err != nil {
+			return nil, err
+		}
+		entries[hdr.Name] = hex.EncodeToString(h.Sum(nil))
+	}
+}
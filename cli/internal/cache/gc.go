@@ -0,0 +1,227 @@
+package cache
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// _artifactSuffixes are the files that make up an artifact in the filesystem cache
+var _artifactSuffixes = []string{".tar.zst", ".tar", "-meta.json"}
+
+// Artifact is an artifact in the filesystem cache
+type Artifact struct {
+	Hash string `json:"hash"`
+	// Size is the size of the archive and its metadata
+	Size int64 `json:"size"`
+	// LastUsed is when the artifact was last written or restored
+	LastUsed time.Time `json:"lastUsed"`
+	Pinned   bool      `json:"pinned,omitempty"`
+	// TaskID is the task that produced the artifact, if it is known
+	TaskID string `json:"taskId,omitempty"`
+}
+
+// ListArtifacts returns the artifacts in the filesystem cache at cacheDir, the
+// least recently used first
+func ListArtifacts(cacheDir turbopath.AbsoluteSystemPath) ([]*Artifact, error) {
+	entries, err := os.ReadDir(cacheDir.ToString())
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	pins, err := ReadPins(cacheDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pinned artifacts: %w", err)
+	}
+	byHash := make(map[string]*Artifact)
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		for _, suffix := range _artifactSuffixes {
+			hash := strings.TrimSuffix(entry.Name(), suffix)
+			if hash == entry.Name() || hash == "" {
+				continue
+			}
+			info, err := entry.Info()
+			if os.IsNotExist(err) {
+				// Removed since the directory was read
+				break
+			} else if err != nil {
+				return nil, err
+			}
+			artifact, ok := byHash[hash]
+			if !ok {
+				artifact = &Artifact{Hash: hash, Pinned: pins.IsPinned(hash)}
+				if pin, ok := pins.Pins[hash]; ok {
+					artifact.TaskID = pin.TaskID
+				}
+				byHash[hash] = artifact
+			}
+			artifact.Size += info.Size()
+			// Restoring an artifact touches its archive, not its metadata
+			if suffix != "-meta.json" || artifact.LastUsed.IsZero() {
+				artifact.LastUsed = info.ModTime()
+			}
+			break
+		}
+	}
+	artifacts := make([]*Artifact, 0, len(byHash))
+	for _, artifact := range byHash {
+		artifacts = append(artifacts, artifact)
+	}
+	sort.Slice(artifacts, func(i, j int) bool {
+		if !artifacts[i].LastUsed.Equal(artifacts[j].LastUsed) {
+			return artifacts[i].LastUsed.Before(artifacts[j].LastUsed)
+		}
+		return artifacts[i].Hash < artifacts[j].Hash
+	})
+	return artifacts, nil
+}
+
+// GCOpts are the limits that GC enforces. A zero limit is not enforced.
+type GCOpts struct {
+	// MaxAge removes artifacts that haven't been used for longer than it
+	MaxAge time.Duration
+	// MaxSize removes the least recently used artifacts until the rest fit in it
+	MaxSize int64
+	// DryRun reports what would be removed without removing it
+	DryRun bool
+	// keep is an artifact that is never removed, such as the one that was just written
+	keep string
+}
+
+// GCResult describes what GC removed, and what it left in the cache
+type GCResult struct {
+	Removed []*Artifact `json:"removed"`
+	// Freed is the total size of the removed artifacts
+	Freed int64 `json:"freed"`
+	// Kept is the number of artifacts that remain, and KeptSize their total size
+	Kept     int   `json:"kept"`
+	KeptSize int64 `json:"keptSize"`
+	// Pinned is the number of artifacts that remain because they are pinned
+	Pinned int  `json:"pinned"`
+	DryRun bool `json:"dryRun"`
+}
+
+// GC removes artifacts from the filesystem cache at cacheDir that are older than
+// opts.MaxAge, and then the least recently used artifacts until the cache fits in
+// opts.MaxSize. Pinned artifacts are never removed, even if they alone exceed it.
+func GC(cacheDir turbopath.AbsoluteSystemPath, opts GCOpts, now time.Time) (*GCResult, error) {
+	artifacts, err := ListArtifacts(cacheDir)
+	if err != nil {
+		return nil, err
+	}
+	result := &GCResult{Removed: []*Artifact{}, DryRun: opts.DryRun}
+	var total int64
+	for _, artifact := range artifacts {
+		total += artifact.Size
+	}
+	for _, artifact := range artifacts {
+		if artifact.Pinned || artifact.Hash == opts.keep {
+			continue
+		}
+		expired := opts.MaxAge > 0 && now.Sub(artifact.LastUsed) > opts.MaxAge
+		oversized := opts.MaxSize > 0 && total > opts.MaxSize
+		if !expired && !oversized {
+			continue
+		}
+		if !opts.DryRun {
+			if err := removeArtifact(cacheDir, artifact.Hash); err != nil {
+				return nil, fmt.Errorf("failed to remove %v: %w", artifact.Hash, err)
+			}
+		}
+		result.Removed = append(result.Removed, artifact)
+		result.Freed += artifact.Size
+		total -= artifact.Size
+	}
+	for _, artifact := range artifacts {
+		if artifact.Pinned {
+			result.Pinned++
+		}
+	}
+	result.Kept = len(artifacts) - len(result.Removed)
+	result.KeptSize = total
+	if !opts.DryRun {
+		if err := removeStaleTempFiles(cacheDir, now); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// _staleTempFileAge is how old a temporary file in the cache must be before it is
+// considered left behind by an interrupted write, rather than being written
+const _staleTempFileAge = time.Hour
+
+// removeStaleTempFiles removes the temporary files that artifacts are written to
+// before they are moved into place, if they were left behind
+func removeStaleTempFiles(cacheDir turbopath.AbsoluteSystemPath, now time.Time) error {
+	entries, err := os.ReadDir(cacheDir.ToString())
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !strings.HasSuffix(entry.Name(), fs.TempFileSuffix) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || now.Sub(info.ModTime()) < _staleTempFileAge {
+			continue
+		}
+		if err := cacheDir.UntypedJoin(entry.Name()).Remove(); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %v: %w", entry.Name(), err)
+		}
+	}
+	return nil
+}
+
+// removeArtifact removes the files of the artifact for hash
+func removeArtifact(cacheDir turbopath.AbsoluteSystemPath, hash string) error {
+	for _, suffix := range _artifactSuffixes {
+		if err := cacheDir.UntypedJoin(hash + suffix).Remove(); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// byteSizeValue is a pflag.Value for a size such as "5GB", stored as a number of bytes
+type byteSizeValue struct {
+	size *int64
+}
+
+var _ pflag.Value = &byteSizeValue{}
+
+// String implements pflag.Value.String
+func (v *byteSizeValue) String() string {
+	if v.size == nil || *v.size == 0 {
+		return ""
+	}
+	return util.FormatByteSize(*v.size)
+}
+
+// Set implements pflag.Value.Set
+func (v *byteSizeValue) Set(raw string) error {
+	size, err := util.ParseByteSize(raw)
+	if err != nil {
+		return err
+	}
+	*v.size = size
+	return nil
+}
+
+// Type implements pflag.Value.Type
+func (v *byteSizeValue) Type() string {
+	return "size"
+}
@@ -0,0 +1,145 @@
+package cache
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"gotest.tools/v3/assert"
+)
+
+// writeArtifact writes an artifact of size bytes, plus its metadata, last used at lastUsed
+func writeArtifact(t *testing.T, cacheDir turbopath.AbsoluteSystemPath, hash string, size int, lastUsed time.Time) {
+	t.Helper()
+	archive := cacheDir.UntypedJoin(hash + ".tar.zst")
+	assert.NilError(t, archive.WriteFile([]byte(strings.Repeat("x", size)), 0644), "WriteFile")
+	assert.NilError(t, WriteCacheMetaFile(cacheDir.UntypedJoin(hash+"-meta.json"), &CacheMetadata{Hash: hash}), "WriteCacheMetaFile")
+	assert.NilError(t, os.Chtimes(archive.ToString(), lastUsed, lastUsed), "Chtimes")
+	assert.NilError(t, os.Chtimes(cacheDir.UntypedJoin(hash+"-meta.json").ToString(), lastUsed, lastUsed), "Chtimes")
+}
+
+func hashes(artifacts []*Artifact) []string {
+	result := make([]string, len(artifacts))
+	for i, artifact := range artifacts {
+		result[i] = artifact.Hash
+	}
+	return result
+}
+
+func TestListArtifacts(t *testing.T) {
+	cacheDir := turbopath.AbsoluteSystemPathFromUpstream(t.TempDir())
+	now := time.Now()
+	writeArtifact(t, cacheDir, "new", 10, now)
+	writeArtifact(t, cacheDir, "old", 20, now.Add(-time.Hour))
+	pins, err := ReadPins(cacheDir)
+	assert.NilError(t, err, "ReadPins")
+	pins.Pin("new", "", "")
+	assert.NilError(t, pins.Write(), "Write")
+
+	artifacts, err := ListArtifacts(cacheDir)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, hashes(artifacts), []string{"old", "new"})
+	assert.Assert(t, artifacts[0].Size > 20)
+	assert.Assert(t, !artifacts[0].Pinned)
+	assert.Assert(t, artifacts[1].Pinned)
+}
+
+func TestGC(t *testing.T) {
+	now := time.Now()
+	setup := func(t *testing.T) turbopath.AbsoluteSystemPath {
+		cacheDir := turbopath.AbsoluteSystemPathFromUpstream(t.TempDir())
+		writeArtifact(t, cacheDir, "a", 1000, now.Add(-40*24*time.Hour))
+		writeArtifact(t, cacheDir, "b", 1000, now.Add(-20*24*time.Hour))
+		writeArtifact(t, cacheDir, "c", 1000, now.Add(-10*24*time.Hour))
+		writeArtifact(t, cacheDir, "d", 1000, now)
+		pins, err := ReadPins(cacheDir)
+		assert.NilError(t, err, "ReadPins")
+		pins.Pin("b", "v1", "")
+		assert.NilError(t, pins.Write(), "Write")
+		return cacheDir
+	}
+
+	t.Run("max age", func(t *testing.T) {
+		cacheDir := setup(t)
+		result, err := GC(cacheDir, GCOpts{MaxAge: 15 * 24 * time.Hour}, now)
+		assert.NilError(t, err)
+		// b is pinned
+		assert.DeepEqual(t, hashes(result.Removed), []string{"a"})
+		assert.Equal(t, result.Kept, 3)
+		assert.Equal(t, result.Pinned, 1)
+		assert.Assert(t, !LocalArtifactExists(cacheDir, "a"))
+		assert.Assert(t, !cacheDir.UntypedJoin("a-meta.json").FileExists())
+		assert.Assert(t, LocalArtifactExists(cacheDir, "b"))
+	})
+
+	t.Run("max size", func(t *testing.T) {
+		cacheDir := setup(t)
+		result, err := GC(cacheDir, GCOpts{MaxSize: 2500}, now)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, hashes(result.Removed), []string{"a", "c"})
+		assert.Assert(t, result.KeptSize <= 2500)
+		assert.Assert(t, result.Freed+result.KeptSize > 4000)
+		assert.Assert(t, LocalArtifactExists(cacheDir, "d"))
+	})
+
+	t.Run("pinned artifacts exceed max size", func(t *testing.T) {
+		cacheDir := setup(t)
+		result, err := GC(cacheDir, GCOpts{MaxSize: 1}, now)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, hashes(result.Removed), []string{"a", "c", "d"})
+		assert.Assert(t, LocalArtifactExists(cacheDir, "b"))
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		cacheDir := setup(t)
+		result, err := GC(cacheDir, GCOpts{MaxAge: 15 * 24 * time.Hour, MaxSize: 1, DryRun: true}, now)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, hashes(result.Removed), []string{"a", "c", "d"})
+		artifacts, err := ListArtifacts(cacheDir)
+		assert.NilError(t, err)
+		assert.Equal(t, len(artifacts), 4)
+	})
+}
+
+func TestFsCacheMaxSize(t *testing.T) {
+	src := turbopath.AbsoluteSystemPathFromUpstream(t.TempDir())
+	assert.NilError(t, src.UntypedJoin("out.txt").WriteFile([]byte(strings.Repeat("output ", 100)), 0644), "WriteFile")
+	files := []turbopath.AnchoredSystemPath{turbopath.AnchoredUnixPath("out.txt").ToSystemPath()}
+	cacheDir := turbopath.AbsoluteSystemPathFromUpstream(t.TempDir())
+	writeArtifact(t, cacheDir, "old", 1000, time.Now().Add(-time.Hour))
+	writeArtifact(t, cacheDir, "recent", 1000, time.Now().Add(-time.Minute))
+
+	cache := &fsCache{cacheDirectory: cacheDir, recorder: &dummyRecorder{}, maxSize: 1500}
+	assert.NilError(t, cache.Put(src, "new", 0, files), "Put")
+	// Evicting the least recently used artifact is enough
+	assert.Assert(t, !LocalArtifactExists(cacheDir, "old"))
+	assert.Assert(t, LocalArtifactExists(cacheDir, "recent"))
+	assert.Assert(t, LocalArtifactExists(cacheDir, "new"))
+
+	// The artifact that was just written is kept, even if it alone is too large
+	cache.maxSize = 1
+	assert.NilError(t, cache.Put(src, "newer", 0, files), "Put")
+	assert.Assert(t, !LocalArtifactExists(cacheDir, "recent"))
+	assert.Assert(t, !LocalArtifactExists(cacheDir, "new"))
+	assert.Assert(t, LocalArtifactExists(cacheDir, "newer"))
+}
+
+func TestGCRemovesStaleTempFiles(t *testing.T) {
+	cacheDir := turbopath.AbsoluteSystemPathFromUpstream(t.TempDir())
+	now := time.Now()
+	writeArtifact(t, cacheDir, "a", 10, now)
+	stale := cacheDir.UntypedJoin(".b.tar.zst.123.tmp")
+	inProgress := cacheDir.UntypedJoin(".c.tar.zst.456.tmp")
+	assert.NilError(t, stale.WriteFile([]byte("trunc"), 0644), "WriteFile")
+	assert.NilError(t, inProgress.WriteFile([]byte("trunc"), 0644), "WriteFile")
+	assert.NilError(t, os.Chtimes(stale.ToString(), now.Add(-2*time.Hour), now.Add(-2*time.Hour)), "Chtimes")
+
+	result, err := GC(cacheDir, GCOpts{MaxAge: 24 * time.Hour}, now)
+	assert.NilError(t, err, "GC")
+	assert.Equal(t, result.Kept, 1)
+	assert.Assert(t, !stale.FileExists())
+	// A write that may still be in progress is left alone
+	assert.Assert(t, inProgress.FileExists())
+}
@@ -0,0 +1,439 @@
+package cache
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// _gcsScope is the OAuth scope needed to read and write objects
+const _gcsScope = "https://www.googleapis.com/auth/devstorage.read_write"
+
+// _googleTokenURL is the OAuth token endpoint, for credentials files that don't name one
+const _googleTokenURL = "https://oauth2.googleapis.com/token"
+
+// gcpToken is an OAuth access token for Google Cloud APIs
+type gcpToken struct {
+	AccessToken string
+	// Expires is zero for tokens that don't expire
+	Expires time.Time
+}
+
+// expired returns true if the token expires within the next five minutes
+func (t *gcpToken) expired(now time.Time) bool {
+	return !t.Expires.IsZero() && now.Add(5*time.Minute).After(t.Expires)
+}
+
+// errNoGCPCredentials is returned by a credential source that has nothing to offer,
+// so that the next source is tried
+var errNoGCPCredentials = errors.New("no Google Cloud credentials")
+
+// gcpCredentialProvider resolves Application Default Credentials the way the
+// Google Cloud SDKs do: from the file named by GOOGLE_APPLICATION_CREDENTIALS, the
+// file written by `gcloud auth application-default login`, and finally the metadata
+// server, which also serves GKE Workload Identity. Tokens are cached until shortly
+// before they expire.
+type gcpCredentialProvider struct {
+	getenv     func(string) string
+	homeDir    string
+	httpClient *http.Client
+	now        func() time.Time
+
+	mu     sync.Mutex
+	cached *gcpToken
+}
+
+func newGCPCredentialProvider() *gcpCredentialProvider {
+	home, _ := os.UserHomeDir()
+	return &gcpCredentialProvider{
+		getenv:     os.Getenv,
+		homeDir:    home,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		now:        time.Now,
+	}
+}
+
+// get returns a valid token, fetching a new one if the cached one is missing or
+// about to expire
+func (p *gcpCredentialProvider) get(ctx context.Context) (*gcpToken, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cached != nil && !p.cached.expired(p.now()) {
+		return p.cached, nil
+	}
+	sources := []func(context.Context) (*gcpToken, error){
+		p.fromEnvFile,
+		p.fromWellKnownFile,
+		p.fromMetadataServer,
+	}
+	for _, source := range sources {
+		token, err := source(ctx)
+		if errors.Is(err, errNoGCPCredentials) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		p.cached = token
+		return token, nil
+	}
+	return nil, errors.New("no Google Cloud credentials were found. Set GOOGLE_APPLICATION_CREDENTIALS, or run `gcloud auth application-default login`")
+}
+
+func (p *gcpCredentialProvider) fromEnvFile(ctx context.Context) (*gcpToken, error) {
+	path := p.getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	if path == "" {
+		return nil, errNoGCPCredentials
+	}
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read GOOGLE_APPLICATION_CREDENTIALS: %w", err)
+	}
+	return p.fromCredentialsFile(ctx, path, contents)
+}
+
+// fromWellKnownFile uses the credentials that gcloud writes for the SDKs
+func (p *gcpCredentialProvider) fromWellKnownFile(ctx context.Context) (*gcpToken, error) {
+	dir := p.getenv("CLOUDSDK_CONFIG")
+	if dir == "" {
+		if runtime.GOOS == "windows" {
+			dir = filepath.Join(p.getenv("APPDATA"), "gcloud")
+		} else if p.homeDir != "" {
+			dir = filepath.Join(p.homeDir, ".config", "gcloud")
+		} else {
+			return nil, errNoGCPCredentials
+		}
+	}
+	path := filepath.Join(dir, "application_default_credentials.json")
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errNoGCPCredentials
+	}
+	return p.fromCredentialsFile(ctx, path, contents)
+}
+
+// gcpCredentialsFile holds the fields of every supported type of credentials file
+type gcpCredentialsFile struct {
+	Type string `json:"type"`
+
+	// service_account
+	ClientEmail  string `json:"client_email"`
+	PrivateKeyID string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+	TokenURI     string `json:"token_uri"`
+
+	// authorized_user
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	RefreshToken string `json:"refresh_token"`
+
+	// external_account, for Workload Identity Federation
+	Audience                       string `json:"audience"`
+	SubjectTokenType               string `json:"subject_token_type"`
+	TokenURL                       string `json:"token_url"`
+	ServiceAccountImpersonationURL string `json:"service_account_impersonation_url"`
+	CredentialSource               struct {
+		File    string            `json:"file"`
+		URL     string            `json:"url"`
+		Headers map[string]string `json:"headers"`
+		Format  struct {
+			Type                  string `json:"type"`
+			SubjectTokenFieldName string `json:"subject_token_field_name"`
+		} `json:"format"`
+	} `json:"credential_source"`
+}
+
+func (p *gcpCredentialProvider) fromCredentialsFile(ctx context.Context, path string, contents []byte) (*gcpToken, error) {
+	var file gcpCredentialsFile
+	if err := json.Unmarshal(contents, &file); err != nil {
+		return nil, fmt.Errorf("invalid Google Cloud credentials in %v: %w", path, err)
+	}
+	var token *gcpToken
+	var err error
+	switch file.Type {
+	case "service_account":
+		token, err = p.fromServiceAccount(ctx, &file)
+	case "authorized_user":
+		token, err = p.fromAuthorizedUser(ctx, &file)
+	case "external_account":
+		token, err = p.fromExternalAccount(ctx, &file)
+	default:
+		return nil, fmt.Errorf("unsupported type of Google Cloud credentials %q in %v", file.Type, path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get a Google Cloud token with %v: %w", path, err)
+	}
+	return token, nil
+}
+
+// fromServiceAccount exchanges a JWT signed with the service account's key for a token
+func (p *gcpCredentialProvider) fromServiceAccount(ctx context.Context, file *gcpCredentialsFile) (*gcpToken, error) {
+	tokenURI := file.TokenURI
+	if tokenURI == "" {
+		tokenURI = _googleTokenURL
+	}
+	now := p.now()
+	claims := map[string]interface{}{
+		"iss":   file.ClientEmail,
+		"scope": _gcsScope,
+		"aud":   tokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}
+	assertion, err := signJWT(file.PrivateKey, file.PrivateKeyID, claims)
+	if err != nil {
+		return nil, err
+	}
+	return p.postToken(ctx, tokenURI, url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	})
+}
+
+// fromAuthorizedUser uses the refresh token of a user who logged in with gcloud
+func (p *gcpCredentialProvider) fromAuthorizedUser(ctx context.Context, file *gcpCredentialsFile) (*gcpToken, error) {
+	tokenURI := file.TokenURI
+	if tokenURI == "" {
+		tokenURI = _googleTokenURL
+	}
+	return p.postToken(ctx, tokenURI, url.Values{
+		"grant_type":    {"refresh_token"},
+		"client_id":     {file.ClientID},
+		"client_secret": {file.ClientSecret},
+		"refresh_token": {file.RefreshToken},
+	})
+}
+
+// fromExternalAccount exchanges a token from another identity provider, such as a
+// CI system's OIDC token, for a federated token with the Security Token Service,
+// and then for a service account's token if the account is impersonated
+func (p *gcpCredentialProvider) fromExternalAccount(ctx context.Context, file *gcpCredentialsFile) (*gcpToken, error) {
+	subjectToken, err := p.subjectToken(ctx, file)
+	if err != nil {
+		return nil, err
+	}
+	scope := _gcsScope
+	if file.ServiceAccountImpersonationURL != "" {
+		// Impersonation requires a token for the IAM Credentials API
+		scope = "https://www.googleapis.com/auth/cloud-platform"
+	}
+	token, err := p.postToken(ctx, file.TokenURL, url.Values{
+		"grant_type":           {"urn:ietf:params:oauth:grant-type:token-exchange"},
+		"audience":             {file.Audience},
+		"scope":                {scope},
+		"requested_token_type": {"urn:ietf:params:oauth:token-type:access_token"},
+		"subject_token":        {subjectToken},
+		"subject_token_type":   {file.SubjectTokenType},
+	})
+	if err != nil || file.ServiceAccountImpersonationURL == "" {
+		return token, err
+	}
+
+	body, err := json.Marshal(map[string]interface{}{"scope": []string{_gcsScope}})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, file.ServiceAccountImpersonationURL, strings.NewReader(string(body)))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	respBody, err := p.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to impersonate service account: %w", err)
+	}
+	var resp struct {
+		AccessToken string    `json:"accessToken"`
+		ExpireTime  time.Time `json:"expireTime"`
+	}
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		return nil, fmt.Errorf("failed to impersonate service account: %w", err)
+	}
+	return &gcpToken{AccessToken: resp.AccessToken, Expires: resp.ExpireTime}, nil
+}
+
+// subjectToken reads the external token from the file or URL in credential_source
+func (p *gcpCredentialProvider) subjectToken(ctx context.Context, file *gcpCredentialsFile) (string, error) {
+	source := file.CredentialSource
+	var contents []byte
+	var err error
+	switch {
+	case source.File != "":
+		contents, err = ioutil.ReadFile(source.File)
+	case source.URL != "":
+		var req *http.Request
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, source.URL, nil)
+		if err != nil {
+			return "", err
+		}
+		for name, value := range source.Headers {
+			req.Header.Set(name, value)
+		}
+		contents, err = p.do(req)
+	default:
+		return "", errors.New("credential_source must have a file or a url")
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read the subject token: %w", err)
+	}
+	if source.Format.Type != "json" {
+		return strings.TrimSpace(string(contents)), nil
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(contents, &fields); err != nil {
+		return "", fmt.Errorf("invalid subject token: %w", err)
+	}
+	token, ok := fields[source.Format.SubjectTokenFieldName].(string)
+	if !ok {
+		return "", fmt.Errorf("the subject token has no %q field", source.Format.SubjectTokenFieldName)
+	}
+	return token, nil
+}
+
+// fromMetadataServer fetches a token for the service account of the Compute Engine
+// instance, Cloud Run service, or GKE workload
+func (p *gcpCredentialProvider) fromMetadataServer(ctx context.Context) (*gcpToken, error) {
+	host := p.getenv("GCE_METADATA_HOST")
+	if host == "" {
+		host = "169.254.169.254"
+	}
+	// Off Google Cloud the metadata server doesn't answer, so don't wait long for it
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	u := fmt.Sprintf("http://%v/computeMetadata/v1/instance/service-accounts/default/token?scopes=%v", host, url.QueryEscape(_gcsScope))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, errNoGCPCredentials
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.Header.Get("Metadata-Flavor") != "Google" {
+		return nil, errNoGCPCredentials
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("failed to get a token from the metadata server: it responded with %v", resp.StatusCode)
+	}
+	return parseGCPToken(body, p.now())
+}
+
+// postToken posts form to an OAuth token endpoint
+func (p *gcpCredentialProvider) postToken(ctx context.Context, tokenURL string, form url.Values) (*gcpToken, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	body, err := p.do(req)
+	if err != nil {
+		return nil, err
+	}
+	return parseGCPToken(body, p.now())
+}
+
+// parseGCPToken parses an OAuth token response, which the metadata server shares
+func parseGCPToken(data []byte, now time.Time) (*gcpToken, error) {
+	var raw struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid token: %w", err)
+	}
+	if raw.AccessToken == "" {
+		return nil, errors.New("invalid token: access_token is required")
+	}
+	token := &gcpToken{AccessToken: raw.AccessToken}
+	if raw.ExpiresIn > 0 {
+		token.Expires = now.Add(time.Duration(raw.ExpiresIn) * time.Second)
+	}
+	return token, nil
+}
+
+// do sends req and returns the response body, or an error if the status isn't 2xx
+func (p *gcpCredentialProvider) do(req *http.Request) ([]byte, error) {
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("%v responded with %v: %v", req.URL.Host, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
+
+// signJWT returns a JWT with claims, signed with RS256 using a PEM-encoded RSA key
+func signJWT(privateKey string, keyID string, claims map[string]interface{}) (string, error) {
+	block, _ := pem.Decode([]byte(privateKey))
+	if block == nil {
+		return "", errors.New("invalid private key: expected PEM")
+	}
+	var key *rsa.PrivateKey
+	if parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		rsaKey, ok := parsed.(*rsa.PrivateKey)
+		if !ok {
+			return "", errors.New("invalid private key: expected an RSA key")
+		}
+		key = rsaKey
+	} else if rsaKey, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		key = rsaKey
+	} else {
+		return "", fmt.Errorf("invalid private key: %w", err)
+	}
+
+	header := map[string]string{"alg": "RS256", "typ": "JWT"}
+	if keyID != "" {
+		header["kid"] = keyID
+	}
+	encode := func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return base64.RawURLEncoding.EncodeToString(data), nil
+	}
+	encodedHeader, err := encode(header)
+	if err != nil {
+		return "", err
+	}
+	encodedClaims, err := encode(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := encodedHeader + "." + encodedClaims
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
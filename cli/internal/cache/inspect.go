@@ -0,0 +1,136 @@
+package cache
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/vercel/turbo/cli/internal/cacheitem"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+// ArtifactFile is an entry in the archive of an artifact
+type ArtifactFile struct {
+	Path string `json:"path"`
+	// Type is "file", "directory", or "symlink"
+	Type string `json:"type"`
+	Size int64  `json:"size"`
+	Mode string `json:"mode"`
+	// Target is where a symlink points
+	Target string `json:"target,omitempty"`
+}
+
+// ArtifactDetails describes an artifact in the filesystem cache, and what it contains
+type ArtifactDetails struct {
+	*Artifact
+	// DurationMs is how long the task that produced the artifact took
+	DurationMs int            `json:"durationMs"`
+	Ref        string         `json:"ref,omitempty"`
+	Files      []ArtifactFile `json:"files"`
+	// LogFile is the path, within the archive, of the task's captured logs, and
+	// Logs its contents
+	LogFile string `json:"logFile,omitempty"`
+	Logs    string `json:"logs,omitempty"`
+}
+
+// FindArtifact returns the artifact in the filesystem cache at cacheDir whose
+// hash is, or uniquely starts with, hash
+func FindArtifact(cacheDir turbopath.AbsoluteSystemPath, hash string) (*Artifact, error) {
+	artifacts, err := ListArtifacts(cacheDir)
+	if err != nil {
+		return nil, err
+	}
+	var matches []*Artifact
+	for _, artifact := range artifacts {
+		if artifact.Hash == hash {
+			return artifact, nil
+		}
+		if strings.HasPrefix(artifact.Hash, hash) {
+			matches = append(matches, artifact)
+		}
+	}
+	if len(matches) == 0 || hash == "" {
+		return nil, ErrArtifactMissing
+	} else if len(matches) > 1 {
+		return nil, fmt.Errorf("%v matches %v artifacts, give more of the hash", hash, len(matches))
+	}
+	return matches[0], nil
+}
+
+// InspectArtifact returns the metadata of the artifact in the filesystem cache at
+// cacheDir whose hash is, or uniquely starts with, hash, along with the files in
+// its archive and the task's captured logs. Nothing is restored.
+func InspectArtifact(cacheDir turbopath.AbsoluteSystemPath, hash string) (*ArtifactDetails, error) {
+	artifact, err := FindArtifact(cacheDir, hash)
+	if err != nil {
+		return nil, err
+	}
+	details := &ArtifactDetails{Artifact: artifact, Files: []ArtifactFile{}}
+	if meta, err := ReadCacheMetaFile(cacheDir.UntypedJoin(artifact.Hash + "-meta.json")); err == nil {
+		details.DurationMs = meta.Duration
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("metadata is unreadable: %w", err)
+	}
+	pins, err := ReadPins(cacheDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pinned artifacts: %w", err)
+	}
+	if pin, ok := pins.Pins[artifact.Hash]; ok {
+		details.Ref = pin.Ref
+	}
+
+	archivePath := cacheDir.UntypedJoin(artifact.Hash + ".tar.zst")
+	if !archivePath.FileExists() {
+		archivePath = cacheDir.UntypedJoin(artifact.Hash + ".tar")
+		if !archivePath.FileExists() {
+			return nil, ErrArtifactMissing
+		}
+	}
+	cacheItem, err := cacheitem.Open(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("archive cannot be opened: %w", err)
+	}
+	defer func() { _ = cacheItem.Close() }()
+	err = cacheItem.Walk(func(header *tar.Header, contents io.Reader) error {
+		file := ArtifactFile{
+			Path: header.Name,
+			Mode: header.FileInfo().Mode().String(),
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			file.Type = "directory"
+		case tar.TypeSymlink:
+			file.Type = "symlink"
+			file.Target = header.Linkname
+		default:
+			file.Type = "file"
+			file.Size = header.Size
+		}
+		details.Files = append(details.Files, file)
+		if file.Type == "file" && details.LogFile == "" && isTaskLogFile(header.Name) {
+			logs, err := ioutil.ReadAll(contents)
+			if err != nil {
+				return err
+			}
+			details.LogFile = header.Name
+			details.Logs = string(logs)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("archive cannot be read: %w", err)
+	}
+	return details, nil
+}
+
+// isTaskLogFile returns true if name, a path within an archive, is where a task's
+// logs are captured: <package>/.turbo/turbo-<task>.log
+func isTaskLogFile(name string) bool {
+	dir, base := path.Split(strings.TrimSuffix(name, "/"))
+	return path.Base(dir) == ".turbo" && strings.HasPrefix(base, "turbo-") && strings.HasSuffix(base, ".log")
+}
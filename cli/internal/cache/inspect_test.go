@@ -0,0 +1,58 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"gotest.tools/v3/assert"
+)
+
+func TestInspectArtifact(t *testing.T) {
+	repoRoot := turbopath.AbsoluteSystemPath(t.TempDir())
+	src := repoRoot.UntypedJoin("my-pkg")
+	assert.NilError(t, src.UntypedJoin(".turbo").MkdirAll(0775), "MkdirAll")
+	assert.NilError(t, src.UntypedJoin("out").WriteFile([]byte("output"), 0644), "WriteFile")
+	assert.NilError(t, src.UntypedJoin(".turbo", "turbo-build.log").WriteFile([]byte("building\n"), 0644), "WriteFile")
+
+	opts := Opts{OverrideDir: "cache"}
+	fsCache, err := newFsCache(opts, &dummyRecorder{}, repoRoot)
+	assert.NilError(t, err, "newFsCache")
+	files := []turbopath.AnchoredSystemPath{"my-pkg/.turbo/turbo-build.log", "my-pkg/out"}
+	assert.NilError(t, fsCache.Put(repoRoot, "abc123", 1500, files), "Put")
+	assert.NilError(t, fsCache.Put(repoRoot, "abd456", 0, files), "Put")
+	cacheDir := repoRoot.UntypedJoin("cache")
+	pins, err := ReadPins(cacheDir)
+	assert.NilError(t, err, "ReadPins")
+	pins.Pin("abc123", "v1", "my-pkg#build")
+	assert.NilError(t, pins.Write(), "Write")
+
+	details, err := InspectArtifact(cacheDir, "abc")
+	assert.NilError(t, err)
+	assert.Equal(t, details.Hash, "abc123")
+	assert.Equal(t, details.TaskID, "my-pkg#build")
+	assert.Equal(t, details.Ref, "v1")
+	assert.Equal(t, details.DurationMs, 1500)
+	assert.Equal(t, details.LogFile, "my-pkg/.turbo/turbo-build.log")
+	assert.Equal(t, details.Logs, "building\n")
+	var paths []string
+	for _, file := range details.Files {
+		paths = append(paths, file.Path)
+		if file.Path == "my-pkg/out" {
+			assert.Equal(t, file.Type, "file")
+			assert.Equal(t, file.Size, int64(6))
+		}
+	}
+	assert.DeepEqual(t, paths, []string{"my-pkg/.turbo/turbo-build.log", "my-pkg/out"})
+
+	_, err = InspectArtifact(cacheDir, "ab")
+	assert.ErrorContains(t, err, "ab matches 2 artifacts")
+	_, err = InspectArtifact(cacheDir, "missing")
+	assert.ErrorIs(t, err, ErrArtifactMissing)
+}
+
+func TestIsTaskLogFile(t *testing.T) {
+	assert.Assert(t, isTaskLogFile("apps/web/.turbo/turbo-build.log"))
+	assert.Assert(t, isTaskLogFile(".turbo/turbo-lint.log"))
+	assert.Assert(t, !isTaskLogFile("apps/web/turbo-build.log"))
+	assert.Assert(t, !isTaskLogFile("apps/web/.turbo/build.log"))
+}
@@ -0,0 +1,221 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/vercel/turbo/cli/internal/util"
+	"github.com/vercel/turbo/cli/pkg/artifacts"
+)
+
+// _maxObjectStoreFailCount is the number of failed requests before an object store
+// is no longer used for the run
+const _maxObjectStoreFailCount = uint64(3)
+
+// Names of the user-defined metadata of an artifact's object. They have no dashes,
+// which Azure Blob Storage doesn't allow in metadata names.
+const (
+	_metadataDuration        = "Duration"
+	_metadataTag             = "Tag"
+	_metadataDigest          = "Digest"
+	_metadataKeyID           = "Keyid"
+	_metadataPlaintextDigest = "Plaintextdigest"
+	_metadataHashSchema      = "Hashschema"
+)
+
+// objectStore stores artifacts as objects in a bucket of S3, Cloud Storage or
+// Azure Blob Storage. It implements the same operations as the Remote Cache API,
+// so that artifacts are signed, encrypted and checked for conflicts the same way.
+// The services differ only in where objects are, how requests are authorized, and
+// how user-defined metadata is named, which each store sets up.
+type objectStore struct {
+	httpClient *http.Client
+	// location names the bucket and prefix in errors, such as "s3://bucket/prefix"
+	location string
+	// objectURL returns the URL of the object holding the artifact for hash
+	objectURL func(hash string) *url.URL
+	// authorize adds credentials to a request, whose body is body
+	authorize func(ctx context.Context, req *http.Request, body []byte) error
+	// metadataPrefix starts the names of the headers that hold user-defined metadata
+	metadataPrefix string
+	// putHeader holds the headers that uploads need besides the metadata. It makes
+	// uploads conditional, so that they only create objects: two uploads racing
+	// for the same hash can't replace each other's artifact.
+	putHeader http.Header
+	failCount uint64
+}
+
+// GetTeamID implements client.GetTeamID. Artifacts in object stores don't belong to a team.
+func (s *objectStore) GetTeamID() string {
+	return ""
+}
+
+// do authorizes and sends a request for the artifact for hash
+func (s *objectStore) do(ctx context.Context, method string, hash string, body []byte, header http.Header) (*http.Response, error) {
+	if atomic.LoadUint64(&s.failCount) >= _maxObjectStoreFailCount {
+		return nil, util.ErrTooManyFailures
+	}
+	req, err := http.NewRequestWithContext(ctx, method, s.objectURL(hash).String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if err := s.authorize(ctx, req, body); err != nil {
+		return nil, &util.CacheDisabledError{Status: util.CachingStatusDisabled, Message: err.Error()}
+	}
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		atomic.AddUint64(&s.failCount, 1)
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		defer func() { _ = resp.Body.Close() }()
+		return nil, &util.CacheDisabledError{
+			Status:  util.CachingStatusDisabled,
+			Message: fmt.Sprintf("access to %v was denied: %v", s.location, storageError(resp)),
+		}
+	}
+	if resp.StatusCode >= 500 {
+		atomic.AddUint64(&s.failCount, 1)
+	}
+	return resp, nil
+}
+
+// ArtifactExists implements artifacts.API.ArtifactExists
+func (s *objectStore) ArtifactExists(ctx context.Context, hash string) (bool, error) {
+	_, err := s.ArtifactMetadata(ctx, hash)
+	if err == artifacts.ErrNotFound {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ArtifactMetadata implements artifacts.API.ArtifactMetadata
+func (s *objectStore) ArtifactMetadata(ctx context.Context, hash string) (*artifacts.Metadata, error) {
+	resp, err := s.do(ctx, http.MethodHead, hash, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, artifacts.ErrNotFound
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return nil, storageError(resp)
+	}
+	metadata := s.metadata(resp.Header)
+	return &metadata, nil
+}
+
+// metadata reads the metadata of an artifact from the user-defined metadata of its object
+func (s *objectStore) metadata(header http.Header) artifacts.Metadata {
+	duration, _ := strconv.Atoi(header.Get(s.metadataPrefix + _metadataDuration))
+	hashSchema, _ := strconv.Atoi(header.Get(s.metadataPrefix + _metadataHashSchema))
+	return artifacts.Metadata{
+		Duration:        duration,
+		Tag:             header.Get(s.metadataPrefix + _metadataTag),
+		Digest:          header.Get(s.metadataPrefix + _metadataDigest),
+		KeyID:           header.Get(s.metadataPrefix + _metadataKeyID),
+		PlaintextDigest: header.Get(s.metadataPrefix + _metadataPlaintextDigest),
+		HashSchema:      hashSchema,
+	}
+}
+
+// FetchArtifact implements artifacts.API.FetchArtifact
+func (s *objectStore) FetchArtifact(ctx context.Context, hash string) (*artifacts.Artifact, error) {
+	resp, err := s.do(ctx, http.MethodGet, hash, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		_ = resp.Body.Close()
+		return nil, artifacts.ErrNotFound
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		defer func() { _ = resp.Body.Close() }()
+		return nil, storageError(resp)
+	}
+	return &artifacts.Artifact{
+		Metadata: s.metadata(resp.Header),
+		Body:     resp.Body,
+	}, nil
+}
+
+// PutArtifact implements artifacts.API.PutArtifact. The metadata is stored as
+// user-defined object metadata. It returns artifacts.ErrExists if there is
+// already an object for hash.
+func (s *objectStore) PutArtifact(ctx context.Context, hash string, body io.Reader, metadata artifacts.Metadata) error {
+	contents, err := ioutil.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	header := s.putHeader.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	header.Set("Content-Type", "application/octet-stream")
+	header.Set(s.metadataPrefix+_metadataDuration, strconv.Itoa(metadata.Duration))
+	if metadata.Tag != "" {
+		header.Set(s.metadataPrefix+_metadataTag, metadata.Tag)
+	}
+	if metadata.Digest != "" {
+		header.Set(s.metadataPrefix+_metadataDigest, metadata.Digest)
+	}
+	if metadata.KeyID != "" {
+		header.Set(s.metadataPrefix+_metadataKeyID, metadata.KeyID)
+	}
+	if metadata.PlaintextDigest != "" {
+		header.Set(s.metadataPrefix+_metadataPlaintextDigest, metadata.PlaintextDigest)
+	}
+	if metadata.HashSchema != 0 {
+		header.Set(s.metadataPrefix+_metadataHashSchema, strconv.Itoa(metadata.HashSchema))
+	}
+	resp, err := s.do(ctx, http.MethodPut, hash, contents, header)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	switch {
+	case resp.StatusCode == http.StatusPreconditionFailed || resp.StatusCode == http.StatusConflict:
+		// The object was created since it was checked for. Azure reports this as a
+		// conflict, and S3 also does when another conditional upload is in progress.
+		return artifacts.ErrExists
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return storageError(resp)
+	}
+	return nil
+}
+
+// storageError returns an *artifacts.APIError for an unsuccessful response from S3,
+// Cloud Storage or Azure Blob Storage, with the code and message from its XML
+// body, if it has one
+func storageError(resp *http.Response) error {
+	apiErr := &artifacts.APIError{StatusCode: resp.StatusCode}
+	var body struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if data, err := ioutil.ReadAll(resp.Body); err == nil && xml.Unmarshal(data, &body) == nil {
+		apiErr.Code = body.Code
+		apiErr.Message = body.Message
+		if apiErr.Message == "" {
+			apiErr.Message = body.Code
+		}
+	}
+	if apiErr.Message == "" {
+		apiErr.Message = http.StatusText(resp.StatusCode)
+	}
+	return apiErr
+}
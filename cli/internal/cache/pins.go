@@ -0,0 +1,118 @@
+package cache
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+// _pinsFile is the name of the file, within the cache directory, that records pinned artifacts
+const _pinsFile = "pins.json"
+
+// Pin records an artifact that must never be evicted from the cache
+type Pin struct {
+	Hash string `json:"hash"`
+	// Ref is an optional name shared by artifacts that were pinned together,
+	// such as the tag of a release
+	Ref      string    `json:"ref,omitempty"`
+	TaskID   string    `json:"taskId,omitempty"`
+	PinnedAt time.Time `json:"pinnedAt"`
+}
+
+// Pins is the set of pinned artifacts for a cache directory
+type Pins struct {
+	path turbopath.AbsoluteSystemPath
+	// Pins is a map of hash -> Pin
+	Pins map[string]*Pin `json:"pins"`
+}
+
+// ResolveCacheDir returns the filesystem cache directory for the given options
+func ResolveCacheDir(opts Opts, repoRoot turbopath.AbsoluteSystemPath) turbopath.AbsoluteSystemPath {
+	return opts.resolveCacheDir(repoRoot)
+}
+
+// ReadPins loads the pinned artifacts for the given cache directory
+func ReadPins(cacheDir turbopath.AbsoluteSystemPath) (*Pins, error) {
+	pins := &Pins{
+		path: cacheDir.UntypedJoin(_pinsFile),
+		Pins: make(map[string]*Pin),
+	}
+	bytes, err := pins.path.ReadFile()
+	if os.IsNotExist(err) {
+		return pins, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(bytes, pins); err != nil {
+		return nil, err
+	}
+	if pins.Pins == nil {
+		pins.Pins = make(map[string]*Pin)
+	}
+	return pins, nil
+}
+
+// IsPinned returns true if the artifact for hash must be retained
+func (p *Pins) IsPinned(hash string) bool {
+	_, ok := p.Pins[hash]
+	return ok
+}
+
+// Pin marks an artifact as pinned. Pinning an already pinned artifact
+// updates its ref and task.
+func (p *Pins) Pin(hash string, ref string, taskID string) {
+	p.Pins[hash] = &Pin{
+		Hash:     hash,
+		Ref:      ref,
+		TaskID:   taskID,
+		PinnedAt: time.Now(),
+	}
+}
+
+// Unpin removes the pin for hashOrRef, which is either the hash of a single artifact
+// or a ref shared by several. It returns the pins that were removed.
+func (p *Pins) Unpin(hashOrRef string) []*Pin {
+	var removed []*Pin
+	for hash, pin := range p.Pins {
+		if hash == hashOrRef || (pin.Ref != "" && pin.Ref == hashOrRef) {
+			removed = append(removed, pin)
+			delete(p.Pins, hash)
+		}
+	}
+	sortPins(removed)
+	return removed
+}
+
+// List returns every pin, ordered by ref and then hash
+func (p *Pins) List() []*Pin {
+	pins := make([]*Pin, 0, len(p.Pins))
+	for _, pin := range p.Pins {
+		pins = append(pins, pin)
+	}
+	sortPins(pins)
+	return pins
+}
+
+// Write saves the pins to the cache directory
+func (p *Pins) Write() error {
+	bytes, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := p.path.EnsureDir(); err != nil {
+		return err
+	}
+	return p.path.WriteFile(bytes, 0644)
+}
+
+func sortPins(pins []*Pin) {
+	sort.Slice(pins, func(i, j int) bool {
+		if pins[i].Ref != pins[j].Ref {
+			return pins[i].Ref < pins[j].Ref
+		}
+		return pins[i].Hash < pins[j].Hash
+	})
+}
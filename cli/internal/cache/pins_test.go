@@ -0,0 +1,40 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"gotest.tools/v3/assert"
+)
+
+func TestPins(t *testing.T) {
+	cacheDir := turbopath.AbsoluteSystemPathFromUpstream(t.TempDir())
+
+	pins, err := ReadPins(cacheDir)
+	assert.NilError(t, err, "ReadPins with no pins file")
+	assert.Equal(t, len(pins.List()), 0)
+
+	pins.Pin("abc", "", "a#build")
+	pins.Pin("def", "v1.0.0", "b#build")
+	pins.Pin("ghi", "v1.0.0", "c#build")
+	assert.NilError(t, pins.Write(), "Write")
+
+	reread, err := ReadPins(cacheDir)
+	assert.NilError(t, err, "ReadPins")
+	assert.Assert(t, reread.IsPinned("abc"))
+	assert.Assert(t, reread.IsPinned("def"))
+	assert.Assert(t, !reread.IsPinned("xyz"))
+	list := reread.List()
+	assert.Equal(t, len(list), 3)
+	assert.Equal(t, list[0].Hash, "abc")
+
+	removed := reread.Unpin("v1.0.0")
+	assert.Equal(t, len(removed), 2)
+	assert.Assert(t, !reread.IsPinned("def"))
+	assert.Assert(t, !reread.IsPinned("ghi"))
+	assert.Assert(t, reread.IsPinned("abc"))
+
+	removed = reread.Unpin("abc")
+	assert.Equal(t, len(removed), 1)
+	assert.Equal(t, len(reread.Unpin("abc")), 0)
+}
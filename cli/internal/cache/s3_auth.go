@@ -0,0 +1,513 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// awsCredentials are the keys used to sign requests to AWS
+type awsCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	// Expires is zero for credentials that don't expire
+	Expires time.Time
+}
+
+// expired returns true if the credentials expire within the next five minutes
+func (c *awsCredentials) expired(now time.Time) bool {
+	return !c.Expires.IsZero() && now.Add(5*time.Minute).After(c.Expires)
+}
+
+// errNoAWSCredentials is returned by a credential source that has nothing to offer,
+// so that the next source is tried
+var errNoAWSCredentials = errors.New("no AWS credentials")
+
+// awsCredentialProvider resolves credentials the way the AWS CLI and SDKs do:
+// from the environment, the shared credentials and config files, a web identity
+// token, the ECS container endpoint, and finally the EC2 instance metadata service.
+// Credentials are cached until shortly before they expire.
+type awsCredentialProvider struct {
+	getenv     func(string) string
+	homeDir    string
+	httpClient *http.Client
+	now        func() time.Time
+
+	mu     sync.Mutex
+	cached *awsCredentials
+}
+
+func newAWSCredentialProvider() *awsCredentialProvider {
+	home, _ := os.UserHomeDir()
+	return &awsCredentialProvider{
+		getenv:     os.Getenv,
+		homeDir:    home,
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+		now:        time.Now,
+	}
+}
+
+// get returns valid credentials, resolving them again if the cached ones are
+// missing or about to expire
+func (p *awsCredentialProvider) get(ctx context.Context) (*awsCredentials, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cached != nil && !p.cached.expired(p.now()) {
+		return p.cached, nil
+	}
+	sources := []func(context.Context) (*awsCredentials, error){
+		p.fromEnv,
+		p.fromSharedFiles,
+		p.fromWebIdentity,
+		p.fromContainer,
+		p.fromInstanceMetadata,
+	}
+	for _, source := range sources {
+		creds, err := source(ctx)
+		if errors.Is(err, errNoAWSCredentials) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		p.cached = creds
+		return creds, nil
+	}
+	return nil, errors.New("no AWS credentials were found. Set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY, or configure a profile")
+}
+
+func (p *awsCredentialProvider) profile() string {
+	if profile := p.getenv("AWS_PROFILE"); profile != "" {
+		return profile
+	}
+	return "default"
+}
+
+func (p *awsCredentialProvider) fromEnv(_ context.Context) (*awsCredentials, error) {
+	accessKeyID := p.getenv("AWS_ACCESS_KEY_ID")
+	secretAccessKey := p.getenv("AWS_SECRET_ACCESS_KEY")
+	if accessKeyID == "" || secretAccessKey == "" {
+		return nil, errNoAWSCredentials
+	}
+	return &awsCredentials{
+		AccessKeyID:     accessKeyID,
+		SecretAccessKey: secretAccessKey,
+		SessionToken:    p.getenv("AWS_SESSION_TOKEN"),
+	}, nil
+}
+
+// sharedConfigFile returns the path to the file named by envVar, or to name in ~/.aws
+func (p *awsCredentialProvider) sharedConfigFile(envVar string, name string) string {
+	if path := p.getenv(envVar); path != "" {
+		return path
+	}
+	if p.homeDir == "" {
+		return ""
+	}
+	return filepath.Join(p.homeDir, ".aws", name)
+}
+
+// profileSettings returns the settings of the current profile from the shared
+// credentials file, falling back to the shared config file for settings that
+// the credentials file doesn't have
+func (p *awsCredentialProvider) profileSettings() map[string]string {
+	profile := p.profile()
+	settings := make(map[string]string)
+	configSection := "profile " + profile
+	if profile == "default" {
+		configSection = "default"
+	}
+	for key, value := range readINISection(p.sharedConfigFile("AWS_CONFIG_FILE", "config"), configSection) {
+		settings[key] = value
+	}
+	for key, value := range readINISection(p.sharedConfigFile("AWS_SHARED_CREDENTIALS_FILE", "credentials"), profile) {
+		settings[key] = value
+	}
+	return settings
+}
+
+func (p *awsCredentialProvider) fromSharedFiles(ctx context.Context) (*awsCredentials, error) {
+	settings := p.profileSettings()
+	if settings["aws_access_key_id"] != "" && settings["aws_secret_access_key"] != "" {
+		return &awsCredentials{
+			AccessKeyID:     settings["aws_access_key_id"],
+			SecretAccessKey: settings["aws_secret_access_key"],
+			SessionToken:    settings["aws_session_token"],
+		}, nil
+	}
+	if command := settings["credential_process"]; command != "" {
+		return p.fromProcess(ctx, command)
+	}
+	return nil, errNoAWSCredentials
+}
+
+// fromProcess runs a credential_process command, which prints credentials as JSON
+func (p *awsCredentialProvider) fromProcess(ctx context.Context, command string) (*awsCredentials, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd.exe", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("credential_process for AWS profile %v failed: %w", p.profile(), err)
+	}
+	creds, err := parseJSONCredentials(out)
+	if err != nil {
+		return nil, fmt.Errorf("credential_process for AWS profile %v: %w", p.profile(), err)
+	}
+	return creds, nil
+}
+
+// parseJSONCredentials parses credentials in the format shared by credential_process,
+// the ECS container endpoint, and the EC2 instance metadata service
+func parseJSONCredentials(data []byte) (*awsCredentials, error) {
+	var raw struct {
+		AccessKeyID     string `json:"AccessKeyId"`
+		SecretAccessKey string `json:"SecretAccessKey"`
+		// The container and instance endpoints call it Token, credential_process SessionToken
+		Token        string `json:"Token"`
+		SessionToken string `json:"SessionToken"`
+		Expiration   string `json:"Expiration"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid credentials: %w", err)
+	}
+	if raw.AccessKeyID == "" || raw.SecretAccessKey == "" {
+		return nil, errors.New("invalid credentials: AccessKeyId and SecretAccessKey are required")
+	}
+	creds := &awsCredentials{
+		AccessKeyID:     raw.AccessKeyID,
+		SecretAccessKey: raw.SecretAccessKey,
+		SessionToken:    raw.SessionToken,
+	}
+	if raw.Token != "" {
+		creds.SessionToken = raw.Token
+	}
+	if raw.Expiration != "" {
+		expires, err := time.Parse(time.RFC3339, raw.Expiration)
+		if err != nil {
+			return nil, fmt.Errorf("invalid credentials: %w", err)
+		}
+		creds.Expires = expires
+	}
+	return creds, nil
+}
+
+// fromWebIdentity exchanges the token in AWS_WEB_IDENTITY_TOKEN_FILE for credentials
+// for AWS_ROLE_ARN, as is done for EKS service accounts and OIDC in CI
+func (p *awsCredentialProvider) fromWebIdentity(ctx context.Context) (*awsCredentials, error) {
+	tokenFile := p.getenv("AWS_WEB_IDENTITY_TOKEN_FILE")
+	roleARN := p.getenv("AWS_ROLE_ARN")
+	if tokenFile == "" || roleARN == "" {
+		return nil, errNoAWSCredentials
+	}
+	token, err := ioutil.ReadFile(tokenFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read web identity token: %w", err)
+	}
+	sessionName := p.getenv("AWS_ROLE_SESSION_NAME")
+	if sessionName == "" {
+		sessionName = fmt.Sprintf("turbo-%d", p.now().Unix())
+	}
+	endpoint := "https://sts.amazonaws.com/"
+	if region := awsRegion(p.getenv, p.profileSettings()); region != "" {
+		endpoint = fmt.Sprintf("https://sts.%v.amazonaws.com/", region)
+	}
+	form := url.Values{
+		"Action":           {"AssumeRoleWithWebIdentity"},
+		"Version":          {"2011-06-15"},
+		"RoleArn":          {roleARN},
+		"RoleSessionName":  {sessionName},
+		"WebIdentityToken": {strings.TrimSpace(string(token))},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	body, err := p.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to assume %v with web identity: %w", roleARN, err)
+	}
+	var resp struct {
+		Credentials struct {
+			AccessKeyID     string    `xml:"AccessKeyId"`
+			SecretAccessKey string    `xml:"SecretAccessKey"`
+			SessionToken    string    `xml:"SessionToken"`
+			Expiration      time.Time `xml:"Expiration"`
+		} `xml:"AssumeRoleWithWebIdentityResult>Credentials"`
+	}
+	if err := xml.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("failed to assume %v with web identity: %w", roleARN, err)
+	}
+	return &awsCredentials{
+		AccessKeyID:     resp.Credentials.AccessKeyID,
+		SecretAccessKey: resp.Credentials.SecretAccessKey,
+		SessionToken:    resp.Credentials.SessionToken,
+		Expires:         resp.Credentials.Expiration,
+	}, nil
+}
+
+// fromContainer fetches credentials from the ECS (or EKS Pod Identity) container endpoint
+func (p *awsCredentialProvider) fromContainer(ctx context.Context) (*awsCredentials, error) {
+	endpoint := p.getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI")
+	if relative := p.getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"); relative != "" {
+		endpoint = "http://169.254.170.2" + relative
+	}
+	if endpoint == "" {
+		return nil, errNoAWSCredentials
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	token := p.getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN")
+	if tokenFile := p.getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE"); tokenFile != "" {
+		contents, err := ioutil.ReadFile(tokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read container authorization token: %w", err)
+		}
+		token = strings.TrimSpace(string(contents))
+	}
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+	body, err := p.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch container credentials: %w", err)
+	}
+	return parseJSONCredentials(body)
+}
+
+// fromInstanceMetadata fetches the credentials of the EC2 instance's role using IMDSv2
+func (p *awsCredentialProvider) fromInstanceMetadata(ctx context.Context) (*awsCredentials, error) {
+	if strings.EqualFold(p.getenv("AWS_EC2_METADATA_DISABLED"), "true") {
+		return nil, errNoAWSCredentials
+	}
+	endpoint := strings.TrimSuffix(p.getenv("AWS_EC2_METADATA_SERVICE_ENDPOINT"), "/")
+	if endpoint == "" {
+		endpoint = "http://169.254.169.254"
+	}
+	// Off EC2 the metadata service doesn't answer, so don't wait long for it
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, endpoint+"/latest/api/token", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "21600")
+	token, err := p.do(req)
+	if err != nil {
+		return nil, errNoAWSCredentials
+	}
+	get := func(path string) ([]byte, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+path, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("X-aws-ec2-metadata-token", string(token))
+		return p.do(req)
+	}
+	role, err := get("/latest/meta-data/iam/security-credentials/")
+	if err != nil {
+		return nil, errNoAWSCredentials
+	}
+	roleName := strings.TrimSpace(strings.SplitN(string(role), "\n", 2)[0])
+	if roleName == "" {
+		return nil, errNoAWSCredentials
+	}
+	body, err := get("/latest/meta-data/iam/security-credentials/" + roleName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch instance credentials: %w", err)
+	}
+	return parseJSONCredentials(body)
+}
+
+// do sends req and returns the response body, or an error if the status isn't 2xx
+func (p *awsCredentialProvider) do(req *http.Request) ([]byte, error) {
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("%v responded with %v", req.URL.Host, resp.StatusCode)
+	}
+	return body, nil
+}
+
+// awsRegion returns the region configured in the environment or the current
+// profile, or "" if there isn't one
+func awsRegion(getenv func(string) string, profileSettings map[string]string) string {
+	if region := getenv("AWS_REGION"); region != "" {
+		return region
+	}
+	if region := getenv("AWS_DEFAULT_REGION"); region != "" {
+		return region
+	}
+	return profileSettings["region"]
+}
+
+// readINISection returns the keys and values in the named section of an INI file,
+// such as ~/.aws/credentials. A missing file has no sections.
+func readINISection(path string, section string) map[string]string {
+	settings := make(map[string]string)
+	if path == "" {
+		return settings
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return settings
+	}
+	defer func() { _ = f.Close() }()
+	inSection := false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			inSection = strings.TrimSpace(line[1:len(line)-1]) == section
+			continue
+		}
+		if !inSection {
+			continue
+		}
+		if key, value, ok := strings.Cut(line, "="); ok {
+			settings[strings.ToLower(strings.TrimSpace(key))] = strings.TrimSpace(value)
+		}
+	}
+	return settings
+}
+
+// _emptyPayloadHash is the SHA-256 of an empty body
+const _emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// signV4 signs req with AWS Signature Version 4. Every header already set on req
+// is signed, along with the host. payloadHash is the hex SHA-256 of the body.
+func signV4(req *http.Request, creds *awsCredentials, region string, service string, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		trimmed := make([]string, len(values))
+		for i, value := range values {
+			trimmed[i] = strings.Join(strings.Fields(value), " ")
+		}
+		headers[strings.ToLower(name)] = strings.Join(trimmed, ",")
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		awsURIEncode(req.URL.Path, false),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := strings.Join([]string{date, region, service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%v/%v,SignedHeaders=%v,Signature=%v",
+		creds.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, key := range keys {
+		values := append([]string{}, query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			parts = append(parts, awsURIEncode(key, true)+"="+awsURIEncode(value, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// awsURIEncode percent-encodes everything but the unreserved characters of RFC 3986,
+// as Signature Version 4 requires. Slashes are kept unless encodeSlash is set.
+func awsURIEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (c == '/' && !encodeSlash) {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	_, _ = mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
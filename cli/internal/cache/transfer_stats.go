@@ -0,0 +1,54 @@
+package cache
+
+import (
+	"io"
+	"sync/atomic"
+)
+
+// TransferStats counts the bytes uploaded to and downloaded from the remote
+// cache. It is safe for concurrent use, and a nil TransferStats counts nothing.
+type TransferStats struct {
+	uploaded   int64
+	downloaded int64
+}
+
+// Uploaded returns the number of bytes uploaded to the remote cache
+func (s *TransferStats) Uploaded() int64 {
+	if s == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&s.uploaded)
+}
+
+// Downloaded returns the number of bytes downloaded from the remote cache
+func (s *TransferStats) Downloaded() int64 {
+	if s == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&s.downloaded)
+}
+
+func (s *TransferStats) addUploaded(n int64) {
+	if s != nil {
+		atomic.AddInt64(&s.uploaded, n)
+	}
+}
+
+// countDownload returns a reader that counts the bytes read from r as downloaded
+func (s *TransferStats) countDownload(r io.Reader) io.Reader {
+	if s == nil {
+		return r
+	}
+	return &downloadCounter{reader: r, stats: s}
+}
+
+type downloadCounter struct {
+	reader io.Reader
+	stats  *TransferStats
+}
+
+func (d *downloadCounter) Read(p []byte) (int, error) {
+	n, err := d.reader.Read(p)
+	atomic.AddInt64(&d.stats.downloaded, int64(n))
+	return n, err
+}
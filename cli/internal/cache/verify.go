@@ -0,0 +1,193 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/DataDog/zstd"
+
+	"github.com/vercel/turbo/cli/internal/cacheitem"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/pkg/artifacts"
+)
+
+// ErrArtifactMissing is returned when verifying an artifact that is not in the cache
+var ErrArtifactMissing = errors.New("artifact not found")
+
+// Verifier checks that artifacts are complete and can be restored. Artifacts are
+// restored into a scratch directory, so the work tree is never written to.
+type Verifier struct {
+	cacheDir       turbopath.AbsoluteSystemPath
+	client         client
+	signerVerifier *ArtifactSignatureAuthentication
+	encryption     *artifactEncryption
+}
+
+// NewVerifier returns a Verifier for the filesystem cache described by opts and,
+// if client is not nil, for the remote cache. If opts names a remote cache
+// provider, it is checked instead of the Remote Cache API.
+func NewVerifier(opts Opts, repoRoot turbopath.AbsoluteSystemPath, client client) (*Verifier, error) {
+	v := &Verifier{
+		cacheDir: opts.resolveCacheDir(repoRoot),
+	}
+	if client != nil {
+		remote, err := remoteClient(opts, client)
+		if err != nil {
+			return nil, err
+		}
+		v.client = remote
+		v.signerVerifier = &ArtifactSignatureAuthentication{
+			teamId:  client.GetTeamID(),
+			enabled: opts.RemoteCacheOpts.Signature,
+		}
+		v.encryption = newArtifactEncryption(opts.RemoteCacheOpts.Encryption)
+	}
+	return v, nil
+}
+
+// HasRemote returns true if the Verifier can check the remote cache
+func (v *Verifier) HasRemote() bool {
+	return v.client != nil
+}
+
+// VerifyLocal checks that the filesystem cache has metadata for hash, and an
+// archive that can be read to the end and restored
+func (v *Verifier) VerifyLocal(hash string) error {
+	archivePath := v.cacheDir.UntypedJoin(hash + ".tar.zst")
+	if !archivePath.FileExists() {
+		archivePath = v.cacheDir.UntypedJoin(hash + ".tar")
+		if !archivePath.FileExists() {
+			return ErrArtifactMissing
+		}
+	}
+	meta, err := ReadCacheMetaFile(v.cacheDir.UntypedJoin(hash + "-meta.json"))
+	if err != nil {
+		return fmt.Errorf("metadata is missing or unreadable: %w", err)
+	}
+	if meta.Hash != hash {
+		return fmt.Errorf("metadata is for %v", meta.Hash)
+	}
+	archive, err := archivePath.Open()
+	if err != nil {
+		return fmt.Errorf("archive cannot be opened: %w", err)
+	}
+	defer func() { _ = archive.Close() }()
+	var reader io.Reader = archive
+	if strings.HasSuffix(archivePath.ToString(), ".zst") {
+		zr := zstd.NewReader(archive)
+		defer func() { _ = zr.Close() }()
+		reader = zr
+	}
+	if err := checkComplete(reader); err != nil {
+		return err
+	}
+	return withScratchDir(func(scratch turbopath.AbsoluteSystemPath) error {
+		cacheItem, err := cacheitem.Open(archivePath)
+		if err != nil {
+			return fmt.Errorf("archive cannot be opened: %w", err)
+		}
+		if _, err := cacheItem.Restore(scratch); err != nil {
+			_ = cacheItem.Close()
+			return fmt.Errorf("archive cannot be restored: %w", err)
+		}
+		return cacheItem.Close()
+	})
+}
+
+// VerifyRemote downloads the artifact for hash from the remote cache, checks its
+// signature if signing is enabled, decrypts it if encryption is enabled, and checks
+// that it can be restored
+func (v *Verifier) VerifyRemote(ctx context.Context, hash string) error {
+	if v.client == nil {
+		return errors.New("remote caching is not enabled")
+	}
+	artifact, err := v.client.FetchArtifact(ctx, hash)
+	if errors.Is(err, artifacts.ErrNotFound) {
+		return ErrArtifactMissing
+	} else if err != nil {
+		return err
+	}
+	defer func() { _ = artifact.Body.Close() }()
+	body, err := ioutil.ReadAll(artifacts.VerifyDigest(artifact.Body, artifact.Digest))
+	if err != nil {
+		return fmt.Errorf("download is incomplete: %w", err)
+	}
+	if v.signerVerifier.isEnabled() {
+		if artifact.Tag == "" {
+			return errors.New("artifact is missing its signature")
+		}
+		isValid, err := v.signerVerifier.validate(hash, body, artifact.Tag)
+		if err != nil {
+			return fmt.Errorf("signature cannot be verified: %w", err)
+		}
+		if !isValid {
+			return errors.New("signature does not match the artifact")
+		}
+	}
+	if v.encryption.isEnabled() {
+		body, err = v.encryption.decrypt(hash, body, artifact.KeyID)
+		if err != nil {
+			return err
+		}
+	}
+	zr := zstd.NewReader(bytes.NewReader(body))
+	defer func() { _ = zr.Close() }()
+	if err := checkComplete(zr); err != nil {
+		return err
+	}
+	return withScratchDir(func(scratch turbopath.AbsoluteSystemPath) error {
+		if _, err := restoreTar(scratch, bytes.NewReader(body)); err != nil {
+			return fmt.Errorf("archive cannot be restored: %w", err)
+		}
+		return nil
+	})
+}
+
+// tarTrailerSize is the size of the end-of-archive marker, two zeroed blocks,
+// that ends every archive turbo writes
+const tarTrailerSize = 2 * 512
+
+// trailerWriter counts the bytes written to it and keeps the last tarTrailerSize of them
+type trailerWriter struct {
+	size int64
+	tail []byte
+}
+
+func (w *trailerWriter) Write(p []byte) (int, error) {
+	w.size += int64(len(p))
+	w.tail = append(w.tail, p...)
+	if excess := len(w.tail) - tarTrailerSize; excess > 0 {
+		w.tail = append(w.tail[:0], w.tail[excess:]...)
+	}
+	return len(p), nil
+}
+
+// checkComplete reads an uncompressed tar stream to the end and checks that it finishes
+// with an end-of-archive marker. A truncated archive otherwise restores without error,
+// as a subset of its files.
+func checkComplete(reader io.Reader) error {
+	w := &trailerWriter{}
+	if _, err := io.Copy(w, reader); err != nil {
+		return fmt.Errorf("archive cannot be read: %w", err)
+	}
+	if w.size%512 != 0 || len(w.tail) < tarTrailerSize || !bytes.Equal(w.tail, make([]byte, tarTrailerSize)) {
+		return errors.New("archive is incomplete")
+	}
+	return nil
+}
+
+// withScratchDir calls fn with an empty temporary directory, which is removed afterwards
+func withScratchDir(fn func(scratch turbopath.AbsoluteSystemPath) error) error {
+	dir, err := ioutil.TempDir("", "turbo-verify")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	return fn(turbopath.AbsoluteSystemPathFromUpstream(dir))
+}
@@ -0,0 +1,112 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/pkg/artifacts"
+	"gotest.tools/v3/assert"
+)
+
+type artifactResp struct {
+	errorResp
+	body []byte
+}
+
+func (ar *artifactResp) FetchArtifact(ctx context.Context, hash string) (*artifacts.Artifact, error) {
+	if ar.body == nil {
+		return nil, artifacts.ErrNotFound
+	}
+	return &artifacts.Artifact{Body: io.NopCloser(bytes.NewReader(ar.body))}, nil
+}
+
+func (ar *artifactResp) ArtifactMetadata(ctx context.Context, hash string) (*artifacts.Metadata, error) {
+	if ar.body == nil {
+		return nil, artifacts.ErrNotFound
+	}
+	return &artifacts.Metadata{}, nil
+}
+
+func newTestVerifier(t *testing.T, opts Opts, repoRoot turbopath.AbsoluteSystemPath, client client) *Verifier {
+	t.Helper()
+	v, err := NewVerifier(opts, repoRoot, client)
+	assert.NilError(t, err, "NewVerifier")
+	return v
+}
+
+func TestVerifyLocal(t *testing.T) {
+	repoRoot := turbopath.AbsoluteSystemPath(t.TempDir())
+	src := repoRoot.UntypedJoin("my-pkg")
+	assert.NilError(t, src.MkdirAll(0775), "MkdirAll")
+	assert.NilError(t, src.UntypedJoin("out").WriteFile([]byte("output"), 0644), "WriteFile")
+
+	opts := Opts{OverrideDir: "cache"}
+	fsCache, err := newFsCache(opts, &dummyRecorder{}, repoRoot)
+	assert.NilError(t, err, "newFsCache")
+	files := []turbopath.AnchoredSystemPath{turbopath.AnchoredSystemPath("my-pkg/out")}
+	assert.NilError(t, fsCache.Put(repoRoot, "the-hash", 0, files), "Put")
+
+	v := newTestVerifier(t, opts, repoRoot, nil)
+	assert.NilError(t, v.VerifyLocal("the-hash"))
+	assert.ErrorIs(t, v.VerifyLocal("missing-hash"), ErrArtifactMissing)
+	assert.Assert(t, !v.HasRemote())
+
+	cacheDir := repoRoot.UntypedJoin("cache")
+	assert.NilError(t, WriteCacheMetaFile(cacheDir.UntypedJoin("the-hash-meta.json"), &CacheMetadata{Hash: "other-hash"}))
+	assert.ErrorContains(t, v.VerifyLocal("the-hash"), "metadata is for other-hash")
+	assert.NilError(t, WriteCacheMetaFile(cacheDir.UntypedJoin("the-hash-meta.json"), &CacheMetadata{Hash: "the-hash"}))
+
+	archive := cacheDir.UntypedJoin("the-hash.tar.zst")
+	contents, err := archive.ReadFile()
+	assert.NilError(t, err, "ReadFile")
+	assert.NilError(t, archive.WriteFile(contents[:len(contents)/2], 0644), "WriteFile")
+	assert.ErrorContains(t, v.VerifyLocal("the-hash"), "archive is incomplete")
+
+	// Nothing is ever restored into the work tree
+	entries, err := ioutil.ReadDir(src.ToString())
+	assert.NilError(t, err, "ReadDir")
+	assert.Equal(t, len(entries), 1)
+}
+
+func TestVerifyRemote(t *testing.T) {
+	repoRoot := turbopath.AbsoluteSystemPath(t.TempDir())
+	valid := makeValidTar(t).Bytes()
+
+	v := newTestVerifier(t, Opts{}, repoRoot, &artifactResp{body: valid})
+	assert.Assert(t, v.HasRemote())
+	assert.NilError(t, v.VerifyRemote(context.Background(), "the-hash"))
+
+	v = newTestVerifier(t, Opts{}, repoRoot, &artifactResp{})
+	assert.ErrorIs(t, v.VerifyRemote(context.Background(), "the-hash"), ErrArtifactMissing)
+
+	v = newTestVerifier(t, Opts{}, repoRoot, &artifactResp{body: valid[:len(valid)/2]})
+	assert.ErrorContains(t, v.VerifyRemote(context.Background(), "the-hash"), "archive is incomplete")
+
+	t.Setenv("TURBO_REMOTE_CACHE_SIGNATURE_KEY", "secret")
+	opts := Opts{}
+	opts.RemoteCacheOpts.Signature = true
+	v = newTestVerifier(t, opts, repoRoot, &artifactResp{body: valid})
+	assert.ErrorContains(t, v.VerifyRemote(context.Background(), "the-hash"), "missing its signature")
+
+	t.Setenv("TURBO_REMOTE_CACHE_ENCRYPTION_KEY", testEncryptionKey(1))
+	opts = Opts{}
+	opts.RemoteCacheOpts.Encryption = true
+	v = newTestVerifier(t, opts, repoRoot, &artifactResp{body: valid})
+	assert.ErrorContains(t, v.VerifyRemote(context.Background(), "the-hash"), "cannot be decrypted")
+	encrypted, _, err := v.encryption.encrypt("the-hash", valid)
+	assert.NilError(t, err, "encrypt")
+	v = newTestVerifier(t, opts, repoRoot, &artifactResp{body: encrypted})
+	assert.NilError(t, v.VerifyRemote(context.Background(), "the-hash"))
+
+	v = newTestVerifier(t, Opts{}, repoRoot, &errorResp{err: errors.New("network down")})
+	assert.ErrorContains(t, v.VerifyRemote(context.Background(), "the-hash"), "network down")
+
+	entries, err := ioutil.ReadDir(repoRoot.ToString())
+	assert.NilError(t, err, "ReadDir")
+	assert.Equal(t, len(entries), 0)
+}
@@ -21,6 +21,10 @@ var (
 	errUnsupportedFileType  = errors.New("attempted to restore unsupported file type")
 )
 
+// ErrAbsoluteLinkTarget is returned when caching or restoring a symlink to an
+// absolute path, which would point outside of the repository wherever it is restored.
+var ErrAbsoluteLinkTarget = errors.New("symlink target is an absolute path")
+
 // CacheItem is a `tar` utility with a little bit extra.
 type CacheItem struct {
 	// Path is the location on disk for the CacheItem.
@@ -34,6 +38,8 @@ type CacheItem struct {
 	fileBuffer *bufio.Writer
 	handle     *os.File
 	compressed bool
+	// tempPath is where a CacheItem being created is written until it is closed
+	tempPath turbopath.AbsoluteSystemPath
 }
 
 // Close any open pipes
@@ -57,14 +63,46 @@ func (ci *CacheItem) Close() error {
 	}
 
 	if ci.handle != nil {
+		// A complete archive reaches the disk before it is moved into place
+		if ci.tempPath != "" {
+			if err := ci.handle.Sync(); err != nil {
+				return err
+			}
+		}
 		if err := ci.handle.Close(); err != nil {
 			return err
 		}
 	}
 
+	if ci.tempPath != "" {
+		// The archive is complete, move it into place
+		if err := os.Chmod(ci.tempPath.ToString(), 0644); err != nil {
+			return err
+		}
+		if err := ci.tempPath.Rename(ci.Path); err != nil {
+			return err
+		}
+		ci.tempPath = ""
+	}
+
 	return nil
 }
 
+// Discard abandons a CacheItem being created, leaving nothing at its Path
+func (ci *CacheItem) Discard() error {
+	if ci.tempPath == "" {
+		return ci.Close()
+	}
+	tempPath := ci.tempPath
+	// Close without moving the archive into place
+	ci.tempPath = ""
+	closeErr := ci.Close()
+	if err := tempPath.Remove(); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return closeErr
+}
+
 // GetSha returns the SHA-512 hash for the CacheItem.
 func (ci *CacheItem) GetSha() ([]byte, error) {
 	sha := sha512.New()
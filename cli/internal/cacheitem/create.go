@@ -3,6 +3,7 @@ package cacheitem
 import (
 	"archive/tar"
 	"bufio"
+	"fmt"
 	"io"
 	"os"
 	"strings"
@@ -11,13 +12,16 @@ import (
 	"github.com/DataDog/zstd"
 
 	"github.com/moby/sys/sequential"
+	"github.com/vercel/turbo/cli/internal/fs"
 	"github.com/vercel/turbo/cli/internal/tarpatch"
 	"github.com/vercel/turbo/cli/internal/turbopath"
 )
 
-// Create makes a new CacheItem at the specified path.
+// Create makes a new CacheItem at the specified path. It is written to a temporary
+// file that Close renames to path, so that an interrupted write never leaves a
+// truncated archive at path. Discard removes it instead.
 func Create(path turbopath.AbsoluteSystemPath) (*CacheItem, error) {
-	handle, err := path.OpenFile(os.O_WRONLY|os.O_CREATE|os.O_TRUNC|os.O_APPEND, 0644)
+	handle, err := fs.CreateTempSibling(path)
 	if err != nil {
 		return nil, err
 	}
@@ -25,6 +29,7 @@ func Create(path turbopath.AbsoluteSystemPath) (*CacheItem, error) {
 	cacheItem := &CacheItem{
 		Path:       path,
 		handle:     handle,
+		tempPath:   turbopath.AbsoluteSystemPathFromUpstream(handle.Name()),
 		compressed: strings.HasSuffix(path.ToString(), ".zst"),
 	}
 
@@ -69,6 +74,9 @@ func (ci *CacheItem) AddFile(fsAnchor turbopath.AbsoluteSystemPath, filePath tur
 		if readlinkErr != nil {
 			return readlinkErr
 		}
+		if IsAbsoluteLinkTarget(linkTarget) {
+			return fmt.Errorf("%v -> %v: %w", filePath, linkTarget, ErrAbsoluteLinkTarget)
+		}
 		link = linkTarget
 	}
 
@@ -141,6 +141,17 @@ func TestCreate(t *testing.T) {
 			},
 			wantErr: errUnsupportedFileType,
 		},
+		{
+			name: "absolute symlink target errors",
+			files: []createFileDefinition{
+				{
+					Path:     turbopath.AnchoredSystemPath("escape"),
+					Linkname: "/etc/passwd",
+					FileMode: 0 | os.ModeSymlink | 0777,
+				},
+			},
+			wantErr: ErrAbsoluteLinkTarget,
+		},
 	}
 	for _, tt := range tests {
 		getTestFunc := func(compressed bool) func(t *testing.T) {
@@ -11,6 +11,7 @@ import (
 	"github.com/DataDog/zstd"
 
 	"github.com/moby/sys/sequential"
+	"github.com/vercel/turbo/cli/internal/fs"
 	"github.com/vercel/turbo/cli/internal/turbopath"
 )
 
@@ -28,8 +29,32 @@ func Open(path turbopath.AbsoluteSystemPath) (*CacheItem, error) {
 	}, nil
 }
 
-// Restore extracts a cache to a specified disk location.
+// Restore extracts a cache to a specified disk location. The archive is extracted
+// to a staging directory in anchor's .turbo folder, and its files are moved into place once all of
+// them are complete, so an archive that can't be restored changes nothing in anchor.
 func (ci *CacheItem) Restore(anchor turbopath.AbsoluteSystemPath) ([]turbopath.AnchoredSystemPath, error) {
+	restorePointErr := anchor.MkdirAll(0755)
+	if restorePointErr != nil {
+		return nil, restorePointErr
+	}
+	staging, err := fs.CreateStagingDir(anchor)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = staging.RemoveAll() }()
+
+	restored, err := ci.extract(staging)
+	if err != nil {
+		return nil, err
+	}
+	if err := fs.MoveStaged(staging, anchor); err != nil {
+		return nil, err
+	}
+	return restored, nil
+}
+
+// extract extracts a cache to anchor, which must exist.
+func (ci *CacheItem) extract(anchor turbopath.AbsoluteSystemPath) ([]turbopath.AnchoredSystemPath, error) {
 	var tr *tar.Reader
 	var closeError error
 
@@ -53,11 +78,6 @@ func (ci *CacheItem) Restore(anchor turbopath.AbsoluteSystemPath) ([]turbopath.A
 
 	restored := make([]turbopath.AnchoredSystemPath, 0)
 
-	restorePointErr := anchor.MkdirAll(0755)
-	if restorePointErr != nil {
-		return nil, restorePointErr
-	}
-
 	// We're going to make the following two assumptions here for "fast" path restoration:
 	// - All directories are enumerated in the `tar`.
 	// - The contents of the tar are enumerated depth-first.
@@ -198,3 +218,26 @@ func checkName(name string) (bool, bool) {
 
 	return wellFormed, windowsSafe
 }
+
+// Walk calls fn with the header of each entry in the archive, in order, and a
+// reader for the contents of regular files. It doesn't write anything to disk.
+func (ci *CacheItem) Walk(fn func(header *tar.Header, contents io.Reader) error) error {
+	var reader io.Reader = ci.handle
+	if ci.compressed {
+		zr := zstd.NewReader(ci.handle)
+		defer func() { _ = zr.Close() }()
+		reader = zr
+	}
+	tr := tar.NewReader(reader)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := fn(header, tr); err != nil {
+			return err
+		}
+	}
+}
@@ -28,6 +28,7 @@ func restoreRegular(dirCache *cachedDirTree, anchor turbopath.AbsoluteSystemPath
 	if f, err := processedName.RestoreAnchor(anchor).OpenFile(os.O_WRONLY|os.O_TRUNC|os.O_CREATE, os.FileMode(header.Mode)); err != nil {
 		return "", err
 	} else if _, err := io.Copy(f, reader); err != nil {
+		_ = f.Close()
 		return "", err
 	} else if err := f.Close(); err != nil {
 		return "", err
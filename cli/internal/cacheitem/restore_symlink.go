@@ -5,6 +5,7 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/pyr-sh/dag"
 	"github.com/vercel/turbo/cli/internal/turbopath"
@@ -37,6 +38,11 @@ func restoreSymlinkMissingTarget(dirCache *cachedDirTree, anchor turbopath.Absol
 }
 
 func actuallyRestoreSymlink(dirCache *cachedDirTree, anchor turbopath.AbsoluteSystemPath, processedName turbopath.AnchoredSystemPath, header *tar.Header) (turbopath.AnchoredSystemPath, error) {
+	// Never restore a link to somewhere outside of the restore path by rule.
+	if IsAbsoluteLinkTarget(header.Linkname) {
+		return "", ErrAbsoluteLinkTarget
+	}
+
 	// We need to traverse `processedName` from base to root split at
 	// `os.Separator` to make sure we don't end up following a symlink
 	// outside of the restore path.
@@ -128,6 +134,20 @@ func topologicallyRestoreSymlinks(dirCache *cachedDirTree, anchor turbopath.Abso
 	return restored, nil
 }
 
+// IsAbsoluteLinkTarget reports whether linkname is absolute on any platform that
+// the cache item may be restored on, not just the current one: a rooted Unix
+// path, a rooted or UNC Windows path, or a Windows path with a drive letter.
+func IsAbsoluteLinkTarget(linkname string) bool {
+	if filepath.IsAbs(linkname) || strings.HasPrefix(linkname, "/") || strings.HasPrefix(linkname, "\\") {
+		return true
+	}
+	if len(linkname) < 2 || linkname[1] != ':' {
+		return false
+	}
+	drive := linkname[0]
+	return ('a' <= drive && drive <= 'z') || ('A' <= drive && drive <= 'Z')
+}
+
 // canonicalizeLinkname determines (lexically) what the resolved path on the
 // system will be when linkname is restored verbatim.
 func canonicalizeLinkname(anchor turbopath.AbsoluteSystemPath, processedName turbopath.AnchoredSystemPath, linkname string) string {
@@ -736,31 +736,6 @@ func TestOpen(t *testing.T) {
 					Body: "this shouldn't work",
 				},
 			},
-			wantFiles: wantFiles{
-				unix: []restoreFile{
-					{
-						Name:     "folder-not-file",
-						FileMode: 0 | os.ModeDir | 0755,
-					},
-					{
-						Name:     "folder-not-file/subfile",
-						FileMode: 0755,
-					},
-				},
-				windows: []restoreFile{
-					{
-						Name:     "folder-not-file",
-						FileMode: 0 | os.ModeDir | 0777,
-					},
-					{
-						Name:     "folder-not-file/subfile",
-						FileMode: 0666,
-					},
-				},
-			},
-			wantOutput: wantOutput{
-				unix: turbopath.AnchoredUnixPathArray{"folder-not-file", "folder-not-file/subfile"}.ToSystemPathArray(),
-			},
 			wantErr: wantErr{
 				unix:    syscall.EISDIR,
 				windows: syscall.EISDIR,
@@ -832,12 +807,6 @@ func TestOpen(t *testing.T) {
 					},
 				},
 			},
-			wantFiles: wantFiles{
-				unix: []restoreFile{},
-			},
-			wantOutput: wantOutput{
-				unix: []turbopath.AnchoredSystemPath{},
-			},
 			wantErr: wantErr{
 				unix:    errCycleDetected,
 				windows: errCycleDetected,
@@ -927,25 +896,6 @@ func TestOpen(t *testing.T) {
 					Body: "file",
 				},
 			},
-			wantFiles: wantFiles{
-				unix: []restoreFile{
-					{
-						Name:     "escape",
-						Linkname: "../",
-						FileMode: 0 | os.ModeSymlink | 0777,
-					},
-				},
-				windows: []restoreFile{
-					{
-						Name:     "escape",
-						Linkname: "..\\",
-						FileMode: 0 | os.ModeSymlink | 0666,
-					},
-				},
-			},
-			wantOutput: wantOutput{
-				unix: turbopath.AnchoredUnixPathArray{"escape"}.ToSystemPathArray(),
-			},
 			wantErr: wantErr{
 				unix:    errTraversal,
 				windows: errTraversal,
@@ -979,12 +929,6 @@ func TestOpen(t *testing.T) {
 				},
 			},
 			wantErr: wantErr{unix: errTraversal, windows: errTraversal},
-			wantOutput: wantOutput{
-				unix: turbopath.AnchoredUnixPathArray{
-					"up",
-					"link",
-				}.ToSystemPathArray(),
-			},
 		},
 		{
 			name: "Double indirection: folder",
@@ -1014,12 +958,6 @@ func TestOpen(t *testing.T) {
 				},
 			},
 			wantErr: wantErr{unix: errTraversal, windows: errTraversal},
-			wantOutput: wantOutput{
-				unix: turbopath.AnchoredUnixPathArray{
-					"up",
-					"link",
-				}.ToSystemPathArray(),
-			},
 		},
 		{
 			name: "name traversal",
@@ -1033,12 +971,6 @@ func TestOpen(t *testing.T) {
 					Body: "file",
 				},
 			},
-			wantFiles: wantFiles{
-				unix: []restoreFile{},
-			},
-			wantOutput: wantOutput{
-				unix: []turbopath.AnchoredSystemPath{},
-			},
 			wantErr: wantErr{
 				unix:    errNameMalformed,
 				windows: errNameMalformed,
@@ -1066,14 +998,30 @@ func TestOpen(t *testing.T) {
 				windows: []restoreFile{},
 			},
 			wantOutput: wantOutput{
-				unix:    turbopath.AnchoredUnixPathArray{"back\\slash\\file"}.ToSystemPathArray(),
-				windows: turbopath.AnchoredUnixPathArray{}.ToSystemPathArray(),
+				unix: turbopath.AnchoredUnixPathArray{"back\\slash\\file"}.ToSystemPathArray(),
 			},
 			wantErr: wantErr{
 				unix:    nil,
 				windows: errNameWindowsUnsafe,
 			},
 		},
+		{
+			name: "absolute symlink target",
+			tarFiles: []tarFile{
+				{
+					Header: &tar.Header{
+						Name:     "escape",
+						Linkname: "/etc/passwd",
+						Typeflag: tar.TypeSymlink,
+						Mode:     0777,
+					},
+				},
+			},
+			wantErr: wantErr{
+				unix:    ErrAbsoluteLinkTarget,
+				windows: ErrAbsoluteLinkTarget,
+			},
+		},
 		{
 			name: "fifo (and others) unsupported",
 			tarFiles: []tarFile{
@@ -1084,12 +1032,6 @@ func TestOpen(t *testing.T) {
 					},
 				},
 			},
-			wantFiles: wantFiles{
-				unix: []restoreFile{},
-			},
-			wantOutput: wantOutput{
-				unix: []turbopath.AnchoredSystemPath{},
-			},
 			wantErr: wantErr{
 				unix:    errUnsupportedFileType,
 				windows: errUnsupportedFileType,
@@ -1121,9 +1063,20 @@ func TestOpen(t *testing.T) {
 					if !errors.Is(restoreErr, desiredErr) {
 						t.Errorf("wanted err: %v, got err: %v", tt.wantErr, restoreErr)
 					}
-				} else {
-					assert.NilError(t, restoreErr, "Restore")
+					// An archive that can't be restored leaves nothing behind but the
+					// empty .turbo folder that it was staged in
+					assert.Equal(t, len(restoreOutput), 0)
+					entries, err := os.ReadDir(anchor.ToString())
+					assert.NilError(t, err, "ReadDir")
+					assert.Equal(t, len(entries), 1)
+					assert.Equal(t, entries[0].Name(), ".turbo")
+					staged, err := os.ReadDir(anchor.UntypedJoin(".turbo").ToString())
+					assert.NilError(t, err, "ReadDir")
+					assert.Equal(t, len(staged), 0)
+					assert.NilError(t, cacheItem.Close(), "Close")
+					return
 				}
+				assert.NilError(t, restoreErr, "Restore")
 
 				outputComparison := tt.wantOutput.unix
 				if runtime.GOOS == "windows" && tt.wantOutput.windows != nil {
@@ -1374,6 +1327,25 @@ func Test_canonicalizeLinkname(t *testing.T) {
 	}
 }
 
+func TestIsAbsoluteLinkTarget(t *testing.T) {
+	tests := []struct {
+		linkname string
+		want     bool
+	}{
+		{linkname: "target", want: false},
+		{linkname: "../sibling/target", want: false},
+		{linkname: "..\\sibling\\target", want: false},
+		{linkname: "c", want: false},
+		{linkname: "/etc/passwd", want: true},
+		{linkname: "\\\\server\\share", want: true},
+		{linkname: "C:\\Windows", want: true},
+		{linkname: "c:relative", want: true},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, IsAbsoluteLinkTarget(tt.linkname), tt.want, tt.linkname)
+	}
+}
+
 func Test_canonicalizeName(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -19,6 +19,7 @@ import (
 	"github.com/hashicorp/go-retryablehttp"
 	"github.com/spf13/pflag"
 	"github.com/vercel/turbo/cli/internal/util"
+	"github.com/vercel/turbo/cli/pkg/artifacts"
 )
 
 type ApiClient struct {
@@ -35,15 +36,28 @@ type ApiClient struct {
 	teamSlug   string
 	// Whether or not to send preflight requests before uploads
 	usePreflight bool
+
+	// The difference between the server's clock and ours, in nanoseconds, as of
+	// the most recent response that carried a Date header. Must be used via atomic package.
+	clockSkew int64
+	// Set to 1 once clockSkew has been measured. Must be used via atomic package.
+	clockSkewMeasured uint32
 }
 
 // ErrTooManyFailures is returned from remote cache API methods after `maxRemoteFailCount` errors have occurred
-var ErrTooManyFailures = errors.New("skipping HTTP Request, too many failures have occurred")
+var ErrTooManyFailures = util.ErrTooManyFailures
+
+// _defaultTimeout is how long a request to the API may take, including reading the response
+const _defaultTimeout = 20 * time.Second
 
 // _maxRemoteFailCount is the number of failed requests before we stop trying to upload/download
 // artifacts to the remote cache
 const _maxRemoteFailCount = uint64(3)
 
+// _maxClockSkew is how far our clock may drift from the server's before we warn about it.
+// Beyond this, signed tokens are likely to be rejected as expired or not yet valid.
+const _maxClockSkew = 5 * time.Minute
+
 // SetToken updates the ApiClient's Token
 func (c *ApiClient) SetToken(token string) {
 	c.token = token
@@ -60,11 +74,20 @@ type RemoteConfig struct {
 // Opts holds values for configuring the behavior of the API client
 type Opts struct {
 	UsePreflight bool
+	// RateLimit is the most requests per second to send, or zero for no limit
+	RateLimit float64
+	// RateLimitBurst is how many requests can be sent at once, within RateLimit
+	RateLimitBurst int
+	// Timeout is how long each request may take, or zero for no limit
+	Timeout time.Duration
 }
 
 // AddFlags adds flags specific to the api client to the given flagset
 func AddFlags(opts *Opts, flags *pflag.FlagSet) {
 	flags.BoolVar(&opts.UsePreflight, "preflight", false, "When enabled, turbo will precede HTTP requests with an OPTIONS request for authorization")
+	flags.Float64Var(&opts.RateLimit, "remote-cache-rate-limit", 0, "The most requests per second to send to the Remote Cache. Further requests are queued. Defaults to no limit")
+	flags.IntVar(&opts.RateLimitBurst, "remote-cache-rate-limit-burst", 0, "How many requests can be sent to the Remote Cache at once with --remote-cache-rate-limit. Defaults to the rate limit")
+	flags.DurationVar(&opts.Timeout, "remote-cache-timeout", _defaultTimeout, "How long each request to the Remote Cache may take, such as 30s, before it is retried. Use 0 for no limit. Can also be set with TURBO_REMOTE_CACHE_TIMEOUT")
 }
 
 // New creates a new ApiClient
@@ -74,7 +97,7 @@ func NewClient(remoteConfig RemoteConfig, logger hclog.Logger, turboVersion stri
 		turboVersion: turboVersion,
 		HttpClient: &retryablehttp.Client{
 			HTTPClient: &http.Client{
-				Timeout: time.Duration(20 * time.Second),
+				Timeout: opts.Timeout,
 			},
 			RetryWaitMin: 2 * time.Second,
 			RetryWaitMax: 10 * time.Second,
@@ -88,6 +111,17 @@ func NewClient(remoteConfig RemoteConfig, logger hclog.Logger, turboVersion stri
 		usePreflight: opts.UsePreflight,
 	}
 	client.HttpClient.CheckRetry = client.checkRetry
+	client.HttpClient.ResponseLogHook = func(_ retryablehttp.Logger, resp *http.Response) {
+		client.recordServerTime(resp, time.Now())
+	}
+	if limiter := newRateLimiter(opts.RateLimit, opts.RateLimitBurst); limiter != nil {
+		// The hook runs before every attempt, including retries. Waiting here, rather
+		// than in the transport, keeps time spent queued out of the request timeout.
+		// If the request's context is done, the request fails as soon as it's sent.
+		client.HttpClient.RequestLogHook = func(_ retryablehttp.Logger, req *http.Request, _ int) {
+			_ = limiter.wait(req.Context())
+		}
+	}
 	return client
 }
 
@@ -111,6 +145,51 @@ func (c *ApiClient) GetTeamID() string {
 	return c.teamID
 }
 
+// recordServerTime measures our clock against the Date header of a response
+// that was received at receivedAt
+func (c *ApiClient) recordServerTime(resp *http.Response, receivedAt time.Time) {
+	date := resp.Header.Get("Date")
+	if date == "" {
+		return
+	}
+	serverTime, err := http.ParseTime(date)
+	if err != nil {
+		return
+	}
+	// The Date header is truncated to the second, so on average the server's
+	// clock was half a second later than it says
+	skew := serverTime.Add(500 * time.Millisecond).Sub(receivedAt)
+	atomic.StoreInt64(&c.clockSkew, int64(skew))
+	atomic.StoreUint32(&c.clockSkewMeasured, 1)
+}
+
+// ClockSkew returns how far the server's clock is ahead of ours, and whether it
+// has been measured yet. A negative skew means that our clock is ahead.
+func (c *ApiClient) ClockSkew() (time.Duration, bool) {
+	if atomic.LoadUint32(&c.clockSkewMeasured) == 0 {
+		return 0, false
+	}
+	return time.Duration(atomic.LoadInt64(&c.clockSkew)), true
+}
+
+// ClockSkewWarning returns a description of the difference between our clock
+// and the server's, if it is large enough to cause problems, or "" otherwise
+func (c *ApiClient) ClockSkewWarning() string {
+	skew, ok := c.ClockSkew()
+	if !ok {
+		return ""
+	}
+	direction := "behind"
+	if skew < 0 {
+		direction = "ahead of"
+		skew = -skew
+	}
+	if skew < _maxClockSkew {
+		return ""
+	}
+	return fmt.Sprintf("the system clock is %v %v the Remote Cache server. Authentication may fail with expired tokens until the clock is synchronized", skew.Round(time.Second), direction)
+}
+
 func (c *ApiClient) retryCachePolicy(resp *http.Response, err error) (bool, error) {
 	if err != nil {
 		if errors.As(err, &x509.UnknownAuthorityError{}) {
@@ -250,116 +329,147 @@ func (c *ApiClient) handle403(body io.Reader) error {
 	return disabledErr
 }
 
-func (c *ApiClient) PutArtifact(hash string, artifactBody []byte, duration int, tag string) error {
+// PutFailureLog uploads the output of a task that failed, and so produced no artifact,
+// so that the failure can be investigated later. The log is stored alongside the
+// artifacts under the task's hash, tagged with the run that produced it.
+func (c *ApiClient) PutFailureLog(hash string, runID string, taskID string, output []byte) error {
 	if err := c.okToRequest(); err != nil {
 		return err
 	}
 	params := url.Values{}
 	c.addTeamParam(&params)
-	// only add a ? if it's actually needed (makes logging cleaner)
 	encoded := params.Encode()
 	if encoded != "" {
 		encoded = "?" + encoded
 	}
 
-	requestURL := c.makeUrl("/v8/artifacts/" + hash + encoded)
+	requestURL := c.makeUrl("/v8/artifacts/" + hash + "/logs" + encoded)
 	allowAuth := true
 	if c.usePreflight {
-		resp, latestRequestURL, err := c.doPreflight(requestURL, http.MethodPut, "Content-Type, x-artifact-duration, Authorization, User-Agent, x-artifact-tag")
+		resp, latestRequestURL, err := c.doPreflight(requestURL, http.MethodPut, "Content-Type, x-artifact-run-id, x-artifact-task-id, Authorization, User-Agent")
 		if err != nil {
-			return fmt.Errorf("pre-flight request failed before trying to store in HTTP cache: %w", err)
+			return fmt.Errorf("pre-flight request failed before trying to upload logs: %w", err)
 		}
 		requestURL = latestRequestURL
 		headers := resp.Header.Get("Access-Control-Allow-Headers")
 		allowAuth = strings.Contains(strings.ToLower(headers), strings.ToLower("Authorization"))
 	}
 
-	req, err := retryablehttp.NewRequest(http.MethodPut, requestURL, artifactBody)
-	req.Header.Set("Content-Type", "application/octet-stream")
-	req.Header.Set("x-artifact-duration", fmt.Sprintf("%v", duration))
+	req, err := retryablehttp.NewRequest(http.MethodPut, requestURL, output)
+	if err != nil {
+		return fmt.Errorf("invalid cache URL: %w", err)
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	req.Header.Set("x-artifact-run-id", runID)
+	req.Header.Set("x-artifact-task-id", taskID)
 	if allowAuth {
 		req.Header.Set("Authorization", "Bearer "+c.token)
 	}
 	req.Header.Set("User-Agent", c.UserAgent())
-	if tag != "" {
-		req.Header.Set("x-artifact-tag", tag)
-	}
-	if err != nil {
-		return fmt.Errorf("[WARNING] Invalid cache URL: %w", err)
-	}
 
 	resp, err := c.HttpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to store files in HTTP cache: %w", err)
+		return fmt.Errorf("failed to upload logs: %w", err)
 	}
 	defer func() { _ = resp.Body.Close() }()
 	if resp.StatusCode == http.StatusForbidden {
 		return c.handle403(resp.Body)
 	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("failed to upload logs: %v", resp.Status)
+	}
 	return nil
 }
 
-// FetchArtifact attempts to retrieve the build artifact with the given hash from the
-// Remote Caching server
-func (c *ApiClient) FetchArtifact(hash string) (*http.Response, error) {
-	return c.getArtifact(hash, http.MethodGet)
+// artifactClient returns a client for the artifact API that uses the credentials,
+// team, and retry policy of this client
+func (c *ApiClient) artifactClient() *artifacts.Client {
+	return artifacts.New(artifacts.Config{
+		BaseURL:      c.baseUrl,
+		Token:        c.token,
+		TeamID:       c.teamID,
+		TeamSlug:     c.teamSlug,
+		UserAgent:    c.UserAgent(),
+		UsePreflight: c.usePreflight,
+		HTTPClient:   c.HttpClient.StandardClient(),
+	})
+}
+
+// artifactError converts a 403 from the artifact API into a CacheDisabledError. Other
+// authentication failures note any clock skew, since a skewed clock makes valid
+// tokens appear expired.
+func (c *ApiClient) artifactError(err error) error {
+	var apiErr *artifacts.APIError
+	if !errors.As(err, &apiErr) {
+		return err
+	}
+	if apiErr.StatusCode == http.StatusForbidden {
+		disabledErr, cacheDisabledErr := (&apiError{Code: apiErr.Code, Message: apiErr.Message}).cacheDisabled()
+		if cacheDisabledErr == nil {
+			return disabledErr
+		}
+		err = cacheDisabledErr
+	}
+	if apiErr.StatusCode == http.StatusForbidden || apiErr.StatusCode == http.StatusUnauthorized {
+		if warning := c.ClockSkewWarning(); warning != "" {
+			return fmt.Errorf("%w (%v)", err, warning)
+		}
+	}
+	return err
 }
 
-// ArtifactExists attempts to determine if the build artifact with the given hash
-// exists in the Remote Caching server
-func (c *ApiClient) ArtifactExists(hash string) (*http.Response, error) {
-	return c.getArtifact(hash, http.MethodHead)
+// PutArtifact uploads an artifact to the Remote Caching server
+func (c *ApiClient) PutArtifact(ctx context.Context, hash string, body io.Reader, metadata artifacts.Metadata) error {
+	if err := c.okToRequest(); err != nil {
+		return err
+	}
+	if err := c.artifactClient().PutArtifact(ctx, hash, body, metadata); err != nil {
+		return fmt.Errorf("failed to store files in HTTP cache: %w", c.artifactError(err))
+	}
+	return nil
 }
 
 // FetchArtifact attempts to retrieve the build artifact with the given hash from the
 // Remote Caching server
-func (c *ApiClient) getArtifact(hash string, httpMethod string) (*http.Response, error) {
-
-	if httpMethod != http.MethodHead && httpMethod != http.MethodGet {
-		return nil, fmt.Errorf("invalid httpMethod %v, expected GET or HEAD", httpMethod)
-	}
-
+func (c *ApiClient) FetchArtifact(ctx context.Context, hash string) (*artifacts.Artifact, error) {
 	if err := c.okToRequest(); err != nil {
 		return nil, err
 	}
-	params := url.Values{}
-	c.addTeamParam(&params)
-	// only add a ? if it's actually needed (makes logging cleaner)
-	encoded := params.Encode()
-	if encoded != "" {
-		encoded = "?" + encoded
-	}
-
-	requestURL := c.makeUrl("/v8/artifacts/" + hash + encoded)
-	allowAuth := true
-	if c.usePreflight {
-		resp, latestRequestURL, err := c.doPreflight(requestURL, http.MethodGet, "Authorization, User-Agent")
-		if err != nil {
-			return nil, fmt.Errorf("pre-flight request failed before trying to fetch files in HTTP cache: %w", err)
-		}
-		requestURL = latestRequestURL
-		headers := resp.Header.Get("Access-Control-Allow-Headers")
-		allowAuth = strings.Contains(strings.ToLower(headers), strings.ToLower("Authorization"))
+	artifact, err := c.artifactClient().FetchArtifact(ctx, hash)
+	if errors.Is(err, artifacts.ErrNotFound) {
+		return nil, err
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to fetch artifact: %w", c.artifactError(err))
 	}
+	return artifact, nil
+}
 
-	req, err := retryablehttp.NewRequest(httpMethod, requestURL, nil)
-	if allowAuth {
-		req.Header.Set("Authorization", "Bearer "+c.token)
+// ArtifactExists attempts to determine if the build artifact with the given hash
+// exists in the Remote Caching server
+func (c *ApiClient) ArtifactExists(ctx context.Context, hash string) (bool, error) {
+	if err := c.okToRequest(); err != nil {
+		return false, err
 	}
-	req.Header.Set("User-Agent", c.UserAgent())
+	exists, err := c.artifactClient().ArtifactExists(ctx, hash)
 	if err != nil {
-		return nil, fmt.Errorf("invalid cache URL: %w", err)
+		return false, fmt.Errorf("failed to check for artifact: %w", c.artifactError(err))
 	}
+	return exists, nil
+}
 
-	resp, err := c.HttpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch artifact: %v", err)
-	} else if resp.StatusCode == http.StatusForbidden {
-		err = c.handle403(resp.Body)
-		_ = resp.Body.Close()
+// ArtifactMetadata returns the metadata of the build artifact with the given hash,
+// without downloading it
+func (c *ApiClient) ArtifactMetadata(ctx context.Context, hash string) (*artifacts.Metadata, error) {
+	if err := c.okToRequest(); err != nil {
+		return nil, err
+	}
+	metadata, err := c.artifactClient().ArtifactMetadata(ctx, hash)
+	if errors.Is(err, artifacts.ErrNotFound) {
 		return nil, err
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to check for artifact: %w", c.artifactError(err))
 	}
-	return resp, nil
+	return metadata, nil
 }
 
 func (c *ApiClient) RecordAnalyticsEvents(events []map[string]interface{}) error {
@@ -568,6 +678,17 @@ func (c *ApiClient) GetUser() (*UserResponse, error) {
 }
 
 // statusResponse is the server response from /artifacts/status
+// StatusError is returned by GetCachingStatus when the server responds with a status
+// other than 200, such as 401 for a token that isn't valid
+type StatusError struct {
+	StatusCode int
+	Message    string
+}
+
+func (se *StatusError) Error() string {
+	return fmt.Sprintf("failed to get caching status (%v): %s", se.StatusCode, se.Message)
+}
+
 type statusResponse struct {
 	Status string `json:"status"`
 }
@@ -599,7 +720,7 @@ func (c *ApiClient) GetCachingStatus() (util.CachingStatus, error) {
 		} else {
 			responseText = string(b)
 		}
-		return util.CachingStatusDisabled, fmt.Errorf("failed to get caching status (%v): %s", resp.StatusCode, responseText)
+		return util.CachingStatusDisabled, &StatusError{StatusCode: resp.StatusCode, Message: responseText}
 	}
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
@@ -2,17 +2,21 @@ package client
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/hashicorp/go-hclog"
 	"github.com/vercel/turbo/cli/internal/util"
+	"github.com/vercel/turbo/cli/pkg/artifacts"
 )
 
 func Test_sendToServer(t *testing.T) {
@@ -94,7 +98,7 @@ func Test_PutArtifact(t *testing.T) {
 	expectedArtifactBody := []byte("My string artifact")
 
 	// Test Put Artifact
-	apiClient.PutArtifact("hash", expectedArtifactBody, 500, "")
+	apiClient.PutArtifact(context.Background(), "hash", bytes.NewReader(expectedArtifactBody), artifacts.Metadata{Duration: 500})
 	testBody := <-ch
 	if !bytes.Equal(expectedArtifactBody, testBody) {
 		t.Errorf("Handler read '%v', wants '%v'", testBody, expectedArtifactBody)
@@ -119,7 +123,7 @@ func Test_PutWhenCachingDisabled(t *testing.T) {
 	apiClient := NewClient(remoteConfig, hclog.Default(), "v1", Opts{})
 	expectedArtifactBody := []byte("My string artifact")
 	// Test Put Artifact
-	err := apiClient.PutArtifact("hash", expectedArtifactBody, 500, "")
+	err := apiClient.PutArtifact(context.Background(), "hash", bytes.NewReader(expectedArtifactBody), artifacts.Metadata{Duration: 500})
 	cd := &util.CacheDisabledError{}
 	if !errors.As(err, &cd) {
 		t.Errorf("expected cache disabled error, got %v", err)
@@ -145,7 +149,7 @@ func Test_FetchWhenCachingDisabled(t *testing.T) {
 	}
 	apiClient := NewClient(remoteConfig, hclog.Default(), "v1", Opts{})
 	// Test Put Artifact
-	resp, err := apiClient.FetchArtifact("hash")
+	resp, err := apiClient.FetchArtifact(context.Background(), "hash")
 	cd := &util.CacheDisabledError{}
 	if !errors.As(err, &cd) {
 		t.Errorf("expected cache disabled error, got %v", err)
@@ -157,3 +161,112 @@ func Test_FetchWhenCachingDisabled(t *testing.T) {
 		t.Errorf("response got %v, want <nil>", resp)
 	}
 }
+
+func Test_ClockSkew(t *testing.T) {
+	serverTime := time.Now().Add(-time.Hour)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		defer func() { _ = req.Body.Close() }()
+		w.Header().Set("Date", serverTime.UTC().Format(http.TimeFormat))
+		w.WriteHeader(401)
+		_, _ = w.Write([]byte("{\"error\": {\"code\": \"forbidden\",\"message\":\"token expired\"}}"))
+	}))
+	defer ts.Close()
+
+	remoteConfig := RemoteConfig{
+		TeamSlug: "my-team-slug",
+		APIURL:   ts.URL,
+		Token:    "my-token",
+	}
+	apiClient := NewClient(remoteConfig, hclog.Default(), "v1", Opts{})
+	if _, ok := apiClient.ClockSkew(); ok {
+		t.Error("expected clock skew to be unmeasured before any requests")
+	}
+	if warning := apiClient.ClockSkewWarning(); warning != "" {
+		t.Errorf("expected no warning before any requests, got %v", warning)
+	}
+
+	_, err := apiClient.FetchArtifact(context.Background(), "hash")
+	if err == nil || !strings.Contains(err.Error(), "the system clock is 1h0m0s ahead of the Remote Cache server") {
+		t.Errorf("expected error to mention clock skew, got %v", err)
+	}
+	skew, ok := apiClient.ClockSkew()
+	if !ok {
+		t.Fatal("expected clock skew to be measured")
+	}
+	if skew > -59*time.Minute || skew < -61*time.Minute {
+		t.Errorf("clock skew: expected about -1h, got %v", skew)
+	}
+}
+
+func Test_ClockSkewWithinTolerance(t *testing.T) {
+	apiClient := NewClient(RemoteConfig{}, hclog.Default(), "v1", Opts{})
+	now := time.Now()
+	resp := &http.Response{Header: http.Header{}}
+	resp.Header.Set("Date", now.Add(time.Minute).UTC().Format(http.TimeFormat))
+	apiClient.recordServerTime(resp, now)
+	if _, ok := apiClient.ClockSkew(); !ok {
+		t.Error("expected clock skew to be measured")
+	}
+	if warning := apiClient.ClockSkewWarning(); warning != "" {
+		t.Errorf("expected no warning for a small skew, got %v", warning)
+	}
+}
+
+func Test_RateLimit(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		defer func() { _ = req.Body.Close() }()
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer ts.Close()
+
+	remoteConfig := RemoteConfig{
+		TeamSlug: "my-team-slug",
+		APIURL:   ts.URL,
+		Token:    "my-token",
+	}
+	apiClient := NewClient(remoteConfig, hclog.Default(), "v1", Opts{RateLimit: 20, RateLimitBurst: 1})
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if _, err := apiClient.ArtifactExists(context.Background(), "hash"); err != nil {
+			t.Fatalf("ArtifactExists: %v", err)
+		}
+	}
+	// The first request is sent right away, and each of the others 50ms later
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("expected requests to be queued, but 3 took %v", elapsed)
+	}
+}
+
+func Test_TimeoutFallsBackAfterTooManyFailures(t *testing.T) {
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		defer func() { _ = req.Body.Close() }()
+		select {
+		case <-release:
+		case <-req.Context().Done():
+		}
+	}))
+	defer ts.Close()
+	defer close(release)
+
+	remoteConfig := RemoteConfig{
+		TeamSlug: "my-team-slug",
+		APIURL:   ts.URL,
+		Token:    "my-token",
+	}
+	apiClient := NewClient(remoteConfig, hclog.Default(), "v1", Opts{Timeout: 20 * time.Millisecond})
+	apiClient.HttpClient.RetryWaitMin = time.Millisecond
+	apiClient.HttpClient.RetryWaitMax = time.Millisecond
+	start := time.Now()
+	_, err := apiClient.ArtifactExists(context.Background(), "hash")
+	if err == nil {
+		t.Fatal("expected the request to time out")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected the request to time out after 20ms, but it took %v", elapsed)
+	}
+	// Timeouts count as failures, and once there have been enough, no more requests are sent
+	if _, err := apiClient.ArtifactExists(context.Background(), "hash"); !errors.Is(err, util.ErrTooManyFailures) {
+		t.Errorf("expected ErrTooManyFailures, got %v", err)
+	}
+}
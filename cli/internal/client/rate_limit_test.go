@@ -0,0 +1,66 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func Test_rateLimiter(t *testing.T) {
+	now := time.Unix(0, 0)
+	rl := newRateLimiter(2, 3)
+	rl.now = func() time.Time { return now }
+
+	// the bucket starts full, so a burst goes through without waiting
+	for i := 0; i < 3; i++ {
+		if delay := rl.reserve(); delay != 0 {
+			t.Errorf("request %v: expected no delay, got %v", i, delay)
+		}
+	}
+	// then requests are spaced out at the rate
+	if delay := rl.reserve(); delay != 500*time.Millisecond {
+		t.Errorf("expected a delay of 500ms, got %v", delay)
+	}
+	if delay := rl.reserve(); delay != time.Second {
+		t.Errorf("expected a delay of 1s, got %v", delay)
+	}
+
+	// the bucket refills over time, but not beyond the burst
+	now = now.Add(time.Minute)
+	for i := 0; i < 3; i++ {
+		if delay := rl.reserve(); delay != 0 {
+			t.Errorf("request %v after refilling: expected no delay, got %v", i, delay)
+		}
+	}
+	if delay := rl.reserve(); delay == 0 {
+		t.Error("expected a delay once the burst is used up")
+	}
+}
+
+func Test_rateLimiterDefaults(t *testing.T) {
+	if rl := newRateLimiter(0, 5); rl != nil {
+		t.Error("expected no limiter without a rate")
+	}
+	if rl := newRateLimiter(0.5, 0); rl.burst != 1 {
+		t.Errorf("burst: expected 1, got %v", rl.burst)
+	}
+	if rl := newRateLimiter(20, 0); rl.burst != 20 {
+		t.Errorf("burst: expected 20, got %v", rl.burst)
+	}
+}
+
+func Test_rateLimiterWaitCanceled(t *testing.T) {
+	rl := newRateLimiter(0.001, 1)
+	if err := rl.wait(context.Background()); err != nil {
+		t.Fatalf("wait: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := rl.wait(ctx); err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	// the canceled request gives its token back
+	if rl.tokens < -0.01 {
+		t.Errorf("expected the token to be returned, got %v tokens", rl.tokens)
+	}
+}
@@ -0,0 +1,33 @@
+// Package cache holds the `turbo cache` command, which manages artifacts in the local cache
+package cache
+
+import (
+	"github.com/spf13/cobra"
+	turbocache "github.com/vercel/turbo/cli/internal/cache"
+	"github.com/vercel/turbo/cli/internal/cmdutil"
+)
+
+type opts struct {
+	cacheOpts turbocache.Opts
+}
+
+// GetCmd returns the root cache command
+func GetCmd(helper *cmdutil.Helper) *cobra.Command {
+	opts := &opts{}
+	cmd := &cobra.Command{
+		Use:                   "cache",
+		Short:                 "Manage artifacts in the local cache",
+		SilenceUsage:          true,
+		SilenceErrors:         true,
+		DisableFlagsInUseLine: true,
+	}
+	cmd.PersistentFlags().StringVar(&opts.cacheOpts.OverrideDir, "cache-dir", "", "Override the filesystem cache directory.")
+	cmd.AddCommand(pinCmd(helper, opts))
+	cmd.AddCommand(unpinCmd(helper, opts))
+	cmd.AddCommand(pinsCmd(helper, opts))
+	cmd.AddCommand(verifyCmd(helper, opts))
+	cmd.AddCommand(gcCmd(helper, opts))
+	cmd.AddCommand(lsCmd(helper, opts))
+	cmd.AddCommand(showCmd(helper, opts))
+	return cmd
+}
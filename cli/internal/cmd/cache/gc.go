@@ -0,0 +1,102 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	turbocache "github.com/vercel/turbo/cli/internal/cache"
+	"github.com/vercel/turbo/cli/internal/cmdutil"
+	"github.com/vercel/turbo/cli/internal/errcode"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+type gcOpts struct {
+	maxAge  string
+	maxSize string
+	dryRun  bool
+	json    bool
+}
+
+func gcCmd(helper *cmdutil.Helper, opts *opts) *cobra.Command {
+	gcOpts := &gcOpts{}
+	cmd := &cobra.Command{
+		Use:   "gc",
+		Short: "Remove old artifacts from the local cache",
+		Long: `Remove old artifacts from the local cache.
+
+Artifacts that haven't been written or restored for longer than --max-age are
+removed, and then the least recently used artifacts until the cache fits in
+--max-size. Pinned artifacts are never removed.`,
+		Args:          cobra.NoArgs,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, err := helper.GetCmdBase(cmd.Flags())
+			if err != nil {
+				return err
+			}
+			if err := gc(base, opts, gcOpts); err != nil {
+				base.LogError("gc failed: %w", err)
+				return err
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&gcOpts.maxAge, "max-age", "", "Remove artifacts that haven't been used for longer than this, such as 30d")
+	cmd.Flags().StringVar(&gcOpts.maxSize, "max-size", "", "Remove the least recently used artifacts until the cache fits in this size, such as 5GB")
+	cmd.Flags().BoolVar(&gcOpts.dryRun, "dry-run", false, "List the artifacts that would be removed without removing them")
+	cmd.Flags().BoolVar(&gcOpts.json, "json", false, "Print the removed artifacts as JSON")
+	return cmd
+}
+
+func gc(base *cmdutil.CmdBase, opts *opts, gcOpts *gcOpts) error {
+	limits := turbocache.GCOpts{DryRun: gcOpts.dryRun}
+	var err error
+	if gcOpts.maxAge == "" && gcOpts.maxSize == "" {
+		return errcode.Wrap(errcode.InvalidArguments, errors.New("pass --max-age, --max-size, or both"))
+	}
+	if gcOpts.maxAge != "" {
+		if limits.MaxAge, err = util.ParseDuration(gcOpts.maxAge); err != nil {
+			return errcode.Wrap(errcode.InvalidArguments, fmt.Errorf("--max-age: %w", err))
+		}
+	}
+	if gcOpts.maxSize != "" {
+		if limits.MaxSize, err = util.ParseByteSize(gcOpts.maxSize); err != nil {
+			return errcode.Wrap(errcode.InvalidArguments, fmt.Errorf("--max-size: %w", err))
+		}
+	}
+	result, err := turbocache.GC(turbocache.ResolveCacheDir(opts.cacheOpts, base.RepoRoot), limits, time.Now())
+	if err != nil {
+		return err
+	}
+	if gcOpts.json {
+		bytes, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return err
+		}
+		base.UI.Output(string(bytes))
+		return nil
+	}
+	if len(result.Removed) > 0 {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "Hash\tSize\tLast Used\t")
+		for _, artifact := range result.Removed {
+			fmt.Fprintf(w, "%v\t%v\t%v\t\n", artifact.Hash, util.FormatByteSize(artifact.Size), artifact.LastUsed.Format("2006-01-02 15:04:05"))
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	verb := "Removed"
+	if result.DryRun {
+		verb = "Would remove"
+	}
+	base.UI.Info(util.Sprintf("${GREY}>>> %v %v artifacts, freeing %v. %v artifacts remain, using %v, of which %v are pinned${RESET}",
+		verb, len(result.Removed), util.FormatByteSize(result.Freed), result.Kept, util.FormatByteSize(result.KeptSize), result.Pinned))
+	return nil
+}
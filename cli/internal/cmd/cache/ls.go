@@ -0,0 +1,107 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	turbocache "github.com/vercel/turbo/cli/internal/cache"
+	"github.com/vercel/turbo/cli/internal/cmdutil"
+	"github.com/vercel/turbo/cli/internal/run"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+type lsOpts struct {
+	json bool
+}
+
+func lsCmd(helper *cmdutil.Helper, opts *opts) *cobra.Command {
+	lsOpts := &lsOpts{}
+	cmd := &cobra.Command{
+		Use:   "ls",
+		Short: "List the artifacts in the local cache",
+		Long: `List the artifacts in the local cache, the most recently used first.
+
+The task that produced an artifact is shown if a recent run in this
+repository, or its pin, records it.`,
+		Args:          cobra.NoArgs,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, err := helper.GetCmdBase(cmd.Flags())
+			if err != nil {
+				return err
+			}
+			if err := ls(base, opts, lsOpts); err != nil {
+				base.LogError("ls failed: %w", err)
+				return err
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&lsOpts.json, "json", false, "Print the artifacts as JSON")
+	return cmd
+}
+
+// resolveTaskID fills in the task of an artifact that its pin doesn't record
+func resolveTaskID(artifact *turbocache.Artifact, taskIDs map[string]string) {
+	if artifact.TaskID == "" {
+		artifact.TaskID = taskIDs[artifact.Hash]
+	}
+}
+
+// readTaskIDs returns the task that produced each hash in recent runs
+func readTaskIDs(repoRoot turbopath.AbsoluteSystemPath) (map[string]string, error) {
+	taskIDs, err := run.TaskIDsByHash(repoRoot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recent runs: %w", err)
+	}
+	return taskIDs, nil
+}
+
+func ls(base *cmdutil.CmdBase, opts *opts, lsOpts *lsOpts) error {
+	artifacts, err := turbocache.ListArtifacts(turbocache.ResolveCacheDir(opts.cacheOpts, base.RepoRoot))
+	if err != nil {
+		return err
+	}
+	taskIDs, err := readTaskIDs(base.RepoRoot)
+	if err != nil {
+		return err
+	}
+	// The most recently used first
+	for i, j := 0, len(artifacts)-1; i < j; i, j = i+1, j-1 {
+		artifacts[i], artifacts[j] = artifacts[j], artifacts[i]
+	}
+	var total int64
+	for _, artifact := range artifacts {
+		resolveTaskID(artifact, taskIDs)
+		total += artifact.Size
+	}
+	if lsOpts.json {
+		bytes, err := json.MarshalIndent(artifacts, "", "  ")
+		if err != nil {
+			return err
+		}
+		base.UI.Output(string(bytes))
+		return nil
+	}
+	if len(artifacts) > 0 {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "Hash\tTask\tSize\tLast Used\tPinned\t")
+		for _, artifact := range artifacts {
+			pinned := ""
+			if artifact.Pinned {
+				pinned = "yes"
+			}
+			fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t\n", artifact.Hash, artifact.TaskID, util.FormatByteSize(artifact.Size), artifact.LastUsed.Format("2006-01-02 15:04:05"), pinned)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	base.UI.Info(util.Sprintf("${GREY}>>> %v artifacts, using %v${RESET}", len(artifacts), util.FormatByteSize(total)))
+	return nil
+}
@@ -0,0 +1,158 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	turbocache "github.com/vercel/turbo/cli/internal/cache"
+	"github.com/vercel/turbo/cli/internal/cmdutil"
+	"github.com/vercel/turbo/cli/internal/errcode"
+	"github.com/vercel/turbo/cli/internal/run"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+type pinOpts struct {
+	ref string
+}
+
+func pinCmd(helper *cmdutil.Helper, opts *opts) *cobra.Command {
+	pinOpts := &pinOpts{}
+	cmd := &cobra.Command{
+		Use:   "pin [<hash>] [--ref <name>]",
+		Short: "Pin artifacts so that they are never evicted from the cache",
+		Long: `Pin artifacts so that they are never evicted from the cache.
+
+With a hash, that artifact is pinned, and it must be in the local cache.
+With --ref and no hash, every artifact produced by the tasks that succeeded
+in the most recent run is pinned under the ref, such as the tag of a release.`,
+		Args:          cobra.MaximumNArgs(1),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, err := helper.GetCmdBase(cmd.Flags())
+			if err != nil {
+				return err
+			}
+			hash := ""
+			if len(args) > 0 {
+				hash = args[0]
+			}
+			if err := pin(base, opts, hash, pinOpts.ref); err != nil {
+				base.LogError("pin failed: %w", err)
+				return err
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&pinOpts.ref, "ref", "", "The ref to pin artifacts under. Without a hash, pins the artifacts of the most recent run.")
+	return cmd
+}
+
+func pin(base *cmdutil.CmdBase, opts *opts, hash string, ref string) error {
+	if hash == "" && ref == "" {
+		return errcode.Wrap(errcode.InvalidArguments, errors.New("either a hash or --ref is required"))
+	}
+	cacheDir := turbocache.ResolveCacheDir(opts.cacheOpts, base.RepoRoot)
+	pins, err := turbocache.ReadPins(cacheDir)
+	if err != nil {
+		return fmt.Errorf("failed to read pinned artifacts: %w", err)
+	}
+	lastRun, err := run.LastRunHashes(base.RepoRoot)
+	if err != nil {
+		return fmt.Errorf("failed to read the most recent run: %w", err)
+	}
+
+	if hash != "" {
+		if !turbocache.LocalArtifactExists(cacheDir, hash) {
+			return errcode.Wrap(errcode.InvalidArguments, fmt.Errorf("%v is not in the local cache", hash))
+		}
+		taskID := ""
+		for id, lastHash := range lastRun {
+			if lastHash == hash {
+				taskID = id
+			}
+		}
+		pins.Pin(hash, ref, taskID)
+		base.UI.Info(util.Sprintf("${GREY}>>> Pinned %v${RESET}", hash))
+		return pins.Write()
+	}
+
+	if len(lastRun) == 0 {
+		return errcode.Wrap(errcode.InvalidArguments, errors.New("there is no previous run to pin"))
+	}
+	taskIDs := make([]string, 0, len(lastRun))
+	for taskID := range lastRun {
+		taskIDs = append(taskIDs, taskID)
+	}
+	sort.Strings(taskIDs)
+	for _, taskID := range taskIDs {
+		pins.Pin(lastRun[taskID], ref, taskID)
+	}
+	base.UI.Info(util.Sprintf("${GREY}>>> Pinned %v artifacts as %v${RESET}", len(taskIDs), ref))
+	return pins.Write()
+}
+
+func unpinCmd(helper *cmdutil.Helper, opts *opts) *cobra.Command {
+	return &cobra.Command{
+		Use:           "unpin <hash|ref>",
+		Short:         "Unpin artifacts, allowing them to be evicted from the cache",
+		Args:          cobra.ExactArgs(1),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, err := helper.GetCmdBase(cmd.Flags())
+			if err != nil {
+				return err
+			}
+			if err := unpin(base, opts, args[0]); err != nil {
+				base.LogError("unpin failed: %w", err)
+				return err
+			}
+			return nil
+		},
+	}
+}
+
+func unpin(base *cmdutil.CmdBase, opts *opts, hashOrRef string) error {
+	pins, err := turbocache.ReadPins(turbocache.ResolveCacheDir(opts.cacheOpts, base.RepoRoot))
+	if err != nil {
+		return fmt.Errorf("failed to read pinned artifacts: %w", err)
+	}
+	removed := pins.Unpin(hashOrRef)
+	if len(removed) == 0 {
+		return errcode.Wrap(errcode.InvalidArguments, fmt.Errorf("nothing is pinned as %v", hashOrRef))
+	}
+	base.UI.Info(util.Sprintf("${GREY}>>> Unpinned %v artifacts${RESET}", len(removed)))
+	return pins.Write()
+}
+
+func pinsCmd(helper *cmdutil.Helper, opts *opts) *cobra.Command {
+	return &cobra.Command{
+		Use:           "pins",
+		Short:         "List pinned artifacts",
+		Args:          cobra.NoArgs,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, err := helper.GetCmdBase(cmd.Flags())
+			if err != nil {
+				return err
+			}
+			pins, err := turbocache.ReadPins(turbocache.ResolveCacheDir(opts.cacheOpts, base.RepoRoot))
+			if err != nil {
+				base.LogError("failed to read pinned artifacts: %w", err)
+				return err
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "Hash\tRef\tTask\tPinned At\t")
+			for _, pin := range pins.List() {
+				fmt.Fprintf(w, "%v\t%v\t%v\t%v\t\n", pin.Hash, pin.Ref, pin.TaskID, pin.PinnedAt.Format("2006-01-02 15:04:05"))
+			}
+			return w.Flush()
+		},
+	}
+}
@@ -0,0 +1,114 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	turbocache "github.com/vercel/turbo/cli/internal/cache"
+	"github.com/vercel/turbo/cli/internal/cmdutil"
+	"github.com/vercel/turbo/cli/internal/errcode"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+type showOpts struct {
+	json bool
+}
+
+func showCmd(helper *cmdutil.Helper, opts *opts) *cobra.Command {
+	showOpts := &showOpts{}
+	cmd := &cobra.Command{
+		Use:   "show <hash>",
+		Short: "Show the files and logs of an artifact in the local cache",
+		Long: `Show the files and logs of an artifact in the local cache.
+
+The hash can be shortened to any prefix that only one artifact starts with.
+Nothing is restored into the repository.`,
+		Args:          cobra.ExactArgs(1),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, err := helper.GetCmdBase(cmd.Flags())
+			if err != nil {
+				return err
+			}
+			if err := show(base, opts, showOpts, args[0]); err != nil {
+				base.LogError("show failed: %w", err)
+				return err
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&showOpts.json, "json", false, "Print the artifact as JSON")
+	return cmd
+}
+
+func show(base *cmdutil.CmdBase, opts *opts, showOpts *showOpts, hash string) error {
+	details, err := turbocache.InspectArtifact(turbocache.ResolveCacheDir(opts.cacheOpts, base.RepoRoot), hash)
+	if errors.Is(err, turbocache.ErrArtifactMissing) {
+		return errcode.Wrap(errcode.InvalidArguments, fmt.Errorf("no artifact for %v in the local cache", hash))
+	} else if err != nil {
+		return err
+	}
+	taskIDs, err := readTaskIDs(base.RepoRoot)
+	if err != nil {
+		return err
+	}
+	resolveTaskID(details.Artifact, taskIDs)
+	if showOpts.json {
+		bytes, err := json.MarshalIndent(details, "", "  ")
+		if err != nil {
+			return err
+		}
+		base.UI.Output(string(bytes))
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Hash\t%v\t\n", details.Hash)
+	if details.TaskID != "" {
+		fmt.Fprintf(w, "Task\t%v\t\n", details.TaskID)
+	}
+	fmt.Fprintf(w, "Size\t%v\t\n", util.FormatByteSize(details.Size))
+	fmt.Fprintf(w, "Last Used\t%v\t\n", details.LastUsed.Format("2006-01-02 15:04:05"))
+	fmt.Fprintf(w, "Duration\t%v\t\n", time.Duration(details.DurationMs)*time.Millisecond)
+	if details.Pinned {
+		pinned := "yes"
+		if details.Ref != "" {
+			pinned = fmt.Sprintf("yes, as %v", details.Ref)
+		}
+		fmt.Fprintf(w, "Pinned\t%v\t\n", pinned)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	base.UI.Output("")
+	base.UI.Output(fmt.Sprintf("Files (%v)", len(details.Files)))
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, file := range details.Files {
+		name, size := file.Path, ""
+		switch file.Type {
+		case "symlink":
+			name = fmt.Sprintf("%v -> %v", file.Path, file.Target)
+		case "file":
+			size = util.FormatByteSize(file.Size)
+		}
+		fmt.Fprintf(w, "  %v\t%v\t%v\t\n", file.Mode, size, name)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if details.LogFile != "" {
+		base.UI.Output("")
+		base.UI.Output(fmt.Sprintf("Logs (%v)", details.LogFile))
+		base.UI.Output(strings.TrimRight(details.Logs, "\n"))
+	}
+	return nil
+}
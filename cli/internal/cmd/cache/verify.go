@@ -0,0 +1,136 @@
+package cache
+
+import (
+	gocontext "context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	turbocache "github.com/vercel/turbo/cli/internal/cache"
+	"github.com/vercel/turbo/cli/internal/cmdutil"
+	"github.com/vercel/turbo/cli/internal/errcode"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/run"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+type verifyOpts struct {
+	remote bool
+}
+
+func verifyCmd(helper *cmdutil.Helper, opts *opts) *cobra.Command {
+	verifyOpts := &verifyOpts{}
+	cmd := &cobra.Command{
+		Use:   "verify [<hash>...]",
+		Short: "Check that cached artifacts are complete and can be restored",
+		Long: `Check that cached artifacts are complete and can be restored.
+
+Each artifact is restored into a temporary directory, never into the
+repository. If no hashes are given, the artifacts produced by the most
+recent run are checked.`,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, err := helper.GetCmdBase(cmd.Flags())
+			if err != nil {
+				return err
+			}
+			if err := verify(cmd.Context(), base, opts, verifyOpts, args); err != nil {
+				base.LogError("verify failed: %w", err)
+				return err
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&verifyOpts.remote, "remote", false, "Also download and check artifacts from the remote cache")
+	return cmd
+}
+
+// verifyStatus describes the state of an artifact in one cache
+func verifyStatus(err error) string {
+	if errors.Is(err, turbocache.ErrArtifactMissing) {
+		return "missing"
+	} else if err != nil {
+		return fmt.Sprintf("corrupt: %v", err)
+	}
+	return "ok"
+}
+
+func verify(ctx gocontext.Context, base *cmdutil.CmdBase, opts *opts, verifyOpts *verifyOpts, hashes []string) error {
+	lastRun, err := run.LastRunHashes(base.RepoRoot)
+	if err != nil {
+		return fmt.Errorf("failed to read the most recent run: %w", err)
+	}
+	taskIDs := make(map[string]string, len(lastRun))
+	for taskID, hash := range lastRun {
+		taskIDs[hash] = taskID
+	}
+	if len(hashes) == 0 {
+		if len(lastRun) == 0 {
+			return errcode.Wrap(errcode.InvalidArguments, errors.New("no hashes were given, and there is no previous run to verify"))
+		}
+		for hash := range taskIDs {
+			hashes = append(hashes, hash)
+		}
+	}
+	hashes = util.SetFromStrings(hashes).UnsafeListOfStrings()
+	sort.Strings(hashes)
+
+	cacheOpts := opts.cacheOpts
+	verifier, err := turbocache.NewVerifier(cacheOpts, base.RepoRoot, nil)
+	if err != nil {
+		return err
+	}
+	if verifyOpts.remote {
+		rootPackageJSON, err := fs.ReadPackageJSON(base.RepoRoot.UntypedJoin("package.json"))
+		if err != nil {
+			return errcode.Wrap(errcode.InvalidPackageJSON, fmt.Errorf("failed to read package.json: %w", err))
+		}
+		turboJSON, err := fs.ReadTurboConfig(base.RepoRoot, rootPackageJSON)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return errcode.Wrap(errcode.InvalidTurboJSON, err)
+		} else if err == nil {
+			cacheOpts.RemoteCacheOpts = turboJSON.RemoteCacheOptions
+		}
+		if cacheOpts.RemoteProvider() == "" && !base.APIClient.IsLinked() {
+			return errcode.Wrap(errcode.InvalidArguments, errors.New("--remote requires a linked Remote Cache. Run \"turbo link\" first"))
+		}
+		verifier, err = turbocache.NewVerifier(cacheOpts, base.RepoRoot, base.APIClient)
+		if err != nil {
+			return err
+		}
+	}
+
+	corrupt := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	if verifier.HasRemote() {
+		fmt.Fprintln(w, "Hash\tTask\tLocal\tRemote\t")
+	} else {
+		fmt.Fprintln(w, "Hash\tTask\tLocal\t")
+	}
+	for _, hash := range hashes {
+		localErr := verifier.VerifyLocal(hash)
+		if localErr != nil && !errors.Is(localErr, turbocache.ErrArtifactMissing) {
+			corrupt++
+		}
+		if !verifier.HasRemote() {
+			fmt.Fprintf(w, "%v\t%v\t%v\t\n", hash, taskIDs[hash], verifyStatus(localErr))
+			continue
+		}
+		remoteErr := verifier.VerifyRemote(ctx, hash)
+		if remoteErr != nil && !errors.Is(remoteErr, turbocache.ErrArtifactMissing) {
+			corrupt++
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t\n", hash, taskIDs[hash], verifyStatus(localErr), verifyStatus(remoteErr))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if corrupt > 0 {
+		return errcode.Wrap(errcode.CorruptArtifact, fmt.Errorf("found %v corrupt artifacts. Remove them from the cache, or rerun their tasks with --force to replace them", corrupt))
+	}
+	return nil
+}
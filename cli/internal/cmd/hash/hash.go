@@ -0,0 +1,94 @@
+// Package hash holds the `turbo hash` command, which describes how turbo
+// calculates the hashes of tasks
+package hash
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/vercel/turbo/cli/internal/cmdutil"
+	"github.com/vercel/turbo/cli/internal/taskhash"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+type opts struct {
+	schema bool
+	json   bool
+}
+
+// GetCmd returns the hash subcommand for use with cobra
+func GetCmd(helper *cmdutil.Helper) *cobra.Command {
+	opts := &opts{}
+	cmd := &cobra.Command{
+		Use:   "hash [<flags>]",
+		Short: "Print the version of the hash schema",
+		Long: `Print the version of the hash schema.
+
+The version is recorded with every cached artifact, and artifacts written
+with a different version are treated as cache misses. Use --schema to print
+exactly what goes into the global hash and the hash of each task.`,
+		Args:                  cobra.NoArgs,
+		SilenceUsage:          true,
+		SilenceErrors:         true,
+		DisableFlagsInUseLine: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, err := helper.GetCmdBase(cmd.Flags())
+			if err != nil {
+				return err
+			}
+			schema := taskhash.HashSchema()
+			if opts.json {
+				bytes, err := json.MarshalIndent(schema, "", "  ")
+				if err != nil {
+					base.LogError("hash failed: %w", err)
+					return err
+				}
+				base.UI.Output(string(bytes))
+				return nil
+			}
+			if !opts.schema {
+				base.UI.Output(fmt.Sprintf("%v", schema.Version))
+				return nil
+			}
+			if err := printSchema(os.Stdout, schema); err != nil {
+				base.LogError("hash failed: %w", err)
+				return err
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&opts.schema, "schema", false, "Print the inputs of the global and task hashes")
+	cmd.Flags().BoolVar(&opts.json, "json", false, "Print the hash schema as JSON")
+	return cmd
+}
+
+func printSchema(out io.Writer, schema taskhash.Schema) error {
+	fmt.Fprintln(out, util.Sprintf("${BOLD}Hash schema${RESET} version %v", schema.Version))
+	fmt.Fprintln(out)
+	fmt.Fprintf(out, "Algorithm: %v\n", schema.Algorithm)
+	fmt.Fprintf(out, "Encoding: %v\n", schema.Encoding)
+	for _, section := range []struct {
+		title  string
+		fields []taskhash.SchemaField
+	}{
+		{"Global hash", schema.Global},
+		{"Task hash", schema.Task},
+	} {
+		fmt.Fprintln(out)
+		fmt.Fprintln(out, util.Sprintf("${BOLD}%v${RESET}", section.title))
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		for i, field := range section.fields {
+			fmt.Fprintf(w, "  %v\t%v\t%v\n", i+1, field.Name, field.Description)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, schema.Passthrough)
+	return nil
+}
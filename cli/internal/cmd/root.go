@@ -9,15 +9,26 @@ import (
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
+	"github.com/vercel/turbo/cli/internal/agent"
+	"github.com/vercel/turbo/cli/internal/bench"
 	"github.com/vercel/turbo/cli/internal/cmd/auth"
+	"github.com/vercel/turbo/cli/internal/cmd/cache"
+	"github.com/vercel/turbo/cli/internal/cmd/hash"
 	"github.com/vercel/turbo/cli/internal/cmd/info"
 	"github.com/vercel/turbo/cli/internal/cmdutil"
 	"github.com/vercel/turbo/cli/internal/daemon"
+	"github.com/vercel/turbo/cli/internal/deps"
+	"github.com/vercel/turbo/cli/internal/doctor"
+	"github.com/vercel/turbo/cli/internal/focus"
+	"github.com/vercel/turbo/cli/internal/gen"
 	"github.com/vercel/turbo/cli/internal/login"
+	"github.com/vercel/turbo/cli/internal/migrate"
 	"github.com/vercel/turbo/cli/internal/process"
 	"github.com/vercel/turbo/cli/internal/prune"
 	"github.com/vercel/turbo/cli/internal/run"
 	"github.com/vercel/turbo/cli/internal/signals"
+	"github.com/vercel/turbo/cli/internal/snapshot"
+	"github.com/vercel/turbo/cli/internal/typescript"
 	"github.com/vercel/turbo/cli/internal/util"
 )
 
@@ -143,6 +154,17 @@ func getCmd(helper *cmdutil.Helper, signalWatcher *signals.Watcher) *cobra.Comma
 	cmd.AddCommand(daemon.GetCmd(helper, signalWatcher))
 	cmd.AddCommand(prune.GetCmd(helper))
 	cmd.AddCommand(run.GetCmd(helper, signalWatcher))
+	cmd.AddCommand(bench.GetCmd(helper))
+	cmd.AddCommand(cache.GetCmd(helper))
+	cmd.AddCommand(deps.GetCmd(helper))
+	cmd.AddCommand(hash.GetCmd(helper))
+	cmd.AddCommand(gen.GetCmd(helper))
+	cmd.AddCommand(snapshot.GetCmd(helper))
+	cmd.AddCommand(agent.GetCmd(helper, signalWatcher))
+	cmd.AddCommand(doctor.GetCmd(helper))
+	cmd.AddCommand(focus.GetCmd(helper))
+	cmd.AddCommand(migrate.GetCmd(helper))
+	cmd.AddCommand(typescript.GetCmd(helper))
 	return cmd
 }
 
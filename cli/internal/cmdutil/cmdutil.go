@@ -7,7 +7,9 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"strconv"
 	"sync"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/hashicorp/go-hclog"
@@ -15,6 +17,7 @@ import (
 	"github.com/spf13/pflag"
 	"github.com/vercel/turbo/cli/internal/client"
 	"github.com/vercel/turbo/cli/internal/config"
+	"github.com/vercel/turbo/cli/internal/errcode"
 	"github.com/vercel/turbo/cli/internal/fs"
 	"github.com/vercel/turbo/cli/internal/turbopath"
 	"github.com/vercel/turbo/cli/internal/ui"
@@ -23,6 +26,8 @@ import (
 const (
 	// _envLogLevel is the environment log level
 	_envLogLevel = "TURBO_LOG_LEVEL"
+	// _envRemoteCacheTimeout is the timeout of requests to the Remote Cache
+	_envRemoteCacheTimeout = "TURBO_REMOTE_CACHE_TIMEOUT"
 )
 
 // Helper is a struct used to hold configuration values passed via flag, env vars,
@@ -37,6 +42,8 @@ type Helper struct {
 	noColor    bool
 	// for logging
 	verbosity int
+	// for reporting failures
+	errorFormat string
 
 	rawRepoRoot string
 
@@ -130,6 +137,7 @@ func (h *Helper) AddFlags(flags *pflag.FlagSet) {
 	flags.BoolVar(&h.noColor, "no-color", false, "Suppress color usage in the terminal")
 	flags.CountVarP(&h.verbosity, "verbosity", "v", "verbosity")
 	flags.StringVar(&h.rawRepoRoot, "cwd", "", "The directory in which to run turbo")
+	flags.StringVar(&h.errorFormat, "error-format", errcode.FormatText, "Format of reported errors, either text or json")
 	client.AddFlags(&h.clientOpts, flags)
 	config.AddRepoConfigFlags(flags)
 	config.AddUserConfigFlags(flags)
@@ -156,6 +164,9 @@ func (h *Helper) GetCmdBase(flags *pflag.FlagSet) (*CmdBase, error) {
 	if err != nil {
 		return nil, err
 	}
+	if err := errcode.ValidateFormat(h.errorFormat); err != nil {
+		return nil, errcode.Wrap(errcode.InvalidArguments, err)
+	}
 	cwd, err := fs.GetCwd()
 	if err != nil {
 		return nil, err
@@ -169,10 +180,22 @@ func (h *Helper) GetCmdBase(flags *pflag.FlagSet) (*CmdBase, error) {
 	if err != nil {
 		return nil, err
 	}
+	// Move a user config file left behind by older versions of turbo. Only the
+	// default location is migrated, so overridden paths never pick up the
+	// real user config.
+	if h.UserConfigPath == config.DefaultUserConfigPath() {
+		legacyPath := config.LegacyUserConfigPath()
+		if migrated, err := config.MigrateUserConfig(legacyPath, h.UserConfigPath); err != nil {
+			terminal.Warn(fmt.Sprintf("failed to move user config from %v to %v: %v", legacyPath, h.UserConfigPath, err))
+		} else if migrated {
+			logger.Info(fmt.Sprintf("moved user config from %v to %v", legacyPath, h.UserConfigPath))
+		}
+	}
 	userConfig, err := config.ReadUserConfigFile(h.UserConfigPath, flags)
 	if err != nil {
 		return nil, err
 	}
+	repoConfig.InheritUserConfig(userConfig)
 	remoteConfig := repoConfig.GetRemoteConfig(userConfig.Token())
 	if remoteConfig.Token == "" && ui.IsCI {
 		vercelArtifactsToken := os.Getenv("VERCEL_ARTIFACTS_TOKEN")
@@ -184,6 +207,13 @@ func (h *Helper) GetCmdBase(flags *pflag.FlagSet) (*CmdBase, error) {
 			remoteConfig.TeamID = vercelArtifactsOwner
 		}
 	}
+	if !flags.Changed("remote-cache-timeout") {
+		if timeout, ok, err := remoteCacheTimeoutFromEnv(); err != nil {
+			return nil, errcode.Wrap(errcode.InvalidArguments, err)
+		} else if ok {
+			h.clientOpts.Timeout = timeout
+		}
+	}
 	apiClient := client.NewClient(
 		remoteConfig,
 		logger,
@@ -200,6 +230,7 @@ func (h *Helper) GetCmdBase(flags *pflag.FlagSet) (*CmdBase, error) {
 		UserConfig:   userConfig,
 		RemoteConfig: remoteConfig,
 		TurboVersion: h.TurboVersion,
+		ErrorFormat:  h.errorFormat,
 	}, nil
 }
 
@@ -213,13 +244,28 @@ type CmdBase struct {
 	UserConfig   *config.UserConfig
 	RemoteConfig client.RemoteConfig
 	TurboVersion string
+	// ErrorFormat is either errcode.FormatText or errcode.FormatJSON
+	ErrorFormat string
 }
 
-// LogError prints an error to the UI
+// LogError prints an error to the UI. If any of args is an error wrapped with
+// %w that carries an errcode.Code, the code is included in the output.
 func (b *CmdBase) LogError(format string, args ...interface{}) {
 	err := fmt.Errorf(format, args...)
 	b.Logger.Error("error", err)
-	b.UI.Error(fmt.Sprintf("%s%s", ui.ERROR_PREFIX, color.RedString(" %v", err)))
+	if b.ErrorFormat == errcode.FormatJSON {
+		report, jsonErr := errcode.NewReport(err).JSON()
+		if jsonErr == nil {
+			// Bypass the UI so that the report is never colored
+			fmt.Fprintln(os.Stderr, report)
+			return
+		}
+	}
+	code := ""
+	if c := errcode.Of(err); c != errcode.Unknown {
+		code = fmt.Sprintf(" [%v]", c)
+	}
+	b.UI.Error(fmt.Sprintf("%s%s%s", ui.ERROR_PREFIX, color.RedString(" %v", err), code))
 }
 
 // LogWarning logs an error and outputs it to the UI.
@@ -238,3 +284,20 @@ func (b *CmdBase) LogInfo(msg string) {
 	b.Logger.Info(msg)
 	b.UI.Info(fmt.Sprintf("%s%s", ui.InfoPrefix, color.WhiteString(" %v", msg)))
 }
+
+// remoteCacheTimeoutFromEnv reads TURBO_REMOTE_CACHE_TIMEOUT, which is either a
+// duration, such as 30s, or a number of seconds
+func remoteCacheTimeoutFromEnv() (time.Duration, bool, error) {
+	raw := os.Getenv(_envRemoteCacheTimeout)
+	if raw == "" {
+		return 0, false, nil
+	}
+	if seconds, err := strconv.ParseUint(raw, 10, 32); err == nil {
+		return time.Duration(seconds) * time.Second, true, nil
+	}
+	timeout, err := time.ParseDuration(raw)
+	if err != nil || timeout < 0 {
+		return 0, false, fmt.Errorf("invalid %v %q, expected a duration such as 30s, or a number of seconds", _envRemoteCacheTimeout, raw)
+	}
+	return timeout, true, nil
+}
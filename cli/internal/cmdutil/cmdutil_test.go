@@ -3,6 +3,7 @@ package cmdutil
 import (
 	"os"
 	"testing"
+	"time"
 
 	"github.com/spf13/pflag"
 	"github.com/vercel/turbo/cli/internal/fs"
@@ -39,3 +40,40 @@ func TestTokenEnvVar(t *testing.T) {
 		})
 	}
 }
+
+func TestRemoteCacheTimeoutEnvVar(t *testing.T) {
+	userConfigPath := fs.AbsoluteSystemPathFromUpstream(t.TempDir()).UntypedJoin("turborepo", "config.json")
+	cases := map[string]struct {
+		env      string
+		args     []string
+		expected time.Duration
+	}{
+		"default":          {expected: 20 * time.Second},
+		"seconds":          {env: "45", expected: 45 * time.Second},
+		"duration":         {env: "1m30s", expected: 90 * time.Second},
+		"flag takes over":  {env: "45", args: []string{"--remote-cache-timeout=5s"}, expected: 5 * time.Second},
+		"no limit by flag": {args: []string{"--remote-cache-timeout=0"}, expected: 0},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("TURBO_REMOTE_CACHE_TIMEOUT", tc.env)
+			flags := pflag.NewFlagSet("test-flags", pflag.ContinueOnError)
+			h := NewHelper("test-version")
+			h.AddFlags(flags)
+			h.UserConfigPath = userConfigPath
+			assert.NilError(t, flags.Parse(tc.args))
+
+			base, err := h.GetCmdBase(flags)
+			assert.NilError(t, err)
+			assert.Equal(t, base.APIClient.HttpClient.HTTPClient.Timeout, tc.expected)
+		})
+	}
+
+	t.Setenv("TURBO_REMOTE_CACHE_TIMEOUT", "soon")
+	flags := pflag.NewFlagSet("test-flags", pflag.ContinueOnError)
+	h := NewHelper("test-version")
+	h.AddFlags(flags)
+	h.UserConfigPath = userConfigPath
+	_, err := h.GetCmdBase(flags)
+	assert.ErrorContains(t, err, `invalid TURBO_REMOTE_CACHE_TIMEOUT "soon"`)
+}
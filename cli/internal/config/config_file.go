@@ -14,11 +14,43 @@ import (
 type RepoConfig struct {
 	repoViper *viper.Viper
 	path      turbopath.AbsoluteSystemPath
+	// userConfig holds the user-level values used for anything
+	// that isn't set for this repository
+	userConfig *UserConfig
 }
 
 // LoginURL returns the configured URL for authenticating the user
 func (rc *RepoConfig) LoginURL() string {
-	return rc.repoViper.GetString("loginurl")
+	return rc.get("loginurl")
+}
+
+// InheritUserConfig makes the API URL, login URL, team, and token
+// environment variable fall back to the values in the given user config
+// when they aren't set for this repository.
+func (rc *RepoConfig) InheritUserConfig(userConfig *UserConfig) {
+	rc.userConfig = userConfig
+}
+
+// get returns the value of key from the repo config, flags, or environment,
+// falling back to the user config and then to the default value.
+func (rc *RepoConfig) get(key string) string {
+	if rc.repoViper.IsSet(key) {
+		return rc.repoViper.GetString(key)
+	}
+	if rc.userConfig != nil && rc.userConfig.userViper.IsSet(key) {
+		return rc.userConfig.userViper.GetString(key)
+	}
+	return _repoDefaults[key]
+}
+
+// team returns the team id and slug. They are read together from either the
+// repo config or the user config, since a slug from one doesn't necessarily
+// match an id from the other.
+func (rc *RepoConfig) team() (teamID string, teamSlug string) {
+	if rc.repoViper.IsSet("teamid") || rc.repoViper.IsSet("teamslug") || rc.userConfig == nil {
+		return rc.repoViper.GetString("teamid"), rc.repoViper.GetString("teamslug")
+	}
+	return rc.userConfig.userViper.GetString("teamid"), rc.userConfig.userViper.GetString("teamslug")
 }
 
 // SetTeamID sets the teamID and clears the slug, since it may have been from an old team
@@ -34,13 +66,21 @@ func (rc *RepoConfig) SetTeamID(teamID string) error {
 	return rc.write()
 }
 
-// GetRemoteConfig produces the necessary values for an API client configuration
+// GetRemoteConfig produces the necessary values for an API client configuration.
+// If token is empty and a token environment variable is configured, the token
+// is read from that variable.
 func (rc *RepoConfig) GetRemoteConfig(token string) client.RemoteConfig {
+	if token == "" {
+		if tokenEnv := rc.get("tokenenv"); tokenEnv != "" {
+			token = os.Getenv(tokenEnv)
+		}
+	}
+	teamID, teamSlug := rc.team()
 	return client.RemoteConfig{
 		Token:    token,
-		TeamID:   rc.repoViper.GetString("teamid"),
-		TeamSlug: rc.repoViper.GetString("teamslug"),
-		APIURL:   rc.repoViper.GetString("apiurl"),
+		TeamID:   teamID,
+		TeamSlug: teamSlug,
+		APIURL:   rc.get("apiurl"),
 	}
 }
 
@@ -120,17 +160,51 @@ func AddUserConfigFlags(flags *pflag.FlagSet) {
 	flags.String("token", "", "Set the auth token for API calls")
 }
 
-// DefaultUserConfigPath returns the default platform-dependent place that
-// we store the user-specific configuration.
+// DefaultUserConfigPath returns the default place that we store the
+// user-specific configuration.
 func DefaultUserConfigPath() turbopath.AbsoluteSystemPath {
 	return fs.GetUserConfigDir().UntypedJoin("config.json")
 }
 
+// LegacyUserConfigPath returns the platform-dependent place that older
+// versions of turbo stored the user-specific configuration.
+func LegacyUserConfigPath() turbopath.AbsoluteSystemPath {
+	return fs.GetLegacyUserConfigDir().UntypedJoin("config.json")
+}
+
+// MigrateUserConfig moves the user config file at legacyPath to path, unless
+// there is already a file at path. It returns true if a file was moved.
+func MigrateUserConfig(legacyPath turbopath.AbsoluteSystemPath, path turbopath.AbsoluteSystemPath) (bool, error) {
+	if legacyPath == path || path.FileExists() || !legacyPath.FileExists() {
+		return false, nil
+	}
+	if err := path.EnsureDir(); err != nil {
+		return false, err
+	}
+	if err := legacyPath.Rename(path); err == nil {
+		return true, nil
+	}
+	// Renaming fails across devices, fall back to copying the file
+	contents, err := legacyPath.ReadFile()
+	if err != nil {
+		return false, err
+	}
+	if err := path.WriteFile(contents, 0600); err != nil {
+		return false, err
+	}
+	return true, legacyPath.Remove()
+}
+
 const (
 	_defaultAPIURL   = "https://vercel.com/api"
 	_defaultLoginURL = "https://vercel.com"
 )
 
+var _repoDefaults = map[string]string{
+	"apiurl":   _defaultAPIURL,
+	"loginurl": _defaultLoginURL,
+}
+
 // ReadRepoConfigFile creates a RepoConfig using the
 // specified path as the repo config file. Note that the path or its
 // parents do not need to exist. On a write to this configuration, they
@@ -144,8 +218,6 @@ func ReadRepoConfigFile(path turbopath.AbsoluteSystemPath, flags *pflag.FlagSet)
 	repoViper.MustBindEnv("loginurl", "TURBO_LOGIN")
 	repoViper.MustBindEnv("teamslug", "TURBO_TEAM")
 	repoViper.MustBindEnv("teamid")
-	repoViper.SetDefault("apiurl", _defaultAPIURL)
-	repoViper.SetDefault("loginurl", _defaultLoginURL)
 	if err := repoViper.BindPFlag("loginurl", flags.Lookup("login")); err != nil {
 		return nil, err
 	}
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/spf13/pflag"
+	"github.com/vercel/turbo/cli/internal/client"
 	"github.com/vercel/turbo/cli/internal/fs"
 	"gotest.tools/v3/assert"
 )
@@ -147,3 +148,83 @@ func TestUserConfigFlags(t *testing.T) {
 	assert.Equal(t, userConfig.Token(), "my-token")
 	assert.Equal(t, userConfig.path, configPath)
 }
+
+func TestRepoConfigInheritsUserConfig(t *testing.T) {
+	dir := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	userConfigPath := dir.UntypedJoin("turborepo", "config.json")
+	repoConfigPath := dir.UntypedJoin("repo", ".turbo", "config.json")
+	flags := pflag.NewFlagSet("test-flags", pflag.ContinueOnError)
+	AddRepoConfigFlags(flags)
+	AddUserConfigFlags(flags)
+
+	assert.NilError(t, userConfigPath.EnsureDir(), "EnsureDir")
+	assert.NilError(t, userConfigPath.WriteFile([]byte(`{"apiUrl":"http://user-api","teamId":"user-team-id","teamSlug":"user-team","tokenEnv":"TEST_TURBO_TOKEN_ENV"}`), 0644), "WriteFile")
+	t.Setenv("TEST_TURBO_TOKEN_ENV", "env-token")
+	userConfig, err := ReadUserConfigFile(userConfigPath, flags)
+	assert.NilError(t, err, "ReadUserConfigFile")
+
+	// Without a repo config, everything comes from the user config
+	repoConfig, err := ReadRepoConfigFile(repoConfigPath, flags)
+	assert.NilError(t, err, "ReadRepoConfigFile")
+	repoConfig.InheritUserConfig(userConfig)
+	assert.DeepEqual(t, repoConfig.GetRemoteConfig(userConfig.Token()), client.RemoteConfig{
+		Token:    "env-token",
+		TeamID:   "user-team-id",
+		TeamSlug: "user-team",
+		APIURL:   "http://user-api",
+	})
+	assert.Equal(t, repoConfig.LoginURL(), _defaultLoginURL)
+	// A token that is set explicitly wins over the token environment variable
+	assert.Equal(t, repoConfig.GetRemoteConfig("my-token").Token, "my-token")
+
+	// The repo config overrides the team as a whole
+	assert.NilError(t, repoConfigPath.EnsureDir(), "EnsureDir")
+	assert.NilError(t, repoConfigPath.WriteFile([]byte(`{"teamSlug":"repo-team"}`), 0644), "WriteFile")
+	repoConfig, err = ReadRepoConfigFile(repoConfigPath, flags)
+	assert.NilError(t, err, "ReadRepoConfigFile")
+	repoConfig.InheritUserConfig(userConfig)
+	assert.DeepEqual(t, repoConfig.GetRemoteConfig(""), client.RemoteConfig{
+		Token:    "env-token",
+		TeamSlug: "repo-team",
+		APIURL:   "http://user-api",
+	})
+
+	// Flags override both
+	assert.NilError(t, flags.Set("api", "http://flag-api"), "flags.Set")
+	repoConfig, err = ReadRepoConfigFile(repoConfigPath, flags)
+	assert.NilError(t, err, "ReadRepoConfigFile")
+	repoConfig.InheritUserConfig(userConfig)
+	assert.Equal(t, repoConfig.GetRemoteConfig("").APIURL, "http://flag-api")
+}
+
+func TestMigrateUserConfig(t *testing.T) {
+	dir := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	legacyPath := dir.UntypedJoin("legacy", "turborepo", "config.json")
+	path := dir.UntypedJoin("xdg", "turborepo", "config.json")
+
+	migrated, err := MigrateUserConfig(legacyPath, path)
+	assert.NilError(t, err, "MigrateUserConfig")
+	assert.Equal(t, migrated, false, "nothing to migrate")
+
+	assert.NilError(t, legacyPath.EnsureDir(), "EnsureDir")
+	assert.NilError(t, legacyPath.WriteFile([]byte(`{"token":"legacy-token"}`), 0600), "WriteFile")
+	migrated, err = MigrateUserConfig(legacyPath, path)
+	assert.NilError(t, err, "MigrateUserConfig")
+	assert.Equal(t, migrated, true)
+	assert.Equal(t, legacyPath.FileExists(), false, "legacy config should be moved")
+
+	flags := pflag.NewFlagSet("test-flags", pflag.ContinueOnError)
+	AddUserConfigFlags(flags)
+	userConfig, err := ReadUserConfigFile(path, flags)
+	assert.NilError(t, err, "ReadUserConfigFile")
+	assert.Equal(t, userConfig.Token(), "legacy-token")
+
+	// An existing config is never overwritten
+	assert.NilError(t, legacyPath.WriteFile([]byte(`{"token":"stale-token"}`), 0600), "WriteFile")
+	migrated, err = MigrateUserConfig(legacyPath, path)
+	assert.NilError(t, err, "MigrateUserConfig")
+	assert.Equal(t, migrated, false)
+	userConfig, err = ReadUserConfigFile(path, flags)
+	assert.NilError(t, err, "ReadUserConfigFile")
+	assert.Equal(t, userConfig.Token(), "legacy-token")
+}
@@ -0,0 +1,35 @@
+package context
+
+import (
+	"errors"
+
+	"github.com/vercel/turbo/cli/internal/errcode"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+// BuildPackageGraphWithConfig builds the package graph of the repository, and adds
+// the task definitions of the workspace configs in each package.json to the pipeline
+// of turboJSON, which can only be found once the workspaces are known. A federation
+// of monorepos is built with BuildFederatedPackageGraph, which applies the workspace
+// configs of each root, and returns the federated config. turboJSON can be nil if the
+// repository has no config, in which case only the graph is built.
+//
+// As with BuildPackageGraph, the error can be *Warnings alongside a usable graph.
+// An invalid workspace config is an errcode.InvalidTurboJSON error.
+func BuildPackageGraphWithConfig(repoRoot turbopath.AbsoluteSystemPath, rootPackageJSON *fs.PackageJSON, turboJSON *fs.TurboJSON) (*Context, *fs.TurboJSON, error) {
+	if turboJSON != nil && len(turboJSON.Roots) > 0 {
+		return BuildFederatedPackageGraph(repoRoot, rootPackageJSON, turboJSON)
+	}
+	ctx, err := BuildPackageGraph(repoRoot, rootPackageJSON)
+	var warnings *Warnings
+	if err != nil && !errors.As(err, &warnings) {
+		return nil, nil, err
+	}
+	if turboJSON != nil {
+		if configErr := turboJSON.ApplyWorkspaceConfigs(ctx.PackageInfos); configErr != nil {
+			return nil, nil, errcode.Wrap(errcode.InvalidTurboJSON, configErr)
+		}
+	}
+	return ctx, turboJSON, err
+}
@@ -0,0 +1,82 @@
+package context
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/errcode"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+func writeWorkspaceConfigRepo(t *testing.T, webConfig interface{}) (turbopath.AbsoluteSystemPath, *fs.PackageJSON, *fs.TurboJSON) {
+	t.Helper()
+	repoRoot := turbopath.AbsoluteSystemPathFromUpstream(t.TempDir())
+	writeJSON(t, repoRoot.UntypedJoin("package.json"), map[string]interface{}{
+		"name":           "workspace-configs",
+		"packageManager": "npm@8.19.2",
+		"workspaces":     []string{"apps/*"},
+	})
+	writeJSON(t, repoRoot.UntypedJoin("package-lock.json"), map[string]interface{}{
+		"lockfileVersion": 2,
+		"packages":        map[string]interface{}{},
+	})
+	writeJSON(t, repoRoot.UntypedJoin("turbo.json"), map[string]interface{}{
+		"pipeline": map[string]interface{}{
+			"build": map[string]interface{}{"outputs": []string{"dist/**"}},
+		},
+	})
+	writeJSON(t, repoRoot.UntypedJoin("apps", "web", "package.json"), map[string]interface{}{
+		"name":  "web",
+		"turbo": webConfig,
+	})
+	writeJSON(t, repoRoot.UntypedJoin("apps", "docs", "package.json"), map[string]interface{}{"name": "docs"})
+
+	rootPackageJSON, err := fs.ReadPackageJSON(repoRoot.UntypedJoin("package.json"))
+	if err != nil {
+		t.Fatalf("failed to read package.json: %v", err)
+	}
+	turboJSON, err := fs.ReadTurboConfig(repoRoot, rootPackageJSON)
+	if err != nil {
+		t.Fatalf("failed to read turbo.json: %v", err)
+	}
+	return repoRoot, rootPackageJSON, turboJSON
+}
+
+func Test_BuildPackageGraphWithConfig(t *testing.T) {
+	repoRoot, rootPackageJSON, turboJSON := writeWorkspaceConfigRepo(t, map[string]interface{}{
+		"extends": []string{"//"},
+		"pipeline": map[string]interface{}{
+			"build": map[string]interface{}{"outputs": []string{".next/**"}},
+		},
+	})
+
+	c, turboJSON, err := BuildPackageGraphWithConfig(repoRoot, rootPackageJSON, turboJSON)
+	var warnings *Warnings
+	if err != nil && !errors.As(err, &warnings) {
+		t.Fatalf("failed to build package graph: %v", err)
+	}
+	if _, ok := c.PackageInfos["web"]; !ok {
+		t.Error("expected web in the package graph")
+	}
+	if got := turboJSON.Pipeline["web#build"].Outputs.Inclusions; len(got) != 1 || got[0] != ".next/**" {
+		t.Errorf("web#build outputs got %v, want the workspace config's definition", got)
+	}
+	if _, ok := turboJSON.Pipeline["docs#build"]; ok {
+		t.Error("expected no package-task for docs, which has no workspace config")
+	}
+}
+
+func Test_BuildPackageGraphWithConfigInvalid(t *testing.T) {
+	repoRoot, rootPackageJSON, turboJSON := writeWorkspaceConfigRepo(t, map[string]interface{}{
+		"extends": []string{"docs"},
+	})
+
+	_, _, err := BuildPackageGraphWithConfig(repoRoot, rootPackageJSON, turboJSON)
+	if err == nil {
+		t.Fatal("expected an error for a workspace config that doesn't extend the root config")
+	}
+	if code := errcode.Of(err); code != errcode.InvalidTurboJSON {
+		t.Errorf("error code got %v, want %v", code, errcode.InvalidTurboJSON)
+	}
+}
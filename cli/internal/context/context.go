@@ -9,6 +9,7 @@ import (
 
 	"github.com/hashicorp/go-multierror"
 	"github.com/vercel/turbo/cli/internal/core"
+	"github.com/vercel/turbo/cli/internal/errcode"
 	"github.com/vercel/turbo/cli/internal/fs"
 	"github.com/vercel/turbo/cli/internal/lockfile"
 	"github.com/vercel/turbo/cli/internal/packagemanager"
@@ -33,6 +34,16 @@ func (w *Warnings) Error() string {
 	return w.warns.Error()
 }
 
+// Errors returns the individual warnings
+func (w *Warnings) Errors() []error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.warns == nil {
+		return nil
+	}
+	return w.warns.Errors
+}
+
 func (w *Warnings) errorOrNil() error {
 	if w.warns != nil {
 		return w
@@ -55,6 +66,9 @@ type Context struct {
 	RootNode         string
 	Lockfile         lockfile.Lockfile
 	PackageManager   *packagemanager.PackageManager
+	// WorkspacePackageManagers is only populated for a federation of monorepos,
+	// where each workspace is managed by the package manager of its own root
+	WorkspacePackageManagers map[string]*packagemanager.PackageManager
 	// Used to arbitrate access to the graph. We parallelise most build operations
 	// and Go maps aren't natively threadsafe so this is needed.
 	mutex sync.Mutex
@@ -283,10 +297,14 @@ func (c *Context) populateTopologicGraphForPackageJSON(pkg *fs.PackageJSON, root
 
 	// split out internal vs. external deps
 	for depName, depVersion := range depMap {
-		if item, ok := c.PackageInfos[depName]; ok && isWorkspaceReference(item.Version, depVersion, pkg.Dir.ToStringDuringMigration(), rootpath) {
+		item, isWorkspace := c.PackageInfos[depName]
+		if isWorkspace && isWorkspaceReference(item.Version, depVersion, pkg.Dir.ToStringDuringMigration(), rootpath) {
 			internalDepsSet.Add(depName)
 			c.TopologicalGraph.Connect(dag.BasicEdge(vertexName, depName))
 		} else {
+			if protocol, _ := parseDependencyProtocol(depVersion); isWorkspace && !isProtocolExternal(protocol) {
+				warnings.append(errcode.NewWarning(errcode.ImplicitDependency, fmt.Errorf("%v depends on %v@%v, which workspace %v (%v) does not satisfy, so it is resolved from the registry instead", vertexName, depName, depVersion, depName, item.Version)))
+			}
 			externalUnresolvedDepsSet.Add(depName)
 		}
 	}
@@ -352,9 +370,15 @@ func (c *Context) parsePackageJSON(repoRoot turbopath.AbsoluteSystemPath, pkgJSO
 		if err != nil {
 			return err
 		}
-		c.TopologicalGraph.Add(pkg.Name)
 		pkg.PackageJSONPath = turbopath.AnchoredSystemPathFromUpstream(relativePkgJSONPath)
 		pkg.Dir = turbopath.AnchoredSystemPathFromUpstream(filepath.Dir(relativePkgJSONPath))
+		if other, ok := c.PackageInfos[pkg.Name]; ok {
+			// package.json files are parsed concurrently, so sort the paths to keep the error stable
+			dirs := []string{other.Dir.ToUnixPath().ToString(), pkg.Dir.ToUnixPath().ToString()}
+			sort.Strings(dirs)
+			return fmt.Errorf("workspace name %q is used by both %v and %v. Workspace names must be unique", pkg.Name, dirs[0], dirs[1])
+		}
+		c.TopologicalGraph.Add(pkg.Name)
 		c.PackageInfos[pkg.Name] = pkg
 		c.PackageNames = append(c.PackageNames, pkg.Name)
 	}
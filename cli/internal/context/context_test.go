@@ -1,8 +1,12 @@
 package context
 
 import (
+	"os"
 	"path/filepath"
 	"testing"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbopath"
 )
 
 func Test_isWorkspaceReference(t *testing.T) {
@@ -121,3 +125,36 @@ func Test_isWorkspaceReference(t *testing.T) {
 		})
 	}
 }
+
+func Test_BuildPackageGraphDuplicateNames(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"package.json":             `{"name": "duplicates", "packageManager": "npm@8.19.2", "workspaces": ["apps/*", "packages/*"]}`,
+		"package-lock.json":        `{"lockfileVersion": 2, "packages": {}}`,
+		"apps/ui/package.json":     `{"name": "ui"}`,
+		"packages/ui/package.json": `{"name": "ui"}`,
+	}
+	for name, contents := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create directory for %v: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatalf("failed to write %v: %v", name, err)
+		}
+	}
+	repoRoot := turbopath.AbsoluteSystemPathFromUpstream(root)
+	rootPackageJSON, err := fs.ReadPackageJSON(repoRoot.UntypedJoin("package.json"))
+	if err != nil {
+		t.Fatalf("failed to read package.json: %v", err)
+	}
+
+	_, err = BuildPackageGraph(repoRoot, rootPackageJSON)
+	if err == nil {
+		t.Fatal("expected an error for duplicate workspace names")
+	}
+	want := `workspace name "ui" is used by both apps/ui and packages/ui. Workspace names must be unique`
+	if err.Error() != want {
+		t.Errorf("error got %v, want %v", err, want)
+	}
+}
@@ -0,0 +1,196 @@
+package context
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/pyr-sh/dag"
+	"github.com/vercel/turbo/cli/internal/core"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/packagemanager"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// BuildFederatedPackageGraph constructs a single Context from several monorepos that live
+// in one repository, as listed in the "roots" key of the top-level turbo.json. Each root
+// keeps its own turbo.json, package manager, and lockfile. Workspaces may depend on
+// workspaces in other roots, and the pipeline of each root is applied only to its own
+// workspaces, by rewriting its tasks into package-tasks. The returned TurboJSON holds
+// the combined configuration.
+func BuildFederatedPackageGraph(repoRoot turbopath.AbsoluteSystemPath, rootPackageJSON *fs.PackageJSON, topLevel *fs.TurboJSON) (*Context, *fs.TurboJSON, error) {
+	c := &Context{
+		PackageInfos:             make(map[interface{}]*fs.PackageJSON),
+		RootNode:                 core.ROOT_NODE_NAME,
+		WorkspacePackageManagers: make(map[string]*packagemanager.PackageManager),
+	}
+	turboJSON := &fs.TurboJSON{
+		GlobalDeps:         append([]string{}, topLevel.GlobalDeps...),
+		GlobalEnv:          append([]string{}, topLevel.GlobalEnv...),
+		Pipeline:           make(fs.Pipeline),
+		RemoteCacheOptions: topLevel.RemoteCacheOptions,
+		Roots:              topLevel.Roots,
+		Defaults:           topLevel.Defaults,
+		HashExclude:        append([]string{}, topLevel.HashExclude...),
+	}
+	var warnings Warnings
+	// pkgRoots maps each workspace to the root that contains it
+	pkgRoots := make(map[string]string)
+
+	for _, root := range topLevel.Roots {
+		rootPath := repoRoot.UntypedJoin(root)
+		subRootPackageJSON, err := fs.ReadPackageJSON(rootPath.UntypedJoin("package.json"))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read package.json for root %v: %w", root, err)
+		}
+		subTurboJSON, err := fs.LoadTurboConfig(rootPath, subRootPackageJSON, false)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read turbo.json for root %v: %w", root, err)
+		}
+		sub, err := BuildPackageGraph(rootPath, subRootPackageJSON)
+		if err != nil {
+			if subWarnings, ok := err.(*Warnings); ok {
+				warnings.append(fmt.Errorf("%v: %w", root, subWarnings))
+			} else {
+				return nil, nil, fmt.Errorf("failed to build package graph for root %v: %w", root, err)
+			}
+		}
+		if c.PackageManager == nil {
+			c.PackageManager = sub.PackageManager
+		}
+
+		for _, name := range sub.PackageNames {
+			if otherRoot, ok := pkgRoots[name]; ok {
+				return nil, nil, fmt.Errorf("workspace %v is defined in both %v and %v", name, otherRoot, root)
+			}
+			pkg := sub.PackageInfos[name]
+			pkg.Dir = turbopath.AnchoredSystemPath(filepath.Join(root, pkg.Dir.ToString()))
+			pkg.PackageJSONPath = turbopath.AnchoredSystemPath(filepath.Join(root, pkg.PackageJSONPath.ToString()))
+			pkgRoots[name] = root
+			c.PackageInfos[name] = pkg
+			c.PackageNames = append(c.PackageNames, name)
+			c.WorkspacePackageManagers[name] = sub.PackageManager
+		}
+		for _, edge := range sub.TopologicalGraph.Edges() {
+			source := dag.VertexName(edge.Source())
+			if source == util.RootPkgName {
+				continue
+			}
+			c.TopologicalGraph.Connect(dag.BasicEdge(source, dag.VertexName(edge.Target())))
+		}
+		for _, name := range sub.PackageNames {
+			c.TopologicalGraph.Add(name)
+		}
+
+		for _, dep := range subTurboJSON.GlobalDeps {
+			turboJSON.GlobalDeps = append(turboJSON.GlobalDeps, filepath.ToSlash(filepath.Join(root, dep)))
+		}
+		for _, pattern := range subTurboJSON.HashExclude {
+			turboJSON.HashExclude = append(turboJSON.HashExclude, filepath.ToSlash(filepath.Join(root, pattern)))
+		}
+		turboJSON.GlobalEnv = append(turboJSON.GlobalEnv, subTurboJSON.GlobalEnv...)
+		if err := subTurboJSON.ApplyWorkspaceConfigs(sub.PackageInfos); err != nil {
+			return nil, nil, err
+		}
+		if err := federatePipeline(turboJSON.Pipeline, subTurboJSON.Pipeline, sub.PackageNames, root); err != nil {
+			return nil, nil, err
+		}
+	}
+	if c.PackageManager == nil {
+		return nil, nil, fmt.Errorf("at least one root is required")
+	}
+
+	c.connectCrossRootDependencies(pkgRoots, repoRoot.ToStringDuringMigration())
+
+	// The top-level package.json is the root package of the federation, and generic
+	// tasks in the top-level turbo.json apply wherever a root does not define them
+	for taskName, taskDefinition := range topLevel.Pipeline {
+		if _, ok := turboJSON.Pipeline[taskName]; !ok || !util.IsPackageTask(taskName) {
+			turboJSON.Pipeline[taskName] = taskDefinition
+		}
+	}
+	if err := c.resolveWorkspaceRootDeps(rootPackageJSON, &warnings); err != nil {
+		return nil, nil, fmt.Errorf("could not resolve workspaces: %w", err)
+	}
+	c.PackageInfos[util.RootPkgName] = rootPackageJSON
+	c.TopologicalGraph.Connect(dag.BasicEdge(util.RootPkgName, core.ROOT_NODE_NAME))
+
+	sort.Strings(c.PackageNames)
+	turboJSON.GlobalEnv = util.SetFromStrings(turboJSON.GlobalEnv).UnsafeListOfStrings()
+	sort.Strings(turboJSON.GlobalEnv)
+	return c, turboJSON, warnings.errorOrNil()
+}
+
+// federatePipeline adds the tasks of a root's pipeline to the federated pipeline
+// as package-tasks for each of the root's workspaces. The first root to define a
+// generic task also provides its fallback definition, which the engine requires
+// to resolve dependencies on the root node.
+func federatePipeline(federated fs.Pipeline, pipeline fs.Pipeline, pkgNames []string, root string) error {
+	pkgs := util.SetFromStrings(pkgNames)
+	for taskID, taskDefinition := range pipeline {
+		if !util.IsPackageTask(taskID) {
+			continue
+		}
+		pkg, _ := util.GetPackageTaskFromId(taskID)
+		if pkg == util.RootPkgName {
+			// Root tasks of individual roots have no equivalent in the federation
+			continue
+		}
+		if !pkgs.Includes(pkg) {
+			return fmt.Errorf("%v in the turbo.json of %v refers to a workspace outside of that root", taskID, root)
+		}
+		federated[taskID] = taskDefinition
+	}
+	for taskName, taskDefinition := range pipeline {
+		if util.IsPackageTask(taskName) {
+			continue
+		}
+		if _, ok := federated[taskName]; !ok {
+			federated[taskName] = taskDefinition
+		}
+		for _, pkg := range pkgNames {
+			taskID := util.GetTaskId(pkg, taskName)
+			if _, ok := pipeline[taskID]; !ok {
+				federated[taskID] = taskDefinition
+			}
+		}
+	}
+	return nil
+}
+
+// connectCrossRootDependencies adds edges for dependencies on workspaces that live in a different root
+func (c *Context) connectCrossRootDependencies(pkgRoots map[string]string, rootpath string) {
+	for name, root := range pkgRoots {
+		pkg := c.PackageInfos[name]
+		depMap := make(map[string]string)
+		for dep, version := range pkg.DevDependencies {
+			depMap[dep] = version
+		}
+		for dep, version := range pkg.OptionalDependencies {
+			depMap[dep] = version
+		}
+		for dep, version := range pkg.Dependencies {
+			depMap[dep] = version
+		}
+		for depName, depVersion := range depMap {
+			depRoot, ok := pkgRoots[depName]
+			if !ok || depRoot == root {
+				continue
+			}
+			item := c.PackageInfos[depName]
+			if !isWorkspaceReference(item.Version, depVersion, pkg.Dir.ToStringDuringMigration(), rootpath) {
+				continue
+			}
+			// a workspace with no internal dependencies is connected directly to the root node
+			for _, edge := range c.TopologicalGraph.EdgesFrom(name) {
+				if edge.Target() == core.ROOT_NODE_NAME {
+					c.TopologicalGraph.RemoveEdge(edge)
+				}
+			}
+			c.TopologicalGraph.Connect(dag.BasicEdge(name, depName))
+			pkg.InternalDeps = append(pkg.InternalDeps, depName)
+		}
+		sort.Strings(pkg.InternalDeps)
+	}
+}
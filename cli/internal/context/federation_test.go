@@ -0,0 +1,102 @@
+package context
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+func writeJSON(t *testing.T, path turbopath.AbsoluteSystemPath, contents interface{}) {
+	t.Helper()
+	bytes, err := json.Marshal(contents)
+	if err != nil {
+		t.Fatalf("failed to marshal %v: %v", path, err)
+	}
+	if err := path.EnsureDir(); err != nil {
+		t.Fatalf("failed to create directory for %v: %v", path, err)
+	}
+	if err := path.WriteFile(bytes, 0644); err != nil {
+		t.Fatalf("failed to write %v: %v", path, err)
+	}
+}
+
+func Test_BuildFederatedPackageGraph(t *testing.T) {
+	repoRoot := turbopath.AbsoluteSystemPathFromUpstream(t.TempDir())
+	writeJSON(t, repoRoot.UntypedJoin("package.json"), map[string]interface{}{"name": "federation"})
+	for _, root := range []string{"libs", "apps"} {
+		writeJSON(t, repoRoot.UntypedJoin(root, "package.json"), map[string]interface{}{
+			"name":           root,
+			"packageManager": "npm@8.19.2",
+			"workspaces":     []string{"packages/*"},
+		})
+		writeJSON(t, repoRoot.UntypedJoin(root, "package-lock.json"), map[string]interface{}{
+			"lockfileVersion": 2,
+			"packages":        map[string]interface{}{},
+		})
+	}
+	writeJSON(t, repoRoot.UntypedJoin("libs", "turbo.json"), map[string]interface{}{
+		"pipeline": map[string]interface{}{
+			"build": map[string]interface{}{"outputs": []string{"lib/**"}},
+		},
+	})
+	writeJSON(t, repoRoot.UntypedJoin("apps", "turbo.json"), map[string]interface{}{
+		"pipeline": map[string]interface{}{
+			"build":     map[string]interface{}{"dependsOn": []string{"^build"}, "outputs": []string{".next/**"}},
+			"web#build": map[string]interface{}{"dependsOn": []string{"^build"}},
+		},
+	})
+	writeJSON(t, repoRoot.UntypedJoin("libs", "packages", "ui", "package.json"), map[string]interface{}{
+		"name":    "ui",
+		"version": "1.0.0",
+	})
+	writeJSON(t, repoRoot.UntypedJoin("apps", "packages", "web", "package.json"), map[string]interface{}{
+		"name":         "web",
+		"dependencies": map[string]string{"ui": "*"},
+	})
+	writeJSON(t, repoRoot.UntypedJoin("apps", "packages", "docs", "package.json"), map[string]interface{}{
+		"name": "docs",
+	})
+
+	rootPackageJSON, err := fs.ReadPackageJSON(repoRoot.UntypedJoin("package.json"))
+	if err != nil {
+		t.Fatalf("failed to read package.json: %v", err)
+	}
+	c, turboJSON, err := BuildFederatedPackageGraph(repoRoot, rootPackageJSON, &fs.TurboJSON{Roots: []string{"libs", "apps"}})
+	var warnings *Warnings
+	if err != nil && !errors.As(err, &warnings) {
+		t.Fatalf("failed to build federated graph: %v", err)
+	}
+
+	if got := c.PackageInfos["web"].Dir.ToUnixPath().ToString(); got != "apps/packages/web" {
+		t.Errorf("web dir got %v, want apps/packages/web", got)
+	}
+	if !c.TopologicalGraph.DownEdges("web").Include("ui") {
+		t.Errorf("expected web to depend on ui across roots, got %v", c.TopologicalGraph.DownEdges("web"))
+	}
+	if c.TopologicalGraph.DownEdges("web").Include(c.RootNode) {
+		t.Error("expected web to no longer be connected to the root node")
+	}
+	if c.WorkspacePackageManagers["ui"] == nil {
+		t.Error("expected a package manager for ui")
+	}
+
+	if got := turboJSON.Pipeline["ui#build"].Outputs.Inclusions; len(got) != 1 || got[0] != "lib/**" {
+		t.Errorf("ui#build outputs got %v, want the libs definition", got)
+	}
+	if got := turboJSON.Pipeline["docs#build"].Outputs.Inclusions; len(got) != 1 || got[0] != ".next/**" {
+		t.Errorf("docs#build outputs got %v, want the apps definition", got)
+	}
+	if got := turboJSON.Pipeline["web#build"].Outputs.Inclusions; len(got) != 2 {
+		t.Errorf("web#build outputs got %v, want the explicit package-task definition", got)
+	}
+	if got := turboJSON.Pipeline["build"].Outputs.Inclusions; len(got) != 1 || got[0] != "lib/**" {
+		t.Errorf("build outputs got %v, want the fallback from the first root", got)
+	}
+	if _, ok := c.PackageInfos[util.RootPkgName]; !ok {
+		t.Error("expected the top-level package.json to be the root package")
+	}
+}
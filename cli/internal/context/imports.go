@@ -0,0 +1,443 @@
+package context
+
+import (
+	"encoding/json"
+	"fmt"
+	iofs "io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pyr-sh/dag"
+	"github.com/vercel/turbo/cli/internal/core"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/util"
+	"golang.org/x/sync/errgroup"
+	"muzzammil.xyz/jsonc"
+)
+
+// UndeclaredImport is an import of one workspace from the source of another, which
+// doesn't list it as a dependency in its package.json
+type UndeclaredImport struct {
+	// From is the workspace with the import
+	From string
+	// To is the workspace that is imported
+	To string
+	// File is the first file that imports To, relative to the repository root
+	File turbopath.AnchoredUnixPath
+	// Specifier is what File imports, such as "@acme/ui/button" or "../../ui/src"
+	Specifier string
+}
+
+// _sourceExtensions are the files that are scanned for imports
+var _sourceExtensions = map[string]bool{
+	".js": true, ".jsx": true, ".mjs": true, ".cjs": true,
+	".ts": true, ".tsx": true, ".mts": true, ".cts": true,
+}
+
+// _skippedDirs aren't scanned for imports: installed packages, and turbo's default
+// outputs, which are built from the sources that are scanned
+var _skippedDirs = map[string]bool{
+	"node_modules": true,
+	"dist":         true,
+	"build":        true,
+}
+
+// _importPattern matches the specifiers of static and dynamic imports, re-exports,
+// and calls to require. It can match in comments and strings, which at worst
+// reports a dependency that the workspace doesn't have.
+var _importPattern = regexp.MustCompile(`(?:\bfrom|\bimport\s*\(?|\brequire\s*\()\s*['"]([^'"\n]+)['"]`)
+
+// FindUndeclaredImports scans the source of each workspace for imports of other
+// workspaces that its package.json doesn't depend on. An import refers to a
+// workspace by its name, by a path into the workspace's directory, or by a path
+// alias from the compilerOptions.paths of the tsconfig.json or jsconfig.json of the
+// importing workspace. An import of a subpath of a workspace by name only counts if
+// the workspace's "exports", if it has any, include the subpath. At most one import
+// is reported for each pair of workspaces.
+func (c *Context) FindUndeclaredImports(repoRoot turbopath.AbsoluteSystemPath) ([]UndeclaredImport, error) {
+	r := &importResolver{
+		repoRoot:   repoRoot,
+		workspaces: make(map[string]*fs.PackageJSON),
+	}
+	for key, pkg := range c.PackageInfos {
+		name, ok := key.(string)
+		if !ok || name == util.RootPkgName {
+			continue
+		}
+		r.workspaces[name] = pkg
+		r.names = append(r.names, name)
+	}
+	sort.Strings(r.names)
+
+	found := make([][]UndeclaredImport, len(r.names))
+	g := &errgroup.Group{}
+	for i, name := range r.names {
+		i, name := i, name
+		g.Go(func() error {
+			imports, err := r.findUndeclaredImports(name)
+			if err != nil {
+				return fmt.Errorf("failed to scan the imports of %v: %w", name, err)
+			}
+			found[i] = imports
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	var undeclared []UndeclaredImport
+	for _, imports := range found {
+		undeclared = append(undeclared, imports...)
+	}
+	return undeclared, nil
+}
+
+// AddImportEdges makes each workspace depend on the workspaces it imports without
+// declaring them, so that their tasks run in the right order
+func (c *Context) AddImportEdges(imports []UndeclaredImport) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, imp := range imports {
+		c.TopologicalGraph.RemoveEdge(dag.BasicEdge(imp.From, core.ROOT_NODE_NAME))
+		c.TopologicalGraph.Connect(dag.BasicEdge(imp.From, imp.To))
+		pkg := c.PackageInfos[imp.From]
+		pkg.InternalDeps = append(pkg.InternalDeps, imp.To)
+		sort.Strings(pkg.InternalDeps)
+	}
+}
+
+type importResolver struct {
+	repoRoot   turbopath.AbsoluteSystemPath
+	workspaces map[string]*fs.PackageJSON
+	names      []string
+}
+
+func (r *importResolver) dir(name string) string {
+	return r.repoRoot.UntypedJoin(r.workspaces[name].Dir.ToString()).ToString()
+}
+
+func (r *importResolver) findUndeclaredImports(name string) ([]UndeclaredImport, error) {
+	pkg := r.workspaces[name]
+	declared := make(util.Set)
+	for _, deps := range []map[string]string{pkg.Dependencies, pkg.DevDependencies, pkg.OptionalDependencies, pkg.PeerDependencies} {
+		for dep := range deps {
+			declared.Add(dep)
+		}
+	}
+	dir := r.dir(name)
+	aliases, err := r.loadPathAliases(dir)
+	if err != nil {
+		return nil, err
+	}
+	files, err := r.sourceFiles(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var undeclared []UndeclaredImport
+	reported := make(util.Set)
+	for _, file := range files {
+		contents, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range _importPattern.FindAllSubmatch(contents, -1) {
+			specifier := string(match[1])
+			target := r.resolve(specifier, filepath.Dir(file), aliases)
+			if target == "" || target == name || declared.Includes(target) || reported.Includes(target) {
+				continue
+			}
+			reported.Add(target)
+			relative, err := filepath.Rel(r.repoRoot.ToString(), file)
+			if err != nil {
+				return nil, err
+			}
+			undeclared = append(undeclared, UndeclaredImport{
+				From:      name,
+				To:        target,
+				File:      turbopath.AnchoredSystemPath(relative).ToUnixPath(),
+				Specifier: specifier,
+			})
+		}
+	}
+	sort.Slice(undeclared, func(i, j int) bool { return undeclared[i].To < undeclared[j].To })
+	return undeclared, nil
+}
+
+// sourceFiles lists the source files of a workspace, leaving out the workspaces
+// nested inside it
+func (r *importResolver) sourceFiles(name string) ([]string, error) {
+	root := r.dir(name)
+	nested := make(util.Set)
+	for _, other := range r.names {
+		if dir := r.dir(other); other != name && isWithin(root, dir) {
+			nested.Add(dir)
+		}
+	}
+	var files []string
+	err := filepath.WalkDir(root, func(path string, entry iofs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if path != root && (_skippedDirs[entry.Name()] || strings.HasPrefix(entry.Name(), ".") || nested.Includes(path)) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if _sourceExtensions[filepath.Ext(path)] && !strings.HasSuffix(path, ".d.ts") {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// resolve returns the workspace that specifier refers to, or "" if it refers to
+// none, such as a package from the registry or a file in the same workspace
+func (r *importResolver) resolve(specifier string, fromDir string, aliases *pathAliases) string {
+	if strings.HasPrefix(specifier, "./") || strings.HasPrefix(specifier, "../") {
+		return r.workspaceContaining(filepath.Join(fromDir, filepath.FromSlash(specifier)))
+	}
+	if target, ok := aliases.resolve(specifier); ok {
+		return r.workspaceContaining(target)
+	}
+	name, subpath := splitPackageSpecifier(specifier)
+	pkg, ok := r.workspaces[name]
+	if !ok || !isExported(pkg.RawJSON["exports"], subpath) {
+		return ""
+	}
+	return name
+}
+
+// workspaceContaining returns the workspace with the most specific directory that
+// contains path
+func (r *importResolver) workspaceContaining(path string) string {
+	found := ""
+	foundDir := ""
+	for _, name := range r.names {
+		dir := r.dir(name)
+		if isWithin(dir, path) && len(dir) > len(foundDir) {
+			found, foundDir = name, dir
+		}
+	}
+	return found
+}
+
+func isWithin(dir string, path string) bool {
+	relative, err := filepath.Rel(dir, path)
+	return err == nil && relative != ".." && !strings.HasPrefix(relative, ".."+string(filepath.Separator))
+}
+
+// splitPackageSpecifier splits "@acme/ui/button" into "@acme/ui" and "./button"
+func splitPackageSpecifier(specifier string) (string, string) {
+	parts := strings.SplitN(specifier, "/", 3)
+	count := 1
+	if strings.HasPrefix(specifier, "@") {
+		count = 2
+	}
+	if len(parts) <= count {
+		return specifier, "."
+	}
+	return strings.Join(parts[:count], "/"), "./" + strings.Join(parts[count:], "/")
+}
+
+// isExported returns true if the "exports" of a package.json include subpath, which
+// is "." for the package itself or starts with "./". A package without exports
+// exports every subpath.
+func isExported(exports interface{}, subpath string) bool {
+	conditions, ok := exports.(map[string]interface{})
+	if exports == nil {
+		return true
+	} else if !ok {
+		// A single export for the package itself
+		return subpath == "."
+	}
+	hasSubpaths := false
+	for key := range conditions {
+		if strings.HasPrefix(key, ".") {
+			hasSubpaths = true
+			break
+		}
+	}
+	if !hasSubpaths {
+		// Conditions, such as "import" and "require", for the package itself
+		return subpath == "."
+	}
+	for key, target := range conditions {
+		if target == nil {
+			continue
+		}
+		if key == subpath {
+			return true
+		}
+		if prefix, suffix, ok := strings.Cut(key, "*"); ok && strings.HasPrefix(subpath, prefix) && strings.HasSuffix(subpath[len(prefix):], suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// pathAliases are the compilerOptions.paths of a tsconfig.json or jsconfig.json
+type pathAliases struct {
+	// dir is the directory that the targets of paths are relative to
+	dir   string
+	paths map[string][]string
+}
+
+// resolve returns the path that specifier is an alias for, using the most specific
+// matching pattern, as TypeScript does
+func (pa *pathAliases) resolve(specifier string) (string, bool) {
+	if pa == nil {
+		return "", false
+	}
+	bestPattern := ""
+	bestMatch := ""
+	found := false
+	for pattern, targets := range pa.paths {
+		if len(targets) == 0 {
+			continue
+		}
+		match := ""
+		if prefix, suffix, ok := strings.Cut(pattern, "*"); ok {
+			if !strings.HasPrefix(specifier, prefix) || !strings.HasSuffix(specifier, suffix) || len(specifier) < len(prefix)+len(suffix) {
+				continue
+			}
+			match = specifier[len(prefix) : len(specifier)-len(suffix)]
+		} else if pattern != specifier {
+			continue
+		}
+		if !found || len(pattern) > len(bestPattern) {
+			bestPattern, bestMatch, found = pattern, match, true
+		}
+	}
+	if !found {
+		return "", false
+	}
+	target := strings.Replace(pa.paths[bestPattern][0], "*", bestMatch, 1)
+	return filepath.Join(pa.dir, filepath.FromSlash(target)), true
+}
+
+type tsconfig struct {
+	Extends         interface{} `json:"extends"`
+	CompilerOptions struct {
+		BaseURL *string             `json:"baseUrl"`
+		Paths   map[string][]string `json:"paths"`
+	} `json:"compilerOptions"`
+}
+
+// loadPathAliases reads the path aliases of the workspace in dir from its
+// tsconfig.json, or else its jsconfig.json, following "extends"
+func (r *importResolver) loadPathAliases(dir string) (*pathAliases, error) {
+	for _, name := range []string{"tsconfig.json", "jsconfig.json"} {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			aliases, _, err := r.readPathAliases(path, 0)
+			return aliases, err
+		}
+	}
+	return nil, nil
+}
+
+// _trailingCommaPattern matches the trailing commas that tsconfig.json files allow.
+// It can match inside a string, which is unlikely in the options that matter here.
+var _trailingCommaPattern = regexp.MustCompile(`,(\s*[}\]])`)
+
+// _maxExtendsDepth guards against a cycle of tsconfig.json files that extend each other
+const _maxExtendsDepth = 10
+
+// readPathAliases returns the path aliases of a tsconfig.json and the baseUrl that
+// it sets, if any. The paths and baseUrl of a config override those it extends.
+// The targets of paths are relative to baseUrl, or else to the config that sets
+// paths.
+func (r *importResolver) readPathAliases(path string, depth int) (*pathAliases, string, error) {
+	if depth > _maxExtendsDepth {
+		return nil, "", fmt.Errorf("too many levels of \"extends\"")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", err
+	}
+	var config tsconfig
+	if err := json.Unmarshal(_trailingCommaPattern.ReplaceAll(jsonc.ToJSON(data), []byte("$1")), &config); err != nil {
+		return nil, "", fmt.Errorf("failed to read %v: %w", path, err)
+	}
+	dir := filepath.Dir(path)
+
+	var aliases *pathAliases
+	baseURL := ""
+	var extends []string
+	switch value := config.Extends.(type) {
+	case string:
+		extends = []string{value}
+	case []interface{}:
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				extends = append(extends, s)
+			}
+		}
+	}
+	// Later configs in an array of extends override earlier ones
+	for _, extend := range extends {
+		extendedPath := r.resolveExtends(extend, dir)
+		if extendedPath == "" {
+			continue
+		}
+		extendedAliases, extendedBaseURL, err := r.readPathAliases(extendedPath, depth+1)
+		if err != nil {
+			return nil, "", err
+		}
+		if extendedAliases != nil {
+			aliases = extendedAliases
+		}
+		if extendedBaseURL != "" {
+			baseURL = extendedBaseURL
+		}
+	}
+
+	if config.CompilerOptions.BaseURL != nil {
+		baseURL = filepath.Join(dir, filepath.FromSlash(*config.CompilerOptions.BaseURL))
+	}
+	if config.CompilerOptions.Paths != nil {
+		aliases = &pathAliases{dir: dir, paths: config.CompilerOptions.Paths}
+	}
+	if aliases != nil && baseURL != "" {
+		aliases = &pathAliases{dir: baseURL, paths: aliases.paths}
+	}
+	return aliases, baseURL, nil
+}
+
+// resolveExtends returns the path of a config that another extends, which is either
+// relative to the config that extends it, or in a package, or "" if it can't be found
+func (r *importResolver) resolveExtends(extends string, dir string) string {
+	candidates := []string{}
+	if strings.HasPrefix(extends, ".") || filepath.IsAbs(extends) {
+		candidates = append(candidates, filepath.Join(dir, filepath.FromSlash(extends)))
+	} else {
+		name, subpath := splitPackageSpecifier(extends)
+		if subpath == "." {
+			subpath = "tsconfig.json"
+		}
+		if _, ok := r.workspaces[name]; ok {
+			candidates = append(candidates, filepath.Join(r.dir(name), filepath.FromSlash(subpath)))
+		}
+		for d := dir; isWithin(r.repoRoot.ToString(), d); d = filepath.Dir(d) {
+			candidates = append(candidates, filepath.Join(d, "node_modules", name, filepath.FromSlash(subpath)))
+			if d == r.repoRoot.ToString() {
+				break
+			}
+		}
+	}
+	for _, candidate := range candidates {
+		for _, path := range []string{candidate, candidate + ".json"} {
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				return path
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,114 @@
+package context
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"gotest.tools/v3/assert"
+)
+
+func writeSource(t *testing.T, path turbopath.AbsoluteSystemPath, contents string) {
+	t.Helper()
+	assert.NilError(t, path.EnsureDir())
+	assert.NilError(t, path.WriteFile([]byte(contents), 0644))
+}
+
+func Test_FindUndeclaredImports(t *testing.T) {
+	repoRoot := turbopath.AbsoluteSystemPathFromUpstream(t.TempDir())
+	writeJSON(t, repoRoot.UntypedJoin("package.json"), map[string]interface{}{
+		"name":           "monorepo",
+		"packageManager": "npm@8.19.2",
+		"workspaces":     []string{"apps/*", "packages/*"},
+	})
+	writeJSON(t, repoRoot.UntypedJoin("package-lock.json"), map[string]interface{}{
+		"lockfileVersion": 2,
+		"packages":        map[string]interface{}{},
+	})
+	writeJSON(t, repoRoot.UntypedJoin("packages", "ui", "package.json"), map[string]interface{}{
+		"name":    "@acme/ui",
+		"exports": map[string]string{".": "./src/index.ts", "./button": "./src/button.ts"},
+	})
+	writeJSON(t, repoRoot.UntypedJoin("packages", "utils", "package.json"), map[string]interface{}{"name": "utils"})
+	writeJSON(t, repoRoot.UntypedJoin("packages", "icons", "package.json"), map[string]interface{}{"name": "icons"})
+	writeJSON(t, repoRoot.UntypedJoin("packages", "tsconfig", "package.json"), map[string]interface{}{"name": "@acme/tsconfig"})
+	writeSource(t, repoRoot.UntypedJoin("packages", "tsconfig", "base.json"), `{
+		// Targets are relative to this file, since it doesn't set baseUrl
+		"compilerOptions": {
+			"strict": true,
+			"paths": { "@icons/*": ["../icons/src/*"] },
+		},
+	}`)
+	writeJSON(t, repoRoot.UntypedJoin("apps", "docs", "package.json"), map[string]interface{}{"name": "docs"})
+	writeJSON(t, repoRoot.UntypedJoin("apps", "web", "package.json"), map[string]interface{}{
+		"name":            "web",
+		"dependencies":    map[string]string{"@acme/ui": "*"},
+		"devDependencies": map[string]string{"@acme/tsconfig": "*"},
+	})
+	writeSource(t, repoRoot.UntypedJoin("apps", "web", "tsconfig.json"), `{ "extends": "@acme/tsconfig/base.json" }`)
+	writeSource(t, repoRoot.UntypedJoin("apps", "web", "src", "app.tsx"), `
+import { Button } from "@acme/ui/button";
+import { capitalize } from 'utils/strings';
+import Star from "@icons/star";
+import React from "react";
+import "./styles";
+const shared = await import("../../docs/src/shared");
+`)
+	// Outputs aren't scanned
+	writeSource(t, repoRoot.UntypedJoin("apps", "web", "dist", "app.js"), `require("@acme/tsconfig-unused")`)
+	writeJSON(t, repoRoot.UntypedJoin("apps", "admin", "package.json"), map[string]interface{}{"name": "admin"})
+	writeSource(t, repoRoot.UntypedJoin("apps", "admin", "index.js"), `
+// Not exported, so not an import of @acme/ui
+const internal = require("@acme/ui/internal");
+export { Button } from "@acme/ui";
+`)
+
+	rootPackageJSON, err := fs.ReadPackageJSON(repoRoot.UntypedJoin("package.json"))
+	assert.NilError(t, err, "ReadPackageJSON")
+	c, err := BuildPackageGraph(repoRoot, rootPackageJSON)
+	var warnings *Warnings
+	if err != nil && !errors.As(err, &warnings) {
+		t.Fatalf("failed to build package graph: %v", err)
+	}
+
+	imports, err := c.FindUndeclaredImports(repoRoot)
+	assert.NilError(t, err, "FindUndeclaredImports")
+	assert.DeepEqual(t, imports, []UndeclaredImport{
+		{From: "admin", To: "@acme/ui", File: "apps/admin/index.js", Specifier: "@acme/ui"},
+		{From: "web", To: "docs", File: "apps/web/src/app.tsx", Specifier: "../../docs/src/shared"},
+		{From: "web", To: "icons", File: "apps/web/src/app.tsx", Specifier: "@icons/star"},
+		{From: "web", To: "utils", File: "apps/web/src/app.tsx", Specifier: "utils/strings"},
+	})
+
+	assert.Assert(t, !c.TopologicalGraph.DownEdges("web").Include("utils"))
+	c.AddImportEdges(imports)
+	for _, dep := range []string{"@acme/ui", "docs", "icons", "utils"} {
+		assert.Assert(t, c.TopologicalGraph.DownEdges("web").Include(dep) || dep == "@acme/ui" && c.TopologicalGraph.DownEdges("admin").Include(dep), dep)
+	}
+	assert.Assert(t, !c.TopologicalGraph.DownEdges("admin").Include(c.RootNode))
+	assert.DeepEqual(t, c.PackageInfos["web"].InternalDeps, []string{"@acme/tsconfig", "@acme/ui", "docs", "icons", "utils"})
+}
+
+func Test_isExported(t *testing.T) {
+	exports := map[string]interface{}{
+		".":           "./index.js",
+		"./button":    map[string]interface{}{"import": "./button.mjs"},
+		"./icons/*":   "./icons/*.js",
+		"./private/*": nil,
+	}
+	cases := map[string]bool{
+		".":             true,
+		"./button":      true,
+		"./icons/star":  true,
+		"./private/key": false,
+		"./internal":    false,
+	}
+	for subpath, expected := range cases {
+		assert.Equal(t, isExported(exports, subpath), expected, subpath)
+	}
+	assert.Assert(t, isExported(nil, "./anything"))
+	assert.Assert(t, isExported("./index.js", "."))
+	assert.Assert(t, !isExported("./index.js", "./button"))
+	assert.Assert(t, isExported(map[string]interface{}{"import": "./index.mjs"}, "."))
+}
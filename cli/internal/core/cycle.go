@@ -0,0 +1,85 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pyr-sh/dag"
+)
+
+// checkCycles returns an error describing a cycle in the task graph, if there is
+// one, that lists each task in the cycle and the pipeline entry behind each of its
+// dependencies
+func (e *Engine) checkCycles() error {
+	cycle := e.findCycle()
+	if cycle == nil {
+		return nil
+	}
+	lines := []string{fmt.Sprintf("cyclic dependency detected: %v", strings.Join(cycle, " -> "))}
+	for i := 0; i < len(cycle)-1; i++ {
+		line := fmt.Sprintf("\t%v -> %v", cycle[i], cycle[i+1])
+		if source, ok := e.depSources[cycle[i]][cycle[i+1]]; ok {
+			line += ": " + source
+		}
+		lines = append(lines, line)
+	}
+	return fmt.Errorf("%v", strings.Join(lines, "\n"))
+}
+
+// findCycle returns the task IDs of a cycle in the task graph, in the order that
+// they depend on each other and starting and ending with the same task, or nil if
+// there is none. Tasks are visited in order, so that the same cycle is reported
+// for the same graph.
+func (e *Engine) findCycle() []string {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	states := make(map[string]int)
+	var path []string
+	var visit func(taskID string) []string
+	visit = func(taskID string) []string {
+		states[taskID] = visiting
+		path = append(path, taskID)
+		for _, depID := range sortedVertexNames(e.TaskGraph.DownEdges(taskID).List()...) {
+			switch states[depID] {
+			case visiting:
+				for i, pathID := range path {
+					if pathID == depID {
+						return append(append([]string{}, path[i:]...), depID)
+					}
+				}
+			case unvisited:
+				if cycle := visit(depID); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		states[taskID] = visited
+		return nil
+	}
+	var vertices []interface{}
+	for _, v := range e.TaskGraph.Vertices() {
+		vertices = append(vertices, v)
+	}
+	for _, taskID := range sortedVertexNames(vertices...) {
+		if states[taskID] == unvisited {
+			if cycle := visit(taskID); cycle != nil {
+				return cycle
+			}
+		}
+	}
+	return nil
+}
+
+func sortedVertexNames(vertices ...interface{}) []string {
+	names := make([]string, len(vertices))
+	for i, v := range vertices {
+		names[i] = dag.VertexName(v)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -1,9 +1,15 @@
 package core
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/vercel/turbo/cli/internal/process"
 	"github.com/vercel/turbo/cli/internal/util"
 
 	"github.com/pyr-sh/dag"
@@ -17,6 +23,25 @@ type Task struct {
 	Deps util.Set
 	// TopoDeps are dependencies across packages within the same topological graph (e.g. parent `build` -> child `build`) */
 	TopoDeps util.Set
+	// ConcurrencyGroup names a group of tasks that share MaxParallel. If it is empty,
+	// the group is the task's Name.
+	ConcurrencyGroup string
+	// MaxParallel is the most tasks in the concurrency group that run at once, in
+	// addition to the limit of the walk. Zero means there is no limit.
+	MaxParallel int
+	// Persistent tasks, such as dev servers, never complete
+	Persistent bool
+	// OrderOnlyDeps are tasks within the same package that this task runs after, if
+	// they run, without depending on them
+	OrderOnlyDeps util.Set
+	// OrderOnlyTopoDeps are tasks in the package's dependencies that this task runs
+	// after, if they run, without depending on them
+	OrderOnlyTopoDeps util.Set
+	// Retries is how many more times the task is visited after it fails, before the
+	// failure is recorded
+	Retries int
+	// RetryDelay is how long to wait before each retry
+	RetryDelay time.Duration
 }
 
 type Visitor = func(taskID string) error
@@ -28,19 +53,29 @@ type Engine struct {
 	// TaskGraph is a graph of package-tasks
 	TaskGraph *dag.AcyclicGraph
 	// Tasks are a map of tasks in the engine
-	Tasks            map[string]*Task
+	Tasks map[string]*Task
+	// OrderOnlyDeps maps tasks in TaskGraph to the tasks in TaskGraph that they run
+	// after without depending on them. Unlike the edges of TaskGraph, these don't
+	// bring tasks into the graph, and a failure of the earlier task doesn't stop
+	// the later one.
+	OrderOnlyDeps    map[string]util.Set
 	PackageTaskDeps  [][]string
 	rootEnabledTasks util.Set
+	// depSources describes, for each edge of TaskGraph, the entry in the pipeline
+	// that introduced it, keyed by the dependent task and then by its dependency
+	depSources map[string]map[string]string
 }
 
 // NewEngine creates a new engine given a topologic graph of workspace package names
 func NewEngine(topologicalGraph *dag.AcyclicGraph) *Engine {
 	return &Engine{
 		Tasks:            make(map[string]*Task),
+		OrderOnlyDeps:    make(map[string]util.Set),
 		TopologicGraph:   topologicalGraph,
 		TaskGraph:        &dag.AcyclicGraph{},
 		PackageTaskDeps:  [][]string{},
 		rootEnabledTasks: make(util.Set),
+		depSources:       make(map[string]map[string]string),
 	}
 }
 
@@ -48,7 +83,8 @@ func NewEngine(topologicalGraph *dag.AcyclicGraph) *Engine {
 type EngineExecutionOptions struct {
 	// Packages in the execution scope, if nil, all packages will be considered in scope
 	Packages []string
-	// TaskNames in the execution scope, if nil, all tasks will be executed
+	// TaskNames in the execution scope, if nil, all tasks will be executed. A root
+	// task ID, such as //#build, runs that task in only the root package.
 	TaskNames []string
 	// Restrict execution to only the listed task names
 	TasksOnly bool
@@ -69,32 +105,724 @@ func (e *Engine) Prepare(options *EngineExecutionOptions) error {
 		return err
 	}
 
-	return nil
+	return e.addOrderOnlyDeps()
+}
+
+// PrepareAffected returns an engine for re-executing part of e's task graph, such
+// as after files changed. Its task graph holds the given tasks, the tasks that
+// depend on them, and the edges between those. The rest of e's tasks are left out,
+// as though they had already run, and e itself is unchanged.
+func (e *Engine) PrepareAffected(taskIDs util.Set) (*Engine, error) {
+	affected := make(util.Set)
+	for _, taskID := range taskIDs.UnsafeListOfStrings() {
+		if taskID == ROOT_NODE_NAME || !e.TaskGraph.HasVertex(taskID) {
+			continue
+		}
+		affected.Add(taskID)
+		dependents, err := e.TaskGraph.Descendents(taskID)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range dependents.List() {
+			affected.Add(dag.VertexName(v))
+		}
+	}
+	next := &Engine{
+		Tasks:            e.Tasks,
+		OrderOnlyDeps:    make(map[string]util.Set),
+		TopologicGraph:   e.TopologicGraph,
+		TaskGraph:        &dag.AcyclicGraph{},
+		PackageTaskDeps:  e.PackageTaskDeps,
+		rootEnabledTasks: e.rootEnabledTasks,
+		depSources:       make(map[string]map[string]string),
+	}
+	for _, taskID := range affected.UnsafeListOfStrings() {
+		hasDeps := false
+		for _, dep := range e.TaskGraph.DownEdges(taskID).List() {
+			depID := dag.VertexName(dep)
+			if affected.Includes(depID) {
+				next.addDependency(taskID, depID, e.depSources[taskID][depID])
+				hasDeps = true
+			}
+		}
+		// Dependencies that were left out are done, so the task can start right away
+		if !hasDeps {
+			next.TaskGraph.Add(ROOT_NODE_NAME)
+			next.TaskGraph.Add(taskID)
+			next.TaskGraph.Connect(dag.BasicEdge(taskID, ROOT_NODE_NAME))
+		}
+		if depIDs, ok := e.OrderOnlyDeps[taskID]; ok {
+			if depIDs := depIDs.Filter(affected.Includes); depIDs.Len() > 0 {
+				next.OrderOnlyDeps[taskID] = depIDs
+			}
+		}
+	}
+	return next, nil
 }
 
 // ExecOpts controls a single walk of the task graph
 type ExecOpts struct {
+	// Context, if set, stops the walk from starting any more tasks once it is done.
+	// Tasks that have already started are left to the visitor.
+	Context context.Context
+	// ContinueOnError keeps starting tasks that don't depend on a failed task, so
+	// that every failure is reported. Otherwise no more tasks start once one fails.
+	ContinueOnError bool
+	// MaxFailures, if set along with ContinueOnError, stops the walk from starting
+	// any more tasks once this many tasks have failed. Tasks that have already
+	// started are left to finish.
+	MaxFailures int
 	// Parallel is whether to run tasks in parallel
 	Parallel bool
 	// Concurrency is the number of concurrent tasks that can be executed
 	Concurrency int
+	// Semaphore, if set, is used to limit concurrency instead of a new semaphore
+	// of size Concurrency, so that work outside of this walk can share its slots
+	Semaphore util.Semaphore
+	// OutputDeps holds, for each task, the dependencies that it only needs some of
+	// the outputs of, rather than needing them to complete
+	OutputDeps map[string]util.Set
+	// Ready, if set, returns a channel that is closed once the outputs of a task
+	// are ready, which may be before it completes, or nil if only completing makes
+	// them ready. Tasks that have the task in OutputDeps start once it is ready.
+	Ready func(taskID string) <-chan struct{}
+	// EstimatedDuration, if set, returns how long a task is expected to take, such
+	// as from previous runs. When more tasks are ready than can run at once, tasks
+	// on the longest chain of dependents start first, and estimates let that chain
+	// be measured in time rather than in tasks.
+	EstimatedDuration func(taskID string) (time.Duration, bool)
+	// Requested, if set, holds the tasks that the user is waiting on, such as those
+	// of the package that a run is filtered to. They, and the tasks they depend on,
+	// start ahead of every other task.
+	Requested util.Set
+	// PersistentOutsideConcurrency stops persistent tasks from taking a slot of the
+	// walk, which they would hold until the walk ends, so that other tasks keep the
+	// full concurrency. Their concurrency group still applies.
+	PersistentOutsideConcurrency bool
+}
+
+// errDependencyFailed is returned for a task whose dependency failed after its
+// outputs were ready. Like the walk does for other failures, the task is skipped
+// without reporting an error of its own.
+var errDependencyFailed = errors.New("dependency failed")
+
+// errStopped is returned for a task that didn't start because another task failed,
+// without ContinueOnError, or because MaxFailures tasks failed. Like
+// errDependencyFailed, it isn't reported.
+var errStopped = errors.New("stopped after a failure")
+
+// errCanceled is returned for a task that didn't start because the walk's context
+// was done. The walk reports this once, with the number of tasks that didn't start.
+var errCanceled = errors.New("canceled")
+
+// TaskStatus is the outcome of a task in a walk of the task graph
+type TaskStatus string
+
+const (
+	// TaskSucceeded is the status of tasks whose visitor returned no error
+	TaskSucceeded TaskStatus = "succeeded"
+	// TaskFailed is the status of tasks whose visitor returned an error
+	TaskFailed TaskStatus = "failed"
+	// TaskSkipped is the status of tasks that were not visited because a dependency
+	// failed, or, without ContinueOnError, because any task failed, or because
+	// MaxFailures tasks failed
+	TaskSkipped TaskStatus = "skipped"
+	// TaskCanceled is the status of tasks that were not visited because the walk's
+	// context was done
+	TaskCanceled TaskStatus = "canceled"
+)
+
+// CacheStatus is whether the outputs of a task were restored from a cache
+type CacheStatus string
+
+const (
+	CacheHit  CacheStatus = "HIT"
+	CacheMiss CacheStatus = "MISS"
+)
+
+// TaskResult describes the execution of a single task in a walk of the task graph
+type TaskResult struct {
+	TaskID    string
+	Status    TaskStatus
+	StartedAt time.Time
+	EndedAt   time.Time
+	Duration  time.Duration
+	// CacheStatus is set by the visitor, and is empty for tasks that don't use the cache
+	CacheStatus CacheStatus
+	// ExitCode is the exit code of the task's process, if it exited with an error
+	ExitCode int
+	Err      error
+	// Attempts is the number of times the task has been visited, which is more
+	// than one if it failed and was retried
+	Attempts int
+	// LastAttempt is set while the task is visited for the last time, such that a
+	// failure will be recorded rather than retried
+	LastAttempt bool
+	// BlockedBy lists, for a task skipped because of failed dependencies, its direct
+	// dependencies that failed or were skipped because of failures themselves
+	BlockedBy []string
+	// RootCauses lists, for a task skipped because of failed dependencies, the failed
+	// tasks that it depends on, directly or through other skipped tasks
+	RootCauses []string
 }
 
+// ResultVisitor visits a task like Visitor, and can report details of its execution,
+// such as its cache status, in result
+type ResultVisitor = func(taskID string, result *TaskResult) error
+
 // Execute executes the pipeline, constructing an internal task graph and walking it accordingly.
 func (e *Engine) Execute(visitor Visitor, opts ExecOpts) []error {
-	var sema = util.NewSemaphore(opts.Concurrency)
-	return e.TaskGraph.Walk(func(v dag.Vertex) error {
+	_, errs := e.ExecuteWithResults(func(taskID string, _ *TaskResult) error {
+		return visitor(taskID)
+	}, opts)
+	return errs
+}
+
+// ExecuteWithResults walks the task graph like Execute, and also returns a result for
+// every task in it, ordered by task ID
+func (e *Engine) ExecuteWithResults(visitor ResultVisitor, opts ExecOpts) ([]TaskResult, []error) {
+	sema := opts.Semaphore
+	if sema == nil {
+		sema = util.NewSemaphore(opts.Concurrency)
+	}
+	groups := e.concurrencyGroups()
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	// walkCtx is also done once a task fails, unless the walk continues on error
+	walkCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	var sched *scheduler
+	var priorities map[string]int64
+	if !opts.Parallel {
+		sched = newScheduler(walkCtx, sema)
+		priorities = e.criticalPathPriorities(opts.EstimatedDuration, opts.Requested)
+	}
+	// Tasks whose outputs were ready before they completed are still running when the
+	// walk moves on, so their completion is tracked separately
+	var mu sync.Mutex
+	completed := make(map[string]chan struct{})
+	// settled is closed once a task completes, or once it's known that it never will
+	// start, for the tasks that run after it without depending on it
+	settled := make(map[string]chan struct{})
+	settledTasks := make(util.Set)
+	settle := func(taskID string) {
+		if !settledTasks.Includes(taskID) {
+			settledTasks.Add(taskID)
+			close(settled[taskID])
+		}
+	}
+	results := make(map[string]*TaskResult)
+	failed := make(util.Set)
+	// failures counts the tasks that failed themselves, for MaxFailures
+	failures := 0
+	canceled := make(util.Set)
+	var running sync.WaitGroup
+	var runningErrs []error
+	start := func(taskID string) *TaskResult {
+		mu.Lock()
+		defer mu.Unlock()
+		result := &TaskResult{TaskID: taskID, StartedAt: time.Now()}
+		results[taskID] = result
+		return result
+	}
+	complete := func(taskID string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if result, ok := results[taskID]; ok {
+			result.EndedAt = time.Now()
+			result.Duration = result.EndedAt.Sub(result.StartedAt)
+			result.Status = TaskSucceeded
+			if err != nil {
+				result.Status = TaskFailed
+				result.Err = err
+				exitErr := &process.ChildExit{}
+				if errors.As(err, &exitErr) {
+					result.ExitCode = exitErr.ExitCode
+				}
+			}
+		}
+		if err != nil {
+			failed.Add(taskID)
+		}
+		if errors.Is(err, errCanceled) {
+			canceled.Add(taskID)
+		} else if err != nil && !errors.Is(err, errDependencyFailed) && !errors.Is(err, errStopped) {
+			failures++
+			if !opts.ContinueOnError || (opts.MaxFailures > 0 && failures >= opts.MaxFailures) {
+				stop()
+			}
+		}
+		close(completed[taskID])
+		settle(taskID)
+		if err == nil && sched != nil {
+			// Dependents that can now start are about to wait for a slot
+			for _, dependent := range e.TaskGraph.UpEdges(taskID) {
+				dependentID := dag.VertexName(dependent)
+				if e.dependenciesSucceeded(dependentID, completed, failed) {
+					sched.expect(dependentID, priorities[dependentID])
+				}
+			}
+		}
+	}
+	// cancel records that taskID won't start because walkCtx is done
+	cancel := func(taskID string) error {
+		err := errCanceled
+		if ctx.Err() == nil {
+			err = errStopped
+		}
+		complete(taskID, err)
+		return err
+	}
+	for _, v := range e.TaskGraph.Vertices() {
+		completed[dag.VertexName(v)] = make(chan struct{})
+		settled[dag.VertexName(v)] = make(chan struct{})
+	}
+	if sched != nil {
+		// Tasks without dependencies are all about to wait for a slot, so the first
+		// slots go to the ones with the highest priority rather than the first to arrive
+		for taskID := range completed {
+			if !strings.Contains(taskID, ROOT_NODE_NAME) && e.dependenciesSucceeded(taskID, completed, failed) {
+				sched.expect(taskID, priorities[taskID])
+			}
+		}
+	}
+
+	// attempt visits a task until it succeeds or has no retries left, unless the
+	// walk stops in the meantime
+	attempt := func(taskID string, result *TaskResult) error {
+		retries, delay := e.retryPolicy(taskID)
+		for {
+			result.Attempts++
+			result.LastAttempt = result.Attempts > retries
+			err := visitor(taskID, result)
+			if err == nil || result.LastAttempt || walkCtx.Err() != nil {
+				return err
+			}
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-walkCtx.Done():
+				timer.Stop()
+				return err
+			}
+		}
+	}
+
+	visit := func(taskID string) error {
+		for _, dep := range e.TaskGraph.DownEdges(taskID) {
+			depID := dag.VertexName(dep)
+			if strings.Contains(depID, ROOT_NODE_NAME) || opts.OutputDeps[taskID].Includes(depID) {
+				continue
+			}
+			select {
+			case <-completed[depID]:
+			case <-walkCtx.Done():
+				return cancel(taskID)
+			}
+			mu.Lock()
+			depFailed := failed.Includes(depID)
+			depCanceled := canceled.Includes(depID)
+			mu.Unlock()
+			if depCanceled {
+				return cancel(taskID)
+			} else if depFailed {
+				complete(taskID, errDependencyFailed)
+				return errDependencyFailed
+			}
+		}
+		for _, depID := range e.OrderOnlyDeps[taskID].UnsafeListOfStrings() {
+			// Tasks may have been removed from the graph since it was prepared
+			if _, ok := settled[depID]; !ok {
+				continue
+			}
+			select {
+			case <-settled[depID]:
+			case <-walkCtx.Done():
+				return cancel(taskID)
+			}
+		}
+		// Acquire the semaphore of the task's concurrency group, even if parallel,
+		// before the semaphore of the walk, so that tasks waiting on their group
+		// don't hold slots that other tasks could use
+		release := func() {}
+		if group, ok := groups[taskID]; ok {
+			if sched != nil {
+				sched.arrive(taskID)
+			}
+			if err := group.AcquireContext(walkCtx); err != nil {
+				return cancel(taskID)
+			}
+			release = group.Release
+		}
+		if !opts.Parallel && opts.PersistentOutsideConcurrency && e.isPersistent(taskID) {
+			sched.arrive(taskID)
+		} else if !opts.Parallel {
+			if err := sched.acquire(walkCtx, taskID, priorities[taskID]); err != nil {
+				release()
+				return cancel(taskID)
+			}
+			releaseGroup := release
+			release = func() {
+				sched.release()
+				releaseGroup()
+			}
+		}
+		// A dependency may have completed as the walk was canceled
+		if walkCtx.Err() != nil {
+			release()
+			return cancel(taskID)
+		}
+		var ready <-chan struct{}
+		if opts.Ready != nil {
+			ready = opts.Ready(taskID)
+		}
+		result := start(taskID)
+		if ready == nil {
+			defer release()
+			err := attempt(taskID, result)
+			complete(taskID, err)
+			return err
+		}
+
+		visited := make(chan error, 1)
+		go func() {
+			visited <- attempt(taskID, result)
+		}()
+		select {
+		case err := <-visited:
+			release()
+			complete(taskID, err)
+			return err
+		case <-ready:
+			running.Add(1)
+			go func() {
+				defer running.Done()
+				err := <-visited
+				release()
+				complete(taskID, err)
+				if err != nil {
+					mu.Lock()
+					runningErrs = append(runningErrs, err)
+					mu.Unlock()
+				}
+			}()
+			return nil
+		}
+	}
+	errs := e.TaskGraph.Walk(func(v dag.Vertex) error {
+		taskID := dag.VertexName(v)
 		// Always return if it is the root node
-		if strings.Contains(dag.VertexName(v), ROOT_NODE_NAME) {
+		if strings.Contains(taskID, ROOT_NODE_NAME) {
 			return nil
 		}
-		// Acquire the semaphore unless parallel
-		if !opts.Parallel {
-			sema.Acquire()
-			defer sema.Release()
+		err := visit(taskID)
+		if err != nil {
+			// The walk won't visit any task that depends on this one, so they never start
+			descendents, _ := e.TaskGraph.Descendents(taskID)
+			mu.Lock()
+			for _, descendent := range descendents {
+				settle(dag.VertexName(descendent))
+			}
+			mu.Unlock()
+		}
+		return err
+	})
+	running.Wait()
+
+	var allErrs []error
+	for _, err := range errs {
+		if !errors.Is(err, errDependencyFailed) && !errors.Is(err, errStopped) && !errors.Is(err, errCanceled) {
+			allErrs = append(allErrs, err)
+		}
+	}
+
+	// The walk doesn't visit the dependents of failed, stopped, or canceled tasks at all
+	var taskResults []TaskResult
+	notStarted := 0
+	for _, v := range e.TaskGraph.Vertices() {
+		taskID := dag.VertexName(v)
+		if strings.Contains(taskID, ROOT_NODE_NAME) {
+			continue
+		}
+		if result, ok := results[taskID]; ok {
+			taskResults = append(taskResults, *result)
+		} else if len(canceled) > 0 && (canceled.Includes(taskID) || !e.dependsOnFailure(v, failed, canceled)) {
+			taskResults = append(taskResults, TaskResult{TaskID: taskID, Status: TaskCanceled})
+			notStarted++
+		} else {
+			taskResults = append(taskResults, TaskResult{TaskID: taskID, Status: TaskSkipped})
 		}
-		return visitor(dag.VertexName(v))
+	}
+	e.attributeFailures(taskResults)
+	sort.Slice(taskResults, func(i, j int) bool {
+		return taskResults[i].TaskID < taskResults[j].TaskID
 	})
+	if notStarted > 0 {
+		allErrs = append(allErrs, fmt.Errorf("stopped before starting %v tasks: %w", notStarted, ctx.Err()))
+	}
+	return taskResults, append(allErrs, runningErrs...)
+}
+
+// retryPolicy returns how many times a task is retried after it fails, and how
+// long to wait before each retry
+func (e *Engine) retryPolicy(taskID string) (int, time.Duration) {
+	pkg, taskName := util.GetPackageTaskFromId(taskID)
+	task, err := e.getTaskDefinition(pkg, taskName, taskID)
+	if err != nil {
+		return 0, 0
+	}
+	return task.Retries, task.RetryDelay
+}
+
+// concurrencyGroups returns the semaphore of the concurrency group of each task
+// in the task graph that has one. If the tasks in a group set different limits,
+// the smallest applies.
+func (e *Engine) concurrencyGroups() map[string]util.Semaphore {
+	taskGroups := make(map[string]string)
+	limits := make(map[string]int)
+	for _, v := range e.TaskGraph.Vertices() {
+		taskID := dag.VertexName(v)
+		if strings.Contains(taskID, ROOT_NODE_NAME) {
+			continue
+		}
+		pkg, taskName := util.GetPackageTaskFromId(taskID)
+		task, err := e.getTaskDefinition(pkg, taskName, taskID)
+		if err != nil || task.MaxParallel <= 0 {
+			continue
+		}
+		group := task.ConcurrencyGroup
+		if group == "" {
+			group = task.Name
+		}
+		taskGroups[taskID] = group
+		if limit, ok := limits[group]; !ok || task.MaxParallel < limit {
+			limits[group] = task.MaxParallel
+		}
+	}
+	semaphores := make(map[string]util.Semaphore, len(limits))
+	for group, limit := range limits {
+		semaphores[group] = util.NewSemaphore(limit)
+	}
+	groups := make(map[string]util.Semaphore, len(taskGroups))
+	for taskID, group := range taskGroups {
+		groups[taskID] = semaphores[group]
+	}
+	return groups
+}
+
+// dependenciesSucceeded returns true if every dependency of taskID has completed
+// without an error. It must be called with the lock on completed and failed held.
+func (e *Engine) dependenciesSucceeded(taskID string, completed map[string]chan struct{}, failed util.Set) bool {
+	for _, dep := range e.TaskGraph.DownEdges(taskID) {
+		depID := dag.VertexName(dep)
+		if strings.Contains(depID, ROOT_NODE_NAME) {
+			continue
+		}
+		select {
+		case <-completed[depID]:
+		default:
+			return false
+		}
+		if failed.Includes(depID) {
+			return false
+		}
+	}
+	for _, depID := range e.OrderOnlyDeps[taskID].UnsafeListOfStrings() {
+		if _, ok := completed[depID]; !ok {
+			continue
+		}
+		select {
+		case <-completed[depID]:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// criticalPathPriorities returns the priority of each task in the task graph: the
+// length of the longest chain of tasks that starts with it and continues through
+// its dependents. The length of each task is its estimated duration, if known, or
+// the mean of the known estimates. Without any estimates, every task has length 1.
+// The requested tasks, and the tasks they depend on, have a higher priority than
+// any other task.
+func (e *Engine) criticalPathPriorities(estimate func(taskID string) (time.Duration, bool), requested util.Set) map[string]int64 {
+	lengths := make(map[string]int64)
+	var total int64
+	var known int64
+	for _, v := range e.TaskGraph.Vertices() {
+		taskID := dag.VertexName(v)
+		if strings.Contains(taskID, ROOT_NODE_NAME) || estimate == nil {
+			continue
+		}
+		if duration, ok := estimate(taskID); ok && duration > 0 {
+			lengths[taskID] = int64(duration)
+			total += int64(duration)
+			known++
+		}
+	}
+	defaultLength := int64(1)
+	if known > 0 {
+		defaultLength = total / known
+	}
+
+	priorities := make(map[string]int64)
+	var priority func(taskID string) int64
+	priority = func(taskID string) int64 {
+		if p, ok := priorities[taskID]; ok {
+			return p
+		}
+		var longest int64
+		for _, dependent := range e.TaskGraph.UpEdges(taskID) {
+			if p := priority(dag.VertexName(dependent)); p > longest {
+				longest = p
+			}
+		}
+		length, ok := lengths[taskID]
+		if !ok {
+			length = defaultLength
+		}
+		priorities[taskID] = longest + length
+		return priorities[taskID]
+	}
+	var highest int64
+	for _, v := range e.TaskGraph.Vertices() {
+		if taskID := dag.VertexName(v); !strings.Contains(taskID, ROOT_NODE_NAME) {
+			if p := priority(taskID); p > highest {
+				highest = p
+			}
+		}
+	}
+	boosted := make(util.Set)
+	for _, taskID := range requested.UnsafeListOfStrings() {
+		if !e.TaskGraph.HasVertex(taskID) {
+			continue
+		}
+		boosted.Add(taskID)
+		deps, err := e.TaskGraph.Ancestors(taskID)
+		if err != nil {
+			continue
+		}
+		for _, dep := range deps {
+			if depID := dag.VertexName(dep); !strings.Contains(depID, ROOT_NODE_NAME) {
+				boosted.Add(depID)
+			}
+		}
+	}
+	for _, taskID := range boosted.UnsafeListOfStrings() {
+		priorities[taskID] += highest
+	}
+	return priorities
+}
+
+// PersistentDependencyChecks configures ValidatePersistentDependencies
+type PersistentDependencyChecks struct {
+	// Cacheable, if set, reports whether the outputs of a task are cached. Cacheable
+	// tasks can't depend on persistent tasks, since they would never be cached.
+	Cacheable func(taskID string) bool
+	// Allowed, if set, reports whether taskID may depend on the persistent task depID
+	// anyway, such as when it only consumes outputs that are ready before depID completes
+	Allowed func(taskID string, depID string) bool
+}
+
+// ValidatePersistentDependencies checks that no persistent task, and, if configured,
+// no cacheable task, waits for a persistent task to complete in the task graph. Every
+// offending dependency is listed in the error, rather than just the first.
+func (e *Engine) ValidatePersistentDependencies(checks PersistentDependencyChecks) error {
+	var invalid []string
+	for _, edge := range e.TaskGraph.Edges() {
+		taskID := dag.VertexName(edge.Source())
+		depID := dag.VertexName(edge.Target())
+		if strings.Contains(taskID, ROOT_NODE_NAME) || strings.Contains(depID, ROOT_NODE_NAME) {
+			continue
+		}
+		if !e.isPersistent(depID) || (checks.Allowed != nil && checks.Allowed(taskID, depID)) {
+			continue
+		}
+		if e.isPersistent(taskID) {
+			invalid = append(invalid, fmt.Sprintf("\t%v depends on %v, and is persistent itself", taskID, depID))
+		} else if checks.Cacheable != nil && checks.Cacheable(taskID) {
+			invalid = append(invalid, fmt.Sprintf("\t%v depends on %v, and is cached", taskID, depID))
+		}
+	}
+	if len(invalid) == 0 {
+		return nil
+	}
+	sort.Strings(invalid)
+	return fmt.Errorf("persistent tasks never complete, so these tasks would never start:\n%v", strings.Join(invalid, "\n"))
+}
+
+func (e *Engine) isPersistent(taskID string) bool {
+	pkg, taskName := util.GetPackageTaskFromId(taskID)
+	task, err := e.getTaskDefinition(pkg, taskName, taskID)
+	return err == nil && task.Persistent
+}
+
+// attributeFailures sets BlockedBy and RootCauses of the tasks that were skipped
+// because of failed dependencies, so that a cascade of skipped tasks can be traced
+// back to the failures that caused it. Tasks that were skipped only because another
+// task failed, without ContinueOnError, have no root causes.
+func (e *Engine) attributeFailures(taskResults []TaskResult) {
+	byID := make(map[string]*TaskResult, len(taskResults))
+	for i := range taskResults {
+		byID[taskResults[i].TaskID] = &taskResults[i]
+	}
+	rootCauses := make(map[string]util.Set)
+	var causesOf func(taskID string) util.Set
+	causesOf = func(taskID string) util.Set {
+		if causes, ok := rootCauses[taskID]; ok {
+			return causes
+		}
+		causes := make(util.Set)
+		rootCauses[taskID] = causes
+		result, ok := byID[taskID]
+		if !ok {
+			return causes
+		}
+		switch result.Status {
+		case TaskFailed:
+			causes.Add(taskID)
+		case TaskSkipped:
+			for _, dep := range e.TaskGraph.DownEdges(taskID) {
+				depID := dag.VertexName(dep)
+				depCauses := causesOf(depID)
+				if depCauses.Len() == 0 {
+					continue
+				}
+				result.BlockedBy = append(result.BlockedBy, depID)
+				for cause := range depCauses {
+					causes.Add(cause)
+				}
+			}
+			sort.Strings(result.BlockedBy)
+			if causes.Len() > 0 {
+				result.RootCauses = causes.UnsafeListOfStrings()
+				sort.Strings(result.RootCauses)
+			}
+		}
+		return causes
+	}
+	for _, result := range taskResults {
+		causesOf(result.TaskID)
+	}
+}
+
+// dependsOnFailure returns true if a task that v depends on failed, other than by
+// being canceled
+func (e *Engine) dependsOnFailure(v dag.Vertex, failed util.Set, canceled util.Set) bool {
+	deps, err := e.TaskGraph.Ancestors(v)
+	if err != nil {
+		return false
+	}
+	for _, dep := range deps {
+		depID := dag.VertexName(dep)
+		if failed.Includes(depID) && !canceled.Includes(depID) {
+			return true
+		}
+	}
+	return false
 }
 
 func (e *Engine) getTaskDefinition(pkg string, taskName string, taskID string) (*Task, error) {
@@ -116,9 +844,22 @@ func (e *Engine) generateTaskGraph(pkgs []string, taskNames []string, tasksOnly
 	packageTasksDepsMap := getPackageTaskDepsMap(e.PackageTaskDeps)
 
 	traversalQueue := []string{}
+	for _, taskName := range taskNames {
+		if util.IsPackageTask(taskName) {
+			// Root tasks requested by their ID are required to exist
+			pkg, name := util.GetPackageTaskFromId(taskName)
+			if _, err := e.getTaskDefinition(pkg, name, taskName); err != nil {
+				return err
+			}
+			traversalQueue = append(traversalQueue, taskName)
+		}
+	}
 	for _, pkg := range pkgs {
 		isRootPkg := pkg == util.RootPkgName
 		for _, taskName := range taskNames {
+			if util.IsPackageTask(taskName) {
+				continue
+			}
 			if !isRootPkg || e.rootEnabledTasks.Includes(taskName) {
 				taskID := util.GetTaskId(pkg, taskName)
 				if _, err := e.getTaskDefinition(pkg, taskName, taskID); err != nil {
@@ -179,9 +920,7 @@ func (e *Engine) generateTaskGraph(pkgs []string, taskNames []string, tasksOnly
 					// add task dep from all the package deps within repo
 					for depPkg := range depPkgs {
 						fromTaskID := util.GetTaskId(depPkg, from)
-						e.TaskGraph.Add(fromTaskID)
-						e.TaskGraph.Add(toTaskID)
-						e.TaskGraph.Connect(dag.BasicEdge(toTaskID, fromTaskID))
+						e.addDependency(toTaskID, fromTaskID, fmt.Sprintf("\"^%v\" in \"dependsOn\" of %q", from, task.Name))
 						traversalQueue = append(traversalQueue, fromTaskID)
 					}
 				}
@@ -190,9 +929,7 @@ func (e *Engine) generateTaskGraph(pkgs []string, taskNames []string, tasksOnly
 			if hasDeps {
 				for _, from := range deps.UnsafeListOfStrings() {
 					fromTaskID := util.GetTaskId(pkg, from)
-					e.TaskGraph.Add(fromTaskID)
-					e.TaskGraph.Add(toTaskID)
-					e.TaskGraph.Connect(dag.BasicEdge(toTaskID, fromTaskID))
+					e.addDependency(toTaskID, fromTaskID, fmt.Sprintf("%q in \"dependsOn\" of %q", from, task.Name))
 					traversalQueue = append(traversalQueue, fromTaskID)
 				}
 			}
@@ -200,9 +937,7 @@ func (e *Engine) generateTaskGraph(pkgs []string, taskNames []string, tasksOnly
 			if hasPackageTaskDeps {
 				if pkgTaskDeps, ok := packageTasksDepsMap[toTaskID]; ok {
 					for _, fromTaskID := range pkgTaskDeps {
-						e.TaskGraph.Add(fromTaskID)
-						e.TaskGraph.Add(toTaskID)
-						e.TaskGraph.Connect(dag.BasicEdge(toTaskID, fromTaskID))
+						e.addDependency(toTaskID, fromTaskID, fmt.Sprintf("%q in \"dependsOn\" of %q", fromTaskID, toTaskID))
 						traversalQueue = append(traversalQueue, fromTaskID)
 					}
 				}
@@ -215,6 +950,75 @@ func (e *Engine) generateTaskGraph(pkgs []string, taskNames []string, tasksOnly
 			}
 		}
 	}
+	return e.checkCycles()
+}
+
+// addDependency adds an edge to the task graph from taskID to depID, which source,
+// an entry in the pipeline, introduced
+func (e *Engine) addDependency(taskID string, depID string, source string) {
+	e.TaskGraph.Add(depID)
+	e.TaskGraph.Add(taskID)
+	e.TaskGraph.Connect(dag.BasicEdge(taskID, depID))
+	if _, ok := e.depSources[taskID]; !ok {
+		e.depSources[taskID] = make(map[string]string)
+	}
+	if _, ok := e.depSources[taskID][depID]; !ok {
+		e.depSources[taskID][depID] = source
+	}
+}
+
+// addOrderOnlyDeps records the order-only dependencies between the tasks in the
+// task graph. Tasks that aren't in the graph are ignored, rather than added.
+func (e *Engine) addOrderOnlyDeps() error {
+	e.OrderOnlyDeps = make(map[string]util.Set)
+	for _, v := range e.TaskGraph.Vertices() {
+		taskID := dag.VertexName(v)
+		if strings.Contains(taskID, ROOT_NODE_NAME) {
+			continue
+		}
+		pkg, taskName := util.GetPackageTaskFromId(taskID)
+		task, err := e.getTaskDefinition(pkg, taskName, taskID)
+		if err != nil {
+			continue
+		}
+		var depIDs []string
+		for _, dep := range task.OrderOnlyDeps.UnsafeListOfStrings() {
+			depIDs = append(depIDs, util.GetTaskId(pkg, dep))
+		}
+		for _, dep := range task.OrderOnlyTopoDeps.UnsafeListOfStrings() {
+			for depPkg := range e.TopologicGraph.DownEdges(pkg) {
+				depIDs = append(depIDs, util.GetTaskId(depPkg, dep))
+			}
+		}
+		for _, depID := range depIDs {
+			if depID == taskID || !e.TaskGraph.HasVertex(depID) {
+				continue
+			}
+			if _, ok := e.OrderOnlyDeps[taskID]; !ok {
+				e.OrderOnlyDeps[taskID] = make(util.Set)
+			}
+			e.OrderOnlyDeps[taskID].Add(depID)
+		}
+	}
+	if len(e.OrderOnlyDeps) == 0 {
+		return nil
+	}
+	// Running after a task that depends on this one would never finish
+	combined := &dag.AcyclicGraph{}
+	for _, v := range e.TaskGraph.Vertices() {
+		combined.Add(v)
+	}
+	for _, edge := range e.TaskGraph.Edges() {
+		combined.Connect(edge)
+	}
+	for taskID, depIDs := range e.OrderOnlyDeps {
+		for _, depID := range depIDs.UnsafeListOfStrings() {
+			combined.Connect(dag.BasicEdge(taskID, depID))
+		}
+	}
+	if err := util.ValidateGraph(combined); err != nil {
+		return fmt.Errorf("invalid \"orderOnly\" dependencies: %w", err)
+	}
 	return nil
 }
 
@@ -1,10 +1,17 @@
 package core
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/vercel/turbo/cli/internal/process"
 	"github.com/vercel/turbo/cli/internal/util"
 	"gotest.tools/v3/assert"
 
@@ -209,6 +216,97 @@ libA#build
 	assert.Equal(t, expected, actual)
 }
 
+func TestTaskGraphJSON(t *testing.T) {
+	graph := &dag.AcyclicGraph{}
+	graph.Add("app1")
+	graph.Add("libA")
+	graph.Connect(dag.BasicEdge("app1", "libA"))
+
+	p := NewEngine(graph)
+	dependOnBuild := make(util.Set)
+	dependOnBuild.Add("build")
+	p.AddTask(&Task{
+		Name:     "build",
+		TopoDeps: dependOnBuild,
+		Deps:     make(util.Set),
+	})
+	p.AddTask(&Task{
+		Name:       "dev",
+		TopoDeps:   make(util.Set),
+		Deps:       dependOnBuild,
+		Persistent: true,
+	})
+	err := p.Prepare(&EngineExecutionOptions{
+		Packages:  []string{"app1"},
+		TaskNames: []string{"dev"},
+	})
+	assert.NilError(t, err, "Prepare")
+
+	bytes, err := p.TaskGraphJSON()
+	assert.NilError(t, err)
+	var doc TaskGraphDocument
+	assert.NilError(t, json.Unmarshal(bytes, &doc))
+	assert.DeepEqual(t, doc, TaskGraphDocument{
+		Version: 1,
+		Nodes: []TaskGraphNode{
+			{TaskID: "app1#build", Package: "app1", Task: "build", Deps: []string{}, TopoDeps: []string{"build"}},
+			{TaskID: "app1#dev", Package: "app1", Task: "dev", Persistent: true, Deps: []string{"build"}, TopoDeps: []string{}},
+			{TaskID: "libA#build", Package: "libA", Task: "build", Deps: []string{}, TopoDeps: []string{"build"}},
+		},
+		Edges: []TaskGraphEdge{
+			{From: "app1#build", To: "libA#build"},
+			{From: "app1#dev", To: "app1#build"},
+		},
+	})
+	again, err := p.TaskGraphJSON()
+	assert.NilError(t, err)
+	assert.Equal(t, string(again), string(bytes))
+}
+
+func TestTaskGraphCycle(t *testing.T) {
+	graph := &dag.AcyclicGraph{}
+	graph.Add("app1")
+	graph.Add("libA")
+	graph.Connect(dag.BasicEdge("app1", "libA"))
+
+	p := NewEngine(graph)
+	dependOnBuild := make(util.Set)
+	dependOnBuild.Add("build")
+	p.AddTask(&Task{
+		Name:     "build",
+		TopoDeps: dependOnBuild,
+		Deps:     make(util.Set),
+	})
+	p.AddTask(&Task{
+		Name:     "libA#build",
+		TopoDeps: dependOnBuild,
+		Deps:     make(util.Set),
+	})
+	assert.NilError(t, p.AddDep("app1#build", "libA#build"))
+	err := p.Prepare(&EngineExecutionOptions{
+		Packages:  []string{"app1", "libA"},
+		TaskNames: []string{"build"},
+	})
+	assert.Error(t, err, `cyclic dependency detected: app1#build -> libA#build -> app1#build
+	app1#build -> libA#build: "^build" in "dependsOn" of "build"
+	libA#build -> app1#build: "app1#build" in "dependsOn" of "libA#build"`)
+
+	dependOnLint := make(util.Set)
+	dependOnLint.Add("lint")
+	p = NewEngine(graph)
+	p.AddTask(&Task{
+		Name:     "lint",
+		TopoDeps: make(util.Set),
+		Deps:     dependOnLint,
+	})
+	err = p.Prepare(&EngineExecutionOptions{
+		Packages:  []string{"libA"},
+		TaskNames: []string{"lint"},
+	})
+	assert.Error(t, err, `cyclic dependency detected: libA#lint -> libA#lint
+	libA#lint -> libA#lint: "lint" in "dependsOn" of "lint"`)
+}
+
 func TestRunWithNoTasksFound(t *testing.T) {
 	graph := &dag.AcyclicGraph{}
 	graph.Add("app")
@@ -284,6 +382,54 @@ libA#test
 	}
 }
 
+func TestRunRootTaskByID(t *testing.T) {
+	graph := &dag.AcyclicGraph{}
+	graph.Add("app1")
+	graph.Add("libA")
+	graph.Connect(dag.BasicEdge("app1", "libA"))
+
+	p := NewEngine(graph)
+	p.AddTask(&Task{
+		Name:     "build",
+		TopoDeps: make(util.Set),
+		Deps:     make(util.Set),
+	})
+	p.AddTask(&Task{
+		Name:     util.RootTaskID("build"),
+		TopoDeps: make(util.Set),
+		Deps:     make(util.Set),
+	})
+	err := p.Prepare(&EngineExecutionOptions{
+		Packages:  []string{util.RootPkgName, "app1", "libA"},
+		TaskNames: []string{util.RootTaskID("build")},
+	})
+	if err != nil {
+		t.Fatalf("failed to prepare engine: %v", err)
+	}
+	actual := strings.TrimSpace(p.TaskGraph.String())
+	expected := fmt.Sprintf(`
+%v#build
+  ___ROOT___
+___ROOT___
+`, util.RootPkgName)
+	expected = strings.TrimSpace(expected)
+	if actual != expected {
+		t.Errorf("task graph got:\n%v\nwant:\n%v", actual, expected)
+	}
+
+	p = NewEngine(graph)
+	p.AddTask(&Task{
+		Name:     "build",
+		TopoDeps: make(util.Set),
+		Deps:     make(util.Set),
+	})
+	err = p.Prepare(&EngineExecutionOptions{
+		Packages:  []string{util.RootPkgName, "app1", "libA"},
+		TaskNames: []string{util.RootTaskID("build")},
+	})
+	assert.ErrorContains(t, err, "needs an entry in turbo.json")
+}
+
 func TestDependOnRootTask(t *testing.T) {
 	graph := &dag.AcyclicGraph{}
 	graph.Add("app1")
@@ -479,3 +625,611 @@ b#test
 c#test
   ___ROOT___
 `
+
+func TestExecuteOutputDeps(t *testing.T) {
+	newEngine := func() *Engine {
+		engine := NewEngine(&dag.AcyclicGraph{})
+		for _, taskID := range []string{ROOT_NODE_NAME, "ui#dev", "web#typecheck", "web#test"} {
+			engine.TaskGraph.Add(taskID)
+		}
+		engine.TaskGraph.Connect(dag.BasicEdge("ui#dev", ROOT_NODE_NAME))
+		engine.TaskGraph.Connect(dag.BasicEdge("web#typecheck", "ui#dev"))
+		engine.TaskGraph.Connect(dag.BasicEdge("web#test", "ui#dev"))
+		return engine
+	}
+	typesReady := make(chan struct{})
+	opts := ExecOpts{
+		Concurrency: 2,
+		OutputDeps:  map[string]util.Set{"web#typecheck": util.SetFromStrings([]string{"ui#dev"})},
+		Ready: func(taskID string) <-chan struct{} {
+			if taskID == "ui#dev" {
+				return typesReady
+			}
+			return nil
+		},
+	}
+
+	var mu sync.Mutex
+	var order []string
+	record := func(event string) {
+		mu.Lock()
+		defer mu.Unlock()
+		order = append(order, event)
+	}
+	typecheckDone := make(chan struct{})
+	errs := newEngine().Execute(func(taskID string) error {
+		switch taskID {
+		case "ui#dev":
+			close(typesReady)
+			// Only finish once a task that needs the outputs, but not completion, has run
+			<-typecheckDone
+		case "web#typecheck":
+			defer close(typecheckDone)
+		}
+		record(taskID)
+		return nil
+	}, opts)
+	assert.Equal(t, len(errs), 0)
+	assert.DeepEqual(t, order, []string{"web#typecheck", "ui#dev", "web#test"})
+
+	// A failure after the outputs were ready is reported, and skips tasks that need
+	// the task to complete
+	typesReady = make(chan struct{})
+	order = nil
+	errs = newEngine().Execute(func(taskID string) error {
+		if taskID == "ui#dev" {
+			close(typesReady)
+			time.Sleep(10 * time.Millisecond)
+			return errors.New("ui#dev failed")
+		}
+		record(taskID)
+		return nil
+	}, opts)
+	assert.Equal(t, len(errs), 1)
+	assert.ErrorContains(t, errs[0], "ui#dev failed")
+	assert.DeepEqual(t, order, []string{"web#typecheck"})
+}
+
+func TestExecuteWithResults(t *testing.T) {
+	engine := NewEngine(&dag.AcyclicGraph{})
+	for _, taskID := range []string{ROOT_NODE_NAME, "ui#build", "web#build", "web#lint", "docs#build"} {
+		engine.TaskGraph.Add(taskID)
+	}
+	engine.TaskGraph.Connect(dag.BasicEdge("ui#build", ROOT_NODE_NAME))
+	engine.TaskGraph.Connect(dag.BasicEdge("web#lint", ROOT_NODE_NAME))
+	engine.TaskGraph.Connect(dag.BasicEdge("docs#build", ROOT_NODE_NAME))
+	engine.TaskGraph.Connect(dag.BasicEdge("web#build", "ui#build"))
+
+	results, errs := engine.ExecuteWithResults(func(taskID string, result *TaskResult) error {
+		switch taskID {
+		case "ui#build":
+			return fmt.Errorf("running ui#build failed: %w", &process.ChildExit{ExitCode: 2, Command: "build"})
+		case "docs#build":
+			result.CacheStatus = CacheHit
+		case "web#lint":
+			result.CacheStatus = CacheMiss
+		}
+		return nil
+	}, ExecOpts{Concurrency: 10, ContinueOnError: true})
+	assert.Equal(t, len(errs), 1)
+
+	var taskIDs []string
+	for _, result := range results {
+		taskIDs = append(taskIDs, result.TaskID)
+	}
+	assert.DeepEqual(t, taskIDs, []string{"docs#build", "ui#build", "web#build", "web#lint"})
+
+	docs, ui, web, lint := results[0], results[1], results[2], results[3]
+	assert.Equal(t, docs.Status, TaskSucceeded)
+	assert.Equal(t, docs.CacheStatus, CacheHit)
+	assert.Assert(t, !docs.StartedAt.IsZero())
+	assert.Assert(t, !docs.EndedAt.Before(docs.StartedAt))
+	assert.Equal(t, docs.Duration, docs.EndedAt.Sub(docs.StartedAt))
+
+	assert.Equal(t, ui.Status, TaskFailed)
+	assert.Equal(t, ui.ExitCode, 2)
+	assert.ErrorContains(t, ui.Err, "ui#build failed")
+
+	assert.Equal(t, web.Status, TaskSkipped)
+	assert.Assert(t, web.StartedAt.IsZero())
+
+	assert.Equal(t, lint.Status, TaskSucceeded)
+	assert.Equal(t, lint.CacheStatus, CacheMiss)
+	assert.Equal(t, lint.ExitCode, 0)
+	assert.NilError(t, lint.Err)
+}
+
+func TestExecuteContext(t *testing.T) {
+	engine := NewEngine(&dag.AcyclicGraph{})
+	for _, taskID := range []string{ROOT_NODE_NAME, "ui#build", "web#build", "web#lint", "docs#build", "docs#test"} {
+		engine.TaskGraph.Add(taskID)
+	}
+	engine.TaskGraph.Connect(dag.BasicEdge("ui#build", ROOT_NODE_NAME))
+	engine.TaskGraph.Connect(dag.BasicEdge("web#lint", ROOT_NODE_NAME))
+	engine.TaskGraph.Connect(dag.BasicEdge("docs#build", ROOT_NODE_NAME))
+	engine.TaskGraph.Connect(dag.BasicEdge("web#build", "ui#build"))
+	engine.TaskGraph.Connect(dag.BasicEdge("docs#test", "docs#build"))
+
+	// With one slot, the walk is canceled while the first task holds it. Only
+	// docs#build fails, which skips docs#test rather than canceling it.
+	ctx, cancel := context.WithCancel(context.Background())
+	var mu sync.Mutex
+	var visited []string
+	results, errs := engine.ExecuteWithResults(func(taskID string, result *TaskResult) error {
+		mu.Lock()
+		visited = append(visited, taskID)
+		first := len(visited) == 1
+		mu.Unlock()
+		if first {
+			cancel()
+			if taskID == "docs#build" {
+				return errors.New("docs#build failed")
+			}
+		}
+		return nil
+	}, ExecOpts{Context: ctx, Concurrency: 1})
+	assert.Equal(t, len(visited), 1)
+
+	statuses := make(map[string]TaskStatus)
+	for _, result := range results {
+		statuses[result.TaskID] = result.Status
+	}
+	canceled := 0
+	for taskID, status := range statuses {
+		if taskID == visited[0] {
+			assert.Assert(t, status == TaskSucceeded || status == TaskFailed, taskID)
+		} else if taskID == "docs#test" && visited[0] == "docs#build" {
+			assert.Equal(t, status, TaskSkipped)
+		} else {
+			assert.Equal(t, status, TaskCanceled, taskID)
+			canceled++
+		}
+	}
+	lastErr := errs[len(errs)-1]
+	assert.Assert(t, errors.Is(lastErr, context.Canceled))
+	assert.ErrorContains(t, lastErr, fmt.Sprintf("stopped before starting %v tasks", canceled))
+
+	// A walk that is already canceled starts nothing
+	visited = nil
+	errs = engine.Execute(func(taskID string) error {
+		visited = append(visited, taskID)
+		return nil
+	}, ExecOpts{Context: ctx, Concurrency: 10})
+	assert.Equal(t, len(visited), 0)
+	assert.Equal(t, len(errs), 1)
+	assert.ErrorContains(t, errs[0], "stopped before starting 5 tasks: context canceled")
+}
+
+func TestExecuteContinueOnError(t *testing.T) {
+	newEngine := func() *Engine {
+		engine := NewEngine(&dag.AcyclicGraph{})
+		for _, taskID := range []string{ROOT_NODE_NAME, "ui#build", "web#build", "docs#setup", "docs#build"} {
+			engine.TaskGraph.Add(taskID)
+		}
+		engine.TaskGraph.Connect(dag.BasicEdge("ui#build", ROOT_NODE_NAME))
+		engine.TaskGraph.Connect(dag.BasicEdge("docs#setup", ROOT_NODE_NAME))
+		engine.TaskGraph.Connect(dag.BasicEdge("web#build", "ui#build"))
+		engine.TaskGraph.Connect(dag.BasicEdge("docs#build", "docs#setup"))
+		return engine
+	}
+	run := func(continueOnError bool) (map[string]TaskStatus, []error) {
+		setupStarted := make(chan struct{})
+		uiFailed := make(chan struct{})
+		results, errs := newEngine().ExecuteWithResults(func(taskID string, result *TaskResult) error {
+			switch taskID {
+			case "ui#build":
+				<-setupStarted
+				close(uiFailed)
+				return errors.New("ui#build failed")
+			case "docs#setup":
+				// Finish after ui#build has failed
+				close(setupStarted)
+				<-uiFailed
+				time.Sleep(10 * time.Millisecond)
+			}
+			return nil
+		}, ExecOpts{Concurrency: 2, ContinueOnError: continueOnError})
+		statuses := make(map[string]TaskStatus)
+		for _, result := range results {
+			statuses[result.TaskID] = result.Status
+		}
+		return statuses, errs
+	}
+
+	// docs#build doesn't depend on ui#build, but doesn't start once it has failed
+	statuses, errs := run(false)
+	assert.Equal(t, len(errs), 1)
+	assert.ErrorContains(t, errs[0], "ui#build failed")
+	assert.DeepEqual(t, statuses, map[string]TaskStatus{
+		"docs#build": TaskSkipped,
+		"docs#setup": TaskSucceeded,
+		"ui#build":   TaskFailed,
+		"web#build":  TaskSkipped,
+	})
+
+	statuses, errs = run(true)
+	assert.Equal(t, len(errs), 1)
+	assert.ErrorContains(t, errs[0], "ui#build failed")
+	assert.DeepEqual(t, statuses, map[string]TaskStatus{
+		"docs#build": TaskSucceeded,
+		"docs#setup": TaskSucceeded,
+		"ui#build":   TaskFailed,
+		"web#build":  TaskSkipped,
+	})
+}
+
+func TestExecuteMaxFailures(t *testing.T) {
+	engine := NewEngine(&dag.AcyclicGraph{})
+	taskIDs := []string{"a#test", "b#test", "c#test", "d#test", "e#test"}
+	engine.TaskGraph.Add(ROOT_NODE_NAME)
+	for _, taskID := range taskIDs {
+		engine.TaskGraph.Add(taskID)
+		engine.TaskGraph.Connect(dag.BasicEdge(taskID, ROOT_NODE_NAME))
+	}
+	run := func(maxFailures int) map[TaskStatus]int {
+		results, errs := engine.ExecuteWithResults(func(taskID string, result *TaskResult) error {
+			return fmt.Errorf("%v failed", taskID)
+		}, ExecOpts{Concurrency: 1, ContinueOnError: true, MaxFailures: maxFailures})
+		counts := make(map[TaskStatus]int)
+		for _, result := range results {
+			counts[result.Status]++
+		}
+		assert.Equal(t, len(errs), counts[TaskFailed])
+		return counts
+	}
+
+	// Tasks already running finish, but no more start once enough have failed
+	assert.DeepEqual(t, run(2), map[TaskStatus]int{TaskFailed: 2, TaskSkipped: 3})
+	assert.DeepEqual(t, run(5), map[TaskStatus]int{TaskFailed: 5})
+	assert.DeepEqual(t, run(0), map[TaskStatus]int{TaskFailed: 5})
+}
+
+func TestExecuteAttributesFailures(t *testing.T) {
+	// ui#build and icons#build fail. web#build is blocked by both, docs#build by
+	// web#build, and api#build by nothing that failed.
+	engine := NewEngine(&dag.AcyclicGraph{})
+	for _, taskID := range []string{ROOT_NODE_NAME, "ui#build", "icons#build", "web#build", "docs#build", "api#build"} {
+		engine.TaskGraph.Add(taskID)
+	}
+	engine.TaskGraph.Connect(dag.BasicEdge("ui#build", ROOT_NODE_NAME))
+	engine.TaskGraph.Connect(dag.BasicEdge("icons#build", ROOT_NODE_NAME))
+	engine.TaskGraph.Connect(dag.BasicEdge("api#build", ROOT_NODE_NAME))
+	engine.TaskGraph.Connect(dag.BasicEdge("web#build", "ui#build"))
+	engine.TaskGraph.Connect(dag.BasicEdge("web#build", "icons#build"))
+	engine.TaskGraph.Connect(dag.BasicEdge("web#build", "api#build"))
+	engine.TaskGraph.Connect(dag.BasicEdge("docs#build", "web#build"))
+
+	results, _ := engine.ExecuteWithResults(func(taskID string, result *TaskResult) error {
+		if taskID == "ui#build" || taskID == "icons#build" {
+			return fmt.Errorf("%v failed", taskID)
+		}
+		return nil
+	}, ExecOpts{Concurrency: 10, ContinueOnError: true})
+	blockedBy := make(map[string][]string)
+	rootCauses := make(map[string][]string)
+	for _, result := range results {
+		if result.BlockedBy != nil {
+			blockedBy[result.TaskID] = result.BlockedBy
+		}
+		if result.RootCauses != nil {
+			rootCauses[result.TaskID] = result.RootCauses
+		}
+	}
+	assert.DeepEqual(t, blockedBy, map[string][]string{
+		"web#build":  {"icons#build", "ui#build"},
+		"docs#build": {"web#build"},
+	})
+	assert.DeepEqual(t, rootCauses, map[string][]string{
+		"web#build":  {"icons#build", "ui#build"},
+		"docs#build": {"icons#build", "ui#build"},
+	})
+}
+
+func TestExecuteConcurrencyGroups(t *testing.T) {
+	engine := NewEngine(&dag.AcyclicGraph{})
+	engine.AddTask(&Task{Name: "build", MaxParallel: 2})
+	engine.AddTask(&Task{Name: "db:migrate", ConcurrencyGroup: "database", MaxParallel: 1})
+	engine.AddTask(&Task{Name: "db:seed", ConcurrencyGroup: "database", MaxParallel: 1})
+	engine.AddTask(&Task{Name: "lint"})
+	engine.TaskGraph.Add(ROOT_NODE_NAME)
+	for _, pkg := range []string{"a", "b", "c", "d"} {
+		for _, task := range []string{"build", "db:migrate", "db:seed", "lint"} {
+			taskID := util.GetTaskId(pkg, task)
+			engine.TaskGraph.Add(taskID)
+			engine.TaskGraph.Connect(dag.BasicEdge(taskID, ROOT_NODE_NAME))
+		}
+	}
+
+	for _, parallel := range []bool{false, true} {
+		var mu sync.Mutex
+		running := make(map[string]int)
+		most := make(map[string]int)
+		errs := engine.Execute(func(taskID string) error {
+			_, task := util.GetPackageTaskFromId(taskID)
+			group := task
+			if strings.HasPrefix(task, "db:") {
+				group = "database"
+			}
+			mu.Lock()
+			running[group]++
+			if running[group] > most[group] {
+				most[group] = running[group]
+			}
+			mu.Unlock()
+			time.Sleep(10 * time.Millisecond)
+			mu.Lock()
+			running[group]--
+			mu.Unlock()
+			return nil
+		}, ExecOpts{Concurrency: 10, Parallel: parallel})
+		assert.Equal(t, len(errs), 0)
+		assert.Equal(t, most["build"], 2, "parallel: %v", parallel)
+		assert.Equal(t, most["database"], 1, "parallel: %v", parallel)
+		// Tasks without a limit still run concurrently
+		assert.Assert(t, most["lint"] > 1, "parallel: %v", parallel)
+	}
+}
+
+func TestValidatePersistentDependencies(t *testing.T) {
+	engine := NewEngine(&dag.AcyclicGraph{})
+	engine.AddTask(&Task{Name: "dev", Persistent: true})
+	engine.AddTask(&Task{Name: "build"})
+	engine.AddTask(&Task{Name: "web#dev", Persistent: true})
+	for _, taskID := range []string{ROOT_NODE_NAME, "ui#dev", "web#dev", "web#build", "docs#dev"} {
+		engine.TaskGraph.Add(taskID)
+	}
+	engine.TaskGraph.Connect(dag.BasicEdge("ui#dev", ROOT_NODE_NAME))
+	engine.TaskGraph.Connect(dag.BasicEdge("web#dev", "ui#dev"))
+	engine.TaskGraph.Connect(dag.BasicEdge("web#build", "ui#dev"))
+	engine.TaskGraph.Connect(dag.BasicEdge("docs#dev", "ui#dev"))
+
+	// Without Cacheable, only persistent dependents are reported
+	err := engine.ValidatePersistentDependencies(PersistentDependencyChecks{})
+	assert.Error(t, err, "persistent tasks never complete, so these tasks would never start:\n"+
+		"\tdocs#dev depends on ui#dev, and is persistent itself\n"+
+		"\tweb#dev depends on ui#dev, and is persistent itself")
+
+	err = engine.ValidatePersistentDependencies(PersistentDependencyChecks{
+		Cacheable: func(taskID string) bool { return true },
+		Allowed: func(taskID string, depID string) bool {
+			return taskID == "docs#dev" || taskID == "web#dev"
+		},
+	})
+	assert.Error(t, err, "persistent tasks never complete, so these tasks would never start:\n"+
+		"\tweb#build depends on ui#dev, and is cached")
+}
+
+func TestExecutePriority(t *testing.T) {
+	engine := NewEngine(&dag.AcyclicGraph{})
+	for _, taskID := range []string{ROOT_NODE_NAME, "a#lint", "b#lint", "c#lint", "ui#build", "web#build", "docs#build"} {
+		engine.TaskGraph.Add(taskID)
+	}
+	for _, taskID := range []string{"a#lint", "b#lint", "c#lint", "ui#build"} {
+		engine.TaskGraph.Connect(dag.BasicEdge(taskID, ROOT_NODE_NAME))
+	}
+	engine.TaskGraph.Connect(dag.BasicEdge("web#build", "ui#build"))
+	engine.TaskGraph.Connect(dag.BasicEdge("docs#build", "web#build"))
+
+	run := func(estimate func(taskID string) (time.Duration, bool), requested util.Set) []string {
+		// Hold the only slot until every task without dependencies is waiting for it
+		sema := util.NewSemaphore(1)
+		sema.Acquire()
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			sema.Release()
+		}()
+		var mu sync.Mutex
+		var order []string
+		errs := engine.Execute(func(taskID string) error {
+			mu.Lock()
+			defer mu.Unlock()
+			order = append(order, taskID)
+			return nil
+		}, ExecOpts{Semaphore: sema, EstimatedDuration: estimate, Requested: requested})
+		assert.Equal(t, len(errs), 0)
+		return order
+	}
+
+	// The longest chain of tasks goes first
+	order := run(nil, nil)
+	assert.DeepEqual(t, order[:2], []string{"ui#build", "web#build"})
+	assert.Equal(t, order[5], "docs#build")
+
+	// Unless the estimates make another chain longer
+	order = run(func(taskID string) (time.Duration, bool) {
+		if strings.HasSuffix(taskID, "#lint") {
+			return 10 * time.Second, true
+		}
+		return time.Second, true
+	}, nil)
+	sort.Strings(order[:3])
+	assert.DeepEqual(t, order, []string{"a#lint", "b#lint", "c#lint", "ui#build", "web#build", "docs#build"})
+
+	// Requested tasks, and their dependencies, go before anything else
+	requested := make(util.Set)
+	requested.Add("c#lint")
+	requested.Add("web#build")
+	order = run(nil, requested)
+	assert.DeepEqual(t, order[:3], []string{"ui#build", "web#build", "c#lint"})
+}
+
+func TestOrderOnlyDeps(t *testing.T) {
+	graph := &dag.AcyclicGraph{}
+	graph.Add("ui")
+	newEngine := func(lint *Task) *Engine {
+		engine := NewEngine(graph)
+		dependOnBuild := make(util.Set)
+		dependOnBuild.Add("build")
+		afterTest := make(util.Set)
+		afterTest.Add("test")
+		engine.AddTask(&Task{Name: "build", TopoDeps: make(util.Set), Deps: make(util.Set)})
+		engine.AddTask(&Task{Name: "test", TopoDeps: make(util.Set), Deps: dependOnBuild})
+		engine.AddTask(&Task{Name: "typecheck", TopoDeps: make(util.Set), Deps: make(util.Set), OrderOnlyDeps: afterTest})
+		lint.Name = "lint"
+		if lint.OrderOnlyDeps == nil {
+			lint.OrderOnlyDeps = dependOnBuild
+		}
+		engine.AddTask(lint)
+		return engine
+	}
+
+	// An order-only dependency isn't added to the run
+	engine := newEngine(&Task{TopoDeps: make(util.Set), Deps: make(util.Set)})
+	err := engine.Prepare(&EngineExecutionOptions{Packages: []string{"ui"}, TaskNames: []string{"lint"}})
+	assert.NilError(t, err)
+	assert.Assert(t, !engine.TaskGraph.HasVertex("ui#build"))
+	assert.Equal(t, len(engine.OrderOnlyDeps), 0)
+
+	// When it is in the run, it finishes first, but failing doesn't skip the task
+	engine = newEngine(&Task{TopoDeps: make(util.Set), Deps: make(util.Set)})
+	err = engine.Prepare(&EngineExecutionOptions{Packages: []string{"ui"}, TaskNames: []string{"build", "test", "typecheck", "lint"}})
+	assert.NilError(t, err)
+	var mu sync.Mutex
+	var finished []string
+	results, errs := engine.ExecuteWithResults(func(taskID string, result *TaskResult) error {
+		if taskID == "ui#build" {
+			// Give order-only dependents the chance to start too early
+			time.Sleep(20 * time.Millisecond)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		finished = append(finished, taskID)
+		if taskID == "ui#build" {
+			return errors.New("ui#build failed")
+		}
+		return nil
+	}, ExecOpts{Concurrency: 10, Parallel: true, ContinueOnError: true})
+	assert.Equal(t, len(errs), 1)
+	assert.ErrorContains(t, errs[0], "ui#build failed")
+	statuses := make(map[string]TaskStatus)
+	for _, result := range results {
+		statuses[result.TaskID] = result.Status
+	}
+	assert.DeepEqual(t, statuses, map[string]TaskStatus{
+		"ui#build":     TaskFailed,
+		"ui#lint":      TaskSucceeded,
+		"ui#test":      TaskSkipped,
+		"ui#typecheck": TaskSucceeded,
+	})
+	assert.Equal(t, finished[0], "ui#build")
+
+	// An order-only dependency can't make a cycle
+	afterTest := make(util.Set)
+	afterTest.Add("test")
+	dependOnLint := make(util.Set)
+	dependOnLint.Add("lint")
+	engine = newEngine(&Task{TopoDeps: make(util.Set), Deps: make(util.Set), OrderOnlyDeps: afterTest})
+	engine.AddTask(&Task{Name: "build", TopoDeps: make(util.Set), Deps: dependOnLint})
+	err = engine.Prepare(&EngineExecutionOptions{Packages: []string{"ui"}, TaskNames: []string{"test", "lint"}})
+	assert.ErrorContains(t, err, "invalid \"orderOnly\" dependencies")
+}
+
+func TestExecutePersistentOutsideConcurrency(t *testing.T) {
+	engine := NewEngine(&dag.AcyclicGraph{})
+	for _, taskID := range []string{ROOT_NODE_NAME, "ui#dev", "web#dev", "web#build"} {
+		engine.TaskGraph.Add(taskID)
+		if taskID != ROOT_NODE_NAME {
+			engine.TaskGraph.Connect(dag.BasicEdge(taskID, ROOT_NODE_NAME))
+		}
+	}
+	engine.AddTask(&Task{Name: "dev", Persistent: true})
+	engine.AddTask(&Task{Name: "build"})
+
+	// The dev servers keep running until the build is done, which only happens if
+	// they don't hold the only slot
+	built := make(chan struct{})
+	errs := engine.Execute(func(taskID string) error {
+		if taskID == "web#build" {
+			close(built)
+			return nil
+		}
+		select {
+		case <-built:
+			return nil
+		case <-time.After(5 * time.Second):
+			return fmt.Errorf("%v held the slot that web#build needed", taskID)
+		}
+	}, ExecOpts{Concurrency: 1, PersistentOutsideConcurrency: true})
+	assert.Equal(t, len(errs), 0)
+}
+
+func TestExecuteRetries(t *testing.T) {
+	engine := NewEngine(&dag.AcyclicGraph{})
+	for _, taskID := range []string{ROOT_NODE_NAME, "ui#test", "web#test", "web#deploy"} {
+		engine.TaskGraph.Add(taskID)
+	}
+	engine.TaskGraph.Connect(dag.BasicEdge("ui#test", ROOT_NODE_NAME))
+	engine.TaskGraph.Connect(dag.BasicEdge("web#test", ROOT_NODE_NAME))
+	engine.TaskGraph.Connect(dag.BasicEdge("web#deploy", "web#test"))
+	engine.AddTask(&Task{Name: "test", Retries: 2, RetryDelay: 10 * time.Millisecond})
+
+	var mu sync.Mutex
+	var lastAttempts []string
+	results, errs := engine.ExecuteWithResults(func(taskID string, result *TaskResult) error {
+		mu.Lock()
+		if result.LastAttempt {
+			lastAttempts = append(lastAttempts, fmt.Sprintf("%v@%v", taskID, result.Attempts))
+		}
+		mu.Unlock()
+		// ui#test always fails, web#test passes on its last attempt
+		if taskID == "ui#test" || (taskID == "web#test" && result.Attempts < 3) {
+			return fmt.Errorf("%v failed", taskID)
+		}
+		return nil
+	}, ExecOpts{Concurrency: 10, ContinueOnError: true})
+	assert.Equal(t, len(errs), 1)
+	assert.ErrorContains(t, errs[0], "ui#test failed")
+	sort.Strings(lastAttempts)
+	assert.DeepEqual(t, lastAttempts, []string{"ui#test@3", "web#deploy@1", "web#test@3"})
+	attempts := make(map[string]int)
+	for _, result := range results {
+		attempts[result.TaskID] = result.Attempts
+		if result.TaskID == "web#test" {
+			assert.Equal(t, result.Status, TaskSucceeded)
+			assert.Assert(t, result.Duration >= 20*time.Millisecond, "waits between attempts")
+		}
+	}
+	assert.DeepEqual(t, attempts, map[string]int{"ui#test": 3, "web#test": 3, "web#deploy": 1})
+}
+
+func TestPrepareAffected(t *testing.T) {
+	// web depends on ui, which depends on utils
+	graph := &dag.AcyclicGraph{}
+	graph.Add("utils")
+	graph.Add("ui")
+	graph.Add("web")
+	graph.Connect(dag.BasicEdge("ui", "utils"))
+	graph.Connect(dag.BasicEdge("web", "ui"))
+	engine := NewEngine(graph)
+	dependOnBuild := make(util.Set)
+	dependOnBuild.Add("build")
+	engine.AddTask(&Task{Name: "build", TopoDeps: dependOnBuild, Deps: make(util.Set)})
+	engine.AddTask(&Task{Name: "test", TopoDeps: make(util.Set), Deps: dependOnBuild})
+	err := engine.Prepare(&EngineExecutionOptions{Packages: []string{"utils", "ui", "web"}, TaskNames: []string{"build", "test"}})
+	assert.NilError(t, err)
+
+	changed := make(util.Set)
+	changed.Add("ui#build")
+	// Tasks that aren't in the graph are ignored
+	changed.Add("docs#build")
+	affected, err := engine.PrepareAffected(changed)
+	assert.NilError(t, err)
+
+	var visited []string
+	var mu sync.Mutex
+	errs := affected.Execute(func(taskID string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		visited = append(visited, taskID)
+		return nil
+	}, ExecOpts{Concurrency: 1})
+	assert.Equal(t, len(errs), 0)
+	sort.Strings(visited)
+	assert.DeepEqual(t, visited, []string{"ui#build", "ui#test", "web#build", "web#test"})
+	// The affected tasks keep their dependencies on each other
+	assert.Assert(t, affected.TaskGraph.DownEdges("web#build").Include("ui#build"))
+	assert.Assert(t, affected.TaskGraph.DownEdges("ui#build").Include(ROOT_NODE_NAME))
+	// The engine it was prepared from is unchanged
+	assert.Assert(t, engine.TaskGraph.HasVertex("utils#build"))
+	assert.Assert(t, engine.TaskGraph.DownEdges("ui#build").Include("utils#build"))
+}
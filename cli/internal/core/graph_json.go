@@ -0,0 +1,95 @@
+package core
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/pyr-sh/dag"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// taskGraphVersion is the version of the document returned by TaskGraphJSON. It
+// changes only if fields are removed or change meaning.
+const taskGraphVersion = 1
+
+// TaskGraphDocument is the resolved task graph, in a form for tooling to consume
+type TaskGraphDocument struct {
+	Version int             `json:"version"`
+	Nodes   []TaskGraphNode `json:"nodes"`
+	Edges   []TaskGraphEdge `json:"edges"`
+}
+
+// TaskGraphNode is a task in the task graph, along with the definition it was
+// resolved from
+type TaskGraphNode struct {
+	TaskID     string `json:"taskId"`
+	Package    string `json:"package"`
+	Task       string `json:"task"`
+	Persistent bool   `json:"persistent"`
+	// Deps and TopoDeps are the dependencies of the task's definition, within its
+	// package and in the packages it depends on respectively
+	Deps     []string `json:"deps"`
+	TopoDeps []string `json:"topoDeps"`
+}
+
+// TaskGraphEdge is a dependency of the task From on the task To
+type TaskGraphEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// TaskGraphJSON returns the task graph built by Prepare as a JSON document. Nodes
+// and edges are sorted, so that the same graph always produces the same document.
+func (e *Engine) TaskGraphJSON() ([]byte, error) {
+	doc := TaskGraphDocument{
+		Version: taskGraphVersion,
+		Nodes:   []TaskGraphNode{},
+		Edges:   []TaskGraphEdge{},
+	}
+	for _, v := range e.TaskGraph.Vertices() {
+		taskID := dag.VertexName(v)
+		if taskID == ROOT_NODE_NAME {
+			continue
+		}
+		pkg, taskName := util.GetPackageTaskFromId(taskID)
+		node := TaskGraphNode{
+			TaskID:   taskID,
+			Package:  pkg,
+			Task:     taskName,
+			Deps:     []string{},
+			TopoDeps: []string{},
+		}
+		if task, err := e.getTaskDefinition(pkg, taskName, taskID); err == nil {
+			node.Persistent = task.Persistent
+			node.Deps = sortedTaskNames(task.Deps)
+			node.TopoDeps = sortedTaskNames(task.TopoDeps)
+		}
+		doc.Nodes = append(doc.Nodes, node)
+	}
+	sort.Slice(doc.Nodes, func(i, j int) bool {
+		return doc.Nodes[i].TaskID < doc.Nodes[j].TaskID
+	})
+	for _, edge := range e.TaskGraph.Edges() {
+		from, to := dag.VertexName(edge.Source()), dag.VertexName(edge.Target())
+		if to == ROOT_NODE_NAME {
+			continue
+		}
+		doc.Edges = append(doc.Edges, TaskGraphEdge{From: from, To: to})
+	}
+	sort.Slice(doc.Edges, func(i, j int) bool {
+		if doc.Edges[i].From != doc.Edges[j].From {
+			return doc.Edges[i].From < doc.Edges[j].From
+		}
+		return doc.Edges[i].To < doc.Edges[j].To
+	})
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+func sortedTaskNames(tasks util.Set) []string {
+	names := tasks.UnsafeListOfStrings()
+	if names == nil {
+		return []string{}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,190 @@
+package core
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// _expectTimeout is how long a slot is held back for a task that is expected to
+// start waiting for one, in case it never does
+const _expectTimeout = 50 * time.Millisecond
+
+// scheduler hands out the slots of a semaphore to the waiting task with the
+// highest priority, rather than to whichever waiting task happens to get it.
+// Tasks with the same priority get slots in the order that they started waiting.
+type scheduler struct {
+	sema util.Semaphore
+	mu   sync.Mutex
+	// waiting are the tasks waiting for a slot, in the order that they started waiting
+	waiting []*schedulerWaiter
+	// expected are tasks whose dependencies have just completed, which will start
+	// waiting for a slot shortly. They get a slot ahead of waiting tasks with a lower
+	// priority, rather than finding that those tasks took the slots first.
+	expected map[string]expectedTask
+	// arrived are the tasks that have started waiting, and are never expected again
+	arrived util.Set
+	// wake is signaled when a task starts waiting or is no longer expected
+	wake chan struct{}
+}
+
+type schedulerWaiter struct {
+	priority int64
+	granted  chan struct{}
+}
+
+type expectedTask struct {
+	priority int64
+	deadline time.Time
+}
+
+// newScheduler creates a scheduler for the slots of sema, which runs until ctx is done
+func newScheduler(ctx context.Context, sema util.Semaphore) *scheduler {
+	s := &scheduler{
+		sema:     sema,
+		expected: make(map[string]expectedTask),
+		arrived:  make(util.Set),
+		wake:     make(chan struct{}, 1),
+	}
+	go s.run(ctx)
+	return s
+}
+
+// run acquires a slot whenever tasks are waiting, and grants it to the task with
+// the highest priority at the time the slot is acquired
+func (s *scheduler) run(ctx context.Context) {
+	held := false
+	for {
+		s.mu.Lock()
+		next, wait := s.next()
+		if next >= 0 && held {
+			w := s.waiting[next]
+			s.waiting = append(s.waiting[:next], s.waiting[next+1:]...)
+			close(w.granted)
+			held = false
+			s.mu.Unlock()
+			continue
+		}
+		waiting := len(s.waiting)
+		s.mu.Unlock()
+		if next >= 0 {
+			if err := s.sema.AcquireContext(ctx); err != nil {
+				return
+			}
+			held = true
+			continue
+		}
+		if held && waiting == 0 && wait == 0 {
+			// The tasks that were waiting gave up
+			s.sema.Release()
+			held = false
+		}
+		var timer *time.Timer
+		var timeout <-chan time.Time
+		if wait > 0 {
+			timer = time.NewTimer(wait)
+			timeout = timer.C
+		}
+		select {
+		case <-s.wake:
+		case <-timeout:
+		case <-ctx.Done():
+			if held {
+				s.sema.Release()
+			}
+			return
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// next returns the index of the waiting task that gets the next slot. If there is
+// none, or if an expected task has a higher priority, it returns -1, along with how
+// long to wait for the expected task. It must be called with mu held.
+func (s *scheduler) next() (int, time.Duration) {
+	next := -1
+	for i, w := range s.waiting {
+		if next < 0 || w.priority > s.waiting[next].priority {
+			next = i
+		}
+	}
+	now := time.Now()
+	var wait time.Duration
+	for taskID, expected := range s.expected {
+		if !now.Before(expected.deadline) {
+			delete(s.expected, taskID)
+		} else if next < 0 || expected.priority > s.waiting[next].priority {
+			if remaining := expected.deadline.Sub(now); wait == 0 || remaining < wait {
+				wait = remaining
+			}
+		}
+	}
+	if wait > 0 {
+		return -1, wait
+	}
+	return next, 0
+}
+
+// expect records that taskID will start waiting for a slot shortly, unless it
+// already has
+func (s *scheduler) expect(taskID string, priority int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.arrived.Includes(taskID) {
+		s.expected[taskID] = expectedTask{priority: priority, deadline: time.Now().Add(_expectTimeout)}
+	}
+}
+
+// arrive records that taskID is no longer expected, such as because it is waiting
+// for something else first
+func (s *scheduler) arrive(taskID string) {
+	s.mu.Lock()
+	s.arrived.Add(taskID)
+	delete(s.expected, taskID)
+	s.mu.Unlock()
+	s.signal()
+}
+
+func (s *scheduler) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// acquire blocks until a slot is granted to taskID, or until ctx is done, in which
+// case it returns ctx.Err() without holding a slot
+func (s *scheduler) acquire(ctx context.Context, taskID string, priority int64) error {
+	w := &schedulerWaiter{priority: priority, granted: make(chan struct{})}
+	s.mu.Lock()
+	s.arrived.Add(taskID)
+	delete(s.expected, taskID)
+	s.waiting = append(s.waiting, w)
+	s.mu.Unlock()
+	s.signal()
+	select {
+	case <-w.granted:
+		return nil
+	case <-ctx.Done():
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, waiting := range s.waiting {
+		if waiting == w {
+			s.waiting = append(s.waiting[:i], s.waiting[i+1:]...)
+			return ctx.Err()
+		}
+	}
+	// The slot was granted as ctx was done
+	s.sema.Release()
+	return ctx.Err()
+}
+
+// release returns a slot
+func (s *scheduler) release() {
+	s.sema.Release()
+}
@@ -0,0 +1,172 @@
+// Package deps holds the `turbo deps` command, which prints the internal
+// dependencies and dependents of a workspace
+package deps
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+	"github.com/pyr-sh/dag"
+	"github.com/spf13/cobra"
+	"github.com/vercel/turbo/cli/internal/cmdutil"
+	"github.com/vercel/turbo/cli/internal/context"
+	"github.com/vercel/turbo/cli/internal/errcode"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+type opts struct {
+	json bool
+}
+
+// Workspace is a workspace related to the one being inspected
+type Workspace struct {
+	Name string `json:"name"`
+	// Path is the directory of the workspace, relative to the root of the monorepo
+	Path string `json:"path"`
+	// Depth is the length of the shortest chain of dependencies between the two
+	// workspaces, so 1 means a direct dependency or dependent
+	Depth int `json:"depth"`
+}
+
+// Summary is the dependency information for a single workspace
+type Summary struct {
+	Name         string       `json:"name"`
+	Path         string       `json:"path"`
+	Dependencies []*Workspace `json:"dependencies"`
+	Dependents   []*Workspace `json:"dependents"`
+}
+
+// GetCmd returns the deps subcommand for use with cobra
+func GetCmd(helper *cmdutil.Helper) *cobra.Command {
+	opts := &opts{}
+	cmd := &cobra.Command{
+		Use:   "deps <workspace> [<flags>]",
+		Short: "Print the internal dependencies and dependents of a workspace",
+		Long: `Print the internal dependencies and dependents of a workspace.
+
+Both direct and transitive relationships are listed, along with their depth
+in the graph, so that scripts can rely on turbo's view of the monorepo.`,
+		Args:                  cobra.ExactArgs(1),
+		SilenceUsage:          true,
+		SilenceErrors:         true,
+		DisableFlagsInUseLine: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, err := helper.GetCmdBase(cmd.Flags())
+			if err != nil {
+				return err
+			}
+			if err := deps(base, opts, args[0]); err != nil {
+				base.LogError("deps failed: %w", err)
+				return err
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&opts.json, "json", false, "Print the dependencies as JSON")
+	return cmd
+}
+
+func deps(base *cmdutil.CmdBase, opts *opts, workspace string) error {
+	rootPackageJSON, err := fs.ReadPackageJSON(base.RepoRoot.UntypedJoin("package.json"))
+	if err != nil {
+		return errcode.Wrap(errcode.InvalidPackageJSON, fmt.Errorf("failed to read package.json: %w", err))
+	}
+	turboJSON, err := fs.ReadTurboConfig(base.RepoRoot, rootPackageJSON)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return errcode.Wrap(errcode.InvalidTurboJSON, err)
+	}
+	ctx, _, err := context.BuildPackageGraphWithConfig(base.RepoRoot, rootPackageJSON, turboJSON)
+	if err != nil {
+		var warnings *context.Warnings
+		if !errors.As(err, &warnings) {
+			return errcode.Wrap(errcode.InvalidPackageGraph, err)
+		}
+		base.Logger.Warn("issues occurred when constructing package graph", "warnings", err)
+	}
+
+	summary, err := Summarize(ctx, workspace)
+	if err != nil {
+		return errcode.Wrap(errcode.InvalidArguments, err)
+	}
+	if opts.json {
+		bytes, err := json.MarshalIndent(summary, "", "  ")
+		if err != nil {
+			return err
+		}
+		base.UI.Output(string(bytes))
+		return nil
+	}
+
+	base.UI.Output(util.Sprintf("${BOLD}%v${RESET} ${GREY}(%v)${RESET}", summary.Name, summary.Path))
+	for _, section := range []struct {
+		title      string
+		workspaces []*Workspace
+	}{
+		{"Dependencies", summary.Dependencies},
+		{"Dependents", summary.Dependents},
+	} {
+		base.UI.Output("")
+		base.UI.Output(util.Sprintf("${BOLD}%v${RESET} (%v)", section.title, len(section.workspaces)))
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, related := range section.workspaces {
+			fmt.Fprintf(w, "  %v\t%v\t%v\n", related.Name, related.Depth, related.Path)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Summarize collects the dependencies and dependents of a workspace from the package graph
+func Summarize(ctx *context.Context, workspace string) (*Summary, error) {
+	pkg, ok := ctx.PackageInfos[workspace]
+	if !ok || workspace == util.RootPkgName {
+		return nil, fmt.Errorf("workspace %v not found", workspace)
+	}
+	return &Summary{
+		Name:         workspace,
+		Path:         pkg.Dir.ToUnixPath().ToString(),
+		Dependencies: walk(ctx, workspace, ctx.TopologicalGraph.DownEdges),
+		Dependents:   walk(ctx, workspace, ctx.TopologicalGraph.UpEdges),
+	}, nil
+}
+
+// walk does a breadth-first traversal of the graph from workspace, so that each
+// related workspace is found at its shortest depth
+func walk(ctx *context.Context, workspace string, edges func(v dag.Vertex) dag.Set) []*Workspace {
+	seen := util.SetFromStrings([]string{workspace})
+	related := []*Workspace{}
+	frontier := []string{workspace}
+	for depth := 1; len(frontier) > 0; depth++ {
+		var next []string
+		for _, current := range frontier {
+			for _, v := range edges(current) {
+				name := dag.VertexName(v)
+				if name == ctx.RootNode || name == util.RootPkgName || seen.Includes(name) {
+					continue
+				}
+				seen.Add(name)
+				next = append(next, name)
+				path := ""
+				if pkg, ok := ctx.PackageInfos[name]; ok {
+					path = pkg.Dir.ToUnixPath().ToString()
+				}
+				related = append(related, &Workspace{Name: name, Path: path, Depth: depth})
+			}
+		}
+		frontier = next
+	}
+	sort.Slice(related, func(i, j int) bool {
+		if related[i].Depth != related[j].Depth {
+			return related[i].Depth < related[j].Depth
+		}
+		return related[i].Name < related[j].Name
+	})
+	return related
+}
@@ -0,0 +1,56 @@
+package deps
+
+import (
+	"testing"
+
+	"github.com/pyr-sh/dag"
+	"github.com/vercel/turbo/cli/internal/context"
+	"github.com/vercel/turbo/cli/internal/core"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/util"
+	"gotest.tools/v3/assert"
+)
+
+func Test_Summarize(t *testing.T) {
+	ctx := &context.Context{
+		PackageInfos: make(map[interface{}]*fs.PackageJSON),
+		RootNode:     core.ROOT_NODE_NAME,
+	}
+	// web -> ui -> tsconfig, docs -> ui, web -> tsconfig
+	for _, name := range []string{"web", "docs", "ui", "tsconfig"} {
+		ctx.PackageInfos[name] = &fs.PackageJSON{Name: name, Dir: turbopath.AnchoredSystemPath("packages/" + name)}
+		ctx.TopologicalGraph.Add(name)
+	}
+	ctx.PackageInfos[util.RootPkgName] = &fs.PackageJSON{}
+	ctx.TopologicalGraph.Connect(dag.BasicEdge("web", "ui"))
+	ctx.TopologicalGraph.Connect(dag.BasicEdge("web", "tsconfig"))
+	ctx.TopologicalGraph.Connect(dag.BasicEdge("docs", "ui"))
+	ctx.TopologicalGraph.Connect(dag.BasicEdge("ui", "tsconfig"))
+	ctx.TopologicalGraph.Connect(dag.BasicEdge("tsconfig", core.ROOT_NODE_NAME))
+
+	summary, err := Summarize(ctx, "ui")
+	assert.NilError(t, err)
+	assert.Equal(t, summary.Path, "packages/ui")
+	assert.DeepEqual(t, summary.Dependencies, []*Workspace{
+		{Name: "tsconfig", Path: "packages/tsconfig", Depth: 1},
+	})
+	assert.DeepEqual(t, summary.Dependents, []*Workspace{
+		{Name: "docs", Path: "packages/docs", Depth: 1},
+		{Name: "web", Path: "packages/web", Depth: 1},
+	})
+
+	summary, err = Summarize(ctx, "tsconfig")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, summary.Dependencies, []*Workspace{})
+	assert.DeepEqual(t, summary.Dependents, []*Workspace{
+		{Name: "ui", Path: "packages/ui", Depth: 1},
+		{Name: "web", Path: "packages/web", Depth: 1},
+		{Name: "docs", Path: "packages/docs", Depth: 2},
+	})
+
+	_, err = Summarize(ctx, "missing")
+	assert.ErrorContains(t, err, "workspace missing not found")
+	_, err = Summarize(ctx, util.RootPkgName)
+	assert.ErrorContains(t, err, "not found")
+}
@@ -0,0 +1,111 @@
+package doctor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+
+	"github.com/spf13/cobra"
+	"github.com/vercel/turbo/cli/internal/cmdutil"
+	"github.com/vercel/turbo/cli/internal/daemon"
+	"github.com/vercel/turbo/cli/internal/daemonclient"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+type opts struct {
+	json     bool
+	cacheDir string
+}
+
+// GetCmd returns the doctor command
+func GetCmd(helper *cmdutil.Helper) *cobra.Command {
+	opts := &opts{}
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Check that turbo can work well in this repository",
+		Long: `Check that turbo can work well in this repository and on this machine.
+
+The checks cover the validity of the configuration, the package manager, git,
+the daemon, the local cache directory, the Remote Cache, and the system clock.
+Each problem that is found comes with a suggested fix. turbo doctor fails if
+any check finds an error, but not for warnings.`,
+		Args:          cobra.NoArgs,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, err := helper.GetCmdBase(cmd.Flags())
+			if err != nil {
+				return err
+			}
+			if err := doctor(cmd.Context(), base, opts); err != nil {
+				base.LogError("%w", err)
+				return err
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&opts.json, "json", false, "Print the results of the checks as JSON")
+	cmd.Flags().StringVar(&opts.cacheDir, "cache-dir", "", "The filesystem cache directory to check, if it's set for turbo run")
+	return cmd
+}
+
+func doctor(ctx context.Context, base *cmdutil.CmdBase, opts *opts) error {
+	c := &checker{
+		repoRoot: base.RepoRoot,
+		cacheDir: opts.cacheDir,
+		remote:   base.APIClient,
+		daemonStatus: func(ctx context.Context) (*daemonclient.Status, error) {
+			client, err := daemon.GetClient(ctx, base.RepoRoot, base.Logger, base.TurboVersion, daemon.ClientOpts{
+				// Checking on the daemon shouldn't start it
+				DontStart: true,
+			})
+			if err != nil {
+				return nil, err
+			}
+			defer func() { _ = client.Close() }()
+			return daemonclient.New(client).Status(ctx)
+		},
+		run: func(name string, args ...string) (string, error) {
+			cmd := exec.Command(name, args...)
+			cmd.Dir = base.RepoRoot.ToString()
+			output, err := cmd.Output()
+			return string(output), err
+		},
+	}
+	report := c.report(ctx, base.TurboVersion)
+	if opts.json {
+		bytes, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		base.UI.Output(string(bytes))
+	} else {
+		printReport(base, report)
+	}
+	if errors := report.count(StatusError); errors > 0 {
+		return fmt.Errorf("%v of %v checks found errors", errors, len(report.Checks))
+	}
+	return nil
+}
+
+func printReport(base *cmdutil.CmdBase, report *Report) {
+	for _, check := range report.Checks {
+		symbol := "${GREEN}✓"
+		switch check.Status {
+		case StatusWarning:
+			symbol = "${YELLOW}!"
+		case StatusError:
+			symbol = "${RED}✗"
+		case StatusSkipped:
+			symbol = "${GREY}-"
+		}
+		// Messages can hold environment variables, such as "$NODE_ENV", that util.Sprintf would expand
+		base.UI.Output(util.Sprintf("%v %-16v${RESET} ", symbol, check.Name) + check.Message)
+		if check.Fix != "" && check.Status != StatusOK {
+			base.UI.Output(util.Sprintf("${GREY}  %-16v → %v${RESET}", "", check.Fix))
+		}
+	}
+	base.UI.Output("")
+	base.UI.Output(util.Sprintf("${BOLD}%v ok${RESET}${GRAY}, %v warnings, %v errors, %v skipped${RESET}", report.count(StatusOK), report.count(StatusWarning), report.count(StatusError), report.count(StatusSkipped)))
+}
@@ -0,0 +1,362 @@
+// Package doctor holds the `turbo doctor` command, which checks that turbo can work
+// well in a repository and on the current machine
+package doctor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/vercel/turbo/cli/internal/cache"
+	"github.com/vercel/turbo/cli/internal/client"
+	turbocontext "github.com/vercel/turbo/cli/internal/context"
+	"github.com/vercel/turbo/cli/internal/daemon/connector"
+	"github.com/vercel/turbo/cli/internal/daemonclient"
+	"github.com/vercel/turbo/cli/internal/errcode"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/packagemanager"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// Status is the outcome of a check
+type Status string
+
+const (
+	// StatusOK means nothing needs to be done
+	StatusOK Status = "ok"
+	// StatusWarning means turbo works, but slower or with fewer features than it could
+	StatusWarning Status = "warning"
+	// StatusError means turbo is likely to fail
+	StatusError Status = "error"
+	// StatusSkipped means the check doesn't apply, such as to an unlinked Remote Cache
+	StatusSkipped Status = "skipped"
+)
+
+// _minFreeSpace is how much free space the cache directory should have
+const _minFreeSpace = 1 << 30
+
+// _daemonTimeout is how long the daemon has to respond before it's unresponsive
+const _daemonTimeout = 5 * time.Second
+
+// Check is the result of one health check
+type Check struct {
+	Name    string `json:"name"`
+	Status  Status `json:"status"`
+	Message string `json:"message"`
+	// Fix suggests how to resolve a warning or an error
+	Fix string `json:"fix,omitempty"`
+}
+
+// Report is the result of every health check
+type Report struct {
+	TurboVersion string  `json:"turboVersion"`
+	Checks       []Check `json:"checks"`
+}
+
+// count returns the number of checks with the given status
+func (r *Report) count(status Status) int {
+	count := 0
+	for _, check := range r.Checks {
+		if check.Status == status {
+			count++
+		}
+	}
+	return count
+}
+
+// remoteClient is the subset of the API client used to check the Remote Cache
+type remoteClient interface {
+	HasUser() bool
+	IsLinked() bool
+	GetCachingStatus() (util.CachingStatus, error)
+	ClockSkew() (time.Duration, bool)
+	ClockSkewWarning() string
+}
+
+// checker runs the health checks. Checks run in order, and later checks use what
+// earlier ones found, such as the root package.json.
+type checker struct {
+	repoRoot turbopath.AbsoluteSystemPath
+	// cacheDir is the --cache-dir flag, which takes precedence over turbo.json
+	cacheDir string
+	remote   remoteClient
+	// daemonStatus contacts the daemon if it's running, and returns
+	// connector.ErrDaemonNotRunning if it isn't
+	daemonStatus func(ctx context.Context) (*daemonclient.Status, error)
+	// run runs a program in the repository and returns its output
+	run func(name string, args ...string) (string, error)
+
+	rootPackageJSON *fs.PackageJSON
+	turboJSON       *fs.TurboJSON
+}
+
+func (c *checker) report(ctx context.Context, turboVersion string) *Report {
+	return &Report{
+		TurboVersion: turboVersion,
+		Checks: []Check{
+			c.checkConfig(),
+			c.checkPackageManager(),
+			c.checkGit(),
+			c.checkDaemon(ctx),
+			c.checkCacheDir(),
+			// The clock is compared to the Remote Cache server's in its responses
+			c.checkRemoteCache(),
+			c.checkClockSkew(),
+		},
+	}
+}
+
+func (c *checker) checkConfig() Check {
+	check := Check{Name: "config"}
+	rootPackageJSON, err := fs.ReadPackageJSON(c.repoRoot.UntypedJoin("package.json"))
+	if err != nil {
+		check.Status = StatusError
+		check.Message = fmt.Sprintf("failed to read package.json: %v", err)
+		check.Fix = "Run turbo from the root of the repository, or pass --cwd"
+		return check
+	}
+	c.rootPackageJSON = rootPackageJSON
+	turboJSON, err := fs.ReadTurboConfig(c.repoRoot, rootPackageJSON)
+	if errors.Is(err, os.ErrNotExist) {
+		check.Status = StatusWarning
+		check.Message = "there is no turbo.json"
+		check.Fix = "Create a turbo.json that defines the pipeline of tasks, see https://turbo.build/repo/docs/reference/configuration"
+		return check
+	} else if err != nil {
+		check.Status = StatusError
+		check.Message = fmt.Sprintf("turbo.json is invalid: %v", err)
+		check.Fix = "Fix turbo.json, see https://turbo.build/repo/docs/reference/configuration"
+		return check
+	}
+	c.turboJSON = turboJSON
+	if len(turboJSON.Roots) > 0 {
+		check.Status = StatusOK
+		check.Message = fmt.Sprintf("turbo.json is valid, with %v roots", len(turboJSON.Roots))
+		return check
+	}
+	pkgDepGraph, _, err := turbocontext.BuildPackageGraphWithConfig(c.repoRoot, rootPackageJSON, turboJSON)
+	if err != nil && errcode.Of(err) == errcode.InvalidTurboJSON {
+		check.Status = StatusError
+		check.Message = fmt.Sprintf("a workspace config is invalid: %v", err)
+		check.Fix = "Fix the \"turbo\" key in the package.json of the workspace, see https://turbo.build/repo/docs/reference/configuration"
+		return check
+	} else if err != nil {
+		check.Status = StatusError
+		check.Message = fmt.Sprintf("the workspaces are invalid: %v", err)
+		check.Fix = "Check the workspaces of the package manager, and that each workspace has a unique name"
+		return check
+	}
+	if len(turboJSON.Migrations) > 0 {
+		check.Status = StatusWarning
+		check.Message = fmt.Sprintf("the config has %v legacy values, such as %v", len(turboJSON.Migrations), turboJSON.Migrations[0].Message)
+		check.Fix = "Run turbo migrate-config to rewrite the config to the current schema"
+		return check
+	}
+	check.Status = StatusOK
+	// The graph includes the root of the repository
+	check.Message = fmt.Sprintf("turbo.json and %v workspaces are valid", len(pkgDepGraph.PackageInfos)-1)
+	return check
+}
+
+func (c *checker) checkPackageManager() Check {
+	check := Check{Name: "package-manager"}
+	if c.rootPackageJSON == nil {
+		check.Status = StatusSkipped
+		check.Message = "package.json could not be read"
+		return check
+	}
+	pm, err := packagemanager.GetPackageManager(c.repoRoot, c.rootPackageJSON)
+	if err != nil {
+		check.Status = StatusError
+		check.Message = err.Error()
+		check.Fix = `Set "packageManager" in package.json, such as "packageManager": "npm@9.5.0"`
+		return check
+	}
+	installed, err := c.run(pm.Command, "--version")
+	if err != nil {
+		check.Status = StatusError
+		check.Message = fmt.Sprintf("%v is used, but is not installed", pm.Command)
+		check.Fix = fmt.Sprintf("Install %v, or run `corepack enable`", pm.Command)
+		return check
+	}
+	installed = strings.TrimPrefix(strings.TrimSpace(installed), "v")
+	check.Status = StatusOK
+	check.Message = fmt.Sprintf("%v %v", pm.Command, installed)
+	if c.rootPackageJSON.PackageManager == "" {
+		return check
+	}
+	manager, declared, err := packagemanager.ParsePackageManagerString(c.rootPackageJSON.PackageManager)
+	if err != nil {
+		check.Status = StatusWarning
+		check.Message = err.Error()
+		check.Fix = `Set "packageManager" in package.json to <name>@<version>, such as "npm@9.5.0"`
+		return check
+	}
+	if matches, err := pm.Matches(manager, declared); !matches || err != nil {
+		check.Status = StatusWarning
+		check.Message = fmt.Sprintf("turbo doesn't support %v, and detected %v from the lockfile instead", c.rootPackageJSON.PackageManager, pm.Name)
+		check.Fix = "Upgrade the package manager to a version that turbo supports"
+		return check
+	}
+	if installed != declared {
+		check.Status = StatusWarning
+		check.Message = fmt.Sprintf("package.json declares %v, but %v %v is installed", c.rootPackageJSON.PackageManager, pm.Command, installed)
+		check.Fix = fmt.Sprintf("Install %v, or run `corepack enable` to use the declared version", c.rootPackageJSON.PackageManager)
+	}
+	return check
+}
+
+func (c *checker) checkGit() Check {
+	check := Check{Name: "git"}
+	version, err := c.run("git", "--version")
+	if err != nil {
+		check.Status = StatusError
+		check.Message = "git is not installed"
+		check.Fix = "Install git. Without it, turbo hashes files more slowly, and --filter can't select changed workspaces"
+		return check
+	}
+	if _, err := c.run("git", "rev-parse", "--is-inside-work-tree"); err != nil {
+		check.Status = StatusWarning
+		check.Message = fmt.Sprintf("%v is not in a git repository", c.repoRoot)
+		check.Fix = "Run turbo in a git checkout, so that files are hashed with git"
+		return check
+	}
+	if shallow, err := c.run("git", "rev-parse", "--is-shallow-repository"); err == nil && strings.TrimSpace(shallow) == "true" {
+		check.Status = StatusWarning
+		check.Message = "the repository is a shallow clone"
+		check.Fix = "Fetch the full history, such as with fetch-depth: 0 in actions/checkout, so that --filter=[<ref>] can find what changed since <ref>"
+		return check
+	}
+	check.Status = StatusOK
+	check.Message = strings.TrimSpace(version)
+	return check
+}
+
+func (c *checker) checkDaemon(ctx context.Context) Check {
+	check := Check{Name: "daemon"}
+	ctx, cancel := context.WithTimeout(ctx, _daemonTimeout)
+	defer cancel()
+	status, err := c.daemonStatus(ctx)
+	if errors.Is(err, connector.ErrDaemonNotRunning) {
+		check.Status = StatusOK
+		check.Message = "not running, it starts with the next run"
+		return check
+	} else if err != nil {
+		check.Status = StatusError
+		check.Message = fmt.Sprintf("the daemon is not responding: %v", err)
+		check.Fix = "Run `turbo daemon restart`, or pass --no-daemon to `turbo run`"
+		return check
+	}
+	check.Status = StatusOK
+	uptime := time.Duration(status.UptimeMs) * time.Millisecond
+	check.Message = fmt.Sprintf("responding, up for %v", uptime.Truncate(time.Second))
+	return check
+}
+
+func (c *checker) checkCacheDir() Check {
+	check := Check{Name: "cache-dir"}
+	override := c.cacheDir
+	if override == "" && c.turboJSON != nil {
+		override = c.turboJSON.Defaults.CacheDir
+	}
+	dir := cache.ResolveCacheDir(cache.Opts{OverrideDir: override}, c.repoRoot)
+	if !fs.IsWritable(dir) {
+		check.Status = StatusError
+		check.Message = fmt.Sprintf("%v is not writable", dir)
+		check.Fix = `Set --cache-dir, or "cacheDir" in the defaults of turbo.json, to a writable directory`
+		return check
+	}
+	// The cache directory is created on first use, on its closest existing parent's filesystem
+	existing := dir.ToString()
+	for !fs.PathExists(existing) && filepath.Dir(existing) != existing {
+		existing = filepath.Dir(existing)
+	}
+	space, err := fs.GetDiskSpace(fs.AbsoluteSystemPathFromUpstream(existing))
+	if err != nil {
+		check.Status = StatusOK
+		check.Message = fmt.Sprintf("%v is writable", dir)
+		return check
+	}
+	if space.Available < _minFreeSpace {
+		check.Status = StatusWarning
+		check.Message = fmt.Sprintf("%v is writable, but only %v is free", dir, util.FormatByteSize(int64(space.Available)))
+		check.Fix = "Free up disk space, or move the cache with --cache-dir"
+		return check
+	}
+	check.Status = StatusOK
+	check.Message = fmt.Sprintf("%v is writable, with %v free", dir, util.FormatByteSize(int64(space.Available)))
+	return check
+}
+
+func (c *checker) checkRemoteCache() Check {
+	check := Check{Name: "remote-cache"}
+	if !c.remote.HasUser() {
+		check.Status = StatusSkipped
+		check.Message = "not logged in"
+		check.Fix = "Run `turbo login` and `turbo link` to use Remote Caching"
+		return check
+	}
+	if !c.remote.IsLinked() {
+		check.Status = StatusSkipped
+		check.Message = "not linked to a team"
+		check.Fix = "Run `turbo link` to use Remote Caching"
+		return check
+	}
+	start := time.Now()
+	status, err := c.remote.GetCachingStatus()
+	elapsed := time.Since(start)
+	var statusErr *client.StatusError
+	if errors.As(err, &statusErr) && (statusErr.StatusCode == http.StatusUnauthorized || statusErr.StatusCode == http.StatusForbidden) {
+		check.Status = StatusError
+		check.Message = fmt.Sprintf("the token was rejected (%v)", statusErr.StatusCode)
+		check.Fix = "Run `turbo login` again, or set TURBO_TOKEN to a valid token"
+		return check
+	} else if err != nil {
+		check.Status = StatusError
+		check.Message = fmt.Sprintf("the Remote Cache can't be reached: %v", err)
+		check.Fix = "Check the network connection, and the URL set with --api or TURBO_API"
+		return check
+	}
+	switch status {
+	case util.CachingStatusEnabled:
+		check.Status = StatusOK
+		check.Message = fmt.Sprintf("reachable and authenticated, responded in %v", elapsed.Round(time.Millisecond))
+	case util.CachingStatusOverLimit, util.CachingStatusPaused:
+		check.Status = StatusWarning
+		check.Message = "Remote Caching is paused for the team, because of its usage limits"
+		check.Fix = "Review the team's usage and spending limits"
+	default:
+		check.Status = StatusWarning
+		check.Message = "Remote Caching is disabled for the team"
+		check.Fix = "Enable Remote Caching in the team's settings"
+	}
+	return check
+}
+
+func (c *checker) checkClockSkew() Check {
+	check := Check{Name: "clock-skew"}
+	skew, ok := c.remote.ClockSkew()
+	if !ok {
+		check.Status = StatusSkipped
+		check.Message = "there is no response from the Remote Cache to compare with"
+		return check
+	}
+	if warning := c.remote.ClockSkewWarning(); warning != "" {
+		check.Status = StatusWarning
+		check.Message = warning
+		check.Fix = "Synchronize the system clock, such as by enabling NTP"
+		return check
+	}
+	if skew < 0 {
+		skew = -skew
+	}
+	check.Status = StatusOK
+	check.Message = fmt.Sprintf("within %v of the Remote Cache server", skew.Round(time.Second))
+	return check
+}
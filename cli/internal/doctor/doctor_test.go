@@ -0,0 +1,184 @@
+package doctor
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vercel/turbo/cli/internal/client"
+	"github.com/vercel/turbo/cli/internal/daemon/connector"
+	"github.com/vercel/turbo/cli/internal/daemonclient"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/util"
+	"gotest.tools/v3/assert"
+)
+
+type fakeRemote struct {
+	hasUser  bool
+	isLinked bool
+	status   util.CachingStatus
+	err      error
+	skew     time.Duration
+	measured bool
+}
+
+func (f *fakeRemote) HasUser() bool  { return f.hasUser }
+func (f *fakeRemote) IsLinked() bool { return f.isLinked }
+func (f *fakeRemote) GetCachingStatus() (util.CachingStatus, error) {
+	if f.err == nil {
+		f.measured = true
+	}
+	return f.status, f.err
+}
+func (f *fakeRemote) ClockSkew() (time.Duration, bool) { return f.skew, f.measured }
+func (f *fakeRemote) ClockSkewWarning() string {
+	if f.skew > 5*time.Minute {
+		return "the system clock is behind the Remote Cache server"
+	}
+	return ""
+}
+
+// fakeCommands answers programs run by the checks, keyed by their command line
+type fakeCommands map[string]string
+
+func (f fakeCommands) run(name string, args ...string) (string, error) {
+	line := strings.Join(append([]string{name}, args...), " ")
+	if output, ok := f[line]; ok {
+		return output, nil
+	}
+	return "", errors.New("exit status 127")
+}
+
+func writeRepo(t *testing.T, files map[string]string) turbopath.AbsoluteSystemPath {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	for name, contents := range files {
+		path := repoRoot.UntypedJoin(name)
+		assert.NilError(t, path.EnsureDir(), "EnsureDir")
+		assert.NilError(t, path.WriteFile([]byte(contents), 0644), "WriteFile")
+	}
+	return repoRoot
+}
+
+func newChecker(repoRoot turbopath.AbsoluteSystemPath, remote *fakeRemote, commands fakeCommands) *checker {
+	return &checker{
+		repoRoot: repoRoot,
+		remote:   remote,
+		daemonStatus: func(ctx context.Context) (*daemonclient.Status, error) {
+			return nil, connector.ErrDaemonNotRunning
+		},
+		run: commands.run,
+	}
+}
+
+func statuses(report *Report) map[string]Status {
+	result := make(map[string]Status)
+	for _, check := range report.Checks {
+		result[check.Name] = check.Status
+	}
+	return result
+}
+
+func TestDoctorHealthy(t *testing.T) {
+	repoRoot := writeRepo(t, map[string]string{
+		"package.json":            `{"name": "root", "packageManager": "npm@9.5.0", "workspaces": ["packages/*"]}`,
+		"package-lock.json":       `{"lockfileVersion": 2, "packages": {"": {}}}`,
+		"turbo.json":              `{"pipeline": {"build": {}}}`,
+		"packages/a/package.json": `{"name": "a"}`,
+	})
+	remote := &fakeRemote{hasUser: true, isLinked: true, status: util.CachingStatusEnabled}
+	c := newChecker(repoRoot, remote, fakeCommands{
+		"npm --version":                         "9.5.0\n",
+		"git --version":                         "git version 2.39.2\n",
+		"git rev-parse --is-inside-work-tree":   "true\n",
+		"git rev-parse --is-shallow-repository": "false\n",
+	})
+	report := c.report(context.Background(), "1.0.0")
+	assert.DeepEqual(t, statuses(report), map[string]Status{
+		"config":          StatusOK,
+		"package-manager": StatusOK,
+		"git":             StatusOK,
+		"daemon":          StatusOK,
+		"cache-dir":       StatusOK,
+		"remote-cache":    StatusOK,
+		"clock-skew":      StatusOK,
+	})
+	assert.Equal(t, report.Checks[0].Message, "turbo.json and 1 workspaces are valid")
+}
+
+func TestDoctorLegacyConfig(t *testing.T) {
+	repoRoot := writeRepo(t, map[string]string{
+		"package.json":            `{"name": "root", "packageManager": "npm@9.5.0", "workspaces": ["packages/*"], "turbo": {"pipeline": {"build": {"dependsOn": ["$NODE_ENV"]}}}}`,
+		"package-lock.json":       `{"lockfileVersion": 2, "packages": {"": {}}}`,
+		"packages/a/package.json": `{"name": "a"}`,
+	})
+	c := newChecker(repoRoot, &fakeRemote{}, fakeCommands{})
+	check := c.checkConfig()
+	assert.Equal(t, check.Status, StatusWarning)
+	assert.Equal(t, check.Message, `the config has 2 legacy values, such as package.json: "turbo" was read as turbo.json`)
+}
+
+func TestDoctorProblems(t *testing.T) {
+	repoRoot := writeRepo(t, map[string]string{
+		"package.json":      `{"name": "root", "packageManager": "npm@9.5.0", "workspaces": ["packages/*"]}`,
+		"package-lock.json": `{}`,
+		"turbo.json":        `{"pipeline": {"build": {"outputs": "dist/**"}}}`,
+	})
+	remote := &fakeRemote{
+		hasUser:  true,
+		isLinked: true,
+		err:      &client.StatusError{StatusCode: 403, Message: "forbidden"},
+	}
+	c := newChecker(repoRoot, remote, fakeCommands{
+		"npm --version":                         "8.1.0\n",
+		"git --version":                         "git version 2.39.2\n",
+		"git rev-parse --is-inside-work-tree":   "true\n",
+		"git rev-parse --is-shallow-repository": "true\n",
+	})
+	c.daemonStatus = func(ctx context.Context) (*daemonclient.Status, error) {
+		return nil, context.DeadlineExceeded
+	}
+	report := c.report(context.Background(), "1.0.0")
+	assert.DeepEqual(t, statuses(report), map[string]Status{
+		"config":          StatusError,
+		"package-manager": StatusWarning,
+		"git":             StatusWarning,
+		"daemon":          StatusError,
+		"cache-dir":       StatusOK,
+		"remote-cache":    StatusError,
+		"clock-skew":      StatusSkipped,
+	})
+	for _, check := range report.Checks {
+		if check.Status == StatusError || check.Status == StatusWarning {
+			assert.Assert(t, check.Fix != "", "expected a fix for %v", check.Name)
+		}
+	}
+	assert.Equal(t, report.count(StatusError), 3)
+}
+
+func TestDoctorWithoutGitOrRemote(t *testing.T) {
+	repoRoot := writeRepo(t, map[string]string{
+		"package.json":      `{"name": "root"}`,
+		"package-lock.json": `{}`,
+	})
+	c := newChecker(repoRoot, &fakeRemote{}, fakeCommands{"npm --version": "9.5.0"})
+	report := c.report(context.Background(), "1.0.0")
+	assert.DeepEqual(t, statuses(report), map[string]Status{
+		"config":          StatusWarning,
+		"package-manager": StatusOK,
+		"git":             StatusError,
+		"daemon":          StatusOK,
+		"cache-dir":       StatusOK,
+		"remote-cache":    StatusSkipped,
+		"clock-skew":      StatusSkipped,
+	})
+}
+
+func TestDoctorClockSkew(t *testing.T) {
+	remote := &fakeRemote{hasUser: true, isLinked: true, status: util.CachingStatusEnabled, skew: time.Hour}
+	c := newChecker(writeRepo(t, nil), remote, fakeCommands{})
+	assert.Equal(t, c.checkRemoteCache().Status, StatusOK)
+	assert.Equal(t, c.checkClockSkew().Status, StatusWarning)
+}
@@ -15,6 +15,7 @@ import (
 	"runtime"
 	"strings"
 	"testing"
+	"testing/fstest"
 )
 
 type MatchTest struct {
@@ -343,6 +344,40 @@ func TestGlobWalk(t *testing.T) {
 	}
 }
 
+func TestGlobWalkSkipDir(t *testing.T) {
+	fsys := fstest.MapFS{
+		"dist/a.js":        {},
+		"dist/skip/b.js":   {},
+		"dist/skip/c/d.js": {},
+		"dist/keep/e.js":   {},
+		"dist/keep.js.map": {},
+		"other/skip/f.js":  {},
+		"other/skip/g.js":  {},
+		"other/h.js":       {},
+	}
+	for _, pattern := range []string{"dist/**", "{dist,other}/**"} {
+		var matches []string
+		err := GlobWalk(fsys, pattern, func(p string, d fs.DirEntry) error {
+			matches = append(matches, p)
+			if d.IsDir() && path.Base(p) == "skip" {
+				return fs.SkipDir
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("GlobWalk(%#q) error = %v", pattern, err)
+		}
+		for _, match := range matches {
+			if strings.HasPrefix(match, "dist/skip/") {
+				t.Errorf("GlobWalk(%#q) walked %v, within a skipped directory", pattern, match)
+			}
+		}
+		if len(matches) == 0 || matches[0] != "dist" {
+			t.Errorf("GlobWalk(%#q) = %v, want it to start with dist", pattern, matches)
+		}
+	}
+}
+
 func testGlobWalkWith(t *testing.T, idx int, tt MatchTest, fsys fs.FS) {
 	defer func() {
 		if r := recover(); r != nil {
@@ -27,7 +27,9 @@ type GlobWalkFunc func(path string, d fs.DirEntry) error
 // GlobWalk ignores file system errors such as I/O errors reading directories.
 // GlobWalk may return ErrBadPattern, reporting that the pattern is malformed.
 // Additionally, if the callback function `fn` returns an error, GlobWalk will
-// exit immediately and return that error.
+// exit immediately and return that error, unless it is fs.SkipDir. If `fn`
+// returns fs.SkipDir for a directory matched by `**`, GlobWalk doesn't walk
+// its contents. fs.SkipDir is otherwise ignored.
 //
 // Like Glob(), this function assumes that your pattern uses `/` as the path
 // separator even if that's not correct for your OS (like Windows). If you
@@ -48,8 +50,7 @@ func doGlobWalk(fsys fs.FS, pattern string, firstSegment bool, fn GlobWalkFunc)
 		// pattern exist?
 		info, err := fs.Stat(fsys, pattern)
 		if err == nil {
-			err = fn(pattern, newDirEntryFromFileInfo(info))
-			return err
+			return skipDirIsNil(fn(pattern, newDirEntryFromFileInfo(info)))
 		}
 		// ignore IO errors
 		return nil
@@ -116,7 +117,7 @@ func globAltsWalk(fsys fs.FS, pattern string, openingIdx, closingIdx int, firstS
 	}
 
 	for _, m := range matches {
-		if err := fn(m.Path, m.Entry); err != nil {
+		if err := skipDirIsNil(fn(m.Path, m.Entry)); err != nil {
 			return err
 		}
 	}
@@ -179,7 +180,7 @@ func globDirWalk(fsys fs.FS, dir, pattern string, canMatchFiles bool, fn GlobWal
 		if err != nil || !info.IsDir() {
 			return nil
 		}
-		return fn(dir, newDirEntryFromFileInfo(info))
+		return skipDirIsNil(fn(dir, newDirEntryFromFileInfo(info)))
 	}
 
 	if pattern == "**" {
@@ -188,7 +189,9 @@ func globDirWalk(fsys fs.FS, dir, pattern string, canMatchFiles bool, fn GlobWal
 		if err != nil || !info.IsDir() {
 			return nil
 		}
-		if err = fn(dir, newDirEntryFromFileInfo(info)); err != nil {
+		if err = fn(dir, newDirEntryFromFileInfo(info)); err == fs.SkipDir {
+			return nil
+		} else if err != nil {
 			return err
 		}
 		return globDoubleStarWalk(fsys, dir, canMatchFiles, fn)
@@ -209,7 +212,7 @@ func globDirWalk(fsys fs.FS, dir, pattern string, canMatchFiles bool, fn GlobWal
 				return err
 			}
 			if matched {
-				if err = fn(path.Join(dir, name), info); err != nil {
+				if err = skipDirIsNil(fn(path.Join(dir, name), info)); err != nil {
 					return err
 				}
 			}
@@ -231,14 +234,16 @@ func globDoubleStarWalk(fsys fs.FS, dir string, canMatchFiles bool, fn GlobWalkF
 		name := info.Name()
 		if isDir(fsys, dir, name, info) {
 			p := path.Join(dir, name)
-			if e := fn(p, info); e != nil {
+			if e := fn(p, info); e == fs.SkipDir {
+				continue
+			} else if e != nil {
 				return e
 			}
 			if e := globDoubleStarWalk(fsys, p, canMatchFiles, fn); e != nil {
 				return e
 			}
 		} else if canMatchFiles {
-			if e := fn(path.Join(dir, name), info); e != nil {
+			if e := skipDirIsNil(fn(path.Join(dir, name), info)); e != nil {
 				return e
 			}
 		}
@@ -247,6 +252,14 @@ func globDoubleStarWalk(fsys fs.FS, dir string, canMatchFiles bool, fn GlobWalkF
 	return nil
 }
 
+// skipDirIsNil ignores fs.SkipDir for a match whose contents aren't walked anyway
+func skipDirIsNil(err error) error {
+	if err == fs.SkipDir {
+		return nil
+	}
+	return err
+}
+
 type dirEntryFromFileInfo struct {
 	fi fs.FileInfo
 }
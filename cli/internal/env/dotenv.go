@@ -0,0 +1,263 @@
+package env
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+// Mode determines which variables of turbo's own environment tasks can read
+type Mode string
+
+const (
+	// LooseMode passes every variable through to tasks
+	LooseMode Mode = "loose"
+	// StrictMode only passes through the variables that are part of a task's hash,
+	// along with those needed to run programs at all
+	StrictMode Mode = "strict"
+)
+
+var _ pflag.Value = (*Mode)(nil)
+
+// String implements pflag.Value.String
+func (m *Mode) String() string {
+	if *m == "" {
+		return string(LooseMode)
+	}
+	return string(*m)
+}
+
+// Set implements pflag.Value.Set
+func (m *Mode) Set(value string) error {
+	switch Mode(value) {
+	case LooseMode, StrictMode:
+		*m = Mode(value)
+		return nil
+	}
+	return fmt.Errorf("must be one of \"%v\" or \"%v\"", LooseMode, StrictMode)
+}
+
+// Type implements pflag.Value.Type
+func (m *Mode) Type() string {
+	return "mode"
+}
+
+// _strictPassthroughVars are passed to tasks in strict mode regardless of their
+// configuration, since shells, package managers, and compilers need them to work.
+var _strictPassthroughVars = []string{
+	"PATH",
+	"HOME",
+	"SHELL",
+	"USER",
+	"LOGNAME",
+	"LANG",
+	"TERM",
+	"COLORTERM",
+	"FORCE_COLOR",
+	"NO_COLOR",
+	"TMPDIR",
+	"TMP",
+	"TEMP",
+	// Windows
+	"APPDATA",
+	"COMSPEC",
+	"LOCALAPPDATA",
+	"PATHEXT",
+	"PROGRAMDATA",
+	"PROGRAMFILES",
+	"SYSTEMDRIVE",
+	"SYSTEMROOT",
+	"USERPROFILE",
+	"WINDIR",
+}
+
+// TaskEnv returns the environment for a task's process, given turbo's own. In strict
+// mode, only the variables in names, those starting with one of prefixes, and those in
+// _strictPassthroughVars are kept. Variables from dotEnv are then added, unless the
+// environment already sets them.
+func TaskEnv(mode Mode, environ []string, names []string, prefixes []string, dotEnv map[string]string) []string {
+	allowed := make(map[string]bool, len(names)+len(_strictPassthroughVars))
+	for _, name := range names {
+		allowed[name] = true
+	}
+	for _, name := range _strictPassthroughVars {
+		allowed[name] = true
+	}
+
+	taskEnv := make([]string, 0, len(environ)+len(dotEnv))
+	set := make(map[string]bool, len(environ))
+	for _, pair := range environ {
+		name := strings.SplitN(pair, "=", 2)[0]
+		if mode == StrictMode && !allowed[strings.ToUpper(name)] && !allowed[name] && !hasAnyPrefix(name, prefixes) {
+			continue
+		}
+		taskEnv = append(taskEnv, pair)
+		set[name] = true
+	}
+	for name, value := range dotEnv {
+		if !set[name] {
+			taskEnv = append(taskEnv, name+"="+value)
+		}
+	}
+	return taskEnv
+}
+
+func hasAnyPrefix(name string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+var _environmentName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// ValidateEnvironment checks that an environment name can be used in dotenv filenames
+func ValidateEnvironment(environment string) error {
+	if !_environmentName.MatchString(environment) {
+		return fmt.Errorf("environment %q may only contain letters, digits, \"-\" and \"_\"", environment)
+	}
+	return nil
+}
+
+// DotEnvFiles returns the names of the dotenv files loaded for an environment, from
+// lowest to highest precedence. As with most tools that load them, .env.local isn't
+// loaded for the test environment, so that tests behave the same for everyone.
+func DotEnvFiles(environment string) []string {
+	files := []string{".env", ".env." + environment}
+	if environment != "test" {
+		files = append(files, ".env.local")
+	}
+	return append(files, ".env."+environment+".local")
+}
+
+// LoadDotEnv reads the dotenv files for an environment from dir. Missing files are
+// skipped, and later files override earlier ones.
+func LoadDotEnv(environment string, dir turbopath.AbsoluteSystemPath) (map[string]string, error) {
+	vars := make(map[string]string)
+	for _, name := range DotEnvFiles(environment) {
+		file := dir.UntypedJoin(name)
+		data, err := file.ReadFile()
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		fileVars, err := ParseDotEnv(data)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", file, err)
+		}
+		for name, value := range fileVars {
+			vars[name] = value
+		}
+	}
+	return vars, nil
+}
+
+var _dotEnvName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+// ParseDotEnv parses the contents of a dotenv file: KEY=value lines, optionally
+// preceded by "export", with # comments. Values in double quotes may span lines and
+// contain escapes, values in single quotes are taken literally, and variables
+// aren't expanded.
+func ParseDotEnv(data []byte) (map[string]string, error) {
+	vars := make(map[string]string)
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		lineNumber := i + 1
+		line := strings.TrimSpace(lines[i])
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "export ") {
+			line = strings.TrimSpace(strings.TrimPrefix(line, "export "))
+		}
+		eq := strings.IndexByte(line, '=')
+		if eq < 0 {
+			return nil, fmt.Errorf("line %v: expected NAME=value", lineNumber)
+		}
+		name := strings.TrimSpace(line[:eq])
+		if !_dotEnvName.MatchString(name) {
+			return nil, fmt.Errorf("line %v: invalid variable name %q", lineNumber, name)
+		}
+		value := strings.TrimSpace(line[eq+1:])
+		if value != "" && (value[0] == '"' || value[0] == '\'') {
+			quote := value[0]
+			raw := value[1:]
+			end := closingQuote(raw, quote)
+			for end < 0 && i+1 < len(lines) {
+				i++
+				raw += "\n" + lines[i]
+				end = closingQuote(raw, quote)
+			}
+			if end < 0 {
+				return nil, fmt.Errorf("line %v: unterminated quoted value for %v", lineNumber, name)
+			}
+			if rest := strings.TrimSpace(raw[end+1:]); rest != "" && !strings.HasPrefix(rest, "#") {
+				return nil, fmt.Errorf("line %v: unexpected %q after quoted value for %v", lineNumber, rest, name)
+			}
+			value = raw[:end]
+			if quote == '"' {
+				value = unescape(value)
+			}
+		} else {
+			value = stripComment(value)
+		}
+		vars[name] = value
+	}
+	return vars, nil
+}
+
+// closingQuote returns the index of the quote that ends s, or -1. Double quotes can
+// be escaped with a backslash.
+func closingQuote(s string, quote byte) int {
+	for i := 0; i < len(s); i++ {
+		if quote == '"' && s[i] == '\\' {
+			i++
+		} else if s[i] == quote {
+			return i
+		}
+	}
+	return -1
+}
+
+func unescape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case '\\', '"':
+			b.WriteByte(s[i])
+		default:
+			b.WriteByte('\\')
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// stripComment removes a comment from an unquoted value, which starts at a # that
+// follows whitespace
+func stripComment(value string) string {
+	for i := 1; i < len(value); i++ {
+		if value[i] == '#' && (value[i-1] == ' ' || value[i-1] == '\t') {
+			return strings.TrimSpace(value[:i])
+		}
+	}
+	return value
+}
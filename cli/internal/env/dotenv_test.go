@@ -0,0 +1,167 @@
+package env
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+func TestParseDotEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    map[string]string
+		wantErr string
+	}{
+		{
+			name: "plain values",
+			data: "# comment\n\nA=1\nexport B = two words \r\nC=\n",
+			want: map[string]string{"A": "1", "B": "two words", "C": ""},
+		},
+		{
+			name: "comments after values",
+			data: "A=value # comment\nB=url#fragment\n",
+			want: map[string]string{"A": "value", "B": "url#fragment"},
+		},
+		{
+			name: "double quotes",
+			data: `A="line one\nline two"` + "\n" + `B="say \"hi\" # not a comment" # comment` + "\n",
+			want: map[string]string{"A": "line one\nline two", "B": `say "hi" # not a comment`},
+		},
+		{
+			name: "single quotes",
+			data: `A='$HOME\n'` + "\n",
+			want: map[string]string{"A": `$HOME\n`},
+		},
+		{
+			name: "multiline",
+			data: "KEY=\"-----BEGIN-----\nabc\n-----END-----\"\nNEXT=1\n",
+			want: map[string]string{"KEY": "-----BEGIN-----\nabc\n-----END-----", "NEXT": "1"},
+		},
+		{
+			name:    "missing equals",
+			data:    "A=1\nB\n",
+			wantErr: "line 2: expected NAME=value",
+		},
+		{
+			name:    "invalid name",
+			data:    "1A=1\n",
+			wantErr: "line 1: invalid variable name \"1A\"",
+		},
+		{
+			name:    "unterminated quote",
+			data:    "A=1\nB=\"open\nC=2\n",
+			wantErr: "line 2: unterminated quoted value for B",
+		},
+		{
+			name:    "text after quotes",
+			data:    "A='one' two\n",
+			wantErr: "line 1: unexpected \"two\" after quoted value for A",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseDotEnv([]byte(tt.data))
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("ParseDotEnv() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseDotEnv() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseDotEnv() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDotEnvFiles(t *testing.T) {
+	if got, want := DotEnvFiles("production"), []string{".env", ".env.production", ".env.local", ".env.production.local"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("DotEnvFiles(production) = %v, want %v", got, want)
+	}
+	if got, want := DotEnvFiles("test"), []string{".env", ".env.test", ".env.test.local"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("DotEnvFiles(test) = %v, want %v", got, want)
+	}
+}
+
+func TestLoadDotEnv(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		".env":                  "A=base\nB=base\nC=base\n",
+		".env.production":       "B=production\n",
+		".env.local":            "C=local\n",
+		".env.development":      "A=development\n",
+		".env.production.local": "D=production-local\n",
+	}
+	for name, contents := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := LoadDotEnv("production", turbopath.AbsoluteSystemPathFromUpstream(dir))
+	if err != nil {
+		t.Fatalf("LoadDotEnv() error = %v", err)
+	}
+	want := map[string]string{"A": "base", "B": "production", "C": "local", "D": "production-local"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("LoadDotEnv() = %v, want %v", got, want)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, ".env.staging"), []byte("not valid\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadDotEnv("staging", turbopath.AbsoluteSystemPathFromUpstream(dir)); err == nil || !strings.Contains(err.Error(), ".env.staging: line 1") {
+		t.Errorf("LoadDotEnv() error = %v, want it to name the invalid file", err)
+	}
+}
+
+func TestTaskEnv(t *testing.T) {
+	environ := []string{"PATH=/bin", "SECRET=1", "API_URL=https://example.com", "NEXT_PUBLIC_KEY=k", "Path=C:\\bin"}
+	dotEnv := map[string]string{"API_URL": "http://localhost", "DB": "postgres://"}
+	tests := []struct {
+		name string
+		mode Mode
+		want []string
+	}{
+		{
+			name: "loose",
+			mode: LooseMode,
+			want: []string{"API_URL=https://example.com", "DB=postgres://", "NEXT_PUBLIC_KEY=k", "PATH=/bin", "Path=C:\\bin", "SECRET=1"},
+		},
+		{
+			name: "strict",
+			mode: StrictMode,
+			want: []string{"API_URL=https://example.com", "DB=postgres://", "NEXT_PUBLIC_KEY=k", "PATH=/bin", "Path=C:\\bin"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := TaskEnv(tt.mode, environ, []string{"API_URL"}, []string{"NEXT_PUBLIC_"}, dotEnv)
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("TaskEnv() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateEnvironment(t *testing.T) {
+	for _, environment := range []string{"production", "staging-eu", "test_1"} {
+		if err := ValidateEnvironment(environment); err != nil {
+			t.Errorf("ValidateEnvironment(%q) = %v, want nil", environment, err)
+		}
+	}
+	for _, environment := range []string{"", "../prod", "prod.local", "a b"} {
+		if err := ValidateEnvironment(environment); err == nil {
+			t.Errorf("ValidateEnvironment(%q) = nil, want an error", environment)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+// Package errcode defines stable codes for the failures turbo reports to users,
+// so that wrappers and CI integrations can branch on the kind of failure rather
+// than parsing error messages. Codes are of the form "<category>/<name>" and must
+// not change once released.
+package errcode
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Category is a broad class of failure
+type Category string
+
+const (
+	// CategoryConfig covers invalid turbo.json, package.json, and command-line configuration
+	CategoryConfig Category = "config"
+	// CategoryFilter covers package selection via --filter, --scope, and --since
+	CategoryFilter Category = "filter"
+	// CategoryGraph covers constructing the package and task graphs
+	CategoryGraph Category = "graph"
+	// CategoryExecution covers hashing and running tasks
+	CategoryExecution Category = "execution"
+	// CategoryCache covers reading and writing task artifacts
+	CategoryCache Category = "cache"
+	// CategoryNetwork covers requests to the remote API
+	CategoryNetwork Category = "network"
+	// CategoryUnknown is used for errors that have not been classified
+	CategoryUnknown Category = "unknown"
+)
+
+// Code is a stable identifier for a specific failure
+type Code string
+
+const (
+	// InvalidArguments means the command-line arguments could not be used
+	InvalidArguments Code = "config/invalid-arguments"
+	// InvalidPackageJSON means the root package.json could not be read
+	InvalidPackageJSON Code = "config/invalid-package-json"
+	// InvalidTurboJSON means turbo.json could not be read or is invalid
+	InvalidTurboJSON Code = "config/invalid-turbo-json"
+	// MissingTask means a requested task is not defined in the pipeline
+	MissingTask Code = "config/missing-task"
+	// InvalidFilter means the requested packages could not be resolved
+	InvalidFilter Code = "filter/invalid-filter"
+	// InvalidPackageGraph means the workspace dependency graph could not be built
+	InvalidPackageGraph Code = "graph/invalid-package-graph"
+	// InvalidTaskGraph means the task graph could not be built from the pipeline
+	InvalidTaskGraph Code = "graph/invalid-task-graph"
+	// HashingFailed means the inputs to a task could not be hashed
+	HashingFailed Code = "execution/hashing-failed"
+	// TaskFailed means at least one task exited with an error
+	TaskFailed Code = "execution/task-failed"
+	// InsufficientDiskSpace means the outputs of the tasks to run are not expected to fit on disk
+	InsufficientDiskSpace Code = "execution/insufficient-disk-space"
+	// RunTimeout means the run took longer than --run-timeout, so some tasks didn't
+	// start or were stopped
+	RunTimeout Code = "execution/run-timeout"
+	// CacheUnavailable means the configured caches could not be set up
+	CacheUnavailable Code = "cache/unavailable"
+	// CorruptArtifact means a cached artifact is incomplete or cannot be restored
+	CorruptArtifact Code = "cache/corrupt-artifact"
+	// RequestFailed means a request to the remote API failed
+	RequestFailed Code = "network/request-failed"
+	// Unknown is reported for errors that have not been classified
+	Unknown Code = "unknown/unknown"
+)
+
+// Category returns the category that this code belongs to
+func (c Code) Category() Category {
+	category, _, ok := strings.Cut(string(c), "/")
+	if !ok {
+		return CategoryUnknown
+	}
+	return Category(category)
+}
+
+// Error attaches a Code to an underlying error
+type Error struct {
+	Code Code
+	Err  error
+}
+
+// Error implements error.Error
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap allows errors.Is and errors.As to inspect the underlying error
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Wrap attaches code to err. It returns nil if err is nil, and leaves err
+// unchanged if it has already been classified.
+func Wrap(code Code, err error) error {
+	if err == nil {
+		return nil
+	}
+	var existing *Error
+	if errors.As(err, &existing) {
+		return err
+	}
+	return &Error{Code: code, Err: err}
+}
+
+// Of returns the code attached to err, or Unknown if there is none
+func Of(err error) Code {
+	var coded *Error
+	if errors.As(err, &coded) {
+		return coded.Code
+	}
+	return Unknown
+}
+
+// Report is the machine-readable representation of a failure
+type Report struct {
+	Code     Code     `json:"code"`
+	Category Category `json:"category"`
+	Message  string   `json:"message"`
+}
+
+// NewReport builds a Report for err
+func NewReport(err error) *Report {
+	code := Of(err)
+	return &Report{
+		Code:     code,
+		Category: code.Category(),
+		Message:  err.Error(),
+	}
+}
+
+// Format values for --error-format
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// ValidateFormat returns an error if format is not a supported --error-format
+func ValidateFormat(format string) error {
+	if format != FormatText && format != FormatJSON {
+		return fmt.Errorf("invalid --error-format %q, expected %q or %q", format, FormatText, FormatJSON)
+	}
+	return nil
+}
+
+// JSON renders the report as a single line of JSON
+func (r *Report) JSON() (string, error) {
+	bytes, err := json.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
+}
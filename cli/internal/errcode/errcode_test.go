@@ -0,0 +1,59 @@
+package errcode
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestCodeCategory(t *testing.T) {
+	assert.Equal(t, InvalidTurboJSON.Category(), CategoryConfig)
+	assert.Equal(t, InvalidFilter.Category(), CategoryFilter)
+	assert.Equal(t, InvalidTaskGraph.Category(), CategoryGraph)
+	assert.Equal(t, TaskFailed.Category(), CategoryExecution)
+	assert.Equal(t, CacheUnavailable.Category(), CategoryCache)
+	assert.Equal(t, RequestFailed.Category(), CategoryNetwork)
+	assert.Equal(t, Code("malformed").Category(), CategoryUnknown)
+}
+
+func TestWrap(t *testing.T) {
+	assert.NilError(t, Wrap(TaskFailed, nil))
+
+	underlying := errors.New("boom")
+	err := fmt.Errorf("run failed: %w", Wrap(InvalidFilter, underlying))
+	assert.Equal(t, Of(err), InvalidFilter)
+	assert.Assert(t, errors.Is(err, underlying))
+	assert.Equal(t, err.Error(), "run failed: boom")
+
+	// The innermost classification wins
+	assert.Equal(t, Of(Wrap(TaskFailed, err)), InvalidFilter)
+
+	assert.Equal(t, Of(underlying), Unknown)
+}
+
+func TestReport(t *testing.T) {
+	err := fmt.Errorf("run failed: %w", Wrap(MissingTask, errors.New("could not find task")))
+	report, jsonErr := NewReport(err).JSON()
+	assert.NilError(t, jsonErr, "JSON")
+	assert.Equal(t, report, `{"code":"config/missing-task","category":"config","message":"run failed: could not find task"}`)
+}
+
+func TestWarningPolicy(t *testing.T) {
+	policy := &WarningPolicy{}
+	assert.Equal(t, policy.Action(MissingOutputs), WarningReport)
+
+	assert.NilError(t, policy.Set("strict"))
+	assert.NilError(t, policy.Set("silence:config/unused-pipeline-entry"))
+	assert.Equal(t, policy.Action(MissingOutputs), WarningEscalate)
+	assert.Equal(t, policy.Action(UnusedPipelineEntry), WarningSilence)
+	assert.Equal(t, policy.String(), "strict,silence:config/unused-pipeline-entry")
+
+	assert.NilError(t, policy.Set("default"))
+	assert.Equal(t, policy.Action(MissingOutputs), WarningReport)
+	assert.Equal(t, policy.Action(UnusedPipelineEntry), WarningReport)
+
+	assert.ErrorContains(t, policy.Set("silence:config/typo"), "unknown warning code \"config/typo\"")
+	assert.ErrorContains(t, policy.Set("loud"), "invalid --warnings \"loud\"")
+}
@@ -0,0 +1,168 @@
+package errcode
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Warning codes identify problems that don't stop turbo, but that are usually
+// mistakes. --warnings can escalate them to errors, or silence them.
+const (
+	// UnusedPipelineEntry means a pipeline entry in turbo.json matches no workspace script
+	UnusedPipelineEntry Code = "config/unused-pipeline-entry"
+	// RootTaskMismatch means a root script and the root tasks in turbo.json disagree,
+	// so a requested root task does nothing or a root script is not run
+	RootTaskMismatch Code = "config/root-task-mismatch"
+	// ImplicitDependency means a workspace depends on another workspace's name with a
+	// version range the workspace doesn't satisfy, so it is implicitly resolved from
+	// the registry instead
+	ImplicitDependency Code = "graph/implicit-dependency"
+	// MissingOutputs means a task with outputs in turbo.json produced none of them, or,
+	// with --validate-outputs, that one of its outputs globs matched no files
+	MissingOutputs Code = "execution/missing-outputs"
+	// UndeclaredImport means a workspace imports another workspace that its
+	// package.json doesn't depend on
+	UndeclaredImport Code = "graph/undeclared-import"
+	// DeprecatedConfig means the config has a legacy shape, such as a "turbo" key in
+	// package.json, that was translated to the current schema when it was read
+	DeprecatedConfig Code = "config/deprecated"
+	// MachineSpecificOutputs means the outputs of a task embed absolute paths of the
+	// machine that ran it, such as the repository root, so they may be wrong when
+	// restored elsewhere, or weren't cached
+	MachineSpecificOutputs Code = "execution/machine-specific-outputs"
+)
+
+// WarningCodes lists every warning code, for validating --warnings
+var WarningCodes = []Code{
+	UnusedPipelineEntry,
+	RootTaskMismatch,
+	ImplicitDependency,
+	MissingOutputs,
+	UndeclaredImport,
+	DeprecatedConfig,
+	MachineSpecificOutputs,
+	// MissingTask is an error, unless --allow-missing-tasks runs the task anyway
+	MissingTask,
+}
+
+// Warning attaches a warning Code to a problem
+type Warning struct {
+	Code Code
+	Err  error
+}
+
+// NewWarning creates a Warning for err
+func NewWarning(code Code, err error) *Warning {
+	return &Warning{Code: code, Err: err}
+}
+
+// Error implements error.Error
+func (w *Warning) Error() string {
+	return w.Err.Error()
+}
+
+// Unwrap allows errors.Is and errors.As to inspect the underlying error
+func (w *Warning) Unwrap() error {
+	return w.Err
+}
+
+// WarningAction is what happens to a warning
+type WarningAction int
+
+const (
+	// WarningReport prints the warning
+	WarningReport WarningAction = iota
+	// WarningSilence hides the warning
+	WarningSilence
+	// WarningEscalate treats the warning as an error
+	WarningEscalate
+)
+
+// Values for --warnings
+const (
+	warningsStrict        = "strict"
+	warningsDefault       = "default"
+	warningsSilencePrefix = "silence:"
+)
+
+// WarningPolicy decides the WarningAction for each warning code. It implements
+// pflag.Value, and can be set several times: "strict" escalates every warning,
+// "silence:<code>" hides warnings with the given code, even in strict mode, and
+// "default" undoes both.
+type WarningPolicy struct {
+	strict   bool
+	silenced map[Code]bool
+}
+
+// Action returns what should happen to warnings with the given code
+func (p *WarningPolicy) Action(code Code) WarningAction {
+	if p.silenced[code] {
+		return WarningSilence
+	} else if p.strict {
+		return WarningEscalate
+	}
+	return WarningReport
+}
+
+// Set implements pflag.Value.Set
+func (p *WarningPolicy) Set(value string) error {
+	switch {
+	case value == warningsStrict:
+		p.strict = true
+	case value == warningsDefault:
+		p.strict = false
+		p.silenced = nil
+	case strings.HasPrefix(value, warningsSilencePrefix):
+		code := Code(strings.TrimPrefix(value, warningsSilencePrefix))
+		if !isWarningCode(code) {
+			return fmt.Errorf("unknown warning code %q, expected one of %v", code, joinCodes(WarningCodes))
+		}
+		if p.silenced == nil {
+			p.silenced = make(map[Code]bool)
+		}
+		p.silenced[code] = true
+	default:
+		return fmt.Errorf("invalid --warnings %q, expected %q, %q, or \"%v<code>\"", value, warningsStrict, warningsDefault, warningsSilencePrefix)
+	}
+	return nil
+}
+
+// String implements pflag.Value.String
+func (p *WarningPolicy) String() string {
+	var values []string
+	if p.strict {
+		values = append(values, warningsStrict)
+	}
+	var silenced []Code
+	for code := range p.silenced {
+		silenced = append(silenced, code)
+	}
+	sort.Slice(silenced, func(i, j int) bool { return silenced[i] < silenced[j] })
+	for _, code := range silenced {
+		values = append(values, warningsSilencePrefix+string(code))
+	}
+	return strings.Join(values, ",")
+}
+
+// Type implements pflag.Value.Type
+func (p *WarningPolicy) Type() string {
+	return "strict|default|silence:<code>"
+}
+
+func isWarningCode(code Code) bool {
+	for _, c := range WarningCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+func joinCodes(codes []Code) string {
+	strs := make([]string, len(codes))
+	for i, code := range codes {
+		strs[i] = string(code)
+	}
+	return strings.Join(strs, ", ")
+}
@@ -0,0 +1,133 @@
+package focus
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/vercel/turbo/cli/internal/cmdutil"
+	"github.com/vercel/turbo/cli/internal/context"
+	"github.com/vercel/turbo/cli/internal/errcode"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/scm"
+	"github.com/vercel/turbo/cli/internal/scope"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+type opts struct {
+	clear bool
+}
+
+// GetCmd returns the focus command
+func GetCmd(helper *cmdutil.Helper) *cobra.Command {
+	opts := &opts{}
+	cmd := &cobra.Command{
+		Use:   "focus [<filter>...]",
+		Short: "Set the workspaces that runs default to",
+		Long: `Set the workspaces that runs default to.
+
+Each argument is a filter, in the same syntax as --filter, such as "web" or
+"web...". Until the focus is cleared, turbo run only runs tasks in the workspaces
+that the filters select, unless --filter, --scope, or --since is passed. The
+focus is local to this checkout of the repository.
+
+Without arguments, the current focus is printed.`,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, err := helper.GetCmdBase(cmd.Flags())
+			if err != nil {
+				return err
+			}
+			if err := focus(base, opts, args); err != nil {
+				base.LogError("%w", err)
+				return err
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&opts.clear, "clear", false, "Clear the focus, so that runs default to every workspace")
+	return cmd
+}
+
+func focus(base *cmdutil.CmdBase, opts *opts, args []string) error {
+	current, err := Read(base.RepoRoot)
+	if err != nil {
+		return fmt.Errorf("failed to read the focus: %w", err)
+	}
+	if opts.clear {
+		if len(args) > 0 {
+			return errcode.Wrap(errcode.InvalidArguments, errors.New("--clear cannot be combined with filters"))
+		}
+		if err := current.Clear(); err != nil {
+			return fmt.Errorf("failed to clear the focus: %w", err)
+		}
+		base.UI.Output("Cleared the focus, runs default to every workspace")
+		return nil
+	}
+	if len(args) == 0 {
+		if !current.IsSet() {
+			base.UI.Output("There is no focus, runs default to every workspace")
+			return nil
+		}
+		pkgs, err := resolve(base, current.Filters)
+		if err != nil {
+			return err
+		}
+		base.UI.Output(util.Sprintf("Focused on ${BOLD}%v${RESET}: %v", strings.Join(current.Filters, " "), describe(pkgs)))
+		return nil
+	}
+	for _, arg := range args {
+		if arg == "-" {
+			return errcode.Wrap(errcode.InvalidArguments, errors.New("the focus cannot read filters from stdin"))
+		}
+	}
+	pkgs, err := resolve(base, args)
+	if err != nil {
+		return err
+	}
+	if len(pkgs) == 0 {
+		return errcode.Wrap(errcode.InvalidFilter, fmt.Errorf("%v matches no workspaces", strings.Join(args, " ")))
+	}
+	if err := current.Set(args); err != nil {
+		return fmt.Errorf("failed to save the focus: %w", err)
+	}
+	base.UI.Output(util.Sprintf("Focused on ${BOLD}%v${RESET}: %v", strings.Join(args, " "), describe(pkgs)))
+	base.UI.Output(util.Sprintf("${GREY}Runs default to these workspaces until `turbo focus --clear`${RESET}"))
+	return nil
+}
+
+// resolve returns the workspaces that filters select, in the same way as --filter
+func resolve(base *cmdutil.CmdBase, filters []string) ([]string, error) {
+	rootPackageJSON, err := fs.ReadPackageJSON(base.RepoRoot.UntypedJoin("package.json"))
+	if err != nil {
+		return nil, errcode.Wrap(errcode.InvalidPackageJSON, fmt.Errorf("failed to read package.json: %w", err))
+	}
+	pkgDepGraph, err := context.BuildPackageGraph(base.RepoRoot, rootPackageJSON)
+	if err != nil {
+		var warnings *context.Warnings
+		if !errors.As(err, &warnings) {
+			return nil, errcode.Wrap(errcode.InvalidPackageGraph, err)
+		}
+	}
+	scmInstance, err := scm.FromInRepo(base.RepoRoot)
+	if err != nil && !errors.Is(err, scm.ErrFallback) {
+		return nil, fmt.Errorf("failed to create SCM: %w", err)
+	}
+	selected, _, err := scope.ResolvePackages(&scope.Opts{FilterPatterns: filters}, base.RepoRoot.ToStringDuringMigration(), scmInstance, pkgDepGraph, base.UI, base.Logger)
+	if err != nil {
+		return nil, errcode.Wrap(errcode.InvalidFilter, err)
+	}
+	pkgs := selected.UnsafeListOfStrings()
+	sort.Strings(pkgs)
+	return pkgs, nil
+}
+
+func describe(pkgs []string) string {
+	if len(pkgs) == 1 {
+		return fmt.Sprintf("1 workspace (%v)", pkgs[0])
+	}
+	return fmt.Sprintf("%v workspaces (%v)", len(pkgs), strings.Join(pkgs, ", "))
+}
@@ -0,0 +1,67 @@
+// Package focus remembers the filters that a developer is working within, so that
+// `turbo run` defaults to them until they're cleared
+package focus
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+// _focusFile is the repo-relative file that records the focus. It's local state
+// for the developer, alongside the other state turbo keeps in .turbo.
+var _focusFile = []string{".turbo", "focus.json"}
+
+// Focus is the set of filters that runs default to when no filter is passed
+type Focus struct {
+	path turbopath.AbsoluteSystemPath
+	// Filters are patterns in the syntax of --filter
+	Filters   []string  `json:"filters"`
+	FocusedAt time.Time `json:"focusedAt"`
+}
+
+// Read loads the focus of the repository at repoRoot. If there is no focus, it
+// has no filters.
+func Read(repoRoot turbopath.AbsoluteSystemPath) (*Focus, error) {
+	focus := &Focus{path: repoRoot.UntypedJoin(_focusFile...)}
+	bytes, err := focus.path.ReadFile()
+	if os.IsNotExist(err) {
+		return focus, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(bytes, focus); err != nil {
+		return nil, err
+	}
+	return focus, nil
+}
+
+// IsSet returns true if there is a focus
+func (f *Focus) IsSet() bool {
+	return len(f.Filters) > 0
+}
+
+// Set replaces the filters of the focus and saves it
+func (f *Focus) Set(filters []string) error {
+	f.Filters = append([]string{}, filters...)
+	f.FocusedAt = time.Now()
+	bytes, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := f.path.EnsureDir(); err != nil {
+		return err
+	}
+	return f.path.WriteFile(bytes, 0644)
+}
+
+// Clear removes the focus, so that runs default to every workspace again
+func (f *Focus) Clear() error {
+	f.Filters = nil
+	if err := f.path.Remove(); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package focus
+
+import (
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"gotest.tools/v3/assert"
+)
+
+func TestFocus(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	focus, err := Read(repoRoot)
+	assert.NilError(t, err, "Read")
+	assert.Assert(t, !focus.IsSet())
+
+	assert.NilError(t, focus.Set([]string{"web", "admin..."}), "Set")
+	focus, err = Read(repoRoot)
+	assert.NilError(t, err, "Read")
+	assert.Assert(t, focus.IsSet())
+	assert.DeepEqual(t, focus.Filters, []string{"web", "admin..."})
+	assert.Assert(t, !focus.FocusedAt.IsZero())
+
+	assert.NilError(t, focus.Clear(), "Clear")
+	assert.Assert(t, !focus.IsSet())
+	focus, err = Read(repoRoot)
+	assert.NilError(t, err, "Read")
+	assert.Assert(t, !focus.IsSet())
+	// Clearing without a focus is fine
+	assert.NilError(t, focus.Clear(), "Clear")
+}
@@ -0,0 +1,156 @@
+package fs
+
+import (
+	"io"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+// TempFileSuffix ends the names of the temporary files that are renamed into place
+// once they are complete
+const TempFileSuffix = ".tmp"
+
+// StagingDirPrefix starts the names of the directories that files are restored to
+// before they are moved into place
+const StagingDirPrefix = ".turbo-restore-"
+
+// CreateTempSibling creates a temporary file in the same directory as path, so that
+// it can be renamed over path once it is complete. Its name starts with a dot and
+// ends with TempFileSuffix.
+func CreateTempSibling(path turbopath.AbsoluteSystemPath) (*os.File, error) {
+	return os.CreateTemp(path.Dir().ToString(), "."+path.Base()+".*"+TempFileSuffix)
+}
+
+// WriteFileAtomic writes contents to path by way of a temporary file that is synced
+// to disk and renamed into place, so that an interrupted write never leaves path with
+// partial contents. The directory of path must exist.
+func WriteFileAtomic(path turbopath.AbsoluteSystemPath, contents io.Reader, mode os.FileMode) error {
+	// A rename would replace an empty directory, where writing a file would fail
+	if info, err := path.Lstat(); err == nil && info.IsDir() {
+		return &os.PathError{Op: "open", Path: path.ToString(), Err: syscall.EISDIR}
+	}
+	tempFile, err := CreateTempSibling(path)
+	if err != nil {
+		return err
+	}
+	tempPath := tempFile.Name()
+	if _, err := io.Copy(tempFile, contents); err != nil {
+		_ = tempFile.Close()
+		_ = os.Remove(tempPath)
+		return err
+	}
+	if err := tempFile.Sync(); err != nil {
+		_ = tempFile.Close()
+		_ = os.Remove(tempPath)
+		return err
+	}
+	if err := tempFile.Close(); err != nil {
+		_ = os.Remove(tempPath)
+		return err
+	}
+	if err := os.Chmod(tempPath, mode); err != nil {
+		_ = os.Remove(tempPath)
+		return err
+	}
+	if err := os.Rename(tempPath, path.ToString()); err != nil {
+		_ = os.Remove(tempPath)
+		return err
+	}
+	return nil
+}
+
+// _staleStagingAge is how old a staging directory has to be before it is treated as
+// left behind by a restore that was killed, rather than one that is running
+const _staleStagingAge = time.Hour
+
+// stagingParent is the directory in root that staging directories are created in.
+// Keeping them in .turbo keeps them out of git status, the hashes of root tasks,
+// and the changes that watch mode reacts to.
+func stagingParent(root turbopath.AbsoluteSystemPath) turbopath.AbsoluteSystemPath {
+	return root.UntypedJoin(".turbo")
+}
+
+// CreateStagingDir creates a directory in root's .turbo folder to restore files to,
+// so that none of them are among the files in root until all of them are complete.
+// Being in root, it is on the same filesystem, and MoveStaged can rename the files
+// into place. Staging directories left behind by killed restores are removed first.
+func CreateStagingDir(root turbopath.AbsoluteSystemPath) (turbopath.AbsoluteSystemPath, error) {
+	parent := stagingParent(root)
+	if err := parent.MkdirAll(0755); err != nil {
+		return "", err
+	}
+	if err := RemoveStaleStagingDirs(root, _staleStagingAge, time.Now()); err != nil {
+		return "", err
+	}
+	dir, err := os.MkdirTemp(parent.ToString(), StagingDirPrefix+"*")
+	if err != nil {
+		return "", err
+	}
+	return turbopath.AbsoluteSystemPathFromUpstream(dir), nil
+}
+
+// MoveStaged moves the files in the staging directory to the same paths in root. A
+// directory that root doesn't have is moved in whole, and the contents of one that
+// it has are moved into it, keeping the files of root that staging doesn't have.
+// A file or link replaces whatever root has at its path.
+func MoveStaged(staging turbopath.AbsoluteSystemPath, root turbopath.AbsoluteSystemPath) error {
+	entries, err := os.ReadDir(staging.ToString())
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		from := staging.UntypedJoin(entry.Name())
+		to := root.UntypedJoin(entry.Name())
+		info, err := to.Lstat()
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if err == nil {
+			if entry.IsDir() && info.IsDir() {
+				if err := MoveStaged(from, to); err != nil {
+					return err
+				}
+				continue
+			}
+			// A rename can't replace a directory, or a file with a directory
+			if entry.IsDir() || info.IsDir() {
+				if err := to.RemoveAll(); err != nil {
+					return err
+				}
+			}
+		}
+		if err := from.Rename(to); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RemoveStaleStagingDirs removes the staging directories in root that were created
+// more than maxAge before now, which were left behind by a restore that was killed
+func RemoveStaleStagingDirs(root turbopath.AbsoluteSystemPath, maxAge time.Duration, now time.Time) error {
+	parent := stagingParent(root)
+	entries, err := os.ReadDir(parent.ToString())
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), StagingDirPrefix) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || now.Sub(info.ModTime()) < maxAge {
+			continue
+		}
+		if err := parent.UntypedJoin(entry.Name()).RemoveAll(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
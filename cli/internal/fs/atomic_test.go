@@ -0,0 +1,103 @@
+package fs
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"gotest.tools/v3/assert"
+)
+
+// failingReader returns some contents, and then an error
+type failingReader struct {
+	read bool
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if r.read {
+		return 0, errors.New("connection reset")
+	}
+	r.read = true
+	return copy(p, "partial"), nil
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := turbopath.AbsoluteSystemPath(t.TempDir())
+	path := dir.UntypedJoin("out.txt")
+	assert.NilError(t, path.WriteFile([]byte("before"), 0644), "WriteFile")
+
+	// An interrupted write leaves the previous contents, and no temporary file
+	err := WriteFileAtomic(path, &failingReader{}, 0755)
+	assert.ErrorContains(t, err, "connection reset")
+	contents, err := path.ReadFile()
+	assert.NilError(t, err, "ReadFile")
+	assert.Equal(t, string(contents), "before")
+	entries, err := os.ReadDir(dir.ToString())
+	assert.NilError(t, err, "ReadDir")
+	assert.Equal(t, len(entries), 1)
+
+	assert.NilError(t, WriteFileAtomic(path, strings.NewReader("after"), 0755), "WriteFileAtomic")
+	contents, err = path.ReadFile()
+	assert.NilError(t, err, "ReadFile")
+	assert.Equal(t, string(contents), "after")
+	entries, err = os.ReadDir(dir.ToString())
+	assert.NilError(t, err, "ReadDir")
+	assert.Equal(t, len(entries), 1)
+
+	// A directory isn't replaced
+	err = WriteFileAtomic(dir, strings.NewReader("x"), 0644)
+	assert.Assert(t, err != nil)
+	assert.Assert(t, dir.DirExists())
+}
+
+func TestMoveStaged(t *testing.T) {
+	root := turbopath.AbsoluteSystemPath(t.TempDir())
+	assert.NilError(t, root.UntypedJoin("dist").MkdirAll(0755), "MkdirAll")
+	assert.NilError(t, root.UntypedJoin("dist", "kept.js").WriteFile([]byte("kept"), 0644), "WriteFile")
+	assert.NilError(t, root.UntypedJoin("dist", "index.js").WriteFile([]byte("before"), 0644), "WriteFile")
+	assert.NilError(t, root.UntypedJoin("was-file").WriteFile([]byte("file"), 0644), "WriteFile")
+
+	staging, err := CreateStagingDir(root)
+	assert.NilError(t, err, "CreateStagingDir")
+	assert.NilError(t, staging.UntypedJoin("dist").MkdirAll(0755), "MkdirAll")
+	assert.NilError(t, staging.UntypedJoin("dist", "index.js").WriteFile([]byte("after"), 0644), "WriteFile")
+	assert.NilError(t, staging.UntypedJoin("was-file").MkdirAll(0755), "MkdirAll")
+	assert.NilError(t, staging.UntypedJoin("new").MkdirAll(0755), "MkdirAll")
+	assert.NilError(t, staging.UntypedJoin("new", "file.txt").WriteFile([]byte("new"), 0644), "WriteFile")
+
+	assert.NilError(t, MoveStaged(staging, root), "MoveStaged")
+
+	for path, want := range map[string]string{
+		"dist/kept.js":  "kept",
+		"dist/index.js": "after",
+		"new/file.txt":  "new",
+	} {
+		contents, err := root.UntypedJoin(path).ReadFile()
+		assert.NilError(t, err, "ReadFile")
+		assert.Equal(t, string(contents), want)
+	}
+	assert.Assert(t, root.UntypedJoin("was-file").DirExists())
+}
+
+func TestRemoveStaleStagingDirs(t *testing.T) {
+	root := turbopath.AbsoluteSystemPath(t.TempDir())
+	stale, err := CreateStagingDir(root)
+	assert.NilError(t, err, "CreateStagingDir")
+	fresh, err := CreateStagingDir(root)
+	assert.NilError(t, err, "CreateStagingDir")
+	assert.NilError(t, root.UntypedJoin("dist").MkdirAll(0755), "MkdirAll")
+
+	old := time.Now().Add(-2 * time.Hour)
+	assert.NilError(t, os.Chtimes(stale.ToString(), old, old), "Chtimes")
+
+	// Staging directories are kept out of the root itself
+	assert.Equal(t, stale.Dir(), root.UntypedJoin(".turbo"))
+
+	assert.NilError(t, RemoveStaleStagingDirs(root, time.Hour, time.Now()), "RemoveStaleStagingDirs")
+	assert.Assert(t, !stale.Exists())
+	assert.Assert(t, fresh.DirExists())
+	assert.Assert(t, root.UntypedJoin("dist").DirExists())
+}
@@ -0,0 +1,91 @@
+package fs
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// errCloneUnsupported is returned by cloneInto on platforms without copy-on-write clones
+var errCloneUnsupported = errors.New("copy-on-write clones are not supported on this platform")
+
+// devicePair is the devices of the source and the destination of a clone
+type devicePair struct {
+	from uint64
+	to   uint64
+}
+
+// unsupportedClones holds the devicePairs that cloning has been reported unsupported
+// between, so that every other file between them goes straight to a regular copy
+var unsupportedClones sync.Map
+
+// cloneFile attempts to copy 'from' to 'to' as a copy-on-write clone, which shares the
+// underlying data blocks and so avoids reading and writing the file's contents. Like
+// writeFileFromStream, it clones to a temporary file and renames it into place. It returns
+// false, leaving 'to' untouched, if the platform or filesystem does not support cloning,
+// in which case the caller should fall back to a regular copy.
+func cloneFile(from string, to string, mode os.FileMode) bool {
+	dir, file := filepath.Split(to)
+	if dir != "" {
+		if err := os.MkdirAll(dir, DirPermissions); err != nil {
+			return false
+		}
+	}
+	pair, pairKnown := cloneDevices(from, dir)
+	if pairKnown {
+		if _, unsupported := unsupportedClones.Load(pair); unsupported {
+			return false
+		}
+	}
+	// Reserve a unique name, then free it up, since some platforms require that the
+	// destination of a clone does not yet exist
+	tempFile, err := ioutil.TempFile(dir, file)
+	if err != nil {
+		return false
+	}
+	tempName := tempFile.Name()
+	if err := tempFile.Close(); err != nil {
+		return false
+	}
+	if err := os.Remove(tempName); err != nil {
+		return false
+	}
+	if err := cloneInto(from, tempName); err != nil {
+		_ = os.Remove(tempName)
+		if pairKnown && isCloneUnsupported(err) {
+			unsupportedClones.Store(pair, true)
+		}
+		return false
+	}
+	if mode == 0 {
+		mode = 0664
+	}
+	if err := os.Chmod(tempName, mode); err != nil {
+		_ = os.Remove(tempName)
+		return false
+	}
+	if err := os.Rename(tempName, to); err != nil {
+		_ = os.Remove(tempName)
+		return false
+	}
+	return true
+}
+
+// cloneDevices returns the devices of the file 'from' and the directory 'dir', if
+// they can be found
+func cloneDevices(from string, dir string) (devicePair, bool) {
+	if dir == "" {
+		dir = "."
+	}
+	fromDevice, ok := deviceOf(from)
+	if !ok {
+		return devicePair{}, false
+	}
+	toDevice, ok := deviceOf(dir)
+	if !ok {
+		return devicePair{}, false
+	}
+	return devicePair{from: fromDevice, to: toDevice}, true
+}
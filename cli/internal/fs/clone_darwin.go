@@ -0,0 +1,35 @@
+//go:build darwin
+// +build darwin
+
+package fs
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// cloneInto creates 'to' as a clone of 'from' using clonefile(2),
+// which is supported by APFS. 'to' must not exist.
+func cloneInto(from string, to string) error {
+	if err := unix.Clonefile(from, to, unix.CLONE_NOFOLLOW); err != nil {
+		return &os.PathError{Op: "clonefile", Path: to, Err: err}
+	}
+	return nil
+}
+
+// isCloneUnsupported returns true if err means that the filesystem can't clone,
+// or can't clone between the two files' devices, rather than that this clone failed
+func isCloneUnsupported(err error) bool {
+	return errors.Is(err, unix.ENOTSUP) || errors.Is(err, unix.EXDEV)
+}
+
+// deviceOf returns the device that path is on
+func deviceOf(path string) (uint64, bool) {
+	var stat unix.Stat_t
+	if err := unix.Stat(path, &stat); err != nil {
+		return 0, false
+	}
+	return uint64(stat.Dev), true
+}
@@ -0,0 +1,51 @@
+//go:build linux
+// +build linux
+
+package fs
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// cloneInto creates 'to' as a reflink of 'from' using the FICLONE ioctl,
+// which is supported by btrfs and XFS, among others. 'to' must not exist.
+func cloneInto(from string, to string) error {
+	fromFile, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = fromFile.Close() }()
+	toFile, err := os.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if err := unix.IoctlFileClone(int(toFile.Fd()), int(fromFile.Fd())); err != nil {
+		_ = toFile.Close()
+		return &os.PathError{Op: "ficlone", Path: to, Err: err}
+	}
+	return toFile.Close()
+}
+
+// isCloneUnsupported returns true if err means that the filesystem can't clone,
+// or can't clone between the two files' devices, rather than that this clone failed.
+// Filesystems without FICLONE report it in different ways.
+func isCloneUnsupported(err error) bool {
+	for _, errno := range []unix.Errno{unix.EOPNOTSUPP, unix.EXDEV, unix.EINVAL, unix.ENOTTY} {
+		if errors.Is(err, errno) {
+			return true
+		}
+	}
+	return false
+}
+
+// deviceOf returns the device that path is on
+func deviceOf(path string) (uint64, bool) {
+	var stat unix.Stat_t
+	if err := unix.Stat(path, &stat); err != nil {
+		return 0, false
+	}
+	return uint64(stat.Dev), true
+}
@@ -0,0 +1,22 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package fs
+
+import "errors"
+
+// cloneInto is not supported on this platform
+func cloneInto(from string, to string) error {
+	return errCloneUnsupported
+}
+
+// isCloneUnsupported returns true if err means that cloning isn't supported
+func isCloneUnsupported(err error) bool {
+	return errors.Is(err, errCloneUnsupported)
+}
+
+// deviceOf returns the same device for every path, since no clone is supported
+// between any of them
+func deviceOf(path string) (uint64, bool) {
+	return 0, true
+}
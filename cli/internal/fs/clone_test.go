@@ -0,0 +1,35 @@
+package fs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestCloneFile(t *testing.T) {
+	srcDir := t.TempDir()
+	destDir := t.TempDir()
+	src := filepath.Join(srcDir, "src")
+	dest := filepath.Join(destDir, "nested", "dest")
+	assert.NilError(t, ioutil.WriteFile(src, []byte("src"), 0644), "WriteFile")
+
+	if !cloneFile(src, dest, 0755) {
+		// The filesystem doesn't support clones, make sure nothing was left behind
+		entries, err := ioutil.ReadDir(filepath.Join(destDir, "nested"))
+		assert.NilError(t, err, "ReadDir")
+		assert.Equal(t, len(entries), 0)
+		// and that the next file between the same devices isn't cloned at all
+		pair, ok := cloneDevices(src, filepath.Join(destDir, "nested"))
+		assert.Assert(t, ok, "cloneDevices")
+		_, unsupported := unsupportedClones.Load(pair)
+		assert.Assert(t, unsupported, "expected clones between %v to be recorded as unsupported", pair)
+		return
+	}
+	assertFileMatches(t, src, dest)
+	info, err := os.Stat(dest)
+	assert.NilError(t, err, "Stat")
+	assert.Equal(t, info.Mode().Perm(), os.FileMode(0755))
+}
@@ -0,0 +1,88 @@
+package fs
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// packageJSONFile is the root package.json, which can hold the config in a "turbo" key
+const packageJSONFile = "package.json"
+
+// ConfigMigration is a value in a legacy shape of the config, which turbo translated
+// to the current schema when it read the config. `turbo migrate-config` rewrites
+// the config so that nothing needs to be translated.
+type ConfigMigration struct {
+	// File holds the legacy value, relative to the repository root
+	File string `json:"file"`
+	// From is the key of the legacy value, as a path separated by dots
+	From string `json:"from"`
+	// Value is the legacy value, when it's one element of From rather than all of it
+	Value string `json:"value,omitempty"`
+	// To is the key of turbo.json that the value was read as, or the file itself
+	// when all of From was
+	To string `json:"to,omitempty"`
+	// Ignored is true if the value wasn't read at all
+	Ignored bool `json:"ignored,omitempty"`
+	// Message describes the migration
+	Message string `json:"message"`
+}
+
+func (m *ConfigMigration) describe() string {
+	switch {
+	case m.Ignored:
+		return fmt.Sprintf("%v: %q is ignored, because %v exists", m.File, m.From, configFile)
+	case m.Value == "":
+		return fmt.Sprintf("%v: %q was read as %v", m.File, m.From, m.To)
+	default:
+		return fmt.Sprintf("%v: %q in %v was read as %q in %v", m.File, m.Value, m.From, strings.TrimPrefix(m.Value, envPipelineDelimiter), m.To)
+	}
+}
+
+// migrationsIn returns copies of migrations that were found in a config under
+// prefix in file, with their messages
+func migrationsIn(file string, prefix string, migrations []ConfigMigration) []ConfigMigration {
+	result := make([]ConfigMigration, len(migrations))
+	for i, migration := range migrations {
+		migration.File = file
+		migration.From = prefix + migration.From
+		migration.Message = migration.describe()
+		result[i] = migration
+	}
+	return result
+}
+
+// envVarDependencyMigrations returns a migration for each environment variable in
+// the dependsOn of a task, which is read as part of the task's env. TaskDefinition
+// doesn't know its own name, so they are found here rather than as tasks are read.
+func envVarDependencyMigrations(data []byte) ([]ConfigMigration, error) {
+	var raw struct {
+		Pipeline map[string]struct {
+			DependsOn []string `json:"dependsOn"`
+		} `json:"pipeline"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	tasks := make([]string, 0, len(raw.Pipeline))
+	for task := range raw.Pipeline {
+		tasks = append(tasks, task)
+	}
+	sort.Strings(tasks)
+	var migrations []ConfigMigration
+	for _, task := range tasks {
+		seen := make(map[string]bool)
+		for _, dependency := range raw.Pipeline[task].DependsOn {
+			if strings.HasPrefix(dependency, envPipelineDelimiter) && !seen[dependency] {
+				seen[dependency] = true
+				migrations = append(migrations, ConfigMigration{
+					From:  fmt.Sprintf("pipeline.%v.dependsOn", task),
+					Value: dependency,
+					To:    fmt.Sprintf("pipeline.%v.env", task),
+				})
+			}
+		}
+	}
+	return migrations, nil
+}
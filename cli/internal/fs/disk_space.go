@@ -0,0 +1,20 @@
+package fs
+
+import (
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+// DiskSpace describes the filesystem that holds a path
+type DiskSpace struct {
+	// Filesystem identifies the filesystem, such that paths on the same filesystem
+	// have the same Filesystem
+	Filesystem string
+	// Available is the number of bytes that can still be written by the current user
+	Available uint64
+}
+
+// GetDiskSpace returns the space available on the filesystem that holds path,
+// which must exist
+func GetDiskSpace(path turbopath.AbsoluteSystemPath) (DiskSpace, error) {
+	return getDiskSpace(path.ToString())
+}
@@ -0,0 +1,14 @@
+//go:build !linux && !darwin && !windows
+// +build !linux,!darwin,!windows
+
+package fs
+
+import "errors"
+
+// errDiskSpaceUnsupported is returned by GetDiskSpace on platforms where the free
+// space of a filesystem can't be determined
+var errDiskSpaceUnsupported = errors.New("checking free disk space is not supported on this platform")
+
+func getDiskSpace(path string) (DiskSpace, error) {
+	return DiskSpace{}, errDiskSpaceUnsupported
+}
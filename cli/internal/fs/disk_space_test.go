@@ -0,0 +1,23 @@
+//go:build linux || darwin || windows
+// +build linux darwin windows
+
+package fs
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestGetDiskSpace(t *testing.T) {
+	dir := AbsoluteSystemPathFromUpstream(t.TempDir())
+	assert.NilError(t, dir.UntypedJoin("nested").Mkdir(0755))
+
+	space, err := GetDiskSpace(dir)
+	assert.NilError(t, err)
+	assert.Assert(t, space.Available > 0)
+	assert.Assert(t, space.Filesystem != "")
+	nested, err := GetDiskSpace(dir.UntypedJoin("nested"))
+	assert.NilError(t, err)
+	assert.Equal(t, nested.Filesystem, space.Filesystem)
+}
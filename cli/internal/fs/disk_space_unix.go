@@ -0,0 +1,25 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package fs
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+func getDiskSpace(path string) (DiskSpace, error) {
+	var stat unix.Stat_t
+	if err := unix.Stat(path, &stat); err != nil {
+		return DiskSpace{}, err
+	}
+	var statfs unix.Statfs_t
+	if err := unix.Statfs(path, &statfs); err != nil {
+		return DiskSpace{}, err
+	}
+	return DiskSpace{
+		Filesystem: fmt.Sprint(stat.Dev),
+		Available:  uint64(statfs.Bavail) * uint64(statfs.Bsize),
+	}, nil
+}
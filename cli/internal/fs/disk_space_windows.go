@@ -0,0 +1,27 @@
+//go:build windows
+// +build windows
+
+package fs
+
+import (
+	"golang.org/x/sys/windows"
+)
+
+func getDiskSpace(path string) (DiskSpace, error) {
+	pathPtr, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return DiskSpace{}, err
+	}
+	volume := make([]uint16, windows.MAX_PATH+1)
+	if err := windows.GetVolumePathName(pathPtr, &volume[0], uint32(len(volume))); err != nil {
+		return DiskSpace{}, err
+	}
+	var available uint64
+	if err := windows.GetDiskFreeSpaceEx(&volume[0], &available, nil, nil); err != nil {
+		return DiskSpace{}, err
+	}
+	return DiskSpace{
+		Filesystem: windows.UTF16ToString(volume),
+		Available:  available,
+	}, nil
+}
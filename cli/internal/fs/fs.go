@@ -64,7 +64,8 @@ func FileExists(filename string) bool {
 }
 
 // CopyFile copies a file from 'from' to 'to', with an attempt to perform a copy & rename
-// to avoid chaos if anything goes wrong partway.
+// to avoid chaos if anything goes wrong partway. Regular files are cloned, rather than
+// copied, on filesystems that support copy-on-write.
 func CopyFile(from *LstatCachedFile, to string) error {
 	fromMode, err := from.GetMode()
 	if err != nil {
@@ -87,6 +88,9 @@ func CopyFile(from *LstatCachedFile, to string) error {
 		}
 		return os.Symlink(target, to)
 	}
+	if cloneFile(from.Path.ToString(), to, fromMode) {
+		return nil
+	}
 	fromFile, err := from.Path.Open()
 	if err != nil {
 		return err
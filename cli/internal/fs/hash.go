@@ -11,6 +11,13 @@ import (
 	"github.com/vercel/turbo/cli/internal/xxhash"
 )
 
+// HashSchemaVersion is the version of what goes into the hashes of tasks, and how
+// they are calculated. It is part of every hash, and is recorded with every
+// artifact, so it must be incremented whenever either changes: artifacts of the
+// previous version are then never restored for hashes of this one.
+const HashSchemaVersion = 1
+
+// HashObject returns the hex encoded xxHash64 of i, formatted with the %v verb
 func HashObject(i interface{}) (string, error) {
 	hash := xxhash.New()
 
@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"runtime"
 
 	"github.com/adrg/xdg"
 	"github.com/vercel/turbo/cli/internal/turbopath"
@@ -103,15 +104,38 @@ func TempDir(subDir string) turbopath.AbsoluteSystemPath {
 }
 
 // GetTurboDataDir returns a directory outside of the repo
-// where turbo can store data files related to turbo.
+// where turbo can store data files related to turbo. If the
+// user's data directory is read-only, as the home directory
+// is in some sandboxed builds, a temp directory is used instead.
 func GetTurboDataDir() turbopath.AbsoluteSystemPath {
 	dataHome := AbsoluteSystemPathFromUpstream(xdg.DataHome)
-	return dataHome.UntypedJoin("turborepo")
+	dataDir := dataHome.UntypedJoin("turborepo")
+	if !IsWritable(dataDir) {
+		return TempDir("turborepo")
+	}
+	return dataDir
 }
 
-// GetUserConfigDir returns the platform-specific common location
-// for configuration files that belong to a user.
+// GetUserConfigDir returns the location for configuration files that
+// belong to a user. It follows the XDG base directory spec on every
+// platform: $XDG_CONFIG_HOME/turborepo if XDG_CONFIG_HOME is set, otherwise
+// ~/.config/turborepo. Windows uses the platform-specific location instead.
 func GetUserConfigDir() turbopath.AbsoluteSystemPath {
+	if configHome := os.Getenv("XDG_CONFIG_HOME"); filepath.IsAbs(configHome) {
+		return AbsoluteSystemPathFromUpstream(configHome).UntypedJoin("turborepo")
+	}
+	if runtime.GOOS != "windows" {
+		if home, err := os.UserHomeDir(); err == nil && filepath.IsAbs(home) {
+			return AbsoluteSystemPathFromUpstream(home).UntypedJoin(".config", "turborepo")
+		}
+	}
+	return GetLegacyUserConfigDir()
+}
+
+// GetLegacyUserConfigDir returns the platform-specific location that older
+// versions of turbo used for configuration files that belong to a user,
+// such as ~/Library/Application Support/turborepo on macOS.
+func GetLegacyUserConfigDir() turbopath.AbsoluteSystemPath {
 	configHome := AbsoluteSystemPathFromUpstream(xdg.ConfigHome)
 	return configHome.UntypedJoin("turborepo")
 }
@@ -4,12 +4,16 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"log"
+	"net/url"
 	"os"
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
+	"github.com/vercel/turbo/cli/internal/doublestar"
 	"github.com/vercel/turbo/cli/internal/turbopath"
 	"github.com/vercel/turbo/cli/internal/util"
 	"muzzammil.xyz/jsonc"
@@ -19,6 +23,8 @@ const (
 	configFile                   = "turbo.json"
 	envPipelineDelimiter         = "$"
 	topologicalPipelineDelimiter = "^"
+	// TagPrefix marks a reference to every task with a given tag, e.g. "tag:e2e"
+	TagPrefix = "tag:"
 )
 
 var defaultOutputs = TaskOutputs{Inclusions: []string{"dist/**/*", "build/**/*"}}
@@ -33,6 +39,18 @@ type rawTurboJSON struct {
 	Pipeline Pipeline
 	// Configuration options when interfacing with the remote cache
 	RemoteCacheOptions RemoteCacheOptions `json:"remoteCache,omitempty"`
+	// Roots lists the directories of monorepos, each with their own turbo.json,
+	// that are federated into a single graph
+	Roots []string `json:"roots,omitempty"`
+	// Defaults for `turbo run` flags
+	Defaults RunDefaults `json:"defaults,omitempty"`
+	// HashExclude are globs of files that aren't hashed, such as vendored trees
+	HashExclude []string `json:"hashExclude,omitempty"`
+	// UndeclaredImports is whether to warn about imports of workspaces that aren't
+	// declared as dependencies, or to also infer the dependencies
+	UndeclaredImports string `json:"undeclaredImports,omitempty"`
+	// NotifyWebhook is a URL that the summary of each run is POSTed to
+	NotifyWebhook string `json:"notifyWebhook,omitempty"`
 }
 
 // TurboJSON is the root turborepo configuration
@@ -41,23 +59,166 @@ type TurboJSON struct {
 	GlobalEnv          []string
 	Pipeline           Pipeline
 	RemoteCacheOptions RemoteCacheOptions
+	Roots              []string
+	Defaults           RunDefaults
+	// HashExclude are globs, relative to the repository root, of files that are left
+	// out of the hash of the package that contains them
+	HashExclude []string
+	// UndeclaredImports is UndeclaredImportsWarn or UndeclaredImportsInfer, or empty
+	// to not look for imports of workspaces that aren't declared as dependencies
+	UndeclaredImports string
+	// NotifyWebhook is an http or https URL that the summary of each run is POSTed
+	// to once it's over, or empty
+	NotifyWebhook string
+	// Migrations are the legacy config values that were translated to the current
+	// schema when the config was read
+	Migrations []ConfigMigration
+
+	// rawPipeline holds the JSON of each task definition, so that workspaces can
+	// override some of its keys
+	rawPipeline map[string]json.RawMessage
+}
+
+const (
+	// UndeclaredImportsWarn warns about imports of workspaces that aren't dependencies
+	UndeclaredImportsWarn = "warn"
+	// UndeclaredImportsInfer also treats the imported workspaces as dependencies
+	UndeclaredImportsInfer = "infer"
+)
+
+// RunDefaults is a struct for deserializing .defaults of configFile. Each field is
+// the value of a `turbo run` flag, used when the flag isn't passed. Empty fields
+// leave the flag's own default in place.
+type RunDefaults struct {
+	CacheDir string `json:"cacheDir,omitempty"`
+	// CacheMaxSize is a size such as "5GB", as for --cache-max-size
+	CacheMaxSize string `json:"cacheMaxSize,omitempty"`
+	// Concurrency is a number or a percentage of CPU cores, as for --concurrency
+	Concurrency string `json:"concurrency,omitempty"`
+	OutputLogs  string `json:"outputLogs,omitempty"`
+	// Warnings are values for --warnings, applied in order
+	Warnings    []string `json:"warnings,omitempty"`
+	EnvMode     string   `json:"envMode,omitempty"`
+	Environment string   `json:"environment,omitempty"`
+	// AllowMissingTasks is "true" or "false", read from a boolean
+	AllowMissingTasks string `json:"allowMissingTasks,omitempty"`
+	// PersistentOutsideConcurrency is "true" or "false", read from a boolean
+	PersistentOutsideConcurrency string `json:"persistentOutsideConcurrency,omitempty"`
+	LogOrder                     string `json:"logOrder,omitempty"`
+}
+
+// UnmarshalJSON deserializes RunDefaults, accepting a number for concurrency
+func (d *RunDefaults) UnmarshalJSON(data []byte) error {
+	raw := struct {
+		CacheDir     string          `json:"cacheDir"`
+		CacheMaxSize string          `json:"cacheMaxSize"`
+		Concurrency  json.RawMessage `json:"concurrency"`
+		OutputLogs   string          `json:"outputLogs"`
+		Warnings     []string        `json:"warnings"`
+		EnvMode      string          `json:"envMode"`
+		Environment  string          `json:"environment"`
+		// AllowMissingTasks is a boolean
+		AllowMissingTasks *bool `json:"allowMissingTasks"`
+		// PersistentOutsideConcurrency is a boolean
+		PersistentOutsideConcurrency *bool  `json:"persistentOutsideConcurrency"`
+		LogOrder                     string `json:"logOrder"`
+	}{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	d.CacheDir = raw.CacheDir
+	d.CacheMaxSize = raw.CacheMaxSize
+	d.OutputLogs = raw.OutputLogs
+	d.Warnings = raw.Warnings
+	d.EnvMode = raw.EnvMode
+	d.Environment = raw.Environment
+	d.LogOrder = raw.LogOrder
+	d.AllowMissingTasks = ""
+	if raw.AllowMissingTasks != nil {
+		d.AllowMissingTasks = strconv.FormatBool(*raw.AllowMissingTasks)
+	}
+	d.PersistentOutsideConcurrency = ""
+	if raw.PersistentOutsideConcurrency != nil {
+		d.PersistentOutsideConcurrency = strconv.FormatBool(*raw.PersistentOutsideConcurrency)
+	}
+	d.Concurrency = ""
+	if len(raw.Concurrency) > 0 && string(raw.Concurrency) != "null" {
+		var number json.Number
+		if err := json.Unmarshal(raw.Concurrency, &d.Concurrency); err == nil {
+			return nil
+		} else if err := json.Unmarshal(raw.Concurrency, &number); err != nil {
+			return fmt.Errorf("\"concurrency\" in \"defaults\" must be a number or a percentage: %w", err)
+		}
+		d.Concurrency = number.String()
+	}
+	return nil
 }
 
 // RemoteCacheOptions is a struct for deserializing .remoteCache of configFile
 type RemoteCacheOptions struct {
-	TeamID    string `json:"teamId,omitempty"`
-	Signature bool   `json:"signature,omitempty"`
+	TeamID     string `json:"teamId,omitempty"`
+	Signature  bool   `json:"signature,omitempty"`
+	Encryption bool   `json:"encryption,omitempty"`
+	// Provider is the URL of the storage that holds artifacts in place of the
+	// Remote Cache API, such as "s3://bucket/prefix", "gs://bucket/prefix" or
+	// "azblob://account/container/prefix"
+	Provider string `json:"provider,omitempty"`
 }
 
 type rawTask struct {
-	Outputs    *[]string           `json:"outputs"`
-	Cache      *bool               `json:"cache,omitempty"`
-	DependsOn  []string            `json:"dependsOn,omitempty"`
-	Inputs     []string            `json:"inputs,omitempty"`
-	OutputMode util.TaskOutputMode `json:"outputMode,omitempty"`
-	Env        []string            `json:"env,omitempty"`
+	Outputs     *[]string            `json:"outputs"`
+	Cache       *bool                `json:"cache,omitempty"`
+	DependsOn   []string             `json:"dependsOn,omitempty"`
+	Inputs      []string             `json:"inputs,omitempty"`
+	OutputMode  *util.TaskOutputMode `json:"outputMode,omitempty"`
+	Env         []string             `json:"env,omitempty"`
+	HashCommand string               `json:"hashCommand,omitempty"`
+	Tags        []string             `json:"tags,omitempty"`
+	// MaxOutputSize is a size such as "100MB"
+	MaxOutputSize       string `json:"maxOutputSize,omitempty"`
+	MaxOutputSizeAction string `json:"maxOutputSizeAction,omitempty"`
+	// MachineSpecificOutputs is what to do with outputs that embed absolute paths of
+	// the machine that produced them
+	MachineSpecificOutputs string `json:"machineSpecificOutputs,omitempty"`
+	Passthrough            bool   `json:"passthrough,omitempty"`
+	// OutputOf maps dependencies to globs of the outputs that the task consumes
+	OutputOf     map[string][]string `json:"outputOf,omitempty"`
+	FailOnStderr bool                `json:"failOnStderr,omitempty"`
+	// FailOnOutput is a list of regular expressions
+	FailOnOutput     []string    `json:"failOnOutput,omitempty"`
+	ConcurrencyGroup string      `json:"concurrencyGroup,omitempty"`
+	MaxParallel      *int        `json:"maxParallel,omitempty"`
+	Persistent       bool        `json:"persistent,omitempty"`
+	OrderOnly        []string    `json:"orderOnly,omitempty"`
+	Retries          *rawRetries `json:"retries,omitempty"`
+	Artifacts        []string    `json:"artifacts,omitempty"`
+	TypeScript       bool        `json:"typescript,omitempty"`
 }
 
+// rawRetries is the retry policy of a task
+type rawRetries struct {
+	Count int `json:"count"`
+	// Delay is a duration such as "5s"
+	Delay string `json:"delay,omitempty"`
+}
+
+// Actions for a task whose outputs exceed its maxOutputSize
+const (
+	maxOutputSizeWarn = "warn"
+	maxOutputSizeFail = "fail"
+)
+
+// Actions for a task whose outputs embed absolute paths of the machine, such as
+// the repository root, which would be wrong on other machines
+const (
+	// MachineSpecificOutputsSkip warns, and doesn't cache the outputs
+	MachineSpecificOutputsSkip = "skip"
+	// MachineSpecificOutputsWarn warns, and caches the outputs anyway
+	MachineSpecificOutputsWarn = "warn"
+	// MachineSpecificOutputsAllow doesn't check the outputs
+	MachineSpecificOutputsAllow = "allow"
+)
+
 // Pipeline is a struct for deserializing .pipeline in configFile
 type Pipeline map[string]TaskDefinition
 
@@ -70,6 +231,66 @@ type TaskDefinition struct {
 	TaskDependencies        []string
 	Inputs                  []string
 	OutputMode              util.TaskOutputMode
+	// HasOutputMode is true when outputMode is set for this task, in which case
+	// it takes precedence over --output-logs
+	HasOutputMode bool
+	// HasOutputs is true when outputs is set for this task, rather than defaulted
+	HasOutputs  bool
+	HashCommand string
+	Tags        []string
+	// MaxOutputSize is the largest total size of outputs, in bytes, that will be
+	// saved to the cache. Zero means there is no limit.
+	MaxOutputSize int64
+	// FailOnMaxOutputSize fails the task, rather than warning, when its outputs
+	// exceed MaxOutputSize
+	FailOnMaxOutputSize bool
+	// MachineSpecificOutputs is MachineSpecificOutputsSkip, MachineSpecificOutputsWarn,
+	// or MachineSpecificOutputsAllow. Empty is the same as MachineSpecificOutputsWarn.
+	MachineSpecificOutputs string
+	// Passthrough marks a task that has nothing to do, because dependents consume the
+	// package's source directly. It is never executed, and dependents are invalidated
+	// by changes to the package's files, but not by the task's env or outputs.
+	Passthrough bool
+	// OutputOf maps dependencies, written as in dependsOn, to globs of the outputs
+	// that the task consumes, relative to the dependency's workspace. They are also
+	// in TopologicalDependencies or TaskDependencies.
+	OutputOf map[string][]string
+	// FailOnStderr fails the task if it writes anything other than whitespace to
+	// stderr, even if it exits successfully
+	FailOnStderr bool
+	// FailOnOutput fails the task if a line that it writes to stdout or stderr
+	// matches one of these regular expressions, even if it exits successfully
+	FailOnOutput []string
+	// ConcurrencyGroup names a group of tasks that share MaxParallel. If it is
+	// empty, each task is its own group.
+	ConcurrencyGroup string
+	// MaxParallel is the most tasks in the concurrency group that run at once,
+	// regardless of --concurrency. Zero means there is no limit.
+	MaxParallel int
+	// Persistent marks a long-running task, such as a dev server or a watcher, that
+	// never completes. Other tasks can only depend on it through OutputOf.
+	Persistent bool
+	// OrderOnlyTaskDependencies are tasks, written as in TaskDependencies, that this
+	// task runs after if they're also running. They aren't run because this task is,
+	// and this task runs even if they fail.
+	OrderOnlyTaskDependencies []string
+	// OrderOnlyTopologicalDependencies are like OrderOnlyTaskDependencies, for the
+	// tasks of the package's dependencies, as in TopologicalDependencies
+	OrderOnlyTopologicalDependencies []string
+	// Retries is how many more times the task is run after it fails, before its
+	// failure is reported
+	Retries int
+	// RetryDelay is how long to wait before each retry
+	RetryDelay time.Duration
+	// Artifacts are globs, relative to the workspace, of reports that are collected
+	// into a directory for the run once the task completes. They are not cached,
+	// unless they are also outputs.
+	Artifacts []string
+	// TypeScript marks a task that builds the TypeScript project of each workspace.
+	// It runs after the same task in the workspace's dependencies, caches the
+	// .tsbuildinfo file and the outDir of the project, and runs `tsc --build` in
+	// workspaces with a tsconfig.json and no script for the task.
+	TypeScript bool
 }
 
 // LoadTurboConfig loads, or optionally, synthesizes a TurboJSON instance
@@ -91,6 +312,9 @@ func LoadTurboConfig(rootPath turbopath.AbsoluteSystemPath, rootPackageJSON *Pac
 	} else if err != nil {
 		// some other happened, we can't recover
 		return nil, err
+	} else if len(turboFromFiles.Roots) > 0 {
+		// The top level of a federation of monorepos is never a single package
+		return turboFromFiles, nil
 	} else {
 		// we're synthesizing, but we have a starting point
 		// Note: this will have to change to support task inference in a monorepo
@@ -122,6 +346,8 @@ type TaskOutputs struct {
 }
 
 // ReadTurboConfig toggles between reading from package.json or the configFile to support early adopters.
+// The pipeline doesn't include the workspace configs until the package graph is built
+// with context.BuildPackageGraphWithConfig.
 func ReadTurboConfig(rootPath turbopath.AbsoluteSystemPath, rootPackageJSON *PackageJSON) (*TurboJSON, error) {
 
 	turboJSONPath := rootPath.UntypedJoin(configFile)
@@ -136,12 +362,15 @@ func ReadTurboConfig(rootPath turbopath.AbsoluteSystemPath, rootPackageJSON *Pac
 			return nil, fmt.Errorf("%s: %w", configFile, err)
 		}
 
-		// If pkg.Turbo exists, log a warning and delete it from the representation
-		// TODO: turn off this warning eventually
+		migrations := migrationsIn(configFile, "", turboJSON.Migrations)
+		// If pkg.Turbo exists, record that it's ignored and delete it from the representation
 		if hasLegacyConfig {
-			log.Printf("[WARNING] Ignoring \"turbo\" key in package.json, using %s instead.", configFile)
+			ignored := ConfigMigration{File: packageJSONFile, From: "turbo", Ignored: true}
+			ignored.Message = ignored.describe()
+			migrations = append([]ConfigMigration{ignored}, migrations...)
 			rootPackageJSON.LegacyTurboConfig = nil
 		}
+		turboJSON.Migrations = migrations
 
 		return turboJSON, nil
 	}
@@ -149,8 +378,11 @@ func ReadTurboConfig(rootPath turbopath.AbsoluteSystemPath, rootPackageJSON *Pac
 	// Use pkg.Turbo if the configFile doesn't exist and we want the fallback feature
 	// TODO: turn this fallback off eventually
 	if hasLegacyConfig {
-		log.Printf("[DEPRECATED] \"turbo\" in package.json is deprecated. Migrate to %s by running \"npx @turbo/codemod create-turbo-config\"\n", configFile)
-		return rootPackageJSON.LegacyTurboConfig, nil
+		turboJSON := *rootPackageJSON.LegacyTurboConfig
+		legacy := ConfigMigration{File: packageJSONFile, From: "turbo", To: configFile}
+		legacy.Message = legacy.describe()
+		turboJSON.Migrations = append([]ConfigMigration{legacy}, migrationsIn(packageJSONFile, "turbo.", turboJSON.Migrations)...)
+		return &turboJSON, nil
 	}
 
 	// If there's no turbo.json and no turbo key in package.json, return an error.
@@ -205,6 +437,61 @@ func (pc Pipeline) HasTask(task string) bool {
 	return false
 }
 
+// TasksWithTag returns the sorted names of the tasks, not package-tasks, that carry the given tag
+func (pc Pipeline) TasksWithTag(tag string) []string {
+	var tasks []string
+	for key, taskDefinition := range pc {
+		if !util.IsPackageTask(key) && taskDefinition.HasTag(tag) {
+			tasks = append(tasks, key)
+		}
+	}
+	sort.Strings(tasks)
+	return tasks
+}
+
+// OutputSubset returns the globs of the outputs of dependencyID that taskID consumes,
+// if it depends on them through outputOf, rather than on dependencyID completing
+func (c TaskDefinition) OutputSubset(taskID string, dependencyID string) ([]string, bool) {
+	pkg, _ := util.GetPackageTaskFromId(taskID)
+	dependencyPkg, dependencyTask := util.GetPackageTaskFromId(dependencyID)
+	for dependency, globs := range c.OutputOf {
+		if strings.HasPrefix(dependency, topologicalPipelineDelimiter) {
+			if dependencyPkg != pkg && dependencyTask == strings.TrimPrefix(dependency, topologicalPipelineDelimiter) {
+				return globs, true
+			}
+		} else if util.IsPackageTask(dependency) {
+			if dependency == dependencyID {
+				return globs, true
+			}
+		} else if dependencyPkg == pkg && dependencyTask == dependency {
+			return globs, true
+		}
+	}
+	return nil, false
+}
+
+// HasTag returns true if the task carries the given tag
+func (c TaskDefinition) HasTag(tag string) bool {
+	for _, t := range c.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// withTypeScriptDependency returns task with a dependency on taskName in the
+// workspace's dependencies if it builds TypeScript projects, since a project is
+// built after the projects it references, which are the projects of those workspaces
+func withTypeScriptDependency(taskName string, task TaskDefinition) TaskDefinition {
+	if !task.TypeScript || util.SetFromStrings(task.TopologicalDependencies).Includes(taskName) {
+		return task
+	}
+	task.TopologicalDependencies = append(append([]string{}, task.TopologicalDependencies...), taskName)
+	sort.Strings(task.TopologicalDependencies)
+	return task
+}
+
 // UnmarshalJSON deserializes JSON into a TaskDefinition
 func (c *TaskDefinition) UnmarshalJSON(data []byte) error {
 	task := rawTask{}
@@ -231,6 +518,10 @@ func (c *TaskDefinition) UnmarshalJSON(data []byte) error {
 			Inclusions: inclusions,
 			Exclusions: exclusions,
 		}
+		c.HasOutputs = true
+	} else if task.TypeScript {
+		// The outputs of each project are read from its tsconfig.json
+		c.Outputs = TaskOutputs{}
 	} else {
 		c.Outputs = defaultOutputs
 	}
@@ -248,7 +539,7 @@ func (c *TaskDefinition) UnmarshalJSON(data []byte) error {
 
 	for _, dependency := range task.DependsOn {
 		if strings.HasPrefix(dependency, envPipelineDelimiter) {
-			log.Printf("[DEPRECATED] Declaring an environment variable in \"dependsOn\" is deprecated, found %s. Use the \"env\" key or use `npx @turbo/codemod migrate-env-var-dependencies`.\n", dependency)
+			// Recorded as a migration by TurboJSON.UnmarshalJSON, which knows the task
 			envVarDependencies.Add(strings.TrimPrefix(dependency, envPipelineDelimiter))
 		} else if strings.HasPrefix(dependency, topologicalPipelineDelimiter) {
 			c.TopologicalDependencies = append(c.TopologicalDependencies, strings.TrimPrefix(dependency, topologicalPipelineDelimiter))
@@ -256,9 +547,48 @@ func (c *TaskDefinition) UnmarshalJSON(data []byte) error {
 			c.TaskDependencies = append(c.TaskDependencies, dependency)
 		}
 	}
+	for dependency, globs := range task.OutputOf {
+		if strings.HasPrefix(dependency, envPipelineDelimiter) || strings.HasPrefix(dependency, TagPrefix) {
+			return fmt.Errorf("invalid dependency %q in \"outputOf\", it must be a task", dependency)
+		}
+		for _, dependsOn := range task.DependsOn {
+			if dependsOn == dependency {
+				return fmt.Errorf("%q is in both \"dependsOn\" and \"outputOf\", it can only be in one", dependency)
+			}
+		}
+		if len(globs) == 0 {
+			return fmt.Errorf("\"outputOf\" must list the outputs of %q that are consumed", dependency)
+		}
+		if strings.HasPrefix(dependency, topologicalPipelineDelimiter) {
+			c.TopologicalDependencies = append(c.TopologicalDependencies, strings.TrimPrefix(dependency, topologicalPipelineDelimiter))
+		} else {
+			c.TaskDependencies = append(c.TaskDependencies, dependency)
+		}
+	}
+	c.OutputOf = task.OutputOf
 	sort.Strings(c.TaskDependencies)
 	sort.Strings(c.TopologicalDependencies)
 
+	c.OrderOnlyTaskDependencies = nil
+	c.OrderOnlyTopologicalDependencies = nil
+	for _, dependency := range task.OrderOnly {
+		if strings.HasPrefix(dependency, envPipelineDelimiter) {
+			return fmt.Errorf("invalid dependency %q in \"orderOnly\", it must be a task", dependency)
+		}
+		for _, dependsOn := range task.DependsOn {
+			if dependsOn == dependency {
+				return fmt.Errorf("%q is in both \"dependsOn\" and \"orderOnly\", it can only be in one", dependency)
+			}
+		}
+		if strings.HasPrefix(dependency, topologicalPipelineDelimiter) {
+			c.OrderOnlyTopologicalDependencies = append(c.OrderOnlyTopologicalDependencies, strings.TrimPrefix(dependency, topologicalPipelineDelimiter))
+		} else {
+			c.OrderOnlyTaskDependencies = append(c.OrderOnlyTaskDependencies, dependency)
+		}
+	}
+	sort.Strings(c.OrderOnlyTaskDependencies)
+	sort.Strings(c.OrderOnlyTopologicalDependencies)
+
 	// Append env key into EnvVarDependencies
 	for _, value := range task.Env {
 		if strings.HasPrefix(value, envPipelineDelimiter) {
@@ -275,7 +605,89 @@ func (c *TaskDefinition) UnmarshalJSON(data []byte) error {
 	// Note that we don't require Inputs to be sorted, we're going to
 	// hash the resulting files and sort that instead
 	c.Inputs = task.Inputs
-	c.OutputMode = task.OutputMode
+	if task.OutputMode != nil {
+		c.OutputMode = *task.OutputMode
+		c.HasOutputMode = true
+	}
+	c.HashCommand = task.HashCommand
+	for _, tag := range task.Tags {
+		if tag == "" || strings.ContainsAny(tag, ":#^$") {
+			return fmt.Errorf("invalid tag %q, tags cannot be empty or contain \":\", \"#\", \"^\", or \"$\"", tag)
+		}
+	}
+	c.Tags = task.Tags
+	sort.Strings(c.Tags)
+	if task.MaxOutputSize != "" {
+		maxOutputSize, err := util.ParseByteSize(task.MaxOutputSize)
+		if err != nil {
+			return fmt.Errorf("maxOutputSize: %w", err)
+		}
+		c.MaxOutputSize = maxOutputSize
+	}
+	switch task.MaxOutputSizeAction {
+	case "", maxOutputSizeWarn:
+		c.FailOnMaxOutputSize = false
+	case maxOutputSizeFail:
+		c.FailOnMaxOutputSize = true
+	default:
+		return fmt.Errorf("invalid maxOutputSizeAction %q, must be one of \"%v\" or \"%v\"", task.MaxOutputSizeAction, maxOutputSizeWarn, maxOutputSizeFail)
+	}
+	switch task.MachineSpecificOutputs {
+	case "", MachineSpecificOutputsSkip, MachineSpecificOutputsWarn, MachineSpecificOutputsAllow:
+		c.MachineSpecificOutputs = task.MachineSpecificOutputs
+	default:
+		return fmt.Errorf("invalid machineSpecificOutputs %q, must be one of \"%v\", \"%v\", or \"%v\"", task.MachineSpecificOutputs, MachineSpecificOutputsSkip, MachineSpecificOutputsWarn, MachineSpecificOutputsAllow)
+	}
+	if task.Passthrough && c.ShouldCache {
+		return fmt.Errorf("passthrough tasks have no outputs to cache, set \"cache\": false")
+	}
+	c.Passthrough = task.Passthrough
+	if task.Persistent && c.ShouldCache {
+		return fmt.Errorf("persistent tasks never complete, so their outputs can't be cached, set \"cache\": false")
+	}
+	c.Persistent = task.Persistent
+	for _, pattern := range task.FailOnOutput {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid pattern in \"failOnOutput\": %w", err)
+		}
+	}
+	c.FailOnStderr = task.FailOnStderr
+	c.FailOnOutput = task.FailOnOutput
+	for _, glob := range task.Artifacts {
+		if !doublestar.ValidatePattern(strings.TrimPrefix(glob, "!")) {
+			return fmt.Errorf("invalid glob %q in \"artifacts\"", glob)
+		}
+	}
+	c.Artifacts = task.Artifacts
+	c.TypeScript = task.TypeScript
+	c.ConcurrencyGroup = task.ConcurrencyGroup
+	c.MaxParallel = 0
+	if task.MaxParallel != nil {
+		if *task.MaxParallel < 1 {
+			return fmt.Errorf("invalid maxParallel %v, it must be at least 1", *task.MaxParallel)
+		}
+		c.MaxParallel = *task.MaxParallel
+	} else if task.ConcurrencyGroup != "" {
+		// Tasks in a group run one at a time unless they say otherwise
+		c.MaxParallel = 1
+	}
+	c.Retries = 0
+	c.RetryDelay = 0
+	if task.Retries != nil {
+		if task.Retries.Count < 0 {
+			return fmt.Errorf("invalid retries count %v, it can't be negative", task.Retries.Count)
+		}
+		c.Retries = task.Retries.Count
+		if task.Retries.Delay != "" {
+			delay, err := time.ParseDuration(task.Retries.Delay)
+			if err != nil {
+				return fmt.Errorf("invalid retries delay: %w", err)
+			} else if delay < 0 {
+				return fmt.Errorf("invalid retries delay %q, it can't be negative", task.Retries.Delay)
+			}
+			c.RetryDelay = delay
+		}
+	}
 	return nil
 }
 
@@ -301,7 +713,7 @@ func (c *TurboJSON) UnmarshalJSON(data []byte) error {
 
 	for _, value := range raw.GlobalDependencies {
 		if strings.HasPrefix(value, envPipelineDelimiter) {
-			log.Printf("[DEPRECATED] Declaring an environment variable in \"globalDependencies\" is deprecated, found %s. Use the \"globalEnv\" key or use `npx @turbo/codemod migrate-env-var-dependencies`.\n", value)
+			c.Migrations = append(c.Migrations, ConfigMigration{From: "globalDependencies", Value: value, To: "globalEnv"})
 			envVarDependencies.Add(strings.TrimPrefix(value, envPipelineDelimiter))
 		} else {
 			globalFileDependencies.Add(value)
@@ -314,9 +726,50 @@ func (c *TurboJSON) UnmarshalJSON(data []byte) error {
 	c.GlobalDeps = globalFileDependencies.UnsafeListOfStrings()
 	sort.Strings(c.GlobalDeps)
 
+	taskMigrations, err := envVarDependencyMigrations(data)
+	if err != nil {
+		return err
+	}
+	c.Migrations = append(c.Migrations, taskMigrations...)
+
+	rawPipeline := struct {
+		Pipeline map[string]json.RawMessage `json:"pipeline"`
+	}{}
+	if err := json.Unmarshal(data, &rawPipeline); err != nil {
+		return err
+	}
+	c.rawPipeline = rawPipeline.Pipeline
+
 	// copy these over, we don't need any changes here.
 	c.Pipeline = raw.Pipeline
+	for taskID, task := range c.Pipeline {
+		c.Pipeline[taskID] = withTypeScriptDependency(util.StripPackageName(taskID), task)
+	}
 	c.RemoteCacheOptions = raw.RemoteCacheOptions
+	c.Roots = raw.Roots
+	c.Defaults = raw.Defaults
+
+	for _, pattern := range raw.HashExclude {
+		if !doublestar.ValidatePattern(pattern) {
+			return fmt.Errorf("invalid glob %q in \"hashExclude\"", pattern)
+		}
+	}
+	c.HashExclude = raw.HashExclude
+
+	switch raw.UndeclaredImports {
+	case "", UndeclaredImportsWarn, UndeclaredImportsInfer:
+		c.UndeclaredImports = raw.UndeclaredImports
+	default:
+		return fmt.Errorf("invalid \"undeclaredImports\" %q, expected %q or %q", raw.UndeclaredImports, UndeclaredImportsWarn, UndeclaredImportsInfer)
+	}
+
+	if raw.NotifyWebhook != "" {
+		u, err := url.Parse(raw.NotifyWebhook)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid \"notifyWebhook\" %q, expected an http or https URL", raw.NotifyWebhook)
+		}
+	}
+	c.NotifyWebhook = raw.NotifyWebhook
 
 	return nil
 }
@@ -1,11 +1,13 @@
 package fs
 
 import (
+	"encoding/json"
 	"os"
 	"reflect"
 	"sort"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/vercel/turbo/cli/internal/turbopath"
@@ -45,19 +47,23 @@ func Test_ReadTurboConfig(t *testing.T) {
 	pipelineExpected := map[string]TaskDefinition{
 		"build": {
 			Outputs:                 TaskOutputs{Inclusions: []string{".next/**", "dist/**"}, Exclusions: []string{"dist/assets/**"}},
+			HasOutputs:              true,
 			TopologicalDependencies: []string{"build"},
 			EnvVarDependencies:      []string{},
 			TaskDependencies:        []string{},
 			ShouldCache:             true,
 			OutputMode:              util.NewTaskOutput,
+			HasOutputMode:           true,
 		},
 		"lint": {
 			Outputs:                 TaskOutputs{},
+			HasOutputs:              true,
 			TopologicalDependencies: []string{},
 			EnvVarDependencies:      []string{"MY_VAR"},
 			TaskDependencies:        []string{},
 			ShouldCache:             true,
 			OutputMode:              util.NewTaskOutput,
+			HasOutputMode:           true,
 		},
 		"dev": {
 			Outputs:                 defaultOutputs,
@@ -66,9 +72,11 @@ func Test_ReadTurboConfig(t *testing.T) {
 			TaskDependencies:        []string{},
 			ShouldCache:             false,
 			OutputMode:              util.FullTaskOutput,
+			HasOutputMode:           true,
 		},
 		"publish": {
 			Outputs:                 TaskOutputs{Inclusions: []string{"dist/**"}},
+			HasOutputs:              true,
 			TopologicalDependencies: []string{"build", "publish"},
 			EnvVarDependencies:      []string{},
 			TaskDependencies:        []string{"admin#lint", "build"},
@@ -80,7 +88,7 @@ func Test_ReadTurboConfig(t *testing.T) {
 
 	validateOutput(t, turboJSON, pipelineExpected)
 
-	remoteCacheOptionsExpected := RemoteCacheOptions{"team_id", true}
+	remoteCacheOptionsExpected := RemoteCacheOptions{TeamID: "team_id", Signature: true}
 	assert.EqualValues(t, remoteCacheOptionsExpected, turboJSON.RemoteCacheOptions)
 }
 
@@ -134,17 +142,19 @@ func Test_ReadTurboConfig_BothCorrectAndLegacy(t *testing.T) {
 	pipelineExpected := map[string]TaskDefinition{
 		"build": {
 			Outputs:                 TaskOutputs{Inclusions: []string{".next/**", "dist/**"}, Exclusions: []string{"dist/assets/**"}},
+			HasOutputs:              true,
 			TopologicalDependencies: []string{"build"},
 			EnvVarDependencies:      []string{},
 			TaskDependencies:        []string{},
 			ShouldCache:             true,
 			OutputMode:              util.NewTaskOutput,
+			HasOutputMode:           true,
 		},
 	}
 
 	validateOutput(t, turboJSON, pipelineExpected)
 
-	remoteCacheOptionsExpected := RemoteCacheOptions{"team_id", true}
+	remoteCacheOptionsExpected := RemoteCacheOptions{TeamID: "team_id", Signature: true}
 	assert.EqualValues(t, remoteCacheOptionsExpected, turboJSON.RemoteCacheOptions)
 
 	assert.Equal(t, rootPackageJSON.LegacyTurboConfig == nil, true)
@@ -234,6 +244,287 @@ func Test_ReadTurboConfig_EnvDeclarations(t *testing.T) {
 	assert.EqualValues(t, sortedArray([]string{"somefile.txt"}), sortedArray(turboJSON.GlobalDeps))
 }
 
+func Test_ReadTurboConfig_Migrations(t *testing.T) {
+	testCases := []struct {
+		dir      string
+		expected []string
+	}{
+		{
+			dir: "correct",
+			expected: []string{
+				`turbo.json: "$GLOBAL_ENV_VAR" in globalDependencies was read as "GLOBAL_ENV_VAR" in globalEnv`,
+				`turbo.json: "$MY_VAR" in pipeline.lint.dependsOn was read as "MY_VAR" in pipeline.lint.env`,
+			},
+		},
+		{
+			dir:      "legacy-only",
+			expected: []string{`package.json: "turbo" was read as turbo.json`},
+		},
+		{
+			dir:      "both",
+			expected: []string{`package.json: "turbo" is ignored, because turbo.json exists`},
+		},
+		{
+			dir: "legacy-env",
+			expected: []string{
+				`turbo.json: "$FOO" in globalDependencies was read as "FOO" in globalEnv`,
+				`turbo.json: "$BAR" in globalDependencies was read as "BAR" in globalEnv`,
+				`turbo.json: "$A" in pipeline.task1.dependsOn was read as "A" in pipeline.task1.env`,
+				`turbo.json: "$A" in pipeline.task10.dependsOn was read as "A" in pipeline.task10.env`,
+				`turbo.json: "$B" in pipeline.task11.dependsOn was read as "B" in pipeline.task11.env`,
+				`turbo.json: "$A" in pipeline.task3.dependsOn was read as "A" in pipeline.task3.env`,
+				`turbo.json: "$A" in pipeline.task4.dependsOn was read as "A" in pipeline.task4.env`,
+				`turbo.json: "$D" in pipeline.task6.dependsOn was read as "D" in pipeline.task6.env`,
+				`turbo.json: "$E" in pipeline.task6.dependsOn was read as "E" in pipeline.task6.env`,
+				`turbo.json: "$F" in pipeline.task6.dependsOn was read as "F" in pipeline.task6.env`,
+				`turbo.json: "$A" in pipeline.task7.dependsOn was read as "A" in pipeline.task7.env`,
+				`turbo.json: "$B" in pipeline.task7.dependsOn was read as "B" in pipeline.task7.env`,
+				`turbo.json: "$C" in pipeline.task7.dependsOn was read as "C" in pipeline.task7.env`,
+				`turbo.json: "$A" in pipeline.task9.dependsOn was read as "A" in pipeline.task9.env`,
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.dir, func(t *testing.T) {
+			testDir := getTestDir(t, tc.dir)
+			rootPackageJSON, err := ReadPackageJSON(testDir.UntypedJoin("package.json"))
+			if err != nil {
+				t.Fatalf("invalid parse: %#v", err)
+			}
+			turboJSON, err := ReadTurboConfig(testDir, rootPackageJSON)
+			if err != nil {
+				t.Fatalf("invalid parse: %#v", err)
+			}
+			messages := []string{}
+			for _, migration := range turboJSON.Migrations {
+				messages = append(messages, migration.Message)
+			}
+			assert.Equal(t, tc.expected, messages)
+		})
+	}
+}
+
+func Test_ReadTurboConfig_LegacyEnvMigrations(t *testing.T) {
+	var rootPackageJSON PackageJSON
+	err := json.Unmarshal([]byte(`{
+		"turbo": {
+			"pipeline": { "build": { "dependsOn": ["^build", "$NODE_ENV"] } }
+		}
+	}`), &rootPackageJSON)
+	if err != nil {
+		t.Fatalf("invalid parse: %#v", err)
+	}
+	turboJSON, err := ReadTurboConfig(turbopath.AbsoluteSystemPath(t.TempDir()), &rootPackageJSON)
+	if err != nil {
+		t.Fatalf("invalid parse: %#v", err)
+	}
+	assert.Equal(t, []ConfigMigration{
+		{File: "package.json", From: "turbo", To: "turbo.json", Message: `package.json: "turbo" was read as turbo.json`},
+		{
+			File:    "package.json",
+			From:    "turbo.pipeline.build.dependsOn",
+			Value:   "$NODE_ENV",
+			To:      "pipeline.build.env",
+			Message: `package.json: "$NODE_ENV" in turbo.pipeline.build.dependsOn was read as "NODE_ENV" in pipeline.build.env`,
+		},
+	}, turboJSON.Migrations)
+	// Reading the config again doesn't prefix the keys twice
+	again, err := ReadTurboConfig(turbopath.AbsoluteSystemPath(t.TempDir()), &rootPackageJSON)
+	if err != nil {
+		t.Fatalf("invalid parse: %#v", err)
+	}
+	assert.Equal(t, turboJSON.Migrations, again.Migrations)
+}
+
+func Test_TaskDefinition_Tags(t *testing.T) {
+	var pipeline Pipeline
+	err := json.Unmarshal([]byte(`{
+		"build": { "tags": ["slow", "ci"] },
+		"e2e": { "tags": ["slow"] },
+		"web#lint": { "tags": ["slow"] },
+		"lint": {}
+	}`), &pipeline)
+	if err != nil {
+		t.Fatalf("invalid parse: %#v", err)
+	}
+	assert.EqualValues(t, []string{"ci", "slow"}, pipeline["build"].Tags)
+	assert.EqualValues(t, []string{"build", "e2e"}, pipeline.TasksWithTag("slow"))
+	assert.Empty(t, pipeline.TasksWithTag("missing"))
+
+	err = json.Unmarshal([]byte(`{ "build": { "tags": ["tag:slow"] } }`), &pipeline)
+	assert.ErrorContains(t, err, "invalid tag \"tag:slow\"")
+}
+
+func Test_TaskDefinition_MaxOutputSize(t *testing.T) {
+	var pipeline Pipeline
+	err := json.Unmarshal([]byte(`{
+		"build": { "maxOutputSize": "100MB" },
+		"e2e": { "maxOutputSize": "1GB", "maxOutputSizeAction": "fail" },
+		"lint": {}
+	}`), &pipeline)
+	if err != nil {
+		t.Fatalf("invalid parse: %#v", err)
+	}
+	assert.EqualValues(t, 100*1024*1024, pipeline["build"].MaxOutputSize)
+	assert.False(t, pipeline["build"].FailOnMaxOutputSize)
+	assert.EqualValues(t, 1024*1024*1024, pipeline["e2e"].MaxOutputSize)
+	assert.True(t, pipeline["e2e"].FailOnMaxOutputSize)
+	assert.EqualValues(t, 0, pipeline["lint"].MaxOutputSize)
+
+	err = json.Unmarshal([]byte(`{ "build": { "maxOutputSize": "lots" } }`), &pipeline)
+	assert.ErrorContains(t, err, "maxOutputSize: invalid size \"lots\"")
+	err = json.Unmarshal([]byte(`{ "build": { "maxOutputSizeAction": "ignore" } }`), &pipeline)
+	assert.ErrorContains(t, err, "invalid maxOutputSizeAction \"ignore\"")
+}
+
+func Test_TaskDefinition_Passthrough(t *testing.T) {
+	var pipeline Pipeline
+	err := json.Unmarshal([]byte(`{
+		"build": {},
+		"ui#build": { "cache": false, "passthrough": true }
+	}`), &pipeline)
+	if err != nil {
+		t.Fatalf("invalid parse: %#v", err)
+	}
+	assert.False(t, pipeline["build"].Passthrough)
+	assert.True(t, pipeline["ui#build"].Passthrough)
+
+	err = json.Unmarshal([]byte(`{ "build": { "passthrough": true } }`), &pipeline)
+	assert.ErrorContains(t, err, "passthrough tasks have no outputs to cache")
+}
+
+func Test_TaskDefinition_FailOnOutput(t *testing.T) {
+	var pipeline Pipeline
+	err := json.Unmarshal([]byte(`{
+		"build": {},
+		"lint": { "failOnStderr": true, "failOnOutput": ["^ERROR", "\\d+ warnings?"] }
+	}`), &pipeline)
+	if err != nil {
+		t.Fatalf("invalid parse: %#v", err)
+	}
+	assert.False(t, pipeline["build"].FailOnStderr)
+	assert.Empty(t, pipeline["build"].FailOnOutput)
+	assert.True(t, pipeline["lint"].FailOnStderr)
+	assert.EqualValues(t, []string{"^ERROR", "\\d+ warnings?"}, pipeline["lint"].FailOnOutput)
+
+	err = json.Unmarshal([]byte(`{ "build": { "failOnOutput": ["(ERROR"] } }`), &pipeline)
+	assert.ErrorContains(t, err, "invalid pattern in \"failOnOutput\"")
+}
+
+func Test_TaskDefinition_OutputOf(t *testing.T) {
+	var pipeline Pipeline
+	err := json.Unmarshal([]byte(`{
+		"typecheck": {
+			"dependsOn": ["codegen"],
+			"outputOf": { "^build": ["dist/types/**"], "ui#tokens": ["tokens.json"], "schema": ["schema.graphql"] }
+		}
+	}`), &pipeline)
+	if err != nil {
+		t.Fatalf("invalid parse: %#v", err)
+	}
+	typecheck := pipeline["typecheck"]
+	assert.EqualValues(t, []string{"build"}, typecheck.TopologicalDependencies)
+	assert.EqualValues(t, []string{"codegen", "schema", "ui#tokens"}, typecheck.TaskDependencies)
+
+	globs, ok := typecheck.OutputSubset("web#typecheck", "ui#build")
+	assert.True(t, ok)
+	assert.EqualValues(t, []string{"dist/types/**"}, globs)
+	_, ok = typecheck.OutputSubset("web#typecheck", "web#build")
+	assert.False(t, ok, "^build only refers to dependencies")
+	globs, ok = typecheck.OutputSubset("web#typecheck", "web#schema")
+	assert.True(t, ok)
+	assert.EqualValues(t, []string{"schema.graphql"}, globs)
+	_, ok = typecheck.OutputSubset("web#typecheck", "ui#schema")
+	assert.False(t, ok)
+	globs, ok = typecheck.OutputSubset("web#typecheck", "ui#tokens")
+	assert.True(t, ok)
+	assert.EqualValues(t, []string{"tokens.json"}, globs)
+	_, ok = typecheck.OutputSubset("web#typecheck", "web#codegen")
+	assert.False(t, ok, "dependsOn waits for completion")
+
+	err = json.Unmarshal([]byte(`{ "test": { "dependsOn": ["^build"], "outputOf": { "^build": ["dist/**"] } } }`), &pipeline)
+	assert.ErrorContains(t, err, "\"^build\" is in both \"dependsOn\" and \"outputOf\"")
+	err = json.Unmarshal([]byte(`{ "test": { "outputOf": { "^build": [] } } }`), &pipeline)
+	assert.ErrorContains(t, err, "\"outputOf\" must list the outputs of \"^build\"")
+	err = json.Unmarshal([]byte(`{ "test": { "outputOf": { "tag:codegen": ["gen/**"] } } }`), &pipeline)
+	assert.ErrorContains(t, err, "invalid dependency \"tag:codegen\" in \"outputOf\"")
+}
+
+func Test_TurboJSON_HashExclude(t *testing.T) {
+	var turboJSON TurboJSON
+	err := json.Unmarshal([]byte(`{ "pipeline": {}, "hashExclude": ["vendor/**", "**/fixtures/large/**"] }`), &turboJSON)
+	if err != nil {
+		t.Fatalf("invalid parse: %#v", err)
+	}
+	assert.Equal(t, []string{"vendor/**", "**/fixtures/large/**"}, turboJSON.HashExclude)
+
+	err = json.Unmarshal([]byte(`{ "pipeline": {}, "hashExclude": ["vendor/[a-"] }`), &turboJSON)
+	assert.ErrorContains(t, err, "invalid glob \"vendor/[a-\" in \"hashExclude\"")
+}
+
+func Test_TurboJSON_UndeclaredImports(t *testing.T) {
+	var turboJSON TurboJSON
+	err := json.Unmarshal([]byte(`{ "pipeline": {}, "undeclaredImports": "infer" }`), &turboJSON)
+	if err != nil {
+		t.Fatalf("invalid parse: %#v", err)
+	}
+	assert.Equal(t, UndeclaredImportsInfer, turboJSON.UndeclaredImports)
+
+	err = json.Unmarshal([]byte(`{ "pipeline": {}, "undeclaredImports": "error" }`), &turboJSON)
+	assert.ErrorContains(t, err, "invalid \"undeclaredImports\" \"error\", expected \"warn\" or \"infer\"")
+}
+
+func Test_TurboJSON_NotifyWebhook(t *testing.T) {
+	var turboJSON TurboJSON
+	err := json.Unmarshal([]byte(`{ "pipeline": {}, "notifyWebhook": "https://ci.example.com/turbo" }`), &turboJSON)
+	if err != nil {
+		t.Fatalf("invalid parse: %#v", err)
+	}
+	assert.Equal(t, "https://ci.example.com/turbo", turboJSON.NotifyWebhook)
+
+	err = json.Unmarshal([]byte(`{ "pipeline": {}, "notifyWebhook": "ci.example.com/turbo" }`), &turboJSON)
+	assert.ErrorContains(t, err, "invalid \"notifyWebhook\" \"ci.example.com/turbo\", expected an http or https URL")
+}
+
+func Test_TurboJSON_Artifacts(t *testing.T) {
+	var turboJSON TurboJSON
+	err := json.Unmarshal([]byte(`{ "pipeline": { "test": { "artifacts": ["coverage/**", "!coverage/tmp/**"] } } }`), &turboJSON)
+	if err != nil {
+		t.Fatalf("invalid parse: %#v", err)
+	}
+	assert.Equal(t, []string{"coverage/**", "!coverage/tmp/**"}, turboJSON.Pipeline["test"].Artifacts)
+
+	err = json.Unmarshal([]byte(`{ "pipeline": { "test": { "artifacts": ["coverage/[a-"] } } }`), &turboJSON)
+	assert.ErrorContains(t, err, "invalid glob \"coverage/[a-\" in \"artifacts\"")
+}
+
+func Test_TurboJSON_Defaults(t *testing.T) {
+	var turboJSON TurboJSON
+	err := json.Unmarshal([]byte(`{
+		"pipeline": {},
+		"defaults": { "cacheDir": ".cache/turbo", "cacheMaxSize": "5GB", "concurrency": 4, "outputLogs": "new-only" }
+	}`), &turboJSON)
+	if err != nil {
+		t.Fatalf("invalid parse: %#v", err)
+	}
+	assert.Equal(t, RunDefaults{CacheDir: ".cache/turbo", CacheMaxSize: "5GB", Concurrency: "4", OutputLogs: "new-only"}, turboJSON.Defaults)
+
+	err = json.Unmarshal([]byte(`{ "pipeline": {}, "defaults": { "concurrency": "50%" } }`), &turboJSON)
+	if err != nil {
+		t.Fatalf("invalid parse: %#v", err)
+	}
+	assert.Equal(t, RunDefaults{Concurrency: "50%"}, turboJSON.Defaults)
+
+	err = json.Unmarshal([]byte(`{ "pipeline": {}, "defaults": { "allowMissingTasks": true, "persistentOutsideConcurrency": false } }`), &turboJSON)
+	if err != nil {
+		t.Fatalf("invalid parse: %#v", err)
+	}
+	assert.Equal(t, RunDefaults{AllowMissingTasks: "true", PersistentOutsideConcurrency: "false"}, turboJSON.Defaults)
+
+	err = json.Unmarshal([]byte(`{ "pipeline": {}, "defaults": { "concurrency": true } }`), &turboJSON)
+	assert.ErrorContains(t, err, "\"concurrency\" in \"defaults\" must be a number or a percentage")
+}
+
 // Helpers
 func validateOutput(t *testing.T, turboJSON *TurboJSON, expectedPipeline map[string]TaskDefinition) {
 	t.Helper()
@@ -290,3 +581,107 @@ func sortedArray(arr []string) []string {
 	sort.Strings(arr)
 	return arr
 }
+
+func Test_TaskDefinition_MaxParallel(t *testing.T) {
+	var pipeline Pipeline
+	err := json.Unmarshal([]byte(`{
+		"build": { "maxParallel": 2 },
+		"db:migrate": { "concurrencyGroup": "database" },
+		"db:seed": { "concurrencyGroup": "database", "maxParallel": 3 },
+		"lint": {}
+	}`), &pipeline)
+	if err != nil {
+		t.Fatalf("invalid parse: %#v", err)
+	}
+	assert.Equal(t, 2, pipeline["build"].MaxParallel)
+	assert.Equal(t, "", pipeline["build"].ConcurrencyGroup)
+	assert.Equal(t, 1, pipeline["db:migrate"].MaxParallel)
+	assert.Equal(t, "database", pipeline["db:migrate"].ConcurrencyGroup)
+	assert.Equal(t, 3, pipeline["db:seed"].MaxParallel)
+	assert.Equal(t, 0, pipeline["lint"].MaxParallel)
+
+	err = json.Unmarshal([]byte(`{ "build": { "maxParallel": 0 } }`), &pipeline)
+	assert.ErrorContains(t, err, "invalid maxParallel 0")
+}
+
+func Test_TaskDefinition_Persistent(t *testing.T) {
+	var pipeline Pipeline
+	err := json.Unmarshal([]byte(`{
+		"build": {},
+		"dev": { "persistent": true, "cache": false }
+	}`), &pipeline)
+	if err != nil {
+		t.Fatalf("invalid parse: %#v", err)
+	}
+	assert.False(t, pipeline["build"].Persistent)
+	assert.True(t, pipeline["dev"].Persistent)
+
+	err = json.Unmarshal([]byte(`{ "dev": { "persistent": true } }`), &pipeline)
+	assert.ErrorContains(t, err, "persistent tasks never complete, so their outputs can't be cached")
+}
+
+func Test_TaskDefinition_OrderOnly(t *testing.T) {
+	var pipeline Pipeline
+	err := json.Unmarshal([]byte(`{
+		"build": {},
+		"lint": { "orderOnly": ["typecheck", "^build", "build"] }
+	}`), &pipeline)
+	if err != nil {
+		t.Fatalf("invalid parse: %#v", err)
+	}
+	assert.Nil(t, pipeline["build"].OrderOnlyTaskDependencies)
+	assert.Equal(t, []string{"build", "typecheck"}, pipeline["lint"].OrderOnlyTaskDependencies)
+	assert.Equal(t, []string{"build"}, pipeline["lint"].OrderOnlyTopologicalDependencies)
+	assert.Empty(t, pipeline["lint"].TaskDependencies)
+
+	err = json.Unmarshal([]byte(`{ "lint": { "orderOnly": ["$FOO"] } }`), &pipeline)
+	assert.ErrorContains(t, err, "invalid dependency \"$FOO\" in \"orderOnly\"")
+
+	err = json.Unmarshal([]byte(`{ "lint": { "dependsOn": ["build"], "orderOnly": ["build"] } }`), &pipeline)
+	assert.ErrorContains(t, err, "\"build\" is in both \"dependsOn\" and \"orderOnly\"")
+}
+
+func Test_TaskDefinition_Retries(t *testing.T) {
+	var pipeline Pipeline
+	err := json.Unmarshal([]byte(`{
+		"build": {},
+		"test": { "retries": { "count": 2, "delay": "5s" } }
+	}`), &pipeline)
+	if err != nil {
+		t.Fatalf("invalid parse: %#v", err)
+	}
+	assert.Equal(t, 0, pipeline["build"].Retries)
+	assert.Equal(t, 2, pipeline["test"].Retries)
+	assert.Equal(t, 5*time.Second, pipeline["test"].RetryDelay)
+
+	err = json.Unmarshal([]byte(`{ "test": { "retries": { "count": -1 } } }`), &pipeline)
+	assert.ErrorContains(t, err, "invalid retries count -1")
+
+	err = json.Unmarshal([]byte(`{ "test": { "retries": { "count": 1, "delay": "soon" } } }`), &pipeline)
+	assert.ErrorContains(t, err, "invalid retries delay")
+}
+
+func Test_TurboJSON_TypeScript(t *testing.T) {
+	var turboJSON TurboJSON
+	err := json.Unmarshal([]byte(`{ "pipeline": {
+		"typecheck": { "typescript": true },
+		"web#compile": { "typescript": true, "dependsOn": ["^compile", "codegen"], "outputs": ["lib/**"] },
+		"build": {}
+	} }`), &turboJSON)
+	if err != nil {
+		t.Fatalf("invalid parse: %#v", err)
+	}
+	typecheck := turboJSON.Pipeline["typecheck"]
+	assert.True(t, typecheck.TypeScript)
+	// The outputs of each project come from its tsconfig.json
+	assert.Empty(t, typecheck.Outputs.Inclusions)
+	assert.Equal(t, []string{"typecheck"}, typecheck.TopologicalDependencies)
+
+	compile := turboJSON.Pipeline["web#compile"]
+	assert.Equal(t, []string{"compile"}, compile.TopologicalDependencies)
+	assert.Equal(t, []string{"codegen"}, compile.TaskDependencies)
+	assert.Equal(t, []string{"lib/**"}, compile.Outputs.Inclusions)
+
+	assert.False(t, turboJSON.Pipeline["build"].TypeScript)
+	assert.Empty(t, turboJSON.Pipeline["build"].TopologicalDependencies)
+}
@@ -0,0 +1,119 @@
+package fs
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// _workspaceConfigExtends is the only config that a workspace config can extend,
+// the root turbo.json
+const _workspaceConfigExtends = "//"
+
+// ApplyWorkspaceConfigs adds the task definitions in the "turbo" key of each
+// workspace's package.json to the pipeline, as package-tasks of that workspace.
+// A workspace config extends the root config:
+//
+//	"turbo": {"extends": ["//"], "pipeline": {"build": {"outputs": ["out/**"]}}}
+//
+// Each task's keys override those of the root config's definition of the task,
+// and the keys that it leaves out are inherited. A task that the root config
+// doesn't define is defined for that workspace alone.
+func (c *TurboJSON) ApplyWorkspaceConfigs(packages map[interface{}]*PackageJSON) error {
+	var names []string
+	for name := range packages {
+		if name, ok := name.(string); ok && name != util.RootPkgName {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		pkg := packages[name]
+		raw, ok := pkg.RawJSON["turbo"]
+		if !ok {
+			continue
+		}
+		if err := c.applyWorkspaceConfig(name, raw); err != nil {
+			return fmt.Errorf("%v: \"turbo\": %w", pkg.PackageJSONPath, err)
+		}
+	}
+	return nil
+}
+
+func (c *TurboJSON) applyWorkspaceConfig(pkgName string, raw interface{}) error {
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return fmt.Errorf("expected an object: %w", err)
+	}
+	for key := range keys {
+		if key != "extends" && key != "pipeline" {
+			return fmt.Errorf("%q can only be set in the root turbo.json, a workspace can only set \"extends\" and \"pipeline\"", key)
+		}
+	}
+	var config struct {
+		Extends  []string                   `json:"extends"`
+		Pipeline map[string]json.RawMessage `json:"pipeline"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return err
+	}
+	if len(config.Extends) != 1 || config.Extends[0] != _workspaceConfigExtends {
+		return fmt.Errorf("a workspace config must extend the root turbo.json with \"extends\": [%q]", _workspaceConfigExtends)
+	}
+	taskNames := make([]string, 0, len(config.Pipeline))
+	for taskName := range config.Pipeline {
+		taskNames = append(taskNames, taskName)
+	}
+	sort.Strings(taskNames)
+	for _, taskName := range taskNames {
+		if util.IsPackageTask(taskName) {
+			return fmt.Errorf("pipeline.%v: tasks of a workspace config apply to the workspace, and can't name a package", taskName)
+		}
+		taskID := util.GetTaskId(pkgName, taskName)
+		if _, ok := c.Pipeline[taskID]; ok {
+			return fmt.Errorf("pipeline.%v: %v is also defined in turbo.json, define it in one place", taskName, taskID)
+		}
+		definition, err := c.extendTaskDefinition(taskName, config.Pipeline[taskName])
+		if err != nil {
+			return fmt.Errorf("pipeline.%v: %w", taskName, err)
+		}
+		if c.Pipeline == nil {
+			c.Pipeline = make(Pipeline)
+		}
+		c.Pipeline[taskID] = withTypeScriptDependency(taskName, definition)
+	}
+	return nil
+}
+
+// extendTaskDefinition returns the root config's definition of taskName, with
+// the keys of override in place of its own
+func (c *TurboJSON) extendTaskDefinition(taskName string, override json.RawMessage) (TaskDefinition, error) {
+	merged := make(map[string]json.RawMessage)
+	if base, ok := c.rawPipeline[taskName]; ok {
+		if err := json.Unmarshal(base, &merged); err != nil {
+			return TaskDefinition{}, err
+		}
+	}
+	var overrides map[string]json.RawMessage
+	if err := json.Unmarshal(override, &overrides); err != nil {
+		return TaskDefinition{}, fmt.Errorf("expected an object: %w", err)
+	}
+	for key, value := range overrides {
+		merged[key] = value
+	}
+	data, err := json.Marshal(merged)
+	if err != nil {
+		return TaskDefinition{}, err
+	}
+	var definition TaskDefinition
+	if err := json.Unmarshal(data, &definition); err != nil {
+		return TaskDefinition{}, err
+	}
+	return definition, nil
+}
@@ -0,0 +1,128 @@
+package fs
+
+import (
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"gotest.tools/v3/assert"
+	"muzzammil.xyz/jsonc"
+)
+
+func workspaceConfigFixture(t *testing.T, turboJSON string, packageJSONs map[string]string) (*TurboJSON, map[interface{}]*PackageJSON) {
+	t.Helper()
+	var config *TurboJSON
+	assert.NilError(t, jsonc.Unmarshal([]byte(turboJSON), &config), "Unmarshal")
+	packages := make(map[interface{}]*PackageJSON)
+	for name, contents := range packageJSONs {
+		pkg, err := UnmarshalPackageJSON([]byte(contents))
+		assert.NilError(t, err, "UnmarshalPackageJSON")
+		pkg.PackageJSONPath = turbopath.AnchoredSystemPath("packages/" + name + "/package.json")
+		packages[name] = pkg
+	}
+	return config, packages
+}
+
+func TestApplyWorkspaceConfigs(t *testing.T) {
+	config, packages := workspaceConfigFixture(t, `{
+  "pipeline": {
+    "build": {"dependsOn": ["^build"], "outputs": ["dist/**"], "env": ["API_URL"]},
+    "test": {"dependsOn": ["build"]}
+  }
+}`, map[string]string{
+		"web": `{"name": "web", "turbo": {
+  "extends": ["//"],
+  "pipeline": {
+    "build": {"outputs": [".next/**", "!.next/cache/**"]},
+    "e2e": {"dependsOn": ["build"], "cache": false}
+  }
+}}`,
+		"docs": `{"name": "docs"}`,
+	})
+	assert.NilError(t, config.ApplyWorkspaceConfigs(packages))
+
+	build := config.Pipeline["web#build"]
+	assert.DeepEqual(t, build.Outputs, TaskOutputs{Inclusions: []string{".next/**"}, Exclusions: []string{".next/cache/**"}})
+	// Keys that the workspace leaves out are inherited
+	assert.DeepEqual(t, build.TopologicalDependencies, []string{"build"})
+	assert.DeepEqual(t, build.EnvVarDependencies, []string{"API_URL"})
+	assert.Assert(t, build.ShouldCache)
+
+	e2e := config.Pipeline["web#e2e"]
+	assert.DeepEqual(t, e2e.TaskDependencies, []string{"build"})
+	assert.Assert(t, !e2e.ShouldCache)
+
+	// Other workspaces and tasks keep the root definitions
+	_, ok := config.Pipeline["docs#build"]
+	assert.Assert(t, !ok)
+	assert.DeepEqual(t, config.Pipeline["build"].Outputs.Inclusions, []string{"dist/**"})
+	_, ok = config.Pipeline["web#test"]
+	assert.Assert(t, !ok)
+}
+
+func TestApplyWorkspaceConfigsTypeScript(t *testing.T) {
+	config, packages := workspaceConfigFixture(t, `{
+  "pipeline": {
+    "build": {"outputs": ["dist/**"]},
+    "typecheck": {"typescript": true, "dependsOn": ["^codegen"]}
+  }
+}`, map[string]string{
+		"web": `{"name": "web", "turbo": {
+  "extends": ["//"],
+  "pipeline": {
+    "build": {"typescript": true},
+    "typecheck": {"dependsOn": ["codegen"]}
+  }
+}}`,
+	})
+	assert.NilError(t, config.ApplyWorkspaceConfigs(packages))
+
+	// A task that a workspace config makes a TypeScript task runs after the same
+	// task in the workspace's dependencies
+	assert.DeepEqual(t, config.Pipeline["web#build"].TopologicalDependencies, []string{"build"})
+	// as does an inherited TypeScript task whose dependsOn is overridden
+	typecheck := config.Pipeline["web#typecheck"]
+	assert.DeepEqual(t, typecheck.TopologicalDependencies, []string{"typecheck"})
+	assert.DeepEqual(t, typecheck.TaskDependencies, []string{"codegen"})
+}
+
+func TestApplyWorkspaceConfigsErrors(t *testing.T) {
+	testCases := []struct {
+		name    string
+		turbo   string
+		wantErr string
+	}{
+		{
+			name:    "missing extends",
+			turbo:   `{"pipeline": {"build": {}}}`,
+			wantErr: `packages/web/package.json: "turbo": a workspace config must extend the root turbo.json with "extends": ["//"]`,
+		},
+		{
+			name:    "root keys",
+			turbo:   `{"extends": ["//"], "globalEnv": ["CI"]}`,
+			wantErr: `"globalEnv" can only be set in the root turbo.json`,
+		},
+		{
+			name:    "package task",
+			turbo:   `{"extends": ["//"], "pipeline": {"docs#build": {}}}`,
+			wantErr: `pipeline.docs#build: tasks of a workspace config apply to the workspace`,
+		},
+		{
+			name:    "defined twice",
+			turbo:   `{"extends": ["//"], "pipeline": {"lint": {}}}`,
+			wantErr: `pipeline.lint: web#lint is also defined in turbo.json`,
+		},
+		{
+			name:    "extends another config",
+			turbo:   `{"extends": ["web"], "pipeline": {}}`,
+			wantErr: `a workspace config must extend the root turbo.json`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config, packages := workspaceConfigFixture(t, `{"pipeline": {"build": {}, "web#lint": {}}}`, map[string]string{
+				"web": `{"name": "web", "turbo": ` + tc.turbo + `}`,
+			})
+			assert.ErrorContains(t, config.ApplyWorkspaceConfigs(packages), tc.wantErr)
+		})
+	}
+}
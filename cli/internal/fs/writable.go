@@ -0,0 +1,71 @@
+package fs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/adrg/xdg"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+// writableDirs memoizes IsWritable, since the mounts that a directory lives on do
+// not change during a run. It is a map of path -> bool.
+var writableDirs sync.Map
+
+// IsWritable returns true if files can be created in dir. If dir does not exist
+// yet, its closest existing parent is checked instead, since that is where dir
+// would be created. This is false for directories on read-only mounts, which are
+// common in some CI and Nix setups.
+func IsWritable(dir turbopath.AbsoluteSystemPath) bool {
+	if writable, ok := writableDirs.Load(dir); ok {
+		return writable.(bool)
+	}
+	existing := dir.ToString()
+	for {
+		if info, err := os.Stat(existing); err == nil {
+			if !info.IsDir() {
+				return false
+			}
+			break
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			return false
+		}
+		existing = parent
+	}
+	writable := false
+	if f, err := os.CreateTemp(existing, ".turbo-write-check-*"); err == nil {
+		_ = f.Close()
+		writable = os.Remove(f.Name()) == nil
+	}
+	writableDirs.Store(dir, writable)
+	return writable
+}
+
+// GetTurboCacheDir returns a directory outside of the repo where turbo can store
+// files that it is able to recreate.
+func GetTurboCacheDir() turbopath.AbsoluteSystemPath {
+	cacheHome := AbsoluteSystemPathFromUpstream(xdg.CacheHome)
+	return cacheHome.UntypedJoin("turborepo")
+}
+
+// WritableDir returns dir, a directory within repoRoot, if it is writable. Otherwise
+// it returns a directory that stands in for it under the user's cache directory,
+// unique to repoRoot, and false.
+func WritableDir(repoRoot turbopath.AbsoluteSystemPath, dir turbopath.AbsoluteSystemPath) (turbopath.AbsoluteSystemPath, bool) {
+	if IsWritable(dir) {
+		return dir, true
+	}
+	relative, err := repoRoot.PathTo(dir)
+	if err != nil || strings.HasPrefix(relative, "..") {
+		relative = dir.Base()
+	}
+	pathHash := sha256.Sum256([]byte(repoRoot.ToString()))
+	repoDir := hex.EncodeToString(pathHash[:])[:16]
+	return GetTurboCacheDir().UntypedJoin("read-only", repoDir, relative), false
+}
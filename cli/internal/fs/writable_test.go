@@ -0,0 +1,35 @@
+package fs
+
+import (
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestIsWritable(t *testing.T) {
+	root := AbsoluteSystemPathFromUpstream(t.TempDir())
+	assert.Assert(t, IsWritable(root))
+	// A directory that does not exist yet is checked against its parent
+	assert.Assert(t, IsWritable(root.UntypedJoin("node_modules", ".cache", "turbo")))
+
+	file := root.UntypedJoin("file")
+	assert.NilError(t, file.WriteFile([]byte("contents"), 0644))
+	assert.Assert(t, !IsWritable(file.UntypedJoin("dir")))
+}
+
+func TestWritableDir(t *testing.T) {
+	repoRoot := AbsoluteSystemPathFromUpstream(t.TempDir())
+	cacheDir := repoRoot.UntypedJoin("node_modules", ".cache", "turbo")
+	dir, ok := WritableDir(repoRoot, cacheDir)
+	assert.Assert(t, ok)
+	assert.Equal(t, dir, cacheDir)
+
+	// node_modules is not a directory, so nothing can be created in it
+	assert.NilError(t, repoRoot.UntypedJoin("node_modules").WriteFile([]byte{}, 0644))
+	otherDir := repoRoot.UntypedJoin("node_modules", ".cache", "other")
+	dir, ok = WritableDir(repoRoot, otherDir)
+	assert.Assert(t, !ok)
+	assert.Assert(t, strings.HasPrefix(dir.ToString(), GetTurboCacheDir().ToString()), dir)
+	assert.Assert(t, strings.HasSuffix(dir.ToString(), otherDir.ToString()[len(repoRoot.ToString()):]), dir)
+}
@@ -0,0 +1,130 @@
+package gen
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/vercel/turbo/cli/internal/jsonedit"
+)
+
+// The files that gen updates are written by hand, so the edits below are made with
+// jsonedit, which keeps their comments and the order of their keys.
+
+// setPackageName sets the top-level "name" of a package.json
+func setPackageName(content []byte, name string) ([]byte, error) {
+	s := jsonedit.NewScanner(content)
+	quoted, err := json.Marshal(name)
+	if err != nil {
+		return nil, err
+	}
+	value, ok, err := s.FindKey(s.Root(), "name")
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return jsonedit.Splice(content, value, string(quoted)), nil
+	}
+	return s.AppendElement(s.Root(), `"name": `+string(quoted))
+}
+
+// addWorkspaceGlob adds glob to the "workspaces" of a root package.json, in either
+// the array or the object form
+func addWorkspaceGlob(content []byte, glob string) ([]byte, error) {
+	s := jsonedit.NewScanner(content)
+	quoted, err := json.Marshal(glob)
+	if err != nil {
+		return nil, err
+	}
+	workspaces, ok, err := s.FindKey(s.Root(), "workspaces")
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("no \"workspaces\" found")
+	}
+	if content[workspaces.Start] == '{' {
+		workspaces, ok, err = s.FindKey(workspaces.Start, "packages")
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("no \"workspaces.packages\" found")
+		}
+	}
+	if content[workspaces.Start] != '[' {
+		return nil, fmt.Errorf("\"workspaces\" is not a list")
+	}
+	return s.AppendElement(workspaces.Start, string(quoted))
+}
+
+// addPipelineTask adds a task definition to the "pipeline" of a turbo.json. The
+// definition is formatted to match the indentation of the surrounding entries.
+func addPipelineTask(content []byte, taskID string, definition json.RawMessage) ([]byte, error) {
+	s := jsonedit.NewScanner(content)
+	pipeline, ok, err := s.FindKey(s.Root(), "pipeline")
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("no \"pipeline\" found")
+	}
+	if content[pipeline.Start] != '{' {
+		return nil, fmt.Errorf("\"pipeline\" is not an object")
+	}
+	if _, exists, err := s.FindKey(pipeline.Start, taskID); err != nil {
+		return nil, err
+	} else if exists {
+		return nil, fmt.Errorf("pipeline already has a task %v", taskID)
+	}
+	key, err := json.Marshal(taskID)
+	if err != nil {
+		return nil, err
+	}
+	formatted := &bytes.Buffer{}
+	if err := json.Indent(formatted, definition, "", s.IndentUnit(pipeline.Start)); err != nil {
+		return nil, fmt.Errorf("invalid task definition for %v: %w", taskID, err)
+	}
+	return s.AppendElement(pipeline.Start, string(key)+": "+formatted.String())
+}
+
+var _pnpmPackagesKey = regexp.MustCompile(`^packages:\s*(#.*)?$`)
+var _yamlListItem = regexp.MustCompile(`^(\s*-\s*)(["']?)`)
+
+// addPnpmWorkspaceGlob adds glob to the packages listed in a pnpm-workspace.yaml
+func addPnpmWorkspaceGlob(content []byte, glob string) ([]byte, error) {
+	lines := strings.Split(string(content), "\n")
+	packagesLine := -1
+	for i, line := range lines {
+		if _pnpmPackagesKey.MatchString(line) {
+			packagesLine = i
+			break
+		}
+	}
+	if packagesLine < 0 {
+		return nil, fmt.Errorf("no \"packages\" list found")
+	}
+	lastItem := -1
+	for i := packagesLine + 1; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		if _yamlListItem.MatchString(lines[i]) {
+			lastItem = i
+		} else if trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+			break
+		}
+	}
+	if lastItem < 0 {
+		return nil, fmt.Errorf("no \"packages\" list found")
+	}
+	match := _yamlListItem.FindStringSubmatch(lines[lastItem])
+	prefix, quote := match[1], match[2]
+	if quote == "" && strings.ContainsAny(glob[:1], "*!&[{") {
+		// These would not be read as plain strings
+		quote = "\""
+	}
+	item := prefix + quote + glob + quote
+	lines = append(lines[:lastItem+1], append([]string{item}, lines[lastItem+1:]...)...)
+	return []byte(strings.Join(lines, "\n")), nil
+}
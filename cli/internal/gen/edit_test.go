@@ -0,0 +1,135 @@
+package gen
+
+import (
+	"encoding/json"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func Test_setPackageName(t *testing.T) {
+	testCases := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "replaces the top-level name",
+			content: `{"author": {"name": "me"}, "name": "ui", "version": "1.0.0"}`,
+			want:    `{"author": {"name": "me"}, "name": "@acme/ui-copy", "version": "1.0.0"}`,
+		},
+		{
+			name:    "adds a missing name",
+			content: "{\n  \"version\": \"1.0.0\"\n}\n",
+			want:    "{\n  \"version\": \"1.0.0\",\n  \"name\": \"@acme/ui-copy\"\n}\n",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := setPackageName([]byte(tc.content), "@acme/ui-copy")
+			assert.NilError(t, err)
+			assert.Equal(t, string(got), tc.want)
+		})
+	}
+}
+
+func Test_addWorkspaceGlob(t *testing.T) {
+	testCases := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "multi-line array",
+			content: "{\n  \"workspaces\": [\n    \"apps/*\",\n    \"packages/*\"\n  ]\n}\n",
+			want:    "{\n  \"workspaces\": [\n    \"apps/*\",\n    \"packages/*\",\n    \"tools/*\"\n  ]\n}\n",
+		},
+		{
+			name:    "single-line array",
+			content: `{"workspaces": ["apps/*"], "scripts": {"build": "turbo run build"}}`,
+			want:    `{"workspaces": ["apps/*", "tools/*"], "scripts": {"build": "turbo run build"}}`,
+		},
+		{
+			name:    "empty array",
+			content: `{"workspaces": []}`,
+			want:    `{"workspaces": ["tools/*"]}`,
+		},
+		{
+			name:    "object form",
+			content: `{"workspaces": {"packages": ["apps/*"], "nohoist": ["**/react"]}}`,
+			want:    `{"workspaces": {"packages": ["apps/*", "tools/*"], "nohoist": ["**/react"]}}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := addWorkspaceGlob([]byte(tc.content), "tools/*")
+			assert.NilError(t, err)
+			assert.Equal(t, string(got), tc.want)
+		})
+	}
+
+	_, err := addWorkspaceGlob([]byte(`{"name": "root"}`), "tools/*")
+	assert.ErrorContains(t, err, "no \"workspaces\" found")
+}
+
+func Test_addPipelineTask(t *testing.T) {
+	content := `{
+    // tasks are below
+    "pipeline": {
+        "build": {
+            "outputs": ["dist/**"] /* the default */
+        }
+    }
+}
+`
+	want := `{
+    // tasks are below
+    "pipeline": {
+        "build": {
+            "outputs": ["dist/**"] /* the default */
+        },
+        "web#build": {
+            "outputs": [
+                ".next/**"
+            ]
+        }
+    }
+}
+`
+	got, err := addPipelineTask([]byte(content), "web#build", json.RawMessage(`{"outputs": [".next/**"]}`))
+	assert.NilError(t, err)
+	assert.Equal(t, string(got), want)
+
+	got, err = addPipelineTask([]byte(`{"pipeline": {}}`), "web#build", json.RawMessage(`{}`))
+	assert.NilError(t, err)
+	assert.Equal(t, string(got), "{\"pipeline\": {\n  \"web#build\": {}\n}}")
+
+	_, err = addPipelineTask([]byte(content), "build", json.RawMessage(`{}`))
+	assert.ErrorContains(t, err, "pipeline already has a task build")
+}
+
+func Test_addPnpmWorkspaceGlob(t *testing.T) {
+	testCases := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "quoted",
+			content: "packages:\n  - \"apps/*\"\n  # shared code\n  - \"packages/*\"\n",
+			want:    "packages:\n  - \"apps/*\"\n  # shared code\n  - \"packages/*\"\n  - \"tools/*\"\n",
+		},
+		{
+			name:    "unquoted",
+			content: "packages:\n- apps/*\n",
+			want:    "packages:\n- apps/*\n- tools/*\n",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := addPnpmWorkspaceGlob([]byte(tc.content), "tools/*")
+			assert.NilError(t, err)
+			assert.Equal(t, string(got), tc.want)
+		})
+	}
+}
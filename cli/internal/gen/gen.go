@@ -0,0 +1,552 @@
+// Package gen holds the `turbo gen` command, which scaffolds new workspaces from a
+// built-in template, an existing workspace, or a generator kept in the repository
+package gen
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/vercel/turbo/cli/internal/cmdutil"
+	"github.com/vercel/turbo/cli/internal/context"
+	"github.com/vercel/turbo/cli/internal/doublestar"
+	"github.com/vercel/turbo/cli/internal/errcode"
+	turbofs "github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// GeneratorsDir is where repository-local generators are kept, relative to the repo root
+const GeneratorsDir = "turbo/generators"
+
+// generatorConfigFile describes a generator, and is not copied into new workspaces
+const generatorConfigFile = "generator.json"
+
+// templateSuffix marks generator files whose contents are rendered with text/template
+const templateSuffix = ".tmpl"
+
+// Types of workspace, which pick the default destination
+const (
+	workspaceTypeApp     = "app"
+	workspaceTypePackage = "package"
+)
+
+// Matches valid npm package names, optionally scoped
+var _packageNameRegex = regexp.MustCompile(`^(@[a-z0-9-~][a-z0-9-._~]*/)?[a-z0-9-~][a-z0-9-._~]*$`)
+
+// Directories that are never copied from an existing workspace
+var _skipCopyDirs = util.SetFromStrings([]string{"node_modules", ".turbo"})
+
+type opts struct {
+	workspaceType string
+	copy          string
+	generator     string
+	destination   string
+}
+
+// Generator is a template for new workspaces, kept in turbo/generators/<name>
+type Generator struct {
+	Name        string `json:"-"`
+	Description string `json:"description"`
+	// Type is "app" or "package"
+	Type string `json:"type"`
+	// Pipeline holds task definitions, keyed by task name, to add to turbo.json
+	// for each workspace created by this generator
+	Pipeline map[string]json.RawMessage `json:"pipeline"`
+
+	dir turbopath.AbsoluteSystemPath
+}
+
+// templateData is available to generator templates
+type templateData struct {
+	// Name is the package name of the new workspace
+	Name string
+	// Dir is the directory of the new workspace, relative to the repo root
+	Dir string
+	// Type is "app" or "package"
+	Type string
+}
+
+// GetCmd returns the gen subcommand for use with cobra
+func GetCmd(helper *cmdutil.Helper) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "gen",
+		Short:                 "Generate new workspaces",
+		SilenceUsage:          true,
+		SilenceErrors:         true,
+		DisableFlagsInUseLine: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Help()
+		},
+	}
+	cmd.AddCommand(workspaceCmd(helper))
+	cmd.AddCommand(listCmd(helper))
+	return cmd
+}
+
+func workspaceCmd(helper *cmdutil.Helper) *cobra.Command {
+	opts := &opts{}
+	cmd := &cobra.Command{
+		Use:   "workspace <name> [<flags>]",
+		Short: "Create a new workspace",
+		Long: `Create a new workspace and add it to the monorepo.
+
+By default the workspace only has a package.json. Use --copy to start from an
+existing workspace, or --generator to use a generator from ` + GeneratorsDir + `.
+The workspace globs are updated if they don't already include the new workspace.`,
+		Args:                  cobra.ExactArgs(1),
+		SilenceUsage:          true,
+		SilenceErrors:         true,
+		DisableFlagsInUseLine: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, err := helper.GetCmdBase(cmd.Flags())
+			if err != nil {
+				return err
+			}
+			if err := generateWorkspace(base, opts, args[0]); err != nil {
+				base.LogError("gen failed: %w", err)
+				return err
+			}
+			return nil
+		},
+	}
+	flags := cmd.Flags()
+	flags.StringVar(&opts.workspaceType, "type", "", "The type of workspace to create, \"app\" or \"package\". Defaults to \"package\", or the generator's type.")
+	flags.StringVar(&opts.copy, "copy", "", "The name of an existing workspace to copy")
+	flags.StringVar(&opts.generator, "generator", "", "The name of a generator in "+GeneratorsDir)
+	flags.StringVar(&opts.destination, "destination", "", "The directory to create the workspace in, relative to the repo root. Defaults to apps/<name> or packages/<name>.")
+	return cmd
+}
+
+func listCmd(helper *cmdutil.Helper) *cobra.Command {
+	return &cobra.Command{
+		Use:                   "list",
+		Short:                 "List the generators in " + GeneratorsDir,
+		Args:                  cobra.NoArgs,
+		SilenceUsage:          true,
+		SilenceErrors:         true,
+		DisableFlagsInUseLine: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, err := helper.GetCmdBase(cmd.Flags())
+			if err != nil {
+				return err
+			}
+			generators, err := ReadGenerators(base.RepoRoot)
+			if err != nil {
+				base.LogError("gen failed: %w", err)
+				return err
+			}
+			if len(generators) == 0 {
+				base.UI.Output(fmt.Sprintf("No generators found in %v", GeneratorsDir))
+				return nil
+			}
+			table := &bytes.Buffer{}
+			w := tabwriter.NewWriter(table, 0, 0, 2, ' ', 0)
+			for _, generator := range generators {
+				fmt.Fprintf(w, "%v\t%v\t%v\n", generator.Name, generator.Type, generator.Description)
+			}
+			if err := w.Flush(); err != nil {
+				return err
+			}
+			base.UI.Output(strings.TrimSuffix(table.String(), "\n"))
+			return nil
+		},
+	}
+}
+
+// ReadGenerators reads the generators in the repository, sorted by name
+func ReadGenerators(repoRoot turbopath.AbsoluteSystemPath) ([]*Generator, error) {
+	generatorsDir := repoRoot.UntypedJoin(GeneratorsDir)
+	entries, err := os.ReadDir(generatorsDir.ToString())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var generators []*Generator
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		generator, err := readGenerator(generatorsDir.UntypedJoin(entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		generators = append(generators, generator)
+	}
+	sort.Slice(generators, func(i, j int) bool { return generators[i].Name < generators[j].Name })
+	return generators, nil
+}
+
+func readGenerator(dir turbopath.AbsoluteSystemPath) (*Generator, error) {
+	generator := &Generator{}
+	config, err := dir.UntypedJoin(generatorConfigFile).ReadFile()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	} else if err == nil {
+		if err := json.Unmarshal(config, generator); err != nil {
+			return nil, fmt.Errorf("%v: %w", dir.UntypedJoin(generatorConfigFile), err)
+		}
+	}
+	generator.Name = filepath.Base(dir.ToString())
+	generator.dir = dir
+	if generator.Type == "" {
+		generator.Type = workspaceTypePackage
+	}
+	if err := validateType(generator.Type); err != nil {
+		return nil, fmt.Errorf("generator %v: %w", generator.Name, err)
+	}
+	for task, definition := range generator.Pipeline {
+		if err := json.Unmarshal(definition, &turbofs.TaskDefinition{}); err != nil {
+			return nil, fmt.Errorf("generator %v: invalid pipeline entry for %v: %w", generator.Name, task, err)
+		}
+	}
+	return generator, nil
+}
+
+func validateType(workspaceType string) error {
+	if workspaceType != workspaceTypeApp && workspaceType != workspaceTypePackage {
+		return fmt.Errorf("invalid type %q, must be %q or %q", workspaceType, workspaceTypeApp, workspaceTypePackage)
+	}
+	return nil
+}
+
+func generateWorkspace(base *cmdutil.CmdBase, opts *opts, name string) error {
+	if !_packageNameRegex.MatchString(name) {
+		return errcode.Wrap(errcode.InvalidArguments, fmt.Errorf("%q is not a valid package name", name))
+	}
+	if opts.copy != "" && opts.generator != "" {
+		return errcode.Wrap(errcode.InvalidArguments, errors.New("--copy and --generator cannot be used together"))
+	}
+	rootPackageJSON, err := turbofs.ReadPackageJSON(base.RepoRoot.UntypedJoin("package.json"))
+	if err != nil {
+		return errcode.Wrap(errcode.InvalidPackageJSON, fmt.Errorf("failed to read package.json: %w", err))
+	}
+	ctx, err := context.BuildPackageGraph(base.RepoRoot, rootPackageJSON)
+	if err != nil {
+		var warnings *context.Warnings
+		if !errors.As(err, &warnings) {
+			return errcode.Wrap(errcode.InvalidPackageGraph, err)
+		}
+		base.Logger.Warn("issues occurred when constructing package graph", "warnings", err)
+	}
+	if _, ok := ctx.PackageInfos[name]; ok {
+		return errcode.Wrap(errcode.InvalidArguments, fmt.Errorf("a workspace named %v already exists", name))
+	}
+
+	var generator *Generator
+	var source *turbofs.PackageJSON
+	if opts.generator != "" {
+		dir := base.RepoRoot.UntypedJoin(GeneratorsDir, opts.generator)
+		if !dir.DirExists() {
+			return errcode.Wrap(errcode.InvalidArguments, fmt.Errorf("generator %v not found in %v", opts.generator, GeneratorsDir))
+		}
+		generator, err = readGenerator(dir)
+		if err != nil {
+			return errcode.Wrap(errcode.InvalidArguments, err)
+		}
+	} else if opts.copy != "" {
+		pkg, ok := ctx.PackageInfos[opts.copy]
+		if !ok || opts.copy == util.RootPkgName {
+			return errcode.Wrap(errcode.InvalidArguments, fmt.Errorf("workspace %v not found", opts.copy))
+		}
+		source = pkg
+	}
+
+	workspaceType := opts.workspaceType
+	if workspaceType == "" && generator != nil {
+		workspaceType = generator.Type
+	} else if workspaceType == "" {
+		workspaceType = workspaceTypePackage
+	}
+	if err := validateType(workspaceType); err != nil {
+		return errcode.Wrap(errcode.InvalidArguments, err)
+	}
+
+	globs, err := ctx.PackageManager.GetWorkspaceGlobs(base.RepoRoot)
+	if err != nil {
+		return errcode.Wrap(errcode.InvalidPackageJSON, err)
+	}
+	destination := opts.destination
+	if destination == "" {
+		destination = path.Join(defaultParentDir(globs, workspaceType), path.Base(name))
+	}
+	destination = filepath.ToSlash(filepath.Clean(destination))
+	if destination == "." || filepath.IsAbs(destination) || strings.HasPrefix(destination, "../") {
+		return errcode.Wrap(errcode.InvalidArguments, fmt.Errorf("--destination must be a directory inside the monorepo, got %v", opts.destination))
+	}
+	dest := base.RepoRoot.UntypedJoin(filepath.FromSlash(destination))
+	if entries, err := os.ReadDir(dest.ToString()); err == nil && len(entries) > 0 {
+		return errcode.Wrap(errcode.InvalidArguments, fmt.Errorf("%v already exists and is not empty", destination))
+	}
+
+	data := templateData{Name: name, Dir: destination, Type: workspaceType}
+	created := firstMissingDir(dest)
+	if err := createWorkspace(base.RepoRoot, generator, source, dest, data); err != nil {
+		if cleanupErr := removeCreated(dest, created); cleanupErr != nil {
+			base.Logger.Warn("failed to clean up", "dir", destination, "error", cleanupErr)
+		}
+		return err
+	}
+	base.UI.Output(util.Sprintf("${BOLD}Created %v${RESET} in %v", name, destination))
+
+	if !matchesAny(globs, destination) {
+		glob := path.Join(path.Dir(destination), "*")
+		if path.Dir(destination) == "." {
+			// Don't treat every top-level directory as a workspace
+			glob = destination
+		}
+		if err := addGlob(base.RepoRoot, ctx.PackageManager.WorkspaceConfigurationPath, glob); err != nil {
+			return fmt.Errorf("%v is not included in the workspaces, and adding %q failed: %w", destination, glob, err)
+		}
+		base.UI.Output(fmt.Sprintf(" - Added %q to workspaces", glob))
+	}
+
+	if generator != nil && len(generator.Pipeline) > 0 {
+		tasks, err := addPipelineTasks(base.RepoRoot, name, generator.Pipeline)
+		if err != nil {
+			return errcode.Wrap(errcode.InvalidTurboJSON, fmt.Errorf("failed to update turbo.json: %w", err))
+		}
+		for _, task := range tasks {
+			base.UI.Output(fmt.Sprintf(" - Added %v to the pipeline", task))
+		}
+	}
+
+	base.UI.Output(util.Sprintf("${GREY}Run %v install to link the new workspace${RESET}", ctx.PackageManager.Command))
+	return nil
+}
+
+// createWorkspace writes the files of a new workspace to dest, from a generator,
+// an existing workspace, or nothing at all, and sets its package name
+func createWorkspace(repoRoot turbopath.AbsoluteSystemPath, generator *Generator, source *turbofs.PackageJSON, dest turbopath.AbsoluteSystemPath, data templateData) error {
+	var err error
+	switch {
+	case generator != nil:
+		err = renderGenerator(generator, dest, data)
+	case source != nil:
+		err = copyWorkspace(source.Dir.RestoreAnchor(repoRoot), dest)
+	default:
+		err = dest.MkdirAll(0755)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create %v: %w", data.Dir, err)
+	}
+	if err := writePackageName(dest.UntypedJoin("package.json"), data.Name); err != nil {
+		return fmt.Errorf("failed to write package.json for %v: %w", data.Name, err)
+	}
+	return nil
+}
+
+// firstMissingDir returns the outermost directory that creating dir would create,
+// or "" if dir already exists
+func firstMissingDir(dir turbopath.AbsoluteSystemPath) turbopath.AbsoluteSystemPath {
+	var missing turbopath.AbsoluteSystemPath
+	for !dir.Exists() {
+		missing = dir
+		parent := dir.Dir()
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	return missing
+}
+
+// removeCreated undoes a failed createWorkspace. It removes the directories that
+// were created, or empties dest if it already existed.
+func removeCreated(dest turbopath.AbsoluteSystemPath, created turbopath.AbsoluteSystemPath) error {
+	if created != "" {
+		return created.RemoveAll()
+	}
+	entries, err := os.ReadDir(dest.ToString())
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := dest.UntypedJoin(entry.Name()).RemoveAll(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// defaultParentDir picks the directory that new workspaces of the given type go in.
+// It prefers a workspace glob such as "apps/*" or "packages/*" that matches the type.
+func defaultParentDir(globs []string, workspaceType string) string {
+	preferred := "packages"
+	if workspaceType == workspaceTypeApp {
+		preferred = "apps"
+	}
+	var fallback string
+	for _, glob := range globs {
+		dir := strings.TrimSuffix(strings.TrimPrefix(glob, "./"), "/*")
+		if dir == glob || strings.ContainsAny(dir, "*?[{") {
+			continue
+		}
+		if path.Base(dir) == preferred {
+			return dir
+		}
+		if fallback == "" {
+			fallback = dir
+		}
+	}
+	if fallback != "" && workspaceType == workspaceTypePackage {
+		return fallback
+	}
+	return preferred
+}
+
+// matchesAny returns true if dir is matched by one of the workspace globs
+func matchesAny(globs []string, dir string) bool {
+	for _, glob := range globs {
+		if matched, err := doublestar.Match(strings.TrimPrefix(glob, "./"), dir); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// addGlob adds a workspace glob to the package manager's workspace configuration
+func addGlob(repoRoot turbopath.AbsoluteSystemPath, workspaceConfigurationPath string, glob string) error {
+	configPath := repoRoot.UntypedJoin("package.json")
+	edit := addWorkspaceGlob
+	if workspaceConfigurationPath != "" {
+		configPath = repoRoot.UntypedJoin(workspaceConfigurationPath)
+		edit = addPnpmWorkspaceGlob
+	}
+	return editFile(configPath, func(content []byte) ([]byte, error) {
+		return edit(content, glob)
+	})
+}
+
+// addPipelineTasks adds <workspace>#<task> entries to turbo.json and returns their IDs
+func addPipelineTasks(repoRoot turbopath.AbsoluteSystemPath, workspace string, pipeline map[string]json.RawMessage) ([]string, error) {
+	tasks := make([]string, 0, len(pipeline))
+	for task := range pipeline {
+		tasks = append(tasks, task)
+	}
+	sort.Strings(tasks)
+	taskIDs := make([]string, len(tasks))
+	err := editFile(repoRoot.UntypedJoin("turbo.json"), func(content []byte) ([]byte, error) {
+		var err error
+		for i, task := range tasks {
+			taskIDs[i] = util.GetTaskId(workspace, task)
+			content, err = addPipelineTask(content, taskIDs[i], pipeline[task])
+			if err != nil {
+				return nil, err
+			}
+		}
+		return content, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return taskIDs, nil
+}
+
+// editFile applies edit to the contents of a file, keeping its permissions
+func editFile(file turbopath.AbsoluteSystemPath, edit func([]byte) ([]byte, error)) error {
+	info, err := file.Lstat()
+	if err != nil {
+		return err
+	}
+	content, err := file.ReadFile()
+	if err != nil {
+		return err
+	}
+	edited, err := edit(content)
+	if err != nil {
+		return fmt.Errorf("%v: %w", file.Base(), err)
+	}
+	return file.WriteFile(edited, info.Mode())
+}
+
+// writePackageName sets the name in a workspace's package.json, creating a minimal
+// package.json if there isn't one
+func writePackageName(packageJSONPath turbopath.AbsoluteSystemPath, name string) error {
+	if !packageJSONPath.FileExists() {
+		quoted, err := json.Marshal(name)
+		if err != nil {
+			return err
+		}
+		content := fmt.Sprintf("{\n  \"name\": %s,\n  \"version\": \"0.0.0\",\n  \"private\": true\n}\n", quoted)
+		return packageJSONPath.WriteFile([]byte(content), 0644)
+	}
+	return editFile(packageJSONPath, func(content []byte) ([]byte, error) {
+		return setPackageName(content, name)
+	})
+}
+
+// copyWorkspace copies the source files of a workspace, leaving out installed
+// dependencies and turbo's logs
+func copyWorkspace(from turbopath.AbsoluteSystemPath, to turbopath.AbsoluteSystemPath) error {
+	return filepath.WalkDir(from.ToString(), func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(from.ToString(), name)
+		if err != nil {
+			return err
+		}
+		dest := to.UntypedJoin(rel)
+		if entry.IsDir() {
+			if _skipCopyDirs.Includes(entry.Name()) {
+				return filepath.SkipDir
+			}
+			return dest.MkdirAll(0755)
+		}
+		return turbofs.CopyFile(&turbofs.LstatCachedFile{Path: turbopath.AbsoluteSystemPathFromUpstream(name)}, dest.ToString())
+	})
+}
+
+// renderGenerator writes the files of a generator to dest. Files ending in .tmpl
+// are rendered with text/template, and have the suffix removed.
+func renderGenerator(generator *Generator, dest turbopath.AbsoluteSystemPath, data templateData) error {
+	return filepath.WalkDir(generator.dir.ToString(), func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(generator.dir.ToString(), name)
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return dest.UntypedJoin(rel).MkdirAll(0755)
+		}
+		if rel == generatorConfigFile {
+			return nil
+		}
+		src := turbopath.AbsoluteSystemPathFromUpstream(name)
+		if !strings.HasSuffix(rel, templateSuffix) {
+			return turbofs.CopyFile(&turbofs.LstatCachedFile{Path: src}, dest.UntypedJoin(rel).ToString())
+		}
+		contents, err := src.ReadFile()
+		if err != nil {
+			return err
+		}
+		tmpl, err := template.New(rel).Option("missingkey=error").Parse(string(contents))
+		if err != nil {
+			return err
+		}
+		rendered := &bytes.Buffer{}
+		if err := tmpl.Execute(rendered, data); err != nil {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		return dest.UntypedJoin(strings.TrimSuffix(rel, templateSuffix)).WriteFile(rendered.Bytes(), info.Mode())
+	})
+}
@@ -0,0 +1,124 @@
+package gen
+
+import (
+	"os"
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"gotest.tools/v3/assert"
+)
+
+func Test_defaultParentDir(t *testing.T) {
+	testCases := []struct {
+		globs         []string
+		workspaceType string
+		want          string
+	}{
+		{[]string{"apps/*", "packages/*"}, workspaceTypeApp, "apps"},
+		{[]string{"apps/*", "packages/*"}, workspaceTypePackage, "packages"},
+		{[]string{"./libs/*"}, workspaceTypePackage, "libs"},
+		{[]string{"./libs/*"}, workspaceTypeApp, "apps"},
+		{[]string{"**/*"}, workspaceTypePackage, "packages"},
+	}
+	for _, tc := range testCases {
+		got := defaultParentDir(tc.globs, tc.workspaceType)
+		assert.Equal(t, got, tc.want, "globs %v, type %v", tc.globs, tc.workspaceType)
+	}
+}
+
+func Test_matchesAny(t *testing.T) {
+	globs := []string{"apps/*", "./packages/**"}
+	assert.Assert(t, matchesAny(globs, "apps/web"))
+	assert.Assert(t, matchesAny(globs, "packages/config/eslint"))
+	assert.Assert(t, !matchesAny(globs, "tools/cli"))
+	assert.Assert(t, !matchesAny(globs, "apps/web/nested"))
+}
+
+func Test_renderGenerator(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	generatorDir := repoRoot.UntypedJoin(GeneratorsDir, "lib")
+	files := map[string]string{
+		"generator.json":     `{"description": "A library", "pipeline": {"build": {"outputs": ["lib/**"]}}}`,
+		"package.json.tmpl":  `{"name": "{{ .Name }}"}`,
+		"src/index.ts.tmpl":  `export const dir = "{{ .Dir }}";`,
+		"src/{{ .Name }}.ts": `// copied as-is`,
+	}
+	for name, contents := range files {
+		file := generatorDir.UntypedJoin(name)
+		assert.NilError(t, file.EnsureDir())
+		assert.NilError(t, file.WriteFile([]byte(contents), 0644))
+	}
+
+	generators, err := ReadGenerators(repoRoot)
+	assert.NilError(t, err)
+	assert.Equal(t, len(generators), 1)
+	generator := generators[0]
+	assert.Equal(t, generator.Name, "lib")
+	assert.Equal(t, generator.Type, workspaceTypePackage)
+	assert.Equal(t, generator.Description, "A library")
+
+	dest := repoRoot.UntypedJoin("packages", "my-lib")
+	err = renderGenerator(generator, dest, templateData{Name: "my-lib", Dir: "packages/my-lib", Type: workspaceTypePackage})
+	assert.NilError(t, err)
+
+	for name, want := range map[string]string{
+		"package.json":       `{"name": "my-lib"}`,
+		"src/index.ts":       `export const dir = "packages/my-lib";`,
+		"src/{{ .Name }}.ts": `// copied as-is`,
+	} {
+		got, err := dest.UntypedJoin(name).ReadFile()
+		assert.NilError(t, err, name)
+		assert.Equal(t, string(got), want)
+	}
+	assert.Assert(t, !dest.UntypedJoin(generatorConfigFile).Exists())
+}
+
+func Test_readGeneratorInvalidPipeline(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	config := repoRoot.UntypedJoin(GeneratorsDir, "bad", generatorConfigFile)
+	assert.NilError(t, config.EnsureDir())
+	assert.NilError(t, config.WriteFile([]byte(`{"pipeline": {"build": {"maxOutputSizeAction": "explode"}}}`), 0644))
+
+	_, err := ReadGenerators(repoRoot)
+	assert.ErrorContains(t, err, "generator bad: invalid pipeline entry for build")
+}
+
+func Test_createWorkspaceRemovesPartialWorkspace(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	generatorDir := repoRoot.UntypedJoin(GeneratorsDir, "broken")
+	for name, contents := range map[string]string{
+		"a.txt":          "copied before the failure",
+		"z.txt.tmpl":     "{{ .Missing }}",
+		"package.json":   `{"name": "template"}`,
+		"src/index.ts":   "export {};",
+		"generator.json": `{}`,
+	} {
+		file := generatorDir.UntypedJoin(name)
+		assert.NilError(t, file.EnsureDir())
+		assert.NilError(t, file.WriteFile([]byte(contents), 0644))
+	}
+	generator, err := readGenerator(generatorDir)
+	assert.NilError(t, err)
+
+	// The destination and its parent are created, so both are removed
+	dest := repoRoot.UntypedJoin("packages", "my-lib")
+	created := firstMissingDir(dest)
+	assert.Equal(t, created, repoRoot.UntypedJoin("packages"))
+	err = createWorkspace(repoRoot, generator, nil, dest, templateData{Name: "my-lib", Dir: "packages/my-lib"})
+	assert.ErrorContains(t, err, "failed to create packages/my-lib")
+	assert.Assert(t, dest.UntypedJoin("a.txt").FileExists())
+	assert.NilError(t, removeCreated(dest, created))
+	assert.Assert(t, !created.Exists())
+
+	// An empty destination that already existed is kept, and emptied
+	assert.NilError(t, dest.MkdirAll(0755))
+	created = firstMissingDir(dest)
+	assert.Equal(t, created, turbopath.AbsoluteSystemPath(""))
+	err = createWorkspace(repoRoot, generator, nil, dest, templateData{Name: "my-lib", Dir: "packages/my-lib"})
+	assert.ErrorContains(t, err, "failed to create packages/my-lib")
+	assert.NilError(t, removeCreated(dest, created))
+	entries, err := os.ReadDir(dest.ToString())
+	assert.NilError(t, err)
+	assert.Equal(t, len(entries), 0)
+}
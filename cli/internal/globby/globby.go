@@ -40,6 +40,20 @@ func GlobFiles(basePath string, includePatterns []string, excludePatterns []stri
 	return output, err
 }
 
+// GlobFilesKeeping is GlobFiles, except that a file named keep directly inside an
+// excluded folder is still returned if it matches an include. Excluded folders are
+// not walked, so files named keep deeper within them are not found.
+func GlobFilesKeeping(basePath string, includePatterns []string, excludePatterns []string, keep string) ([]string, error) {
+	fsys := fs.CreateDirFSAtRoot(basePath)
+	fsysRoot := fs.GetDirFSRootPath(fsys)
+	output, err := globWalkFsKeeping(fsys, fsysRoot, basePath, includePatterns, excludePatterns, false, keep)
+
+	// Because this is coming out of a map output is in no way ordered.
+	// Sorting will put the files in a depth-first order.
+	sort.Strings(output)
+	return output, err
+}
+
 // checkRelativePath ensures that the the requested file path is a child of `from`.
 func checkRelativePath(from string, to string) error {
 	relativePath, err := filepath.Rel(from, to)
@@ -67,6 +81,12 @@ func globAllFs(fsys iofs.FS, fsysRoot string, basePath string, includePatterns [
 
 // globWalkFs searches the specified file system to enumerate all files and folders to include.
 func globWalkFs(fsys iofs.FS, fsysRoot string, basePath string, includePatterns []string, excludePatterns []string, includeDirs bool) ([]string, error) {
+	return globWalkFsKeeping(fsys, fsysRoot, basePath, includePatterns, excludePatterns, includeDirs, "")
+}
+
+// globWalkFsKeeping is globWalkFs, also returning the files named keep directly inside
+// excluded folders that match an include. An empty keep keeps nothing.
+func globWalkFsKeeping(fsys iofs.FS, fsysRoot string, basePath string, includePatterns []string, excludePatterns []string, includeDirs bool, keep string) ([]string, error) {
 	var processedIncludes []string
 	var processedExcludes []string
 	result := make(util.Set)
@@ -105,18 +125,6 @@ func globWalkFs(fsys iofs.FS, fsysRoot string, basePath string, includePatterns
 		processedExcludes = append(processedExcludes, filepath.Join(iofsRelativePath, "**"))
 	}
 
-	// We start from a naive includePattern
-	includePattern := ""
-	includeCount := len(processedIncludes)
-
-	// Do not use alternation if unnecessary.
-	if includeCount == 1 {
-		includePattern = processedIncludes[0]
-	} else if includeCount > 1 {
-		// We use alternation from the very root of the path. This avoids fs.Stat of the basePath.
-		includePattern = "{" + strings.Join(processedIncludes, ",") + "}"
-	}
-
 	// We start with an empty string excludePattern which we only use if excludeCount > 0.
 	excludePattern := ""
 	excludeCount := len(processedExcludes)
@@ -130,10 +138,49 @@ func globWalkFs(fsys iofs.FS, fsysRoot string, basePath string, includePatterns
 	}
 
 	// GlobWalk expects that everything uses Unix path conventions.
-	includePattern = filepath.ToSlash(includePattern)
 	excludePattern = filepath.ToSlash(excludePattern)
 
-	err := doublestar.GlobWalk(fsys, includePattern, func(path string, dirEntry iofs.DirEntry) error {
+	// keepFile adds a file within an excluded folder if it exists and is included
+	keepFile := func(path string) error {
+		info, err := iofs.Stat(fsys, path)
+		if err != nil || info.IsDir() {
+			return nil
+		}
+		for _, includePattern := range processedIncludes {
+			isIncluded, err := doublestar.Match(filepath.ToSlash(includePattern), path)
+			if err != nil {
+				return err
+			}
+			if isIncluded {
+				result.Add(fsysRoot + path)
+				return nil
+			}
+		}
+		return nil
+	}
+
+	walkFunc := func(path string, dirEntry iofs.DirEntry) error {
+		// Excludes and includes are evaluated together: an excluded folder is
+		// skipped as soon as it's reached, rather than walked and then filtered.
+		// Every exclude ends in `**`, so everything within it is excluded too.
+		if excludeCount > 0 {
+			isExcluded, err := doublestar.Match(excludePattern, filepath.ToSlash(path))
+			if err != nil {
+				return err
+			}
+			if isExcluded {
+				if dirEntry.IsDir() {
+					if keep != "" {
+						if err := keepFile(path + "/" + keep); err != nil {
+							return err
+						}
+					}
+					return iofs.SkipDir
+				}
+				return nil
+			}
+		}
+
 		if !includeDirs && dirEntry.IsDir() {
 			return nil
 		}
@@ -149,28 +196,19 @@ func globWalkFs(fsys iofs.FS, fsysRoot string, basePath string, includePatterns
 		// As a consequence, when processing, we need to *restore* the original
 		// root to the file path after returning. This works because when we create
 		// the `os.dirFS` filesystem we do so at the root of the current volume.
-		if excludeCount == 0 {
-			// Reconstruct via string concatenation since the root is already pre-composed.
-			result.Add(fsysRoot + path)
-			return nil
-		}
-
-		isExcluded, err := doublestar.Match(excludePattern, filepath.ToSlash(path))
-		if err != nil {
-			return err
-		}
-
-		if !isExcluded {
-			// Reconstruct via string concatenation since the root is already pre-composed.
-			result.Add(fsysRoot + path)
-		}
-
+		//
+		// Reconstruct via string concatenation since the root is already pre-composed.
+		result.Add(fsysRoot + path)
 		return nil
-	})
+	}
 
-	// GlobWalk threw an error.
-	if err != nil {
-		return nil, err
+	// Each include is walked on its own, rather than as one alternation, so that
+	// the walk can skip the folders that are excluded.
+	for _, includePattern := range processedIncludes {
+		if err := doublestar.GlobWalk(fsys, filepath.ToSlash(includePattern), walkFunc); err != nil {
+			// GlobWalk threw an error.
+			return nil, err
+		}
 	}
 
 	// Never actually capture the root folder.
@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"reflect"
 	"sort"
+	"strings"
 	"testing"
 
 	"testing/fstest"
@@ -420,6 +421,35 @@ func TestGlobFilesFs(t *testing.T) {
 				"/repos/some-app/dist/index.html",
 			},
 		},
+		{
+			name: "excluding files by pattern keeps the rest of their folders",
+			files: []string{
+				"/repos/some-app/dist/index.js",
+				"/repos/some-app/dist/index.js.map",
+				"/repos/some-app/dist/js/lib.js",
+				"/repos/some-app/dist/js/lib.js.map",
+				"/repos/some-app/.next/server.js",
+				"/repos/some-app/.next/cache/webpack.pack",
+			},
+			args: args{
+				basePath:        "/repos/some-app/",
+				includePatterns: []string{"dist/**", ".next/**"},
+				excludePatterns: []string{"dist/**/*.map", ".next/cache"},
+			},
+			wantAll: []string{
+				"/repos/some-app/.next",
+				"/repos/some-app/.next/server.js",
+				"/repos/some-app/dist",
+				"/repos/some-app/dist/index.js",
+				"/repos/some-app/dist/js",
+				"/repos/some-app/dist/js/lib.js",
+			},
+			wantFiles: []string{
+				"/repos/some-app/.next/server.js",
+				"/repos/some-app/dist/index.js",
+				"/repos/some-app/dist/js/lib.js",
+			},
+		},
 		{
 			name: "exclude everything with folder . applies at base path",
 			files: []string{
@@ -706,3 +736,61 @@ func TestGlobFilesFs(t *testing.T) {
 		})
 	}
 }
+
+// readDirRecorder records the directories that are read from a file system
+type readDirRecorder struct {
+	fstest.MapFS
+	read []string
+}
+
+func (r *readDirRecorder) ReadDir(name string) ([]fs.DirEntry, error) {
+	r.read = append(r.read, name)
+	return r.MapFS.ReadDir(name)
+}
+
+func TestGlobAllFsSkipsExcludedFolders(t *testing.T) {
+	fsys := &readDirRecorder{MapFS: setup("/", []string{
+		"/repo/.next/server.js",
+		"/repo/.next/cache/webpack/client.pack",
+		"/repo/.turbo/turbo-build.log",
+	}).(fstest.MapFS)}
+
+	got, err := globAllFs(fsys, "/", "/repo", []string{".next/**", ".turbo/turbo-build.log"}, []string{".next/cache"})
+	if err != nil {
+		t.Fatalf("globAllFs() error = %v", err)
+	}
+	sort.Strings(got)
+	want := []string{"/repo/.next", "/repo/.next/server.js", "/repo/.turbo/turbo-build.log"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("globAllFs() = %v, want %v", got, want)
+	}
+	for _, dir := range fsys.read {
+		if strings.HasPrefix(dir, "repo/.next/cache") {
+			t.Errorf("the excluded folder %v was read", dir)
+		}
+	}
+}
+
+func TestGlobWalkFsKeepingKeepsFilesAtTopOfExcludedFolders(t *testing.T) {
+	fsys := &readDirRecorder{MapFS: setup("/", []string{
+		"/repo/src/index.js",
+		"/repo/vendor/.turbo-hash",
+		"/repo/vendor/big.js",
+		"/repo/vendor/nested/.turbo-hash",
+	}).(fstest.MapFS)}
+
+	got, err := globWalkFsKeeping(fsys, "/", "/repo", []string{"**"}, []string{"vendor"}, false, ".turbo-hash")
+	if err != nil {
+		t.Fatalf("globWalkFsKeeping() error = %v", err)
+	}
+	sort.Strings(got)
+	want := []string{"/repo/src/index.js", "/repo/vendor/.turbo-hash"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("globWalkFsKeeping() = %v, want %v", got, want)
+	}
+	for _, dir := range fsys.read {
+		if strings.HasPrefix(dir, "repo/vendor") {
+			t.Errorf("the excluded folder %v was read", dir)
+		}
+	}
+}
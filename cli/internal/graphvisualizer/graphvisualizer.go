@@ -1,24 +1,37 @@
 package graphvisualizer
 
 import (
+	_ "embed"
+	"encoding/json"
 	"fmt"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/fatih/color"
 	"github.com/mitchellh/cli"
 	"github.com/pyr-sh/dag"
+	"github.com/vercel/turbo/cli/internal/core"
 	"github.com/vercel/turbo/cli/internal/turbopath"
 	"github.com/vercel/turbo/cli/internal/ui"
+	"github.com/vercel/turbo/cli/internal/util"
 	"github.com/vercel/turbo/cli/internal/util/browser"
 )
 
+// viewerHTML is the page for .html graph files, into which the graph is inserted
+//
+//go:embed viewer.html
+var viewerHTML string
+
 // GraphVisualizer requirements
 type GraphVisualizer struct {
 	repoRoot  turbopath.AbsoluteSystemPath
 	ui        cli.Ui
 	TaskGraph *dag.AcyclicGraph
+	// PackageDirs are the directories of packages, relative to the repository root,
+	// which the Bazel and Buck2 exports label tasks by
+	PackageDirs map[string]turbopath.AnchoredUnixPath
 }
 
 // hasGraphViz checks for the presence of https://graphviz.org/
@@ -44,6 +57,141 @@ func (g *GraphVisualizer) generateDotString() string {
 	}))
 }
 
+// taskGraph returns the tasks of the TaskGraph and the dependencies between them,
+// sorted, leaving out the root node that tasks without dependencies depend on, and
+// the tasks of the root node
+func (g *GraphVisualizer) taskGraph() ([]string, [][2]string) {
+	var tasks []string
+	for _, v := range g.TaskGraph.Vertices() {
+		if name := dag.VertexName(v); !strings.Contains(name, core.ROOT_NODE_NAME) {
+			tasks = append(tasks, name)
+		}
+	}
+	sort.Strings(tasks)
+	var deps [][2]string
+	for _, edge := range g.TaskGraph.Edges() {
+		from, to := dag.VertexName(edge.Source()), dag.VertexName(edge.Target())
+		if !strings.Contains(from, core.ROOT_NODE_NAME) && !strings.Contains(to, core.ROOT_NODE_NAME) {
+			deps = append(deps, [2]string{from, to})
+		}
+	}
+	sort.Slice(deps, func(i, j int) bool {
+		if deps[i][0] != deps[j][0] {
+			return deps[i][0] < deps[j][0]
+		}
+		return deps[i][1] < deps[j][1]
+	})
+	return tasks, deps
+}
+
+// Converts the TaskGraph dag into a Mermaid flowchart, which renders in markdown
+// on GitHub and GitLab without Graphviz
+func (g *GraphVisualizer) generateMermaidString() string {
+	tasks, deps := g.taskGraph()
+	ids := make(map[string]string, len(tasks))
+	var b strings.Builder
+	b.WriteString("graph TD\n")
+	for i, task := range tasks {
+		ids[task] = fmt.Sprintf("task%v", i)
+		// Mermaid reads # as the start of an entity code, and " as the end of the label
+		label := strings.NewReplacer("#", "#35;", "\"", "#quot;").Replace(task)
+		fmt.Fprintf(&b, "\t%v[\"%v\"]\n", ids[task], label)
+	}
+	for _, dep := range deps {
+		fmt.Fprintf(&b, "\t%v --> %v\n", ids[dep[0]], ids[dep[1]])
+	}
+	return b.String()
+}
+
+// Converts the TaskGraph dag into a page that lays out and explores the graph
+// without Graphviz, or anything else, being available
+func (g *GraphVisualizer) generateHTMLString() (string, error) {
+	tasks, deps := g.taskGraph()
+	data := struct {
+		Nodes []string            `json:"nodes"`
+		Edges []map[string]string `json:"edges"`
+	}{Nodes: tasks, Edges: []map[string]string{}}
+	if data.Nodes == nil {
+		data.Nodes = []string{}
+	}
+	for _, dep := range deps {
+		data.Edges = append(data.Edges, map[string]string{"from": dep[0], "to": dep[1]})
+	}
+	// json.Marshal escapes <, > and &, so the data can't close its script element
+	bytes, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+	return strings.Replace(viewerHTML, "__GRAPH_DATA__", string(bytes), 1), nil
+}
+
+// buildSystemLabel returns the label of taskID in Bazel and Buck2, with the
+// directory of its package as the package, and its task as the target, such as
+// //packages/ui:build. Target names can't contain colons, which become underscores.
+func (g *GraphVisualizer) buildSystemLabel(cell string, taskID string) (string, string, string) {
+	pkg, task := util.GetPackageTaskFromId(taskID)
+	dir := g.PackageDirs[pkg].ToString()
+	if dir == "." {
+		dir = ""
+	}
+	name := strings.ReplaceAll(task, ":", "_")
+	return cell + "//" + dir, name, cell + "//" + dir + ":" + name
+}
+
+// Converts the TaskGraph dag into the output of
+// `bazel query 'deps(//...)' --output=graph --nograph:factored`, so that it can be
+// diffed against the dependencies that Bazel has for the same targets
+func (g *GraphVisualizer) generateBazelQueryString() string {
+	tasks, deps := g.taskGraph()
+	depsOf := make(map[string][]string, len(tasks))
+	for _, dep := range deps {
+		depsOf[dep[0]] = append(depsOf[dep[0]], dep[1])
+	}
+	var b strings.Builder
+	b.WriteString("digraph mygraph {\n  node [shape=box];\n")
+	for _, task := range tasks {
+		_, _, label := g.buildSystemLabel("", task)
+		fmt.Fprintf(&b, "  %q\n", label)
+		for _, dep := range depsOf[task] {
+			_, _, depLabel := g.buildSystemLabel("", dep)
+			fmt.Fprintf(&b, "  %q -> %q\n", label, depLabel)
+		}
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// buck2Target is a target in the output of `buck2 targets --json`
+type buck2Target struct {
+	Type    string   `json:"buck.type"`
+	Package string   `json:"buck.package"`
+	Name    string   `json:"name"`
+	Deps    []string `json:"buck.deps"`
+	TaskID  string   `json:"turbo.task_id"`
+}
+
+// Converts the TaskGraph dag into the output of `buck2 targets --json`, in the
+// root cell, so that it can be diffed against the dependencies that Buck2 has
+// for the same targets
+func (g *GraphVisualizer) generateBuck2JSON() ([]byte, error) {
+	tasks, deps := g.taskGraph()
+	depsOf := make(map[string][]string, len(tasks))
+	for _, dep := range deps {
+		_, _, depLabel := g.buildSystemLabel("root", dep[1])
+		depsOf[dep[0]] = append(depsOf[dep[0]], depLabel)
+	}
+	targets := make([]buck2Target, 0, len(tasks))
+	for _, task := range tasks {
+		pkg, name, _ := g.buildSystemLabel("root", task)
+		taskDeps := depsOf[task]
+		if taskDeps == nil {
+			taskDeps = []string{}
+		}
+		targets = append(targets, buck2Target{Type: "turbo_task", Package: pkg, Name: name, Deps: taskDeps, TaskID: task})
+	}
+	return json.MarshalIndent(targets, "", "  ")
+}
+
 // Outputs a warning when a file was requested, but graphviz is not available
 func (g *GraphVisualizer) graphVizWarnUI() {
 	g.ui.Warn(color.New(color.FgYellow, color.Bold, color.ReverseVideo).Sprint(" WARNING ") + color.YellowString(" `turbo` uses Graphviz to generate an image of your\ngraph, but Graphviz isn't installed on this machine.\n\nYou can download Graphviz from https://graphviz.org/download.\n\nIn the meantime, you can use this string output with an\nonline Dot graph viewer."))
@@ -65,39 +213,36 @@ func (g *GraphVisualizer) GenerateGraphFile(outputName string) error {
 		ext = ".jpg"
 		outputFilename = g.repoRoot.UntypedJoin(outputName + ext)
 	}
-	if ext == ".html" {
-		f, err := outputFilename.Create()
+	if strings.HasSuffix(outputFilename.ToString(), ".buck.json") {
+		ext = ".buck.json"
+	}
+	switch ext {
+	case ".bazel":
+		if err := outputFilename.WriteFile([]byte(g.generateBazelQueryString()), 0644); err != nil {
+			return fmt.Errorf("error writing graph contents: %w", err)
+		}
+		g.ui.Output("")
+		g.ui.Output(fmt.Sprintf("✔ Generated task graph in %s", ui.Bold(outputFilename.ToString())))
+		return nil
+	case ".buck.json":
+		targets, err := g.generateBuck2JSON()
 		if err != nil {
-			return fmt.Errorf("error creating file: %w", err)
+			return fmt.Errorf("error generating graph contents: %w", err)
 		}
-		defer f.Close() //nolint errcheck
-		_, writeErr1 := f.WriteString(`<!DOCTYPE html>
-    <html>
-    <head>
-      <meta charset="utf-8">
-      <title>Graph</title>
-    </head>
-    <body>
-      <script src="https://cdn.jsdelivr.net/npm/viz.js@2.1.2-pre.1/viz.js"></script>
-      <script src="https://cdn.jsdelivr.net/npm/viz.js@2.1.2-pre.1/full.render.js"></script>
-      <script>`)
-		if writeErr1 != nil {
-			return fmt.Errorf("error writing graph contents: %w", writeErr1)
+		if err := outputFilename.WriteFile(append(targets, '\n'), 0644); err != nil {
+			return fmt.Errorf("error writing graph contents: %w", err)
 		}
-
-		_, writeErr2 := f.WriteString("const s = `" + graphString + "`.replace(/\\_\\_\\_ROOT\\_\\_\\_/g, \"Root\").replace(/\\[root\\]/g, \"\");new Viz().renderSVGElement(s).then(el => document.body.appendChild(el)).catch(e => console.error(e));")
-		if writeErr2 != nil {
-			return fmt.Errorf("error creating file: %w", writeErr2)
+		g.ui.Output("")
+		g.ui.Output(fmt.Sprintf("✔ Generated task graph in %s", ui.Bold(outputFilename.ToString())))
+		return nil
+	case ".html":
+		html, err := g.generateHTMLString()
+		if err != nil {
+			return fmt.Errorf("error generating graph contents: %w", err)
 		}
-
-		_, writeErr3 := f.WriteString(`
-    </script>
-  </body>
-  </html>`)
-		if writeErr3 != nil {
-			return fmt.Errorf("error creating file: %w", writeErr3)
+		if err := outputFilename.WriteFile([]byte(html), 0644); err != nil {
+			return fmt.Errorf("error writing graph contents: %w", err)
 		}
-
 		g.ui.Output("")
 		g.ui.Output(fmt.Sprintf("✔ Generated task graph in %s", ui.Bold(outputFilename.ToString())))
 		if ui.IsTTY {
@@ -106,6 +251,13 @@ func (g *GraphVisualizer) GenerateGraphFile(outputName string) error {
 			}
 		}
 		return nil
+	case ".mermaid", ".mmd":
+		if err := outputFilename.WriteFile([]byte(g.generateMermaidString()), 0644); err != nil {
+			return fmt.Errorf("error writing graph contents: %w", err)
+		}
+		g.ui.Output("")
+		g.ui.Output(fmt.Sprintf("✔ Generated task graph in %s", ui.Bold(outputFilename.ToString())))
+		return nil
 	}
 	hasDot := hasGraphViz()
 	if hasDot {
@@ -0,0 +1,98 @@
+package graphvisualizer
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/pyr-sh/dag"
+	"github.com/vercel/turbo/cli/internal/core"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"gotest.tools/v3/assert"
+)
+
+func testGraph() *GraphVisualizer {
+	graph := &dag.AcyclicGraph{}
+	for _, taskID := range []string{core.ROOT_NODE_NAME, core.ROOT_NODE_NAME + "#build", "web#build", "ui#build", `ui#"quoted"`} {
+		graph.Add(taskID)
+	}
+	graph.Connect(dag.BasicEdge("ui#build", core.ROOT_NODE_NAME+"#build"))
+	graph.Connect(dag.BasicEdge("web#build", "ui#build"))
+	graph.Connect(dag.BasicEdge("ui#build", core.ROOT_NODE_NAME))
+	graph.Connect(dag.BasicEdge(`ui#"quoted"`, core.ROOT_NODE_NAME))
+	return New("", nil, graph)
+}
+
+func TestGenerateMermaidString(t *testing.T) {
+	assert.Equal(t, testGraph().generateMermaidString(), `graph TD
+	task0["ui#35;#quot;quoted#quot;"]
+	task1["ui#35;build"]
+	task2["web#35;build"]
+	task2 --> task1
+`)
+}
+
+func TestGenerateHTMLString(t *testing.T) {
+	html, err := testGraph().generateHTMLString()
+	assert.NilError(t, err)
+	assert.Assert(t, !strings.Contains(html, "https://"), "the page should be self-contained")
+	start := strings.Index(html, `type="application/json">`) + len(`type="application/json">`)
+	end := strings.Index(html[start:], "</script>")
+	var data struct {
+		Nodes []string            `json:"nodes"`
+		Edges []map[string]string `json:"edges"`
+	}
+	assert.NilError(t, json.Unmarshal([]byte(html[start:start+end]), &data))
+	assert.DeepEqual(t, data.Nodes, []string{`ui#"quoted"`, "ui#build", "web#build"})
+	assert.DeepEqual(t, data.Edges, []map[string]string{{"from": "web#build", "to": "ui#build"}})
+}
+
+func buildSystemTestGraph() *GraphVisualizer {
+	graph := &dag.AcyclicGraph{}
+	for _, taskID := range []string{core.ROOT_NODE_NAME, "//#lint", "web#build", "ui#build", "ui#test:ci"} {
+		graph.Add(taskID)
+	}
+	graph.Connect(dag.BasicEdge("web#build", "ui#build"))
+	graph.Connect(dag.BasicEdge("web#build", "//#lint"))
+	graph.Connect(dag.BasicEdge("ui#test:ci", "ui#build"))
+	graph.Connect(dag.BasicEdge("ui#build", core.ROOT_NODE_NAME))
+	g := New("", nil, graph)
+	g.PackageDirs = map[string]turbopath.AnchoredUnixPath{"//": ".", "web": "apps/web", "ui": "packages/ui"}
+	return g
+}
+
+func TestGenerateBazelQueryString(t *testing.T) {
+	assert.Equal(t, buildSystemTestGraph().generateBazelQueryString(), `digraph mygraph {
+  node [shape=box];
+  "//:lint"
+  "//packages/ui:build"
+  "//packages/ui:test_ci"
+  "//packages/ui:test_ci" -> "//packages/ui:build"
+  "//apps/web:build"
+  "//apps/web:build" -> "//:lint"
+  "//apps/web:build" -> "//packages/ui:build"
+}
+`)
+}
+
+func TestGenerateBuck2JSON(t *testing.T) {
+	bytes, err := buildSystemTestGraph().generateBuck2JSON()
+	assert.NilError(t, err)
+	var targets []map[string]interface{}
+	assert.NilError(t, json.Unmarshal(bytes, &targets))
+	assert.Equal(t, len(targets), 4)
+	assert.DeepEqual(t, targets[0], map[string]interface{}{
+		"buck.type":     "turbo_task",
+		"buck.package":  "root//",
+		"name":          "lint",
+		"buck.deps":     []interface{}{},
+		"turbo.task_id": "//#lint",
+	})
+	assert.DeepEqual(t, targets[3], map[string]interface{}{
+		"buck.type":     "turbo_task",
+		"buck.package":  "root//apps/web",
+		"name":          "build",
+		"buck.deps":     []interface{}{"root//:lint", "root//packages/ui:build"},
+		"turbo.task_id": "web#build",
+	})
+}
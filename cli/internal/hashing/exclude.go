@@ -0,0 +1,43 @@
+package hashing
+
+import (
+	"path"
+
+	"github.com/vercel/turbo/cli/internal/doublestar"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+// HashStampFile is the name of a file that is hashed even where files are excluded
+// from hashing. Changing the stamp in an excluded directory, such as whenever its
+// contents are updated, changes the hash of the tasks that it belongs to.
+const HashStampFile = ".turbo-hash"
+
+// IsHashExcluded returns whether the file at repoPath, relative to the repository
+// root, matches one of the hashExclude patterns, and so isn't hashed. A pattern
+// without wildcards, such as "vendor", excludes everything under that directory.
+// Stamp files are never excluded, though for tasks with inputs only the ones at the
+// top of an excluded directory are found, since excluded directories aren't walked.
+func IsHashExcluded(patterns []string, repoPath turbopath.AnchoredUnixPath) bool {
+	name := repoPath.ToString()
+	if len(patterns) == 0 || path.Base(name) == HashStampFile {
+		return false
+	}
+	for _, pattern := range patterns {
+		for _, candidate := range []string{pattern, path.Join(pattern, "**")} {
+			// Patterns are validated when turbo.json is read
+			if matched, _ := doublestar.Match(candidate, name); matched {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// excludedFilter returns a function reporting whether a file, relative to the
+// package at pkgPath, is excluded from hashing by patterns
+func excludedFilter(patterns []string, pkgPath turbopath.AnchoredSystemPath) func(turbopath.AnchoredUnixPath) bool {
+	pkgDir := pkgPath.ToUnixPath().ToString()
+	return func(filePath turbopath.AnchoredUnixPath) bool {
+		return IsHashExcluded(patterns, turbopath.AnchoredUnixPathFromUpstream(path.Join(pkgDir, filePath.ToString())))
+	}
+}
@@ -24,11 +24,19 @@ type PackageDepsOptions struct {
 	PackagePath turbopath.AnchoredSystemPath
 
 	InputPatterns []string
+
+	// ExcludePatterns are globs, relative to the repository root, of files that
+	// aren't hashed. See IsHashExcluded.
+	ExcludePatterns []string
 }
 
 // GetPackageDeps Builds an object containing git hashes for the files under the specified `packagePath` folder.
 func GetPackageDeps(rootPath turbopath.AbsoluteSystemPath, p *PackageDepsOptions) (map[turbopath.AnchoredUnixPath]string, error) {
 	pkgPath := rootPath.UntypedJoin(p.PackagePath.ToStringDuringMigration())
+	excluded := excludedFilter(p.ExcludePatterns, p.PackagePath)
+	// Only a sparse checkout leaves files out of the working tree, so only then is
+	// it worth listing them for each package
+	sparse := memoizedIsSparseCheckout(rootPath)
 	// Add all the checked in hashes.
 	var result map[turbopath.AnchoredUnixPath]string
 
@@ -42,6 +50,37 @@ func GetPackageDeps(rootPath turbopath.AbsoluteSystemPath, p *PackageDepsOptions
 			return nil, fmt.Errorf("could not get git hashes for files in package %s: %w", p.PackagePath, err)
 		}
 		result = gitLsTreeOutput
+		if len(p.ExcludePatterns) > 0 {
+			for filePath := range result {
+				if excluded(filePath) {
+					delete(result, filePath)
+				}
+			}
+		}
+
+		// In a sparse checkout `ls-tree` still reports files that were never written
+		// to disk, and `git status` doesn't report on them at all. Hash the ones that
+		// are present and drop the rest, rather than hashing what is in the index.
+		if sparse {
+			skipped, err := GetSkipWorktreeFiles(pkgPath)
+			if err != nil {
+				return nil, fmt.Errorf("could not get sparse checkout entries for package %s: %w", p.PackagePath, err)
+			}
+			var materialized []turbopath.AnchoredSystemPath
+			for _, filePath := range skipped {
+				delete(result, filePath)
+				if !excluded(filePath) && filePath.ToSystemPath().RestoreAnchor(pkgPath).FileExists() {
+					materialized = append(materialized, filePath.ToSystemPath())
+				}
+			}
+			hashes, err := gitHashObject(pkgPath, materialized)
+			if err != nil {
+				return nil, err
+			}
+			for filePath, hash := range hashes {
+				result[filePath] = hash
+			}
+		}
 	} else {
 
 		// Add in package.json to input patterns because if the `scripts` in
@@ -62,28 +101,58 @@ func GetPackageDeps(rootPath turbopath.AbsoluteSystemPath, p *PackageDepsOptions
 			prefixedInputPatterns[index] = rerooted
 		}
 
-		absoluteFilesToHash, err := globby.GlobFiles(rootPath.ToStringDuringMigration(), prefixedInputPatterns, nil)
+		// Excluded folders are skipped while walking, rather than walked and filtered,
+		// keeping the stamp file at the top of each
+		absoluteFilesToHash, err := globby.GlobFilesKeeping(rootPath.ToStringDuringMigration(), prefixedInputPatterns, p.ExcludePatterns, HashStampFile)
 
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to resolve input globs %v", calculatedInputs)
 		}
 
-		filesToHash := make([]turbopath.AnchoredSystemPath, len(absoluteFilesToHash))
-		for i, rawPath := range absoluteFilesToHash {
+		filesToHash := make([]turbopath.AnchoredSystemPath, 0, len(absoluteFilesToHash))
+		for _, rawPath := range absoluteFilesToHash {
 			relativePathString, err := pkgPath.RelativePathString(rawPath)
 
 			if err != nil {
 				return nil, errors.Wrapf(err, "not relative to package: %v", rawPath)
 			}
 
-			filesToHash[i] = turbopath.AnchoredSystemPathFromUpstream(relativePathString)
+			filePath := turbopath.AnchoredSystemPathFromUpstream(relativePathString)
+			if !excluded(filePath.ToUnixPath()) {
+				filesToHash = append(filesToHash, filePath)
+			}
 		}
 
-		hashes, err := gitHashObject(turbopath.AbsoluteSystemPathFromUpstream(pkgPath.ToStringDuringMigration()), filesToHash)
+		gitStatusOutput, err := gitStatus(pkgPath, calculatedInputs)
+		if err != nil {
+			return nil, fmt.Errorf("Could not get git hashes from git status: %v", err)
+		}
+
+		// Files that git reports as unchanged already have an object ID, and the ones
+		// it reports as changed are hashed with the status below, so only the rest need
+		// to be read here. Without object IDs, such as before the first commit, hash them all.
+		clean, err := cleanObjectIDs(pkgPath, gitStatusOutput, sparse)
+		if err != nil {
+			clean = nil
+		}
+		result = make(map[turbopath.AnchoredUnixPath]string, len(filesToHash))
+		var changedFiles []turbopath.AnchoredSystemPath
+		for _, filePath := range filesToHash {
+			if objectID, ok := clean[filePath.ToUnixPath()]; ok {
+				result[filePath.ToUnixPath()] = objectID
+			} else if _, ok := gitStatusOutput[filePath.ToUnixPath()]; !ok {
+				changedFiles = append(changedFiles, filePath)
+			}
+		}
+
+		hashes, err := gitHashObject(turbopath.AbsoluteSystemPathFromUpstream(pkgPath.ToStringDuringMigration()), changedFiles)
 		if err != nil {
 			return nil, errors.Wrap(err, "failed hashing resolved inputs globs")
 		}
-		result = hashes
+		for filePath, hash := range hashes {
+			result[filePath] = hash
+		}
+		return withStatus(pkgPath, result, gitStatusOutput, excluded)
 	}
 
 	// Update the checked in hashes with the current repo status
@@ -92,12 +161,17 @@ func GetPackageDeps(rootPath turbopath.AbsoluteSystemPath, p *PackageDepsOptions
 	if err != nil {
 		return nil, fmt.Errorf("Could not get git hashes from git status: %v", err)
 	}
+	return withStatus(pkgPath, result, gitStatusOutput, excluded)
+}
 
+// withStatus updates result, the hashes of the files under pkgPath, with the files
+// that git status reports as changed, deleted, or untracked
+func withStatus(pkgPath turbopath.AbsoluteSystemPath, result map[turbopath.AnchoredUnixPath]string, gitStatusOutput map[turbopath.AnchoredUnixPath]statusCode, excluded func(turbopath.AnchoredUnixPath) bool) (map[turbopath.AnchoredUnixPath]string, error) {
 	var filesToHash []turbopath.AnchoredSystemPath
 	for filePath, status := range gitStatusOutput {
 		if status.isDelete() {
 			delete(result, filePath)
-		} else {
+		} else if !excluded(filePath) {
 			filesToHash = append(filesToHash, filePath.ToSystemPath())
 		}
 	}
@@ -324,6 +398,104 @@ func gitLsTree(rootPath turbopath.AbsoluteSystemPath) (map[turbopath.AnchoredUni
 	return output, nil
 }
 
+// cleanObjectIDs returns the object IDs of the regular files under rootPath that are
+// committed and unchanged in the index and the working tree, which are the hashes that
+// `git hash-object` would compute for them. Symlinks are left out, since their object
+// is the link rather than the file it points to. status is the output of gitStatus for
+// rootPath, and sparse is whether the repository is a sparse checkout.
+func cleanObjectIDs(rootPath turbopath.AbsoluteSystemPath, status map[turbopath.AnchoredUnixPath]statusCode, sparse bool) (map[turbopath.AnchoredUnixPath]string, error) {
+	cmd := exec.Command("git", "ls-tree", "-r", "-z", "HEAD")
+	cmd.Dir = rootPath.ToString()
+	entries, err := runGitCommand(cmd, "ls-tree", gitoutput.NewLSTreeReader)
+	if err != nil {
+		return nil, err
+	}
+	objectIDs := make(map[turbopath.AnchoredUnixPath]string, len(entries))
+	for _, entry := range entries {
+		lsTreeEntry := gitoutput.LsTreeEntry(entry)
+		if mode := lsTreeEntry.GetField(gitoutput.ObjectMode); mode == "100644" || mode == "100755" {
+			objectIDs[turbopath.AnchoredUnixPathFromUpstream(lsTreeEntry.GetField(gitoutput.Path))] = lsTreeEntry.GetField(gitoutput.ObjectName)
+		}
+	}
+	for filePath := range status {
+		delete(objectIDs, filePath)
+	}
+	if !sparse {
+		return objectIDs, nil
+	}
+	// Files outside of a sparse checkout aren't reported by `git status`, even if they exist
+	skipped, err := GetSkipWorktreeFiles(rootPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, filePath := range skipped {
+		delete(objectIDs, filePath)
+	}
+	return objectIDs, nil
+}
+
+// GetSkipWorktreeFiles returns the paths, relative to rootPath, of files under rootPath that
+// have the skip-worktree bit set. This is how a sparse checkout marks files that are
+// in the index but were not written to the working tree.
+func GetSkipWorktreeFiles(rootPath turbopath.AbsoluteSystemPath) ([]turbopath.AnchoredUnixPath, error) {
+	cmd := exec.Command(
+		"git",      // Using `git` from $PATH,
+		"ls-files", // list the files in the index,
+		"-t",       // tagged with their status,
+		"-z",       // with each file path relative to the invocation directory and \000-terminated.
+	)
+	cmd.Dir = rootPath.ToString() // Include files only from this directory.
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read `git ls-files`: %w", err)
+	}
+
+	var skipped []turbopath.AnchoredUnixPath
+	for _, entry := range strings.Split(string(out), "\000") {
+		// Each entry is a one-letter tag, a space, and the path. `S` marks skip-worktree.
+		if strings.HasPrefix(entry, "S ") {
+			skipped = append(skipped, turbopath.AnchoredUnixPathFromUpstream(entry[2:]))
+		}
+	}
+	return skipped, nil
+}
+
+// isSparseCheckout returns whether the repository at rootPath is a sparse checkout,
+// which can leave files that are in the index out of the working tree
+func isSparseCheckout(rootPath turbopath.AbsoluteSystemPath) bool {
+	cmd := exec.Command("git", "config", "--bool", "core.sparseCheckout")
+	cmd.Dir = rootPath.ToString()
+	// The setting being unset is an error, and means the same as false
+	out, err := cmd.Output()
+	return err == nil && strings.TrimSpace(string(out)) == "true"
+}
+
+// Don't shell out for every package to find out whether the repository is sparse
+func memoizeIsSparseCheckout() func(turbopath.AbsoluteSystemPath) bool {
+	cacheMutex := &sync.RWMutex{}
+	cachedResult := map[turbopath.AbsoluteSystemPath]bool{}
+
+	return func(rootPath turbopath.AbsoluteSystemPath) bool {
+		cacheMutex.RLock()
+		result, resultExists := cachedResult[rootPath]
+		cacheMutex.RUnlock()
+
+		if resultExists {
+			return result
+		}
+
+		invokedResult := isSparseCheckout(rootPath)
+		cacheMutex.Lock()
+		cachedResult[rootPath] = invokedResult
+		cacheMutex.Unlock()
+
+		return invokedResult
+	}
+}
+
+var memoizedIsSparseCheckout = memoizeIsSparseCheckout()
+
 // getTraversePath gets the distance of the current working directory to the repository root.
 // This is used to convert repo-relative paths to cwd-relative paths.
 //
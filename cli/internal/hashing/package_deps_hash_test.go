@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"reflect"
 	"runtime"
+	"sort"
 	"strings"
 	"testing"
 
@@ -376,6 +377,44 @@ func TestGetPackageDeps(t *testing.T) {
 	}
 }
 
+func Test_cleanObjectIDs(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	files := map[string]string{
+		"clean-file":    "clean",
+		"modified-file": "original",
+		"staged-file":   "original",
+	}
+	for name, contents := range files {
+		assert.NilError(t, repoRoot.UntypedJoin(name).WriteFile([]byte(contents), 0644), "WriteFile")
+	}
+	assert.NilError(t, repoRoot.UntypedJoin("link").Symlink("clean-file"), "Symlink")
+	requireGitCmd(t, repoRoot, "init", ".")
+	requireGitCmd(t, repoRoot, "config", "--local", "user.name", "test")
+	requireGitCmd(t, repoRoot, "config", "--local", "user.email", "test@example.com")
+	requireGitCmd(t, repoRoot, "add", ".")
+	requireGitCmd(t, repoRoot, "commit", "-m", "foo")
+
+	assert.NilError(t, repoRoot.UntypedJoin("modified-file").WriteFile([]byte("modified"), 0644), "WriteFile")
+	assert.NilError(t, repoRoot.UntypedJoin("staged-file").WriteFile([]byte("staged"), 0644), "WriteFile")
+	requireGitCmd(t, repoRoot, "add", "staged-file")
+	assert.NilError(t, repoRoot.UntypedJoin("untracked-file").WriteFile([]byte("untracked"), 0644), "WriteFile")
+
+	status, err := gitStatus(repoRoot, nil)
+	assert.NilError(t, err, "gitStatus")
+	clean, err := cleanObjectIDs(repoRoot, status, false)
+	assert.NilError(t, err, "cleanObjectIDs")
+	assert.DeepEqual(t, clean, map[turbopath.AnchoredUnixPath]string{
+		"clean-file": "8d750d5f501340a13c2942eb7c3a984020682578",
+	})
+
+	// Files that git has an object for hash the same as those it doesn't
+	got, err := GetPackageDeps(repoRoot, &PackageDepsOptions{PackagePath: ".", InputPatterns: []string{"*"}})
+	assert.NilError(t, err, "GetPackageDeps")
+	want, err := gitHashObject(repoRoot, []turbopath.AnchoredSystemPath{"clean-file", "link", "modified-file", "staged-file", "untracked-file"})
+	assert.NilError(t, err, "gitHashObject")
+	assert.DeepEqual(t, got, want)
+}
+
 func Test_memoizedGetTraversePath(t *testing.T) {
 	fixturePath := getFixture(1)
 
@@ -384,3 +423,109 @@ func Test_memoizedGetTraversePath(t *testing.T) {
 
 	assert.Check(t, gotOne == gotTwo, "The strings are identical.")
 }
+
+func Test_isSparseCheckout(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	assert.NilError(t, repoRoot.UntypedJoin("file").WriteFile([]byte("file"), 0644), "WriteFile")
+	requireGitCmd(t, repoRoot, "init", ".")
+	requireGitCmd(t, repoRoot, "config", "--local", "user.name", "test")
+	requireGitCmd(t, repoRoot, "config", "--local", "user.email", "test@example.com")
+	requireGitCmd(t, repoRoot, "add", ".")
+	requireGitCmd(t, repoRoot, "commit", "-m", "foo")
+	assert.Assert(t, !isSparseCheckout(repoRoot))
+
+	requireGitCmd(t, repoRoot, "sparse-checkout", "set", "--no-cone", "/*")
+	assert.Assert(t, isSparseCheckout(repoRoot))
+}
+
+func TestGetPackageDepsSparseCheckout(t *testing.T) {
+	// Directory structure:
+	// <root>/
+	//   my-pkg/
+	//     package.json
+	//     docs/
+	//       excluded-file <- not checked out
+	//       present-file <- excluded, but written to disk anyway
+
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	myPkgDir := repoRoot.UntypedJoin("my-pkg")
+	excludedFilePath := myPkgDir.UntypedJoin("docs", "excluded-file")
+	presentFilePath := myPkgDir.UntypedJoin("docs", "present-file")
+	assert.NilError(t, excludedFilePath.EnsureDir(), "EnsureDir")
+	assert.NilError(t, myPkgDir.UntypedJoin("package.json").WriteFile([]byte("{}"), 0644), "WriteFile")
+	assert.NilError(t, excludedFilePath.WriteFile([]byte("excluded"), 0644), "WriteFile")
+	assert.NilError(t, presentFilePath.WriteFile([]byte("committed"), 0644), "WriteFile")
+
+	requireGitCmd(t, repoRoot, "init", ".")
+	requireGitCmd(t, repoRoot, "config", "--local", "user.name", "test")
+	requireGitCmd(t, repoRoot, "config", "--local", "user.email", "test@example.com")
+	requireGitCmd(t, repoRoot, "add", ".")
+	requireGitCmd(t, repoRoot, "commit", "-m", "foo")
+	requireGitCmd(t, repoRoot, "sparse-checkout", "set", "--no-cone", "/*", "!/my-pkg/docs/")
+
+	assert.Assert(t, !excludedFilePath.Exists(), "expected sparse checkout to remove excluded-file")
+	// git status doesn't report changes to skip-worktree files, so this has to be hashed from disk.
+	assert.NilError(t, presentFilePath.EnsureDir(), "EnsureDir")
+	assert.NilError(t, presentFilePath.WriteFile([]byte("changed"), 0644), "WriteFile")
+
+	got, err := GetPackageDeps(repoRoot, &PackageDepsOptions{PackagePath: "my-pkg"})
+	assert.NilError(t, err, "GetPackageDeps")
+	assert.DeepEqual(t, got, map[turbopath.AnchoredUnixPath]string{
+		"package.json":      "9e26dfeeb6e641a33dae4961196235bdb965b21b",
+		"docs/present-file": "21fb1eca31e64cd3914025058b21992ab76edcf9",
+	})
+}
+
+func TestGetPackageDepsHashExclude(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	files := map[string]string{
+		"my-pkg/package.json":             "{}",
+		"my-pkg/src/index.js":             "index",
+		"my-pkg/vendor/big.js":            "big",
+		"my-pkg/vendor/.turbo-hash":       "v1",
+		"my-pkg/fixtures/large/data.json": "data",
+	}
+	for name, contents := range files {
+		path := repoRoot.UntypedJoin(filepath.FromSlash(name))
+		assert.NilError(t, path.EnsureDir(), "EnsureDir")
+		assert.NilError(t, path.WriteFile([]byte(contents), 0644), "WriteFile")
+	}
+	requireGitCmd(t, repoRoot, "init", ".")
+	requireGitCmd(t, repoRoot, "config", "--local", "user.name", "test")
+	requireGitCmd(t, repoRoot, "config", "--local", "user.email", "test@example.com")
+	requireGitCmd(t, repoRoot, "add", ".")
+	requireGitCmd(t, repoRoot, "commit", "-m", "foo")
+
+	// Changes to excluded files, tracked or not, don't matter
+	assert.NilError(t, repoRoot.UntypedJoin("my-pkg", "vendor", "big.js").WriteFile([]byte("bigger"), 0644), "WriteFile")
+	assert.NilError(t, repoRoot.UntypedJoin("my-pkg", "vendor", "new.js").WriteFile([]byte("new"), 0644), "WriteFile")
+
+	exclude := []string{"my-pkg/vendor", "**/fixtures/large/**"}
+	want := []turbopath.AnchoredUnixPath{"package.json", "src/index.js", "vendor/.turbo-hash"}
+	for _, inputs := range [][]string{nil, {"**"}} {
+		got, err := GetPackageDeps(repoRoot, &PackageDepsOptions{PackagePath: "my-pkg", InputPatterns: inputs, ExcludePatterns: exclude})
+		assert.NilError(t, err, "GetPackageDeps")
+		var paths []turbopath.AnchoredUnixPath
+		for path := range got {
+			paths = append(paths, path)
+		}
+		sort.Slice(paths, func(i, j int) bool { return paths[i] < paths[j] })
+		assert.DeepEqual(t, paths, want)
+		assert.Equal(t, got["vendor/.turbo-hash"], "28c218c44b49222f91536daf5b4d9871638edc8e")
+	}
+}
+
+func TestIsHashExcluded(t *testing.T) {
+	patterns := []string{"vendor", "**/fixtures/large/**"}
+	for path, excluded := range map[turbopath.AnchoredUnixPath]bool{
+		"vendor/lib/a.js":                        true,
+		"vendor/.turbo-hash":                     false,
+		"vendored/a.js":                          false,
+		"packages/ui/fixtures/large/img.png":     true,
+		"packages/ui/fixtures/small/img.png":     false,
+		"packages/ui/fixtures/large/.turbo-hash": false,
+	} {
+		assert.Equal(t, IsHashExcluded(patterns, path), excluded, path)
+	}
+	assert.Assert(t, !IsHashExcluded(nil, "vendor/lib/a.js"))
+}
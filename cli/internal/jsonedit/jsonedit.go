@@ -0,0 +1,297 @@
+// Package jsonedit edits JSON documents that are written by hand, such as
+// package.json and turbo.json. Rather than round-tripping them through
+// encoding/json, which would drop comments and reorder keys, edits splice new
+// values into the original text.
+package jsonedit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Span is the byte range of a value in a JSON document
+type Span struct {
+	Start int
+	End   int
+}
+
+// Scanner walks a JSON document, allowing the comments that turbo.json permits
+type Scanner struct {
+	content []byte
+}
+
+// NewScanner returns a Scanner for content
+func NewScanner(content []byte) *Scanner {
+	return &Scanner{content: content}
+}
+
+func (s *Scanner) errorAt(i int, format string, args ...interface{}) error {
+	line := bytes.Count(s.content[:i], []byte("\n")) + 1
+	return fmt.Errorf("line %v: %v", line, fmt.Sprintf(format, args...))
+}
+
+// skipSpace skips whitespace and comments, and returns the index of the next token
+func (s *Scanner) skipSpace(i int) int {
+	for i < len(s.content) {
+		switch {
+		case bytes.IndexByte([]byte(" \t\r\n"), s.content[i]) >= 0:
+			i++
+		case bytes.HasPrefix(s.content[i:], []byte("//")):
+			end := bytes.IndexByte(s.content[i:], '\n')
+			if end < 0 {
+				return len(s.content)
+			}
+			i += end + 1
+		case bytes.HasPrefix(s.content[i:], []byte("/*")):
+			end := bytes.Index(s.content[i+2:], []byte("*/"))
+			if end < 0 {
+				return len(s.content)
+			}
+			i += end + 4
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+// skipValue returns the index just past the value that starts at i
+func (s *Scanner) skipValue(i int) (int, error) {
+	if i >= len(s.content) {
+		return 0, s.errorAt(i, "unexpected end of file")
+	}
+	switch s.content[i] {
+	case '"':
+		for j := i + 1; j < len(s.content); j++ {
+			if s.content[j] == '\\' {
+				j++
+			} else if s.content[j] == '"' {
+				return j + 1, nil
+			}
+		}
+		return 0, s.errorAt(i, "unterminated string")
+	case '{', '[':
+		closer := byte('}')
+		if s.content[i] == '[' {
+			closer = ']'
+		}
+		j := s.skipSpace(i + 1)
+		for j < len(s.content) && s.content[j] != closer {
+			end, err := s.skipValue(j)
+			if err != nil {
+				return 0, err
+			}
+			j = s.skipSpace(end)
+			if j < len(s.content) && (s.content[j] == ',' || s.content[j] == ':') {
+				j = s.skipSpace(j + 1)
+			}
+		}
+		if j >= len(s.content) {
+			return 0, s.errorAt(i, "unterminated %c", s.content[i])
+		}
+		return j + 1, nil
+	default:
+		j := i
+		for j < len(s.content) && bytes.IndexByte([]byte(",:]} \t\r\n/"), s.content[j]) < 0 {
+			j++
+		}
+		if j == i {
+			return 0, s.errorAt(i, "unexpected %q", s.content[i])
+		}
+		return j, nil
+	}
+}
+
+// Elements returns the spans of the members of the object or array that starts at
+// start. For an object, each member spans from its key to the end of its value.
+func (s *Scanner) Elements(start int) ([]Span, error) {
+	var elements []Span
+	i := s.skipSpace(start + 1)
+	for i < len(s.content) && s.content[i] != '}' && s.content[i] != ']' {
+		elementStart := i
+		end, err := s.skipValue(i)
+		if err != nil {
+			return nil, err
+		}
+		if s.content[start] == '{' {
+			colon := s.skipSpace(end)
+			if colon >= len(s.content) || s.content[colon] != ':' {
+				return nil, s.errorAt(end, "expected ':'")
+			}
+			end, err = s.skipValue(s.skipSpace(colon + 1))
+			if err != nil {
+				return nil, err
+			}
+		}
+		elements = append(elements, Span{elementStart, end})
+		i = s.skipSpace(end)
+		if i < len(s.content) && s.content[i] == ',' {
+			i = s.skipSpace(i + 1)
+		}
+	}
+	return elements, nil
+}
+
+// FindKey returns the span of the value for key in the object that starts at start
+func (s *Scanner) FindKey(start int, key string) (Span, bool, error) {
+	member, _, ok, err := s.findMember(start, key)
+	if err != nil || !ok {
+		return Span{}, ok, err
+	}
+	keyEnd, err := s.skipValue(member.Start)
+	if err != nil {
+		return Span{}, false, err
+	}
+	valueStart := s.skipSpace(s.skipSpace(keyEnd) + 1)
+	return Span{valueStart, member.End}, true, nil
+}
+
+// findMember returns the span of the member for key, from the key to the end of
+// its value, and its index in the object that starts at start
+func (s *Scanner) findMember(start int, key string) (Span, int, bool, error) {
+	if start >= len(s.content) || s.content[start] != '{' {
+		return Span{}, 0, false, s.errorAt(start, "expected an object")
+	}
+	members, err := s.Elements(start)
+	if err != nil {
+		return Span{}, 0, false, err
+	}
+	for i, member := range members {
+		keyEnd, err := s.skipValue(member.Start)
+		if err != nil {
+			return Span{}, 0, false, err
+		}
+		var name string
+		if err := json.Unmarshal(s.content[member.Start:keyEnd], &name); err != nil {
+			return Span{}, 0, false, s.errorAt(member.Start, "invalid key")
+		}
+		if name == key {
+			return member, i, true, nil
+		}
+	}
+	return Span{}, 0, false, nil
+}
+
+// Root returns the index of the top-level value of the document
+func (s *Scanner) Root() int {
+	return s.skipSpace(0)
+}
+
+// lineIndent returns the leading whitespace of the line containing i
+func lineIndent(content []byte, i int) string {
+	lineStart := bytes.LastIndexByte(content[:i], '\n') + 1
+	end := lineStart
+	for end < len(content) && (content[end] == ' ' || content[end] == '\t') {
+		end++
+	}
+	return string(content[lineStart:end])
+}
+
+// Splice replaces content[at.Start:at.End] with value
+func Splice(content []byte, at Span, value string) []byte {
+	result := make([]byte, 0, len(content)+len(value))
+	result = append(result, content[:at.Start]...)
+	result = append(result, value...)
+	return append(result, content[at.End:]...)
+}
+
+// AppendElement adds value to the end of the array or object that starts at start,
+// following the layout of the existing elements
+func (s *Scanner) AppendElement(start int, value string) ([]byte, error) {
+	elements, err := s.Elements(start)
+	if err != nil {
+		return nil, err
+	}
+	baseIndent := lineIndent(s.content, start)
+	if len(elements) == 0 {
+		end, err := s.skipValue(start)
+		if err != nil {
+			return nil, err
+		}
+		if s.content[start] == '[' {
+			return Splice(s.content, Span{start + 1, end - 1}, value), nil
+		}
+		indent := baseIndent + "  "
+		return Splice(s.content, Span{start + 1, end - 1}, "\n"+indent+IndentValue(value, indent)+"\n"+baseIndent), nil
+	}
+	first := elements[0]
+	last := elements[len(elements)-1]
+	if !bytes.Contains(s.content[start:first.Start], []byte("\n")) {
+		return Splice(s.content, Span{last.End, last.End}, ", "+value), nil
+	}
+	indent := lineIndent(s.content, first.Start)
+	return Splice(s.content, Span{last.End, last.End}, ",\n"+indent+IndentValue(value, indent)), nil
+}
+
+// RemoveElement removes the element at index from the array or object that starts
+// at start, along with the separator and any comments that follow it
+func (s *Scanner) RemoveElement(start int, index int) ([]byte, error) {
+	elements, err := s.Elements(start)
+	if err != nil {
+		return nil, err
+	}
+	if index < 0 || index >= len(elements) {
+		return nil, s.errorAt(start, "no element %v", index)
+	}
+	switch {
+	case len(elements) == 1:
+		end, err := s.skipValue(start)
+		if err != nil {
+			return nil, err
+		}
+		return Splice(s.content, Span{start + 1, end - 1}, ""), nil
+	case index < len(elements)-1:
+		return Splice(s.content, Span{elements[index].Start, elements[index+1].Start}, ""), nil
+	default:
+		return Splice(s.content, Span{elements[index-1].End, elements[index].End}, ""), nil
+	}
+}
+
+// RemoveMember removes the member for key from the object that starts at start,
+// if it has one
+func (s *Scanner) RemoveMember(start int, key string) ([]byte, error) {
+	_, index, ok, err := s.findMember(start, key)
+	if err != nil {
+		return nil, err
+	} else if !ok {
+		return s.content, nil
+	}
+	return s.RemoveElement(start, index)
+}
+
+// IndentUnit returns the indentation that a document uses for each level of nesting,
+// judging by the object or array that starts at start
+func (s *Scanner) IndentUnit(start int) string {
+	elements, err := s.Elements(start)
+	if err != nil || len(elements) == 0 {
+		return "  "
+	}
+	baseIndent := lineIndent(s.content, start)
+	indent := lineIndent(s.content, elements[0].Start)
+	if len(indent) > len(baseIndent) && strings.HasPrefix(indent, baseIndent) {
+		return indent[len(baseIndent):]
+	}
+	return "  "
+}
+
+// Dedent returns the value that starts at start, with the indentation of the line
+// it starts on removed from each of its lines, so that it can stand on its own
+func (s *Scanner) Dedent(start int) ([]byte, error) {
+	end, err := s.skipValue(start)
+	if err != nil {
+		return nil, err
+	}
+	indent := lineIndent(s.content, start)
+	if indent == "" {
+		return append([]byte{}, s.content[start:end]...), nil
+	}
+	return []byte(strings.ReplaceAll(string(s.content[start:end]), "\n"+indent, "\n")), nil
+}
+
+// IndentValue indents every line of value after the first, so that a multi-line
+// value lines up with the line it is inserted on
+func IndentValue(value string, indent string) string {
+	return strings.ReplaceAll(value, "\n", "\n"+indent)
+}
@@ -0,0 +1,72 @@
+package jsonedit
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func Test_RemoveElement(t *testing.T) {
+	testCases := []struct {
+		name     string
+		content  string
+		index    int
+		expected string
+	}{
+		{
+			name:     "first of several",
+			content:  `["a", "b", "c"]`,
+			index:    0,
+			expected: `["b", "c"]`,
+		},
+		{
+			name:     "last of several",
+			content:  `["a", "b", "c"]`,
+			index:    2,
+			expected: `["a", "b"]`,
+		},
+		{
+			name:     "only element",
+			content:  "{\n  \"a\": 1 // one\n}",
+			index:    0,
+			expected: "{}",
+		},
+		{
+			name:     "member with a comment",
+			content:  "{\n  \"a\": 1, // one\n  \"b\": 2\n}",
+			index:    0,
+			expected: "{\n  \"b\": 2\n}",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := NewScanner([]byte(tc.content))
+			result, err := s.RemoveElement(s.Root(), tc.index)
+			assert.NilError(t, err)
+			assert.Equal(t, string(result), tc.expected)
+		})
+	}
+}
+
+func Test_RemoveMember(t *testing.T) {
+	s := NewScanner([]byte(`{"name": "root", "turbo": {"pipeline": {}}, "private": true}`))
+	result, err := s.RemoveMember(s.Root(), "turbo")
+	assert.NilError(t, err)
+	assert.Equal(t, string(result), `{"name": "root", "private": true}`)
+
+	s = NewScanner(result)
+	result, err = s.RemoveMember(s.Root(), "turbo")
+	assert.NilError(t, err)
+	assert.Equal(t, string(result), `{"name": "root", "private": true}`)
+}
+
+func Test_Dedent(t *testing.T) {
+	content := "{\n  \"turbo\": {\n    \"pipeline\": {\n      \"build\": {}\n    }\n  }\n}\n"
+	s := NewScanner([]byte(content))
+	value, ok, err := s.FindKey(s.Root(), "turbo")
+	assert.NilError(t, err)
+	assert.Assert(t, ok)
+	result, err := s.Dedent(value.Start)
+	assert.NilError(t, err)
+	assert.Equal(t, string(result), "{\n  \"pipeline\": {\n    \"build\": {}\n  }\n}")
+}
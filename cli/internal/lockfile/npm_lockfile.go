@@ -1,9 +1,11 @@
 package lockfile
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
+	"sort"
 	"strings"
 
 	"github.com/vercel/turbo/cli/internal/turbopath"
@@ -19,6 +21,12 @@ type NpmLockfile struct {
 	Packages map[string]NpmPackage `json:"packages,omitempty"`
 	// Legacy info for npm version 5 & 6
 	Dependencies map[string]NpmDependency `json:"dependencies,omitempty"`
+
+	// fields are the top level fields as they were decoded, in order
+	fields *npmObject
+	// rawPackages are the entries of Packages as they were decoded, in order,
+	// so that they can be encoded again exactly as npm wrote them
+	rawPackages *npmObject
 }
 
 // NpmPackage Representation of dependencies used in LockfileVersion 2+
@@ -46,6 +54,8 @@ type NpmPackage struct {
 	PeerDependencies     map[string]string `json:"peerDependencies,omitempty"`
 	OptionalDependencies map[string]string `json:"optionalDependencies,omitempty"`
 
+	PeerDependenciesMeta map[string]NpmPeerDependencyMeta `json:"peerDependenciesMeta,omitempty"`
+
 	Bin map[string]string `json:"bin,omitempty"`
 
 	// Engines has two valid formats historically, an array and an object.
@@ -61,6 +71,11 @@ type NpmPackage struct {
 	Workspaces []string `json:"workspaces,omitempty"`
 }
 
+// NpmPeerDependencyMeta describes a peer dependency
+type NpmPeerDependencyMeta struct {
+	Optional bool `json:"optional,omitempty"`
+}
+
 // NpmDependency Legacy representation of dependencies
 type NpmDependency struct {
 	Version      string                   `json:"version"`
@@ -143,6 +158,10 @@ func (l *NpmLockfile) AllDependencies(key string) (map[string]string, bool) {
 }
 
 // Subgraph Given a list of lockfile keys returns a Lockfile based off the original one that only contains the packages given
+//
+// Entries keep their contents and order, except that their dev, optional, devOptional
+// and peer flags are calculated again for the pruned dependency graph, so that
+// installing from the pruned lockfile leaves it unchanged.
 func (l *NpmLockfile) Subgraph(workspacePackages []turbopath.AnchoredSystemPath, packages []string) (Lockfile, error) {
 	prunedPackages := make(map[string]NpmPackage, len(packages))
 	for _, pkg := range packages {
@@ -155,6 +174,7 @@ func (l *NpmLockfile) Subgraph(workspacePackages []turbopath.AnchoredSystemPath,
 	if rootEntry, ok := l.Packages[""]; ok {
 		prunedPackages[""] = rootEntry
 	}
+	workspaces := make([]string, 0, len(workspacePackages))
 	for _, workspacePackages := range workspacePackages {
 		workspacePkg := workspacePackages.ToUnixPath().ToString()
 		if workspaceEntry, ok := l.Packages[workspacePkg]; ok {
@@ -162,17 +182,27 @@ func (l *NpmLockfile) Subgraph(workspacePackages []turbopath.AnchoredSystemPath,
 		} else {
 			return nil, fmt.Errorf("No lockfile entry found for %s", workspacePkg)
 		}
+		workspaces = append(workspaces, workspacePkg)
 		// Each workspace package has a fake version of the package that links back to the original
 		// but is needed for dependency resolution.
 		for key, entry := range l.Packages {
-			if entry.Resolved == workspacePkg {
+			if entry.Link && entry.Resolved == workspacePkg {
 				prunedPackages[key] = entry
-				break
 			}
 		}
 	}
+	// Links to directories that aren't workspaces, such as file: dependencies,
+	// need the entry of the directory too
+	for _, entry := range prunedPackages {
+		if !entry.Link {
+			continue
+		}
+		if target, ok := l.Packages[entry.Resolved]; ok {
+			prunedPackages[entry.Resolved] = target
+		}
+	}
 
-	return &NpmLockfile{
+	pruned := &NpmLockfile{
 		Name:    l.Name,
 		Version: l.Version,
 		// Forcible upgrade to version 3 since we aren't including backward compatibility capabilities
@@ -180,15 +210,115 @@ func (l *NpmLockfile) Subgraph(workspacePackages []turbopath.AnchoredSystemPath,
 		Requires:        l.Requires,
 		Packages:        prunedPackages,
 		// We omit dependencies since they are only used for supporting npm <=6
-	}, nil
+	}
+	if l.fields != nil {
+		pruned.fields = l.fields.clone()
+		pruned.fields.remove("dependencies")
+	}
+	rawPackages, err := l.prunedRawPackages(prunedPackages, workspaces)
+	if err != nil {
+		return nil, err
+	}
+	pruned.rawPackages = rawPackages
+	return pruned, nil
 }
 
-// Encode the lockfile representation and write it to the given writer
+// prunedRawPackages returns the entries of packages in their original order, with
+// their flags calculated for the graph they form
+func (l *NpmLockfile) prunedRawPackages(packages map[string]NpmPackage, workspaces []string) (*npmObject, error) {
+	if l.rawPackages == nil {
+		return nil, nil
+	}
+	flags := calculateNpmFlags(packages, workspaces)
+	pruned := &npmObject{values: make(map[string]json.RawMessage, len(packages))}
+	for _, key := range l.rawPackages.keys {
+		if _, ok := packages[key]; !ok {
+			continue
+		}
+		raw := l.rawPackages.values[key]
+		if nodeFlags, ok := flags[key]; ok {
+			var err error
+			raw, err = withNpmFlags(raw, nodeFlags)
+			if err != nil {
+				return nil, fmt.Errorf("invalid lockfile entry for %v: %w", key, err)
+			}
+		}
+		pruned.keys = append(pruned.keys, key)
+		pruned.values[key] = raw
+	}
+	return pruned, nil
+}
+
+// Encode the lockfile representation and write it to the given writer.
+// A decoded lockfile keeps the order of its fields and entries, and is formatted
+// the way npm formats it.
 func (l *NpmLockfile) Encode(w io.Writer) error {
-	encoder := json.NewEncoder(w)
-	encoder.SetIndent("", "  ")
-	encoder.SetEscapeHTML(false)
-	return encoder.Encode(l)
+	out := &npmObject{}
+	set := func(key string, value interface{}) error {
+		raw, err := marshalNpmJSON(value)
+		if err != nil {
+			return err
+		}
+		out.set(key, raw)
+		return nil
+	}
+	if l.fields != nil {
+		out = l.fields.clone()
+	} else {
+		if err := set("name", l.Name); err != nil {
+			return err
+		}
+		if err := set("version", l.Version); err != nil {
+			return err
+		}
+	}
+	if err := set("lockfileVersion", l.LockfileVersion); err != nil {
+		return err
+	}
+	if l.fields == nil && l.Requires {
+		if err := set("requires", true); err != nil {
+			return err
+		}
+	}
+
+	packages := l.rawPackages
+	if packages == nil {
+		packages = &npmObject{}
+		keys := make([]string, 0, len(l.Packages))
+		for key := range l.Packages {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			raw, err := marshalNpmJSON(l.Packages[key])
+			if err != nil {
+				return err
+			}
+			packages.set(key, raw)
+		}
+	}
+	var packagesJSON bytes.Buffer
+	if err := packages.encode(&packagesJSON); err != nil {
+		return err
+	}
+	out.set("packages", packagesJSON.Bytes())
+	if l.fields == nil && len(l.Dependencies) > 0 {
+		if err := set("dependencies", l.Dependencies); err != nil {
+			return err
+		}
+	}
+
+	var compact bytes.Buffer
+	if err := out.encode(&compact); err != nil {
+		return err
+	}
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, compact.Bytes(), "", "  "); err != nil {
+		return err
+	}
+	indented.WriteByte('\n')
+	_, err := indented.WriteTo(w)
+	return err
 }
 
 // Patches return a list of patches used in the lockfile
@@ -202,6 +332,17 @@ func DecodeNpmLockfile(content []byte) (*NpmLockfile, error) {
 	if err := json.Unmarshal(content, &lockfile); err != nil {
 		return nil, err
 	}
+	fields, err := decodeNpmObject(content)
+	if err != nil {
+		return nil, err
+	}
+	if rawPackages, ok := fields.values["packages"]; ok {
+		lockfile.rawPackages, err = decodeNpmObject(rawPackages)
+		if err != nil {
+			return nil, err
+		}
+	}
+	lockfile.fields = fields
 
 	// LockfileVersion <=1 is for npm <=6
 	ancientLockfile := lockfile.LockfileVersion <= 1 || (len(lockfile.Dependencies) > 0 && len(lockfile.Packages) == 0)
@@ -236,3 +377,294 @@ func npmPathParent(key string) string {
 	}
 	return ""
 }
+
+// npmObject is a JSON object that keeps the order of its keys
+type npmObject struct {
+	keys   []string
+	values map[string]json.RawMessage
+}
+
+// decodeNpmObject decodes a JSON object, keeping its values as they were written
+func decodeNpmObject(data []byte) (*npmObject, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if token, err := decoder.Token(); err != nil {
+		return nil, err
+	} else if token != json.Delim('{') {
+		return nil, fmt.Errorf("expected an object, found %v", token)
+	}
+	obj := &npmObject{values: make(map[string]json.RawMessage)}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, ok := token.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected a key, found %v", token)
+		}
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return nil, err
+		}
+		obj.set(key, value)
+	}
+	if _, err := decoder.Token(); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// set replaces the value of key, or adds key at the end if it isn't present
+func (o *npmObject) set(key string, value json.RawMessage) {
+	if o.values == nil {
+		o.values = make(map[string]json.RawMessage)
+	}
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = value
+}
+
+// insert adds key at index, which must not be present
+func (o *npmObject) insert(index int, key string, value json.RawMessage) {
+	o.keys = append(o.keys, "")
+	copy(o.keys[index+1:], o.keys[index:])
+	o.keys[index] = key
+	o.values[key] = value
+}
+
+// remove removes key, and returns the index it was at, or -1 if it wasn't present
+func (o *npmObject) remove(key string) int {
+	if _, ok := o.values[key]; !ok {
+		return -1
+	}
+	delete(o.values, key)
+	for i, k := range o.keys {
+		if k == key {
+			o.keys = append(o.keys[:i], o.keys[i+1:]...)
+			return i
+		}
+	}
+	return -1
+}
+
+func (o *npmObject) clone() *npmObject {
+	clone := &npmObject{
+		keys:   append([]string{}, o.keys...),
+		values: make(map[string]json.RawMessage, len(o.values)),
+	}
+	for key, value := range o.values {
+		clone.values[key] = value
+	}
+	return clone
+}
+
+// encode writes the object to buf. Values are copied as they are, so the result
+// should be indented.
+func (o *npmObject) encode(buf *bytes.Buffer) error {
+	buf.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		encodedKey, err := marshalNpmJSON(key)
+		if err != nil {
+			return err
+		}
+		buf.Write(encodedKey)
+		buf.WriteByte(':')
+		buf.Write(o.values[key])
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+// marshalNpmJSON encodes v as JSON.stringify would, without escaping HTML characters
+func marshalNpmJSON(v interface{}) (json.RawMessage, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+// npmFlags are the flags npm sets on an entry, according to how it can be reached
+// from the root of the project
+type npmFlags struct {
+	// Dev is set if the package is only reachable through dev dependencies
+	Dev bool
+	// Optional is set if the package is only reachable through optional dependencies
+	Optional bool
+	// DevOptional is set if the package is only reachable through dependencies
+	// that are either dev or optional, but neither Dev nor Optional is set
+	DevOptional bool
+	// Peer is set if the package is only reachable through peer dependencies
+	Peer bool
+}
+
+// npmEdgeType is the kind of dependency one package has on another
+type npmEdgeType int
+
+const (
+	npmEdgeProd npmEdgeType = iota
+	npmEdgeDev
+	npmEdgeOptional
+	npmEdgePeer
+	npmEdgePeerOptional
+)
+
+// npmEdges returns the packages that the entry at key depends on, and how. A
+// dependency of more than one kind has the kind that npm would give it.
+func npmEdges(packages map[string]NpmPackage, key string, isTop bool) map[string]npmEdgeType {
+	entry := packages[key]
+	edges := make(map[string]npmEdgeType)
+	if entry.Link {
+		// A link has the flags of whatever depends on it, and passes them to its target
+		if _, ok := packages[entry.Resolved]; ok {
+			edges[entry.Resolved] = npmEdgeProd
+		}
+		return edges
+	}
+	byName := make(map[string]npmEdgeType)
+	// Later kinds replace earlier ones, in the same order as npm loads them
+	if isTop {
+		for name := range entry.DevDependencies {
+			byName[name] = npmEdgeDev
+		}
+	}
+	for name := range entry.PeerDependencies {
+		if entry.PeerDependenciesMeta[name].Optional {
+			byName[name] = npmEdgePeerOptional
+		} else {
+			byName[name] = npmEdgePeer
+		}
+	}
+	for name := range entry.Dependencies {
+		byName[name] = npmEdgeProd
+	}
+	for name := range entry.OptionalDependencies {
+		byName[name] = npmEdgeOptional
+	}
+	for name, edgeType := range byName {
+		candidates := []string{"node_modules/" + name}
+		if key != "" {
+			candidates = possibleNpmDeps(key, name)
+		}
+		for _, candidate := range candidates {
+			if _, ok := packages[candidate]; ok {
+				edges[candidate] = edgeType
+				break
+			}
+		}
+	}
+	return edges
+}
+
+// calculateNpmFlags calculates the flags of each entry of packages that can be
+// reached from the root, the way npm does. The root depends on each workspace.
+func calculateNpmFlags(packages map[string]NpmPackage, workspaces []string) map[string]npmFlags {
+	isTop := make(map[string]bool, len(workspaces)+1)
+	isTop[""] = true
+	for _, workspace := range workspaces {
+		isTop[workspace] = true
+	}
+	edges := make(map[string]map[string]npmEdgeType, len(packages))
+	for key := range packages {
+		edges[key] = npmEdges(packages, key, isTop[key])
+	}
+	for _, workspace := range workspaces {
+		edges[""][workspace] = npmEdgeProd
+	}
+
+	// reachable returns the packages that can be reached from the root using only
+	// the kinds of edges that follow allows. A flag is unset for exactly those packages.
+	reachable := func(follow func(npmEdgeType) bool) map[string]bool {
+		seen := map[string]bool{"": true}
+		queue := []string{""}
+		for len(queue) > 0 {
+			key := queue[0]
+			queue = queue[1:]
+			for dep, edgeType := range edges[key] {
+				if !seen[dep] && follow(edgeType) {
+					seen[dep] = true
+					queue = append(queue, dep)
+				}
+			}
+		}
+		return seen
+	}
+	notDev := reachable(func(t npmEdgeType) bool { return t != npmEdgeDev })
+	notOptional := reachable(func(t npmEdgeType) bool { return t != npmEdgeOptional && t != npmEdgePeerOptional })
+	notDevOptional := reachable(func(t npmEdgeType) bool { return t == npmEdgeProd || t == npmEdgePeer })
+	notPeer := reachable(func(t npmEdgeType) bool { return t != npmEdgePeer && t != npmEdgePeerOptional })
+	all := reachable(func(npmEdgeType) bool { return true })
+
+	flags := make(map[string]npmFlags, len(all))
+	for key := range all {
+		if key == "" || packages[key].Link {
+			continue
+		}
+		f := npmFlags{
+			Dev:         !notDev[key],
+			Optional:    !notOptional[key],
+			DevOptional: !notDevOptional[key],
+			Peer:        !notPeer[key],
+		}
+		if f.Dev || f.Optional {
+			f.DevOptional = false
+		}
+		flags[key] = f
+	}
+	return flags
+}
+
+// _npmFlagKeys are the keys of npm's flags, in the order npm writes them
+var _npmFlagKeys = []string{"extraneous", "peer", "dev", "optional", "devOptional"}
+
+// withNpmFlags returns the entry raw, with its flags replaced by flags. The
+// flags go where they were, or after the fields that identify the package.
+func withNpmFlags(raw json.RawMessage, flags npmFlags) (json.RawMessage, error) {
+	entry, err := decodeNpmObject(raw)
+	if err != nil {
+		return nil, err
+	}
+	index := -1
+	for _, key := range _npmFlagKeys {
+		if removedAt := entry.remove(key); removedAt != -1 && (index == -1 || removedAt < index) {
+			index = removedAt
+		}
+	}
+	if index == -1 {
+		index = 0
+		for index < len(entry.keys) {
+			switch entry.keys[index] {
+			case "name", "version", "resolved", "integrity", "link":
+				index++
+				continue
+			}
+			break
+		}
+	}
+	values := []struct {
+		key string
+		set bool
+	}{
+		{"peer", flags.Peer},
+		{"dev", flags.Dev},
+		{"optional", flags.Optional},
+		{"devOptional", flags.DevOptional},
+	}
+	for _, value := range values {
+		if value.set {
+			entry.insert(index, value.key, json.RawMessage("true"))
+			index++
+		}
+	}
+	var buf bytes.Buffer
+	if err := entry.encode(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
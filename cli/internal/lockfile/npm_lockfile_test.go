@@ -1,6 +1,7 @@
 package lockfile
 
 import (
+	"bytes"
 	"sort"
 	"testing"
 
@@ -203,3 +204,53 @@ func Test_NpmAllDependencies(t *testing.T) {
 	}
 
 }
+
+func Test_NpmEncodeRoundTrip(t *testing.T) {
+	// npm-lock-v3.json is written by npm, so it's formatted as npm formats lockfiles
+	content, err := getFixture(t, "npm-lock-v3.json")
+	assert.NilError(t, err, "reading npm-lock-v3.json")
+	lockfile, err := DecodeNpmLockfile(content)
+	assert.NilError(t, err, "parsing npm-lock-v3.json")
+	var out bytes.Buffer
+	assert.NilError(t, lockfile.Encode(&out))
+	assert.Equal(t, out.String(), string(content))
+}
+
+func Test_NpmSubgraphRecalculatesFlags(t *testing.T) {
+	content, err := getFixture(t, "npm-lock-v3.json")
+	assert.NilError(t, err, "reading npm-lock-v3.json")
+	lockfile, err := DecodeNpmLockfile(content)
+	assert.NilError(t, err, "parsing npm-lock-v3.json")
+
+	// react is a dependency of docs and web, but only a dev dependency of ui
+	pruned, err := lockfile.Subgraph(
+		[]turbopath.AnchoredSystemPath{turbopath.AnchoredUnixPath("packages/ui").ToSystemPath()},
+		[]string{"node_modules/react", "node_modules/loose-envify", "node_modules/js-tokens"},
+	)
+	assert.NilError(t, err)
+	var out bytes.Buffer
+	assert.NilError(t, pruned.Encode(&out))
+
+	decoded, err := DecodeNpmLockfile(out.Bytes())
+	assert.NilError(t, err)
+	keys := decoded.rawPackages.keys
+	assert.DeepEqual(t, keys, []string{
+		"",
+		"node_modules/js-tokens",
+		"node_modules/loose-envify",
+		"node_modules/react",
+		"node_modules/ui",
+		"packages/ui",
+	})
+	for _, key := range keys[1:4] {
+		assert.Assert(t, decoded.Packages[key].Dev, key)
+	}
+	assert.Assert(t, cmp.Contains(out.String(), `"node_modules/react": {
+      "version": "18.2.0",
+      "resolved": "https://registry.npmjs.org/react/-/react-18.2.0.tgz",
+      "integrity": "sha512-/3IjMdb2L9QbBdWiW5e3P2/npwMBaU9mHCSCUzNln0ZCYbcfTsGbTJrU/kGemdH2IWmB2ioZ+zkxtmq6g09fGQ==",
+      "dev": true,
+      "dependencies": {`))
+	_, ok := decoded.fields.values["dependencies"]
+	assert.Assert(t, !ok, "pruned lockfile shouldn't have dependencies")
+}
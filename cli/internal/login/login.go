@@ -12,6 +12,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/vercel/turbo/cli/internal/client"
 	"github.com/vercel/turbo/cli/internal/cmdutil"
+	"github.com/vercel/turbo/cli/internal/errcode"
 	"github.com/vercel/turbo/cli/internal/ui"
 	"github.com/vercel/turbo/cli/internal/util"
 	"github.com/vercel/turbo/cli/internal/util/browser"
@@ -51,7 +52,7 @@ func NewLoginCommand(helper *cmdutil.Helper) *cobra.Command {
 					} else if errors.Is(err, errTryAfterEnable) || errors.Is(err, errNeedCachingEnabled) || errors.Is(err, errOverage) {
 						base.UI.Info("Remote Caching not enabled. Please run 'turbo login' again after Remote Caching has been enabled")
 					} else {
-						base.LogError("SSO login failed: %v", err)
+						base.LogError("SSO login failed: %w", errcode.Wrap(errcode.RequestFailed, err))
 					}
 					return err
 				}
@@ -61,7 +62,7 @@ func NewLoginCommand(helper *cmdutil.Helper) *cobra.Command {
 					if errors.Is(err, context.Canceled) {
 						base.UI.Info("Canceled. Turborepo not set up.")
 					} else {
-						base.LogError("login failed: %v", err)
+						base.LogError("login failed: %w", errcode.Wrap(errcode.RequestFailed, err))
 					}
 					return err
 				}
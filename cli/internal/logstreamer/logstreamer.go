@@ -141,8 +141,13 @@ var _ io.Writer = (*PrettyStdoutWriter)(nil)
 
 // NewPrettyStdoutWriter returns an instance of PrettyStdoutWriter
 func NewPrettyStdoutWriter(prefix string) *PrettyStdoutWriter {
+	return NewPrettyWriter(os.Stdout, prefix)
+}
+
+// NewPrettyWriter returns a PrettyStdoutWriter that writes to w rather than stdout
+func NewPrettyWriter(w io.Writer, prefix string) *PrettyStdoutWriter {
 	return &PrettyStdoutWriter{
-		w:      os.Stdout,
+		w:      w,
 		Prefix: prefix,
 	}
 }
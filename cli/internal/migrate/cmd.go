@@ -0,0 +1,78 @@
+package migrate
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/vercel/turbo/cli/internal/cmdutil"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+type opts struct {
+	dryRun bool
+	json   bool
+}
+
+// GetCmd returns the migrate-config command
+func GetCmd(helper *cmdutil.Helper) *cobra.Command {
+	opts := &opts{}
+	cmd := &cobra.Command{
+		Use:   "migrate-config",
+		Short: "Rewrite the config to the current schema",
+		Long: `Rewrite the config to the current schema.
+
+turbo still reads some legacy shapes of the config, and warns about each value
+that it translates with the code config/deprecated. migrate-config rewrites them:
+a "turbo" key in package.json becomes turbo.json, and environment variables in
+"dependsOn" and "globalDependencies" move to "env" and "globalEnv". Comments and
+the order of keys are kept.`,
+		Args:          cobra.NoArgs,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, err := helper.GetCmdBase(cmd.Flags())
+			if err != nil {
+				return err
+			}
+			if err := migrateConfig(base, opts); err != nil {
+				base.LogError("%w", err)
+				return err
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&opts.dryRun, "dry-run", false, "List the changes without writing them")
+	cmd.Flags().BoolVar(&opts.json, "json", false, "Print the changes as JSON")
+	return cmd
+}
+
+func migrateConfig(base *cmdutil.CmdBase, opts *opts) error {
+	result, err := Config(base.RepoRoot, opts.dryRun)
+	if err != nil {
+		return err
+	}
+	if opts.json {
+		bytes, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return err
+		}
+		base.UI.Output(string(bytes))
+		return nil
+	}
+	if len(result.Migrations) == 0 {
+		base.UI.Output("The config already uses the current schema")
+		return nil
+	}
+	for _, migration := range result.Migrations {
+		// Messages can hold environment variables, such as "$NODE_ENV", that util.Sprintf would expand
+		base.UI.Output(util.Sprintf("${GREY}•${RESET} ") + migration.Message)
+	}
+	base.UI.Output("")
+	if opts.dryRun {
+		base.UI.Output(util.Sprintf("${BOLD}Would rewrite %v${RESET}", strings.Join(result.Files, " and ")))
+	} else {
+		base.UI.Output(util.Sprintf("${BOLD}Rewrote %v${RESET}", strings.Join(result.Files, " and ")))
+	}
+	return nil
+}
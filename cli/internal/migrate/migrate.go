@@ -0,0 +1,300 @@
+// Package migrate holds the `turbo migrate-config` command, which rewrites legacy
+// shapes of the config to the current schema
+package migrate
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/jsonedit"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"muzzammil.xyz/jsonc"
+)
+
+// Result is what migrate-config changed, or would change with --dry-run
+type Result struct {
+	// Migrations are the legacy values that were rewritten
+	Migrations []fs.ConfigMigration `json:"migrations"`
+	// Files are the files that were written, relative to the repository root
+	Files  []string `json:"files"`
+	DryRun bool     `json:"dryRun"`
+}
+
+// Config rewrites the config of the repository at repoRoot to the current schema,
+// keeping its comments and the order of its keys. With dryRun, nothing is written.
+func Config(repoRoot turbopath.AbsoluteSystemPath, dryRun bool) (*Result, error) {
+	packageJSONPath := repoRoot.UntypedJoin("package.json")
+	turboJSONPath := repoRoot.UntypedJoin("turbo.json")
+	rootPackageJSON, err := fs.ReadPackageJSON(packageJSONPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read package.json: %w", err)
+	}
+	hasLegacyConfig := rootPackageJSON.LegacyTurboConfig != nil
+	turboJSON, err := fs.ReadTurboConfig(repoRoot, rootPackageJSON)
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{Migrations: turboJSON.Migrations, Files: []string{}, DryRun: dryRun}
+	if len(turboJSON.Migrations) == 0 {
+		return result, nil
+	}
+
+	packageJSON, err := packageJSONPath.ReadFile()
+	if err != nil {
+		return nil, err
+	}
+	var config []byte
+	if turboJSONPath.FileExists() {
+		config, err = turboJSONPath.ReadFile()
+	} else {
+		config, err = legacyConfig(packageJSON)
+	}
+	if err != nil {
+		return nil, err
+	}
+	edited, err := migrateEnvVarDependencies(config)
+	if err != nil {
+		return nil, fmt.Errorf("turbo.json: %w", err)
+	}
+	writeTurboJSON := !turboJSONPath.FileExists() || string(edited) != string(config)
+	if writeTurboJSON {
+		result.Files = append(result.Files, "turbo.json")
+	}
+	if hasLegacyConfig {
+		s := jsonedit.NewScanner(packageJSON)
+		packageJSON, err = s.RemoveMember(s.Root(), "turbo")
+		if err != nil {
+			return nil, fmt.Errorf("package.json: %w", err)
+		}
+		result.Files = append(result.Files, "package.json")
+	}
+	if dryRun {
+		return result, nil
+	}
+
+	if writeTurboJSON {
+		if err := writeFile(turboJSONPath, edited); err != nil {
+			return nil, err
+		}
+	}
+	if hasLegacyConfig {
+		if err := writeFile(packageJSONPath, packageJSON); err != nil {
+			return nil, err
+		}
+	}
+	// Anything left over is a shape that couldn't be rewritten, such as a dependsOn
+	// that isn't a list
+	rootPackageJSON, err = fs.ReadPackageJSON(packageJSONPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read package.json: %w", err)
+	}
+	turboJSON, err = fs.ReadTurboConfig(repoRoot, rootPackageJSON)
+	if err != nil {
+		return nil, fmt.Errorf("the migrated config is invalid: %w", err)
+	}
+	if len(turboJSON.Migrations) > 0 {
+		messages := make([]string, len(turboJSON.Migrations))
+		for i, migration := range turboJSON.Migrations {
+			messages[i] = migration.Message
+		}
+		return nil, fmt.Errorf("some legacy values could not be migrated:\n%v", strings.Join(messages, "\n"))
+	}
+	return result, nil
+}
+
+// writeFile writes contents to file, keeping its permissions if it exists
+func writeFile(file turbopath.AbsoluteSystemPath, contents []byte) error {
+	mode := os.FileMode(0644)
+	if info, err := file.Lstat(); err == nil {
+		mode = info.Mode()
+	}
+	return file.WriteFile(contents, mode)
+}
+
+// legacyConfig returns the "turbo" key of a package.json as the contents of a
+// turbo.json
+func legacyConfig(packageJSON []byte) ([]byte, error) {
+	s := jsonedit.NewScanner(packageJSON)
+	value, ok, err := s.FindKey(s.Root(), "turbo")
+	if err != nil {
+		return nil, fmt.Errorf("package.json: %w", err)
+	} else if !ok {
+		return nil, fmt.Errorf("package.json: %w", os.ErrNotExist)
+	}
+	config, err := s.Dedent(value.Start)
+	if err != nil {
+		return nil, fmt.Errorf("package.json: %w", err)
+	}
+	return append(config, '\n'), nil
+}
+
+// migrateEnvVarDependencies moves environment variables out of globalDependencies
+// and into globalEnv, and out of the dependsOn of each task and into its env
+func migrateEnvVarDependencies(config []byte) ([]byte, error) {
+	var raw struct {
+		Pipeline map[string]json.RawMessage `json:"pipeline"`
+	}
+	if err := jsonc.Unmarshal(config, &raw); err != nil {
+		return nil, err
+	}
+	config, err := moveEnvVars(config, nil, "globalDependencies", "globalEnv")
+	if err != nil {
+		return nil, err
+	}
+	tasks := make([]string, 0, len(raw.Pipeline))
+	for task := range raw.Pipeline {
+		tasks = append(tasks, task)
+	}
+	sort.Strings(tasks)
+	for _, task := range tasks {
+		config, err = moveEnvVars(config, []string{"pipeline", task}, "dependsOn", "env")
+		if err != nil {
+			return nil, err
+		}
+	}
+	return config, nil
+}
+
+// moveEnvVars moves the values prefixed with $ out of the list at the key from, in
+// the object at path, and into the list at the key to, without the $. The list at
+// from is removed if nothing is left in it.
+func moveEnvVars(config []byte, path []string, from string, to string) ([]byte, error) {
+	s := jsonedit.NewScanner(config)
+	list, ok, err := findList(s, config, path, from)
+	if err != nil || !ok {
+		return config, err
+	}
+	elements, err := s.Elements(list.Start)
+	if err != nil {
+		return nil, err
+	}
+	var envVars []string
+	var indices []int
+	for i, element := range elements {
+		var value string
+		if json.Unmarshal(config[element.Start:element.End], &value) == nil && strings.HasPrefix(value, "$") {
+			envVars = append(envVars, strings.TrimPrefix(value, "$"))
+			indices = append(indices, i)
+		}
+	}
+	if len(envVars) == 0 {
+		return config, nil
+	}
+
+	if len(indices) == len(elements) {
+		object, _, err := findObject(s, config, path)
+		if err != nil {
+			return nil, err
+		}
+		config, err = s.RemoveMember(object, from)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		// Later elements go first, so that the earlier indices stay valid
+		for i := len(indices) - 1; i >= 0; i-- {
+			s = jsonedit.NewScanner(config)
+			list, _, err := findList(s, config, path, from)
+			if err != nil {
+				return nil, err
+			}
+			config, err = s.RemoveElement(list.Start, indices[i])
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	existing := make(map[string]bool)
+	s = jsonedit.NewScanner(config)
+	list, ok, err = findList(s, config, path, to)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		elements, err := s.Elements(list.Start)
+		if err != nil {
+			return nil, err
+		}
+		for _, element := range elements {
+			var value string
+			if json.Unmarshal(config[element.Start:element.End], &value) == nil {
+				existing[value] = true
+			}
+		}
+	}
+	var added []string
+	for _, envVar := range envVars {
+		if !existing[envVar] {
+			existing[envVar] = true
+			quoted, err := json.Marshal(envVar)
+			if err != nil {
+				return nil, err
+			}
+			added = append(added, string(quoted))
+		}
+	}
+	if len(added) == 0 {
+		return config, nil
+	}
+	if !ok {
+		object, _, err := findObject(s, config, path)
+		if err != nil {
+			return nil, err
+		}
+		key, err := json.Marshal(to)
+		if err != nil {
+			return nil, err
+		}
+		return s.AppendElement(object, fmt.Sprintf("%s: [%v]", key, strings.Join(added, ", ")))
+	}
+	for _, value := range added {
+		s = jsonedit.NewScanner(config)
+		list, _, err := findList(s, config, path, to)
+		if err != nil {
+			return nil, err
+		}
+		config, err = s.AppendElement(list.Start, value)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return config, nil
+}
+
+// findObject returns the start of the object at path, a list of keys from the root
+func findObject(s *jsonedit.Scanner, config []byte, path []string) (int, bool, error) {
+	start := s.Root()
+	for _, key := range path {
+		value, ok, err := s.FindKey(start, key)
+		if err != nil || !ok {
+			return 0, ok, err
+		}
+		start = value.Start
+	}
+	if start >= len(config) || config[start] != '{' {
+		return 0, false, fmt.Errorf("%q is not an object", strings.Join(path, "."))
+	}
+	return start, true, nil
+}
+
+// findList returns the span of the list at the key of the object at path. Values
+// that aren't lists are left for the config to report as invalid.
+func findList(s *jsonedit.Scanner, config []byte, path []string, key string) (jsonedit.Span, bool, error) {
+	object, ok, err := findObject(s, config, path)
+	if err != nil || !ok {
+		return jsonedit.Span{}, ok, err
+	}
+	value, ok, err := s.FindKey(object, key)
+	if err != nil || !ok {
+		return jsonedit.Span{}, ok, err
+	}
+	if config[value.Start] != '[' {
+		return jsonedit.Span{}, false, nil
+	}
+	return value, true, nil
+}
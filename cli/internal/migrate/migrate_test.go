@@ -0,0 +1,142 @@
+package migrate
+
+import (
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"gotest.tools/v3/assert"
+)
+
+func writeRepo(t *testing.T, files map[string]string) turbopath.AbsoluteSystemPath {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	for name, contents := range files {
+		assert.NilError(t, repoRoot.UntypedJoin(name).WriteFile([]byte(contents), 0644), "WriteFile")
+	}
+	return repoRoot
+}
+
+func readFile(t *testing.T, repoRoot turbopath.AbsoluteSystemPath, name string) string {
+	contents, err := repoRoot.UntypedJoin(name).ReadFile()
+	assert.NilError(t, err, "ReadFile")
+	return string(contents)
+}
+
+func TestConfigFromPackageJSON(t *testing.T) {
+	repoRoot := writeRepo(t, map[string]string{
+		"package.json": `{
+  "name": "root",
+  "turbo": {
+    "pipeline": {
+      "build": {
+        "dependsOn": ["^build", "$NODE_ENV"],
+        "outputs": ["dist/**"]
+      }
+    }
+  },
+  "workspaces": ["packages/*"]
+}
+`,
+	})
+	result, err := Config(repoRoot, false)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, result.Files, []string{"turbo.json", "package.json"})
+	assert.Equal(t, len(result.Migrations), 2)
+	assert.Equal(t, result.Migrations[0].Message, `package.json: "turbo" was read as turbo.json`)
+	assert.Equal(t, result.Migrations[1].Message, `package.json: "$NODE_ENV" in turbo.pipeline.build.dependsOn was read as "NODE_ENV" in pipeline.build.env`)
+	assert.Equal(t, readFile(t, repoRoot, "turbo.json"), `{
+  "pipeline": {
+    "build": {
+      "dependsOn": ["^build"],
+      "outputs": ["dist/**"],
+      "env": ["NODE_ENV"]
+    }
+  }
+}
+`)
+	assert.Equal(t, readFile(t, repoRoot, "package.json"), `{
+  "name": "root",
+  "workspaces": ["packages/*"]
+}
+`)
+}
+
+func TestConfigEnvVarDependencies(t *testing.T) {
+	repoRoot := writeRepo(t, map[string]string{
+		"package.json": `{"name": "root"}`,
+		"turbo.json": `{
+  // Hashed into every task
+  "globalDependencies": ["$CI", "tsconfig.json"],
+  "globalEnv": ["VERCEL_ENV"],
+  "pipeline": {
+    "build": {
+      "dependsOn": ["$API_URL"] // Baked into the bundle
+    },
+    "test": {
+      "dependsOn": ["build", "$A", "$B"],
+      "env": ["A"]
+    }
+  }
+}
+`,
+	})
+	result, err := Config(repoRoot, false)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, result.Files, []string{"turbo.json"})
+	assert.Equal(t, len(result.Migrations), 4)
+	assert.Equal(t, readFile(t, repoRoot, "turbo.json"), `{
+  // Hashed into every task
+  "globalDependencies": ["tsconfig.json"],
+  "globalEnv": ["VERCEL_ENV", "CI"],
+  "pipeline": {
+    "build": {
+      "env": ["API_URL"]
+    },
+    "test": {
+      "dependsOn": ["build"],
+      "env": ["A", "B"]
+    }
+  }
+}
+`)
+	// Running it again changes nothing
+	result, err = Config(repoRoot, false)
+	assert.NilError(t, err)
+	assert.Equal(t, len(result.Migrations), 0)
+	assert.Equal(t, len(result.Files), 0)
+}
+
+func TestConfigFromSingleLinePackageJSON(t *testing.T) {
+	repoRoot := writeRepo(t, map[string]string{
+		"package.json": `{"name": "root", "turbo": {"pipeline": {"build": {}}}}` + "\n",
+	})
+	_, err := Config(repoRoot, false)
+	assert.NilError(t, err)
+	assert.Equal(t, readFile(t, repoRoot, "turbo.json"), `{"pipeline": {"build": {}}}`+"\n")
+	assert.Equal(t, readFile(t, repoRoot, "package.json"), `{"name": "root"}`+"\n")
+}
+
+func TestConfigIgnoredPackageJSON(t *testing.T) {
+	repoRoot := writeRepo(t, map[string]string{
+		"package.json": `{"name": "root", "turbo": {"pipeline": {"lint": {}}}}`,
+		"turbo.json":   `{"pipeline": {"build": {}}}`,
+	})
+	result, err := Config(repoRoot, false)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, result.Files, []string{"package.json"})
+	assert.Equal(t, result.Migrations[0].Message, `package.json: "turbo" is ignored, because turbo.json exists`)
+	assert.Equal(t, readFile(t, repoRoot, "package.json"), `{"name": "root"}`)
+	assert.Equal(t, readFile(t, repoRoot, "turbo.json"), `{"pipeline": {"build": {}}}`)
+}
+
+func TestConfigDryRun(t *testing.T) {
+	packageJSON := `{"name": "root", "turbo": {"pipeline": {"build": {"dependsOn": ["$A"]}}}}`
+	repoRoot := writeRepo(t, map[string]string{"package.json": packageJSON})
+	result, err := Config(repoRoot, true)
+	assert.NilError(t, err)
+	assert.Assert(t, result.DryRun)
+	assert.DeepEqual(t, result.Files, []string{"turbo.json", "package.json"})
+	assert.Equal(t, len(result.Migrations), 2)
+	assert.Equal(t, readFile(t, repoRoot, "package.json"), packageJSON)
+	assert.Assert(t, !repoRoot.UntypedJoin("turbo.json").FileExists())
+}
@@ -17,13 +17,25 @@ type PackageTask struct {
 	PackageName    string
 	Pkg            *fs.PackageJSON
 	TaskDefinition *fs.TaskDefinition
+	// BuiltinCommand is run when package.json has no script for the task, such as
+	// `tsc --build` for a TypeScript task in a workspace with a tsconfig.json
+	BuiltinCommand string
 }
 
-// Command returns the script for this task from package.json and a boolean indicating
-// whether or not it exists
+// Command returns the script for this task from package.json, or its builtin
+// command, and a boolean indicating whether or not either exists
 func (pt *PackageTask) Command() (string, bool) {
-	cmd, ok := pt.Pkg.Scripts[pt.Task]
-	return cmd, ok
+	if cmd, ok := pt.Pkg.Scripts[pt.Task]; ok {
+		return cmd, true
+	}
+	return pt.BuiltinCommand, pt.BuiltinCommand != ""
+}
+
+// UsesBuiltinCommand returns true if the task runs its builtin command, rather than
+// a script from package.json
+func (pt *PackageTask) UsesBuiltinCommand() bool {
+	_, ok := pt.Pkg.Scripts[pt.Task]
+	return !ok && pt.BuiltinCommand != ""
 }
 
 // OutputPrefix returns the prefix to be used for logging and ui for this task
@@ -159,6 +159,12 @@ func (pm PackageManager) GetWorkspaces(rootpath turbopath.AbsoluteSystemPath) ([
 	return f, nil
 }
 
+// GetWorkspaceGlobs returns the globs that workspaces are found with, as written in the
+// workspace configuration.
+func (pm PackageManager) GetWorkspaceGlobs(rootpath turbopath.AbsoluteSystemPath) ([]string, error) {
+	return pm.getWorkspaceGlobs(rootpath)
+}
+
 // GetWorkspaceIgnores returns an array of globs not to search for workspaces.
 func (pm PackageManager) GetWorkspaceIgnores(rootpath turbopath.AbsoluteSystemPath) ([]string, error) {
 	return pm.getWorkspaceIgnores(pm, rootpath)
@@ -1,6 +1,7 @@
 package process
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -30,15 +31,18 @@ func (ce *ChildExit) Error() string {
 type Manager struct {
 	done     bool
 	children map[*Child]struct{}
-	mu       sync.Mutex
-	doneCh   chan struct{}
-	logger   hclog.Logger
+	// cancels stops work that isn't a child process, see ExecFunc
+	cancels map[*context.CancelFunc]struct{}
+	mu      sync.Mutex
+	doneCh  chan struct{}
+	logger  hclog.Logger
 }
 
 // NewManager creates a new properly-initialized Manager instance
 func NewManager(logger hclog.Logger) *Manager {
 	return &Manager{
 		children: make(map[*Child]struct{}),
+		cancels:  make(map[*context.CancelFunc]struct{}),
 		doneCh:   make(chan struct{}),
 		logger:   logger,
 	}
@@ -95,6 +99,31 @@ func (m *Manager) Exec(cmd *exec.Cmd) error {
 	return err
 }
 
+// ExecFunc runs work that happens outside of a child process, such as on another
+// machine, as though it were one: the context passed to fn is cancelled when the
+// manager closes, in which case ExecFunc returns ErrClosing.
+func (m *Manager) ExecFunc(fn func(ctx context.Context) error) error {
+	m.mu.Lock()
+	if m.done {
+		m.mu.Unlock()
+		return ErrClosing
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	m.cancels[&cancel] = struct{}{}
+	m.mu.Unlock()
+
+	err := fn(ctx)
+
+	m.mu.Lock()
+	delete(m.cancels, &cancel)
+	m.mu.Unlock()
+	if ctx.Err() != nil {
+		return ErrClosing
+	}
+	return err
+}
+
 // Close sends SIGINT to all child processes if it hasn't been done yet,
 // and in either case blocks until they all exit or timeout
 func (m *Manager) Close() {
@@ -106,6 +135,9 @@ func (m *Manager) Close() {
 	}
 	wg := sync.WaitGroup{}
 	m.done = true
+	for cancel := range m.cancels {
+		(*cancel)()
+	}
 	for child := range m.children {
 		child := child
 		wg.Add(1)
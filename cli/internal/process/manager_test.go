@@ -1,6 +1,7 @@
 package process
 
 import (
+	"context"
 	"errors"
 	"os/exec"
 	"sync"
@@ -92,3 +93,30 @@ func TestExitCode(t *testing.T) {
 		t.Error("expected non-zero exit code , got 0")
 	}
 }
+
+func TestExecFunc_close(t *testing.T) {
+	mgr := newManager()
+
+	started := make(chan struct{})
+	result := make(chan error)
+	go func() {
+		result <- mgr.ExecFunc(func(ctx context.Context) error {
+			close(started)
+			<-ctx.Done()
+			return ctx.Err()
+		})
+	}()
+	<-started
+	mgr.Close()
+	if err := <-result; err != ErrClosing {
+		t.Errorf("expected manager closing error, found %q", err)
+	}
+
+	err := mgr.ExecFunc(func(ctx context.Context) error {
+		t.Error("expected ExecFunc not to run after closing")
+		return nil
+	})
+	if err != ErrClosing {
+		t.Errorf("expected manager closing error, found %q", err)
+	}
+}
@@ -3,12 +3,15 @@ package prune
 import (
 	"bufio"
 	"fmt"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	"github.com/vercel/turbo/cli/internal/cmdutil"
 	"github.com/vercel/turbo/cli/internal/context"
+	"github.com/vercel/turbo/cli/internal/errcode"
 	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/hashing"
 	"github.com/vercel/turbo/cli/internal/turbopath"
 	"github.com/vercel/turbo/cli/internal/ui"
 
@@ -51,8 +54,8 @@ func GetCmd(helper *cmdutil.Helper) *cobra.Command {
 				return err
 			}
 			if opts.scope == "" {
-				err := errors.New("at least one target must be specified")
-				base.LogError(err.Error())
+				err := errcode.Wrap(errcode.InvalidArguments, errors.New("at least one target must be specified"))
+				base.LogError("%w", err)
 				return err
 			}
 			p := &prune{
@@ -119,6 +122,17 @@ func (p *prune) prune(opts *opts) error {
 		return errors.New("Cannot prune without parsed lockfile")
 	}
 
+	targets := []interface{}{opts.scope}
+	internalDeps, err := ctx.TopologicalGraph.Ancestors(opts.scope)
+	if err != nil {
+		return errors.Wrap(err, "could find traverse the dependency graph to find topological dependencies")
+	}
+	targets = append(targets, internalDeps.List()...)
+
+	if err := checkMaterialized(p.base.RepoRoot, ctx, targets); err != nil {
+		return err
+	}
+
 	p.base.UI.Output(fmt.Sprintf("Generating pruned monorepo for %v in %v", ui.Bold(opts.scope), ui.Bold(outDir.ToString())))
 
 	packageJSONPath := outDir.UntypedJoin("package.json")
@@ -140,12 +154,6 @@ func (p *prune) prune(opts *opts) error {
 		}
 	}
 	workspaces := []turbopath.AnchoredSystemPath{}
-	targets := []interface{}{opts.scope}
-	internalDeps, err := ctx.TopologicalGraph.Ancestors(opts.scope)
-	if err != nil {
-		return errors.Wrap(err, "could find traverse the dependency graph to find topological dependencies")
-	}
-	targets = append(targets, internalDeps.List()...)
 
 	lockfileKeys := make([]string, 0, len(rootPackageJSON.TransitiveDeps))
 	lockfileKeys = append(lockfileKeys, rootPackageJSON.TransitiveDeps...)
@@ -277,3 +285,36 @@ func (p *prune) prune(opts *opts) error {
 
 	return nil
 }
+
+// checkMaterialized makes sure that every workspace prune needs to copy is fully
+// present on disk before anything is written. In a sparse checkout, files outside of
+// the checked out paths are in git but not in the working tree.
+func checkMaterialized(repoRoot turbopath.AbsoluteSystemPath, ctx *context.Context, targets []interface{}) error {
+	var missing []string
+	for _, target := range targets {
+		if target == ctx.RootNode {
+			continue
+		}
+		pkgDir := ctx.PackageInfos[target].Dir.RestoreAnchor(repoRoot)
+		// This fails outside of a git repository, where nothing can be sparsely checked out.
+		skipped, err := hashing.GetSkipWorktreeFiles(pkgDir)
+		if err != nil {
+			continue
+		}
+		for _, file := range skipped {
+			if !file.ToSystemPath().RestoreAnchor(pkgDir).Exists() {
+				missing = append(missing, ctx.PackageInfos[target].Dir.ToString())
+				break
+			}
+		}
+	}
+	for _, patch := range ctx.Lockfile.Patches() {
+		if !patch.ToSystemPath().RestoreAnchor(repoRoot).Exists() {
+			missing = append(missing, patch.ToString())
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	return errors.Errorf("cannot prune, the following paths are not fully checked out: %v. If this is a sparse checkout, add them with `git sparse-checkout add`", strings.Join(missing, ", "))
+}
@@ -0,0 +1,117 @@
+package run
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// taskArgs are the arguments forwarded to particular tasks, as given by --args-for
+type taskArgs struct {
+	raw []string
+	// args are the arguments for each task name or package task ID, in the order given
+	args map[string][]string
+}
+
+var _ pflag.Value = &taskArgs{}
+
+// String implements pflag.Value.String for taskArgs
+func (ta *taskArgs) String() string {
+	return strings.Join(ta.raw, ",")
+}
+
+// Set implements pflag.Value.Set for taskArgs. Values are of the form
+// "<task>=<args>", where <task> is a task name, such as "test", or a package
+// task, such as "web#test", and <args> are split like a shell would split them.
+func (ta *taskArgs) Set(value string) error {
+	task, args, ok := strings.Cut(value, "=")
+	task = strings.TrimSpace(task)
+	if !ok || task == "" {
+		return fmt.Errorf("invalid value %q, expected %q", value, "<task>=<args>")
+	}
+	split, err := splitArgs(args)
+	if err != nil {
+		return fmt.Errorf("invalid arguments for %v: %w", task, err)
+	}
+	if ta.args == nil {
+		ta.args = make(map[string][]string)
+	}
+	ta.args[task] = append(ta.args[task], split...)
+	ta.raw = append(ta.raw, value)
+	return nil
+}
+
+// Type implements pflag.Value.Type for taskArgs
+func (ta *taskArgs) Type() string {
+	return "<task>=<args>"
+}
+
+// forTask returns the arguments forwarded to taskID, both those given for its
+// task name and those given for it specifically
+func (ta *taskArgs) forTask(taskID string) []string {
+	_, task := util.GetPackageTaskFromId(taskID)
+	var args []string
+	args = append(args, ta.args[task]...)
+	if taskID != task {
+		args = append(args, ta.args[taskID]...)
+	}
+	return args
+}
+
+// splitArgs splits a string into arguments the way a POSIX shell would, honoring
+// single quotes, double quotes, and backslash escapes, without expanding anything
+func splitArgs(s string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	inArg := false
+	var quote rune
+	escaped := false
+	for _, r := range s {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else if r == '\\' {
+				escaped = true
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\\':
+			escaped = true
+			inArg = true
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if escaped {
+		return nil, fmt.Errorf("trailing backslash in %q", s)
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote in %q", quote, s)
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
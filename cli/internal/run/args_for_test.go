@@ -0,0 +1,56 @@
+package run
+
+import (
+	"testing"
+
+	"github.com/spf13/pflag"
+	"gotest.tools/v3/assert"
+)
+
+func TestSplitArgs(t *testing.T) {
+	cases := map[string][]string{
+		"":                             nil,
+		"--runInBand":                  {"--runInBand"},
+		"  --runInBand   --ci ":        {"--runInBand", "--ci"},
+		`--grep "button click" --bail`: {"--grep", "button click", "--bail"},
+		`--name='it''s' -t a\ b`:       {"--name=its", "-t", "a b"},
+		`"" --empty`:                   {"", "--empty"},
+		`"say \"hi\"" '\n'`:            {`say "hi"`, `\n`},
+	}
+	for input, expected := range cases {
+		args, err := splitArgs(input)
+		assert.NilError(t, err, input)
+		assert.DeepEqual(t, args, expected)
+	}
+
+	_, err := splitArgs(`--grep "button`)
+	assert.ErrorContains(t, err, `unterminated " quote`)
+	_, err = splitArgs(`--grep \`)
+	assert.ErrorContains(t, err, "trailing backslash")
+}
+
+func TestArgsFor(t *testing.T) {
+	var opts runOpts
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	addRunOpts(&opts, flags, make(map[string]string))
+	assert.NilError(t, flags.Parse([]string{
+		"--args-for=test=--runInBand",
+		"--args-for", "web#test=--coverage --ci",
+		"--args-for=lint=--fix",
+	}))
+	opts.passThroughArgs = []string{"--verbose"}
+	rs := &runSpec{
+		Targets: []string{"build", "test"},
+		Opts:    &Opts{runOpts: opts},
+	}
+	assert.DeepEqual(t, rs.ArgsForTask("ui#test"), []string{"--verbose", "--runInBand"})
+	assert.DeepEqual(t, rs.ArgsForTask("web#test"), []string{"--verbose", "--runInBand", "--coverage", "--ci"})
+	assert.DeepEqual(t, rs.ArgsForTask("web#build"), []string{"--verbose"})
+	// Forwarded to tasks that run as dependencies, too
+	assert.DeepEqual(t, rs.ArgsForTask("web#lint"), []string{"--fix"})
+
+	for _, value := range []string{"--runInBand", "=--runInBand", "test=--grep 'button"} {
+		var ta taskArgs
+		assert.Assert(t, ta.Set(value) != nil, value)
+	}
+}
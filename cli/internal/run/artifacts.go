@@ -0,0 +1,157 @@
+package run
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/mitchellh/cli"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/globby"
+	"github.com/vercel/turbo/cli/internal/nodes"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// artifactsDir is the repo-relative directory that the artifacts of each run are
+// collected into, in a directory named after the run's ID, unless --artifacts-dir is set
+var artifactsDir = []string{".turbo", "artifacts"}
+
+// artifactsManifestFile is the name of the file in the artifacts directory that
+// lists the artifacts of each task
+const artifactsManifestFile = "artifacts.json"
+
+// artifactsManifest is the contents of artifactsManifestFile
+type artifactsManifest struct {
+	RunID string `json:"runId"`
+	// Tasks maps task IDs to the paths of their artifacts, relative to the
+	// artifacts directory
+	Tasks map[string][]string `json:"tasks"`
+}
+
+// pipelineHasArtifacts is true if any task in the pipeline declares artifacts
+func pipelineHasArtifacts(pipeline fs.Pipeline) bool {
+	for _, taskDefinition := range pipeline {
+		if len(taskDefinition.Artifacts) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// taskArtifacts copies the files matching the artifacts of each task, once it has
+// completed, into a directory for the run. Unlike outputs, artifacts are never
+// cached, so that CI can upload reports from a single place.
+type taskArtifacts struct {
+	repoRoot turbopath.AbsoluteSystemPath
+	dir      turbopath.AbsoluteSystemPath
+	runID    string
+	mu       sync.Mutex
+	files    map[string][]string
+}
+
+func newTaskArtifacts(repoRoot turbopath.AbsoluteSystemPath, dir turbopath.AbsoluteSystemPath, runID string) *taskArtifacts {
+	return &taskArtifacts{
+		repoRoot: repoRoot,
+		dir:      dir,
+		runID:    runID,
+		files:    make(map[string][]string),
+	}
+}
+
+// collect copies the artifacts of packageTask into <dir>/<package>/<task>, keeping
+// their paths relative to the package
+func (ta *taskArtifacts) collect(packageTask *nodes.PackageTask) error {
+	globs := packageTask.TaskDefinition.Artifacts
+	if len(globs) == 0 {
+		return nil
+	}
+	var inclusions, exclusions []string
+	for _, glob := range globs {
+		if strings.HasPrefix(glob, "!") {
+			exclusions = append(exclusions, glob[1:])
+		} else {
+			inclusions = append(inclusions, glob)
+		}
+	}
+	pkgDir := ta.repoRoot.UntypedJoin(packageTask.Pkg.Dir.ToStringDuringMigration())
+	matches, err := globby.GlobFiles(pkgDir.ToString(), inclusions, exclusions)
+	if err != nil {
+		return err
+	}
+	taskDir := ta.dir.UntypedJoin(filepath.FromSlash(packageTask.PackageName), packageTask.Task)
+	var collected []string
+	for _, match := range matches {
+		relativePath, err := filepath.Rel(pkgDir.ToString(), match)
+		if err != nil {
+			return err
+		}
+		from := &fs.LstatCachedFile{Path: fs.UnsafeToAbsoluteSystemPath(match)}
+		to := taskDir.UntypedJoin(relativePath)
+		if err := fs.CopyFile(from, to.ToString()); err != nil {
+			return fmt.Errorf("failed to copy artifact %v: %w", relativePath, err)
+		}
+		manifestPath, err := ta.dir.RelativePathString(to.ToString())
+		if err != nil {
+			return err
+		}
+		collected = append(collected, filepath.ToSlash(manifestPath))
+	}
+	ta.mu.Lock()
+	defer ta.mu.Unlock()
+	// A task that is retried is collected again, and only its last attempt counts
+	ta.files[packageTask.TaskID] = collected
+	return nil
+}
+
+// manifest returns the artifacts collected so far
+func (ta *taskArtifacts) manifest() *artifactsManifest {
+	ta.mu.Lock()
+	defer ta.mu.Unlock()
+	manifest := &artifactsManifest{RunID: ta.runID, Tasks: make(map[string][]string)}
+	for taskID, files := range ta.files {
+		if len(files) > 0 {
+			manifest.Tasks[taskID] = append([]string{}, files...)
+		}
+	}
+	return manifest
+}
+
+// writeManifest writes the list of artifacts alongside them, if there are any
+func (ta *taskArtifacts) writeManifest() error {
+	manifest := ta.manifest()
+	if len(manifest.Tasks) == 0 {
+		return nil
+	}
+	bytes, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := ta.dir.UntypedJoin(artifactsManifestFile)
+	if err := path.EnsureDir(); err != nil {
+		return err
+	}
+	return path.WriteFile(bytes, 0644)
+}
+
+// print lists the artifacts of each task, as part of the summary of the run
+func (ta *taskArtifacts) print(terminal cli.Ui) {
+	manifest := ta.manifest()
+	if len(manifest.Tasks) == 0 {
+		return
+	}
+	taskIDs := make([]string, 0, len(manifest.Tasks))
+	count := 0
+	for taskID, files := range manifest.Tasks {
+		taskIDs = append(taskIDs, taskID)
+		count += len(files)
+	}
+	sort.Strings(taskIDs)
+	terminal.Output(util.Sprintf("${BOLD}Artifacts: %v files from %v tasks${RESET}${GRAY} in %v${RESET}", count, len(taskIDs), ta.dir))
+	for _, taskID := range taskIDs {
+		terminal.Output(util.Sprintf("${GREY}           %v: %v${RESET}", taskID, strings.Join(manifest.Tasks[taskID], ", ")))
+	}
+}
@@ -0,0 +1,67 @@
+package run
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/nodes"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"gotest.tools/v3/assert"
+)
+
+func TestTaskArtifacts(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	for _, file := range []string{"coverage/lcov.info", "coverage/tmp/raw.json", "bundle-stats.json", "src/index.ts"} {
+		path := repoRoot.UntypedJoin("packages", "web", filepath.FromSlash(file))
+		assert.NilError(t, path.EnsureDir(), "EnsureDir")
+		assert.NilError(t, path.WriteFile([]byte(file), 0644), "WriteFile")
+	}
+	pkg := &fs.PackageJSON{Dir: turbopath.AnchoredSystemPath(filepath.FromSlash("packages/web"))}
+
+	dir := repoRoot.UntypedJoin(".turbo", "artifacts", "run-1")
+	ta := newTaskArtifacts(repoRoot, dir, "run-1")
+	assert.NilError(t, ta.collect(&nodes.PackageTask{
+		TaskID:      "web#test",
+		Task:        "test",
+		PackageName: "web",
+		Pkg:         pkg,
+		TaskDefinition: &fs.TaskDefinition{
+			Artifacts: []string{"coverage/**", "!coverage/tmp/**", "bundle-stats.json"},
+		},
+	}), "collect")
+	// tasks without artifacts are left out of the manifest
+	assert.NilError(t, ta.collect(&nodes.PackageTask{
+		TaskID:         "web#lint",
+		Task:           "lint",
+		PackageName:    "web",
+		Pkg:            pkg,
+		TaskDefinition: &fs.TaskDefinition{},
+	}), "collect")
+
+	contents, err := dir.UntypedJoin("web", "test", "coverage", "lcov.info").ReadFile()
+	assert.NilError(t, err, "ReadFile")
+	assert.Equal(t, string(contents), "coverage/lcov.info")
+	assert.Assert(t, !dir.UntypedJoin("web", "test", "coverage", "tmp", "raw.json").FileExists(), "expected excluded artifact not to be collected")
+
+	assert.NilError(t, ta.writeManifest(), "writeManifest")
+	contents, err = dir.UntypedJoin(artifactsManifestFile).ReadFile()
+	assert.NilError(t, err, "ReadFile")
+	var manifest artifactsManifest
+	assert.NilError(t, json.Unmarshal(contents, &manifest), "Unmarshal")
+	assert.DeepEqual(t, manifest, artifactsManifest{
+		RunID: "run-1",
+		Tasks: map[string][]string{
+			"web#test": {"web/test/bundle-stats.json", "web/test/coverage/lcov.info"},
+		},
+	})
+}
+
+func TestTaskArtifactsWithoutArtifacts(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	dir := repoRoot.UntypedJoin(".turbo", "artifacts", "run-1")
+	ta := newTaskArtifacts(repoRoot, dir, "run-1")
+	assert.NilError(t, ta.writeManifest(), "writeManifest")
+	assert.Assert(t, !dir.Exists(), "expected no artifacts directory when nothing was collected")
+}
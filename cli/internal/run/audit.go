@@ -0,0 +1,375 @@
+package run
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pyr-sh/dag"
+	"github.com/vercel/turbo/cli/internal/core"
+	"github.com/vercel/turbo/cli/internal/doublestar"
+	"github.com/vercel/turbo/cli/internal/hashing"
+	"github.com/vercel/turbo/cli/internal/nodes"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// _auditedCalls are the system calls that strace records for --audit
+const _auditedCalls = "trace=open,openat,openat2,creat"
+
+// _maxAuditPaths is the number of paths listed for each finding
+const _maxAuditPaths = 3
+
+// auditFinding is a change to turbo.json that would make a task's hash account
+// for files that it read
+type auditFinding struct {
+	TaskID     string
+	Suggestion string
+	// Paths are the repo-relative paths of the files that the change would account for
+	Paths []string
+}
+
+// inputAudit traces the files that tasks open, to find the ones that their hash
+// doesn't account for: files of their workspace that aren't inputs, files of other
+// workspaces that no task in dependsOn covers, and files outside of any workspace
+// that aren't in globalDependencies.
+type inputAudit struct {
+	repoRoot   turbopath.AbsoluteSystemPath
+	graph      *completeGraph
+	taskGraph  *dag.AcyclicGraph
+	globalDeps []string
+	stracePath string
+
+	mu       sync.Mutex
+	findings []auditFinding
+}
+
+// newInputAudit returns an error if tracing isn't available on this machine
+func newInputAudit(repoRoot turbopath.AbsoluteSystemPath, graph *completeGraph, taskGraph *dag.AcyclicGraph, globalDeps []string) (*inputAudit, error) {
+	if runtime.GOOS != "linux" {
+		return nil, fmt.Errorf("--audit requires strace, which is only available on Linux")
+	}
+	stracePath, err := exec.LookPath("strace")
+	if err != nil {
+		return nil, fmt.Errorf("--audit requires strace, which was not found: %w", err)
+	}
+	return &inputAudit{
+		repoRoot:   repoRoot,
+		graph:      graph,
+		taskGraph:  taskGraph,
+		globalDeps: globalDeps,
+		stracePath: stracePath,
+	}, nil
+}
+
+// wrap makes cmd run under strace, and returns the file that the trace is written to
+func (ia *inputAudit) wrap(cmd *exec.Cmd) (turbopath.AbsoluteSystemPath, error) {
+	file, err := os.CreateTemp("", "turbo-audit-*.trace")
+	if err != nil {
+		return "", err
+	}
+	if err := file.Close(); err != nil {
+		return "", err
+	}
+	tracePath := turbopath.AbsoluteSystemPathFromUpstream(file.Name())
+	args := []string{ia.stracePath, "-f", "-qq", "-e", _auditedCalls, "-o", tracePath.ToString(), "--", cmd.Path}
+	cmd.Args = append(args, cmd.Args[1:]...)
+	cmd.Path = ia.stracePath
+	return tracePath, nil
+}
+
+// record analyzes the trace of a task that succeeded
+func (ia *inputAudit) record(packageTask *nodes.PackageTask, dir string, tracePath turbopath.AbsoluteSystemPath) error {
+	defer func() { _ = tracePath.Remove() }()
+	file, err := tracePath.Open()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+	reads, writes, err := parseTrace(file, dir)
+	if err != nil {
+		return err
+	}
+	hashed, err := hashing.GetPackageDeps(ia.repoRoot, &hashing.PackageDepsOptions{
+		PackagePath:     packageTask.Pkg.Dir,
+		InputPatterns:   packageTask.TaskDefinition.Inputs,
+		ExcludePatterns: ia.graph.HashExclude,
+	})
+	if err != nil {
+		return err
+	}
+	findings, err := ia.analyze(packageTask, reads, writes, hashed)
+	if err != nil {
+		return err
+	}
+	ia.mu.Lock()
+	defer ia.mu.Unlock()
+	ia.findings = append(ia.findings, findings...)
+	return nil
+}
+
+// results returns the findings for every audited task, ordered by task
+func (ia *inputAudit) results() []auditFinding {
+	ia.mu.Lock()
+	defer ia.mu.Unlock()
+	findings := append([]auditFinding{}, ia.findings...)
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].TaskID < findings[j].TaskID
+	})
+	return findings
+}
+
+// analyze returns a finding for each group of files that a task read, but that its
+// hash doesn't account for. hashed holds the files of the task's workspace that are
+// part of its hash, relative to the workspace.
+func (ia *inputAudit) analyze(packageTask *nodes.PackageTask, reads []string, writes util.Set, hashed map[turbopath.AnchoredUnixPath]string) ([]auditFinding, error) {
+	dependencies, err := ia.dependencyPackages(packageTask.TaskID)
+	if err != nil {
+		return nil, err
+	}
+	workspaces := ia.workspaceDirs()
+	var order []string
+	byFinding := make(map[string]*auditFinding)
+	add := func(suggestion string, path string) {
+		finding, ok := byFinding[suggestion]
+		if !ok {
+			finding = &auditFinding{TaskID: packageTask.TaskID, Suggestion: suggestion}
+			byFinding[suggestion] = finding
+			order = append(order, suggestion)
+		}
+		finding.Paths = append(finding.Paths, path)
+	}
+
+	seen := make(util.Set)
+	for _, read := range reads {
+		if seen.Includes(read) || writes.Includes(read) {
+			continue
+		}
+		seen.Add(read)
+		relativePath, err := filepath.Rel(ia.repoRoot.ToString(), read)
+		if err != nil || relativePath == ".." || strings.HasPrefix(relativePath, ".."+string(filepath.Separator)) {
+			// Files outside of the repository, such as the system's, are not our concern
+			continue
+		}
+		unixPath := filepath.ToSlash(relativePath)
+		if isUnauditedPath(unixPath) {
+			continue
+		}
+		workspace, workspaceDir := workspaceOf(workspaces, unixPath)
+		switch {
+		case workspace == packageTask.PackageName:
+			packagePath := strings.TrimPrefix(unixPath, workspaceDir+"/")
+			if workspace == util.RootPkgName {
+				packagePath = unixPath
+			}
+			if _, ok := hashed[turbopath.AnchoredUnixPath(packagePath)]; ok || matchesAny(packageTask.TaskDefinition.Outputs.Inclusions, packagePath) {
+				continue
+			}
+			if len(packageTask.TaskDefinition.Inputs) == 0 {
+				add("commit it, or stop ignoring it in .gitignore: files ignored by git aren't part of the hash", unixPath)
+			} else {
+				add(fmt.Sprintf("add %q to inputs", packagePath), unixPath)
+			}
+		case workspace == util.RootPkgName:
+			if matchesAny(ia.globalDeps, unixPath) || isGlobalFile(unixPath) {
+				continue
+			}
+			add(fmt.Sprintf("add %q to globalDependencies", unixPath), unixPath)
+		default:
+			if dependencies.Includes(workspace) {
+				continue
+			}
+			add(fmt.Sprintf("add a task in %v, such as %q, to dependsOn", workspace, ia.suggestedDependency(packageTask, workspace)), unixPath)
+		}
+	}
+
+	findings := make([]auditFinding, len(order))
+	for i, suggestion := range order {
+		findings[i] = *byFinding[suggestion]
+	}
+	return findings, nil
+}
+
+// dependencyPackages returns the workspaces of the tasks that taskID depends on
+func (ia *inputAudit) dependencyPackages(taskID string) (util.Set, error) {
+	packages := make(util.Set)
+	ancestors, err := ia.taskGraph.Ancestors(taskID)
+	if err != nil {
+		return nil, err
+	}
+	for _, ancestor := range ancestors {
+		ancestorID := ancestor.(string)
+		if strings.Contains(ancestorID, core.ROOT_NODE_NAME) {
+			continue
+		}
+		pkg, _ := util.GetPackageTaskFromId(ancestorID)
+		packages.Add(pkg)
+	}
+	return packages, nil
+}
+
+// suggestedDependency returns the dependsOn entry for a task to depend on the same
+// task in another workspace, using ^ if the workspace is a dependency
+func (ia *inputAudit) suggestedDependency(packageTask *nodes.PackageTask, workspace string) string {
+	dependencies, err := ia.graph.TopologicalGraph.Ancestors(packageTask.PackageName)
+	if err == nil && dependencies.Include(workspace) {
+		return "^" + packageTask.Task
+	}
+	return util.GetTaskId(workspace, packageTask.Task)
+}
+
+type workspaceDir struct {
+	name string
+	dir  string
+}
+
+// workspaceDirs returns the repo-relative directories of every workspace but the
+// root, deepest first
+func (ia *inputAudit) workspaceDirs() []workspaceDir {
+	var dirs []workspaceDir
+	for name, pkg := range ia.graph.PackageInfos {
+		if name == util.RootPkgName {
+			continue
+		}
+		dirs = append(dirs, workspaceDir{name: name.(string), dir: filepath.ToSlash(pkg.Dir.ToStringDuringMigration())})
+	}
+	sort.Slice(dirs, func(i, j int) bool {
+		return len(dirs[i].dir) > len(dirs[j].dir)
+	})
+	return dirs
+}
+
+// workspaceOf returns the workspace that contains a repo-relative path, and its directory
+func workspaceOf(workspaces []workspaceDir, unixPath string) (string, string) {
+	for _, workspace := range workspaces {
+		if strings.HasPrefix(unixPath, workspace.dir+"/") {
+			return workspace.name, workspace.dir
+		}
+	}
+	return util.RootPkgName, ""
+}
+
+// isUnauditedPath returns whether reading a file says nothing about missing inputs:
+// installed dependencies are accounted for by the lockfile, and turbo's own files
+// and git's aren't inputs
+func isUnauditedPath(unixPath string) bool {
+	for _, segment := range strings.Split(unixPath, "/") {
+		if segment == "node_modules" || segment == ".git" || segment == ".turbo" {
+			return true
+		}
+	}
+	return false
+}
+
+// isGlobalFile returns whether a file at the root of the repository is always part
+// of the global hash
+func isGlobalFile(unixPath string) bool {
+	switch unixPath {
+	case "package.json", "turbo.json", "package-lock.json", "yarn.lock", "pnpm-lock.yaml", "pnpm-workspace.yaml":
+		return true
+	}
+	return false
+}
+
+func matchesAny(globs []string, unixPath string) bool {
+	for _, glob := range globs {
+		if matched, err := doublestar.Match(filepath.ToSlash(glob), unixPath); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	// _traceCall matches a system call that opens a file, e.g.
+	// 123 openat(AT_FDCWD, "/repo/a.js", O_RDONLY|O_CLOEXEC) = 3
+	_traceCall = regexp.MustCompile(`^(\d+) +(open|openat|openat2|creat)\((?:[^"]*?, )?"((?:[^"\\]|\\.)*)"(.*)$`)
+	// _traceResumed matches the end of a call that was interrupted by another process, e.g.
+	// 123 <... openat resumed>) = 3
+	_traceResumed = regexp.MustCompile(`^(\d+) +<\.\.\. (open|openat|openat2|creat) resumed>(.*)$`)
+	_traceResult  = regexp.MustCompile(`= (-?\d+)`)
+)
+
+type tracedOpen struct {
+	call  string
+	path  string
+	flags string
+}
+
+// parseTrace returns the absolute paths of the files that were successfully opened
+// for reading, and the set of those that were opened for writing, from the output
+// of strace -f. Relative paths are resolved against dir.
+func parseTrace(r io.Reader, dir string) ([]string, util.Set, error) {
+	var reads []string
+	writes := make(util.Set)
+	pending := make(map[string]tracedOpen)
+	finish := func(open tracedOpen, rest string) {
+		match := _traceResult.FindStringSubmatch(rest)
+		if match == nil {
+			return
+		}
+		if fd, err := strconv.Atoi(match[1]); err != nil || fd < 0 {
+			return
+		}
+		path := open.path
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		path = filepath.Clean(path)
+		if open.call == "creat" || strings.Contains(open.flags, "O_WRONLY") || strings.Contains(open.flags, "O_RDWR") || strings.Contains(open.flags, "O_CREAT") {
+			writes.Add(path)
+		} else if !strings.Contains(open.flags, "O_DIRECTORY") {
+			reads = append(reads, path)
+		}
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if match := _traceCall.FindStringSubmatch(line); match != nil {
+			path, err := strconv.Unquote(`"` + match[3] + `"`)
+			if err != nil {
+				path = match[3]
+			}
+			open := tracedOpen{call: match[2], path: path, flags: match[4]}
+			if strings.Contains(match[4], "<unfinished ...>") {
+				pending[match[1]] = open
+			} else {
+				finish(open, match[4])
+			}
+		} else if match := _traceResumed.FindStringSubmatch(line); match != nil {
+			if open, ok := pending[match[1]]; ok {
+				delete(pending, match[1])
+				finish(open, match[3])
+			}
+		}
+	}
+	return reads, writes, scanner.Err()
+}
+
+// describePaths lists the paths of a finding, abbreviating long lists
+func (f auditFinding) describePaths() string {
+	if len(f.Paths) > _maxAuditPaths {
+		return fmt.Sprintf("%v and %v more", strings.Join(f.Paths[:_maxAuditPaths], ", "), len(f.Paths)-_maxAuditPaths)
+	}
+	return strings.Join(f.Paths, ", ")
+}
+
+// auditedTasks returns the number of distinct tasks with findings
+func auditedTasks(findings []auditFinding) int {
+	tasks := make(util.Set)
+	for _, finding := range findings {
+		tasks.Add(finding.TaskID)
+	}
+	return tasks.Len()
+}
@@ -0,0 +1,113 @@
+package run
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pyr-sh/dag"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/nodes"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/util"
+	"gotest.tools/v3/assert"
+)
+
+func Test_parseTrace(t *testing.T) {
+	trace := strings.Join([]string{
+		`100 openat(AT_FDCWD, "/repo/apps/web/src/index.ts", O_RDONLY|O_CLOEXEC) = 3`,
+		`100 openat(AT_FDCWD, "/repo/apps/web/missing.ts", O_RDONLY|O_CLOEXEC) = -1 ENOENT (No such file or directory)`,
+		`100 openat(AT_FDCWD, "/repo/apps/web", O_RDONLY|O_NONBLOCK|O_CLOEXEC|O_DIRECTORY) = 4`,
+		`101 openat(AT_FDCWD, "dist/index.js", O_WRONLY|O_CREAT|O_TRUNC|O_CLOEXEC, 0666 <unfinished ...>`,
+		`100 open("tsconfig.json", O_RDONLY) = 5`,
+		`101 <... openat resumed>) = 6`,
+		`102 openat2(AT_FDCWD, "/repo/.env", {flags=O_RDONLY|O_CLOEXEC, resolve=0}, 24) = 7`,
+		`103 creat("/repo/apps/web/out.txt", 0644) = 8`,
+		`103 openat(AT_FDCWD, "/repo/apps/web/with \"quotes\".txt", O_RDONLY) = 9`,
+		`103 +++ exited with 0 +++`,
+	}, "\n")
+	reads, writes, err := parseTrace(strings.NewReader(trace), "/repo/apps/web")
+	assert.NilError(t, err, "parseTrace")
+	assert.DeepEqual(t, reads, []string{
+		filepath.FromSlash("/repo/apps/web/src/index.ts"),
+		filepath.FromSlash("/repo/apps/web/tsconfig.json"),
+		filepath.FromSlash("/repo/.env"),
+		filepath.FromSlash(`/repo/apps/web/with "quotes".txt`),
+	})
+	assert.Assert(t, writes.Includes(filepath.FromSlash("/repo/apps/web/dist/index.js")))
+	assert.Assert(t, writes.Includes(filepath.FromSlash("/repo/apps/web/out.txt")))
+	assert.Equal(t, writes.Len(), 2)
+}
+
+func Test_inputAudit_analyze(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(filepath.FromSlash("/repo"))
+	topologicalGraph := dag.AcyclicGraph{}
+	for _, pkg := range []string{"web", "ui", "config", "docs"} {
+		topologicalGraph.Add(pkg)
+	}
+	topologicalGraph.Connect(dag.BasicEdge("web", "ui"))
+	topologicalGraph.Connect(dag.BasicEdge("web", "config"))
+	graph := &completeGraph{
+		TopologicalGraph: topologicalGraph,
+		PackageInfos: map[interface{}]*fs.PackageJSON{
+			util.RootPkgName: {},
+			"web":            {Dir: fs.UnsafeToAnchoredSystemPath(filepath.Join("apps", "web"))},
+			"docs":           {Dir: fs.UnsafeToAnchoredSystemPath(filepath.Join("apps", "docs"))},
+			"ui":             {Dir: fs.UnsafeToAnchoredSystemPath(filepath.Join("packages", "ui"))},
+			"config":         {Dir: fs.UnsafeToAnchoredSystemPath(filepath.Join("packages", "config"))},
+		},
+	}
+	taskGraph := &dag.AcyclicGraph{}
+	taskGraph.Add("web#build")
+	taskGraph.Add("ui#build")
+	taskGraph.Connect(dag.BasicEdge("web#build", "ui#build"))
+	ia := &inputAudit{repoRoot: repoRoot, graph: graph, taskGraph: taskGraph, globalDeps: []string{".env.*"}}
+
+	packageTask := &nodes.PackageTask{
+		TaskID:      "web#build",
+		Task:        "build",
+		PackageName: "web",
+		Pkg:         graph.PackageInfos["web"],
+		TaskDefinition: &fs.TaskDefinition{
+			Inputs:  []string{"src/**"},
+			Outputs: fs.TaskOutputs{Inclusions: []string{"dist/**"}},
+		},
+	}
+	hashed := map[turbopath.AnchoredUnixPath]string{
+		"package.json":  "a",
+		"src/index.ts":  "b",
+		"src/styles.ts": "c",
+	}
+	abs := func(path string) string {
+		return repoRoot.UntypedJoin(filepath.FromSlash(path)).ToString()
+	}
+	reads := []string{
+		abs("apps/web/src/index.ts"),
+		abs("apps/web/tsconfig.json"),
+		abs("apps/web/tsconfig.json"),
+		abs("apps/web/dist/previous.js"),
+		abs("apps/web/generated.ts"),
+		abs("apps/web/node_modules/react/index.js"),
+		abs("packages/ui/src/button.tsx"),
+		abs("packages/config/tsconfig.base.json"),
+		abs("apps/docs/content.md"),
+		abs(".env.production"),
+		abs("tsconfig.json"),
+		abs("package.json"),
+		filepath.FromSlash("/usr/lib/node/index.js"),
+	}
+	writes := make(util.Set)
+	writes.Add(abs("apps/web/generated.ts"))
+
+	findings, err := ia.analyze(packageTask, reads, writes, hashed)
+	assert.NilError(t, err, "analyze")
+	assert.DeepEqual(t, findings, []auditFinding{
+		{TaskID: "web#build", Suggestion: `add "tsconfig.json" to inputs`, Paths: []string{"apps/web/tsconfig.json"}},
+		{TaskID: "web#build", Suggestion: `add a task in config, such as "^build", to dependsOn`, Paths: []string{"packages/config/tsconfig.base.json"}},
+		{TaskID: "web#build", Suggestion: `add a task in docs, such as "docs#build", to dependsOn`, Paths: []string{"apps/docs/content.md"}},
+		{TaskID: "web#build", Suggestion: `add "tsconfig.json" to globalDependencies`, Paths: []string{"tsconfig.json"}},
+	})
+
+	finding := auditFinding{Paths: []string{"a", "b", "c", "d", "e"}}
+	assert.Equal(t, finding.describePaths(), "a, b, c and 2 more")
+}
@@ -0,0 +1,116 @@
+package run
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/mitchellh/cli"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// _maxCausalityLines is the number of skipped tasks shown under each failed task
+const _maxCausalityLines = 10
+
+// failureCausality traces the targets that were skipped because of failed
+// dependencies back to the failures that caused them
+type failureCausality struct {
+	// rootCauses are the failed targets that caused other targets to be skipped
+	rootCauses []string
+	// children are the skipped targets that each target blocked directly
+	children map[string][]string
+	skipped  int
+}
+
+// newFailureCausality returns the causality of the skipped targets in results. A
+// skipped target is shown under the targets that blocked it, or, if those have no
+// command and so aren't part of the results, under its root causes.
+func newFailureCausality(results []BuildTargetState) *failureCausality {
+	states := make(map[string]*BuildTargetState, len(results))
+	for i := range results {
+		states[results[i].Label] = &results[i]
+	}
+	fc := &failureCausality{children: make(map[string][]string)}
+	rootCauses := make(util.Set)
+	for _, result := range results {
+		if result.Status != TargetSkipped || len(result.RootCauses) == 0 {
+			continue
+		}
+		fc.skipped++
+		var parents []string
+		for _, blocker := range result.BlockedBy {
+			if _, ok := states[blocker]; ok {
+				parents = append(parents, blocker)
+			}
+		}
+		if len(parents) == 0 {
+			parents = result.RootCauses
+		}
+		for _, parent := range parents {
+			fc.children[parent] = append(fc.children[parent], result.Label)
+		}
+		for _, cause := range result.RootCauses {
+			rootCauses.Add(cause)
+		}
+	}
+	fc.rootCauses = rootCauses.UnsafeListOfStrings()
+	sort.Strings(fc.rootCauses)
+	for _, children := range fc.children {
+		sort.Strings(children)
+	}
+	return fc
+}
+
+// print writes each failed target that caused others to be skipped, with a tree of
+// the targets it blocked. A target blocked through several paths is shown once
+// under each failure, at the first place it's reached.
+func (fc *failureCausality) print(terminal cli.Ui) {
+	if len(fc.rootCauses) == 0 {
+		return
+	}
+	terminal.Output(util.Sprintf("${BOLD_RED}Skipped:   %v because of failures in:${RESET}", pluralize(fc.skipped, "task")))
+	for _, cause := range fc.rootCauses {
+		terminal.Output(util.Sprintf("           ${BOLD}%v${RESET}", cause))
+		lines := fc.tree(cause)
+		for i, line := range lines {
+			if i == _maxCausalityLines {
+				terminal.Output(util.Sprintf("${GREY}           ...and %v more${RESET}", len(lines)-i))
+				break
+			}
+			terminal.Output(util.Sprintf("${GREY}           %v${RESET}", line))
+		}
+	}
+}
+
+// tree renders the targets that cause blocked, one per line, indented by depth
+func (fc *failureCausality) tree(cause string) []string {
+	var lines []string
+	seen := make(util.Set)
+	var visit func(label string, indent string)
+	visit = func(label string, indent string) {
+		var children []string
+		for _, child := range fc.children[label] {
+			if !seen.Includes(child) {
+				seen.Add(child)
+				children = append(children, child)
+			}
+		}
+		for i, child := range children {
+			branch, next := "├── ", "│   "
+			if i == len(children)-1 {
+				branch, next = "└── ", "    "
+			}
+			lines = append(lines, indent+branch+child)
+			visit(child, indent+next)
+		}
+	}
+	visit(cause, "")
+	return lines
+}
+
+// pluralize formats a count of things
+func pluralize(count int, noun string) string {
+	if count == 1 {
+		return fmt.Sprintf("1 %v", noun)
+	}
+	return fmt.Sprintf("%v %vs", count, noun)
+}
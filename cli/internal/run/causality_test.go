@@ -0,0 +1,43 @@
+package run
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mitchellh/cli"
+	"gotest.tools/v3/assert"
+)
+
+func TestFailureCausality(t *testing.T) {
+	results := []BuildTargetState{
+		{Label: "ui#build", Status: TargetBuildFailed},
+		{Label: "icons#build", Status: TargetBuildFailed},
+		{Label: "api#build", Status: TargetBuilt},
+		{Label: "web#build", Status: TargetSkipped, BlockedBy: []string{"icons#build", "ui#build"}, RootCauses: []string{"icons#build", "ui#build"}},
+		{Label: "web#test", Status: TargetSkipped, BlockedBy: []string{"web#build"}, RootCauses: []string{"icons#build", "ui#build"}},
+		{Label: "web#deploy", Status: TargetSkipped, BlockedBy: []string{"web#build", "web#test"}, RootCauses: []string{"icons#build", "ui#build"}},
+		// blocked by a task without a command, which isn't part of the results
+		{Label: "docs#build", Status: TargetSkipped, BlockedBy: []string{"ui#prepare"}, RootCauses: []string{"ui#build"}},
+		// skipped only because another task failed, without --continue
+		{Label: "api#test", Status: TargetSkipped},
+	}
+	fc := newFailureCausality(results)
+	assert.DeepEqual(t, fc.rootCauses, []string{"icons#build", "ui#build"})
+	assert.Equal(t, fc.skipped, 4)
+	assert.DeepEqual(t, fc.tree("ui#build"), []string{
+		"├── docs#build",
+		"└── web#build",
+		"    ├── web#deploy",
+		"    └── web#test",
+	})
+
+	terminal := cli.NewMockUi()
+	fc.print(terminal)
+	output := terminal.OutputWriter.String()
+	assert.Assert(t, strings.Contains(output, "4 tasks because of failures in:"), output)
+	assert.Assert(t, strings.Contains(output, "icons#build"), output)
+
+	terminal = cli.NewMockUi()
+	newFailureCausality([]BuildTargetState{{Label: "api#test", Status: TargetSkipped}}).print(terminal)
+	assert.Equal(t, terminal.OutputWriter.String(), "")
+}
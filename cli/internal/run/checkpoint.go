@@ -0,0 +1,143 @@
+package run
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+// checkpointFile is the repo-relative location of the log of progress made by the
+// current run. It is removed when the run finishes, so finding one means that the
+// run that wrote it was interrupted.
+var checkpointFile = []string{".turbo", "run-checkpoint.jsonl"}
+
+// checkpointEvent is a step in the execution of a task
+type checkpointEvent string
+
+const (
+	checkpointStarted   checkpointEvent = "started"
+	checkpointSaved     checkpointEvent = "saved"
+	checkpointSucceeded checkpointEvent = "succeeded"
+	checkpointFailed    checkpointEvent = "failed"
+)
+
+// checkpointRecord is a single line of the checkpoint log
+type checkpointRecord struct {
+	Event  checkpointEvent `json:"event"`
+	TaskID string          `json:"taskId"`
+	Hash   string          `json:"hash"`
+	Time   time.Time       `json:"time"`
+}
+
+// checkpoint appends a record to disk as each task progresses, so that knowledge
+// of completed work survives a crash of the CLI
+type checkpoint struct {
+	mu   sync.Mutex
+	path turbopath.AbsoluteSystemPath
+	file *os.File
+}
+
+// interruptedRun summarizes the checkpoint log left behind by an interrupted run
+type interruptedRun struct {
+	// Completed is a map of taskID -> hash for tasks that succeeded, or whose
+	// outputs were saved to the cache, before the interruption
+	Completed map[string]string
+	// InProgress is the number of tasks that had started but not finished
+	InProgress int
+}
+
+// readCheckpoint returns the progress recorded by an interrupted run, or nil if
+// the previous run finished
+func readCheckpoint(repoRoot turbopath.AbsoluteSystemPath) (*interruptedRun, error) {
+	file, err := runStatePath(repoRoot, checkpointFile).Open()
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+
+	run := &interruptedRun{Completed: make(map[string]string)}
+	inProgress := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		record := &checkpointRecord{}
+		if err := json.Unmarshal(scanner.Bytes(), record); err != nil {
+			// The last record may have been cut off by the crash
+			continue
+		}
+		switch record.Event {
+		case checkpointStarted:
+			inProgress[record.TaskID] = true
+		case checkpointSaved, checkpointSucceeded:
+			delete(inProgress, record.TaskID)
+			run.Completed[record.TaskID] = record.Hash
+		case checkpointFailed:
+			delete(inProgress, record.TaskID)
+			delete(run.Completed, record.TaskID)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	run.InProgress = len(inProgress)
+	return run, nil
+}
+
+// openCheckpoint starts a new checkpoint log, replacing any existing one. The tasks
+// in completed, a map of taskID -> hash carried over from an interrupted run, are
+// recorded as succeeded first, so that they aren't lost if this run is interrupted too.
+func openCheckpoint(repoRoot turbopath.AbsoluteSystemPath, completed map[string]string) (*checkpoint, error) {
+	path := runStatePath(repoRoot, checkpointFile)
+	if err := path.EnsureDir(); err != nil {
+		return nil, err
+	}
+	file, err := path.OpenFile(os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, err
+	}
+	c := &checkpoint{path: path, file: file}
+	for taskID, hash := range completed {
+		c.record(checkpointSucceeded, taskID, hash)
+	}
+	return c, nil
+}
+
+// record appends a record to the log. Each record is written with a single
+// unbuffered write, so it is on disk as soon as record returns, even if turbo
+// itself crashes. Failing to checkpoint should not fail the run, so errors are ignored.
+func (c *checkpoint) record(event checkpointEvent, taskID string, hash string) {
+	bytes, err := json.Marshal(&checkpointRecord{
+		Event:  event,
+		TaskID: taskID,
+		Hash:   hash,
+		Time:   time.Now(),
+	})
+	if err != nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.file == nil {
+		return
+	}
+	_, _ = c.file.Write(append(bytes, '\n'))
+}
+
+// close ends the log. The run finished, so the log is no longer needed.
+func (c *checkpoint) close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.file == nil {
+		return nil
+	}
+	if err := c.file.Close(); err != nil {
+		return err
+	}
+	c.file = nil
+	return c.path.Remove()
+}
@@ -0,0 +1,138 @@
+package run
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/nodes"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// directCommand attempts to build a command that executes the package.json script for
+// packageTask directly, rather than through the package manager and a shell. It returns
+// nil if the script needs to be run by the package manager, either because it uses
+// shell syntax, has pre or post lifecycle scripts, or its executable can't be found.
+func directCommand(packageTask *nodes.PackageTask, passThroughArgs []string, repoRoot turbopath.AbsoluteSystemPath, env []string) *exec.Cmd {
+	script, ok := packageTask.Command()
+	if !ok {
+		return nil
+	}
+	if _, ok := packageTask.Pkg.Scripts["pre"+packageTask.Task]; ok {
+		return nil
+	}
+	if _, ok := packageTask.Pkg.Scripts["post"+packageTask.Task]; ok {
+		return nil
+	}
+
+	pkgDir := repoRoot.UntypedJoin(packageTask.Pkg.Dir.ToStringDuringMigration())
+	// Match the package manager, which puts the binaries of the package
+	// and every enclosing directory up to the repo root on the PATH
+	var binDirs []string
+	for dir := pkgDir.ToString(); ; dir = filepath.Dir(dir) {
+		binDirs = append(binDirs, filepath.Join(dir, "node_modules", ".bin"))
+		if dir == repoRoot.ToString() || filepath.Dir(dir) == dir {
+			break
+		}
+	}
+	env, path := prependPath(env, binDirs)
+
+	argv, ok := util.SplitScriptCommand(script, func(name string) string {
+		return lookupEnv(env, name)
+	})
+	if !ok {
+		return nil
+	}
+	executable, ok := findExecutable(argv[0], pkgDir.ToString(), filepath.SplitList(path))
+	if !ok {
+		return nil
+	}
+
+	cmd := exec.Command(executable, append(argv[1:], passThroughArgs...)...)
+	cmd.Dir = pkgDir.ToString()
+	cmd.Env = append(env, "npm_lifecycle_event="+packageTask.Task, "npm_lifecycle_script="+script)
+	cmd.Env = append(cmd.Env, npmPackageEnv(packageTask.Pkg, pkgDir)...)
+	return cmd
+}
+
+// npmPackageEnv returns the npm_package_* variables that npm sets for a script from
+// its package.json. The npm_config_* variables hold the package manager's own
+// configuration, which turbo doesn't read, so they aren't set.
+func npmPackageEnv(pkg *fs.PackageJSON, pkgDir turbopath.AbsoluteSystemPath) []string {
+	env := []string{"npm_package_json=" + pkgDir.UntypedJoin("package.json").ToString()}
+	if pkg.Name != "" {
+		env = append(env, "npm_package_name="+pkg.Name)
+	}
+	if pkg.Version != "" {
+		env = append(env, "npm_package_version="+pkg.Version)
+	}
+	config, _ := pkg.RawJSON["config"].(map[string]interface{})
+	keys := make([]string, 0, len(config))
+	for key := range config {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		switch value := config[key].(type) {
+		case string, float64, bool:
+			env = append(env, fmt.Sprintf("npm_package_config_%v=%v", key, value))
+		}
+	}
+	return env
+}
+
+// findExecutable resolves name against dir if it is a path, or searches for it in path
+func findExecutable(name string, dir string, path []string) (string, bool) {
+	if strings.ContainsAny(name, `/\`) {
+		if !filepath.IsAbs(name) {
+			name = filepath.Join(dir, name)
+		}
+		executable, err := exec.LookPath(name)
+		return executable, err == nil
+	}
+	for _, pathDir := range path {
+		if pathDir == "" {
+			continue
+		}
+		if executable, err := exec.LookPath(filepath.Join(pathDir, name)); err == nil {
+			return executable, true
+		}
+	}
+	return "", false
+}
+
+// prependPath returns env with dirs added to the front of PATH, along with the new PATH
+func prependPath(env []string, dirs []string) ([]string, string) {
+	path := strings.Join(dirs, string(os.PathListSeparator))
+	result := make([]string, 0, len(env)+1)
+	found := false
+	for _, pair := range env {
+		// Windows environment variable names are case-insensitive, and PATH is usually "Path"
+		if key, value, ok := strings.Cut(pair, "="); ok && !found && strings.EqualFold(key, "PATH") {
+			path = path + string(os.PathListSeparator) + value
+			result = append(result, key+"="+path)
+			found = true
+			continue
+		}
+		result = append(result, pair)
+	}
+	if !found {
+		result = append(result, "PATH="+path)
+	}
+	return result, path
+}
+
+func lookupEnv(env []string, name string) string {
+	// later entries win, as they do for exec.Cmd
+	for i := len(env) - 1; i >= 0; i-- {
+		if key, value, ok := strings.Cut(env[i], "="); ok && key == name {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,50 @@
+package run
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/nodes"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"gotest.tools/v3/assert"
+)
+
+func TestDirectCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test relies on executable shell scripts")
+	}
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	tool := repoRoot.UntypedJoin("node_modules", ".bin", "tool")
+	assert.NilError(t, tool.EnsureDir(), "EnsureDir")
+	assert.NilError(t, tool.WriteFile([]byte("#!/bin/sh\n"), 0755), "WriteFile")
+
+	pkg := &fs.PackageJSON{
+		Name:    "a",
+		Version: "1.2.3",
+		RawJSON: map[string]interface{}{"config": map[string]interface{}{"port": float64(8080)}},
+		Dir:     turbopath.AnchoredSystemPath("packages/a"),
+		Scripts: map[string]string{
+			"build":   "tool --mode $MODE",
+			"lint":    "tool && tool",
+			"test":    "tool",
+			"pretest": "tool",
+		},
+	}
+	env := []string{"PATH=/usr/bin", "MODE=production"}
+
+	cmd := directCommand(&nodes.PackageTask{Task: "build", Pkg: pkg}, []string{"--verbose"}, repoRoot, env)
+	assert.Assert(t, cmd != nil, "expected build to be executed directly")
+	assert.Equal(t, cmd.Path, tool.ToString())
+	assert.DeepEqual(t, cmd.Args[1:], []string{"--mode", "production", "--verbose"})
+	assert.Equal(t, cmd.Dir, repoRoot.UntypedJoin("packages", "a").ToString())
+	assert.Equal(t, lookupEnv(cmd.Env, "npm_lifecycle_event"), "build")
+	assert.Equal(t, lookupEnv(cmd.Env, "npm_package_name"), "a")
+	assert.Equal(t, lookupEnv(cmd.Env, "npm_package_version"), "1.2.3")
+	assert.Equal(t, lookupEnv(cmd.Env, "npm_package_config_port"), "8080")
+	assert.Equal(t, lookupEnv(cmd.Env, "npm_package_json"), repoRoot.UntypedJoin("packages", "a", "package.json").ToString())
+
+	assert.Assert(t, directCommand(&nodes.PackageTask{Task: "lint", Pkg: pkg}, nil, repoRoot, env) == nil, "expected shell syntax to fall back")
+	assert.Assert(t, directCommand(&nodes.PackageTask{Task: "test", Pkg: pkg}, nil, repoRoot, env) == nil, "expected lifecycle scripts to fall back")
+	assert.Assert(t, directCommand(&nodes.PackageTask{Task: "missing", Pkg: pkg}, nil, repoRoot, env) == nil, "expected missing script to fall back")
+}
@@ -0,0 +1,95 @@
+package run
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/vercel/turbo/cli/internal/errcode"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// skipDiskCheckEnvVar disables the check that the outputs of a run fit on disk
+const skipDiskCheckEnvVar = "TURBO_SKIP_DISK_CHECK"
+
+// _largestOutputs is the number of tasks whose outputs are named when they don't fit
+const _largestOutputs = 3
+
+// plannedOutputs is the estimated size of the outputs of a task that is about to run
+type plannedOutputs struct {
+	taskID string
+	size   int64
+	// cached is whether the outputs are also saved to the local cache
+	cached bool
+}
+
+// diskLocation is a place that a run writes outputs to, and the filesystem it's on
+type diskLocation struct {
+	name  string
+	space fs.DiskSpace
+}
+
+// checkDiskSpace returns an error if the outputs of the tasks need more space than
+// is available. Whether they are restored from the cache or recreated, outputs are
+// written to workTree, and outputs that are cached are also saved to cacheDir, if
+// it's set. Locations on the same filesystem share its space.
+func checkDiskSpace(tasks []plannedOutputs, workTree diskLocation, cacheDir *diskLocation) error {
+	locations := []diskLocation{workTree}
+	if cacheDir != nil {
+		locations = append(locations, *cacheDir)
+	}
+	required := make(map[string]int64)
+	// counted is the space that each task needs on each filesystem
+	counted := make(map[string]map[string]int64)
+	names := make(map[string][]string)
+	available := make(map[string]uint64)
+	var filesystems []string
+	for i, location := range locations {
+		filesystem := location.space.Filesystem
+		if _, ok := available[filesystem]; !ok {
+			filesystems = append(filesystems, filesystem)
+		}
+		available[filesystem] = location.space.Available
+		if counted[filesystem] == nil {
+			counted[filesystem] = make(map[string]int64)
+		}
+		names[filesystem] = append(names[filesystem], location.name)
+		for _, task := range tasks {
+			// The first location is the work tree
+			if i == 0 || task.cached {
+				required[filesystem] += task.size
+				counted[filesystem][task.taskID] += task.size
+			}
+		}
+	}
+	for _, filesystem := range filesystems {
+		if required[filesystem] <= 0 || uint64(required[filesystem]) <= available[filesystem] {
+			continue
+		}
+		var largest []string
+		for taskID := range counted[filesystem] {
+			largest = append(largest, taskID)
+		}
+		sort.Slice(largest, func(i, j int) bool {
+			a, b := counted[filesystem][largest[i]], counted[filesystem][largest[j]]
+			return a > b || (a == b && largest[i] < largest[j])
+		})
+		if len(largest) > _largestOutputs {
+			largest = largest[:_largestOutputs]
+		}
+		descriptions := make([]string, len(largest))
+		for i, taskID := range largest {
+			descriptions[i] = fmt.Sprintf("%v %v", taskID, util.FormatByteSize(counted[filesystem][taskID]))
+		}
+		return errcode.Wrap(errcode.InsufficientDiskSpace, fmt.Errorf(
+			"not enough disk space: judging by previous runs, the outputs of the tasks to run need about %v in %v, but only %v is available (largest: %v). Free up space, or set %v=true to run anyway",
+			util.FormatByteSize(required[filesystem]),
+			strings.Join(names[filesystem], " and "),
+			util.FormatByteSize(int64(available[filesystem])),
+			strings.Join(descriptions, ", "),
+			skipDiskCheckEnvVar,
+		))
+	}
+	return nil
+}
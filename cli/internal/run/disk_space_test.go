@@ -0,0 +1,35 @@
+package run
+
+import (
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/errcode"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"gotest.tools/v3/assert"
+)
+
+func TestCheckDiskSpace(t *testing.T) {
+	tasks := []plannedOutputs{
+		{taskID: "web#build", size: 600, cached: true},
+		{taskID: "docs#build", size: 300, cached: true},
+		{taskID: "web#dev", size: 100},
+	}
+	workTree := func(available uint64) diskLocation {
+		return diskLocation{name: "the work tree", space: fs.DiskSpace{Filesystem: "1", Available: available}}
+	}
+	cacheDir := func(filesystem string, available uint64) *diskLocation {
+		return &diskLocation{name: "the cache directory", space: fs.DiskSpace{Filesystem: filesystem, Available: available}}
+	}
+
+	// Every output is written to the work tree
+	assert.NilError(t, checkDiskSpace(tasks, workTree(1000), nil))
+	err := checkDiskSpace(tasks, workTree(999), nil)
+	assert.ErrorContains(t, err, "need about 1000B in the work tree, but only 999B is available (largest: web#build 600B, docs#build 300B, web#dev 100B)")
+	assert.Equal(t, errcode.Of(err), errcode.InsufficientDiskSpace)
+
+	// Cached outputs are also saved to the cache, which may be on the same filesystem
+	assert.NilError(t, checkDiskSpace(tasks, workTree(1000), cacheDir("2", 900)))
+	assert.ErrorContains(t, checkDiskSpace(tasks, workTree(1000), cacheDir("2", 899)), "need about 900B in the cache directory, but only 899B is available (largest: web#build 600B, docs#build 300B)")
+	assert.NilError(t, checkDiskSpace(tasks, workTree(1900), cacheDir("1", 1900)))
+	assert.ErrorContains(t, checkDiskSpace(tasks, workTree(1899), cacheDir("1", 1899)), "need about 1.9KB in the work tree and the cache directory, but only 1.9KB is available (largest: web#build 1.2KB, docs#build 600B, web#dev 100B)")
+}
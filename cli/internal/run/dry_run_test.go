@@ -0,0 +1,79 @@
+package run
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mitchellh/cli"
+	"github.com/pyr-sh/dag"
+	"github.com/vercel/turbo/cli/internal/cache"
+	"github.com/vercel/turbo/cli/internal/core"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/util"
+	"gotest.tools/v3/assert"
+)
+
+func TestDryRunOrder(t *testing.T) {
+	engine := core.NewEngine(&dag.AcyclicGraph{})
+	for _, taskID := range []string{core.ROOT_NODE_NAME, "a#lint", "b#lint", "ui#build", "web#build", "docs#build"} {
+		engine.TaskGraph.Add(taskID)
+	}
+	for _, taskID := range []string{"a#lint", "b#lint", "ui#build"} {
+		engine.TaskGraph.Connect(dag.BasicEdge(taskID, core.ROOT_NODE_NAME))
+	}
+	engine.TaskGraph.Connect(dag.BasicEdge("web#build", "ui#build"))
+	engine.TaskGraph.Connect(dag.BasicEdge("docs#build", "web#build"))
+
+	order := func(history *taskHistory, requested util.Set) []string {
+		var mu sync.Mutex
+		var order []string
+		errs := engine.Execute(func(taskID string) error {
+			mu.Lock()
+			defer mu.Unlock()
+			order = append(order, taskID)
+			return nil
+		}, dryRunExecOpts(context.Background(), history, requested))
+		assert.Equal(t, len(errs), 0)
+		return order
+	}
+
+	// Without a history, the longest chain of tasks goes first
+	history := newTaskHistory(fs.AbsoluteSystemPathFromUpstream(t.TempDir()))
+	got := order(history, nil)
+	assert.DeepEqual(t, got[:2], []string{"ui#build", "web#build"})
+
+	// Tasks that took longer before go first
+	history.record("a#lint", "hash", true, 10*time.Second)
+	history.record("b#lint", "hash", true, 10*time.Second)
+	for _, taskID := range []string{"ui#build", "web#build", "docs#build"} {
+		history.record(taskID, "hash", true, time.Second)
+	}
+	got = order(history, nil)
+	sort.Strings(got[:2])
+	assert.DeepEqual(t, got, []string{"a#lint", "b#lint", "ui#build", "web#build", "docs#build"})
+
+	// Requested tasks, and their dependencies, go before anything else
+	requested := make(util.Set)
+	requested.Add("web#build")
+	got = order(history, requested)
+	assert.DeepEqual(t, got[:2], []string{"ui#build", "web#build"})
+}
+
+func TestDisplayDryTextRunSummary(t *testing.T) {
+	tasks := []hashedTask{
+		{TaskID: "//#build", Task: "build", CacheState: cache.ItemStatus{Local: true}},
+		{TaskID: "//#lint", Task: "lint", CacheState: cache.ItemStatus{Remote: true}},
+		{TaskID: "//#test", Task: "test"},
+	}
+	terminal := cli.NewMockUi()
+	assert.NilError(t, displayDryTextRun(terminal, tasks, nil, nil, true))
+	output := terminal.OutputWriter.String()
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	summary := lines[len(lines)-1]
+	assert.Assert(t, strings.Contains(summary, "1 would run"), output)
+	assert.Assert(t, strings.Contains(summary, "2 cached, 3 total"), output)
+}
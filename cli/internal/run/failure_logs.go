@@ -0,0 +1,69 @@
+package run
+
+import (
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// _failureLogBytes is how much of the end of a failed task's output is uploaded
+const _failureLogBytes = 1024 * 1024
+
+// failureLogClient is the subset of the API client used to upload logs
+type failureLogClient interface {
+	PutFailureLog(hash string, runID string, taskID string, output []byte) error
+}
+
+// failureLogs records the output of tasks so that, if they fail, it can be uploaded
+// to the remote cache. Failed tasks produce no artifact, so otherwise their logs are
+// lost along with the machine that ran them.
+type failureLogs struct {
+	client  failureLogClient
+	runID   string
+	mu      sync.Mutex
+	outputs map[string]*tailBuffer
+	uploads errgroup.Group
+	// uploaded is the number of logs that have been queued for upload
+	uploaded int
+}
+
+func newFailureLogs(client failureLogClient, runID string) *failureLogs {
+	return &failureLogs{
+		client:  client,
+		runID:   runID,
+		outputs: make(map[string]*tailBuffer),
+	}
+}
+
+// outputFor returns a writer that records the tail of the combined output of taskID
+func (fl *failureLogs) outputFor(taskID string) *tailBuffer {
+	fl.mu.Lock()
+	defer fl.mu.Unlock()
+	tail := &tailBuffer{limit: _failureLogBytes}
+	fl.outputs[taskID] = tail
+	return tail
+}
+
+// upload starts uploading the recorded output of a failed task
+func (fl *failureLogs) upload(taskID string, hash string) {
+	fl.mu.Lock()
+	defer fl.mu.Unlock()
+	tail, ok := fl.outputs[taskID]
+	if !ok {
+		return
+	}
+	output := []byte(tail.String())
+	fl.uploaded++
+	fl.uploads.Go(func() error {
+		return fl.client.PutFailureLog(hash, fl.runID, taskID, output)
+	})
+}
+
+// wait blocks until all uploads have finished, and returns the number of logs
+// that were uploaded along with the first error encountered
+func (fl *failureLogs) wait() (int, error) {
+	err := fl.uploads.Wait()
+	fl.mu.Lock()
+	defer fl.mu.Unlock()
+	return fl.uploaded, err
+}
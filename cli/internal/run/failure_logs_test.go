@@ -0,0 +1,42 @@
+package run
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+type fakeFailureLogClient struct {
+	mu   sync.Mutex
+	logs map[string]string
+}
+
+func (f *fakeFailureLogClient) PutFailureLog(hash string, runID string, taskID string, output []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.logs[fmt.Sprintf("%v %v %v", runID, taskID, hash)] = string(output)
+	return nil
+}
+
+func TestFailureLogs(t *testing.T) {
+	client := &fakeFailureLogClient{logs: make(map[string]string)}
+	fl := newFailureLogs(client, "run-1")
+
+	_, err := fl.outputFor("web#build").Write([]byte("compiling\nerror: type mismatch\n"))
+	assert.NilError(t, err, "Write")
+	_, err = fl.outputFor("ui#build").Write([]byte("built\n"))
+	assert.NilError(t, err, "Write")
+
+	fl.upload("web#build", "abc123")
+	// tasks that never produced output have nothing to upload
+	fl.upload("docs#build", "def456")
+
+	uploaded, err := fl.wait()
+	assert.NilError(t, err, "wait")
+	assert.Equal(t, uploaded, 1)
+	assert.DeepEqual(t, client.logs, map[string]string{
+		"run-1 web#build abc123": "compiling\nerror: type mismatch\n",
+	})
+}
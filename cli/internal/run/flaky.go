@@ -0,0 +1,231 @@
+package run
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+// taskHistoryFile is the repo-relative location of the outcomes of recent
+// executions of each task, which are used to detect flaky tasks and to estimate
+// how long tasks take
+var taskHistoryFile = []string{".turbo", "task-history.json"}
+
+// _maxTaskHistory is the number of executions remembered for each task
+const _maxTaskHistory = 20
+
+// taskOutcome is the result of a single execution of a task
+type taskOutcome struct {
+	Hash   string `json:"hash"`
+	Passed bool   `json:"passed"`
+	// DurationMs is how long the task ran for, in milliseconds
+	DurationMs int64 `json:"durationMs,omitempty"`
+	// OutputBytes is the total size of the outputs that the task saved to the cache
+	OutputBytes int64 `json:"outputBytes,omitempty"`
+}
+
+// taskHistory holds the outcomes of the recent executions of each task in a
+// repository. Cache hits are not executions, and are not recorded.
+type taskHistory struct {
+	mu   sync.Mutex
+	path turbopath.AbsoluteSystemPath
+	// executed maps each task executed by the current run to its hash
+	executed map[string]string
+	// Tasks maps taskID to its recent outcomes, oldest first
+	Tasks map[string][]taskOutcome `json:"tasks"`
+}
+
+// flakyTask is a task that has both passed and failed with the same hash
+type flakyTask struct {
+	TaskID   string `json:"taskId"`
+	Hash     string `json:"hash"`
+	Passes   int    `json:"passes"`
+	Failures int    `json:"failures"`
+}
+
+// newTaskHistory creates an empty task history for the given repository
+func newTaskHistory(repoRoot turbopath.AbsoluteSystemPath) *taskHistory {
+	return &taskHistory{
+		path:     runStatePath(repoRoot, taskHistoryFile),
+		executed: make(map[string]string),
+		Tasks:    make(map[string][]taskOutcome),
+	}
+}
+
+// readTaskHistory loads the task history of the given repository
+func readTaskHistory(repoRoot turbopath.AbsoluteSystemPath) (*taskHistory, error) {
+	th := newTaskHistory(repoRoot)
+	bytes, err := th.path.ReadFile()
+	if os.IsNotExist(err) {
+		return th, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(bytes, th); err != nil {
+		return nil, err
+	}
+	if th.Tasks == nil {
+		th.Tasks = make(map[string][]taskOutcome)
+	}
+	return th, nil
+}
+
+// TaskIDsByHash returns the task that produced each hash, as far as the recent
+// runs in the given repository know. Hashes of the most recent run take precedence.
+func TaskIDsByHash(repoRoot turbopath.AbsoluteSystemPath) (map[string]string, error) {
+	th, err := readTaskHistory(repoRoot)
+	if err != nil {
+		return nil, err
+	}
+	taskIDs := make(map[string]string)
+	for taskID, outcomes := range th.Tasks {
+		for _, outcome := range outcomes {
+			taskIDs[outcome.Hash] = taskID
+		}
+	}
+	lastRun, err := LastRunHashes(repoRoot)
+	if err != nil {
+		return nil, err
+	}
+	for taskID, hash := range lastRun {
+		taskIDs[hash] = taskID
+	}
+	return taskIDs, nil
+}
+
+// record adds the outcome of executing a task, forgetting its oldest outcome if necessary
+func (th *taskHistory) record(taskID string, hash string, passed bool, duration time.Duration) {
+	if hash == "" {
+		// Without a hash, outcomes can't be compared
+		return
+	}
+	th.mu.Lock()
+	defer th.mu.Unlock()
+	th.executed[taskID] = hash
+	outcomes := append(th.Tasks[taskID], taskOutcome{Hash: hash, Passed: passed, DurationMs: duration.Milliseconds()})
+	if len(outcomes) > _maxTaskHistory {
+		outcomes = outcomes[len(outcomes)-_maxTaskHistory:]
+	}
+	th.Tasks[taskID] = outcomes
+}
+
+// recordOutputSize adds the size of the outputs of a task to the outcome of its
+// execution with the given hash
+func (th *taskHistory) recordOutputSize(taskID string, hash string, size int64) {
+	if hash == "" || size <= 0 {
+		return
+	}
+	th.mu.Lock()
+	defer th.mu.Unlock()
+	outcomes := th.Tasks[taskID]
+	if len(outcomes) > 0 && outcomes[len(outcomes)-1].Hash == hash {
+		outcomes[len(outcomes)-1].OutputBytes = size
+	}
+}
+
+// outputSize returns the most recently recorded size of the outputs of a task
+func (th *taskHistory) outputSize(taskID string) (int64, bool) {
+	th.mu.Lock()
+	defer th.mu.Unlock()
+	outcomes := th.Tasks[taskID]
+	for i := len(outcomes) - 1; i >= 0; i-- {
+		if outcomes[i].OutputBytes > 0 {
+			return outcomes[i].OutputBytes, true
+		}
+	}
+	return 0, false
+}
+
+// estimatedDuration implements core.ExecOpts.EstimatedDuration, as the mean duration
+// of the recent executions of a task that passed
+func (th *taskHistory) estimatedDuration(taskID string) (time.Duration, bool) {
+	th.mu.Lock()
+	defer th.mu.Unlock()
+	var total int64
+	var count int64
+	for _, outcome := range th.Tasks[taskID] {
+		if outcome.Passed && outcome.DurationMs > 0 {
+			total += outcome.DurationMs
+			count++
+		}
+	}
+	if count == 0 {
+		return 0, false
+	}
+	return time.Duration(total/count) * time.Millisecond, true
+}
+
+// flakyTasks returns the tasks that have both passed and failed with the same hash,
+// ordered by taskID and then hash. If executedOnly is true, only tasks executed by
+// the current run are considered, with the hash they had in this run.
+func (th *taskHistory) flakyTasks(executedOnly bool) []flakyTask {
+	th.mu.Lock()
+	defer th.mu.Unlock()
+	var flaky []flakyTask
+	for taskID, outcomes := range th.Tasks {
+		byHash := make(map[string]*flakyTask)
+		for _, outcome := range outcomes {
+			if executedOnly && th.executed[taskID] != outcome.Hash {
+				continue
+			}
+			ft, ok := byHash[outcome.Hash]
+			if !ok {
+				ft = &flakyTask{TaskID: taskID, Hash: outcome.Hash}
+				byHash[outcome.Hash] = ft
+			}
+			if outcome.Passed {
+				ft.Passes++
+			} else {
+				ft.Failures++
+			}
+		}
+		for _, ft := range byHash {
+			if ft.Passes > 0 && ft.Failures > 0 {
+				flaky = append(flaky, *ft)
+			}
+		}
+	}
+	sort.Slice(flaky, func(i, j int) bool {
+		if flaky[i].TaskID != flaky[j].TaskID {
+			return flaky[i].TaskID < flaky[j].TaskID
+		}
+		return flaky[i].Hash < flaky[j].Hash
+	})
+	return flaky
+}
+
+// write saves the task history to the repository
+func (th *taskHistory) write() error {
+	th.mu.Lock()
+	defer th.mu.Unlock()
+	bytes, err := json.MarshalIndent(th, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := th.path.EnsureDir(); err != nil {
+		return err
+	}
+	return th.path.WriteFile(bytes, 0644)
+}
+
+// writeFlakyReport writes every task that the history shows to be flaky to the given file, as JSON
+func (th *taskHistory) writeFlakyReport(path turbopath.AbsoluteSystemPath) error {
+	flaky := th.flakyTasks(false)
+	if flaky == nil {
+		flaky = []flakyTask{}
+	}
+	bytes, err := json.MarshalIndent(struct {
+		FlakyTasks []flakyTask `json:"flakyTasks"`
+	}{flaky}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := path.EnsureDir(); err != nil {
+		return err
+	}
+	return path.WriteFile(bytes, 0644)
+}
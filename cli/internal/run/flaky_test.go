@@ -0,0 +1,90 @@
+package run
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"gotest.tools/v3/assert"
+)
+
+func TestTaskHistory(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+
+	first, err := readTaskHistory(repoRoot)
+	assert.NilError(t, err, "readTaskHistory with no previous runs")
+	first.record("web#test", "aaa", false, time.Second)
+	first.record("web#test", "aaa", true, time.Second)
+	first.record("web#lint", "bbb", false, time.Second)
+	first.record("web#lint", "ccc", true, time.Second)
+	first.record("docs#test", "ddd", true, time.Second)
+	first.record("docs#test", "", false, time.Second)
+	assert.NilError(t, first.write(), "write")
+
+	history, err := readTaskHistory(repoRoot)
+	assert.NilError(t, err, "readTaskHistory")
+	// Nothing has been executed by this run yet
+	assert.Assert(t, history.flakyTasks(true) == nil)
+	// A change of hash is a change of inputs, not flakiness
+	assert.DeepEqual(t, history.flakyTasks(false), []flakyTask{
+		{TaskID: "web#test", Hash: "aaa", Passes: 1, Failures: 1},
+	})
+
+	history.record("web#test", "eee", true, time.Second)
+	history.record("docs#test", "ddd", false, time.Second)
+	assert.DeepEqual(t, history.flakyTasks(true), []flakyTask{
+		{TaskID: "docs#test", Hash: "ddd", Passes: 1, Failures: 1},
+	})
+
+	report := repoRoot.UntypedJoin("reports", "flaky.json")
+	assert.NilError(t, history.writeFlakyReport(report), "writeFlakyReport")
+	contents, err := report.ReadFile()
+	assert.NilError(t, err, "ReadFile")
+	assert.Assert(t, strings.Contains(string(contents), `"taskId": "docs#test"`))
+	assert.Assert(t, strings.Contains(string(contents), `"taskId": "web#test"`))
+
+	for i := 0; i < _maxTaskHistory; i++ {
+		history.record("web#test", "aaa", true, time.Second)
+	}
+	assert.Equal(t, len(history.Tasks["web#test"]), _maxTaskHistory)
+	assert.DeepEqual(t, history.flakyTasks(false), []flakyTask{
+		{TaskID: "docs#test", Hash: "ddd", Passes: 1, Failures: 1},
+	})
+}
+
+func TestTaskHistoryEstimatedDuration(t *testing.T) {
+	history := newTaskHistory(fs.AbsoluteSystemPathFromUpstream(t.TempDir()))
+	history.record("web#build", "aaa", true, 2*time.Second)
+	history.record("web#build", "bbb", true, 4*time.Second)
+	// Failures can end early, so they don't count
+	history.record("web#build", "ccc", false, 100*time.Millisecond)
+	history.record("web#test", "ddd", false, time.Second)
+
+	duration, ok := history.estimatedDuration("web#build")
+	assert.Assert(t, ok)
+	assert.Equal(t, duration, 3*time.Second)
+	_, ok = history.estimatedDuration("web#test")
+	assert.Assert(t, !ok, "no passing executions")
+	_, ok = history.estimatedDuration("docs#build")
+	assert.Assert(t, !ok, "no executions")
+}
+
+func TestTaskHistoryOutputSize(t *testing.T) {
+	history := newTaskHistory(fs.AbsoluteSystemPathFromUpstream(t.TempDir()))
+	history.record("web#build", "aaa", true, time.Second)
+	history.recordOutputSize("web#build", "aaa", 100)
+	history.record("web#build", "bbb", true, time.Second)
+	// The size is only recorded for the execution with the same hash
+	history.recordOutputSize("web#build", "ccc", 300)
+	size, ok := history.outputSize("web#build")
+	assert.Assert(t, ok)
+	assert.Equal(t, size, int64(100))
+
+	history.recordOutputSize("web#build", "bbb", 200)
+	size, ok = history.outputSize("web#build")
+	assert.Assert(t, ok)
+	assert.Equal(t, size, int64(200))
+	_, ok = history.outputSize("docs#build")
+	assert.Assert(t, !ok)
+}
@@ -3,11 +3,11 @@ package run
 import (
 	"fmt"
 	"os"
-	"path/filepath"
 	"sort"
 	"strings"
 
 	"github.com/hashicorp/go-hclog"
+	turboenv "github.com/vercel/turbo/cli/internal/env"
 	"github.com/vercel/turbo/cli/internal/fs"
 	"github.com/vercel/turbo/cli/internal/globby"
 	"github.com/vercel/turbo/cli/internal/hashing"
@@ -24,7 +24,31 @@ var _defaultEnvVars = []string{
 	"VERCEL_ANALYTICS_ID",
 }
 
-func calculateGlobalHash(rootpath turbopath.AbsoluteSystemPath, rootPackageJSON *fs.PackageJSON, pipeline fs.Pipeline, envVarDependencies []string, globalFileDependencies []string, packageManager *packagemanager.PackageManager, lockFile lockfile.Lockfile, logger hclog.Logger, env []string) (string, error) {
+// globalHashInputs is the global hash along with what went into it, apart from the
+// values of environment variables, which may be secret
+type globalHashInputs struct {
+	Hash                 string                                `json:"hash"`
+	Files                map[turbopath.AnchoredUnixPath]string `json:"files"`
+	RootExternalDepsHash string                                `json:"rootExternalDepsHash"`
+	EnvVars              []string                              `json:"envVars"`
+	Environment          string                                `json:"environment"`
+	EnvMode              string                                `json:"envMode"`
+}
+
+// globalHashable is what goes into the global hash. taskhash.HashSchema describes
+// its fields, in order, which is part of the hash.
+type globalHashable struct {
+	globalFileHashMap    map[turbopath.AnchoredUnixPath]string
+	rootExternalDepsHash string
+	hashedSortedEnvPairs []string
+	globalCacheKey       string
+	pipeline             fs.Pipeline
+	environment          string
+	strictEnv            bool
+	hashSchemaVersion    int
+}
+
+func calculateGlobalHash(rootpath turbopath.AbsoluteSystemPath, rootPackageJSON *fs.PackageJSON, pipeline fs.Pipeline, envVarDependencies []string, globalFileDependencies []string, packageManager *packagemanager.PackageManager, lockFile lockfile.Lockfile, logger hclog.Logger, env []string, environment string, envMode turboenv.Mode) (globalHashInputs, error) {
 	// Calculate env var dependencies
 	globalHashableEnvNames := []string{}
 	globalHashableEnvPairs := []string{}
@@ -44,12 +68,12 @@ func calculateGlobalHash(rootpath turbopath.AbsoluteSystemPath, rootPackageJSON
 	if len(globalFileDependencies) > 0 {
 		ignores, err := packageManager.GetWorkspaceIgnores(rootpath)
 		if err != nil {
-			return "", err
+			return globalHashInputs{}, err
 		}
 
 		f, err := globby.GlobFiles(rootpath.ToStringDuringMigration(), globalFileDependencies, ignores)
 		if err != nil {
-			return "", err
+			return globalHashInputs{}, err
 		}
 
 		for _, val := range f {
@@ -57,6 +81,15 @@ func calculateGlobalHash(rootpath turbopath.AbsoluteSystemPath, rootPackageJSON
 		}
 	}
 
+	// The root dotenv files apply to every task
+	if environment != "" {
+		for _, name := range turboenv.DotEnvFiles(environment) {
+			if path := rootpath.UntypedJoin(name); path.FileExists() {
+				globalDeps.Add(path.ToStringDuringMigration())
+			}
+		}
+	}
+
 	// get system env vars for hashing purposes, these include any variable that includes "TURBO"
 	// that is NOT TURBO_TOKEN or TURBO_TEAM or TURBO_BINARY_PATH.
 	names, pairs := getHashableTurboEnvVarsFromOs(env)
@@ -68,9 +101,14 @@ func calculateGlobalHash(rootpath turbopath.AbsoluteSystemPath, rootPackageJSON
 	logger.Debug("global hash env vars", "vars", globalHashableEnvNames)
 
 	if lockFile == nil {
-		// If we don't have lockfile information available, add the specfile and lockfile to global deps
-		globalDeps.Add(filepath.Join(rootpath.ToStringDuringMigration(), packageManager.Specfile))
-		globalDeps.Add(filepath.Join(rootpath.ToStringDuringMigration(), packageManager.Lockfile))
+		// If we don't have lockfile information available, add the specfile and lockfile to global deps.
+		// The top level of a federation of monorepos may have neither.
+		for _, file := range []string{packageManager.Specfile, packageManager.Lockfile} {
+			path := rootpath.UntypedJoin(file)
+			if path.FileExists() {
+				globalDeps.Add(path.ToStringDuringMigration())
+			}
+		}
 	}
 
 	// No prefix, global deps already have full paths
@@ -82,26 +120,30 @@ func calculateGlobalHash(rootpath turbopath.AbsoluteSystemPath, rootPackageJSON
 
 	globalFileHashMap, err := hashing.GetHashableDeps(rootpath, globalDepsPaths)
 	if err != nil {
-		return "", fmt.Errorf("error hashing files: %w", err)
+		return globalHashInputs{}, fmt.Errorf("error hashing files: %w", err)
 	}
-	globalHashable := struct {
-		globalFileHashMap    map[turbopath.AnchoredUnixPath]string
-		rootExternalDepsHash string
-		hashedSortedEnvPairs []string
-		globalCacheKey       string
-		pipeline             fs.Pipeline
-	}{
+	globalHashable := globalHashable{
 		globalFileHashMap:    globalFileHashMap,
 		rootExternalDepsHash: rootPackageJSON.ExternalDepsHash,
 		hashedSortedEnvPairs: globalHashableEnvPairs,
 		globalCacheKey:       _globalCacheKey,
 		pipeline:             pipeline,
+		environment:          environment,
+		strictEnv:            envMode == turboenv.StrictMode,
+		hashSchemaVersion:    fs.HashSchemaVersion,
 	}
 	globalHash, err := fs.HashObject(globalHashable)
 	if err != nil {
-		return "", fmt.Errorf("error hashing global dependencies %w", err)
+		return globalHashInputs{}, fmt.Errorf("error hashing global dependencies %w", err)
 	}
-	return globalHash, nil
+	return globalHashInputs{
+		Hash:                 globalHash,
+		Files:                globalFileHashMap,
+		RootExternalDepsHash: rootPackageJSON.ExternalDepsHash,
+		EnvVars:              globalHashableEnvNames,
+		Environment:          environment,
+		EnvMode:              envMode.String(),
+	}, nil
 }
 
 // getHashableTurboEnvVarsFromOs returns a list of environment variables names and
@@ -3,6 +3,8 @@ package run
 import (
 	"reflect"
 	"testing"
+
+	"github.com/vercel/turbo/cli/internal/taskhash"
 )
 
 func Test_getHashableTurboEnvVarsFromOs(t *testing.T) {
@@ -24,3 +26,16 @@ func Test_getHashableTurboEnvVarsFromOs(t *testing.T) {
 		t.Errorf("getHashableTurboEnvVarsFromOs() env pairs got = %v, want %v", gotPairs, wantPairs)
 	}
 }
+
+func TestHashSchemaDescribesGlobalHashable(t *testing.T) {
+	hashable := reflect.TypeOf(globalHashable{})
+	schema := taskhash.HashSchema()
+	if len(schema.Global) != hashable.NumField() {
+		t.Fatalf("HashSchema() describes %v global fields, globalHashable has %v", len(schema.Global), hashable.NumField())
+	}
+	for i, field := range schema.Global {
+		if field.Name != hashable.Field(i).Name {
+			t.Errorf("HashSchema() global field %v is %v, want %v", i, field.Name, hashable.Field(i).Name)
+		}
+	}
+}
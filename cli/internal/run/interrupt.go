@@ -0,0 +1,105 @@
+package run
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/mitchellh/cli"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// interruptSummaryFile is the repo-relative location of the summary of the most
+// recent run that was interrupted. It is removed once a run finishes.
+var interruptSummaryFile = []string{".turbo", "run-interrupted.json"}
+
+// interruptedTask is a task of a run that was interrupted
+type interruptedTask struct {
+	TaskID string `json:"taskId"`
+	// Saved is whether the task's outputs are in the cache, for completed tasks
+	Saved bool `json:"saved"`
+}
+
+// interruptSummary is the state of the tasks of a run when it was interrupted
+type interruptSummary struct {
+	Completed []interruptedTask `json:"completed"`
+	Failed    []interruptedTask `json:"failed"`
+	Running   []interruptedTask `json:"running"`
+	// Pending tasks hadn't started, including those that were skipped after a failure
+	Pending []interruptedTask `json:"pending"`
+}
+
+// newInterruptSummary sorts taskIDs, the tasks of the run that execute a command,
+// by their state in results. outputsSaved reports whether the outputs of a task that
+// it executed were saved to the cache.
+func newInterruptSummary(taskIDs []string, results []BuildTargetState, outputsSaved func(taskID string) bool) *interruptSummary {
+	states := make(map[string]BuildTargetState, len(results))
+	for _, result := range results {
+		states[result.Label] = result
+	}
+	summary := &interruptSummary{
+		Completed: []interruptedTask{},
+		Failed:    []interruptedTask{},
+		Running:   []interruptedTask{},
+		Pending:   []interruptedTask{},
+	}
+	sorted := append([]string{}, taskIDs...)
+	sort.Strings(sorted)
+	for _, taskID := range sorted {
+		state, ok := states[taskID]
+		if !ok {
+			summary.Pending = append(summary.Pending, interruptedTask{TaskID: taskID})
+			continue
+		}
+		switch state.Status {
+		case TargetCached:
+			summary.Completed = append(summary.Completed, interruptedTask{TaskID: taskID, Saved: true})
+		case TargetBuilt:
+			summary.Completed = append(summary.Completed, interruptedTask{TaskID: taskID, Saved: outputsSaved(taskID)})
+		case TargetBuildFailed:
+			summary.Failed = append(summary.Failed, interruptedTask{TaskID: taskID})
+		case TargetSkipped:
+			summary.Pending = append(summary.Pending, interruptedTask{TaskID: taskID})
+		default:
+			summary.Running = append(summary.Running, interruptedTask{TaskID: taskID})
+		}
+	}
+	return summary
+}
+
+// print writes the summary to the terminal
+func (is *interruptSummary) print(terminal cli.Ui) {
+	terminal.Output("")
+	terminal.Output(util.Sprintf("${BOLD_YELLOW}Interrupted: %v completed, %v failed, %v running, %v pending${RESET}", len(is.Completed), len(is.Failed), len(is.Running), len(is.Pending)))
+	for _, task := range is.Completed {
+		saved := "outputs not cached"
+		if task.Saved {
+			saved = "outputs cached"
+		}
+		terminal.Output(util.Sprintf("${GREY}           completed  %v (%v)${RESET}", task.TaskID, saved))
+	}
+	for _, group := range []struct {
+		state string
+		tasks []interruptedTask
+	}{{"failed", is.Failed}, {"running", is.Running}, {"pending", is.Pending}} {
+		for _, task := range group.tasks {
+			terminal.Output(util.Sprintf("${GREY}           %-9v  %v${RESET}", group.state, task.TaskID))
+		}
+	}
+}
+
+// write saves the summary as JSON to path
+func (is *interruptSummary) write(path turbopath.AbsoluteSystemPath) error {
+	bytes, err := json.MarshalIndent(is, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := path.EnsureDir(); err != nil {
+		return err
+	}
+	if err := path.WriteFile(bytes, 0644); err != nil {
+		return fmt.Errorf("failed to write summary of the interrupted run: %w", err)
+	}
+	return nil
+}
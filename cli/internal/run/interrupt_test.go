@@ -0,0 +1,50 @@
+package run
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"gotest.tools/v3/assert"
+)
+
+func TestInterruptSummary(t *testing.T) {
+	results := []BuildTargetState{
+		{Label: "ui#build", Status: TargetBuilt},
+		{Label: "ui#lint", Status: TargetBuilt},
+		{Label: "ui#test", Status: TargetBuildFailed},
+		{Label: "docs#build", Status: TargetCached},
+		{Label: "web#build", Status: TargetBuilding},
+		{Label: "web#test", Status: TargetBuildRetried},
+		{Label: "docs#test", Status: TargetSkipped},
+	}
+	taskIDs := []string{"web#lint", "web#test", "web#build", "ui#test", "ui#lint", "ui#build", "docs#test", "docs#build"}
+	summary := newInterruptSummary(taskIDs, results, func(taskID string) bool {
+		return taskID == "ui#build"
+	})
+	assert.DeepEqual(t, summary, &interruptSummary{
+		Completed: []interruptedTask{{TaskID: "docs#build", Saved: true}, {TaskID: "ui#build", Saved: true}, {TaskID: "ui#lint"}},
+		Failed:    []interruptedTask{{TaskID: "ui#test"}},
+		Running:   []interruptedTask{{TaskID: "web#build"}, {TaskID: "web#test"}},
+		Pending:   []interruptedTask{{TaskID: "docs#test"}, {TaskID: "web#lint"}},
+	})
+
+	// The summary is kept until a run finishes
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	path := runStatePath(repoRoot, interruptSummaryFile)
+	assert.NilError(t, summary.write(path))
+	bytes, err := path.ReadFile()
+	assert.NilError(t, err)
+	read := &interruptSummary{}
+	assert.NilError(t, json.Unmarshal(bytes, read))
+	assert.DeepEqual(t, read, summary)
+
+	rs, err := newResumeState(repoRoot, true)
+	assert.NilError(t, err)
+	assert.Assert(t, path.FileExists())
+	rs.recordSaved("ui#build", "abc")
+	assert.Assert(t, rs.outputsSaved("ui#build"))
+	assert.Assert(t, !rs.outputsSaved("ui#lint"))
+	assert.NilError(t, rs.Close())
+	assert.Assert(t, !path.FileExists())
+}
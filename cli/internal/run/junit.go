@@ -0,0 +1,187 @@
+package run
+
+import (
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// _junitTailBytes is how much of the end of a failed task's stderr is included in the report
+const _junitTailBytes = 4096
+
+// tailBuffer is an io.Writer that retains only the last limit bytes written to it
+type tailBuffer struct {
+	mu    sync.Mutex
+	limit int
+	buf   []byte
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.buf = append(t.buf, p...)
+	if len(t.buf) > t.limit {
+		t.buf = t.buf[len(t.buf)-t.limit:]
+	}
+	return len(p), nil
+}
+
+func (t *tailBuffer) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return string(t.buf)
+}
+
+// junitReport collects the stderr of tasks so that failures can be
+// reported with context in a JUnit XML file
+type junitReport struct {
+	mu     sync.Mutex
+	stderr map[string]*tailBuffer
+}
+
+func newJUnitReport() *junitReport {
+	return &junitReport{
+		stderr: make(map[string]*tailBuffer),
+	}
+}
+
+// stderrFor returns a writer that records the tail of stderr for taskID
+func (jr *junitReport) stderrFor(taskID string) *tailBuffer {
+	jr.mu.Lock()
+	defer jr.mu.Unlock()
+	tail := &tailBuffer{limit: _junitTailBytes}
+	jr.stderr[taskID] = tail
+	return tail
+}
+
+func (jr *junitReport) stderrTail(taskID string) string {
+	jr.mu.Lock()
+	defer jr.mu.Unlock()
+	if tail, ok := jr.stderr[taskID]; ok {
+		return tail.String()
+	}
+	return ""
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name          `xml:"testsuites"`
+	Name     string            `xml:"name,attr"`
+	Tests    int               `xml:"tests,attr"`
+	Failures int               `xml:"failures,attr"`
+	Skipped  int               `xml:"skipped,attr"`
+	Time     string            `xml:"time,attr"`
+	Suites   []*junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string           `xml:"name,attr"`
+	Tests     int              `xml:"tests,attr"`
+	Failures  int              `xml:"failures,attr"`
+	Skipped   int              `xml:"skipped,attr"`
+	Time      string           `xml:"time,attr"`
+	Timestamp string           `xml:"timestamp,attr"`
+	Cases     []*junitTestCase `xml:"testcase"`
+	duration  time.Duration
+	startAt   time.Time
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+}
+
+type junitFailure struct {
+	Message  string `xml:"message,attr"`
+	Contents string `xml:",chardata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+func junitSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+// build converts the results of a run into JUnit test suites, one per package
+func (jr *junitReport) build(results []BuildTargetState, runDuration time.Duration) *junitTestSuites {
+	suites := make(map[string]*junitTestSuite)
+	report := &junitTestSuites{
+		Name: "turbo run",
+		Time: junitSeconds(runDuration),
+	}
+	for _, result := range results {
+		pkg, task := util.GetPackageTaskFromId(result.Label)
+		suite, ok := suites[pkg]
+		if !ok {
+			suite = &junitTestSuite{Name: pkg}
+			suites[pkg] = suite
+		}
+		testCase := &junitTestCase{
+			Name:      task,
+			Classname: pkg,
+			Time:      junitSeconds(result.Duration),
+		}
+		switch result.Status {
+		case TargetBuildFailed:
+			message := "task failed"
+			if result.Err != nil {
+				message = result.Err.Error()
+			}
+			testCase.Failure = &junitFailure{
+				Message:  message,
+				Contents: jr.stderrTail(result.Label),
+			}
+			suite.Failures++
+		case TargetCached:
+			testCase.Skipped = &junitSkipped{Message: "cached"}
+			suite.Skipped++
+		case TargetBuilding, TargetBuildStopped, TargetBuildRetried:
+			testCase.Skipped = &junitSkipped{Message: "did not complete"}
+			suite.Skipped++
+		case TargetSkipped:
+			testCase.Skipped = &junitSkipped{Message: "not run after a failure"}
+			suite.Skipped++
+		}
+		suite.Tests++
+		suite.duration += result.Duration
+		// Skipped tasks never started
+		if !result.StartAt.IsZero() && (suite.startAt.IsZero() || result.StartAt.Before(suite.startAt)) {
+			suite.startAt = result.StartAt
+		}
+		suite.Cases = append(suite.Cases, testCase)
+	}
+
+	for _, suite := range suites {
+		suite.Time = junitSeconds(suite.duration)
+		suite.Timestamp = suite.startAt.UTC().Format("2006-01-02T15:04:05")
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+		report.Skipped += suite.Skipped
+		report.Suites = append(report.Suites, suite)
+	}
+	sort.Slice(report.Suites, func(i, j int) bool {
+		return report.Suites[i].Name < report.Suites[j].Name
+	})
+	return report
+}
+
+// write renders the results of a run as JUnit XML to path
+func (jr *junitReport) write(path turbopath.AbsoluteSystemPath, results []BuildTargetState, runDuration time.Duration) error {
+	bytes, err := xml.MarshalIndent(jr.build(results, runDuration), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := path.EnsureDir(); err != nil {
+		return err
+	}
+	return path.WriteFile(append([]byte(xml.Header), bytes...), 0644)
+}
@@ -0,0 +1,72 @@
+package run
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"gotest.tools/v3/assert"
+)
+
+func TestTailBuffer(t *testing.T) {
+	tail := &tailBuffer{limit: 5}
+	_, _ = tail.Write([]byte("abc"))
+	_, _ = tail.Write([]byte("defg"))
+	assert.Equal(t, tail.String(), "cdefg")
+}
+
+func TestJUnitReport(t *testing.T) {
+	start := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	jr := newJUnitReport()
+	_, _ = jr.stderrFor("b#test").Write([]byte("expected 1 to equal 2\n"))
+	results := []BuildTargetState{
+		{Label: "a#build", StartAt: start, Duration: 2 * time.Second, Status: TargetBuilt},
+		{Label: "a#test", StartAt: start.Add(2 * time.Second), Duration: time.Second, Status: TargetCached},
+		{Label: "b#test", StartAt: start, Duration: 1500 * time.Millisecond, Status: TargetBuildFailed, Err: errors.New("exit status 1")},
+	}
+
+	report := jr.build(results, 5*time.Second)
+	assert.Equal(t, report.Tests, 3)
+	assert.Equal(t, report.Failures, 1)
+	assert.Equal(t, report.Skipped, 1)
+	assert.Equal(t, len(report.Suites), 2)
+
+	a := report.Suites[0]
+	assert.Equal(t, a.Name, "a")
+	assert.Equal(t, a.Time, "3.000")
+	assert.Equal(t, a.Timestamp, "2022-10-01T12:00:00")
+	assert.Equal(t, a.Cases[1].Skipped.Message, "cached")
+
+	b := report.Suites[1]
+	assert.Equal(t, b.Cases[0].Name, "test")
+	assert.Equal(t, b.Cases[0].Failure.Message, "exit status 1")
+	assert.Equal(t, b.Cases[0].Failure.Contents, "expected 1 to equal 2\n")
+
+	path := fs.AbsoluteSystemPathFromUpstream(t.TempDir()).UntypedJoin("reports", "junit.xml")
+	assert.NilError(t, jr.write(path, results, 5*time.Second), "write")
+	contents, err := path.ReadFile()
+	assert.NilError(t, err, "ReadFile")
+	assert.Assert(t, strings.Contains(string(contents), `<testcase name="build" classname="a" time="2.000"></testcase>`))
+}
+
+func TestJUnitReportSkipped(t *testing.T) {
+	start := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	jr := newJUnitReport()
+	results := []BuildTargetState{
+		{Label: "a#build", StartAt: start, Duration: time.Second, Status: TargetBuildFailed, Err: errors.New("exit status 1")},
+		{Label: "b#build", Status: TargetSkipped},
+		{Label: "b#lint", StartAt: start.Add(time.Second), Duration: time.Second, Status: TargetBuilt},
+	}
+
+	report := jr.build(results, 2*time.Second)
+	assert.Equal(t, report.Tests, 3)
+	assert.Equal(t, report.Failures, 1)
+	assert.Equal(t, report.Skipped, 1)
+
+	b := report.Suites[1]
+	assert.Equal(t, b.Cases[0].Skipped.Message, "not run after a failure")
+	// The skipped task doesn't move the start of its suite
+	assert.Equal(t, b.Timestamp, "2022-10-01T12:00:01")
+}
@@ -0,0 +1,281 @@
+package run
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+)
+
+const (
+	// _logDrainInterval is how often buffered events are sent to the log drain
+	_logDrainInterval = 500 * time.Millisecond
+	// _logDrainBatchSize is the most events sent in one request, and the number of
+	// buffered events that triggers a send before the interval has passed
+	_logDrainBatchSize = 500
+	// _maxLogDrainEvents is the most events buffered while the log drain is
+	// unreachable. Beyond it, the oldest events are dropped.
+	_maxLogDrainEvents = 10000
+	// _logDrainRetries is how many times a failed request is retried
+	_logDrainRetries = 3
+	// _logDrainBackoff is the delay before the first retry, doubled for each one after it
+	_logDrainBackoff = 250 * time.Millisecond
+)
+
+// Types of log drain events
+const (
+	logEventStart  = "start"
+	logEventOutput = "output"
+	logEventEnd    = "end"
+)
+
+// Statuses of tasks in "end" events
+const (
+	logStatusCached    = "cached"
+	logStatusSucceeded = "succeeded"
+	logStatusFailed    = "failed"
+)
+
+// logEvent is a single line of NDJSON sent to the log drain
+type logEvent struct {
+	Time   time.Time `json:"time"`
+	RunID  string    `json:"runId"`
+	Type   string    `json:"type"`
+	TaskID string    `json:"taskId"`
+	Hash   string    `json:"hash,omitempty"`
+	// Stream is "stdout" or "stderr", for output events
+	Stream string `json:"stream,omitempty"`
+	Line   string `json:"line,omitempty"`
+	// Status and DurationMs are set for end events
+	Status     string `json:"status,omitempty"`
+	DurationMs int64  `json:"durationMs,omitempty"`
+}
+
+// logDrain streams task events and output to an HTTP endpoint while the run is in
+// progress. Events are buffered and sent as NDJSON in batches, so that a slow or
+// unavailable endpoint never blocks tasks.
+type logDrain struct {
+	url    string
+	token  string
+	runID  string
+	client *http.Client
+	logger hclog.Logger
+
+	mu      sync.Mutex
+	pending []logEvent
+	// dropped is the number of events that were never delivered
+	dropped int
+
+	wake chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newLogDrain(rawURL string, token string, runID string, logger hclog.Logger) (*logDrain, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("%v is not an http or https URL", rawURL)
+	}
+	ld := &logDrain{
+		url:    rawURL,
+		token:  token,
+		runID:  runID,
+		client: &http.Client{Timeout: 10 * time.Second},
+		logger: logger,
+		wake:   make(chan struct{}, 1),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go ld.loop()
+	return ld, nil
+}
+
+// taskStarted records that a task began executing
+func (ld *logDrain) taskStarted(taskID string, hash string) {
+	ld.add(logEvent{Type: logEventStart, TaskID: taskID, Hash: hash})
+}
+
+// taskEnded records the outcome of a task
+func (ld *logDrain) taskEnded(taskID string, hash string, status string, duration time.Duration) {
+	ld.add(logEvent{Type: logEventEnd, TaskID: taskID, Hash: hash, Status: status, DurationMs: duration.Milliseconds()})
+}
+
+// outputFor returns a writer that sends each line written to it as an output event
+func (ld *logDrain) outputFor(taskID string, hash string, stream string) *logDrainWriter {
+	return &logDrainWriter{drain: ld, taskID: taskID, hash: hash, stream: stream}
+}
+
+func (ld *logDrain) add(event logEvent) {
+	event.Time = time.Now()
+	event.RunID = ld.runID
+	ld.mu.Lock()
+	ld.pending = append(ld.pending, event)
+	if overflow := len(ld.pending) - _maxLogDrainEvents; overflow > 0 {
+		ld.pending = ld.pending[overflow:]
+		ld.dropped += overflow
+	}
+	full := len(ld.pending) >= _logDrainBatchSize
+	ld.mu.Unlock()
+	if full {
+		select {
+		case ld.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (ld *logDrain) loop() {
+	defer close(ld.done)
+	ticker := time.NewTicker(_logDrainInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ld.stop:
+			ld.flush()
+			return
+		case <-ticker.C:
+			ld.flush()
+		case <-ld.wake:
+			ld.flush()
+		}
+	}
+}
+
+// flush sends every buffered event. Batches that can't be delivered are put back,
+// to be retried on the next flush, unless the endpoint rejected them.
+func (ld *logDrain) flush() {
+	for {
+		ld.mu.Lock()
+		n := len(ld.pending)
+		if n > _logDrainBatchSize {
+			n = _logDrainBatchSize
+		}
+		batch := ld.pending[:n:n]
+		ld.pending = ld.pending[n:]
+		ld.mu.Unlock()
+		if len(batch) == 0 {
+			return
+		}
+		retry, err := ld.send(batch)
+		if err == nil {
+			continue
+		}
+		ld.logger.Debug("failed to send events to log drain", "url", ld.url, "events", len(batch), "error", err)
+		ld.mu.Lock()
+		if retry {
+			ld.pending = append(batch, ld.pending...)
+			if overflow := len(ld.pending) - _maxLogDrainEvents; overflow > 0 {
+				ld.pending = ld.pending[overflow:]
+				ld.dropped += overflow
+			}
+		} else {
+			ld.dropped += len(batch)
+		}
+		ld.mu.Unlock()
+		return
+	}
+}
+
+// send posts a batch of events, retrying failures that may be temporary. It returns
+// whether the batch is worth sending again later.
+func (ld *logDrain) send(batch []logEvent) (bool, error) {
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	for _, event := range batch {
+		if err := encoder.Encode(event); err != nil {
+			return false, err
+		}
+	}
+	backoff := _logDrainBackoff
+	var err error
+	for attempt := 0; attempt <= _logDrainRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		var req *http.Request
+		req, err = http.NewRequest(http.MethodPost, ld.url, bytes.NewReader(body.Bytes()))
+		if err != nil {
+			return false, err
+		}
+		req.Header.Set("Content-Type", "application/x-ndjson")
+		if ld.token != "" {
+			req.Header.Set("Authorization", "Bearer "+ld.token)
+		}
+		var resp *http.Response
+		resp, err = ld.client.Do(req)
+		if err != nil {
+			continue
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode < 300 {
+			return false, nil
+		}
+		err = fmt.Errorf("log drain responded with %v", resp.Status)
+		if resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+			return false, err
+		}
+	}
+	return true, err
+}
+
+// close sends the remaining events, and returns an error if any events were lost
+func (ld *logDrain) close() error {
+	close(ld.stop)
+	<-ld.done
+	ld.mu.Lock()
+	defer ld.mu.Unlock()
+	lost := ld.dropped + len(ld.pending)
+	if lost > 0 {
+		return fmt.Errorf("%v events could not be sent to the log drain at %v", lost, ld.url)
+	}
+	return nil
+}
+
+// logDrainWriter splits the output of a task into lines for the log drain
+type logDrainWriter struct {
+	drain  *logDrain
+	taskID string
+	hash   string
+	stream string
+	mu     sync.Mutex
+	buf    []byte
+}
+
+// Write implements io.Writer.Write
+func (w *logDrainWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.emit(bytes.TrimSuffix(w.buf[:i], []byte{'\r'}))
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush sends any output that isn't terminated by a newline
+func (w *logDrainWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.buf) > 0 {
+		w.emit(w.buf)
+		w.buf = nil
+	}
+}
+
+func (w *logDrainWriter) emit(line []byte) {
+	w.drain.add(logEvent{Type: logEventOutput, TaskID: w.taskID, Hash: w.hash, Stream: w.stream, Line: string(line)})
+}
@@ -0,0 +1,81 @@
+package run
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"gotest.tools/v3/assert"
+)
+
+func TestLogDrain(t *testing.T) {
+	var mu sync.Mutex
+	var events []logEvent
+	failures := 1
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, r.Header.Get("Content-Type"), "application/x-ndjson")
+		assert.Equal(t, r.Header.Get("Authorization"), "Bearer secret")
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			var event logEvent
+			assert.NilError(t, json.Unmarshal(scanner.Bytes(), &event))
+			events = append(events, event)
+		}
+	}))
+	defer server.Close()
+
+	drain, err := newLogDrain(server.URL, "secret", "run-1", hclog.NewNullLogger())
+	assert.NilError(t, err, "newLogDrain")
+	drain.taskStarted("web#build", "abc")
+	stdout := drain.outputFor("web#build", "abc", "stdout")
+	_, err = stdout.Write([]byte("compiling\r\nbuilt in"))
+	assert.NilError(t, err)
+	_, err = stdout.Write([]byte(" 2s\ndone"))
+	assert.NilError(t, err)
+	stdout.Flush()
+	drain.taskEnded("web#build", "abc", logStatusSucceeded, 2*time.Second)
+	assert.NilError(t, drain.close(), "close")
+
+	type summary struct{ Type, TaskID, Stream, Line, Status string }
+	var got []summary
+	for _, event := range events {
+		assert.Equal(t, event.RunID, "run-1")
+		assert.Equal(t, event.Hash, "abc")
+		got = append(got, summary{event.Type, event.TaskID, event.Stream, event.Line, event.Status})
+	}
+	assert.DeepEqual(t, got, []summary{
+		{logEventStart, "web#build", "", "", ""},
+		{logEventOutput, "web#build", "stdout", "compiling", ""},
+		{logEventOutput, "web#build", "stdout", "built in 2s", ""},
+		{logEventOutput, "web#build", "stdout", "done", ""},
+		{logEventEnd, "web#build", "", "", logStatusSucceeded},
+	})
+}
+
+func TestLogDrainRejected(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	drain, err := newLogDrain(server.URL, "", "run-1", hclog.NewNullLogger())
+	assert.NilError(t, err, "newLogDrain")
+	drain.taskStarted("web#build", "abc")
+	drain.taskEnded("web#build", "abc", logStatusFailed, time.Second)
+	assert.ErrorContains(t, drain.close(), "2 events could not be sent")
+
+	_, err = newLogDrain("ws://localhost:1234", "", "run-1", hclog.NewNullLogger())
+	assert.ErrorContains(t, err, "not an http or https URL")
+}
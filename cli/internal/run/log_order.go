@@ -0,0 +1,170 @@
+package run
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/mitchellh/cli"
+	"github.com/vercel/turbo/cli/internal/ui"
+)
+
+// logOrder is how the output of tasks that run concurrently is ordered
+type logOrder string
+
+const (
+	// logOrderAuto groups output in CI, and streams it otherwise
+	logOrderAuto logOrder = "auto"
+	// logOrderStream prints each line of output as soon as a task writes it
+	logOrderStream logOrder = "stream"
+	// logOrderGrouped prints all of a task's output together once it completes
+	logOrderGrouped logOrder = "grouped"
+)
+
+// String implements pflag.Value.String
+func (o *logOrder) String() string {
+	if *o == "" {
+		return string(logOrderAuto)
+	}
+	return string(*o)
+}
+
+// Set implements pflag.Value.Set
+func (o *logOrder) Set(value string) error {
+	switch logOrder(value) {
+	case logOrderAuto, logOrderStream, logOrderGrouped:
+		*o = logOrder(value)
+		return nil
+	}
+	return fmt.Errorf("must be one of \"%v\", \"%v\", or \"%v\"", logOrderStream, logOrderGrouped, logOrderAuto)
+}
+
+// Type implements pflag.Value.Type
+func (o *logOrder) Type() string {
+	return "order"
+}
+
+// grouped returns true if output should be grouped by task
+func (o logOrder) grouped() bool {
+	switch o {
+	case logOrderGrouped:
+		return true
+	case logOrderStream:
+		return false
+	}
+	return ui.IsCIEnvironment
+}
+
+// groupedOutputLevel is the kind of a message a task printed
+type groupedOutputLevel int
+
+const (
+	groupedRaw groupedOutputLevel = iota
+	groupedMessage
+	groupedInfo
+	groupedWarn
+	groupedError
+)
+
+type groupedEntry struct {
+	level groupedOutputLevel
+	text  string
+}
+
+// groupedOutput buffers everything a task prints, both the output of its command
+// and turbo's messages about it, so that it can be printed as one block once the
+// task completes, rather than interleaved with the output of other tasks
+type groupedOutput struct {
+	mu      sync.Mutex
+	entries []groupedEntry
+}
+
+var _ cli.Ui = (*groupedOutput)(nil)
+var _ io.Writer = (*groupedOutput)(nil)
+
+func (g *groupedOutput) add(level groupedOutputLevel, text string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.entries = append(g.entries, groupedEntry{level: level, text: text})
+}
+
+// Write buffers output that would have been written to stdout
+func (g *groupedOutput) Write(p []byte) (int, error) {
+	g.add(groupedRaw, string(p))
+	return len(p), nil
+}
+
+// Ask implements cli.Ui.Ask. Tasks can't ask for input.
+func (g *groupedOutput) Ask(string) (string, error) {
+	return "", errors.New("cannot ask for input while output is grouped")
+}
+
+// AskSecret implements cli.Ui.AskSecret. Tasks can't ask for input.
+func (g *groupedOutput) AskSecret(string) (string, error) {
+	return "", errors.New("cannot ask for input while output is grouped")
+}
+
+// Output implements cli.Ui.Output
+func (g *groupedOutput) Output(message string) { g.add(groupedMessage, message) }
+
+// Info implements cli.Ui.Info
+func (g *groupedOutput) Info(message string) { g.add(groupedInfo, message) }
+
+// Warn implements cli.Ui.Warn
+func (g *groupedOutput) Warn(message string) { g.add(groupedWarn, message) }
+
+// Error implements cli.Ui.Error
+func (g *groupedOutput) Error(message string) { g.add(groupedError, message) }
+
+// groupedLogs prints the buffered output of each task as it completes, one task at a time
+type groupedLogs struct {
+	mu     sync.Mutex
+	ui     cli.Ui
+	stdout io.Writer
+	// githubActions is set when running in GitHub Actions, which can collapse each group
+	githubActions bool
+}
+
+func newGroupedLogs(terminal cli.Ui) *groupedLogs {
+	return &groupedLogs{
+		ui:            terminal,
+		stdout:        os.Stdout,
+		githubActions: os.Getenv("GITHUB_ACTIONS") == "true",
+	}
+}
+
+// flush prints the output of a task that has completed. Nothing is printed for a
+// task that printed nothing.
+func (gl *groupedLogs) flush(taskID string, output *groupedOutput) {
+	output.mu.Lock()
+	entries := output.entries
+	output.entries = nil
+	output.mu.Unlock()
+	if len(entries) == 0 {
+		return
+	}
+	gl.mu.Lock()
+	defer gl.mu.Unlock()
+	if gl.githubActions {
+		_, _ = fmt.Fprintf(gl.stdout, "::group::%v\n", taskID)
+	}
+	for _, entry := range entries {
+		switch entry.level {
+		case groupedRaw:
+			_, _ = io.WriteString(gl.stdout, entry.text)
+		case groupedMessage:
+			gl.ui.Output(entry.text)
+		case groupedInfo:
+			gl.ui.Info(entry.text)
+		case groupedWarn:
+			gl.ui.Warn(entry.text)
+		case groupedError:
+			gl.ui.Error(entry.text)
+		}
+	}
+	if gl.githubActions {
+		_, _ = io.WriteString(gl.stdout, "::endgroup::\n")
+	}
+}
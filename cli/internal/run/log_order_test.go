@@ -0,0 +1,85 @@
+package run
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+
+	"github.com/mitchellh/cli"
+	"gotest.tools/v3/assert"
+)
+
+func TestLogOrderSet(t *testing.T) {
+	var order logOrder
+	assert.Equal(t, order.String(), "auto")
+	assert.NilError(t, order.Set("grouped"))
+	assert.Assert(t, order.grouped())
+	assert.NilError(t, order.Set("stream"))
+	assert.Assert(t, !order.grouped())
+	assert.ErrorContains(t, order.Set("sorted"), "must be one of")
+	assert.Equal(t, order.String(), "stream")
+}
+
+func newTestGroupedLogs(githubActions bool) (*groupedLogs, *bytes.Buffer) {
+	out := &bytes.Buffer{}
+	return &groupedLogs{
+		ui:            &cli.BasicUi{Writer: out, ErrorWriter: out},
+		stdout:        out,
+		githubActions: githubActions,
+	}, out
+}
+
+func TestGroupedLogs(t *testing.T) {
+	gl, out := newTestGroupedLogs(false)
+	ui := &groupedOutput{}
+	web := &groupedOutput{}
+	ui.Output("ui:build: cache miss, executing 123")
+	web.Output("web:build: cache miss, executing 456")
+	_, _ = ui.Write([]byte("ui:build: compiling\n"))
+	_, _ = web.Write([]byte("web:build: compiling\n"))
+	web.Error("web:build: ERROR: command finished with error")
+	_, _ = ui.Write([]byte("ui:build: done\n"))
+
+	gl.flush("web#build", web)
+	gl.flush("ui#build", ui)
+	// A task is only printed once
+	gl.flush("ui#build", ui)
+	assert.Equal(t, out.String(), `web:build: cache miss, executing 456
+web:build: compiling
+web:build: ERROR: command finished with error
+ui:build: cache miss, executing 123
+ui:build: compiling
+ui:build: done
+`)
+}
+
+func TestGroupedLogsGitHubActions(t *testing.T) {
+	gl, out := newTestGroupedLogs(true)
+	web := &groupedOutput{}
+	_, _ = web.Write([]byte("web:build: compiling\n"))
+	gl.flush("web#build", web)
+	gl.flush("ui#build", &groupedOutput{})
+	assert.Equal(t, out.String(), "::group::web#build\nweb:build: compiling\n::endgroup::\n")
+}
+
+func TestGroupedLogsConcurrent(t *testing.T) {
+	gl, out := newTestGroupedLogs(false)
+	var wg sync.WaitGroup
+	for _, task := range []string{"a", "b", "c", "d"} {
+		wg.Add(1)
+		go func(task string) {
+			defer wg.Done()
+			output := &groupedOutput{}
+			for i := 0; i < 100; i++ {
+				_, _ = output.Write([]byte(task))
+			}
+			_, _ = output.Write([]byte("\n"))
+			gl.flush(task, output)
+		}(task)
+	}
+	wg.Wait()
+	for _, line := range bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n")) {
+		assert.Equal(t, len(line), 100)
+		assert.Equal(t, len(bytes.Trim(line, string(line[:1]))), 0, "output of tasks was interleaved: %s", line)
+	}
+}
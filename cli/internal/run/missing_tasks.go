@@ -0,0 +1,66 @@
+package run
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/vercel/turbo/cli/internal/errcode"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// withMissingTasks returns a copy of pipeline that also defines each of the requested
+// tasks that it doesn't, along with a warning for each of them. The added tasks have
+// no dependencies and aren't cached, since turbo.json says nothing about their
+// outputs, and they run in the workspaces that have a script for them.
+func withMissingTasks(pipeline fs.Pipeline, tasks []string, packageInfos map[interface{}]*fs.PackageJSON, singlePackage bool) (fs.Pipeline, []*errcode.Warning) {
+	var missing []string
+	for _, task := range tasks {
+		if !pipeline.HasTask(task) {
+			missing = append(missing, task)
+		}
+	}
+	if len(missing) == 0 {
+		return pipeline, nil
+	}
+	withMissing := make(fs.Pipeline, len(pipeline)+len(missing))
+	for key, taskDefinition := range pipeline {
+		withMissing[key] = taskDefinition
+	}
+	sort.Strings(missing)
+	var warnings []*errcode.Warning
+	for _, task := range missing {
+		key := task
+		if singlePackage {
+			key = util.RootTaskID(task)
+		}
+		withMissing[key] = fs.TaskDefinition{}
+
+		var workspaces []string
+		if util.IsPackageTask(task) {
+			pkgName, script := util.GetPackageTaskFromId(task)
+			if pkg, ok := packageInfos[pkgName]; ok {
+				if _, ok := pkg.Scripts[script]; ok {
+					workspaces = append(workspaces, pkgName)
+				}
+			}
+		} else {
+			for name, pkg := range packageInfos {
+				if _, ok := pkg.Scripts[task]; ok && (singlePackage || name != util.RootPkgName) {
+					workspaces = append(workspaces, fmt.Sprint(name))
+				}
+			}
+		}
+		var err error
+		switch len(workspaces) {
+		case 0:
+			err = fmt.Errorf("task `%v` is not in the pipeline in turbo.json, and no workspace has a %q script, so it does nothing", task, task)
+		case 1:
+			err = fmt.Errorf("task `%v` is not in the pipeline in turbo.json, running it without caching in the 1 workspace that has the script", task)
+		default:
+			err = fmt.Errorf("task `%v` is not in the pipeline in turbo.json, running it without caching in the %v workspaces that have the script", task, len(workspaces))
+		}
+		warnings = append(warnings, errcode.NewWarning(errcode.MissingTask, err))
+	}
+	return withMissing, warnings
+}
@@ -0,0 +1,55 @@
+package run
+
+import (
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/errcode"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"gotest.tools/v3/assert"
+)
+
+func TestWithMissingTasks(t *testing.T) {
+	pipeline := fs.Pipeline{"build": fs.TaskDefinition{ShouldCache: true}}
+	packageInfos := map[interface{}]*fs.PackageJSON{
+		"//":   {Scripts: map[string]string{"storybook": "storybook"}},
+		"ui":   {Scripts: map[string]string{"build": "tsc", "storybook": "storybook", "lint": "eslint"}},
+		"web":  {Scripts: map[string]string{"build": "next build", "storybook": "storybook"}},
+		"docs": {Scripts: map[string]string{}},
+	}
+
+	withMissing, warnings := withMissingTasks(pipeline, []string{"build"}, packageInfos, false)
+	assert.DeepEqual(t, withMissing, pipeline)
+	assert.Equal(t, len(warnings), 0)
+
+	withMissing, warnings = withMissingTasks(pipeline, []string{"storybook", "build", "lint", "deploy", "web#lint"}, packageInfos, false)
+	assert.DeepEqual(t, withMissing, fs.Pipeline{
+		"build":     fs.TaskDefinition{ShouldCache: true},
+		"deploy":    fs.TaskDefinition{},
+		"lint":      fs.TaskDefinition{},
+		"storybook": fs.TaskDefinition{},
+		"web#lint":  fs.TaskDefinition{},
+	})
+	// The pipeline it was given is left alone
+	assert.Equal(t, len(pipeline), 1)
+	var messages []string
+	for _, warning := range warnings {
+		assert.Equal(t, warning.Code, errcode.MissingTask)
+		messages = append(messages, warning.Error())
+	}
+	assert.DeepEqual(t, messages, []string{
+		"task `deploy` is not in the pipeline in turbo.json, and no workspace has a \"deploy\" script, so it does nothing",
+		"task `lint` is not in the pipeline in turbo.json, running it without caching in the 1 workspace that has the script",
+		"task `storybook` is not in the pipeline in turbo.json, running it without caching in the 2 workspaces that have the script",
+		"task `web#lint` is not in the pipeline in turbo.json, and no workspace has a \"web#lint\" script, so it does nothing",
+	})
+}
+
+func TestWithMissingTasksSinglePackage(t *testing.T) {
+	packageInfos := map[interface{}]*fs.PackageJSON{
+		"//": {Scripts: map[string]string{"storybook": "storybook"}},
+	}
+	withMissing, warnings := withMissingTasks(fs.Pipeline{}, []string{"storybook"}, packageInfos, true)
+	assert.DeepEqual(t, withMissing, fs.Pipeline{"//#storybook": fs.TaskDefinition{}})
+	assert.Equal(t, len(warnings), 1)
+	assert.ErrorContains(t, warnings[0], "running it without caching in the 1 workspace that has the script")
+}
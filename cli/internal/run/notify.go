@@ -0,0 +1,92 @@
+package run
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"time"
+)
+
+// _notifyWebhookTimeout is how long sending the run summary to notifyWebhook can take
+const _notifyWebhookTimeout = 10 * time.Second
+
+// notifyWebhookTokenEnvVar is a bearer token for notifyWebhook, kept out of turbo.json
+const notifyWebhookTokenEnvVar = "TURBO_NOTIFY_WEBHOOK_TOKEN"
+
+// postRunSummary POSTs the summary of a run as JSON to the webhook at url
+func postRunSummary(url string, token string, summary *runSummary) error {
+	body, err := json.Marshal(summary)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	client := &http.Client{Timeout: _notifyWebhookTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%v responded with %v", url, resp.Status)
+	}
+	return nil
+}
+
+// Environment variables that pass the notification to PowerShell, which would
+// otherwise need them quoted into its script
+const (
+	_notificationTitleEnvVar   = "TURBO_NOTIFICATION_TITLE"
+	_notificationMessageEnvVar = "TURBO_NOTIFICATION_MESSAGE"
+)
+
+// _windowsNotificationScript shows a balloon tip from the notification area
+const _windowsNotificationScript = `Add-Type -AssemblyName System.Windows.Forms
+$icon = New-Object System.Windows.Forms.NotifyIcon
+$icon.Icon = [System.Drawing.SystemIcons]::Information
+$icon.Visible = $true
+$icon.ShowBalloonTip(10000, $env:` + _notificationTitleEnvVar + `, $env:` + _notificationMessageEnvVar + `, 'Info')
+Start-Sleep -Seconds 5
+$icon.Dispose()`
+
+// desktopNotificationCommand returns the command that shows a desktop notification on goos
+func desktopNotificationCommand(goos string, title string, message string) *exec.Cmd {
+	switch goos {
+	case "darwin":
+		// The arguments are passed to the script, rather than quoted into it
+		return exec.Command("osascript",
+			"-e", "on run argv",
+			"-e", "display notification (item 2 of argv) with title (item 1 of argv)",
+			"-e", "end run",
+			title, message)
+	case "windows":
+		cmd := exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", _windowsNotificationScript)
+		cmd.Env = append(os.Environ(), _notificationTitleEnvVar+"="+title, _notificationMessageEnvVar+"="+message)
+		return cmd
+	default:
+		return exec.Command("notify-send", "--app-name=turbo", title, message)
+	}
+}
+
+// notifyDesktop shows a native desktop notification
+func notifyDesktop(title string, message string) error {
+	cmd := desktopNotificationCommand(runtime.GOOS, title, message)
+	if err := cmd.Run(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return fmt.Errorf("%v is not installed", cmd.Args[0])
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package run
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestPostRunSummary(t *testing.T) {
+	var received runSummary
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, r.Method, http.MethodPost)
+		assert.Equal(t, r.Header.Get("Content-Type"), "application/json")
+		assert.Equal(t, r.Header.Get("Authorization"), "Bearer secret")
+		assert.NilError(t, json.NewDecoder(r.Body).Decode(&received))
+	}))
+	defer server.Close()
+
+	summary := &runSummary{Version: _runSummaryVersion, ID: "run-1", ExitCode: 1, Cache: cacheStats{Cached: 2}}
+	assert.NilError(t, postRunSummary(server.URL, "secret", summary))
+	assert.Equal(t, received.ID, "run-1")
+	assert.Equal(t, received.ExitCode, 1)
+	assert.Equal(t, received.Cache.Cached, 2)
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer failing.Close()
+	assert.ErrorContains(t, postRunSummary(failing.URL, "", summary), "403 Forbidden")
+}
+
+func TestDesktopNotificationCommand(t *testing.T) {
+	title, message := "turbo run", `✘ build: 1 of 2 tasks failed: "web#build" (1s)`
+
+	cmd := desktopNotificationCommand("darwin", title, message)
+	assert.Equal(t, cmd.Args[0], "osascript")
+	// Quotes in the message aren't interpreted by the script
+	assert.DeepEqual(t, cmd.Args[len(cmd.Args)-2:], []string{title, message})
+
+	cmd = desktopNotificationCommand("linux", title, message)
+	assert.DeepEqual(t, cmd.Args, []string{"notify-send", "--app-name=turbo", title, message})
+
+	cmd = desktopNotificationCommand("windows", title, message)
+	assert.Equal(t, cmd.Args[0], "powershell")
+	env := cmd.Env[len(cmd.Env)-2:]
+	assert.DeepEqual(t, env, []string{"TURBO_NOTIFICATION_TITLE=" + title, "TURBO_NOTIFICATION_MESSAGE=" + message})
+}
@@ -0,0 +1,124 @@
+package run
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sync"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+)
+
+// _maxMatchedLine is the length after which a line without a newline is matched as is
+const _maxMatchedLine = 64 * 1024
+
+// outputFailure watches the output of a task for signs that it failed despite
+// exiting successfully, as configured with failOnStderr and failOnOutput
+type outputFailure struct {
+	taskID   string
+	onStderr bool
+	patterns []*regexp.Regexp
+
+	mu sync.Mutex
+	// failure describes the first line that failed the task
+	failure string
+}
+
+// newOutputFailure returns nil if the task doesn't fail based on its output
+func newOutputFailure(taskID string, taskDefinition *fs.TaskDefinition) (*outputFailure, error) {
+	if !taskDefinition.FailOnStderr && len(taskDefinition.FailOnOutput) == 0 {
+		return nil, nil
+	}
+	of := &outputFailure{taskID: taskID, onStderr: taskDefinition.FailOnStderr}
+	for _, pattern := range taskDefinition.FailOnOutput {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern in \"failOnOutput\": %w", err)
+		}
+		of.patterns = append(of.patterns, re)
+	}
+	return of, nil
+}
+
+// stdout returns a writer for the task's stdout
+func (of *outputFailure) stdout() *outputFailureWriter {
+	return &outputFailureWriter{of: of}
+}
+
+// stderr returns a writer for the task's stderr
+func (of *outputFailure) stderr() *outputFailureWriter {
+	return &outputFailureWriter{of: of, isStderr: true}
+}
+
+func (of *outputFailure) match(line []byte, isStderr bool) {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 {
+		return
+	}
+	failure := ""
+	if isStderr && of.onStderr {
+		failure = fmt.Sprintf("wrote to stderr: %q", line)
+	} else {
+		for _, re := range of.patterns {
+			if re.Match(line) {
+				failure = fmt.Sprintf("output matched %q in \"failOnOutput\": %q", re.String(), line)
+				break
+			}
+		}
+	}
+	if failure == "" {
+		return
+	}
+	of.mu.Lock()
+	defer of.mu.Unlock()
+	if of.failure == "" {
+		of.failure = failure
+	}
+}
+
+// err returns an error describing why the task failed, if it did, once its
+// writers have been closed
+func (of *outputFailure) err() error {
+	of.mu.Lock()
+	defer of.mu.Unlock()
+	if of.failure == "" {
+		return nil
+	}
+	return fmt.Errorf("%v exited successfully, but %v", of.taskID, of.failure)
+}
+
+// outputFailureWriter matches each line written to one of a task's outputs
+type outputFailureWriter struct {
+	of       *outputFailure
+	isStderr bool
+	line     []byte
+}
+
+func (w *outputFailureWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			w.line = append(w.line, p...)
+			if len(w.line) > _maxMatchedLine {
+				w.flush()
+			}
+			break
+		}
+		w.line = append(w.line, p[:i]...)
+		w.flush()
+		p = p[i+1:]
+	}
+	return n, nil
+}
+
+// Close matches the last line, if it didn't end in a newline
+func (w *outputFailureWriter) Close() error {
+	w.flush()
+	return nil
+}
+
+func (w *outputFailureWriter) flush() {
+	w.of.match(w.line, w.isStderr)
+	w.line = w.line[:0]
+}
@@ -0,0 +1,92 @@
+package run
+
+import (
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"gotest.tools/v3/assert"
+)
+
+func Test_outputFailure(t *testing.T) {
+	of, err := newOutputFailure("web#lint", &fs.TaskDefinition{})
+	assert.NilError(t, err)
+	assert.Assert(t, of == nil)
+
+	testCases := []struct {
+		name           string
+		taskDefinition fs.TaskDefinition
+		stdout         string
+		stderr         string
+		expected       string
+	}{
+		{
+			name:           "clean stderr",
+			taskDefinition: fs.TaskDefinition{FailOnStderr: true},
+			stdout:         "compiled\n",
+			stderr:         "\n  \n",
+		},
+		{
+			name:           "stderr",
+			taskDefinition: fs.TaskDefinition{FailOnStderr: true},
+			stdout:         "compiled\n",
+			stderr:         "warning: deprecated\n",
+			expected:       `web#lint exited successfully, but wrote to stderr: "warning: deprecated"`,
+		},
+		{
+			name:           "pattern on stdout",
+			taskDefinition: fs.TaskDefinition{FailOnOutput: []string{"^ERROR"}},
+			stdout:         "compiled\nERROR: missing export\nERROR: missing import\n",
+			expected:       `web#lint exited successfully, but output matched "^ERROR" in "failOnOutput": "ERROR: missing export"`,
+		},
+		{
+			name:           "pattern mid-line",
+			taskDefinition: fs.TaskDefinition{FailOnOutput: []string{"^ERROR"}},
+			stdout:         "no ERROR here\n",
+		},
+		{
+			name:           "last line without a newline",
+			taskDefinition: fs.TaskDefinition{FailOnOutput: []string{`\d+ problems`}},
+			stdout:         "checked\n3 problems",
+			expected:       `web#lint exited successfully, but output matched "\\d+ problems" in "failOnOutput": "3 problems"`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			of, err := newOutputFailure("web#lint", &tc.taskDefinition)
+			assert.NilError(t, err)
+			stdout, stderr := of.stdout(), of.stderr()
+			// Lines arrive in arbitrary pieces
+			for i := 0; i < len(tc.stdout); i += 3 {
+				end := i + 3
+				if end > len(tc.stdout) {
+					end = len(tc.stdout)
+				}
+				_, _ = io.WriteString(stdout, tc.stdout[i:end])
+			}
+			_, _ = io.WriteString(stderr, tc.stderr)
+			assert.NilError(t, stdout.Close())
+			assert.NilError(t, stderr.Close())
+			if tc.expected == "" {
+				assert.NilError(t, of.err())
+			} else {
+				assert.Error(t, of.err(), tc.expected)
+			}
+		})
+	}
+}
+
+func Test_outputFailureLongLine(t *testing.T) {
+	of, err := newOutputFailure("web#lint", &fs.TaskDefinition{FailOnOutput: []string{"^FATAL"}})
+	assert.NilError(t, err)
+	stdout := of.stdout()
+	line := make([]byte, _maxMatchedLine+1)
+	for i := range line {
+		line[i] = 'x'
+	}
+	_, _ = stdout.Write(line)
+	_, _ = fmt.Fprint(stdout, "FATAL\n")
+	// The rest of a long line is matched on its own
+	assert.ErrorContains(t, of.err(), `"FATAL"`)
+}
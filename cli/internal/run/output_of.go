@@ -0,0 +1,185 @@
+package run
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/cli"
+	"github.com/pyr-sh/dag"
+	"github.com/vercel/turbo/cli/internal/core"
+	"github.com/vercel/turbo/cli/internal/globby"
+	"github.com/vercel/turbo/cli/internal/nodes"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/ui"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+const (
+	// _outputPollInterval is how often the outputs of a running task are checked
+	_outputPollInterval = 250 * time.Millisecond
+	// _outputSettleTime is how long outputs must go unchanged to be considered ready
+	_outputSettleTime = time.Second
+)
+
+// outputDependencies returns, for each task in taskGraph, the dependencies that it
+// consumes through outputOf, and for each of those dependencies, the globs of the
+// outputs that are consumed
+func outputDependencies(g *completeGraph, taskGraph *dag.AcyclicGraph) (map[string]util.Set, map[string][]string, error) {
+	outputDeps := make(map[string]util.Set)
+	consumed := make(map[string]util.Set)
+	for _, v := range taskGraph.Vertices() {
+		taskID := dag.VertexName(v)
+		if strings.Contains(taskID, core.ROOT_NODE_NAME) {
+			continue
+		}
+		packageTask, err := g.packageTask(taskID)
+		if err != nil {
+			return nil, nil, err
+		} else if packageTask == nil || len(packageTask.TaskDefinition.OutputOf) == 0 {
+			continue
+		}
+		for _, dep := range taskGraph.DownEdges(taskID) {
+			depID := dag.VertexName(dep)
+			if strings.Contains(depID, core.ROOT_NODE_NAME) {
+				continue
+			}
+			globs, ok := packageTask.TaskDefinition.OutputSubset(taskID, depID)
+			if !ok {
+				continue
+			}
+			if _, ok := outputDeps[taskID]; !ok {
+				outputDeps[taskID] = make(util.Set)
+			}
+			outputDeps[taskID].Add(depID)
+			if _, ok := consumed[depID]; !ok {
+				consumed[depID] = make(util.Set)
+			}
+			for _, glob := range globs {
+				consumed[depID].Add(glob)
+			}
+		}
+	}
+	consumedGlobs := make(map[string][]string, len(consumed))
+	for depID, globs := range consumed {
+		consumedGlobs[depID] = globs.UnsafeListOfStrings()
+		sort.Strings(consumedGlobs[depID])
+	}
+	return outputDeps, consumedGlobs, nil
+}
+
+// outputReadiness watches the outputs of tasks that don't cache their outputs, such as
+// watch tasks, which may never complete. Once the outputs that tasks consume through
+// outputOf have been written and stop changing, those tasks can start.
+type outputReadiness struct {
+	repoRoot turbopath.AbsoluteSystemPath
+	ui       cli.Ui
+	globs    map[string][]string
+	ready    map[string]chan struct{}
+}
+
+func newOutputReadiness(repoRoot turbopath.AbsoluteSystemPath, output cli.Ui, g *completeGraph, consumedGlobs map[string][]string) (*outputReadiness, error) {
+	or := &outputReadiness{
+		repoRoot: repoRoot,
+		ui:       output,
+		globs:    make(map[string][]string),
+		ready:    make(map[string]chan struct{}),
+	}
+	for taskID, globs := range consumedGlobs {
+		packageTask, err := g.packageTask(taskID)
+		if err != nil {
+			return nil, err
+		}
+		// Cached tasks complete, and are ready once they have
+		if packageTask == nil || packageTask.TaskDefinition.ShouldCache {
+			continue
+		}
+		or.globs[taskID] = globs
+		or.ready[taskID] = make(chan struct{})
+	}
+	return or, nil
+}
+
+// readyChannel implements core.ExecOpts.Ready
+func (or *outputReadiness) readyChannel(taskID string) <-chan struct{} {
+	if ready, ok := or.ready[taskID]; ok {
+		return ready
+	}
+	return nil
+}
+
+// watch checks the consumed outputs of packageTask until they are ready, or the
+// returned function is called because the task has exited
+func (or *outputReadiness) watch(packageTask *nodes.PackageTask) func() {
+	ready, ok := or.ready[packageTask.TaskID]
+	if !ok {
+		return func() {}
+	}
+	// Outputs left over from before the task started don't count, allowing for
+	// filesystems that only record modification times to the second
+	started := time.Now().Truncate(time.Second)
+	pkgDir := or.repoRoot.UntypedJoin(packageTask.Pkg.Dir.ToStringDuringMigration())
+	globs := or.globs[packageTask.TaskID]
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(_outputPollInterval)
+		defer ticker.Stop()
+		var last string
+		var unchangedSince time.Time
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			snapshot, written := outputSnapshot(pkgDir, globs, started)
+			if !written {
+				continue
+			}
+			if snapshot != last {
+				last = snapshot
+				unchangedSince = time.Now()
+				continue
+			}
+			if time.Since(unchangedSince) >= _outputSettleTime {
+				or.ui.Output(ui.Dim(fmt.Sprintf("• Outputs of %v are ready, starting the tasks that consume them", packageTask.TaskID)))
+				close(ready)
+				return
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// outputSnapshot describes the files matching globs in dir, and whether any of them
+// was written since started
+func outputSnapshot(dir turbopath.AbsoluteSystemPath, globs []string, started time.Time) (string, bool) {
+	var inclusions []string
+	var exclusions []string
+	for _, glob := range globs {
+		if strings.HasPrefix(glob, "!") {
+			exclusions = append(exclusions, glob[1:])
+		} else {
+			inclusions = append(inclusions, glob)
+		}
+	}
+	files, err := globby.GlobFiles(dir.ToString(), inclusions, exclusions)
+	if err != nil {
+		return "", false
+	}
+	var snapshot strings.Builder
+	written := false
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			continue
+		}
+		if !info.ModTime().Before(started) {
+			written = true
+		}
+		fmt.Fprintf(&snapshot, "%v:%v:%v\n", file, info.Size(), info.ModTime().UnixNano())
+	}
+	return snapshot.String(), written
+}
@@ -0,0 +1,80 @@
+package run
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pyr-sh/dag"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/util"
+	"gotest.tools/v3/assert"
+)
+
+func Test_outputDependencies(t *testing.T) {
+	graph := &completeGraph{
+		PackageInfos: map[interface{}]*fs.PackageJSON{
+			"web": {Name: "web", Dir: fs.UnsafeToAnchoredSystemPath(filepath.Join("apps", "web"))},
+			"ui":  {Name: "ui", Dir: fs.UnsafeToAnchoredSystemPath(filepath.Join("packages", "ui"))},
+		},
+		Pipeline: fs.Pipeline{
+			"build": fs.TaskDefinition{ShouldCache: true},
+			"dev":   fs.TaskDefinition{},
+			"typecheck": fs.TaskDefinition{
+				TaskDependencies: []string{"lint"},
+				OutputOf:         map[string][]string{"^build": {"dist/types/**"}, "^dev": {"dist/types/**", "dist/meta.json"}},
+			},
+			"lint": fs.TaskDefinition{},
+		},
+	}
+	taskGraph := &dag.AcyclicGraph{}
+	for _, taskID := range []string{"web#typecheck", "web#lint", "ui#build", "ui#dev"} {
+		taskGraph.Add(taskID)
+	}
+	taskGraph.Connect(dag.BasicEdge("web#typecheck", "ui#build"))
+	taskGraph.Connect(dag.BasicEdge("web#typecheck", "ui#dev"))
+	taskGraph.Connect(dag.BasicEdge("web#typecheck", "web#lint"))
+
+	outputDeps, consumedGlobs, err := outputDependencies(graph, taskGraph)
+	assert.NilError(t, err, "outputDependencies")
+	assert.DeepEqual(t, outputDeps, map[string]util.Set{
+		"web#typecheck": util.SetFromStrings([]string{"ui#build", "ui#dev"}),
+	})
+	assert.DeepEqual(t, consumedGlobs, map[string][]string{
+		"ui#build": {"dist/types/**"},
+		"ui#dev":   {"dist/meta.json", "dist/types/**"},
+	})
+
+	// Only tasks that aren't cached are watched, since the others complete
+	readiness, err := newOutputReadiness("", nil, graph, consumedGlobs)
+	assert.NilError(t, err, "newOutputReadiness")
+	assert.Assert(t, readiness.readyChannel("ui#build") == nil)
+	assert.Assert(t, readiness.readyChannel("ui#dev") != nil)
+	assert.Assert(t, readiness.readyChannel("web#lint") == nil)
+}
+
+func Test_outputSnapshot(t *testing.T) {
+	dir := turbopath.AbsoluteSystemPathFromUpstream(t.TempDir())
+	types := dir.UntypedJoin("dist", "types", "index.d.ts")
+	assert.NilError(t, types.EnsureDir(), "EnsureDir")
+	assert.NilError(t, types.WriteFile([]byte("export {}"), 0644), "WriteFile")
+	assert.NilError(t, dir.UntypedJoin("dist", "index.js").WriteFile([]byte("export {}"), 0644), "WriteFile")
+	globs := []string{"dist/types/**"}
+
+	stale := time.Now().Add(-time.Hour)
+	assert.NilError(t, os.Chtimes(types.ToString(), stale, stale), "Chtimes")
+	started := time.Now().Truncate(time.Second)
+	first, written := outputSnapshot(dir, globs, started)
+	assert.Assert(t, !written, "outputs from before the task started don't count")
+
+	assert.NilError(t, types.WriteFile([]byte("export declare const a: string;"), 0644), "WriteFile")
+	second, written := outputSnapshot(dir, globs, started)
+	assert.Assert(t, written)
+	assert.Assert(t, first != second)
+
+	assert.NilError(t, dir.UntypedJoin("dist", "index.js").WriteFile([]byte("export const a = 'a'"), 0644), "WriteFile")
+	third, _ := outputSnapshot(dir, globs, started)
+	assert.Equal(t, second, third, "files that aren't consumed don't affect the snapshot")
+}
@@ -0,0 +1,160 @@
+package run
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/cli"
+	"github.com/spf13/pflag"
+	"github.com/vercel/turbo/cli/internal/errcode"
+)
+
+// quietReport stands in for turbo's output during a run with --quiet, and prints
+// a single line, or JSON object, summarizing the run once it's over
+type quietReport struct {
+	output    cli.Ui
+	json      bool
+	targets   []string
+	startedAt time.Time
+	// runState is set once tasks start executing
+	runState *RunState
+}
+
+// newQuietReport returns a report that prints to output, along with a UI that discards
+// everything, to use in place of output until then
+func newQuietReport(output cli.Ui, json bool, targets []string) (*quietReport, cli.Ui) {
+	qr := &quietReport{
+		output:    output,
+		json:      json,
+		targets:   targets,
+		startedAt: time.Now(),
+	}
+	return qr, &cli.BasicUi{
+		Reader:      os.Stdin,
+		Writer:      io.Discard,
+		ErrorWriter: io.Discard,
+	}
+}
+
+// quietSummary is the summary of a run printed with --quiet=json
+type quietSummary struct {
+	Success     bool     `json:"success"`
+	Tasks       int      `json:"tasks"`
+	Successful  int      `json:"successful"`
+	Cached      int      `json:"cached"`
+	Failed      int      `json:"failed"`
+	FailedTasks []string `json:"failedTasks"`
+	DurationMs  int64    `json:"durationMs"`
+	Error       string   `json:"error,omitempty"`
+	Code        string   `json:"code,omitempty"`
+}
+
+func (qr *quietReport) summarize(err error) quietSummary {
+	return summarizeRun(qr.runState, qr.startedAt, err)
+}
+
+// summarizeRun summarizes a run that started at startedAt and ended with err.
+// runState is nil if the run ended before tasks started executing.
+func summarizeRun(runState *RunState, startedAt time.Time, err error) quietSummary {
+	summary := quietSummary{
+		Success:     err == nil,
+		FailedTasks: []string{},
+		DurationMs:  time.Since(startedAt).Milliseconds(),
+	}
+	if runState != nil {
+		summary.Tasks = runState.Attempted
+		summary.Successful = runState.Success + runState.Cached
+		summary.Cached = runState.Cached
+		summary.Failed = runState.Failure
+		for _, result := range runState.Results() {
+			if result.Status == TargetBuildFailed {
+				summary.FailedTasks = append(summary.FailedTasks, result.Label)
+			}
+		}
+	}
+	// A failed task is explained by the list of failed tasks, anything else by the error
+	if err != nil && errcode.Of(err) != errcode.TaskFailed {
+		summary.Error = err.Error()
+		if code := errcode.Of(err); code != errcode.Unknown {
+			summary.Code = string(code)
+		}
+	}
+	return summary
+}
+
+// text renders the summary as a single line
+func (qs quietSummary) text(targets []string) string {
+	name := strings.Join(targets, ", ")
+	if qs.Error != "" {
+		return fmt.Sprintf("✘ %v: %v", name, qs.Error)
+	}
+	duration := (time.Duration(qs.DurationMs) * time.Millisecond).String()
+	if qs.Failed > 0 {
+		return fmt.Sprintf("✘ %v: %v of %v tasks failed: %v (%v)", name, qs.Failed, qs.Tasks, strings.Join(qs.FailedTasks, ", "), duration)
+	}
+	return fmt.Sprintf("✔ %v: %v tasks successful, %v cached (%v)", name, qs.Successful, qs.Cached, duration)
+}
+
+// print writes the summary of a run that ended with err
+func (qr *quietReport) print(err error) {
+	summary := qr.summarize(err)
+	if !qr.json {
+		qr.output.Output(summary.text(qr.targets))
+		return
+	}
+	bytes, jsonErr := json.Marshal(summary)
+	if jsonErr != nil {
+		qr.output.Output(summary.text(qr.targets))
+		return
+	}
+	qr.output.Output(string(bytes))
+}
+
+// quiet custom flag
+const (
+	_quietText      = "text"
+	_quietJSONValue = "json"
+	_quietNoValue   = "text|json"
+)
+
+// quietValue implements a flag that can be treated as a boolean (--quiet)
+// or a string (--quiet=json).
+type quietValue struct {
+	opts *runOpts
+}
+
+var _ pflag.Value = &quietValue{}
+
+func (q *quietValue) String() string {
+	if q.opts.quietJSON {
+		return _quietJSONValue
+	} else if q.opts.quiet {
+		return _quietText
+	}
+	return ""
+}
+
+func (q *quietValue) Set(value string) error {
+	switch value {
+	case _quietJSONValue:
+		q.opts.quiet = true
+		q.opts.quietJSON = true
+	case _quietNoValue, _quietText:
+		// the NoOptDefValue is used when the flag is passed without a value
+		q.opts.quiet = true
+		q.opts.quietJSON = false
+	default:
+		return fmt.Errorf("invalid quiet mode: %v", value)
+	}
+	return nil
+}
+
+// Type implements Value.Type, and in this case is used to
+// show the possible values in the usage text.
+func (q *quietValue) Type() string {
+	return ""
+}
@@ -0,0 +1,52 @@
+package run
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/vercel/turbo/cli/internal/errcode"
+	"github.com/vercel/turbo/cli/internal/process"
+	"gotest.tools/v3/assert"
+)
+
+func TestQuietSummary(t *testing.T) {
+	runState := NewRunState(time.Now(), "")
+	runState.Run("ui#build")(TargetCached, nil)
+	runState.Run("web#build")(TargetBuilt, nil)
+	runState.Run("web#test")(TargetBuildFailed, errors.New("exit status 1"))
+	qr := &quietReport{targets: []string{"build", "test"}, startedAt: time.Now(), runState: runState}
+
+	summary := qr.summarize(errcode.Wrap(errcode.TaskFailed, &process.ChildExit{ExitCode: 1}))
+	summary.DurationMs = 1500
+	assert.DeepEqual(t, summary, quietSummary{
+		Tasks:       3,
+		Successful:  2,
+		Cached:      1,
+		Failed:      1,
+		FailedTasks: []string{"web#test"},
+		DurationMs:  1500,
+	})
+	assert.Equal(t, summary.text(qr.targets), "✘ build, test: 1 of 3 tasks failed: web#test (1.5s)")
+
+	summary = qr.summarize(nil)
+	summary.Failed = 0
+	summary.DurationMs = 250
+	assert.Equal(t, summary.text(qr.targets), "✔ build, test: 2 tasks successful, 1 cached (250ms)")
+
+	// Failures before any task ran are described by the error
+	qr = &quietReport{targets: []string{"lint"}, startedAt: time.Now()}
+	summary = qr.summarize(errcode.Wrap(errcode.MissingTask, errors.New("task `lint` not found")))
+	assert.Equal(t, summary.Code, "config/missing-task")
+	assert.Equal(t, summary.text(qr.targets), "✘ lint: task `lint` not found")
+}
+
+func TestQuietValue(t *testing.T) {
+	opts := &runOpts{}
+	value := &quietValue{opts: opts}
+	assert.NilError(t, value.Set(_quietNoValue))
+	assert.Equal(t, value.String(), "text")
+	assert.NilError(t, value.Set("json"))
+	assert.Assert(t, opts.quiet && opts.quietJSON)
+	assert.ErrorContains(t, value.Set("loud"), "invalid quiet mode")
+}
@@ -0,0 +1,118 @@
+package run
+
+import (
+	gocontext "context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/pyr-sh/dag"
+	"github.com/vercel/turbo/cli/internal/agent"
+	"github.com/vercel/turbo/cli/internal/core"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/nodes"
+	"github.com/vercel/turbo/cli/internal/process"
+)
+
+// remoteAgents dispatches tasks to `turbo agent` processes on other machines.
+// Tasks are sent along with the outputs of every task they depend on, and their
+// outputs are restored locally once they succeed, so the rest of the run, including
+// caching, is unaware that they ran elsewhere.
+type remoteAgents struct {
+	pool      *agent.Pool
+	graph     *completeGraph
+	taskGraph *dag.AcyclicGraph
+}
+
+// runsRemotely returns whether a task should be sent to an agent. Only cacheable
+// tasks are, since they have declared their outputs and aren't long-running.
+func (ra *remoteAgents) runsRemotely(packageTask *nodes.PackageTask) bool {
+	return packageTask.TaskDefinition.ShouldCache
+}
+
+// exec runs a task on an agent, writing its output to stdout and stderr
+func (ra *remoteAgents) exec(ec *execContext, packageTask *nodes.PackageTask, hash string, passThroughArgs []string, stdout io.Writer, stderr io.Writer) error {
+	inputs, err := ra.dependencyOutputs(packageTask.TaskID)
+	if err != nil {
+		return err
+	}
+	artifact, err := agent.Pack(ec.repoRoot, inputs)
+	if err != nil {
+		return fmt.Errorf("failed to collect the outputs of the dependencies of %v: %w", packageTask.TaskID, err)
+	}
+	req := &agent.TaskRequest{
+		TaskID:  packageTask.TaskID,
+		Hash:    hash,
+		Dir:     packageTask.Pkg.Dir.ToStringDuringMigration(),
+		Script:  packageTask.Task,
+		Args:    passThroughArgs,
+		Outputs: repoRelativeOutputs(packageTask),
+		Inputs:  artifact,
+	}
+	var result *agent.Result
+	err = ec.processes.ExecFunc(func(ctx gocontext.Context) error {
+		var err error
+		result, err = ra.pool.Run(ctx, req, stdout, stderr)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	ec.logger.Debug("ran task on agent", "task", packageTask.TaskID, "agent", result.Agent, "exitCode", result.ExitCode)
+	if result.ExitCode != 0 {
+		return &process.ChildExit{
+			ExitCode: result.ExitCode,
+			Command:  fmt.Sprintf("%v on %v", packageTask.TaskID, result.Agent),
+		}
+	}
+	if _, err := agent.Unpack(ec.repoRoot, result.Outputs); err != nil {
+		return fmt.Errorf("failed to restore outputs of %v from %v: %w", packageTask.TaskID, result.Agent, err)
+	}
+	return nil
+}
+
+// dependencyOutputs returns the repo-relative globs for the outputs of every task
+// that taskID depends on, directly or transitively
+func (ra *remoteAgents) dependencyOutputs(taskID string) (fs.TaskOutputs, error) {
+	var outputs fs.TaskOutputs
+	ancestors, err := ra.taskGraph.Ancestors(taskID)
+	if err != nil {
+		return outputs, err
+	}
+	for _, ancestor := range ancestors {
+		ancestorID := ancestor.(string)
+		if strings.Contains(ancestorID, core.ROOT_NODE_NAME) {
+			continue
+		}
+		packageTask, err := ra.graph.packageTask(ancestorID)
+		if err != nil {
+			return outputs, err
+		} else if packageTask == nil {
+			continue
+		}
+		taskOutputs := repoRelativeOutputs(packageTask)
+		outputs.Inclusions = append(outputs.Inclusions, taskOutputs.Inclusions...)
+		outputs.Exclusions = append(outputs.Exclusions, taskOutputs.Exclusions...)
+	}
+	return outputs, nil
+}
+
+// repoRelativeOutputs returns the globs for the outputs of a task, relative to the
+// root of the repository. Unlike the cache, the log file is left out, since logs are
+// streamed instead.
+func repoRelativeOutputs(packageTask *nodes.PackageTask) fs.TaskOutputs {
+	outputs := packageTask.TaskDefinition.Outputs
+	dir := packageTask.Pkg.Dir.ToStringDuringMigration()
+	globs := fs.TaskOutputs{
+		Inclusions: make([]string, len(outputs.Inclusions)),
+		Exclusions: make([]string, len(outputs.Exclusions)),
+	}
+	for i, output := range outputs.Inclusions {
+		globs.Inclusions[i] = filepath.Join(dir, output)
+	}
+	for i, output := range outputs.Exclusions {
+		globs.Exclusions[i] = filepath.Join(dir, output)
+	}
+	return globs
+}
@@ -0,0 +1,61 @@
+package run
+
+import (
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/pyr-sh/dag"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"gotest.tools/v3/assert"
+)
+
+func Test_dependencyOutputs(t *testing.T) {
+	graph := &completeGraph{
+		PackageInfos: map[interface{}]*fs.PackageJSON{
+			"web":  {Name: "web", Dir: fs.UnsafeToAnchoredSystemPath(filepath.Join("apps", "web"))},
+			"ui":   {Name: "ui", Dir: fs.UnsafeToAnchoredSystemPath(filepath.Join("packages", "ui"))},
+			"util": {Name: "util", Dir: fs.UnsafeToAnchoredSystemPath(filepath.Join("packages", "util"))},
+		},
+		Pipeline: fs.Pipeline{
+			"build": fs.TaskDefinition{
+				Outputs:     fs.TaskOutputs{Inclusions: []string{"dist/**"}, Exclusions: []string{"dist/cache/**"}},
+				ShouldCache: true,
+			},
+			"codegen": fs.TaskDefinition{
+				Outputs:     fs.TaskOutputs{Inclusions: []string{"generated/**"}},
+				ShouldCache: true,
+			},
+		},
+	}
+	taskGraph := &dag.AcyclicGraph{}
+	for _, taskID := range []string{"web#build", "ui#build", "util#build", "util#codegen", "util#lint"} {
+		taskGraph.Add(taskID)
+	}
+	taskGraph.Connect(dag.BasicEdge("web#build", "ui#build"))
+	taskGraph.Connect(dag.BasicEdge("ui#build", "util#build"))
+	taskGraph.Connect(dag.BasicEdge("util#build", "util#codegen"))
+	// util#lint has no definition in the pipeline, so it has no outputs
+	taskGraph.Connect(dag.BasicEdge("util#build", "util#lint"))
+	ra := &remoteAgents{graph: graph, taskGraph: taskGraph}
+
+	outputs, err := ra.dependencyOutputs("web#build")
+	assert.NilError(t, err, "dependencyOutputs")
+	sort.Strings(outputs.Inclusions)
+	sort.Strings(outputs.Exclusions)
+	assert.DeepEqual(t, outputs, fs.TaskOutputs{
+		Inclusions: []string{
+			filepath.Join("packages", "ui", "dist", "**"),
+			filepath.Join("packages", "util", "dist", "**"),
+			filepath.Join("packages", "util", "generated", "**"),
+		},
+		Exclusions: []string{
+			filepath.Join("packages", "ui", "dist", "cache", "**"),
+			filepath.Join("packages", "util", "dist", "cache", "**"),
+		},
+	})
+
+	outputs, err = ra.dependencyOutputs("util#codegen")
+	assert.NilError(t, err, "dependencyOutputs")
+	assert.Equal(t, len(outputs.Inclusions), 0)
+}
@@ -0,0 +1,52 @@
+package run
+
+import (
+	"strings"
+
+	"github.com/pyr-sh/dag"
+	"github.com/vercel/turbo/cli/internal/core"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// requestedTasks returns the tasks of the packages that a filtered run is for: the
+// filtered packages that no other filtered package depends on, such as the app
+// selected by --filter=web..., rather than the packages that it depends on. These
+// tasks, and the tasks they depend on, start ahead of other tasks, such as the
+// tasks of sibling packages that were only pulled in as dependencies. Runs that
+// include every package request nothing in particular, and nil is returned.
+func requestedTasks(g *completeGraph, filteredPkgs util.Set, taskGraph *dag.AcyclicGraph) util.Set {
+	filtered := false
+	for pkg := range g.PackageInfos {
+		if pkg != util.RootPkgName && !filteredPkgs.Includes(pkg) {
+			filtered = true
+			break
+		}
+	}
+	if !filtered {
+		return nil
+	}
+	terminal := make(util.Set)
+	for _, pkg := range filteredPkgs.UnsafeListOfStrings() {
+		hasDependent := false
+		for _, dependent := range g.TopologicalGraph.UpEdges(pkg) {
+			if filteredPkgs.Includes(dag.VertexName(dependent)) {
+				hasDependent = true
+				break
+			}
+		}
+		if !hasDependent {
+			terminal.Add(pkg)
+		}
+	}
+	requested := make(util.Set)
+	for _, v := range taskGraph.Vertices() {
+		taskID := dag.VertexName(v)
+		if strings.Contains(taskID, core.ROOT_NODE_NAME) {
+			continue
+		}
+		if pkg, _ := util.GetPackageTaskFromId(taskID); terminal.Includes(pkg) {
+			requested.Add(taskID)
+		}
+	}
+	return requested
+}
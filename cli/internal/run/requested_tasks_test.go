@@ -0,0 +1,43 @@
+package run
+
+import (
+	"testing"
+
+	"github.com/pyr-sh/dag"
+	"github.com/vercel/turbo/cli/internal/core"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/util"
+	"gotest.tools/v3/assert"
+)
+
+func TestRequestedTasks(t *testing.T) {
+	g := &completeGraph{PackageInfos: make(map[interface{}]*fs.PackageJSON)}
+	for _, pkg := range []string{util.RootPkgName, "web", "docs", "ui", "utils"} {
+		g.PackageInfos[pkg] = &fs.PackageJSON{Name: pkg}
+		g.TopologicalGraph.Add(pkg)
+	}
+	g.TopologicalGraph.Connect(dag.BasicEdge("web", "ui"))
+	g.TopologicalGraph.Connect(dag.BasicEdge("docs", "ui"))
+	g.TopologicalGraph.Connect(dag.BasicEdge("ui", "utils"))
+
+	taskGraph := &dag.AcyclicGraph{}
+	taskGraph.Add(core.ROOT_NODE_NAME)
+	for _, taskID := range []string{"web#build", "web#lint", "ui#build", "ui#lint", "utils#build", "utils#lint"} {
+		taskGraph.Add(taskID)
+	}
+
+	filtered := func(pkgs ...string) util.Set {
+		set := make(util.Set)
+		for _, pkg := range pkgs {
+			set.Add(pkg)
+		}
+		return set
+	}
+	// --filter=web...
+	requested := requestedTasks(g, filtered("web", "ui", "utils"), taskGraph)
+	assert.DeepEqual(t, requested, filtered("web#build", "web#lint"))
+
+	// Without a filter, no task is requested in particular
+	requested = requestedTasks(g, filtered("web", "docs", "ui", "utils"), taskGraph)
+	assert.Assert(t, requested == nil)
+}
@@ -0,0 +1,94 @@
+package run
+
+import (
+	gocontext "context"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/pyr-sh/dag"
+	"github.com/vercel/turbo/cli/internal/core"
+	"github.com/vercel/turbo/cli/internal/nodes"
+	"github.com/vercel/turbo/cli/internal/runcache"
+	"github.com/vercel/turbo/cli/internal/taskhash"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// restoreAhead hashes the tasks of a run ahead of the run itself, and queues the
+// outputs of cache hits to be restored by the workers of the run cache, so that
+// chains of cache hits don't restore one task at a time. A task is only restored
+// ahead if every task it depends on is a cache hit too, so that nothing a task
+// depends on runs after its outputs were restored.
+type restoreAhead struct {
+	engine      *core.Engine
+	tracker     *taskhash.Tracker
+	runCache    *runcache.RunCache
+	rs          *runSpec
+	resumeState *resumeState
+	logger      hclog.Logger
+
+	cancel gocontext.CancelFunc
+	done   chan struct{}
+	mu     sync.Mutex
+	// hits are the tasks whose outputs are restored ahead, or need no restoring
+	hits util.Set
+}
+
+// start begins hashing and queueing tasks in the background, using up to
+// concurrency goroutines
+func (ra *restoreAhead) start(ctx gocontext.Context, g *completeGraph, concurrency int) {
+	ctx, ra.cancel = gocontext.WithCancel(ctx)
+	ra.done = make(chan struct{})
+	ra.hits = make(util.Set)
+	go func() {
+		defer close(ra.done)
+		ra.engine.Execute(g.getPackageTaskVisitor(ctx, ra.visit), core.ExecOpts{
+			Context:     ctx,
+			Concurrency: concurrency,
+		})
+	}()
+}
+
+// visit queues the outputs of a single task, if it and the tasks it depends on are
+// cache hits
+func (ra *restoreAhead) visit(ctx gocontext.Context, packageTask *nodes.PackageTask) error {
+	if len(packageTask.TaskDefinition.OutputOf) > 0 {
+		// Its hash depends on outputs that may not have been produced yet
+		return nil
+	}
+	deps := ra.engine.TaskGraph.DownEdges(packageTask.TaskID)
+	ra.mu.Lock()
+	for _, dep := range deps {
+		depID := dag.VertexName(dep)
+		if !strings.Contains(depID, core.ROOT_NODE_NAME) && !ra.hits.Includes(depID) {
+			ra.mu.Unlock()
+			return nil
+		}
+	}
+	ra.mu.Unlock()
+	// The run calculates the same hash, since the hashes of its dependencies are
+	// known, and none of them depend on outputs
+	hash, err := ra.tracker.CalculateTaskHash(packageTask, deps, ra.logger, ra.rs.ArgsForTask(packageTask.TaskID))
+	if err != nil {
+		return nil
+	}
+	hit := true
+	if _, ok := packageTask.Command(); ok && !packageTask.TaskDefinition.Passthrough && !ra.resumeState.alreadySucceeded(packageTask.TaskID, hash) {
+		hit, err = ra.runCache.TaskCache(packageTask, hash).RestoreAhead(ctx)
+		if err != nil {
+			ra.logger.Debug("failed to check the cache", "task", packageTask.TaskID, "error", err)
+		}
+	}
+	if hit {
+		ra.mu.Lock()
+		ra.hits.Add(packageTask.TaskID)
+		ra.mu.Unlock()
+	}
+	return nil
+}
+
+// stop stops queueing tasks, and waits for the tasks being queued
+func (ra *restoreAhead) stop() {
+	ra.cancel()
+	<-ra.done
+}
@@ -0,0 +1,192 @@
+package run
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+// resumeStateFile is the repo-relative location of the state that
+// --resume consults to skip tasks that already succeeded
+var resumeStateFile = []string{".turbo", "run-state.json"}
+
+// runStatePath returns the location of a file, such as resumeStateFile, that records
+// the state of runs in the repository. If the repository is on a read-only mount, the
+// file is kept in the user's cache directory instead.
+func runStatePath(repoRoot turbopath.AbsoluteSystemPath, file []string) turbopath.AbsoluteSystemPath {
+	dir, _ := fs.WritableDir(repoRoot, repoRoot.UntypedJoin(file[:len(file)-1]...))
+	return dir.UntypedJoin(file[len(file)-1])
+}
+
+// resumeState tracks which package-tasks succeeded, and with which hash,
+// so that a subsequent `turbo run --resume` can skip them without depending
+// on the cache, which may be disabled for some tasks. Progress is checkpointed
+// as it is made, so that it is not lost if turbo crashes.
+type resumeState struct {
+	mu         sync.Mutex
+	path       turbopath.AbsoluteSystemPath
+	previous   map[string]string
+	checkpoint *checkpoint
+	// saved holds the tasks of this run whose outputs were saved to the cache
+	saved map[string]bool
+	// interruptSummary is where the summary of this run is written if it's interrupted
+	interruptSummary turbopath.AbsoluteSystemPath
+	// Interrupted is the progress of the previous run, if it did not finish
+	Interrupted *interruptedRun `json:"-"`
+	// Succeeded is a map of taskID -> hash for every task that completed successfully
+	// in this run
+	Succeeded map[string]string `json:"succeeded"`
+	// Carried is a map of taskID -> hash for the tasks completed by an interrupted
+	// run that this run didn't complete itself, such as because it ran other tasks.
+	// They're kept apart from Succeeded, so that a later --resume can still skip them
+	// without them counting as part of this run.
+	Carried map[string]string `json:"carried,omitempty"`
+}
+
+// newResumeState creates a resumeState for the given repository. If resume is true,
+// the state recorded by the previous run is loaded so it can be consulted, including
+// the progress made by a previous run that was interrupted.
+func newResumeState(repoRoot turbopath.AbsoluteSystemPath, resume bool) (*resumeState, error) {
+	rs := &resumeState{
+		path:      runStatePath(repoRoot, resumeStateFile),
+		previous:  make(map[string]string),
+		Succeeded: make(map[string]string),
+		Carried:   make(map[string]string),
+		saved:     make(map[string]bool),
+
+		interruptSummary: runStatePath(repoRoot, interruptSummaryFile),
+	}
+	interrupted, err := readCheckpoint(repoRoot)
+	if err != nil {
+		return nil, err
+	}
+	rs.Interrupted = interrupted
+	recorded, err := readRunState(repoRoot)
+	if err != nil {
+		return nil, err
+	}
+	// The progress of an interrupted run is kept, even by runs that don't resume
+	// it, such as a run of other tasks, until another run is interrupted. The
+	// checkpoint of an interrupted run already includes what it carried.
+	carried := recorded.Carried
+	if interrupted != nil {
+		carried = interrupted.Completed
+	}
+	for taskID, hash := range carried {
+		rs.Carried[taskID] = hash
+	}
+	if resume {
+		for taskID, hash := range recorded.Succeeded {
+			rs.previous[taskID] = hash
+		}
+		for taskID, hash := range carried {
+			rs.previous[taskID] = hash
+		}
+	}
+	rs.checkpoint, err = openCheckpoint(repoRoot, carried)
+	if err != nil {
+		return nil, err
+	}
+	return rs, nil
+}
+
+// readRunState reads the state written by the most recent run that finished
+func readRunState(repoRoot turbopath.AbsoluteSystemPath) (*resumeState, error) {
+	bytes, err := runStatePath(repoRoot, resumeStateFile).ReadFile()
+	if os.IsNotExist(err) {
+		return &resumeState{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	previous := &resumeState{}
+	if err := json.Unmarshal(bytes, previous); err != nil {
+		return nil, err
+	}
+	return previous, nil
+}
+
+// LastRunHashes returns a map of taskID -> hash for every task that succeeded
+// in the most recent run in the given repository
+func LastRunHashes(repoRoot turbopath.AbsoluteSystemPath) (map[string]string, error) {
+	previous, err := readRunState(repoRoot)
+	if err != nil {
+		return nil, err
+	}
+	if previous.Succeeded == nil {
+		return map[string]string{}, nil
+	}
+	return previous.Succeeded, nil
+}
+
+// alreadySucceeded returns true if the previous run completed this task
+// with the same hash, meaning its inputs have not changed.
+func (rs *resumeState) alreadySucceeded(taskID string, hash string) bool {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	previousHash, ok := rs.previous[taskID]
+	return ok && hash != "" && previousHash == hash
+}
+
+// recordStart checkpoints that a task has started executing
+func (rs *resumeState) recordStart(taskID string, hash string) {
+	rs.checkpoint.record(checkpointStarted, taskID, hash)
+}
+
+// recordSaved checkpoints that a task's outputs were saved to the cache
+func (rs *resumeState) recordSaved(taskID string, hash string) {
+	rs.checkpoint.record(checkpointSaved, taskID, hash)
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.saved[taskID] = true
+}
+
+// outputsSaved returns true if this run saved the outputs of a task to the cache
+func (rs *resumeState) outputsSaved(taskID string) bool {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	return rs.saved[taskID]
+}
+
+// recordFailure checkpoints that a task failed
+func (rs *resumeState) recordFailure(taskID string, hash string) {
+	rs.checkpoint.record(checkpointFailed, taskID, hash)
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	// It may have succeeded in an interrupted run
+	delete(rs.Succeeded, taskID)
+	delete(rs.Carried, taskID)
+}
+
+// recordSuccess marks a task as having succeeded with the given hash
+func (rs *resumeState) recordSuccess(taskID string, hash string) {
+	rs.checkpoint.record(checkpointSucceeded, taskID, hash)
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.Succeeded[taskID] = hash
+	delete(rs.Carried, taskID)
+}
+
+// Close writes the current state to disk for a future --resume, and removes
+// the checkpoint log and any summary of an interrupted run, since this run was
+// not interrupted
+func (rs *resumeState) Close() error {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	bytes, err := json.MarshalIndent(rs, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := rs.path.EnsureDir(); err != nil {
+		return err
+	}
+	if err := rs.path.WriteFile(bytes, 0644); err != nil {
+		return err
+	}
+	if err := rs.interruptSummary.Remove(); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return rs.checkpoint.close()
+}
@@ -0,0 +1,129 @@
+package run
+
+import (
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"gotest.tools/v3/assert"
+)
+
+func TestResumeState(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+
+	first, err := newResumeState(repoRoot, true)
+	assert.NilError(t, err, "newResumeState with no previous state")
+	assert.Assert(t, !first.alreadySucceeded("a#build", "abc"))
+	first.recordSuccess("a#build", "abc")
+	first.recordSuccess("b#build", "def")
+	assert.NilError(t, first.Close(), "Close")
+
+	resumed, err := newResumeState(repoRoot, true)
+	assert.NilError(t, err, "newResumeState")
+	assert.Assert(t, resumed.alreadySucceeded("a#build", "abc"))
+	assert.Assert(t, !resumed.alreadySucceeded("a#build", "changed"))
+	assert.Assert(t, !resumed.alreadySucceeded("c#build", "abc"))
+
+	fresh, err := newResumeState(repoRoot, false)
+	assert.NilError(t, err, "newResumeState without resume")
+	assert.Assert(t, !fresh.alreadySucceeded("a#build", "abc"))
+}
+
+func TestResumeStateAfterCrash(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+
+	crashed, err := newResumeState(repoRoot, false)
+	assert.NilError(t, err, "newResumeState")
+	crashed.recordStart("a#build", "abc")
+	crashed.recordSaved("a#build", "abc")
+	crashed.recordSuccess("a#build", "abc")
+	crashed.recordStart("b#build", "def")
+	crashed.recordStart("c#build", "ghi")
+	crashed.recordFailure("c#build", "ghi")
+	// no Close, as if turbo had crashed
+
+	resumed, err := newResumeState(repoRoot, true)
+	assert.NilError(t, err, "newResumeState after crash")
+	assert.Assert(t, resumed.Interrupted != nil)
+	assert.Equal(t, resumed.Interrupted.InProgress, 1)
+	assert.DeepEqual(t, resumed.Interrupted.Completed, map[string]string{"a#build": "abc"})
+	assert.Assert(t, resumed.alreadySucceeded("a#build", "abc"))
+	assert.Assert(t, !resumed.alreadySucceeded("b#build", "def"))
+	assert.NilError(t, resumed.Close(), "Close")
+
+	finished, err := newResumeState(repoRoot, true)
+	assert.NilError(t, err, "newResumeState after a finished run")
+	assert.Assert(t, finished.Interrupted == nil)
+	// The resumed run carried the progress of the interrupted run into its state
+	assert.Assert(t, finished.alreadySucceeded("a#build", "abc"))
+	assert.Assert(t, !finished.alreadySucceeded("b#build", "def"))
+}
+
+func TestResumeStateKeepsInterruptedProgress(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+
+	crashed, err := newResumeState(repoRoot, false)
+	assert.NilError(t, err, "newResumeState")
+	crashed.recordStart("a#build", "abc")
+	crashed.recordSuccess("a#build", "abc")
+	crashed.recordStart("b#build", "def")
+	crashed.recordSuccess("b#build", "def")
+	crashed.recordStart("c#build", "ghi")
+	// no Close, as if turbo had crashed
+
+	// A run of other tasks, without --resume, is interrupted too
+	other, err := newResumeState(repoRoot, false)
+	assert.NilError(t, err, "newResumeState")
+	other.recordStart("a#lint", "jkl")
+	other.recordSuccess("a#lint", "jkl")
+	// b#build fails in this run, so it no longer counts as completed
+	other.recordStart("b#build", "def")
+	other.recordFailure("b#build", "def")
+
+	interrupted, err := readCheckpoint(repoRoot)
+	assert.NilError(t, err, "readCheckpoint")
+	assert.DeepEqual(t, interrupted.Completed, map[string]string{"a#build": "abc", "a#lint": "jkl"})
+
+	// A run that finishes keeps the progress in its state for --resume
+	finished, err := newResumeState(repoRoot, false)
+	assert.NilError(t, err, "newResumeState")
+	assert.NilError(t, finished.Close(), "Close")
+	resumed, err := newResumeState(repoRoot, true)
+	assert.NilError(t, err, "newResumeState")
+	assert.Assert(t, resumed.alreadySucceeded("a#build", "abc"))
+	assert.Assert(t, resumed.alreadySucceeded("a#lint", "jkl"))
+	assert.Assert(t, !resumed.alreadySucceeded("b#build", "def"))
+}
+
+func TestResumeStateOnlyPreviousRun(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+
+	build, err := newResumeState(repoRoot, false)
+	assert.NilError(t, err, "newResumeState")
+	build.recordSuccess("a#build", "abc")
+	assert.NilError(t, build.Close(), "Close")
+
+	crashed, err := newResumeState(repoRoot, false)
+	assert.NilError(t, err, "newResumeState")
+	crashed.recordStart("b#build", "def")
+	crashed.recordSuccess("b#build", "def")
+	// no Close, as if turbo had crashed
+
+	lint, err := newResumeState(repoRoot, false)
+	assert.NilError(t, err, "newResumeState")
+	lint.recordSuccess("a#lint", "ghi")
+	assert.NilError(t, lint.Close(), "Close")
+
+	// The last run is only the tasks that it ran, without the interrupted progress
+	// that it carried
+	lastRun, err := LastRunHashes(repoRoot)
+	assert.NilError(t, err, "LastRunHashes")
+	assert.DeepEqual(t, lastRun, map[string]string{"a#lint": "ghi"})
+
+	// Successes of runs before the previous one aren't skipped, as their outputs may
+	// have changed since
+	resumed, err := newResumeState(repoRoot, true)
+	assert.NilError(t, err, "newResumeState")
+	assert.Assert(t, resumed.alreadySucceeded("a#lint", "ghi"))
+	assert.Assert(t, resumed.alreadySucceeded("b#build", "def"))
+	assert.Assert(t, !resumed.alreadySucceeded("a#build", "abc"))
+}
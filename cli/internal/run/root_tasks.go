@@ -0,0 +1,46 @@
+package run
+
+import (
+	"fmt"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// checkRootTasks looks for targets that are the name of both a script in the root
+// package.json and of a workspace task. Whether the root script runs depends only on
+// whether turbo.json has a //#<task> entry for it, so a missing or unintended entry
+// silently changes what runs. It returns a warning for each likely mistake, and an
+// error for a root task that would run turbo recursively.
+func checkRootTasks(pipeline fs.Pipeline, rootPackageJSON *fs.PackageJSON, targets []string) ([]string, error) {
+	var warnings []string
+	for _, target := range targets {
+		if util.IsPackageTask(target) {
+			pkg, task := util.GetPackageTaskFromId(target)
+			if pkg != util.RootPkgName {
+				continue
+			}
+			script, ok := rootPackageJSON.Scripts[task]
+			if !ok {
+				warnings = append(warnings, fmt.Sprintf("%v does nothing because the root package.json has no %q script", target, task))
+			} else if commandLooksLikeTurbo(script) {
+				return nil, fmt.Errorf("%v runs the root script %q (%v), which would invoke turbo recursively", target, task, script)
+			}
+			continue
+		}
+
+		rootTaskID := util.RootTaskID(target)
+		script, hasScript := rootPackageJSON.Scripts[target]
+		_, hasRootTask := pipeline[rootTaskID]
+		if !hasScript {
+			continue
+		}
+		looksLikeTurbo := commandLooksLikeTurbo(script)
+		if hasRootTask && looksLikeTurbo {
+			return nil, fmt.Errorf("the root script %q (%v) invokes turbo, so it cannot also be a task. Remove %q from turbo.json, or rename the script", target, script, rootTaskID)
+		} else if !hasRootTask && !looksLikeTurbo {
+			warnings = append(warnings, fmt.Sprintf("the root package.json has a %q script, which is not run because turbo.json has no %q entry. Add %q to turbo.json to run it along with the workspace tasks", target, rootTaskID, rootTaskID))
+		}
+	}
+	return warnings, nil
+}
@@ -0,0 +1,57 @@
+package run
+
+import (
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"gotest.tools/v3/assert"
+)
+
+func Test_checkRootTasks(t *testing.T) {
+	rootPackageJSON := &fs.PackageJSON{
+		Scripts: map[string]string{
+			"build":  "turbo run build",
+			"lint":   "eslint .",
+			"format": "prettier --write .",
+			"dev":    "turbo run dev --parallel",
+		},
+	}
+	pipeline := fs.Pipeline{
+		"build":     fs.TaskDefinition{},
+		"lint":      fs.TaskDefinition{},
+		"format":    fs.TaskDefinition{},
+		"//#format": fs.TaskDefinition{},
+		"//#test":   fs.TaskDefinition{},
+		"dev":       fs.TaskDefinition{},
+		"//#dev":    fs.TaskDefinition{},
+	}
+
+	warnings, err := checkRootTasks(pipeline, rootPackageJSON, []string{"build", "format", "test"})
+	assert.NilError(t, err)
+	assert.Equal(t, len(warnings), 0)
+
+	warnings, err = checkRootTasks(pipeline, rootPackageJSON, []string{"lint"})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, warnings, []string{
+		`the root package.json has a "lint" script, which is not run because turbo.json has no "//#lint" entry. Add "//#lint" to turbo.json to run it along with the workspace tasks`,
+	})
+
+	warnings, err = checkRootTasks(pipeline, rootPackageJSON, []string{"//#format", "//#test"})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, warnings, []string{`//#test does nothing because the root package.json has no "test" script`})
+
+	_, err = checkRootTasks(pipeline, rootPackageJSON, []string{"dev"})
+	assert.ErrorContains(t, err, `the root script "dev" (turbo run dev --parallel) invokes turbo`)
+	_, err = checkRootTasks(pipeline, rootPackageJSON, []string{"//#dev"})
+	assert.ErrorContains(t, err, "would invoke turbo recursively")
+}
+
+func Test_ArgsForRootTask(t *testing.T) {
+	rs := &runSpec{
+		Targets: []string{"//#build", "lint"},
+		Opts:    &Opts{runOpts: runOpts{passThroughArgs: []string{"--verbose"}}},
+	}
+	assert.DeepEqual(t, rs.ArgsForTask("//#build"), []string{"--verbose"})
+	assert.DeepEqual(t, rs.ArgsForTask("web#build"), []string{})
+	assert.DeepEqual(t, rs.ArgsForTask("web#lint"), []string{"--verbose"})
+}
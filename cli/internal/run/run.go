@@ -4,6 +4,7 @@ import (
 	gocontext "context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/exec"
@@ -16,9 +17,11 @@ import (
 	"text/tabwriter"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/pyr-sh/dag"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
+	"github.com/vercel/turbo/cli/internal/agent"
 	"github.com/vercel/turbo/cli/internal/analytics"
 	"github.com/vercel/turbo/cli/internal/cache"
 	"github.com/vercel/turbo/cli/internal/cmdutil"
@@ -27,6 +30,9 @@ import (
 	"github.com/vercel/turbo/cli/internal/core"
 	"github.com/vercel/turbo/cli/internal/daemon"
 	"github.com/vercel/turbo/cli/internal/daemonclient"
+	"github.com/vercel/turbo/cli/internal/env"
+	"github.com/vercel/turbo/cli/internal/errcode"
+	"github.com/vercel/turbo/cli/internal/focus"
 	"github.com/vercel/turbo/cli/internal/fs"
 	"github.com/vercel/turbo/cli/internal/graphvisualizer"
 	"github.com/vercel/turbo/cli/internal/logstreamer"
@@ -40,6 +46,7 @@ import (
 	"github.com/vercel/turbo/cli/internal/spinner"
 	"github.com/vercel/turbo/cli/internal/taskhash"
 	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/typescript"
 	"github.com/vercel/turbo/cli/internal/ui"
 	"github.com/vercel/turbo/cli/internal/util"
 
@@ -56,7 +63,20 @@ type completeGraph struct {
 	Pipeline         fs.Pipeline
 	PackageInfos     map[interface{}]*fs.PackageJSON
 	GlobalHash       string
+	// GlobalHashInputs is what went into GlobalHash, for --summarize
+	GlobalHashInputs globalHashInputs
 	RootNode         string
+	// WorkspacePackageManagers is only populated for a federation of monorepos
+	WorkspacePackageManagers map[string]*packagemanager.PackageManager
+	// GlobalDeps are the globalDependencies globs from turbo.json
+	GlobalDeps []string
+	// GlobalEnv are the globalEnv variables from turbo.json
+	GlobalEnv []string
+	// HashExclude are the hashExclude globs from turbo.json
+	HashExclude []string
+	// TypeScriptProjects reads the projects of workspaces for tasks with
+	// "typescript": true
+	TypeScriptProjects *typescript.Projects
 }
 
 // runSpec contains the run-specific configuration elements that come from a particular
@@ -64,17 +84,21 @@ type completeGraph struct {
 type runSpec struct {
 	Targets      []string
 	FilteredPkgs util.Set
-	Opts         *Opts
+	// ChangeReasons are why packages in scope are considered changed, when a filter
+	// such as --since looked at changed files
+	ChangeReasons scope.ChangeReasons
+	Opts          *Opts
 }
 
-func (rs *runSpec) ArgsForTask(task string) []string {
+func (rs *runSpec) ArgsForTask(taskID string) []string {
+	_, task := util.GetPackageTaskFromId(taskID)
 	passThroughArgs := make([]string, 0, len(rs.Opts.runOpts.passThroughArgs))
 	for _, target := range rs.Targets {
-		if target == task {
+		if target == task || target == taskID {
 			passThroughArgs = append(passThroughArgs, rs.Opts.runOpts.passThroughArgs...)
 		}
 	}
-	return passThroughArgs
+	return append(passThroughArgs, rs.Opts.runOpts.argsFor.forTask(taskID)...)
 }
 
 var _cmdLong = `
@@ -87,6 +111,7 @@ artifacts from the cache into the correct output folders (as if the task
 occurred again).
 
 Arguments passed after '--' will be passed through to the named tasks.
+Use --args-for to pass arguments to particular tasks instead.
 `
 
 // GetCmd returns the run command
@@ -108,19 +133,50 @@ func GetCmd(helper *cmdutil.Helper, signalWatcher *signals.Watcher) *cobra.Comma
 			}
 			tasks, passThroughArgs := parseTasksAndPassthroughArgs(args, flags)
 			if len(tasks) == 0 {
-				return errors.New("at least one task must be specified")
+				err := errcode.Wrap(errcode.InvalidArguments, errors.New("at least one task must be specified"))
+				base.LogError("%w", err)
+				return err
 			}
 			_, packageMode := packagemanager.InferRoot(base.RepoRoot)
 			opts.runOpts.singlePackage = packageMode == packagemanager.Single
 
 			opts.runOpts.passThroughArgs = passThroughArgs
-			run := configureRun(base, opts, signalWatcher)
-			ctx := cmd.Context()
-			if err := run.run(ctx, tasks); err != nil {
-				base.LogError("run failed: %v", err)
+			run := configureRun(base, opts, flags, signalWatcher)
+			if opts.runOpts.notify && opts.runOpts.watch {
+				err := errcode.Wrap(errcode.InvalidArguments, errors.New("--notify cannot be combined with --watch"))
+				base.LogError("%w", err)
 				return err
 			}
-			return nil
+			if opts.runOpts.runTimeout != 0 && opts.runOpts.watch {
+				err := errcode.Wrap(errcode.InvalidArguments, errors.New("--run-timeout cannot be combined with --watch"))
+				base.LogError("%w", err)
+				return err
+			}
+			if opts.runOpts.quiet {
+				run.quiet, base.UI = newQuietReport(base.UI, opts.runOpts.quietJSON, tasks)
+			}
+			startedAt := time.Now()
+			// Stop starting tasks once turbo is interrupted
+			ctx, cancel := gocontext.WithCancel(cmd.Context())
+			defer cancel()
+			signalWatcher.AddOnClose(cancel)
+			if opts.runOpts.watch {
+				err = run.watch(ctx, tasks)
+			} else {
+				err = run.run(ctx, tasks)
+			}
+			if run.quiet != nil {
+				run.quiet.print(err)
+			} else if err != nil {
+				base.LogError("run failed: %w", err)
+			}
+			if opts.runOpts.notify {
+				message := summarizeRun(run.runState, startedAt, err).text(tasks)
+				if notifyErr := notifyDesktop("turbo run", message); notifyErr != nil {
+					base.LogWarning("failed to show a desktop notification", notifyErr)
+				}
+			}
+			return err
 		},
 	}
 
@@ -152,7 +208,7 @@ func optsFromFlags(flags *pflag.FlagSet) *Opts {
 	return opts
 }
 
-func configureRun(base *cmdutil.CmdBase, opts *Opts, signalWatcher *signals.Watcher) *run {
+func configureRun(base *cmdutil.CmdBase, opts *Opts, flags *pflag.FlagSet, signalWatcher *signals.Watcher) *run {
 	if os.Getenv("TURBO_FORCE") == "true" {
 		opts.runcacheOpts.SkipReads = true
 	}
@@ -161,19 +217,39 @@ func configureRun(base *cmdutil.CmdBase, opts *Opts, signalWatcher *signals.Watc
 		opts.cacheOpts.SkipFilesystem = true
 	}
 
+	if os.Getenv("TURBO_REMOTE_READ_ONLY") == "true" {
+		opts.cacheOpts.RemoteReadOnly = true
+	}
+
 	processes := process.NewManager(base.Logger.Named("processes"))
 	signalWatcher.AddOnClose(processes.Close)
 	return &run{
 		base:      base,
 		opts:      opts,
+		flags:     flags,
 		processes: processes,
+		warnings:  &warningReporter{base: base, policy: &opts.runOpts.warnings},
+
+		signalWatcher: signalWatcher,
 	}
 }
 
 type run struct {
-	base      *cmdutil.CmdBase
-	opts      *Opts
+	base *cmdutil.CmdBase
+	opts *Opts
+	// flags is used to apply defaults from turbo.json to flags that weren't passed
+	flags     *pflag.FlagSet
 	processes *process.Manager
+	warnings  *warningReporter
+	// signalWatcher runs cleanup, such as reporting the progress of the run, when
+	// turbo is interrupted
+	signalWatcher *signals.Watcher
+	// quiet is set with --quiet, to summarize the run once it's over
+	quiet *quietReport
+	// runState is the state of the run's tasks, once they start executing
+	runState *RunState
+	// watching is set with --watch, to re-run tasks as files change
+	watching *watchState
 }
 
 func (r *run) run(ctx gocontext.Context, targets []string) error {
@@ -181,28 +257,56 @@ func (r *run) run(ctx gocontext.Context, targets []string) error {
 	packageJSONPath := r.base.RepoRoot.UntypedJoin("package.json")
 	rootPackageJSON, err := fs.ReadPackageJSON(packageJSONPath)
 	if err != nil {
-		return fmt.Errorf("failed to read package.json: %w", err)
+		return errcode.Wrap(errcode.InvalidPackageJSON, fmt.Errorf("failed to read package.json: %w", err))
 	}
 	turboJSON, err := fs.LoadTurboConfig(r.base.RepoRoot, rootPackageJSON, r.opts.runOpts.singlePackage)
 	if err != nil {
-		return err
+		return errcode.Wrap(errcode.InvalidTurboJSON, err)
 	}
 
 	// TODO: these values come from a config file, hopefully viper can help us merge these
 	r.opts.cacheOpts.RemoteCacheOpts = turboJSON.RemoteCacheOptions
+	r.opts.runOpts.notifyWebhook = turboJSON.NotifyWebhook
+	if err := applyRunDefaults(r.flags, turboJSON.Defaults); err != nil {
+		return errcode.Wrap(errcode.InvalidTurboJSON, err)
+	}
+	// Reported once the defaults are applied, since they can change the --warnings policy
+	for _, migration := range turboJSON.Migrations {
+		r.warnings.report(errcode.NewWarning(errcode.DeprecatedConfig, fmt.Errorf("%v, run \"turbo migrate-config\" to update the config", migration.Message)))
+	}
+	if r.opts.runOpts.quiet {
+		if r.opts.runOpts.dryRun || r.opts.runOpts.graphDot || r.opts.runOpts.graphFile != "" {
+			return errcode.Wrap(errcode.InvalidArguments, errors.New("--quiet cannot be combined with --dry-run or --graph"))
+		}
+		// task output would only be discarded, so don't stream or replay it
+		noTaskOutput := util.NoTaskOutput
+		r.opts.runcacheOpts.TaskOutputModeOverride = &noTaskOutput
+	}
+	if r.opts.runOpts.maxFailures < 0 {
+		return errcode.Wrap(errcode.InvalidArguments, fmt.Errorf("--max-failures must be at least 1, got %v", r.opts.runOpts.maxFailures))
+	}
+	if r.opts.runOpts.runTimeout < 0 || r.opts.runOpts.runTimeoutGrace < 0 {
+		return errcode.Wrap(errcode.InvalidArguments, errors.New("--run-timeout and --run-timeout-grace can't be negative"))
+	}
+	if r.opts.runOpts.environment != "" {
+		if err := env.ValidateEnvironment(r.opts.runOpts.environment); err != nil {
+			return errcode.Wrap(errcode.InvalidArguments, err)
+		}
+	}
 
 	var pkgDepGraph *context.Context
-	if r.opts.runOpts.singlePackage {
+	if r.opts.runOpts.singlePackage && len(turboJSON.Roots) == 0 {
 		pkgDepGraph, err = context.SinglePackageGraph(r.base.RepoRoot, rootPackageJSON)
 	} else {
-		pkgDepGraph, err = context.BuildPackageGraph(r.base.RepoRoot, rootPackageJSON)
+		r.opts.runOpts.singlePackage = false
+		pkgDepGraph, turboJSON, err = context.BuildPackageGraphWithConfig(r.base.RepoRoot, rootPackageJSON, turboJSON)
 	}
 	if err != nil {
 		var warnings *context.Warnings
 		if errors.As(err, &warnings) {
-			r.base.LogWarning("Issues occurred when constructing package graph. Turbo will function, but some features may not be available", err)
+			r.reportGraphWarnings(warnings)
 		} else {
-			return err
+			return errcode.Wrap(errcode.InvalidPackageGraph, err)
 		}
 	}
 	if ui.IsCI && !r.opts.runOpts.noDaemon {
@@ -219,15 +323,52 @@ func (r *run) run(ctx gocontext.Context, targets []string) error {
 		}
 	}
 
+	if turboJSON.UndeclaredImports != "" && !r.opts.runOpts.singlePackage {
+		if err := r.checkUndeclaredImports(pkgDepGraph, turboJSON.UndeclaredImports); err != nil {
+			return errcode.Wrap(errcode.InvalidPackageGraph, err)
+		}
+	}
+
 	if err := util.ValidateGraph(&pkgDepGraph.TopologicalGraph); err != nil {
-		return errors.Wrap(err, "Invalid package dependency graph")
+		return errcode.Wrap(errcode.InvalidPackageGraph, errors.Wrap(err, "Invalid package dependency graph"))
 	}
 
 	pipeline := turboJSON.Pipeline
+	if r.opts.runOpts.allowMissingTasks {
+		var warnings []*errcode.Warning
+		pipeline, warnings = withMissingTasks(pipeline, targets, pkgDepGraph.PackageInfos, r.opts.runOpts.singlePackage)
+		for _, warning := range warnings {
+			r.warnings.report(warning)
+		}
+	}
 	if err := validateTasks(pipeline, targets); err != nil {
-		return err
+		return errcode.Wrap(errcode.MissingTask, err)
+	}
+	if !r.opts.runOpts.singlePackage {
+		// Tasks added by --allow-missing-tasks were already warned about
+		warnings, err := checkRootTasks(turboJSON.Pipeline, rootPackageJSON, targets)
+		if err != nil {
+			return errcode.Wrap(errcode.InvalidTurboJSON, err)
+		}
+		for _, warning := range warnings {
+			r.warnings.report(errcode.NewWarning(errcode.RootTaskMismatch, errors.New(warning)))
+		}
+		for _, warning := range checkUnusedPipelineEntries(turboJSON.Pipeline, pkgDepGraph.PackageInfos) {
+			r.warnings.report(warning)
+		}
 	}
 
+	if !r.opts.scopeOpts.HasFilters() && !r.opts.runOpts.singlePackage {
+		if focus, err := focus.Read(r.base.RepoRoot); err != nil {
+			r.base.LogWarning("failed to read the focus, running every workspace", err)
+		} else if focus.IsSet() {
+			r.opts.scopeOpts.FilterPatterns = focus.Filters
+			// JSON and dot graphs are printed to stdout, and must stay parseable
+			if !r.opts.runOpts.dryRunJSON && !r.opts.runOpts.graphDot {
+				r.base.UI.Output(ui.Dim(fmt.Sprintf("• Focused on %v. Pass --filter to select other workspaces, or run `turbo focus --clear`", strings.Join(focus.Filters, " "))))
+			}
+		}
+	}
 	scmInstance, err := scm.FromInRepo(r.base.RepoRoot)
 	if err != nil {
 		if errors.Is(err, scm.ErrFallback) {
@@ -236,9 +377,24 @@ func (r *run) run(ctx gocontext.Context, targets []string) error {
 			return errors.Wrap(err, "failed to create SCM")
 		}
 	}
-	filteredPkgs, isAllPackages, err := scope.ResolvePackages(&r.opts.scopeOpts, r.base.RepoRoot.ToStringDuringMigration(), scmInstance, pkgDepGraph, r.base.UI, r.base.Logger)
+	filteredPkgs, isAllPackages, changeReasons, err := scope.ResolvePackagesWithChanges(&r.opts.scopeOpts, r.base.RepoRoot.ToStringDuringMigration(), scmInstance, pkgDepGraph, r.base.UI, r.base.Logger)
 	if err != nil {
-		return errors.Wrap(err, "failed to resolve packages to run")
+		return errcode.Wrap(errcode.InvalidFilter, errors.Wrap(err, "failed to resolve packages to run"))
+	}
+	// tasks requested by their ID, such as //#build, run regardless of the filter
+	var targetPkgs []string
+	for _, target := range targets {
+		if util.IsPackageTask(target) {
+			pkg, _ := util.GetPackageTaskFromId(target)
+			targetPkgs = append(targetPkgs, pkg)
+		}
+	}
+	if isAllPackages && len(targetPkgs) > 0 && len(targetPkgs) == len(targets) {
+		// only tasks requested by their ID are running, so no other packages are in scope
+		filteredPkgs = make(util.Set)
+	}
+	for _, pkg := range targetPkgs {
+		filteredPkgs.Add(pkg)
 	}
 	if isAllPackages {
 		// if there is a root task for any of our targets, we need to add it
@@ -251,7 +407,7 @@ func (r *run) run(ctx gocontext.Context, targets []string) error {
 			}
 		}
 	}
-	globalHash, err := calculateGlobalHash(
+	globalHashInputs, err := calculateGlobalHash(
 		r.base.RepoRoot,
 		rootPackageJSON,
 		pipeline,
@@ -261,11 +417,13 @@ func (r *run) run(ctx gocontext.Context, targets []string) error {
 		pkgDepGraph.Lockfile,
 		r.base.Logger,
 		os.Environ(),
+		r.opts.runOpts.environment,
+		r.opts.runOpts.envMode,
 	)
 	if err != nil {
-		return fmt.Errorf("failed to calculate global hash: %v", err)
+		return errcode.Wrap(errcode.HashingFailed, fmt.Errorf("failed to calculate global hash: %v", err))
 	}
-	r.base.Logger.Debug("global hash", "value", globalHash)
+	r.base.Logger.Debug("global hash", "value", globalHashInputs.Hash)
 	r.base.Logger.Debug("local cache folder", "path", r.opts.cacheOpts.OverrideDir)
 
 	// TODO: consolidate some of these arguments
@@ -273,16 +431,64 @@ func (r *run) run(ctx gocontext.Context, targets []string) error {
 		TopologicalGraph: pkgDepGraph.TopologicalGraph,
 		Pipeline:         pipeline,
 		PackageInfos:     pkgDepGraph.PackageInfos,
-		GlobalHash:       globalHash,
+		GlobalHash:       globalHashInputs.Hash,
+		GlobalHashInputs: globalHashInputs,
 		RootNode:         pkgDepGraph.RootNode,
+		GlobalDeps:       turboJSON.GlobalDeps,
+		GlobalEnv:        turboJSON.GlobalEnv,
+		HashExclude:      turboJSON.HashExclude,
+
+		WorkspacePackageManagers: pkgDepGraph.WorkspacePackageManagers,
+		TypeScriptProjects:       typescript.NewProjects(r.base.RepoRoot),
 	}
 	rs := &runSpec{
-		Targets:      targets,
-		FilteredPkgs: filteredPkgs,
-		Opts:         r.opts,
+		Targets:       targets,
+		FilteredPkgs:  filteredPkgs,
+		ChangeReasons: changeReasons,
+		Opts:          r.opts,
 	}
 	packageManager := pkgDepGraph.PackageManager
-	return r.runOperation(ctx, g, rs, packageManager, startAt)
+	if err := r.runOperation(ctx, g, rs, packageManager, startAt); err != nil {
+		return err
+	}
+	return r.warnings.err()
+}
+
+// reportGraphWarnings reports the coded warnings from constructing the package graph
+// according to --warnings, and logs the rest
+func (r *run) reportGraphWarnings(warnings *context.Warnings) {
+	var uncoded []string
+	for _, err := range warnings.Errors() {
+		var warning *errcode.Warning
+		if errors.As(err, &warning) {
+			r.warnings.report(warning)
+		} else {
+			uncoded = append(uncoded, err.Error())
+		}
+	}
+	if len(uncoded) > 0 {
+		r.base.LogWarning("Issues occurred when constructing package graph. Turbo will function, but some features may not be available", errors.New(strings.Join(uncoded, ", ")))
+	}
+}
+
+// checkUndeclaredImports warns about the imports of workspaces that aren't declared
+// as dependencies, and with "infer", makes them dependencies
+func (r *run) checkUndeclaredImports(pkgDepGraph *context.Context, mode string) error {
+	imports, err := pkgDepGraph.FindUndeclaredImports(r.base.RepoRoot)
+	if err != nil {
+		return err
+	}
+	for _, imp := range imports {
+		consequence := "so its tasks may run before the ones they need"
+		if mode == fs.UndeclaredImportsInfer {
+			consequence = "so it is treated as a dependency"
+		}
+		r.warnings.report(errcode.NewWarning(errcode.UndeclaredImport, fmt.Errorf("%v imports %v (%q in %v), but doesn't depend on it in its package.json, %v", imp.From, imp.To, imp.Specifier, imp.File, consequence)))
+	}
+	if mode == fs.UndeclaredImportsInfer {
+		pkgDepGraph.AddImportEdges(imports)
+	}
+	return nil
 }
 
 func (r *run) runOperation(ctx gocontext.Context, g *completeGraph, rs *runSpec, packageManager *packagemanager.PackageManager, startAt time.Time) error {
@@ -293,12 +499,12 @@ func (r *run) runOperation(ctx gocontext.Context, g *completeGraph, rs *runSpec,
 
 	engine, err := buildTaskGraphEngine(&g.TopologicalGraph, g.Pipeline, rs)
 	if err != nil {
-		return errors.Wrap(err, "error preparing engine")
+		return errcode.Wrap(errcode.InvalidTaskGraph, errors.Wrap(err, "error preparing engine"))
 	}
-	tracker := taskhash.NewTracker(g.RootNode, g.GlobalHash, g.Pipeline, g.PackageInfos)
+	tracker := taskhash.NewTracker(g.RootNode, g.GlobalHash, g.Pipeline, g.PackageInfos, rs.Opts.runOpts.environment, g.HashExclude)
 	err = tracker.CalculateFileHashes(engine.TaskGraph.Vertices(), rs.Opts.runOpts.concurrency, r.base.RepoRoot)
 	if err != nil {
-		return errors.Wrap(err, "error hashing package files")
+		return errcode.Wrap(errcode.HashingFailed, errors.Wrap(err, "error hashing package files"))
 	}
 
 	// If we are running in parallel, then we remove all the edges in the graph
@@ -312,7 +518,7 @@ func (r *run) runOperation(ctx gocontext.Context, g *completeGraph, rs *runSpec,
 		}
 		engine, err = buildTaskGraphEngine(&g.TopologicalGraph, g.Pipeline, rs)
 		if err != nil {
-			return errors.Wrap(err, "error preparing engine")
+			return errcode.Wrap(errcode.InvalidTaskGraph, errors.Wrap(err, "error preparing engine"))
 		}
 	}
 
@@ -322,6 +528,10 @@ func (r *run) runOperation(ctx gocontext.Context, g *completeGraph, rs *runSpec,
 			graph = filterSinglePackageGraphForDisplay(engine.TaskGraph)
 		}
 		visualizer := graphvisualizer.New(r.base.RepoRoot, r.base.UI, graph)
+		visualizer.PackageDirs = make(map[string]turbopath.AnchoredUnixPath, len(g.PackageInfos))
+		for name, pkg := range g.PackageInfos {
+			visualizer.PackageDirs[name.(string)] = pkg.Dir.ToUnixPath()
+		}
 
 		if rs.Opts.runOpts.graphDot {
 			visualizer.RenderDotGraph()
@@ -343,7 +553,7 @@ func (r *run) runOperation(ctx gocontext.Context, g *completeGraph, rs *runSpec,
 			if r.opts.runOpts.singlePackage {
 				rendered, err = renderDryRunSinglePackageJSON(tasksRun)
 			} else {
-				rendered, err = renderDryRunFullJSON(tasksRun, packagesInScope)
+				rendered, err = renderDryRunFullJSON(tasksRun, packagesInScope, rs.ChangeReasons)
 			}
 			if err != nil {
 				return err
@@ -363,11 +573,29 @@ func (r *run) runOperation(ctx gocontext.Context, g *completeGraph, rs *runSpec,
 			r.base.UI.Output(fmt.Sprintf(ui.Dim("• Packages in scope: %v"), strings.Join(packagesInScope, ", ")))
 			r.base.UI.Output(fmt.Sprintf("%s %s %s", ui.Dim("• Running"), ui.Dim(ui.Bold(strings.Join(rs.Targets, ", "))), ui.Dim(fmt.Sprintf("in %v packages", rs.FilteredPkgs.Len()))))
 		}
+		if r.opts.runOpts.environment != "" {
+			r.base.UI.Output(ui.Dim(fmt.Sprintf("• Environment: %v", r.opts.runOpts.environment)))
+		}
+		if r.watching != nil {
+			return r.watchTasks(ctx, g, rs, engine, packageManager, tracker)
+		}
 		return r.executeTasks(ctx, g, rs, engine, packageManager, tracker, startAt)
 	}
 	return nil
 }
 
+// dryRunExecOpts walks the task graph one task at a time, so that tasks are listed
+// in the order that a run would start them in, which depends on how long they took before
+func dryRunExecOpts(ctx gocontext.Context, history *taskHistory, requested util.Set) core.ExecOpts {
+	return core.ExecOpts{
+		Context:           ctx,
+		Concurrency:       1,
+		Parallel:          false,
+		EstimatedDuration: history.estimatedDuration,
+		Requested:         requested,
+	}
+}
+
 func renderDryRunSinglePackageJSON(tasksRun []hashedTask) (string, error) {
 	singlePackageTasks := make([]hashedSinglePackageTask, len(tasksRun))
 	for i, ht := range tasksRun {
@@ -383,12 +611,15 @@ func renderDryRunSinglePackageJSON(tasksRun []hashedTask) (string, error) {
 	return string(bytes), nil
 }
 
-func renderDryRunFullJSON(tasksRun []hashedTask, packagesInScope []string) (string, error) {
+func renderDryRunFullJSON(tasksRun []hashedTask, packagesInScope []string, changeReasons scope.ChangeReasons) (string, error) {
 	dryRun := &struct {
-		Packages []string     `json:"packages"`
-		Tasks    []hashedTask `json:"tasks"`
+		Packages []string `json:"packages"`
+		// Changes are why each package is in scope, for filters on changed files
+		Changes scope.ChangeReasons `json:"changes,omitempty"`
+		Tasks   []hashedTask        `json:"tasks"`
 	}{
 		Packages: packagesInScope,
+		Changes:  changeReasons,
 		Tasks:    tasksRun,
 	}
 	bytes, err := json.MarshalIndent(dryRun, "", "  ")
@@ -454,6 +685,15 @@ func displayDryTextRun(ui cli.Ui, tasksRun []hashedTask, packagesInScope []strin
 			return err
 		}
 	}
+
+	cached := 0
+	for _, task := range tasksRun {
+		if task.CacheState.Local || task.CacheState.Remote {
+			cached++
+		}
+	}
+	ui.Output("")
+	ui.Output(util.Sprintf("${BOLD} Tasks:${BOLD_GREEN}    %v would run${RESET}${GRAY}, %v cached, %v total${RESET}", len(tasksRun)-cached, cached, len(tasksRun)))
 	return nil
 }
 
@@ -480,7 +720,7 @@ func buildTaskGraphEngine(topoGraph *dag.AcyclicGraph, pipeline fs.Pipeline, rs
 		topoDeps := make(util.Set)
 		deps := make(util.Set)
 		isPackageTask := util.IsPackageTask(taskName)
-		for _, dependency := range taskDefinition.TaskDependencies {
+		for _, dependency := range expandTagDependencies(pipeline, taskName, taskDefinition.TaskDependencies) {
 			if isPackageTask && util.IsPackageTask(dependency) {
 				err := engine.AddDep(dependency, taskName)
 				if err != nil {
@@ -490,13 +730,28 @@ func buildTaskGraphEngine(topoGraph *dag.AcyclicGraph, pipeline fs.Pipeline, rs
 				deps.Add(dependency)
 			}
 		}
-		for _, dependency := range taskDefinition.TopologicalDependencies {
+		for _, dependency := range expandTagDependencies(pipeline, taskName, taskDefinition.TopologicalDependencies) {
 			topoDeps.Add(dependency)
 		}
+		orderOnlyDeps := make(util.Set)
+		for _, dependency := range expandTagDependencies(pipeline, taskName, taskDefinition.OrderOnlyTaskDependencies) {
+			orderOnlyDeps.Add(dependency)
+		}
+		orderOnlyTopoDeps := make(util.Set)
+		for _, dependency := range expandTagDependencies(pipeline, taskName, taskDefinition.OrderOnlyTopologicalDependencies) {
+			orderOnlyTopoDeps.Add(dependency)
+		}
 		engine.AddTask(&core.Task{
-			Name:     taskName,
-			TopoDeps: topoDeps,
-			Deps:     deps,
+			Name:              taskName,
+			TopoDeps:          topoDeps,
+			Deps:              deps,
+			ConcurrencyGroup:  taskDefinition.ConcurrencyGroup,
+			MaxParallel:       taskDefinition.MaxParallel,
+			Persistent:        taskDefinition.Persistent,
+			OrderOnlyDeps:     orderOnlyDeps,
+			OrderOnlyTopoDeps: orderOnlyTopoDeps,
+			Retries:           taskDefinition.Retries,
+			RetryDelay:        taskDefinition.RetryDelay,
 		})
 	}
 
@@ -508,10 +763,32 @@ func buildTaskGraphEngine(topoGraph *dag.AcyclicGraph, pipeline fs.Pipeline, rs
 		return nil, err
 	}
 
+	if err := rs.Opts.runOpts.taskFilter.apply(engine.TaskGraph, pipeline, rs.FilteredPkgs.UnsafeListOfStrings(), rs.Targets); err != nil {
+		return nil, err
+	}
+
 	if err := util.ValidateGraph(engine.TaskGraph); err != nil {
 		return nil, fmt.Errorf("Invalid task dependency graph:\n%v", err)
 	}
 
+	if err := engine.ValidatePersistentDependencies(core.PersistentDependencyChecks{
+		Cacheable: func(taskID string) bool {
+			taskDefinition, ok := pipeline.GetTaskDefinition(taskID)
+			return ok && taskDefinition.ShouldCache
+		},
+		// Tasks that consume outputs through outputOf start once they're ready
+		Allowed: func(taskID string, depID string) bool {
+			taskDefinition, ok := pipeline.GetTaskDefinition(taskID)
+			if !ok {
+				return false
+			}
+			_, ok = taskDefinition.OutputSubset(taskID, depID)
+			return ok
+		},
+	}); err != nil {
+		return nil, err
+	}
+
 	return engine, nil
 }
 
@@ -535,7 +812,15 @@ type runOpts struct {
 	profile string
 	// If true, continue task executions even if a task fails.
 	continueOnError bool
+	// If set, continue task executions until this many tasks fail
+	maxFailures     int
 	passThroughArgs []string
+	// Stop starting tasks once the run has taken this long, and stop the running
+	// ones after runTimeoutGrace more
+	runTimeout      time.Duration
+	runTimeoutGrace time.Duration
+	// Arguments to forward to particular tasks, whichever tasks were named
+	argsFor taskArgs
 	// Restrict execution to only the listed task names. Default false
 	only bool
 	// Dry run flags
@@ -546,6 +831,57 @@ type runOpts struct {
 	graphFile     string
 	noDaemon      bool
 	singlePackage bool
+	// Skip tasks that succeeded with the same hash in the previous run
+	resume bool
+	// Execute simple scripts directly, rather than via the package manager
+	directExec bool
+	// File to write a JUnit XML report of task results to
+	junitFile string
+	// Upload the output of failed tasks to the remote cache
+	uploadFailureLogs bool
+	// Select or exclude tasks by their tags
+	taskFilter taskFilter
+	// Restore the outputs of the tasks that are likely to run next while slots are idle
+	speculate bool
+	// File to write a JSON report of flaky tasks to
+	flakyReportFile string
+	// Which warnings to print, silence, or treat as errors
+	warnings errcode.WarningPolicy
+	// URL to stream task events and output to while the run is in progress
+	logDrain string
+	// URLs of `turbo agent` processes to run cacheable tasks on
+	remoteAgents []string
+	// Trace the files that tasks read, and report the ones their hash doesn't account for
+	audit bool
+	// Which of turbo's environment variables tasks can read
+	envMode env.Mode
+	// Named environment, such as production, whose dotenv files are loaded into tasks
+	environment string
+	// Print only a single line, or a JSON object, summarizing the run
+	quiet     bool
+	quietJSON bool
+	// Run requested tasks that aren't in the pipeline, rather than failing
+	allowMissingTasks bool
+	// Don't count persistent tasks against the concurrency limit
+	persistentOutsideConcurrency bool
+	// Directory to collect the artifacts of tasks into, rather than one for the run under .turbo
+	artifactsDir string
+	// Write a JSON summary of the run to .turbo/runs
+	summarize bool
+	// Show a desktop notification once the run is over
+	notify bool
+	// URL from turbo.json to POST the summary of the run to once it's over
+	notifyWebhook string
+	// Keep running, and re-run the tasks that changed files affect
+	watch bool
+	// Whether the output of concurrent tasks is streamed or grouped by task
+	logOrder logOrder
+}
+
+// continuesOnError returns true if tasks keep starting after a task fails, with
+// --continue, or until --max-failures tasks have failed
+func (ro *runOpts) continuesOnError() bool {
+	return ro.continueOnError || ro.maxFailures > 0
 }
 
 var (
@@ -554,6 +890,14 @@ You can load the file up in chrome://tracing to see
 which parts of your build were slow.`
 	_continueHelp = `Continue execution even if a task exits with an error
 or non-zero exit code. The default behavior is to bail`
+	_maxFailuresHelp = `Continue execution like --continue until this many tasks
+have failed, then start no more tasks, letting the ones
+that are running finish.`
+	_runTimeoutHelp = `Stop starting tasks once the run has taken this long, such
+as 30m, save the results of the tasks that finished, and
+exit with code 124.`
+	_runTimeoutGraceHelp = `How long tasks that are running when --run-timeout passes
+may take to finish before they are stopped.`
 	_dryRunHelp = `List the packages in scope and the tasks that would be run,
 but don't actually run them. Passing --dry=json or
 --dry-run=json will render the output in JSON format.`
@@ -562,6 +906,71 @@ Outputs dot graph to stdout when if no filename is provided`
 	_concurrencyHelp = `Limit the concurrency of task execution. Use 1 for serial (i.e. one-at-a-time) execution.`
 	_parallelHelp    = `Execute all tasks in parallel.`
 	_onlyHelp        = `Run only the specified tasks, not their dependencies.`
+	_resumeHelp      = `Resume the previous run, skipping tasks that already
+succeeded and whose inputs have not changed since.`
+	_directExecHelp = `Execute package.json scripts that use no shell syntax
+directly, rather than through the package manager and a shell.`
+	_junitHelp = `File to write a JUnit XML report of task results to, with
+one testsuite per package and one testcase per task.`
+	_uploadFailureLogsHelp = `Upload the output of failed tasks to the Remote Cache,
+tagged with the run ID and task hash, so that failures
+can be investigated after the machine is gone.`
+	_filterTaskHelp = `Use "tag:<tag>" to only run tasks with the given tag,
+along with their dependencies, or "!tag:<tag>" to skip
+tasks with the given tag. Can be specified multiple times.`
+	_speculateHelp = `While concurrency slots are idle, restore the outputs of
+the tasks that usually run next from the Remote Cache,
+so that running them is a local cache hit.`
+	_flakyReportHelp = `File to write a JSON report of flaky tasks to: tasks that
+both passed and failed with the same hash in recent runs.`
+	_warningsHelp = `Set how warnings are handled. Use "strict" to treat
+warnings as errors, "silence:<code>" to hide warnings
+with the given code, or "default". Can be passed
+multiple times.`
+	_logDrainHelp = `URL to stream task events and output to as NDJSON
+while tasks run. Set TURBO_LOG_DRAIN_TOKEN to send a
+bearer token with each request.`
+	_remoteAgentsHelp = `Run cacheable tasks on the given turbo agents, as
+a comma-separated list of URLs. Set TURBO_AGENT_TOKEN
+to the token of the agents. This is experimental.`
+	_auditHelp = `Trace the files that executed tasks read, and report
+the ones that their hash doesn't account for, along
+with the changes to turbo.json that would. Requires
+strace, on Linux.`
+	_envModeHelp = `Set which environment variables tasks can read. Use
+"strict" to only pass through those that are part of
+their hash, or "loose" to pass through all of them.`
+	_environmentHelp = `Name of the environment to run tasks in, such as
+"production". Its dotenv files are loaded into tasks
+and hashed, from the repository root and each workspace.`
+	_quietHelp = `Print nothing but a single line summarizing the run once
+it's over, or a JSON object with --quiet=json. Suitable
+for git hooks and shell prompts.`
+	_allowMissingTasksHelp = `Run requested tasks that aren't in the pipeline in the
+workspaces that have a script for them, without caching,
+rather than failing.`
+	_argsForHelp = `Forward arguments to the given task only, as
+"<task>=<args>", such as test="--runInBand". <task> is
+a task name or a package task, such as web#test. Can be
+passed multiple times.`
+	_logOrderHelp = `Set how the output of tasks that run concurrently is
+ordered. Use "stream" to show each line as soon as it is
+written, or "grouped" to show all of a task's output
+together when it completes. Defaults to "auto", which
+groups output in CI and streams it otherwise.`
+	_persistentOutsideConcurrencyHelp = `Don't count persistent tasks, such as dev servers,
+against --concurrency. They never complete, so otherwise
+each holds a slot for the rest of the run.`
+	_artifactsDirHelp = `Directory to collect the "artifacts" of tasks into.
+Defaults to a directory for the run in .turbo/artifacts.`
+	_summarizeHelp = `Write a JSON summary of the run, with the hash, cache
+status, timing, and exit code of each task, and the
+inputs of the global hash, to .turbo/runs/<run ID>.json.`
+	_notifyHelp = `Show a desktop notification with the outcome of the run
+once it's over.`
+	_watchHelp = `Keep running after the tasks complete, and run them again
+as files change. Only the tasks of the workspaces with
+changes, and the tasks that depend on them, run again.`
 )
 
 func addRunOpts(opts *runOpts, flags *pflag.FlagSet, aliases map[string]string) {
@@ -576,9 +985,33 @@ func addRunOpts(opts *runOpts, flags *pflag.FlagSet, aliases map[string]string)
 	flags.BoolVar(&opts.parallel, "parallel", false, _parallelHelp)
 	flags.StringVar(&opts.profile, "profile", "", _profileHelp)
 	flags.BoolVar(&opts.continueOnError, "continue", false, _continueHelp)
+	flags.IntVar(&opts.maxFailures, "max-failures", 0, _maxFailuresHelp)
+	flags.DurationVar(&opts.runTimeout, "run-timeout", 0, _runTimeoutHelp)
+	flags.DurationVar(&opts.runTimeoutGrace, "run-timeout-grace", time.Minute, _runTimeoutGraceHelp)
 	flags.BoolVar(&opts.only, "only", false, _onlyHelp)
 	flags.BoolVar(&opts.noDaemon, "no-daemon", false, "Run without using turbo's daemon process")
 	flags.BoolVar(&opts.singlePackage, "single-package", false, "Run turbo in single-package mode")
+	flags.BoolVar(&opts.resume, "resume", false, _resumeHelp)
+	flags.BoolVar(&opts.directExec, "direct-exec", false, _directExecHelp)
+	flags.StringVar(&opts.junitFile, "junit", "", _junitHelp)
+	flags.BoolVar(&opts.uploadFailureLogs, "upload-failure-logs", false, _uploadFailureLogsHelp)
+	flags.Var(&opts.taskFilter, "filter-task", _filterTaskHelp)
+	flags.BoolVar(&opts.speculate, "speculate", false, _speculateHelp)
+	flags.StringVar(&opts.flakyReportFile, "flaky-report", "", _flakyReportHelp)
+	flags.Var(&opts.warnings, "warnings", _warningsHelp)
+	flags.StringVar(&opts.logDrain, "log-drain", "", _logDrainHelp)
+	flags.StringSliceVar(&opts.remoteAgents, "experimental-remote-agents", nil, _remoteAgentsHelp)
+	flags.BoolVar(&opts.audit, "audit", false, _auditHelp)
+	flags.Var(&opts.envMode, "env-mode", _envModeHelp)
+	flags.StringVar(&opts.environment, "environment", "", _environmentHelp)
+	flags.BoolVar(&opts.allowMissingTasks, "allow-missing-tasks", false, _allowMissingTasksHelp)
+	flags.BoolVar(&opts.persistentOutsideConcurrency, "persistent-outside-concurrency", false, _persistentOutsideConcurrencyHelp)
+	flags.StringVar(&opts.artifactsDir, "artifacts-dir", "", _artifactsDirHelp)
+	flags.BoolVar(&opts.summarize, "summarize", false, _summarizeHelp)
+	flags.BoolVar(&opts.notify, "notify", false, _notifyHelp)
+	flags.BoolVar(&opts.watch, "watch", false, _watchHelp)
+	flags.Var(&opts.logOrder, "log-order", _logOrderHelp)
+	flags.Var(&opts.argsFor, "args-for", _argsForHelp)
 	// This is a no-op flag, we don't need it anymore
 	flags.Bool("experimental-use-daemon", false, "Use the experimental turbo daemon")
 	if err := flags.MarkHidden("experimental-use-daemon"); err != nil {
@@ -599,6 +1032,13 @@ func addRunOpts(opts *runOpts, flags *pflag.FlagSet, aliases map[string]string)
 		NoOptDefVal: _dryRunNoValue,
 		Value:       &dryRunValue{opts: opts},
 	})
+	flags.AddFlag(&pflag.Flag{
+		Name:        "quiet",
+		Usage:       _quietHelp,
+		DefValue:    "",
+		NoOptDefVal: _quietNoValue,
+		Value:       &quietValue{opts: opts},
+	})
 	flags.AddFlag(&pflag.Flag{
 		Name:        "graph",
 		Usage:       _graphHelp,
@@ -707,13 +1147,50 @@ func getDefaultOptions() *Opts {
 	}
 }
 
+// applyRunDefaults sets the flags that have a default in turbo.json, unless they were passed
+func applyRunDefaults(flags *pflag.FlagSet, defaults fs.RunDefaults) error {
+	for _, d := range []struct {
+		flag  string
+		key   string
+		value string
+	}{
+		{"cache-dir", "cacheDir", defaults.CacheDir},
+		{"cache-max-size", "cacheMaxSize", defaults.CacheMaxSize},
+		{"concurrency", "concurrency", defaults.Concurrency},
+		{"output-logs", "outputLogs", defaults.OutputLogs},
+		{"env-mode", "envMode", defaults.EnvMode},
+		{"environment", "environment", defaults.Environment},
+		{"allow-missing-tasks", "allowMissingTasks", defaults.AllowMissingTasks},
+		{"persistent-outside-concurrency", "persistentOutsideConcurrency", defaults.PersistentOutsideConcurrency},
+		{"log-order", "logOrder", defaults.LogOrder},
+	} {
+		if d.value == "" || flags.Changed(d.flag) {
+			continue
+		}
+		if err := flags.Set(d.flag, d.value); err != nil {
+			return fmt.Errorf("invalid \"%v\" in \"defaults\" of turbo.json: %w", d.key, err)
+		}
+	}
+	if !flags.Changed("warnings") {
+		for _, value := range defaults.Warnings {
+			if err := flags.Set("warnings", value); err != nil {
+				return fmt.Errorf("invalid \"warnings\" in \"defaults\" of turbo.json: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
 func (r *run) initAnalyticsClient(ctx gocontext.Context) analytics.Client {
 	apiClient := r.base.APIClient
 	var analyticsSink analytics.Sink
 	if apiClient.IsLinked() {
 		analyticsSink = apiClient
 	} else {
-		r.opts.cacheOpts.SkipRemote = true
+		// Artifacts in storage such as S3 don't need a linked Remote Cache
+		if r.opts.cacheOpts.RemoteProvider() == "" {
+			r.opts.cacheOpts.SkipRemote = true
+		}
 		analyticsSink = analytics.NullSink
 	}
 	analyticsClient := analytics.NewClient(ctx, analyticsSink, r.base.Logger.Named("analytics"))
@@ -732,6 +1209,8 @@ func (r *run) initCache(ctx gocontext.Context, rs *runSpec, analyticsClient anal
 		once.Do(func() {
 			r.base.LogWarning("Remote Caching is unavailable", err)
 		})
+	}, func(conflict *cache.ArtifactConflictError) {
+		r.base.LogWarning("", conflict)
 	})
 }
 
@@ -740,51 +1219,283 @@ func (r *run) executeTasks(ctx gocontext.Context, g *completeGraph, rs *runSpec,
 	defer analyticsClient.CloseWithTimeout(50 * time.Millisecond)
 
 	useHTTPCache := !rs.Opts.cacheOpts.SkipRemote
-	if useHTTPCache {
-		r.base.UI.Info(ui.Dim("• Remote caching enabled"))
+	remoteMode := "enabled"
+	if rs.Opts.cacheOpts.RemoteReadOnly {
+		remoteMode = "enabled, read-only"
+	}
+	if provider := rs.Opts.cacheOpts.RemoteProvider(); useHTTPCache && provider != "" {
+		r.base.UI.Info(ui.Dim(fmt.Sprintf("• Remote caching %v (%v)", remoteMode, provider)))
+	} else if useHTTPCache {
+		r.base.UI.Info(ui.Dim(fmt.Sprintf("• Remote caching %v", remoteMode)))
 	} else {
 		r.base.UI.Info(ui.Dim("• Remote caching disabled"))
 	}
 
+	if !rs.Opts.cacheOpts.SkipFilesystem && rs.Opts.cacheOpts.OverrideDir == "" {
+		defaultDir := cache.DefaultLocation(r.base.RepoRoot)
+		if cacheDir, ok := fs.WritableDir(r.base.RepoRoot, defaultDir); !ok {
+			r.base.LogWarning("", fmt.Errorf("%v is read-only, caching artifacts in %v instead", defaultDir, cacheDir))
+		}
+	}
+	transfers := &cache.TransferStats{}
+	rs.Opts.cacheOpts.Transfers = transfers
 	turboCache, err := r.initCache(ctx, rs, analyticsClient)
 	if err != nil {
 		if errors.Is(err, cache.ErrNoCachesEnabled) {
 			r.base.LogWarning("No caches are enabled. You can try \"turbo login\", \"turbo link\", or ensuring you are not passing --remote-only to enable caching", nil)
 		} else {
-			return errors.Wrap(err, "failed to set up caching")
+			return errcode.Wrap(errcode.CacheUnavailable, errors.Wrap(err, "failed to set up caching"))
 		}
 	}
-	defer func() {
-		_ = spinner.WaitFor(ctx, turboCache.Shutdown, r.base.UI, "...writing to cache...", 1500*time.Millisecond)
-	}()
+	cacheShutdown := &sync.Once{}
+	shutdownCache := func() {
+		cacheShutdown.Do(func() {
+			_ = spinner.WaitFor(ctx, turboCache.Shutdown, r.base.UI, "...writing to cache...", 1500*time.Millisecond)
+		})
+	}
+	defer shutdownCache()
 	colorCache := colorcache.New()
 	runState := NewRunState(startAt, rs.Opts.runOpts.profile)
+	r.runState = runState
+	if useHTTPCache {
+		runState.transfers = transfers
+	}
+	if r.quiet != nil {
+		r.quiet.runState = runState
+	}
+	rs.Opts.runcacheOpts.OnWarning = r.warnings.report
 	runCache := runcache.New(turboCache, r.base.RepoRoot, rs.Opts.runcacheOpts, colorCache)
+	resumeState, err := newResumeState(r.base.RepoRoot, rs.Opts.runOpts.resume)
+	if err != nil {
+		return errors.Wrap(err, "failed to read state of the previous run")
+	}
+	if interrupted := resumeState.Interrupted; interrupted != nil && !rs.Opts.runOpts.resume {
+		r.base.LogWarning(fmt.Sprintf("The previous run was interrupted with %v tasks in progress. Pass --resume to skip the %v tasks it completed", interrupted.InProgress, len(interrupted.Completed)), nil)
+	}
 
+	history, err := readTaskHistory(r.base.RepoRoot)
+	if err != nil {
+		r.base.Logger.Debug("failed to read task history", "error", err)
+		history = newTaskHistory(r.base.RepoRoot)
+	}
+	if os.Getenv(skipDiskCheckEnvVar) != "true" {
+		if err := r.checkDiskSpace(g, rs, engine, history); err != nil {
+			return err
+		}
+	}
+	taskEnv, err := newTaskEnvironment(&rs.Opts.runOpts, g.GlobalEnv, r.base.RepoRoot, g, engine.TaskGraph)
+	if err != nil {
+		return errcode.Wrap(errcode.InvalidArguments, errors.Wrap(err, "failed to load dotenv files"))
+	}
 	ec := &execContext{
-		colorCache:      colorCache,
-		runState:        runState,
-		rs:              rs,
-		ui:              &cli.ConcurrentUi{Ui: r.base.UI},
-		runCache:        runCache,
-		logger:          r.base.Logger,
-		packageManager:  packageManager,
-		processes:       r.processes,
-		taskHashes:      hashes,
-		repoRoot:        r.base.RepoRoot,
-		isSinglePackage: r.opts.runOpts.singlePackage,
+		colorCache:     colorCache,
+		runState:       runState,
+		rs:             rs,
+		ui:             &cli.ConcurrentUi{Ui: r.base.UI},
+		runCache:       runCache,
+		logger:         r.base.Logger,
+		packageManager: packageManager,
+
+		workspacePackageManagers: g.WorkspacePackageManagers,
+		processes:                r.processes,
+		taskHashes:               hashes,
+		repoRoot:                 r.base.RepoRoot,
+		isSinglePackage:          r.opts.runOpts.singlePackage,
+		resumeState:              resumeState,
+		taskHistory:              history,
+		taskEnv:                  taskEnv,
+	}
+	if rs.Opts.runOpts.logOrder.grouped() {
+		ec.groupedLogs = newGroupedLogs(ec.ui)
+	}
+	if rs.Opts.runOpts.junitFile != "" {
+		ec.junit = newJUnitReport()
+	}
+	runID := uuid.New().String()
+	if rs.Opts.runOpts.logDrain != "" {
+		drain, err := newLogDrain(rs.Opts.runOpts.logDrain, os.Getenv("TURBO_LOG_DRAIN_TOKEN"), runID, r.base.Logger.Named("log-drain"))
+		if err != nil {
+			return errors.Wrap(err, "invalid --log-drain")
+		}
+		ec.logDrain = drain
+		r.base.UI.Info(ui.Dim(fmt.Sprintf("• Streaming logs of run %v to %v", runID, rs.Opts.runOpts.logDrain)))
+	}
+	trace, err := newRunTrace(os.Getenv, runID, r.base.TurboVersion)
+	if err != nil {
+		r.base.LogWarning("", err)
+	}
+	ec.taskEnv.trace = trace
+	if rs.Opts.runOpts.audit {
+		audit, err := newInputAudit(r.base.RepoRoot, g, engine.TaskGraph, g.GlobalDeps)
+		if err != nil {
+			r.base.LogWarning("tasks will not be audited", err)
+		} else {
+			ec.audit = audit
+		}
+	}
+	if len(rs.Opts.runOpts.remoteAgents) > 0 {
+		token := os.Getenv(agent.TokenEnvVar)
+		if token == "" {
+			return errcode.Wrap(errcode.InvalidArguments, fmt.Errorf("--experimental-remote-agents requires %v to be set to the token of the agents", agent.TokenEnvVar))
+		}
+		pool, err := agent.NewPool(rs.Opts.runOpts.remoteAgents, token)
+		if err != nil {
+			return errcode.Wrap(errcode.InvalidArguments, errors.Wrap(err, "invalid --experimental-remote-agents"))
+		}
+		ec.remoteAgents = &remoteAgents{pool: pool, graph: g, taskGraph: engine.TaskGraph}
+		r.base.UI.Info(ui.Dim(fmt.Sprintf("• Running cacheable tasks on %v remote agents", len(rs.Opts.runOpts.remoteAgents))))
+	}
+	if pipelineHasArtifacts(g.Pipeline) {
+		dir := runStatePath(r.base.RepoRoot, append(artifactsDir, runID))
+		if rs.Opts.runOpts.artifactsDir != "" {
+			dir = fs.ResolveUnknownPath(r.base.RepoRoot, rs.Opts.runOpts.artifactsDir)
+		}
+		ec.artifacts = newTaskArtifacts(r.base.RepoRoot, dir, runID)
+	}
+	if rs.Opts.runOpts.uploadFailureLogs {
+		if useHTTPCache {
+			ec.failureLogs = newFailureLogs(r.base.APIClient, runID)
+		} else {
+			r.base.LogWarning("--upload-failure-logs requires Remote Caching, logs of failed tasks will not be uploaded", nil)
+		}
+	}
+
+	recent, err := readRecentTargets(r.base.RepoRoot)
+	if err != nil {
+		r.base.Logger.Debug("failed to read recent targets", "error", err)
+		recent = &recentTargets{path: runStatePath(r.base.RepoRoot, recentTargetsFile)}
+	}
+
+	// If turbo is interrupted, it exits once the signal's cleanup is done, without
+	// finishing the run, so report how far the run got as part of that cleanup
+	finished := make(chan struct{})
+	defer close(finished)
+	removeReporter := r.signalWatcher.AddOnClose(func() {
+		select {
+		case <-finished:
+		default:
+			r.reportInterruption(g, engine, runState, resumeState)
+		}
+	})
+	// Under --watch, each iteration registers its own reporter
+	defer removeReporter()
+
+	// Once --run-timeout passes, no more tasks start, but the tasks that are running
+	// keep ctx, so that the outputs of those that finish are still saved
+	engineCtx := ctx
+	var deadline *runDeadline
+	if timeout := rs.Opts.runOpts.runTimeout; timeout > 0 {
+		deadline = newRunDeadline(ctx, startAt, timeout, rs.Opts.runOpts.runTimeoutGrace, ec.processes.Close)
+		engineCtx = deadline.ctx
 	}
 
 	// run the thing
 	execOpts := core.ExecOpts{
-		Parallel:    rs.Opts.runOpts.parallel,
-		Concurrency: rs.Opts.runOpts.concurrency,
+		Context:         engineCtx,
+		ContinueOnError: rs.Opts.runOpts.continuesOnError(),
+		MaxFailures:     rs.Opts.runOpts.maxFailures,
+		Parallel:        rs.Opts.runOpts.parallel,
+		Concurrency:     rs.Opts.runOpts.concurrency,
+		Semaphore:       util.NewSemaphore(rs.Opts.runOpts.concurrency),
+		// Tasks on the longest chains, by how long they took before, start first
+		EstimatedDuration: history.estimatedDuration,
+		Requested:         requestedTasks(g, rs.FilteredPkgs, engine.TaskGraph),
+		// Dev servers run alongside one-shot tasks without using up their slots
+		PersistentOutsideConcurrency: rs.Opts.runOpts.persistentOutsideConcurrency,
+	}
+	outputDeps, consumedGlobs, err := outputDependencies(g, engine.TaskGraph)
+	if err != nil {
+		return err
+	}
+	if len(outputDeps) > 0 {
+		readiness, err := newOutputReadiness(r.base.RepoRoot, ec.ui, g, consumedGlobs)
+		if err != nil {
+			return err
+		}
+		execOpts.OutputDeps = outputDeps
+		execOpts.Ready = readiness.readyChannel
+		ec.outputReadiness = readiness
+	}
+	var spec *speculation
+	if rs.Opts.runOpts.speculate {
+		spec = r.prepareSpeculation(g, rs, engine, recent, runCache, useHTTPCache, execOpts.Semaphore)
+	}
+	if spec != nil {
+		spec.start(ctx, g, rs.Opts.runOpts.concurrency)
+	}
+	// Restore the outputs of cache hits ahead of their tasks, with --cache-workers workers
+	var ahead *restoreAhead
+	if workers := rs.Opts.cacheOpts.Workers; workers > 0 {
+		runCache.StartRestoring(workers)
+		ahead = &restoreAhead{
+			engine:      engine,
+			tracker:     hashes,
+			runCache:    runCache,
+			rs:          rs,
+			resumeState: resumeState,
+			logger:      r.base.Logger.Named("restore"),
+		}
+		ahead.start(engineCtx, g, workers)
+	}
+	results, errs := engine.ExecuteWithResults(func(taskID string, result *core.TaskResult) error {
+		packageTask, err := g.packageTask(taskID)
+		if err != nil || packageTask == nil {
+			return err
+		}
+		deps := engine.TaskGraph.DownEdges(taskID)
+		return ec.exec(ctx, packageTask, deps, result)
+	}, execOpts)
+	if ahead != nil {
+		ahead.stop()
+		runCache.StopRestoring()
+	}
+	if r.watching != nil {
+		r.watching.record(results)
+	}
+	timedOut, stoppedRunning := false, false
+	if deadline != nil {
+		timedOut, stoppedRunning = deadline.finish()
+	}
+	// Tasks that never ran because a dependency failed are only known to the engine
+	failedTasks, stoppedTasks, canceledTasks := 0, 0, 0
+	for _, result := range results {
+		if result.Status == core.TaskFailed {
+			failedTasks++
+		}
+		if result.Status == core.TaskCanceled {
+			if packageTask, err := g.packageTask(result.TaskID); err == nil && packageTask != nil {
+				if _, ok := packageTask.Command(); ok {
+					canceledTasks++
+				}
+			}
+		}
+		if result.Status != core.TaskSkipped {
+			continue
+		}
+		if packageTask, err := g.packageTask(result.TaskID); err == nil && packageTask != nil {
+			if _, ok := packageTask.Command(); ok {
+				if len(result.BlockedBy) == 0 {
+					stoppedTasks++
+				}
+				runState.skipped(result.TaskID, result.BlockedBy, result.RootCauses)
+			}
+		}
+	}
+	if maxFailures := rs.Opts.runOpts.maxFailures; maxFailures > 0 && failedTasks >= maxFailures && stoppedTasks > 0 {
+		r.base.UI.Warn(fmt.Sprintf("• Stopped after %v tasks failed (--max-failures=%v), %v tasks didn't start", failedTasks, maxFailures, stoppedTasks))
+	}
+	if timedOut {
+		r.base.UI.Warn(timeoutMessage(rs.Opts.runOpts.runTimeout, rs.Opts.runOpts.runTimeoutGrace, canceledTasks, stoppedRunning))
+	}
+	if spec != nil {
+		if restored := spec.stop(); len(restored) > 0 {
+			r.base.UI.Info(ui.Dim(fmt.Sprintf("• Speculatively restored %v from the Remote Cache, in case %v runs next", strings.Join(restored, ", "), strings.Join(spec.targets, ", "))))
+		}
+	}
+	recent.record(rs.Targets)
+	if err := recent.write(); err != nil {
+		r.base.Logger.Debug("failed to record recent targets", "error", err)
 	}
-	visitor := g.getPackageTaskVisitor(ctx, func(ctx gocontext.Context, packageTask *nodes.PackageTask) error {
-		deps := engine.TaskGraph.DownEdges(packageTask.TaskID)
-		return ec.exec(ctx, packageTask, deps)
-	})
-	errs := engine.Execute(visitor, execOpts)
 
 	// Track if we saw any child with a non-zero exit code
 	exitCode := 0
@@ -800,24 +1511,169 @@ func (r *run) executeTasks(ctx gocontext.Context, g *completeGraph, rs *runSpec,
 		}
 		r.base.UI.Error(err.Error())
 	}
+	if timedOut {
+		exitCode = _runTimeoutExitCode
+	}
+
+	runState.flaky = history.flakyTasks(true)
+	if ec.audit != nil {
+		runState.audited = true
+		runState.audit = ec.audit.results()
+	}
+	if err := history.write(); err != nil {
+		r.base.Logger.Debug("failed to record task history", "error", err)
+	}
+	if rs.Opts.runOpts.flakyReportFile != "" {
+		reportPath := fs.ResolveUnknownPath(r.base.RepoRoot, rs.Opts.runOpts.flakyReportFile)
+		if err := history.writeFlakyReport(reportPath); err != nil {
+			r.base.LogWarning("failed to write flaky task report", err)
+		}
+	}
 
+	if ec.artifacts != nil {
+		runState.artifacts = ec.artifacts
+		if err := ec.artifacts.writeManifest(); err != nil {
+			r.base.LogWarning("failed to write the manifest of task artifacts", err)
+		}
+	}
+
+	// Finish writing to the cache first, so that the summary counts every upload
+	shutdownCache()
 	if err := runState.Close(r.base.UI, rs.Opts.runOpts.profile); err != nil {
 		return errors.Wrap(err, "error with profiler")
 	}
+	if err := resumeState.Close(); err != nil {
+		r.base.LogWarning("failed to save run state, --resume will not be available", err)
+	}
+	if ec.junit != nil {
+		junitPath := fs.ResolveUnknownPath(r.base.RepoRoot, rs.Opts.runOpts.junitFile)
+		if err := ec.junit.write(junitPath, runState.Results(), time.Since(startAt)); err != nil {
+			r.base.LogWarning("failed to write JUnit report", err)
+		}
+	}
+	if rs.Opts.runOpts.summarize || rs.Opts.runOpts.notifyWebhook != "" {
+		summary := newRunSummary(runID, r.base.TurboVersion, rs.Targets, startAt, exitCode, g.GlobalHashInputs, runState.Results(), hashes.TaskHashes(), runState.transfers)
+		if rs.Opts.runOpts.summarize {
+			summaryPath := runStatePath(r.base.RepoRoot, append(runSummaryDir, runID+".json"))
+			if err := summary.write(summaryPath); err != nil {
+				r.base.LogWarning("failed to write run summary", err)
+			} else {
+				r.base.UI.Info(ui.Dim(fmt.Sprintf("• Wrote summary of run %v to %v", runID, summaryPath)))
+			}
+		}
+		if rs.Opts.runOpts.notifyWebhook != "" {
+			if err := postRunSummary(rs.Opts.runOpts.notifyWebhook, os.Getenv(notifyWebhookTokenEnvVar), summary); err != nil {
+				r.base.LogWarning("failed to send the run summary to notifyWebhook", err)
+			}
+		}
+	}
+	if ec.failureLogs != nil {
+		uploaded, err := ec.failureLogs.wait()
+		if err != nil {
+			r.base.LogWarning("failed to upload logs of failed tasks", err)
+		} else if uploaded > 0 {
+			r.base.UI.Info(ui.Dim(fmt.Sprintf("• Uploaded logs of %v failed tasks for run %v", uploaded, ec.failureLogs.runID)))
+		}
+	}
+	if ec.logDrain != nil {
+		if err := ec.logDrain.close(); err != nil {
+			r.base.LogWarning("", err)
+		}
+	}
+	if trace != nil && trace.exporting() {
+		if err := trace.export(trace.spans(rs.Targets, startAt, time.Now(), exitCode, results)); err != nil {
+			r.base.LogWarning("failed to export spans of the run", err)
+		}
+	}
+	if warning := r.base.APIClient.ClockSkewWarning(); warning != "" {
+		r.base.LogWarning("", errors.New(warning))
+	}
+	if timedOut {
+		return errcode.Wrap(errcode.RunTimeout, fmt.Errorf("the run took longer than --run-timeout=%v: %w", rs.Opts.runOpts.runTimeout, &process.ChildExit{
+			ExitCode: exitCode,
+		}))
+	}
 	if exitCode != 0 {
-		return &process.ChildExit{
+		return errcode.Wrap(errcode.TaskFailed, &process.ChildExit{
 			ExitCode: exitCode,
-		}
+		})
 	}
 	return nil
 }
 
+// checkDiskSpace fails early if the outputs of the tasks to run, by their size in
+// previous runs, won't fit in the work tree and the local cache
+func (r *run) checkDiskSpace(g *completeGraph, rs *runSpec, engine *core.Engine, history *taskHistory) error {
+	var tasks []plannedOutputs
+	for _, v := range engine.TaskGraph.Vertices() {
+		taskID := dag.VertexName(v)
+		size, ok := history.outputSize(taskID)
+		if !ok {
+			continue
+		}
+		packageTask, err := g.packageTask(taskID)
+		if err != nil || packageTask == nil {
+			continue
+		}
+		if _, ok := packageTask.Command(); ok {
+			tasks = append(tasks, plannedOutputs{taskID: taskID, size: size, cached: packageTask.TaskDefinition.ShouldCache})
+		}
+	}
+	if len(tasks) == 0 {
+		return nil
+	}
+	workTree, err := fs.GetDiskSpace(r.base.RepoRoot)
+	if err != nil {
+		r.base.Logger.Debug("failed to check free disk space", "error", err)
+		return nil
+	}
+	var cacheDir *diskLocation
+	if !rs.Opts.cacheOpts.SkipFilesystem && !rs.Opts.runcacheOpts.SkipWrites {
+		dir := cache.ResolveCacheDir(rs.Opts.cacheOpts, r.base.RepoRoot)
+		space, err := fs.GetDiskSpace(dir)
+		if err != nil {
+			r.base.Logger.Debug("failed to check free disk space", "error", err)
+			return nil
+		}
+		cacheDir = &diskLocation{name: "the cache directory", space: space}
+	}
+	return checkDiskSpace(tasks, diskLocation{name: "the work tree", space: workTree}, cacheDir)
+}
+
+// reportInterruption prints, and saves for later, which tasks of the run completed,
+// were running, or hadn't started when turbo was interrupted
+func (r *run) reportInterruption(g *completeGraph, engine *core.Engine, runState *RunState, resumeState *resumeState) {
+	var taskIDs []string
+	for _, v := range engine.TaskGraph.Vertices() {
+		taskID := dag.VertexName(v)
+		if strings.Contains(taskID, core.ROOT_NODE_NAME) {
+			continue
+		}
+		packageTask, err := g.packageTask(taskID)
+		if err != nil || packageTask == nil || packageTask.TaskDefinition.Passthrough {
+			continue
+		}
+		if _, ok := packageTask.Command(); ok {
+			taskIDs = append(taskIDs, taskID)
+		}
+	}
+	summary := newInterruptSummary(taskIDs, runState.Results(), resumeState.outputsSaved)
+	summary.print(r.base.UI)
+	path := runStatePath(r.base.RepoRoot, interruptSummaryFile)
+	if err := summary.write(path); err != nil {
+		r.base.LogWarning("", err)
+		return
+	}
+	r.base.UI.Info(ui.Dim(fmt.Sprintf("• Saved this summary to %v. Pass --resume to skip the tasks that completed", path)))
+}
+
 type hashedTask struct {
 	TaskID          string           `json:"taskId"`
 	Task            string           `json:"task"`
 	Package         string           `json:"package"`
 	Hash            string           `json:"hash"`
 	CacheState      cache.ItemStatus `json:"cacheState"`
+	CacheSource     string           `json:"cacheSource"`
 	Command         string           `json:"command"`
 	Outputs         []string         `json:"outputs"`
 	ExcludedOutputs []string         `json:"excludedOutputs"`
@@ -858,6 +1714,46 @@ type hashedSinglePackageTask struct {
 	Dependents      []string `json:"dependents"`
 }
 
+// prepareSpeculation returns a speculation on the tasks that usually run after the
+// current targets, or nil if there is nothing to speculate on
+func (r *run) prepareSpeculation(g *completeGraph, rs *runSpec, engine *core.Engine, recent *recentTargets, runCache *runcache.RunCache, useHTTPCache bool, sema util.Semaphore) *speculation {
+	logger := r.base.Logger.Named("speculation")
+	if rs.Opts.runOpts.parallel || !useHTTPCache {
+		logger.Debug("not speculating, it requires Remote Caching and limited concurrency")
+		return nil
+	}
+	targets := recent.predictNext(rs.Targets)
+	if len(targets) == 0 {
+		logger.Debug("no prediction for the next targets")
+		return nil
+	}
+	if err := validateTasks(g.Pipeline, targets); err != nil {
+		logger.Debug("not speculating on predicted targets", "targets", targets, "error", err)
+		return nil
+	}
+	specRS := &runSpec{
+		Targets:      targets,
+		FilteredPkgs: rs.FilteredPkgs,
+		Opts:         rs.Opts,
+	}
+	specEngine, err := buildTaskGraphEngine(&g.TopologicalGraph, g.Pipeline, specRS)
+	if err != nil {
+		logger.Debug("not speculating on predicted targets", "targets", targets, "error", err)
+		return nil
+	}
+	logger.Debug("speculating", "targets", targets)
+	return &speculation{
+		targets:  targets,
+		engine:   specEngine,
+		tracker:  taskhash.NewTracker(g.RootNode, g.GlobalHash, g.Pipeline, g.PackageInfos, rs.Opts.runOpts.environment, g.HashExclude),
+		runCache: runCache,
+		running:  engine,
+		sema:     sema,
+		logger:   logger,
+		repoRoot: r.base.RepoRoot,
+	}
+}
+
 func (r *run) executeDryRun(ctx gocontext.Context, engine *core.Engine, g *completeGraph, taskHashes *taskhash.Tracker, rs *runSpec) ([]hashedTask, error) {
 	analyticsClient := r.initAnalyticsClient(ctx)
 	defer analyticsClient.CloseWithTimeout(50 * time.Millisecond)
@@ -872,10 +1768,16 @@ func (r *run) executeDryRun(ctx gocontext.Context, engine *core.Engine, g *compl
 		}
 	}
 
+	history, err := readTaskHistory(r.base.RepoRoot)
+	if err != nil {
+		r.base.Logger.Debug("failed to read task history", "error", err)
+		history = newTaskHistory(r.base.RepoRoot)
+	}
+
 	taskIDs := []hashedTask{}
 
 	errs := engine.Execute(g.getPackageTaskVisitor(ctx, func(ctx gocontext.Context, packageTask *nodes.PackageTask) error {
-		passThroughArgs := rs.ArgsForTask(packageTask.Task)
+		passThroughArgs := rs.ArgsForTask(packageTask.TaskID)
 		deps := engine.TaskGraph.DownEdges(packageTask.TaskID)
 		hash, err := taskHashes.CalculateTaskHash(packageTask, deps, r.base.Logger, passThroughArgs)
 		if err != nil {
@@ -924,6 +1826,7 @@ func (r *run) executeDryRun(ctx gocontext.Context, engine *core.Engine, g *compl
 			Package:         packageTask.PackageName,
 			Hash:            hash,
 			CacheState:      itemStatus,
+			CacheSource:     itemStatus.Source(),
 			Command:         command,
 			Dir:             packageTask.Pkg.Dir.ToString(),
 			Outputs:         packageTask.TaskDefinition.Outputs.Inclusions,
@@ -934,10 +1837,7 @@ func (r *run) executeDryRun(ctx gocontext.Context, engine *core.Engine, g *compl
 		})
 
 		return nil
-	}), core.ExecOpts{
-		Concurrency: 1,
-		Parallel:    false,
-	})
+	}), dryRunExecOpts(ctx, history, requestedTasks(g, rs.FilteredPkgs, engine.TaskGraph)))
 	if len(errs) > 0 {
 		for _, err := range errs {
 			r.base.UI.Error(err.Error())
@@ -963,30 +1863,44 @@ func validateTasks(pipeline fs.Pipeline, tasks []string) error {
 }
 
 type execContext struct {
-	colorCache      *colorcache.ColorCache
-	runState        *RunState
-	rs              *runSpec
-	ui              cli.Ui
-	runCache        *runcache.RunCache
-	logger          hclog.Logger
-	packageManager  *packagemanager.PackageManager
-	processes       *process.Manager
-	taskHashes      *taskhash.Tracker
-	repoRoot        turbopath.AbsoluteSystemPath
-	isSinglePackage bool
+	colorCache     *colorcache.ColorCache
+	runState       *RunState
+	rs             *runSpec
+	ui             cli.Ui
+	runCache       *runcache.RunCache
+	logger         hclog.Logger
+	packageManager *packagemanager.PackageManager
+	// workspacePackageManagers overrides packageManager for workspaces in a federation of monorepos
+	workspacePackageManagers map[string]*packagemanager.PackageManager
+	processes                *process.Manager
+	taskHashes               *taskhash.Tracker
+	repoRoot                 turbopath.AbsoluteSystemPath
+	isSinglePackage          bool
+	resumeState              *resumeState
+	junit                    *junitReport
+	failureLogs              *failureLogs
+	artifacts                *taskArtifacts
+	taskHistory              *taskHistory
+	logDrain                 *logDrain
+	remoteAgents             *remoteAgents
+	audit                    *inputAudit
+	taskEnv                  *taskEnvironment
+	outputReadiness          *outputReadiness
+	// groupedLogs is set if the output of each task is printed together when it completes
+	groupedLogs *groupedLogs
 }
 
-func (ec *execContext) logError(log hclog.Logger, prefix string, err error) {
+func (ec *execContext) logError(terminal cli.Ui, prefix string, err error) {
 	ec.logger.Error(prefix, "error", err)
 
 	if prefix != "" {
 		prefix += ": "
 	}
 
-	ec.ui.Error(fmt.Sprintf("%s%s%s", ui.ERROR_PREFIX, prefix, color.RedString(" %v", err)))
+	terminal.Error(fmt.Sprintf("%s%s%s", ui.ERROR_PREFIX, prefix, color.RedString(" %v", err)))
 }
 
-func (ec *execContext) exec(ctx gocontext.Context, packageTask *nodes.PackageTask, deps dag.Set) error {
+func (ec *execContext) exec(ctx gocontext.Context, packageTask *nodes.PackageTask, deps dag.Set, result *core.TaskResult) error {
 	cmdTime := time.Now()
 
 	prefix := packageTask.OutputPrefix(ec.isSinglePackage)
@@ -995,68 +1909,128 @@ func (ec *execContext) exec(ctx gocontext.Context, packageTask *nodes.PackageTas
 	progressLogger := ec.logger.Named("")
 	progressLogger.Debug("start")
 
+	// Persistent tasks never complete, so their output can't be grouped
+	var taskUI cli.Ui = ec.ui
+	var terminalOut io.Writer = os.Stdout
+	if ec.groupedLogs != nil && !packageTask.TaskDefinition.Persistent {
+		group := &groupedOutput{}
+		taskUI, terminalOut = group, group
+		defer ec.groupedLogs.flush(packageTask.TaskID, group)
+	}
+
 	// Setup tracer
 	tracer := ec.runState.Run(packageTask.TaskID)
 
-	passThroughArgs := ec.rs.ArgsForTask(packageTask.Task)
+	passThroughArgs := ec.rs.ArgsForTask(packageTask.TaskID)
 	hash, err := ec.taskHashes.CalculateTaskHash(packageTask, deps, ec.logger, passThroughArgs)
 	ec.logger.Debug("task hash", "value", hash)
 	if err != nil {
-		ec.ui.Error(fmt.Sprintf("Hashing error: %v", err))
+		taskUI.Error(fmt.Sprintf("Hashing error: %v", err))
 		// @TODO probably should abort fatally???
 	}
 	// TODO(gsoltis): if/when we fix https://github.com/vercel/turbo/issues/937
 	// the following block should never get hit. In the meantime, keep it after hashing
 	// so that downstream tasks can count on the hash existing
 	//
-	// bail if the script doesn't exist
+	// bail if the script doesn't exist, or is a passthrough that dependents don't need
+	if packageTask.TaskDefinition.Passthrough {
+		progressLogger.Debug("passthrough task, skipping")
+		progressLogger.Debug("done", "status", "skipped", "duration", time.Since(cmdTime))
+		return nil
+	}
 	if _, ok := packageTask.Command(); !ok {
 		progressLogger.Debug("no task in package, skipping")
 		progressLogger.Debug("done", "status", "skipped", "duration", time.Since(cmdTime))
 		return nil
 	}
+	if ec.resumeState.alreadySucceeded(packageTask.TaskID, hash) {
+		taskUI.Output(fmt.Sprintf("%s: succeeded in previous run, skipping %s", prettyPrefix, ui.Dim(hash)))
+		ec.resumeState.recordSuccess(packageTask.TaskID, hash)
+		tracer(TargetBuilt, nil)
+		progressLogger.Debug("done", "status", "resumed", "duration", time.Since(cmdTime))
+		return nil
+	}
 	// Cache ---------------------------------------------
 	taskCache := ec.runCache.TaskCache(packageTask, hash)
 	// Create a logger for replaying
 	prefixedUI := &cli.PrefixedUi{
-		Ui:           ec.ui,
+		Ui:           taskUI,
 		OutputPrefix: prettyPrefix,
 		InfoPrefix:   prettyPrefix,
 		ErrorPrefix:  prettyPrefix,
 		WarnPrefix:   prettyPrefix,
 	}
-	hit, err := taskCache.RestoreOutputs(ctx, prefixedUI, progressLogger)
+	itemStatus, timeSaved, err := taskCache.RestoreOutputs(ctx, prefixedUI, progressLogger)
 	if err != nil {
 		prefixedUI.Error(fmt.Sprintf("error fetching from cache: %s", err))
-	} else if hit {
+	} else if itemStatus.Hit() {
+		result.CacheStatus = core.CacheHit
+		if timeSaved == 0 {
+			// Outputs that were already in place weren't fetched, so the cache didn't
+			// say how long the task took
+			timeSaved, _ = ec.taskHistory.estimatedDuration(packageTask.TaskID)
+		}
+		ec.runState.cacheHit(packageTask.TaskID, itemStatus.Source(), timeSaved)
+		ec.resumeState.recordSuccess(packageTask.TaskID, hash)
+		ec.collectArtifacts(packageTask, prefixedUI)
+		if ec.logDrain != nil {
+			ec.logDrain.taskEnded(packageTask.TaskID, hash, logStatusCached, time.Since(cmdTime))
+		}
 		tracer(TargetCached, nil)
 		return nil
 	}
 
+	result.CacheStatus = core.CacheMiss
+
 	// Setup command execution
-	argsactual := append([]string{"run"}, packageTask.Task)
-	if len(passThroughArgs) > 0 {
-		// This will be either '--' or a typed nil
-		argsactual = append(argsactual, ec.packageManager.ArgSeparator...)
-		argsactual = append(argsactual, passThroughArgs...)
+	taskEnv := ec.taskEnv.forTask(packageTask, hash)
+	var cmd *exec.Cmd
+	if ec.rs.Opts.runOpts.directExec || packageTask.UsesBuiltinCommand() {
+		cmd = directCommand(packageTask, passThroughArgs, ec.repoRoot, taskEnv)
+	}
+	if cmd == nil && packageTask.UsesBuiltinCommand() {
+		// The package manager can only run scripts from package.json
+		err := fmt.Errorf("cannot run %q, it isn't installed in node_modules/.bin", packageTask.BuiltinCommand)
+		tracer(TargetBuildFailed, err)
+		ec.logError(taskUI, prettyPrefix, err)
+		return err
 	}
+	if cmd == nil {
+		packageManager := ec.packageManager
+		if workspacePackageManager, ok := ec.workspacePackageManagers[packageTask.PackageName]; ok {
+			packageManager = workspacePackageManager
+		}
+		argsactual := append([]string{"run"}, packageTask.Task)
+		if len(passThroughArgs) > 0 {
+			// This will be either '--' or a typed nil
+			argsactual = append(argsactual, packageManager.ArgSeparator...)
+			argsactual = append(argsactual, passThroughArgs...)
+		}
 
-	cmd := exec.Command(ec.packageManager.Command, argsactual...)
-	// TODO: repoRoot probably should be AbsoluteSystemPath, but it's Join method
-	// takes a RelativeSystemPath. Resolve during migration from turbopath.AbsoluteSystemPath to
-	// AbsoluteSystemPath
-	cmd.Dir = ec.repoRoot.UntypedJoin(packageTask.Pkg.Dir.ToStringDuringMigration()).ToString()
-	envs := fmt.Sprintf("TURBO_HASH=%v", hash)
-	cmd.Env = append(os.Environ(), envs)
+		cmd = exec.Command(packageManager.Command, argsactual...)
+		// TODO: repoRoot probably should be AbsoluteSystemPath, but it's Join method
+		// takes a RelativeSystemPath. Resolve during migration from turbopath.AbsoluteSystemPath to
+		// AbsoluteSystemPath
+		cmd.Dir = ec.repoRoot.UntypedJoin(packageTask.Pkg.Dir.ToStringDuringMigration()).ToString()
+		cmd.Env = taskEnv
+	}
+
+	// A task can fail because of its output, as well as its exit code
+	outputFailure, err := newOutputFailure(packageTask.TaskID, packageTask.TaskDefinition)
+	if err != nil {
+		tracer(TargetBuildFailed, err)
+		ec.logError(taskUI, prettyPrefix, err)
+		return err
+	}
 
 	// Setup stdout/stderr
 	// If we are not caching anything, then we don't need to write logs to disk
 	// be careful about this conditional given the default of cache = true
-	writer, err := taskCache.OutputWriter(prettyPrefix)
+	writer, err := taskCache.OutputWriter(prettyPrefix, terminalOut)
 	if err != nil {
 		tracer(TargetBuildFailed, err)
-		ec.logError(progressLogger, prettyPrefix, err)
-		if !ec.rs.Opts.runOpts.continueOnError {
+		ec.logError(taskUI, prettyPrefix, err)
+		if !ec.rs.Opts.runOpts.continuesOnError() {
 			os.Exit(1)
 		}
 	}
@@ -1067,8 +2041,32 @@ func (ec *execContext) exec(ctx gocontext.Context, packageTask *nodes.PackageTas
 	logStreamerOut := logstreamer.NewLogstreamer(logger, prettyPrefix, false)
 	// Setup a streamer that we'll pipe cmd.Stderr to.
 	logStreamerErr := logstreamer.NewLogstreamer(logger, prettyPrefix, false)
-	cmd.Stderr = logStreamerErr
-	cmd.Stdout = logStreamerOut
+	var stdout io.Writer = logStreamerOut
+	var stderr io.Writer = logStreamerErr
+	if ec.junit != nil {
+		stderr = io.MultiWriter(stderr, ec.junit.stderrFor(packageTask.TaskID))
+	}
+	if ec.failureLogs != nil {
+		output := ec.failureLogs.outputFor(packageTask.TaskID)
+		stdout = io.MultiWriter(stdout, output)
+		stderr = io.MultiWriter(stderr, output)
+	}
+	var drainOut, drainErr *logDrainWriter
+	if ec.logDrain != nil {
+		drainOut = ec.logDrain.outputFor(packageTask.TaskID, hash, "stdout")
+		drainErr = ec.logDrain.outputFor(packageTask.TaskID, hash, "stderr")
+		stdout = io.MultiWriter(stdout, drainOut)
+		stderr = io.MultiWriter(stderr, drainErr)
+	}
+	var failureOut, failureErr *outputFailureWriter
+	if outputFailure != nil {
+		failureOut = outputFailure.stdout()
+		failureErr = outputFailure.stderr()
+		stdout = io.MultiWriter(stdout, failureOut)
+		stderr = io.MultiWriter(stderr, failureErr)
+	}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
 	// Flush/Reset any error we recorded
 	logStreamerErr.FlushRecord()
 	logStreamerOut.FlushRecord()
@@ -1086,6 +2084,10 @@ func (ec *execContext) exec(ctx gocontext.Context, packageTask *nodes.PackageTas
 		if err := writer.Close(); err != nil {
 			closeErrors = append(closeErrors, errors.Wrap(err, "log file"))
 		}
+		if ec.logDrain != nil {
+			drainOut.Flush()
+			drainErr.Flush()
+		}
 		if len(closeErrors) > 0 {
 			msgs := make([]string, len(closeErrors))
 			for i, err := range closeErrors {
@@ -1097,7 +2099,18 @@ func (ec *execContext) exec(ctx gocontext.Context, packageTask *nodes.PackageTas
 	}
 
 	// Run the command
-	if err := ec.processes.Exec(cmd); err != nil {
+	ec.resumeState.recordStart(packageTask.TaskID, hash)
+	if ec.logDrain != nil {
+		ec.logDrain.taskStarted(packageTask.TaskID, hash)
+	}
+	err = ec.execCommand(packageTask, cmd, hash, passThroughArgs)
+	if err == nil && outputFailure != nil {
+		// The command has exited, so its output is complete apart from any last line
+		_ = failureOut.Close()
+		_ = failureErr.Close()
+		err = outputFailure.err()
+	}
+	if err != nil {
 		// close off our outputs. We errored, so we mostly don't care if we fail to close
 		_ = closeOutputs()
 		// if we already know we're in the process of exiting,
@@ -1105,9 +2118,31 @@ func (ec *execContext) exec(ctx gocontext.Context, packageTask *nodes.PackageTas
 		if errors.Is(err, process.ErrClosing) {
 			return nil
 		}
-		tracer(TargetBuildFailed, err)
+		taskCache.ReplayFailedOutput(progressLogger, prefixedUI)
+		// Only the task itself failing says anything about whether it's flaky, not
+		// failing to start it or to reach an agent
+		childExit := &process.ChildExit{}
+		if errors.As(err, &childExit) || (outputFailure != nil && outputFailure.err() != nil) {
+			ec.taskHistory.record(packageTask.TaskID, hash, false, time.Since(cmdTime))
+		}
+		if ec.logDrain != nil {
+			ec.logDrain.taskEnded(packageTask.TaskID, hash, logStatusFailed, time.Since(cmdTime))
+		}
 		progressLogger.Error(fmt.Sprintf("Error: command finished with error: %v", err))
-		if !ec.rs.Opts.runOpts.continueOnError {
+		if !result.LastAttempt {
+			// The engine runs the task again, and only the last failure is recorded
+			tracer(TargetBuildRetried, err)
+			prefixedUI.Warn(fmt.Sprintf("command finished with error, retrying (attempt %v failed)", result.Attempts))
+			return err
+		}
+		tracer(TargetBuildFailed, err)
+		ec.resumeState.recordFailure(packageTask.TaskID, hash)
+		// Reports, such as of failed tests, are most useful when the task fails
+		ec.collectArtifacts(packageTask, prefixedUI)
+		if ec.failureLogs != nil {
+			ec.failureLogs.upload(packageTask.TaskID, hash)
+		}
+		if !ec.rs.Opts.runOpts.continuesOnError() {
 			prefixedUI.Error(fmt.Sprintf("ERROR: command finished with error: %s", err))
 			ec.processes.Close()
 		} else {
@@ -1117,48 +2152,150 @@ func (ec *execContext) exec(ctx gocontext.Context, packageTask *nodes.PackageTas
 	}
 
 	duration := time.Since(cmdTime)
+	ec.taskHistory.record(packageTask.TaskID, hash, true, duration)
 	// Close off our outputs and cache them
 	if err := closeOutputs(); err != nil {
-		ec.logError(progressLogger, "", err)
+		ec.logError(taskUI, "", err)
 	} else {
-		if err = taskCache.SaveOutputs(ctx, progressLogger, prefixedUI, int(duration.Milliseconds())); err != nil {
-			ec.logError(progressLogger, "", fmt.Errorf("error caching output: %w", err))
+		var outputBytes int64
+		outputBytes, err = taskCache.SaveOutputs(ctx, progressLogger, prefixedUI, int(duration.Milliseconds()))
+		ec.taskHistory.recordOutputSize(packageTask.TaskID, hash, outputBytes)
+		var sizeErr *runcache.OutputSizeError
+		var missingErr *runcache.MissingOutputsError
+		if errors.As(err, &sizeErr) {
+			ec.runState.outputsTooLarge(sizeErr)
+		}
+		// Outputs that are too large fail the task only if it asks to, but missing
+		// outputs only get here with --validate-outputs=strict
+		if errors.As(err, &missingErr) || (sizeErr != nil && packageTask.TaskDefinition.FailOnMaxOutputSize) {
+			if !result.LastAttempt {
+				tracer(TargetBuildRetried, err)
+				prefixedUI.Warn(fmt.Sprintf("%v, retrying (attempt %v failed)", err, result.Attempts))
+				return err
+			}
+			tracer(TargetBuildFailed, err)
+			ec.resumeState.recordFailure(packageTask.TaskID, hash)
+			progressLogger.Error(fmt.Sprintf("Error: %v", err))
+			if !ec.rs.Opts.runOpts.continuesOnError() {
+				prefixedUI.Error(fmt.Sprintf("ERROR: %v", err))
+				ec.processes.Close()
+			} else {
+				prefixedUI.Warn(fmt.Sprintf("%v, but continuing...", err))
+			}
+			return err
+		} else if sizeErr != nil {
+			prefixedUI.Warn(fmt.Sprintf("not caching outputs: %v", err))
+		} else if errors.Is(err, runcache.ErrMachineSpecificOutputs) {
+			// Already reported as a warning
+		} else if err != nil {
+			ec.logError(taskUI, "", fmt.Errorf("error caching output: %w", err))
+		} else {
+			ec.resumeState.recordSaved(packageTask.TaskID, hash)
 		}
 	}
 
+	ec.resumeState.recordSuccess(packageTask.TaskID, hash)
+	ec.collectArtifacts(packageTask, prefixedUI)
+	if ec.logDrain != nil {
+		ec.logDrain.taskEnded(packageTask.TaskID, hash, logStatusSucceeded, duration)
+	}
 	// Clean up tracing
 	tracer(TargetBuilt, nil)
 	progressLogger.Debug("done", "status", "complete", "duration", duration)
 	return nil
 }
 
+// collectArtifacts copies the artifacts of a task that has completed into the
+// artifacts directory of the run
+func (ec *execContext) collectArtifacts(packageTask *nodes.PackageTask, prefixedUI *cli.PrefixedUi) {
+	if ec.artifacts == nil {
+		return
+	}
+	if err := ec.artifacts.collect(packageTask); err != nil {
+		prefixedUI.Warn(fmt.Sprintf("failed to collect artifacts: %v", err))
+	}
+}
+
+// execCommand runs the command for a task, on a remote agent if there are any and
+// the task can run remotely
+func (ec *execContext) execCommand(packageTask *nodes.PackageTask, cmd *exec.Cmd, hash string, passThroughArgs []string) error {
+	if ec.remoteAgents != nil && ec.remoteAgents.runsRemotely(packageTask) {
+		return ec.remoteAgents.exec(ec, packageTask, hash, passThroughArgs, cmd.Stdout, cmd.Stderr)
+	}
+	if ec.outputReadiness != nil {
+		defer ec.outputReadiness.watch(packageTask)()
+	}
+	if ec.audit == nil {
+		return ec.processes.Exec(cmd)
+	}
+	tracePath, err := ec.audit.wrap(cmd)
+	if err != nil {
+		return err
+	}
+	if err := ec.processes.Exec(cmd); err != nil {
+		_ = tracePath.Remove()
+		return err
+	}
+	if err := ec.audit.record(packageTask, cmd.Dir, tracePath); err != nil {
+		ec.logger.Warn("failed to audit the files read by task", "task", packageTask.TaskID, "error", err)
+	}
+	return nil
+}
+
 func (g *completeGraph) getPackageTaskVisitor(ctx gocontext.Context, visitor func(ctx gocontext.Context, packageTask *nodes.PackageTask) error) func(taskID string) error {
 	return func(taskID string) error {
-
-		name, task := util.GetPackageTaskFromId(taskID)
-		pkg, ok := g.PackageInfos[name]
-		if !ok {
-			return fmt.Errorf("cannot find package %v for task %v", name, taskID)
+		packageTask, err := g.packageTask(taskID)
+		if err != nil || packageTask == nil {
+			return err
 		}
+		return visitor(ctx, packageTask)
+	}
+}
 
-		// first check for package-tasks
-		taskDefinition, ok := g.Pipeline[fmt.Sprintf("%v", taskID)]
-		if !ok {
-			// then check for regular tasks
-			fallbackTaskDefinition, notcool := g.Pipeline[task]
-			// if neither, then bail
-			if !notcool && !ok {
-				return nil
+// packageTask returns the PackageTask for taskID, or nil if the pipeline has no
+// definition for it
+func (g *completeGraph) packageTask(taskID string) (*nodes.PackageTask, error) {
+	name, task := util.GetPackageTaskFromId(taskID)
+	pkg, ok := g.PackageInfos[name]
+	if !ok {
+		return nil, fmt.Errorf("cannot find package %v for task %v", name, taskID)
+	}
+
+	// first check for package-tasks
+	taskDefinition, ok := g.Pipeline[fmt.Sprintf("%v", taskID)]
+	if !ok {
+		// then check for regular tasks
+		fallbackTaskDefinition, notcool := g.Pipeline[task]
+		// if neither, then bail
+		if !notcool && !ok {
+			return nil, nil
+		}
+		// override if we need to...
+		taskDefinition = fallbackTaskDefinition
+	}
+	packageTask := &nodes.PackageTask{
+		TaskID:         taskID,
+		Task:           task,
+		PackageName:    name,
+		Pkg:            pkg,
+		TaskDefinition: &taskDefinition,
+	}
+	if taskDefinition.TypeScript && g.TypeScriptProjects != nil {
+		outputs, ok, err := g.TypeScriptProjects.Outputs(pkg.Dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the TypeScript project of %v: %w", name, err)
+		}
+		if ok {
+			inclusions := append([]string{}, taskDefinition.Outputs.Inclusions...)
+			for _, output := range outputs {
+				if !util.SetFromStrings(inclusions).Includes(output) {
+					inclusions = append(inclusions, output)
+				}
 			}
-			// override if we need to...
-			taskDefinition = fallbackTaskDefinition
-		}
-		return visitor(ctx, &nodes.PackageTask{
-			TaskID:         taskID,
-			Task:           task,
-			PackageName:    name,
-			Pkg:            pkg,
-			TaskDefinition: &taskDefinition,
-		})
+			sort.Strings(inclusions)
+			taskDefinition.Outputs.Inclusions = inclusions
+			packageTask.BuiltinCommand = typescript.BuildCommand
+		}
 	}
+	return packageTask, nil
 }
@@ -2,11 +2,14 @@ package run
 
 import (
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
+	"github.com/vercel/turbo/cli/internal/cache"
 	"github.com/vercel/turbo/cli/internal/chrometracing"
 	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/runcache"
 	"github.com/vercel/turbo/cli/internal/ui"
 	"github.com/vercel/turbo/cli/internal/util"
 
@@ -38,10 +41,19 @@ const (
 	TargetBuilt
 	TargetCached
 	TargetBuildFailed
+	// TargetSkipped is the status of targets that didn't run because a dependency, or
+	// without --continue any other target, failed
+	TargetSkipped
+	// TargetBuildRetried is the status of targets that failed, and are about to run again
+	TargetBuildRetried
 )
 
 type BuildTargetState struct {
 	StartAt time.Time
+	// CacheSource is the cache that a cached target was restored from, and
+	// TimeSaved how long the target took when its outputs were cached
+	CacheSource string
+	TimeSaved   time.Duration
 
 	Duration time.Duration
 	// Target which has just changed
@@ -50,6 +62,13 @@ type BuildTargetState struct {
 	Status RunResultStatus
 	// Error, only populated for failure statuses
 	Err error
+	// Attempts is the number of times the target started building
+	Attempts int
+	// BlockedBy and RootCauses are, for a target skipped because of failed
+	// dependencies, the dependencies that blocked it directly, and the failed
+	// targets that caused it to be skipped
+	BlockedBy  []string
+	RootCauses []string
 }
 
 type RunState struct {
@@ -60,6 +79,21 @@ type RunState struct {
 	// Is the output streaming?
 	Cached    int
 	Attempted int
+	// cacheSources counts cached targets by the cache they were restored from
+	cacheSources map[string]int
+	// oversized holds the tasks whose outputs were not cached because they
+	// exceeded their maxOutputSize
+	oversized []*runcache.OutputSizeError
+	// flaky holds the tasks of this run that have both passed and failed with
+	// their current hash
+	flaky []flakyTask
+	// audited is set if --audit traced the tasks of this run, and audit holds its findings
+	audited bool
+	audit   []auditFinding
+	// artifacts are the artifacts collected from tasks, if any task declares them
+	artifacts *taskArtifacts
+	// transfers counts the bytes moved to and from the remote cache, if it's enabled
+	transfers *cache.TransferStats
 
 	startedAt time.Time
 }
@@ -77,6 +111,8 @@ func NewRunState(startedAt time.Time, tracingProfile string) *RunState {
 		Attempted: 0,
 		state:     make(map[string]*BuildTargetState),
 
+		cacheSources: make(map[string]int),
+
 		startedAt: startedAt,
 	}
 }
@@ -112,6 +148,9 @@ func (r *RunState) add(result *RunResult, previous string, active bool) {
 		s.Status = result.Status
 		s.Err = result.Err
 		s.Duration = result.Duration
+		if result.Status == TargetBuilding {
+			s.Attempts++
+		}
 	} else {
 		r.state[result.Label] = &BuildTargetState{
 			StartAt:  result.Time,
@@ -119,6 +158,7 @@ func (r *RunState) add(result *RunResult, previous string, active bool) {
 			Status:   result.Status,
 			Err:      result.Err,
 			Duration: result.Duration,
+			Attempts: 1,
 		}
 	}
 	switch {
@@ -134,6 +174,70 @@ func (r *RunState) add(result *RunResult, previous string, active bool) {
 	}
 }
 
+// Results returns a snapshot of the state of every task that has started or was
+// skipped, ordered by label
+func (r *RunState) Results() []BuildTargetState {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	results := make([]BuildTargetState, 0, len(r.state))
+	for _, state := range r.state {
+		results = append(results, *state)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Label < results[j].Label
+	})
+	return results
+}
+
+// retried returns the state of every target that started building more than once,
+// ordered by label
+func (r *RunState) retried() []BuildTargetState {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var retried []BuildTargetState
+	for _, state := range r.state {
+		if state.Attempts > 1 {
+			retried = append(retried, *state)
+		}
+	}
+	sort.Slice(retried, func(i, j int) bool {
+		return retried[i].Label < retried[j].Label
+	})
+	return retried
+}
+
+// skipped records that a target didn't run because of a failure
+func (r *RunState) skipped(label string, blockedBy []string, rootCauses []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.state[label] = &BuildTargetState{
+		Label:      label,
+		Status:     TargetSkipped,
+		BlockedBy:  blockedBy,
+		RootCauses: rootCauses,
+	}
+}
+
+// cacheHit records the cache that a target's outputs were restored from, and how
+// long the target took when they were cached. It is called before the target
+// finishes as TargetCached.
+func (r *RunState) cacheHit(label string, source string, timeSaved time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if s, ok := r.state[label]; ok {
+		s.CacheSource = source
+		s.TimeSaved = timeSaved
+	}
+	r.cacheSources[source]++
+}
+
+// outputsTooLarge records that a task's outputs were not cached because of their size
+func (r *RunState) outputsTooLarge(err *runcache.OutputSizeError) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.oversized = append(r.oversized, err)
+}
+
 // Close finishes a trace of a turbo run. The tracing file will be written if applicable,
 // and run stats are written to the terminal
 func (r *RunState) Close(terminal cli.Ui, filename string) error {
@@ -147,8 +251,53 @@ func (r *RunState) Close(terminal cli.Ui, filename string) error {
 	}
 	terminal.Output("") // Clear the line
 	terminal.Output(util.Sprintf("${BOLD} Tasks:${BOLD_GREEN}    %v successful${RESET}${GRAY}, %v total${RESET}", r.Cached+r.Success, r.Attempted))
-	terminal.Output(util.Sprintf("${BOLD}Cached:    %v cached${RESET}${GRAY}, %v total${RESET}", r.Cached, r.Attempted))
+	if r.Cached > 0 {
+		terminal.Output(util.Sprintf("${BOLD}Cached:    %v cached${RESET}${GRAY} (%v local, %v remote), %v total${RESET}", r.Cached, r.cacheSources[cache.CacheSourceLocal], r.cacheSources[cache.CacheSourceRemote], r.Attempted))
+	} else {
+		terminal.Output(util.Sprintf("${BOLD}Cached:    %v cached${RESET}${GRAY}, %v total${RESET}", r.Cached, r.Attempted))
+	}
 	terminal.Output(util.Sprintf("${BOLD}  Time:    %v${RESET} %v${RESET}", time.Since(r.startedAt).Truncate(time.Millisecond), maybeFullTurbo))
+	if r.Attempted > 0 {
+		summary, details := newCacheStats(r.Results(), r.transfers).text(r.transfers != nil)
+		terminal.Output(util.Sprintf("${BOLD} Cache:    %v${RESET}${GRAY} (%v)${RESET}", summary, details))
+	}
+	newFailureCausality(r.Results()).print(terminal)
+	if len(r.oversized) > 0 {
+		sort.Slice(r.oversized, func(i, j int) bool {
+			return r.oversized[i].TaskID < r.oversized[j].TaskID
+		})
+		terminal.Output(util.Sprintf("${BOLD}Uncached:  %v over maxOutputSize${RESET}", len(r.oversized)))
+		for _, oversized := range r.oversized {
+			terminal.Output(util.Sprintf("${GREY}           %v (%v > %v)${RESET}", oversized.TaskID, util.FormatByteSize(oversized.Size), util.FormatByteSize(oversized.Limit)))
+		}
+	}
+	if retried := r.retried(); len(retried) > 0 {
+		terminal.Output(util.Sprintf("${BOLD_YELLOW}Retried:   %v failed at least once and ran again${RESET}", len(retried)))
+		for _, state := range retried {
+			outcome := fmt.Sprintf("passed on attempt %v", state.Attempts)
+			if state.Status != TargetBuilt {
+				outcome = fmt.Sprintf("failed %v attempts", state.Attempts)
+			}
+			terminal.Output(util.Sprintf("${GREY}           %v (%v)${RESET}", state.Label, outcome))
+		}
+	}
+	if len(r.flaky) > 0 {
+		terminal.Output(util.Sprintf("${BOLD_YELLOW}Flaky:     %v failed intermittently with identical inputs${RESET}", len(r.flaky)))
+		for _, flaky := range r.flaky {
+			terminal.Output(util.Sprintf("${GREY}           %v (%v passed, %v failed with hash %v)${RESET}", flaky.TaskID, flaky.Passes, flaky.Failures, flaky.Hash))
+		}
+	}
+	if len(r.audit) > 0 {
+		terminal.Output(util.Sprintf("${BOLD_YELLOW}Audit:     %v of the executed tasks read files that their hash doesn't account for${RESET}", auditedTasks(r.audit)))
+		for _, finding := range r.audit {
+			terminal.Output(util.Sprintf("${GREY}           %v: %v (read %v)${RESET}", finding.TaskID, finding.Suggestion, finding.describePaths()))
+		}
+	} else if r.audited {
+		terminal.Output(util.Sprintf("${BOLD}Audit:     every file read by executed tasks is accounted for${RESET}"))
+	}
+	if r.artifacts != nil {
+		r.artifacts.print(terminal)
+	}
 	terminal.Output("")
 	return nil
 }
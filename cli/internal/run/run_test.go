@@ -4,13 +4,17 @@ import (
 	"fmt"
 	"runtime"
 	"testing"
+	"time"
 
 	"github.com/pyr-sh/dag"
 	"github.com/spf13/pflag"
 	"github.com/vercel/turbo/cli/internal/cache"
+	"github.com/vercel/turbo/cli/internal/env"
 	"github.com/vercel/turbo/cli/internal/fs"
 	"github.com/vercel/turbo/cli/internal/runcache"
 	"github.com/vercel/turbo/cli/internal/scope"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/typescript"
 	"github.com/vercel/turbo/cli/internal/util"
 
 	"github.com/stretchr/testify/assert"
@@ -33,7 +37,8 @@ func TestParseConfig(t *testing.T) {
 			[]string{"foo"},
 			&Opts{
 				runOpts: runOpts{
-					concurrency: 10,
+					concurrency:     10,
+					runTimeoutGrace: time.Minute,
 				},
 				cacheOpts: cache.Opts{
 					Workers: 10,
@@ -48,7 +53,8 @@ func TestParseConfig(t *testing.T) {
 			[]string{"foo", "--scope=foo", "--scope=blah"},
 			&Opts{
 				runOpts: runOpts{
-					concurrency: 10,
+					concurrency:     10,
+					runTimeoutGrace: time.Minute,
 				},
 				cacheOpts: cache.Opts{
 					Workers: 10,
@@ -67,7 +73,8 @@ func TestParseConfig(t *testing.T) {
 			[]string{"foo", "--concurrency=12"},
 			&Opts{
 				runOpts: runOpts{
-					concurrency: 12,
+					concurrency:     12,
+					runTimeoutGrace: time.Minute,
 				},
 				cacheOpts: cache.Opts{
 					Workers: 10,
@@ -82,7 +89,8 @@ func TestParseConfig(t *testing.T) {
 			[]string{"foo", "--concurrency=100%"},
 			&Opts{
 				runOpts: runOpts{
-					concurrency: cpus,
+					concurrency:     cpus,
+					runTimeoutGrace: time.Minute,
 				},
 				cacheOpts: cache.Opts{
 					Workers: 10,
@@ -97,9 +105,10 @@ func TestParseConfig(t *testing.T) {
 			[]string{"foo", "--graph=g.png"},
 			&Opts{
 				runOpts: runOpts{
-					concurrency: 10,
-					graphFile:   "g.png",
-					graphDot:    false,
+					concurrency:     10,
+					runTimeoutGrace: time.Minute,
+					graphFile:       "g.png",
+					graphDot:        false,
 				},
 				cacheOpts: cache.Opts{
 					Workers: 10,
@@ -114,9 +123,10 @@ func TestParseConfig(t *testing.T) {
 			[]string{"foo", "--graph"},
 			&Opts{
 				runOpts: runOpts{
-					concurrency: 10,
-					graphFile:   "",
-					graphDot:    true,
+					concurrency:     10,
+					runTimeoutGrace: time.Minute,
+					graphFile:       "",
+					graphDot:        true,
 				},
 				cacheOpts: cache.Opts{
 					Workers: 10,
@@ -132,6 +142,7 @@ func TestParseConfig(t *testing.T) {
 			&Opts{
 				runOpts: runOpts{
 					concurrency:     10,
+					runTimeoutGrace: time.Minute,
 					graphFile:       "g.png",
 					graphDot:        false,
 					passThroughArgs: []string{"--boop", "zoop"},
@@ -149,7 +160,8 @@ func TestParseConfig(t *testing.T) {
 			[]string{"foo", "--force"},
 			&Opts{
 				runOpts: runOpts{
-					concurrency: 10,
+					concurrency:     10,
+					runTimeoutGrace: time.Minute,
 				},
 				cacheOpts: cache.Opts{
 					Workers: 10,
@@ -166,7 +178,8 @@ func TestParseConfig(t *testing.T) {
 			[]string{"foo", "--remote-only"},
 			&Opts{
 				runOpts: runOpts{
-					concurrency: 10,
+					concurrency:     10,
+					runTimeoutGrace: time.Minute,
 				},
 				cacheOpts: cache.Opts{
 					Workers:        10,
@@ -182,7 +195,8 @@ func TestParseConfig(t *testing.T) {
 			[]string{"foo", "--no-cache"},
 			&Opts{
 				runOpts: runOpts{
-					concurrency: 10,
+					concurrency:     10,
+					runTimeoutGrace: time.Minute,
 				},
 				cacheOpts: cache.Opts{
 					Workers: 10,
@@ -194,12 +208,50 @@ func TestParseConfig(t *testing.T) {
 			},
 			[]string{"foo"},
 		},
+		{
+			"cache-write-only with a read-only remote cache",
+			[]string{"foo", "--cache-write-only", "--remote-read-only"},
+			&Opts{
+				runOpts: runOpts{
+					concurrency:     10,
+					runTimeoutGrace: time.Minute,
+				},
+				cacheOpts: cache.Opts{
+					Workers:        10,
+					RemoteReadOnly: true,
+				},
+				runcacheOpts: runcache.Opts{
+					SkipReads: true,
+				},
+				scopeOpts: scope.Opts{},
+			},
+			[]string{"foo"},
+		},
+		{
+			"validate-outputs",
+			[]string{"foo", "--validate-outputs"},
+			&Opts{
+				runOpts: runOpts{
+					concurrency:     10,
+					runTimeoutGrace: time.Minute,
+				},
+				cacheOpts: cache.Opts{
+					Workers: 10,
+				},
+				runcacheOpts: runcache.Opts{
+					ValidateOutputs: runcache.ValidateOutputsWarn,
+				},
+				scopeOpts: scope.Opts{},
+			},
+			[]string{"foo"},
+		},
 		{
 			"Empty passThroughArgs",
 			[]string{"foo", "--graph=g.png", "--"},
 			&Opts{
 				runOpts: runOpts{
 					concurrency:     10,
+					runTimeoutGrace: time.Minute,
 					graphFile:       "g.png",
 					graphDot:        false,
 					passThroughArgs: []string{},
@@ -217,7 +269,8 @@ func TestParseConfig(t *testing.T) {
 			[]string{"foo", "--filter=bar", "--filter=...[main]"},
 			&Opts{
 				runOpts: runOpts{
-					concurrency: 10,
+					concurrency:     10,
+					runTimeoutGrace: time.Minute,
 				},
 				cacheOpts: cache.Opts{
 					Workers: 10,
@@ -236,6 +289,24 @@ func TestParseConfig(t *testing.T) {
 				runOpts: runOpts{
 					continueOnError: true,
 					concurrency:     10,
+					runTimeoutGrace: time.Minute,
+				},
+				cacheOpts: cache.Opts{
+					Workers: 10,
+				},
+				runcacheOpts: runcache.Opts{},
+				scopeOpts:    scope.Opts{},
+			},
+			[]string{"foo"},
+		},
+		{
+			"continue until max failures",
+			[]string{"foo", "--max-failures=3"},
+			&Opts{
+				runOpts: runOpts{
+					maxFailures:     3,
+					concurrency:     10,
+					runTimeoutGrace: time.Minute,
 				},
 				cacheOpts: cache.Opts{
 					Workers: 10,
@@ -252,6 +323,7 @@ func TestParseConfig(t *testing.T) {
 				runOpts: runOpts{
 					continueOnError: true,
 					concurrency:     10,
+					runTimeoutGrace: time.Minute,
 				},
 				cacheOpts: cache.Opts{
 					OverrideDir: "bar",
@@ -269,6 +341,7 @@ func TestParseConfig(t *testing.T) {
 				runOpts: runOpts{
 					continueOnError: true,
 					concurrency:     10,
+					runTimeoutGrace: time.Minute,
 				},
 				cacheOpts: cache.Opts{
 					OverrideDir: defaultCwd.UntypedJoin("bar").ToString(),
@@ -362,3 +435,142 @@ func Test_taskSelfRef(t *testing.T) {
 		t.Fatalf("expected to failed to build task graph: %v", err)
 	}
 }
+
+func Test_persistentDependencies(t *testing.T) {
+	topoGraph := &dag.AcyclicGraph{}
+	topoGraph.Add("a")
+
+	pipeline := map[string]fs.TaskDefinition{
+		"dev": {
+			Persistent: true,
+		},
+		"build": {
+			ShouldCache:      true,
+			TaskDependencies: []string{"dev"},
+		},
+		"storybook": {
+			Persistent:       true,
+			TaskDependencies: []string{"dev"},
+		},
+		"lint": {
+			TaskDependencies: []string{"dev"},
+		},
+		"test": {
+			ShouldCache:      true,
+			TaskDependencies: []string{"dev"},
+			OutputOf:         map[string][]string{"dev": {"dist/**"}},
+		},
+	}
+	filteredPkgs := make(util.Set)
+	filteredPkgs.Add("a")
+	rs := &runSpec{
+		FilteredPkgs: filteredPkgs,
+		Targets:      []string{"lint", "test"},
+		Opts:         &Opts{},
+	}
+	_, err := buildTaskGraphEngine(topoGraph, pipeline, rs)
+	assert.NoError(t, err, "non-cached tasks and outputOf can depend on persistent tasks")
+
+	rs.Targets = []string{"build", "lint", "storybook", "test"}
+	_, err = buildTaskGraphEngine(topoGraph, pipeline, rs)
+	assert.EqualError(t, err, "persistent tasks never complete, so these tasks would never start:\n"+
+		"\ta#build depends on a#dev, and is cached\n"+
+		"\ta#storybook depends on a#dev, and is persistent itself")
+}
+
+func Test_applyRunDefaults(t *testing.T) {
+	flags := pflag.NewFlagSet("test-flags", pflag.ContinueOnError)
+	opts := optsFromFlags(flags)
+	if err := flags.Parse([]string{"build", "--concurrency=2"}); err != nil {
+		t.Fatalf("invalid parse: %#v", err)
+	}
+	err := applyRunDefaults(flags, fs.RunDefaults{
+		CacheDir:     "/tmp/turbo-cache",
+		CacheMaxSize: "5GB",
+		Concurrency:  "8",
+		OutputLogs:   "new-only",
+	})
+	assert.NoError(t, err)
+	// Flags that were passed win over turbo.json
+	assert.Equal(t, 2, opts.runOpts.concurrency)
+	assert.Equal(t, "/tmp/turbo-cache", opts.cacheOpts.OverrideDir)
+	assert.Equal(t, int64(5<<30), opts.cacheOpts.MaxSize)
+	assert.Equal(t, util.NewTaskOutput, *opts.runcacheOpts.TaskOutputModeOverride)
+
+	flags = pflag.NewFlagSet("test-flags", pflag.ContinueOnError)
+	_ = optsFromFlags(flags)
+	err = applyRunDefaults(flags, fs.RunDefaults{OutputLogs: "everything"})
+	assert.ErrorContains(t, err, "invalid \"outputLogs\" in \"defaults\" of turbo.json")
+
+	flags = pflag.NewFlagSet("test-flags", pflag.ContinueOnError)
+	_ = optsFromFlags(flags)
+	err = applyRunDefaults(flags, fs.RunDefaults{CacheMaxSize: "lots"})
+	assert.ErrorContains(t, err, "invalid \"cacheMaxSize\" in \"defaults\" of turbo.json")
+
+	flags = pflag.NewFlagSet("test-flags", pflag.ContinueOnError)
+	opts = optsFromFlags(flags)
+	err = applyRunDefaults(flags, fs.RunDefaults{Warnings: []string{"strict", "silence:execution/missing-outputs"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "strict,silence:execution/missing-outputs", opts.runOpts.warnings.String())
+
+	flags = pflag.NewFlagSet("test-flags", pflag.ContinueOnError)
+	opts = optsFromFlags(flags)
+	if err := flags.Parse([]string{"build", "--environment=staging"}); err != nil {
+		t.Fatalf("invalid parse: %#v", err)
+	}
+	err = applyRunDefaults(flags, fs.RunDefaults{EnvMode: "strict", Environment: "development"})
+	assert.NoError(t, err)
+	assert.Equal(t, env.StrictMode, opts.runOpts.envMode)
+	assert.Equal(t, "staging", opts.runOpts.environment)
+
+	flags = pflag.NewFlagSet("test-flags", pflag.ContinueOnError)
+	_ = optsFromFlags(flags)
+	err = applyRunDefaults(flags, fs.RunDefaults{EnvMode: "lax"})
+	assert.ErrorContains(t, err, "invalid \"envMode\" in \"defaults\" of turbo.json")
+}
+
+func Test_packageTaskTypeScript(t *testing.T) {
+	repoRoot := turbopath.AbsoluteSystemPathFromUpstream(t.TempDir())
+	config := repoRoot.UntypedJoin("packages", "ui", "tsconfig.json")
+	assert.NoError(t, config.EnsureDir())
+	assert.NoError(t, config.WriteFile([]byte(`{"compilerOptions": {"outDir": "dist"}}`), 0644))
+
+	g := &completeGraph{
+		PackageInfos: map[interface{}]*fs.PackageJSON{
+			"ui":   {Name: "ui", Dir: turbopath.AnchoredUnixPath("packages/ui").ToSystemPath()},
+			"docs": {Name: "docs", Dir: turbopath.AnchoredUnixPath("packages/docs").ToSystemPath()},
+			"web": {
+				Name:    "web",
+				Dir:     turbopath.AnchoredUnixPath("packages/web").ToSystemPath(),
+				Scripts: map[string]string{"typecheck": "tsc --noEmit"},
+			},
+		},
+		Pipeline: fs.Pipeline{
+			"typecheck": {TypeScript: true, Outputs: fs.TaskOutputs{Inclusions: []string{"reports/**"}}},
+		},
+		TypeScriptProjects: typescript.NewProjects(repoRoot),
+	}
+
+	packageTask, err := g.packageTask("ui#typecheck")
+	assert.NoError(t, err)
+	command, ok := packageTask.Command()
+	assert.True(t, ok)
+	assert.Equal(t, "tsc --build", command)
+	assert.True(t, packageTask.UsesBuiltinCommand())
+	assert.Equal(t, []string{"dist/**", "dist/tsconfig.tsbuildinfo", "reports/**"}, packageTask.TaskDefinition.Outputs.Inclusions)
+	// The outputs of other workspaces are unchanged
+	assert.Equal(t, []string{"reports/**"}, g.Pipeline["typecheck"].Outputs.Inclusions)
+
+	// Without a tsconfig.json, there is nothing to build
+	packageTask, err = g.packageTask("docs#typecheck")
+	assert.NoError(t, err)
+	_, ok = packageTask.Command()
+	assert.False(t, ok)
+
+	// A script takes precedence over tsc --build
+	packageTask, err = g.packageTask("web#typecheck")
+	assert.NoError(t, err)
+	command, _ = packageTask.Command()
+	assert.Equal(t, "tsc --noEmit", command)
+	assert.False(t, packageTask.UsesBuiltinCommand())
+}
@@ -0,0 +1,92 @@
+package run
+
+import (
+	gocontext "context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// _runTimeoutExitCode is the exit code of a run that took longer than --run-timeout,
+// the same as the one of the timeout command
+const _runTimeoutExitCode = 124
+
+// runDeadline enforces --run-timeout: once the timeout has passed since the run
+// started, its context is done, so that no more tasks start. The tasks that are
+// still running get a grace period to finish, after which they are stopped.
+type runDeadline struct {
+	ctx    gocontext.Context
+	cancel gocontext.CancelFunc
+
+	mu      sync.Mutex
+	expired bool
+	// stopped is whether running tasks were stopped at the end of the grace period
+	stopped bool
+	grace   *time.Timer
+	done    chan struct{}
+}
+
+// newRunDeadline starts the clock of a run that started at startAt. stopRunning is
+// called to stop the tasks that are still running once the grace period is over.
+func newRunDeadline(ctx gocontext.Context, startAt time.Time, timeout time.Duration, grace time.Duration, stopRunning func()) *runDeadline {
+	deadlineCtx, cancel := gocontext.WithDeadline(ctx, startAt.Add(timeout))
+	rd := &runDeadline{ctx: deadlineCtx, cancel: cancel, done: make(chan struct{})}
+	go func() {
+		select {
+		case <-deadlineCtx.Done():
+		case <-rd.done:
+			return
+		}
+		if deadlineCtx.Err() != gocontext.DeadlineExceeded {
+			return
+		}
+		rd.mu.Lock()
+		defer rd.mu.Unlock()
+		select {
+		case <-rd.done:
+			// The run finished just as the deadline passed
+			return
+		default:
+		}
+		rd.expired = true
+		rd.grace = time.AfterFunc(grace, func() {
+			rd.mu.Lock()
+			select {
+			case <-rd.done:
+				rd.mu.Unlock()
+				return
+			default:
+			}
+			rd.stopped = true
+			rd.mu.Unlock()
+			stopRunning()
+		})
+	}()
+	return rd
+}
+
+// finish is called once the tasks of the run are over, and reports whether the
+// timeout had passed, and whether running tasks were stopped because of it
+func (rd *runDeadline) finish() (expired bool, stopped bool) {
+	rd.mu.Lock()
+	defer rd.mu.Unlock()
+	select {
+	case <-rd.done:
+	default:
+		close(rd.done)
+		rd.cancel()
+		if rd.grace != nil {
+			rd.grace.Stop()
+		}
+	}
+	return rd.expired, rd.stopped
+}
+
+// timeoutMessage describes how a run that took longer than --run-timeout was cut short
+func timeoutMessage(timeout time.Duration, grace time.Duration, notStarted int, stopped bool) string {
+	message := fmt.Sprintf("• Stopped after --run-timeout=%v, %v tasks didn't start", timeout, notStarted)
+	if stopped {
+		message += fmt.Sprintf(", and tasks still running after the %v grace period were stopped", grace)
+	}
+	return message
+}
@@ -0,0 +1,66 @@
+package run
+
+import (
+	gocontext "context"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestRunDeadline(t *testing.T) {
+	stoppedCh := make(chan struct{})
+	deadline := newRunDeadline(gocontext.Background(), time.Now(), 10*time.Millisecond, 10*time.Millisecond, func() { close(stoppedCh) })
+	<-deadline.ctx.Done()
+	assert.Equal(t, deadline.ctx.Err(), gocontext.DeadlineExceeded)
+	select {
+	case <-stoppedCh:
+	case <-time.After(5 * time.Second):
+		t.Fatal("running tasks were not stopped after the grace period")
+	}
+	expired, stopped := deadline.finish()
+	assert.Assert(t, expired)
+	assert.Assert(t, stopped)
+}
+
+func TestRunDeadlineFinishesInTime(t *testing.T) {
+	deadline := newRunDeadline(gocontext.Background(), time.Now(), time.Hour, time.Millisecond, func() {
+		t.Error("running tasks were stopped")
+	})
+	expired, stopped := deadline.finish()
+	assert.Assert(t, !expired)
+	assert.Assert(t, !stopped)
+	// Finishing releases the context
+	assert.Equal(t, deadline.ctx.Err(), gocontext.Canceled)
+}
+
+func TestRunDeadlineGracePeriod(t *testing.T) {
+	deadline := newRunDeadline(gocontext.Background(), time.Now().Add(-time.Hour), time.Minute, time.Hour, func() {
+		t.Error("running tasks were stopped during the grace period")
+	})
+	<-deadline.ctx.Done()
+	// The tasks that were running finished within the grace period
+	assert.Assert(t, waitFor(func() bool {
+		deadline.mu.Lock()
+		defer deadline.mu.Unlock()
+		return deadline.expired
+	}))
+	expired, stopped := deadline.finish()
+	assert.Assert(t, expired)
+	assert.Assert(t, !stopped)
+}
+
+func TestTimeoutMessage(t *testing.T) {
+	assert.Equal(t, timeoutMessage(30*time.Minute, time.Minute, 3, false), "• Stopped after --run-timeout=30m0s, 3 tasks didn't start")
+	assert.Equal(t, timeoutMessage(30*time.Minute, time.Minute, 0, true), "• Stopped after --run-timeout=30m0s, 0 tasks didn't start, and tasks still running after the 1m0s grace period were stopped")
+}
+
+// waitFor polls condition until it is true, for up to 5 seconds
+func waitFor(condition func() bool) bool {
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(time.Millisecond) {
+		if condition() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,206 @@
+package run
+
+import (
+	gocontext "context"
+	"encoding/json"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/vercel/turbo/cli/internal/core"
+	"github.com/vercel/turbo/cli/internal/nodes"
+	"github.com/vercel/turbo/cli/internal/runcache"
+	"github.com/vercel/turbo/cli/internal/taskhash"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// recentTargetsFile is the repo-relative location of the targets of recent runs,
+// which --speculate consults to guess which tasks will be run next
+var recentTargetsFile = []string{".turbo", "recent-targets.json"}
+
+// _maxRecentRuns is the number of runs whose targets are remembered
+const _maxRecentRuns = 20
+
+// _idleSlotPollInterval is how often a speculative task checks for an idle slot
+const _idleSlotPollInterval = 50 * time.Millisecond
+
+// recentTargets is the targets of the most recent runs in a repository, oldest first
+type recentTargets struct {
+	path turbopath.AbsoluteSystemPath
+	Runs [][]string `json:"runs"`
+}
+
+// readRecentTargets loads the targets of recent runs in the given repository
+func readRecentTargets(repoRoot turbopath.AbsoluteSystemPath) (*recentTargets, error) {
+	rt := &recentTargets{
+		path: runStatePath(repoRoot, recentTargetsFile),
+	}
+	bytes, err := rt.path.ReadFile()
+	if os.IsNotExist(err) {
+		return rt, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(bytes, rt); err != nil {
+		return nil, err
+	}
+	return rt, nil
+}
+
+// targetsKey identifies a set of targets regardless of the order they were given in
+func targetsKey(targets []string) string {
+	sorted := make([]string, len(targets))
+	copy(sorted, targets)
+	sort.Strings(sorted)
+	return strings.Join(sorted, " ")
+}
+
+// record adds the targets of a run, forgetting the oldest run if necessary
+func (rt *recentTargets) record(targets []string) {
+	rt.Runs = append(rt.Runs, strings.Split(targetsKey(targets), " "))
+	if len(rt.Runs) > _maxRecentRuns {
+		rt.Runs = rt.Runs[len(rt.Runs)-_maxRecentRuns:]
+	}
+}
+
+// predictNext returns the targets that most often followed the given targets in
+// recent runs, preferring the most recent in case of a tie. Targets that are part
+// of the given targets are left out.
+func (rt *recentTargets) predictNext(targets []string) []string {
+	key := targetsKey(targets)
+	counts := make(map[string]int)
+	best := ""
+	for i := 0; i+1 < len(rt.Runs); i++ {
+		next := targetsKey(rt.Runs[i+1])
+		if targetsKey(rt.Runs[i]) != key || next == key {
+			continue
+		}
+		counts[next]++
+		if counts[next] >= counts[best] {
+			best = next
+		}
+	}
+	if best == "" {
+		return nil
+	}
+	current := util.SetFromStrings(targets)
+	var predicted []string
+	for _, target := range strings.Split(best, " ") {
+		if !current.Includes(target) {
+			predicted = append(predicted, target)
+		}
+	}
+	return predicted
+}
+
+// write saves the targets of recent runs to the repository
+func (rt *recentTargets) write() error {
+	bytes, err := json.MarshalIndent(rt, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := rt.path.EnsureDir(); err != nil {
+		return err
+	}
+	return rt.path.WriteFile(bytes, 0644)
+}
+
+// speculation restores the outputs of tasks that are likely to be run next, using
+// the concurrency slots that the tasks of the current run leave idle. Speculative
+// work never delays the current run, and is cancelled when the current run finishes.
+type speculation struct {
+	targets  []string
+	engine   *core.Engine
+	tracker  *taskhash.Tracker
+	runCache *runcache.RunCache
+	// running is the task graph of the current run, whose tasks are not speculated on
+	running  *core.Engine
+	sema     util.Semaphore
+	logger   hclog.Logger
+	repoRoot turbopath.AbsoluteSystemPath
+
+	cancel   gocontext.CancelFunc
+	done     chan struct{}
+	mu       sync.Mutex
+	restored []string
+}
+
+// start begins speculating in the background
+func (s *speculation) start(ctx gocontext.Context, g *completeGraph, concurrency int) {
+	ctx, s.cancel = gocontext.WithCancel(ctx)
+	s.done = make(chan struct{})
+	go func() {
+		defer close(s.done)
+		if err := s.tracker.CalculateFileHashes(s.engine.TaskGraph.Vertices(), 1, s.repoRoot); err != nil {
+			s.logger.Debug("failed to hash package files", "error", err)
+			return
+		}
+		s.engine.Execute(g.getPackageTaskVisitor(ctx, s.visit), core.ExecOpts{
+			Context:     ctx,
+			Concurrency: concurrency,
+		})
+	}()
+}
+
+// visit restores the outputs of a single speculative task, once a slot is idle
+func (s *speculation) visit(ctx gocontext.Context, packageTask *nodes.PackageTask) error {
+	deps := s.engine.TaskGraph.DownEdges(packageTask.TaskID)
+	hash, err := s.tracker.CalculateTaskHash(packageTask, deps, s.logger, nil)
+	if err != nil {
+		return err
+	}
+	if s.running.TaskGraph.HasVertex(packageTask.TaskID) {
+		// This task is part of the current run, we only needed its hash
+		return nil
+	}
+	if !s.acquireIdleSlot(ctx) {
+		return ctx.Err()
+	}
+	defer s.sema.Release()
+	itemStatus, err := s.runCache.TaskCache(packageTask, hash).Prefetch(ctx)
+	if err != nil {
+		s.logger.Debug("failed to restore outputs", "task", packageTask.TaskID, "error", err)
+		return nil
+	}
+	if itemStatus.Hit() {
+		s.logger.Debug("restored outputs", "task", packageTask.TaskID, "hash", hash)
+		s.mu.Lock()
+		s.restored = append(s.restored, packageTask.TaskID)
+		s.mu.Unlock()
+	}
+	return nil
+}
+
+// acquireIdleSlot waits for a concurrency slot that no task of the current run is
+// waiting for. Returns false if speculation was cancelled first.
+func (s *speculation) acquireIdleSlot(ctx gocontext.Context) bool {
+	ticker := time.NewTicker(_idleSlotPollInterval)
+	defer ticker.Stop()
+	for {
+		// Tasks of the current run block on the semaphore, so they are handed a
+		// released slot before it can be taken here
+		if ctx.Err() == nil && s.sema.TryAcquire() {
+			return true
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+}
+
+// stop cancels any speculative work that has not started yet, waits for the work
+// in progress, and returns the tasks whose outputs were restored
+func (s *speculation) stop() []string {
+	s.cancel()
+	<-s.done
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sort.Strings(s.restored)
+	return s.restored
+}
@@ -0,0 +1,47 @@
+package run
+
+import (
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"gotest.tools/v3/assert"
+)
+
+func TestRecentTargets(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+
+	first, err := readRecentTargets(repoRoot)
+	assert.NilError(t, err, "readRecentTargets with no previous runs")
+	assert.Assert(t, first.predictNext([]string{"build"}) == nil)
+	for _, targets := range [][]string{
+		{"build"}, {"test", "build"},
+		{"build"}, {"lint"},
+		{"build"}, {"build", "test"},
+		{"build"},
+	} {
+		first.record(targets)
+	}
+	assert.NilError(t, first.write(), "write")
+
+	recent, err := readRecentTargets(repoRoot)
+	assert.NilError(t, err, "readRecentTargets")
+	assert.DeepEqual(t, recent.predictNext([]string{"build"}), []string{"test"})
+	// build always follows build and test, but it has just run
+	assert.Assert(t, recent.predictNext([]string{"test", "build"}) == nil)
+	assert.DeepEqual(t, recent.predictNext([]string{"lint"}), []string{"build"})
+	assert.Assert(t, recent.predictNext([]string{"typecheck"}) == nil)
+
+	for i := 0; i < _maxRecentRuns; i++ {
+		recent.record([]string{"dev"})
+	}
+	assert.Equal(t, len(recent.Runs), _maxRecentRuns)
+	assert.Assert(t, recent.predictNext([]string{"build"}) == nil)
+}
+
+func TestPredictNextPrefersRecent(t *testing.T) {
+	recent := &recentTargets{Runs: [][]string{
+		{"build"}, {"lint"},
+		{"build"}, {"test"},
+	}}
+	assert.DeepEqual(t, recent.predictNext([]string{"build"}), []string{"test"})
+}
@@ -0,0 +1,208 @@
+package run
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/vercel/turbo/cli/internal/cache"
+	"github.com/vercel/turbo/cli/internal/process"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// runSummaryDir is the repo-relative directory that --summarize writes the summary
+// of each run into, as <run ID>.json
+var runSummaryDir = []string{".turbo", "runs"}
+
+// _runSummaryVersion is the version of the format of run summaries. Fields may be
+// added without changing it, but not removed or changed in meaning.
+const _runSummaryVersion = "1"
+
+// Cache values of a task in a run summary
+const (
+	summaryCacheLocal  = "local"
+	summaryCacheRemote = "remote"
+	summaryCacheMiss   = "miss"
+)
+
+// Status values of a task in a run summary
+const (
+	summaryStatusSucceeded = "succeeded"
+	summaryStatusFailed    = "failed"
+	summaryStatusSkipped   = "skipped"
+)
+
+// runSummary is the summary of a run written by --summarize
+type runSummary struct {
+	Version      string           `json:"version"`
+	ID           string           `json:"id"`
+	TurboVersion string           `json:"turboVersion"`
+	Targets      []string         `json:"targets"`
+	StartedAt    time.Time        `json:"startedAt"`
+	EndedAt      time.Time        `json:"endedAt"`
+	ExitCode     int              `json:"exitCode"`
+	GlobalHash   globalHashInputs `json:"globalHash"`
+	Cache        cacheStats       `json:"cache"`
+	Tasks        []taskSummary    `json:"tasks"`
+}
+
+// cacheStats is what the cache did for a run
+type cacheStats struct {
+	Cached   int `json:"cached"`
+	Executed int `json:"executed"`
+	// TimeSavedMs is an estimate of the time that cache hits saved: how long the
+	// cached tasks took when their outputs were cached, less how long restoring
+	// them took, which is RestoreTimeMs
+	TimeSavedMs   int64 `json:"timeSavedMs"`
+	RestoreTimeMs int64 `json:"restoreTimeMs"`
+	// BytesUploaded and BytesDownloaded are the bytes moved to and from the remote cache
+	BytesUploaded   int64 `json:"bytesUploaded"`
+	BytesDownloaded int64 `json:"bytesDownloaded"`
+}
+
+// newCacheStats totals what the cache did for the tasks of a run from their results
+func newCacheStats(results []BuildTargetState, transfers *cache.TransferStats) cacheStats {
+	stats := cacheStats{
+		BytesUploaded:   transfers.Uploaded(),
+		BytesDownloaded: transfers.Downloaded(),
+	}
+	for _, result := range results {
+		switch result.Status {
+		case TargetCached:
+			stats.Cached++
+			stats.TimeSavedMs += timeSaved(result).Milliseconds()
+			stats.RestoreTimeMs += result.Duration.Milliseconds()
+		case TargetBuilt, TargetBuildFailed:
+			stats.Executed++
+		}
+	}
+	return stats
+}
+
+// timeSaved estimates the time that restoring a cached target saved
+func timeSaved(result BuildTargetState) time.Duration {
+	if saved := result.TimeSaved - result.Duration; saved > 0 {
+		return saved
+	}
+	return 0
+}
+
+// text describes the stats for the summary printed at the end of a run, as a
+// summary and its details, which include the bytes moved to and from the remote
+// cache if it's enabled
+func (cs cacheStats) text(remote bool) (string, string) {
+	summary := fmt.Sprintf("%v cached, %v executed, saved %v", cs.Cached, cs.Executed, time.Duration(cs.TimeSavedMs)*time.Millisecond)
+	details := fmt.Sprintf("restored in %v", time.Duration(cs.RestoreTimeMs)*time.Millisecond)
+	if remote {
+		details += fmt.Sprintf(", %v downloaded, %v uploaded", util.FormatByteSize(cs.BytesDownloaded), util.FormatByteSize(cs.BytesUploaded))
+	}
+	return summary, details
+}
+
+// taskSummary is the outcome of a task in a run summary
+type taskSummary struct {
+	TaskID  string `json:"taskId"`
+	Package string `json:"package"`
+	Task    string `json:"task"`
+	Hash    string `json:"hash"`
+	// Status is one of "succeeded", "failed", or "skipped", for tasks that didn't
+	// run because a dependency failed
+	Status string `json:"status"`
+	// Cache is where the task was restored from, "local" or "remote", or "miss"
+	// if it was executed
+	Cache      string     `json:"cache"`
+	StartedAt  *time.Time `json:"startedAt"`
+	DurationMs int64      `json:"durationMs"`
+	// TimeSavedMs is, for a cached task, an estimate of the time that restoring it saved
+	TimeSavedMs int64 `json:"timeSavedMs,omitempty"`
+	Attempts    int   `json:"attempts"`
+	// ExitCode is the exit code of the task's command, or null if the command
+	// didn't run or didn't exit on its own
+	ExitCode *int `json:"exitCode"`
+	// RootCauses are, for a skipped task, the failed tasks that caused it to be
+	// skipped. BlockedBy are the dependencies that blocked it directly: failed
+	// tasks, or tasks that were skipped themselves.
+	RootCauses []string `json:"rootCauses,omitempty"`
+	BlockedBy  []string `json:"blockedBy,omitempty"`
+}
+
+// newRunSummary summarizes the tasks of a run from their results and hashes
+func newRunSummary(id string, turboVersion string, targets []string, startedAt time.Time, exitCode int, globalHash globalHashInputs, results []BuildTargetState, hashes map[string]string, transfers *cache.TransferStats) *runSummary {
+	summary := &runSummary{
+		Version:      _runSummaryVersion,
+		ID:           id,
+		TurboVersion: turboVersion,
+		Targets:      append([]string{}, targets...),
+		StartedAt:    startedAt,
+		EndedAt:      time.Now(),
+		ExitCode:     exitCode,
+		GlobalHash:   globalHash,
+		Cache:        newCacheStats(results, transfers),
+		Tasks:        []taskSummary{},
+	}
+	sort.Strings(summary.Targets)
+	for _, result := range results {
+		// Tasks with no command to run never finish building, and aren't part of the summary
+		if result.Status == TargetBuilding {
+			continue
+		}
+		pkg, task := util.GetPackageTaskFromId(result.Label)
+		ts := taskSummary{
+			TaskID:     result.Label,
+			Package:    pkg,
+			Task:       task,
+			Hash:       hashes[result.Label],
+			Cache:      summaryCacheMiss,
+			DurationMs: result.Duration.Milliseconds(),
+			Attempts:   result.Attempts,
+		}
+		if !result.StartAt.IsZero() {
+			startAt := result.StartAt
+			ts.StartedAt = &startAt
+		}
+		switch result.Status {
+		case TargetCached:
+			ts.Status = summaryStatusSucceeded
+			ts.Cache = summaryCacheLocal
+			ts.TimeSavedMs = timeSaved(result).Milliseconds()
+			if result.CacheSource == cache.CacheSourceRemote {
+				ts.Cache = summaryCacheRemote
+			}
+		case TargetBuilt:
+			ts.Status = summaryStatusSucceeded
+			zero := 0
+			ts.ExitCode = &zero
+		case TargetSkipped:
+			ts.Status = summaryStatusSkipped
+			ts.RootCauses = result.RootCauses
+			ts.BlockedBy = result.BlockedBy
+		default:
+			ts.Status = summaryStatusFailed
+			childExit := &process.ChildExit{}
+			if errors.As(result.Err, &childExit) {
+				code := childExit.ExitCode
+				ts.ExitCode = &code
+			}
+		}
+		summary.Tasks = append(summary.Tasks, ts)
+	}
+	sort.Slice(summary.Tasks, func(i, j int) bool {
+		return summary.Tasks[i].TaskID < summary.Tasks[j].TaskID
+	})
+	return summary
+}
+
+// write saves the summary as JSON to path
+func (rs *runSummary) write(path turbopath.AbsoluteSystemPath) error {
+	bytes, err := json.MarshalIndent(rs, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := path.EnsureDir(); err != nil {
+		return err
+	}
+	return path.WriteFile(bytes, 0644)
+}
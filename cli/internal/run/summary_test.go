@@ -0,0 +1,70 @@
+package run
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/vercel/turbo/cli/internal/cache"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/process"
+	"gotest.tools/v3/assert"
+)
+
+func TestRunSummary(t *testing.T) {
+	startAt := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	results := []BuildTargetState{
+		{Label: "web#build", Status: TargetBuilt, StartAt: startAt, Duration: 1500 * time.Millisecond, Attempts: 1},
+		{Label: "ui#build", Status: TargetCached, StartAt: startAt, Duration: 200 * time.Millisecond, CacheSource: cache.CacheSourceRemote, TimeSaved: 2 * time.Second, Attempts: 1},
+		{Label: "web#test", Status: TargetBuildFailed, StartAt: startAt, Duration: time.Second, Attempts: 2, Err: &process.ChildExit{ExitCode: 3}},
+		{Label: "web#lint", Status: TargetBuildFailed, StartAt: startAt, Attempts: 1, Err: errors.New("matched failOnOutput")},
+		{Label: "web#deploy", Status: TargetSkipped, BlockedBy: []string{"web#test"}, RootCauses: []string{"web#test"}},
+		// a task without a command
+		{Label: "docs#build", Status: TargetBuilding, StartAt: startAt, Attempts: 1},
+	}
+	hashes := map[string]string{"web#build": "abc", "ui#build": "def", "web#test": "ghi", "web#lint": "jkl"}
+	globalHash := globalHashInputs{Hash: "global", EnvVars: []string{"VERCEL_ANALYTICS_ID"}, EnvMode: "loose"}
+	summary := newRunSummary("run-1", "1.0.0", []string{"test", "build"}, startAt, 3, globalHash, results, hashes, nil)
+
+	assert.Equal(t, summary.Version, _runSummaryVersion)
+	assert.DeepEqual(t, summary.Targets, []string{"build", "test"})
+	assert.Equal(t, summary.GlobalHash.Hash, "global")
+	assert.DeepEqual(t, summary.Cache, cacheStats{Cached: 1, Executed: 3, TimeSavedMs: 1800, RestoreTimeMs: 200})
+	zero, three := 0, 3
+	assert.DeepEqual(t, summary.Tasks, []taskSummary{
+		{TaskID: "ui#build", Package: "ui", Task: "build", Hash: "def", Status: "succeeded", Cache: "remote", StartedAt: &startAt, DurationMs: 200, TimeSavedMs: 1800, Attempts: 1},
+		{TaskID: "web#build", Package: "web", Task: "build", Hash: "abc", Status: "succeeded", Cache: "miss", StartedAt: &startAt, DurationMs: 1500, Attempts: 1, ExitCode: &zero},
+		{TaskID: "web#deploy", Package: "web", Task: "deploy", Status: "skipped", Cache: "miss", RootCauses: []string{"web#test"}, BlockedBy: []string{"web#test"}},
+		{TaskID: "web#lint", Package: "web", Task: "lint", Hash: "jkl", Status: "failed", Cache: "miss", StartedAt: &startAt, Attempts: 1},
+		{TaskID: "web#test", Package: "web", Task: "test", Hash: "ghi", Status: "failed", Cache: "miss", StartedAt: &startAt, DurationMs: 1000, Attempts: 2, ExitCode: &three},
+	})
+
+	path := fs.AbsoluteSystemPathFromUpstream(t.TempDir()).UntypedJoin(".turbo", "runs", "run-1.json")
+	assert.NilError(t, summary.write(path), "write")
+	contents, err := path.ReadFile()
+	assert.NilError(t, err, "ReadFile")
+	var raw map[string]interface{}
+	assert.NilError(t, json.Unmarshal(contents, &raw), "Unmarshal")
+	assert.Equal(t, raw["id"], "run-1")
+	assert.Equal(t, raw["exitCode"], float64(3))
+	tasks := raw["tasks"].([]interface{})
+	assert.Equal(t, len(tasks), 5)
+	// exitCode is null, rather than left out, for tasks whose command didn't exit
+	deploy := tasks[2].(map[string]interface{})
+	exitCode, ok := deploy["exitCode"]
+	assert.Assert(t, ok, "expected exitCode to be present")
+	assert.Assert(t, exitCode == nil, "expected exitCode to be null")
+}
+
+func TestCacheStatsText(t *testing.T) {
+	stats := cacheStats{Cached: 12, Executed: 3, TimeSavedMs: 252000, RestoreTimeMs: 1200, BytesDownloaded: 2048}
+	summary, details := stats.text(false)
+	assert.Equal(t, summary, "12 cached, 3 executed, saved 4m12s")
+	assert.Equal(t, details, "restored in 1.2s")
+	_, details = stats.text(true)
+	assert.Equal(t, details, "restored in 1.2s, 2.0KB downloaded, 0B uploaded")
+
+	// Restoring can take longer than the task did, which saves nothing
+	assert.Equal(t, timeSaved(BuildTargetState{Duration: 2 * time.Second, TimeSaved: time.Second}), time.Duration(0))
+}
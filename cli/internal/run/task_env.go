@@ -0,0 +1,100 @@
+package run
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pyr-sh/dag"
+	"github.com/vercel/turbo/cli/internal/core"
+	"github.com/vercel/turbo/cli/internal/env"
+	"github.com/vercel/turbo/cli/internal/inference"
+	"github.com/vercel/turbo/cli/internal/nodes"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+// taskEnvironment builds the environment of each task's process from turbo's own,
+// filtered by the env mode, and the dotenv files of the selected environment.
+type taskEnvironment struct {
+	mode      env.Mode
+	globalEnv []string
+	// dotEnv holds the variables from the dotenv files of the repository root, overridden
+	// by those of each workspace, by workspace directory
+	dotEnv map[string]map[string]string
+	// trace is the trace that the run is part of, if any
+	trace *runTrace
+}
+
+// newTaskEnvironment loads the dotenv files of every workspace with a task in taskGraph,
+// so that invalid files are reported before anything runs
+func newTaskEnvironment(opts *runOpts, globalEnv []string, repoRoot turbopath.AbsoluteSystemPath, g *completeGraph, taskGraph *dag.AcyclicGraph) (*taskEnvironment, error) {
+	te := &taskEnvironment{
+		mode:      opts.envMode,
+		globalEnv: globalEnv,
+		dotEnv:    make(map[string]map[string]string),
+	}
+	if opts.environment == "" {
+		return te, nil
+	}
+	rootVars, err := env.LoadDotEnv(opts.environment, repoRoot)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range taskGraph.Vertices() {
+		taskID := dag.VertexName(v)
+		if strings.Contains(taskID, core.ROOT_NODE_NAME) {
+			continue
+		}
+		packageTask, err := g.packageTask(taskID)
+		if err != nil {
+			return nil, err
+		} else if packageTask == nil {
+			continue
+		}
+		dir := packageTask.Pkg.Dir.ToStringDuringMigration()
+		if _, ok := te.dotEnv[dir]; ok {
+			continue
+		}
+		vars := make(map[string]string, len(rootVars))
+		for name, value := range rootVars {
+			vars[name] = value
+		}
+		workspaceVars, err := env.LoadDotEnv(opts.environment, repoRoot.UntypedJoin(dir))
+		if err != nil {
+			return nil, err
+		}
+		for name, value := range workspaceVars {
+			vars[name] = value
+		}
+		te.dotEnv[dir] = vars
+	}
+	return te, nil
+}
+
+// forTask returns the environment for a task's process. In strict mode, only the
+// variables that the task's hash accounts for are passed through.
+func (te *taskEnvironment) forTask(packageTask *nodes.PackageTask, hash string) []string {
+	var names []string
+	names = append(names, _defaultEnvVars...)
+	names = append(names, te.globalEnv...)
+	names = append(names, packageTask.TaskDefinition.EnvVarDependencies...)
+	var prefixes []string
+	if framework := inference.InferFramework(packageTask.Pkg); framework != nil && framework.EnvPrefix != "" {
+		prefixes = append(prefixes, framework.EnvPrefix)
+	}
+	dotEnv := te.dotEnv[packageTask.Pkg.Dir.ToStringDuringMigration()]
+	taskEnv := env.TaskEnv(te.mode, os.Environ(), names, prefixes, dotEnv)
+	if te.trace != nil {
+		// The task's own span replaces the trace context that turbo was started with
+		inherited := taskEnv
+		taskEnv = make([]string, 0, len(inherited)+2)
+		for _, pair := range inherited {
+			name := strings.SplitN(pair, "=", 2)[0]
+			if name != traceparentEnvVar && name != tracestateEnvVar {
+				taskEnv = append(taskEnv, pair)
+			}
+		}
+		taskEnv = append(taskEnv, te.trace.envForTask(packageTask.TaskID)...)
+	}
+	return append(taskEnv, fmt.Sprintf("TURBO_HASH=%v", hash))
+}
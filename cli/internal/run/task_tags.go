@@ -0,0 +1,139 @@
+package run
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pyr-sh/dag"
+	"github.com/spf13/pflag"
+	"github.com/vercel/turbo/cli/internal/core"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// taskFilter selects tasks to run by their tags, as given by --filter-task
+type taskFilter struct {
+	raw []string
+	// include lists tags of which the requested tasks must carry at least one
+	include []string
+	// exclude lists tags of tasks that must not run
+	exclude []string
+}
+
+var _ pflag.Value = &taskFilter{}
+
+// String implements pflag.Value.String for taskFilter
+func (tf *taskFilter) String() string {
+	return strings.Join(tf.raw, ",")
+}
+
+// Set implements pflag.Value.Set for taskFilter. Values are of the form
+// "tag:<tag>" to select tasks, or "!tag:<tag>" to exclude them.
+func (tf *taskFilter) Set(value string) error {
+	exclude := strings.HasPrefix(value, "!")
+	tag := strings.TrimPrefix(value, "!")
+	if !strings.HasPrefix(tag, fs.TagPrefix) || tag == fs.TagPrefix {
+		return fmt.Errorf("invalid task filter %q, expected %q or %q", value, "tag:<tag>", "!tag:<tag>")
+	}
+	tag = strings.TrimPrefix(tag, fs.TagPrefix)
+	if exclude {
+		tf.exclude = append(tf.exclude, tag)
+	} else {
+		tf.include = append(tf.include, tag)
+	}
+	tf.raw = append(tf.raw, value)
+	return nil
+}
+
+// Type implements pflag.Value.Type for taskFilter
+func (tf *taskFilter) Type() string {
+	return "tag:<tag>"
+}
+
+func (tf *taskFilter) isEmpty() bool {
+	return len(tf.include) == 0 && len(tf.exclude) == 0
+}
+
+func hasAnyTag(taskDefinition fs.TaskDefinition, tags []string) bool {
+	for _, tag := range tags {
+		if taskDefinition.HasTag(tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// apply removes the tasks that were not selected from taskGraph. If any tags are
+// included, only the requested tasks carrying one of them are run, along with their
+// dependencies. Excluded tasks are removed even when other tasks depend on them,
+// in which case those tasks depend directly on the dependencies of the excluded task.
+func (tf *taskFilter) apply(taskGraph *dag.AcyclicGraph, pipeline fs.Pipeline, pkgs []string, targets []string) error {
+	if tf.isEmpty() {
+		return nil
+	}
+	taskDefinition := func(taskID string) fs.TaskDefinition {
+		definition, _ := pipeline.GetTaskDefinition(taskID)
+		return definition
+	}
+
+	if len(tf.include) > 0 {
+		selected := make(dag.Set)
+		for _, pkg := range pkgs {
+			for _, target := range targets {
+				taskID := util.GetTaskId(pkg, target)
+				if !taskGraph.HasVertex(taskID) || !hasAnyTag(taskDefinition(taskID), tf.include) {
+					continue
+				}
+				selected.Add(taskID)
+				deps, err := taskGraph.Ancestors(taskID)
+				if err != nil {
+					return err
+				}
+				for _, dep := range deps {
+					selected.Add(dep)
+				}
+			}
+		}
+		for _, v := range taskGraph.Vertices() {
+			if v != core.ROOT_NODE_NAME && !selected.Include(v) {
+				taskGraph.Remove(v)
+			}
+		}
+	}
+
+	for _, v := range taskGraph.Vertices() {
+		taskID := dag.VertexName(v)
+		if taskID == core.ROOT_NODE_NAME || !hasAnyTag(taskDefinition(taskID), tf.exclude) {
+			continue
+		}
+		for _, dependent := range taskGraph.UpEdges(v) {
+			for _, dep := range taskGraph.DownEdges(v) {
+				taskGraph.Connect(dag.BasicEdge(dependent, dep))
+			}
+		}
+		taskGraph.Remove(v)
+	}
+	return nil
+}
+
+// expandTagDependencies replaces references to tags, e.g. "tag:codegen", in the
+// dependencies of taskName with every task in the pipeline that carries the tag
+func expandTagDependencies(pipeline fs.Pipeline, taskName string, dependencies []string) []string {
+	self := taskName
+	if util.IsPackageTask(taskName) {
+		_, self = util.GetPackageTaskFromId(taskName)
+	}
+	var expanded []string
+	for _, dependency := range dependencies {
+		if !strings.HasPrefix(dependency, fs.TagPrefix) {
+			expanded = append(expanded, dependency)
+			continue
+		}
+		for _, task := range pipeline.TasksWithTag(strings.TrimPrefix(dependency, fs.TagPrefix)) {
+			if task != self {
+				expanded = append(expanded, task)
+			}
+		}
+	}
+	return expanded
+}
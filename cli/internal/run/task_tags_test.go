@@ -0,0 +1,98 @@
+package run
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/pyr-sh/dag"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/util"
+	"gotest.tools/v3/assert"
+)
+
+func taskGraphVertices(graph *dag.AcyclicGraph) []string {
+	var vertices []string
+	for _, v := range graph.Vertices() {
+		vertices = append(vertices, dag.VertexName(v))
+	}
+	sort.Strings(vertices)
+	return vertices
+}
+
+func Test_taskTags(t *testing.T) {
+	pipeline := fs.Pipeline{
+		"codegen": {Tags: []string{"codegen"}},
+		"schema":  {Tags: []string{"codegen"}},
+		"build":   {TaskDependencies: []string{"tag:codegen"}},
+		"e2e":     {TaskDependencies: []string{"build"}, Tags: []string{"e2e", "slow"}},
+		"deploy":  {TaskDependencies: []string{"e2e"}},
+		"lint":    {},
+	}
+
+	testCases := []struct {
+		name    string
+		filters []string
+		targets []string
+		want    []string
+	}{
+		{
+			name:    "tags in dependsOn",
+			targets: []string{"build"},
+			want:    []string{"___ROOT___", "a#build", "a#codegen", "a#schema"},
+		},
+		{
+			name:    "include",
+			filters: []string{"tag:slow"},
+			targets: []string{"e2e", "lint"},
+			want:    []string{"___ROOT___", "a#build", "a#codegen", "a#e2e", "a#schema"},
+		},
+		{
+			name:    "exclude",
+			filters: []string{"!tag:e2e"},
+			targets: []string{"deploy"},
+			want:    []string{"___ROOT___", "a#build", "a#codegen", "a#deploy", "a#schema"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			topoGraph := &dag.AcyclicGraph{}
+			topoGraph.Add("a")
+			rs := &runSpec{
+				FilteredPkgs: util.SetFromStrings([]string{"a"}),
+				Targets:      tc.targets,
+				Opts:         &Opts{},
+			}
+			for _, filter := range tc.filters {
+				assert.NilError(t, rs.Opts.runOpts.taskFilter.Set(filter), "Set")
+			}
+			engine, err := buildTaskGraphEngine(topoGraph, pipeline, rs)
+			assert.NilError(t, err, "buildTaskGraphEngine")
+			assert.DeepEqual(t, taskGraphVertices(engine.TaskGraph), tc.want)
+		})
+	}
+
+	t.Run("excluded tasks keep the order of their dependencies", func(t *testing.T) {
+		topoGraph := &dag.AcyclicGraph{}
+		topoGraph.Add("a")
+		rs := &runSpec{
+			FilteredPkgs: util.SetFromStrings([]string{"a"}),
+			Targets:      []string{"deploy"},
+			Opts:         &Opts{},
+		}
+		assert.NilError(t, rs.Opts.runOpts.taskFilter.Set("!tag:e2e"), "Set")
+		engine, err := buildTaskGraphEngine(topoGraph, pipeline, rs)
+		assert.NilError(t, err, "buildTaskGraphEngine")
+		assert.Assert(t, engine.TaskGraph.DownEdges("a#deploy").Include("a#build"))
+	})
+}
+
+func Test_taskFilterSet(t *testing.T) {
+	tf := &taskFilter{}
+	assert.NilError(t, tf.Set("tag:slow"), "Set")
+	assert.NilError(t, tf.Set("!tag:e2e"), "Set")
+	assert.DeepEqual(t, tf.include, []string{"slow"})
+	assert.DeepEqual(t, tf.exclude, []string{"e2e"})
+	assert.Equal(t, tf.String(), "tag:slow,!tag:e2e")
+	assert.ErrorContains(t, tf.Set("slow"), "invalid task filter")
+	assert.ErrorContains(t, tf.Set("!tag:"), "invalid task filter")
+}
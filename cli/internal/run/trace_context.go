@@ -0,0 +1,318 @@
+package run
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vercel/turbo/cli/internal/core"
+)
+
+// Environment variables that carry W3C trace context between processes, as set
+// by CI systems and read by OpenTelemetry SDKs
+const (
+	traceparentEnvVar = "TRACEPARENT"
+	tracestateEnvVar  = "TRACESTATE"
+	// otlpTracesEndpointEnvVar is the URL that spans are posted to, and
+	// otlpEndpointEnvVar the base URL that "/v1/traces" is appended to
+	otlpTracesEndpointEnvVar = "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"
+	otlpEndpointEnvVar       = "OTEL_EXPORTER_OTLP_ENDPOINT"
+	otlpTracesHeadersEnvVar  = "OTEL_EXPORTER_OTLP_TRACES_HEADERS"
+	otlpHeadersEnvVar        = "OTEL_EXPORTER_OTLP_HEADERS"
+)
+
+// _traceparentPattern matches version 00 of the traceparent header, and the
+// leading fields of later versions
+var _traceparentPattern = regexp.MustCompile(`^([0-9a-f]{2})-([0-9a-f]{32})-([0-9a-f]{16})-([0-9a-f]{2})(-.*)?$`)
+
+// traceparent is a parsed W3C traceparent
+type traceparent struct {
+	traceID  string
+	parentID string
+	flags    string
+}
+
+// parseTraceparent parses a traceparent, rejecting the invalid values that the
+// W3C spec says must be ignored
+func parseTraceparent(value string) (traceparent, bool) {
+	match := _traceparentPattern.FindStringSubmatch(strings.TrimSpace(value))
+	if match == nil {
+		return traceparent{}, false
+	}
+	version, traceID, parentID, flags, rest := match[1], match[2], match[3], match[4], match[5]
+	if version == "ff" || (version == "00" && rest != "") {
+		return traceparent{}, false
+	}
+	if traceID == strings.Repeat("0", 32) || parentID == strings.Repeat("0", 16) {
+		return traceparent{}, false
+	}
+	return traceparent{traceID: traceID, parentID: parentID, flags: flags}, true
+}
+
+// String formats tp as a version 00 traceparent
+func (tp traceparent) String() string {
+	return fmt.Sprintf("00-%v-%v-%v", tp.traceID, tp.parentID, tp.flags)
+}
+
+func newSpanID() string {
+	var id [8]byte
+	_, _ = rand.Read(id[:])
+	return hex.EncodeToString(id[:])
+}
+
+// runTrace links the tasks of a run into the trace that turbo was started in.
+// Each task gets a span of its own, which is passed to its process in
+// TRACEPARENT, so that the spans of tools that the task runs are its children.
+// If an OTLP endpoint is configured, turbo exports a span for the run, and one
+// for each task, once the run is done. Otherwise, tasks are passed turbo's own
+// parent, since spans that are never exported would break the trace.
+type runTrace struct {
+	parent     traceparent
+	tracestate string
+	// endpoint is where spans are exported to, if anywhere
+	endpoint string
+	headers  map[string]string
+	runID    string
+	version  string
+	// runSpanID is the span of the whole run, the parent of every task's span
+	runSpanID string
+
+	mu        sync.Mutex
+	taskSpans map[string]string
+}
+
+// newRunTrace returns the trace of a run, or nil if turbo isn't part of one
+func newRunTrace(getenv func(string) string, runID string, version string) (*runTrace, error) {
+	raw := getenv(traceparentEnvVar)
+	if raw == "" {
+		return nil, nil
+	}
+	parent, ok := parseTraceparent(raw)
+	if !ok {
+		return nil, fmt.Errorf("ignoring invalid %v %q", traceparentEnvVar, raw)
+	}
+	rt := &runTrace{
+		parent:     parent,
+		tracestate: getenv(tracestateEnvVar),
+		runID:      runID,
+		version:    version,
+		runSpanID:  newSpanID(),
+		taskSpans:  make(map[string]string),
+	}
+	if endpoint := getenv(otlpTracesEndpointEnvVar); endpoint != "" {
+		rt.endpoint = endpoint
+	} else if endpoint := getenv(otlpEndpointEnvVar); endpoint != "" {
+		rt.endpoint = strings.TrimSuffix(endpoint, "/") + "/v1/traces"
+	}
+	if rt.endpoint != "" {
+		if u, err := url.Parse(rt.endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return nil, fmt.Errorf("%v is not an http or https URL, spans will not be exported", rt.endpoint)
+		}
+		headers := getenv(otlpTracesHeadersEnvVar)
+		if headers == "" {
+			headers = getenv(otlpHeadersEnvVar)
+		}
+		rt.headers = parseOTLPHeaders(headers)
+	}
+	return rt, nil
+}
+
+// parseOTLPHeaders parses a comma-separated list of key=value pairs, whose values
+// may be URL encoded
+func parseOTLPHeaders(raw string) map[string]string {
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(raw, ",") {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			continue
+		}
+		if unescaped, err := url.QueryUnescape(strings.TrimSpace(value)); err == nil {
+			value = unescaped
+		}
+		headers[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return headers
+}
+
+// exporting is whether turbo's spans are exported
+func (rt *runTrace) exporting() bool {
+	return rt.endpoint != ""
+}
+
+// taskSpanID returns the span of a task, which is the same for every attempt
+func (rt *runTrace) taskSpanID(taskID string) string {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	spanID, ok := rt.taskSpans[taskID]
+	if !ok {
+		spanID = newSpanID()
+		rt.taskSpans[taskID] = spanID
+	}
+	return spanID
+}
+
+// envForTask returns the trace context variables for a task's process
+func (rt *runTrace) envForTask(taskID string) []string {
+	parent := rt.parent
+	if rt.exporting() {
+		parent.parentID = rt.taskSpanID(taskID)
+	}
+	env := []string{traceparentEnvVar + "=" + parent.String()}
+	if rt.tracestate != "" {
+		env = append(env, tracestateEnvVar+"="+rt.tracestate)
+	}
+	return env
+}
+
+// OTLP/HTTP JSON encoding of spans, of which turbo only needs a small part
+type otlpTraces struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID      string `json:"traceId"`
+	SpanID       string `json:"spanId"`
+	ParentSpanID string `json:"parentSpanId,omitempty"`
+	TraceState   string `json:"traceState,omitempty"`
+	Name         string `json:"name"`
+	// Kind is 1, for spans internal to turbo
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+// otlpStatusError is the status code of spans that failed
+const otlpStatusError = 2
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+func otlpString(key string, value string) otlpAttribute {
+	return otlpAttribute{Key: key, Value: otlpValue{StringValue: value}}
+}
+
+func otlpTime(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// spans returns the span of the run, followed by the span of each task that ran
+func (rt *runTrace) spans(targets []string, startAt time.Time, endAt time.Time, exitCode int, results []core.TaskResult) []otlpSpan {
+	runSpan := otlpSpan{
+		TraceID:           rt.parent.traceID,
+		SpanID:            rt.runSpanID,
+		ParentSpanID:      rt.parent.parentID,
+		TraceState:        rt.tracestate,
+		Name:              "turbo run " + strings.Join(targets, " "),
+		Kind:              1,
+		StartTimeUnixNano: otlpTime(startAt),
+		EndTimeUnixNano:   otlpTime(endAt),
+		Attributes: []otlpAttribute{
+			otlpString("turbo.run.id", rt.runID),
+			otlpString("turbo.run.exit_code", strconv.Itoa(exitCode)),
+		},
+	}
+	if exitCode != 0 {
+		runSpan.Status = otlpStatus{Code: otlpStatusError, Message: fmt.Sprintf("exited with code %v", exitCode)}
+	}
+	spans := []otlpSpan{runSpan}
+	for _, result := range results {
+		if result.Status == core.TaskSkipped || result.Status == core.TaskCanceled || result.StartedAt.IsZero() {
+			continue
+		}
+		span := otlpSpan{
+			TraceID:           rt.parent.traceID,
+			SpanID:            rt.taskSpanID(result.TaskID),
+			ParentSpanID:      rt.runSpanID,
+			TraceState:        rt.tracestate,
+			Name:              result.TaskID,
+			Kind:              1,
+			StartTimeUnixNano: otlpTime(result.StartedAt),
+			EndTimeUnixNano:   otlpTime(result.EndedAt),
+			Attributes: []otlpAttribute{
+				otlpString("turbo.task.id", result.TaskID),
+				otlpString("turbo.task.status", string(result.Status)),
+			},
+		}
+		if result.CacheStatus != "" {
+			span.Attributes = append(span.Attributes, otlpString("turbo.task.cache", string(result.CacheStatus)))
+		}
+		if result.Status == core.TaskFailed {
+			span.Status = otlpStatus{Code: otlpStatusError}
+			if result.Err != nil {
+				span.Status.Message = result.Err.Error()
+			}
+		}
+		spans = append(spans, span)
+	}
+	return spans
+}
+
+// export posts the spans of the run to the OTLP endpoint
+func (rt *runTrace) export(spans []otlpSpan) error {
+	body, err := json.Marshal(otlpTraces{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: []otlpAttribute{otlpString("service.name", "turbo")}},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "turbo", Version: rt.version}, Spans: spans}},
+	}}})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, rt.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range rt.headers {
+		req.Header.Set(key, value)
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%v responded with %v", rt.endpoint, resp.Status)
+	}
+	return nil
+}
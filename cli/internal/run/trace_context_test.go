@@ -0,0 +1,147 @@
+package run
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vercel/turbo/cli/internal/core"
+	"gotest.tools/v3/assert"
+)
+
+const _testTraceparent = "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+
+func getenvFrom(vars map[string]string) func(string) string {
+	return func(name string) string {
+		return vars[name]
+	}
+}
+
+func TestParseTraceparent(t *testing.T) {
+	tp, ok := parseTraceparent(_testTraceparent)
+	assert.Assert(t, ok)
+	assert.Equal(t, tp.traceID, "0af7651916cd43dd8448eb211c80319c")
+	assert.Equal(t, tp.parentID, "b7ad6b7169203331")
+	assert.Equal(t, tp.flags, "01")
+	assert.Equal(t, tp.String(), _testTraceparent)
+
+	// Later versions may add fields, which are dropped
+	tp, ok = parseTraceparent("01-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01-extra")
+	assert.Assert(t, ok)
+	assert.Equal(t, tp.String(), _testTraceparent)
+
+	for _, invalid := range []string{
+		"",
+		"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331",
+		"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01-extra",
+		"ff-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+		"00-00000000000000000000000000000000-b7ad6b7169203331-01",
+		"00-0af7651916cd43dd8448eb211c80319c-0000000000000000-01",
+		"00-0AF7651916CD43DD8448EB211C80319C-b7ad6b7169203331-01",
+	} {
+		_, ok := parseTraceparent(invalid)
+		assert.Assert(t, !ok, invalid)
+	}
+}
+
+func TestNewRunTrace(t *testing.T) {
+	rt, err := newRunTrace(getenvFrom(nil), "run-1", "1.0.0")
+	assert.NilError(t, err)
+	assert.Assert(t, rt == nil)
+
+	_, err = newRunTrace(getenvFrom(map[string]string{traceparentEnvVar: "invalid"}), "run-1", "1.0.0")
+	assert.ErrorContains(t, err, "ignoring invalid TRACEPARENT")
+
+	rt, err = newRunTrace(getenvFrom(map[string]string{
+		traceparentEnvVar:  _testTraceparent,
+		otlpEndpointEnvVar: "http://collector:4318/",
+		otlpHeadersEnvVar:  "x-api-key=secret,x-team=a%20b",
+	}), "run-1", "1.0.0")
+	assert.NilError(t, err)
+	assert.Equal(t, rt.endpoint, "http://collector:4318/v1/traces")
+	assert.DeepEqual(t, rt.headers, map[string]string{"x-api-key": "secret", "x-team": "a b"})
+
+	rt, err = newRunTrace(getenvFrom(map[string]string{
+		traceparentEnvVar:        _testTraceparent,
+		otlpEndpointEnvVar:       "http://collector:4318",
+		otlpTracesEndpointEnvVar: "http://collector:4318/traces",
+	}), "run-1", "1.0.0")
+	assert.NilError(t, err)
+	assert.Equal(t, rt.endpoint, "http://collector:4318/traces")
+}
+
+func TestRunTraceEnvForTask(t *testing.T) {
+	// Without an endpoint, tasks are children of turbo's parent
+	rt, err := newRunTrace(getenvFrom(map[string]string{
+		traceparentEnvVar: _testTraceparent,
+		tracestateEnvVar:  "vendor=value",
+	}), "run-1", "1.0.0")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, rt.envForTask("web#build"), []string{"TRACEPARENT=" + _testTraceparent, "TRACESTATE=vendor=value"})
+
+	// With one, each task is a child of its own span
+	rt, err = newRunTrace(getenvFrom(map[string]string{
+		traceparentEnvVar:  _testTraceparent,
+		otlpEndpointEnvVar: "http://collector:4318",
+	}), "run-1", "1.0.0")
+	assert.NilError(t, err)
+	env := rt.envForTask("web#build")
+	assert.Equal(t, len(env), 1)
+	tp, ok := parseTraceparent(strings.TrimPrefix(env[0], "TRACEPARENT="))
+	assert.Assert(t, ok)
+	assert.Equal(t, tp.traceID, "0af7651916cd43dd8448eb211c80319c")
+	assert.Equal(t, tp.parentID, rt.taskSpanID("web#build"))
+	assert.Assert(t, tp.parentID != rt.taskSpanID("docs#build"))
+	assert.DeepEqual(t, rt.envForTask("web#build"), env)
+}
+
+func TestRunTraceExport(t *testing.T) {
+	var received otlpTraces
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, r.URL.Path, "/v1/traces")
+		assert.Equal(t, r.Header.Get("Content-Type"), "application/json")
+		assert.Equal(t, r.Header.Get("x-api-key"), "secret")
+		assert.NilError(t, json.NewDecoder(r.Body).Decode(&received))
+	}))
+	defer server.Close()
+
+	rt, err := newRunTrace(getenvFrom(map[string]string{
+		traceparentEnvVar:  _testTraceparent,
+		otlpEndpointEnvVar: server.URL,
+		otlpHeadersEnvVar:  "x-api-key=secret",
+	}), "run-1", "1.0.0")
+	assert.NilError(t, err)
+	startAt := time.Now()
+	results := []core.TaskResult{
+		{TaskID: "web#build", Status: core.TaskSucceeded, StartedAt: startAt, EndedAt: startAt.Add(time.Second), CacheStatus: core.CacheMiss},
+		{TaskID: "web#test", Status: core.TaskFailed, StartedAt: startAt.Add(time.Second), EndedAt: startAt.Add(2 * time.Second), Err: errors.New("exit status 1")},
+		{TaskID: "web#lint", Status: core.TaskSkipped},
+	}
+	assert.NilError(t, rt.export(rt.spans([]string{"build", "test"}, startAt, startAt.Add(3*time.Second), 1, results)))
+
+	assert.Equal(t, len(received.ResourceSpans), 1)
+	spans := received.ResourceSpans[0].ScopeSpans[0].Spans
+	assert.Equal(t, len(spans), 3)
+	run, build, test := spans[0], spans[1], spans[2]
+	assert.Equal(t, run.Name, "turbo run build test")
+	assert.Equal(t, run.TraceID, "0af7651916cd43dd8448eb211c80319c")
+	assert.Equal(t, run.ParentSpanID, "b7ad6b7169203331")
+	assert.Equal(t, run.Status.Code, otlpStatusError)
+	assert.Equal(t, build.Name, "web#build")
+	assert.Equal(t, build.ParentSpanID, run.SpanID)
+	assert.Equal(t, build.SpanID, rt.taskSpanID("web#build"))
+	assert.Equal(t, build.Status.Code, 0)
+	assert.DeepEqual(t, build.Attributes[2], otlpString("turbo.task.cache", "MISS"))
+	assert.Equal(t, test.Status.Message, "exit status 1")
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer failing.Close()
+	rt.endpoint = failing.URL
+	assert.ErrorContains(t, rt.export(nil), "401 Unauthorized")
+}
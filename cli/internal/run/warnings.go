@@ -0,0 +1,93 @@
+package run
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/fatih/color"
+	"github.com/vercel/turbo/cli/internal/cmdutil"
+	"github.com/vercel/turbo/cli/internal/errcode"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/ui"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// warningReporter applies the --warnings policy to the warnings of a run. Warnings
+// that are escalated to errors are printed as they happen, and fail the run once
+// it has finished.
+type warningReporter struct {
+	mu        sync.Mutex
+	base      *cmdutil.CmdBase
+	policy    *errcode.WarningPolicy
+	escalated []*errcode.Warning
+}
+
+// report prints, silences, or escalates a warning, according to the policy
+func (wr *warningReporter) report(warning *errcode.Warning) {
+	switch wr.policy.Action(warning.Code) {
+	case errcode.WarningSilence:
+		wr.base.Logger.Debug("silenced warning", "code", warning.Code, "warning", warning)
+	case errcode.WarningEscalate:
+		wr.mu.Lock()
+		wr.escalated = append(wr.escalated, warning)
+		wr.mu.Unlock()
+		wr.base.Logger.Error("warning treated as error", "code", warning.Code, "error", warning)
+		wr.base.UI.Error(fmt.Sprintf("%s%s [%v]", ui.ERROR_PREFIX, color.RedString(" %v", warning), warning.Code))
+	default:
+		wr.base.Logger.Warn("", "code", warning.Code, "warning", warning)
+		wr.base.UI.Warn(fmt.Sprintf("%s%s [%v]", ui.WARNING_PREFIX, color.YellowString(" %v", warning), warning.Code))
+	}
+}
+
+// err returns an error if any warnings were escalated, classified with the code
+// of the first of them
+func (wr *warningReporter) err() error {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+	if len(wr.escalated) == 0 {
+		return nil
+	}
+	return errcode.Wrap(wr.escalated[0].Code, fmt.Errorf("%v warnings were treated as errors because of --warnings=strict", len(wr.escalated)))
+}
+
+// checkUnusedPipelineEntries returns a warning for each pipeline entry that cannot
+// match any script: tasks that no workspace has a script for, and package-tasks
+// for workspaces that don't exist or don't have the script. Passthrough tasks are
+// never run, so they don't need a script.
+func checkUnusedPipelineEntries(pipeline fs.Pipeline, packageInfos map[interface{}]*fs.PackageJSON) []*errcode.Warning {
+	scripts := make(util.Set)
+	for _, pkg := range packageInfos {
+		for script := range pkg.Scripts {
+			scripts.Add(script)
+		}
+	}
+	var warnings []*errcode.Warning
+	for key, taskDefinition := range pipeline {
+		if taskDefinition.Passthrough || taskDefinition.TypeScript {
+			// TypeScript tasks run tsc --build where there is no script
+			continue
+		}
+		if !util.IsPackageTask(key) {
+			if !scripts.Includes(key) {
+				warnings = append(warnings, errcode.NewWarning(errcode.UnusedPipelineEntry, fmt.Errorf("the %q task in turbo.json does nothing, because no workspace has a %q script", key, key)))
+			}
+			continue
+		}
+		pkgName, task := util.GetPackageTaskFromId(key)
+		pkg, ok := packageInfos[pkgName]
+		if !ok {
+			warnings = append(warnings, errcode.NewWarning(errcode.UnusedPipelineEntry, fmt.Errorf("the %q task in turbo.json does nothing, because there is no workspace named %q", key, pkgName)))
+		} else if _, hasScript := pkg.Scripts[task]; hasScript {
+			continue
+		} else if pkgName == util.RootPkgName {
+			warnings = append(warnings, errcode.NewWarning(errcode.UnusedPipelineEntry, fmt.Errorf("the %q task in turbo.json does nothing, because the root package.json has no %q script", key, task)))
+		} else {
+			warnings = append(warnings, errcode.NewWarning(errcode.UnusedPipelineEntry, fmt.Errorf("the %q task in turbo.json does nothing, because %v has no %q script", key, pkgName, task)))
+		}
+	}
+	sort.Slice(warnings, func(i, j int) bool {
+		return warnings[i].Error() < warnings[j].Error()
+	})
+	return warnings
+}
@@ -0,0 +1,46 @@
+package run
+
+import (
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/errcode"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/util"
+	"gotest.tools/v3/assert"
+)
+
+func Test_checkUnusedPipelineEntries(t *testing.T) {
+	packageInfos := map[interface{}]*fs.PackageJSON{
+		util.RootPkgName: {Scripts: map[string]string{"format": "prettier --write ."}},
+		"web":            {Scripts: map[string]string{"build": "next build", "dev": "next dev"}},
+		"docs":           {Scripts: map[string]string{"build": "next build"}},
+	}
+	pipeline := fs.Pipeline{
+		"build":          fs.TaskDefinition{},
+		"dev":            fs.TaskDefinition{},
+		"lint":           fs.TaskDefinition{},
+		"web#dev":        fs.TaskDefinition{},
+		"docs#dev":       fs.TaskDefinition{},
+		"blog#build":     fs.TaskDefinition{},
+		"//#format":      fs.TaskDefinition{},
+		"//#test":        fs.TaskDefinition{},
+		"ui#build":       fs.TaskDefinition{Passthrough: true},
+		"typecheck":      fs.TaskDefinition{Passthrough: true},
+		"web#storybook":  fs.TaskDefinition{Passthrough: true},
+		"docs#storybook": fs.TaskDefinition{},
+	}
+
+	warnings := checkUnusedPipelineEntries(pipeline, packageInfos)
+	var messages []string
+	for _, warning := range warnings {
+		assert.Equal(t, warning.Code, errcode.UnusedPipelineEntry)
+		messages = append(messages, warning.Error())
+	}
+	assert.DeepEqual(t, messages, []string{
+		`the "//#test" task in turbo.json does nothing, because the root package.json has no "test" script`,
+		`the "blog#build" task in turbo.json does nothing, because there is no workspace named "blog"`,
+		`the "docs#dev" task in turbo.json does nothing, because docs has no "dev" script`,
+		`the "docs#storybook" task in turbo.json does nothing, because docs has no "storybook" script`,
+		`the "lint" task in turbo.json does nothing, because no workspace has a "lint" script`,
+	})
+}
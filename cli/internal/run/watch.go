@@ -0,0 +1,353 @@
+package run
+
+import (
+	gocontext "context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/pyr-sh/dag"
+	gitignore "github.com/sabhiram/go-gitignore"
+	"github.com/vercel/turbo/cli/internal/core"
+	"github.com/vercel/turbo/cli/internal/doublestar"
+	"github.com/vercel/turbo/cli/internal/filewatcher"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/packagemanager"
+	"github.com/vercel/turbo/cli/internal/process"
+	"github.com/vercel/turbo/cli/internal/taskhash"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/ui"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// _watchSettleTime is how long files must go unchanged before tasks are re-run, so
+// that saving many files at once, such as when switching branches, re-runs them once
+const _watchSettleTime = 200 * time.Millisecond
+
+// errReload is returned by a watched run when files changed that can change the
+// package graph or the pipeline, so the run must be prepared again from scratch
+var errReload = errors.New("configuration changed")
+
+// _watchIgnoredDirs are directories whose changes never affect tasks
+var _watchIgnoredDirs = []string{".git", ".turbo", "node_modules"}
+
+// watchState is the state of --watch that outlives each run: the files that changed,
+// and the tasks that succeeded, which don't need to run again until they're affected
+type watchState struct {
+	logger hclog.Logger
+
+	mu      sync.Mutex
+	changed map[turbopath.AbsoluteSystemPath]struct{}
+	// notify has a value once files have changed
+	notify chan struct{}
+
+	succeeded util.Set
+}
+
+var _ filewatcher.FileWatchClient = (*watchState)(nil)
+
+func newWatchState(logger hclog.Logger) *watchState {
+	return &watchState{
+		logger:    logger,
+		changed:   make(map[turbopath.AbsoluteSystemPath]struct{}),
+		notify:    make(chan struct{}, 1),
+		succeeded: make(util.Set),
+	}
+}
+
+// OnFileWatchEvent implements filewatcher.FileWatchClient.OnFileWatchEvent
+func (ws *watchState) OnFileWatchEvent(ev filewatcher.Event) {
+	ws.mu.Lock()
+	ws.changed[ev.Path] = struct{}{}
+	ws.mu.Unlock()
+	select {
+	case ws.notify <- struct{}{}:
+	default:
+	}
+}
+
+// OnFileWatchError implements filewatcher.FileWatchClient.OnFileWatchError
+func (ws *watchState) OnFileWatchError(err error) {
+	ws.logger.Warn("file watching error", "error", err)
+}
+
+// OnFileWatchClosed implements filewatcher.FileWatchClient.OnFileWatchClosed
+func (ws *watchState) OnFileWatchClosed() {}
+
+// settle waits until no files have changed for _watchSettleTime, then returns the
+// files that changed since it was last called
+func (ws *watchState) settle(ctx gocontext.Context) ([]turbopath.AbsoluteSystemPath, error) {
+	timer := time.NewTimer(_watchSettleTime)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ws.notify:
+			if !timer.Stop() {
+				<-timer.C
+			}
+			timer.Reset(_watchSettleTime)
+		case <-timer.C:
+			ws.mu.Lock()
+			defer ws.mu.Unlock()
+			paths := make([]turbopath.AbsoluteSystemPath, 0, len(ws.changed))
+			for path := range ws.changed {
+				paths = append(paths, path)
+			}
+			ws.changed = make(map[turbopath.AbsoluteSystemPath]struct{})
+			return paths, nil
+		}
+	}
+}
+
+// record updates which tasks succeeded from the results of a run
+func (ws *watchState) record(results []core.TaskResult) {
+	for _, result := range results {
+		if result.Status == core.TaskSucceeded {
+			ws.succeeded.Add(result.TaskID)
+		} else {
+			ws.succeeded.Delete(result.TaskID)
+		}
+	}
+}
+
+// watch runs the targets like run, and then runs the tasks that changed files
+// affect again, until turbo is interrupted
+func (r *run) watch(ctx gocontext.Context, targets []string) error {
+	if r.opts.runOpts.dryRun || r.opts.runOpts.graphDot || r.opts.runOpts.graphFile != "" || r.opts.runOpts.quiet {
+		return errors.New("--watch cannot be combined with --dry-run, --graph, or --quiet")
+	}
+	logger := r.base.Logger.Named("watch")
+	backend, err := filewatcher.GetPlatformSpecificBackend(logger)
+	if err != nil {
+		return fmt.Errorf("failed to watch files: %w", err)
+	}
+	watcher := filewatcher.New(logger, r.base.RepoRoot, backend)
+	r.watching = newWatchState(logger)
+	watcher.AddClient(r.watching)
+	if err := watcher.Start(); err != nil {
+		return fmt.Errorf("failed to watch files: %w", err)
+	}
+	defer func() { _ = watcher.Close() }()
+	for {
+		err := r.run(ctx, targets)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if !errors.Is(err, errReload) {
+			// The run couldn't start, such as because turbo.json is invalid, so
+			// wait for files to change before trying again
+			r.base.LogError("run failed: %w", err)
+			r.base.UI.Output(ui.Dim("• Watching for changes"))
+			if _, err := r.watching.settle(ctx); err != nil {
+				return nil
+			}
+		}
+		r.base.UI.Output(ui.Dim("• Configuration changed, restarting"))
+	}
+}
+
+// watchTasks executes the task graph, and then executes the tasks that changed files
+// affect again. A run that is in progress when files change is stopped, including
+// persistent tasks, and the tasks that didn't succeed are part of the next run.
+func (r *run) watchTasks(ctx gocontext.Context, g *completeGraph, rs *runSpec, engine *core.Engine, packageManager *packagemanager.PackageManager, tracker *taskhash.Tracker) error {
+	changes := newWatchedChanges(r.base.RepoRoot, g, rs)
+	next := engine
+	for {
+		runCtx, cancel := gocontext.WithCancel(ctx)
+		processes := process.NewManager(r.base.Logger.Named("processes"))
+		removeCloser := r.signalWatcher.AddOnClose(processes.Close)
+		r.processes = processes
+		done := make(chan error, 1)
+		go func(engine *core.Engine) {
+			done <- r.executeTasks(runCtx, g, rs, engine, packageManager, tracker, time.Now())
+		}(next)
+		stop := func() {
+			cancel()
+			processes.Close()
+			if done != nil {
+				<-done
+			}
+			// Otherwise the watcher would keep every iteration's processes alive
+			removeCloser()
+		}
+
+		var affected util.Set
+		for affected == nil {
+			select {
+			case <-ctx.Done():
+				stop()
+				return nil
+			case err := <-done:
+				done = nil
+				// Failed tasks were already reported
+				childExit := &process.ChildExit{}
+				if err != nil && !errors.As(err, &childExit) {
+					r.base.LogError("run failed: %w", err)
+				}
+				r.base.UI.Output(ui.Dim("• Watching for changes"))
+			case <-r.watching.notify:
+				paths, err := r.watching.settle(ctx)
+				if err != nil {
+					continue
+				}
+				pkgs, reload := changes.packages(paths)
+				if reload {
+					stop()
+					return errReload
+				} else if pkgs.Len() == 0 {
+					continue
+				}
+				stop()
+				affected = make(util.Set)
+				for _, v := range engine.TaskGraph.Vertices() {
+					taskID := dag.VertexName(v)
+					if taskID == core.ROOT_NODE_NAME {
+						continue
+					}
+					pkg, _ := util.GetPackageTaskFromId(taskID)
+					if pkgs.Includes(pkg) || !r.watching.succeeded.Includes(taskID) {
+						affected.Add(taskID)
+					}
+				}
+				changedPkgs := pkgs.UnsafeListOfStrings()
+				sort.Strings(changedPkgs)
+				r.base.UI.Output("")
+				r.base.UI.Output(ui.Dim(fmt.Sprintf("• Files changed in %v", strings.Join(changedPkgs, ", "))))
+			}
+		}
+		cancel()
+
+		var err error
+		next, err = engine.PrepareAffected(affected)
+		if err != nil {
+			return fmt.Errorf("failed to prepare the affected tasks: %w", err)
+		}
+		if err := tracker.CalculateFileHashes(next.TaskGraph.Vertices(), rs.Opts.runOpts.concurrency, r.base.RepoRoot); err != nil {
+			return fmt.Errorf("error hashing package files: %w", err)
+		}
+	}
+}
+
+// watchedChanges maps changed files to the workspaces whose tasks they affect
+type watchedChanges struct {
+	repoRoot turbopath.AbsoluteSystemPath
+	g        *completeGraph
+	// ignored are files and directories that turbo writes to during a run
+	ignored   []turbopath.AbsoluteSystemPath
+	gitignore *gitignore.GitIgnore
+}
+
+func newWatchedChanges(repoRoot turbopath.AbsoluteSystemPath, g *completeGraph, rs *runSpec) *watchedChanges {
+	wc := &watchedChanges{repoRoot: repoRoot, g: g}
+	for _, path := range []string{
+		rs.Opts.cacheOpts.OverrideDir,
+		rs.Opts.runOpts.artifactsDir,
+		rs.Opts.runOpts.junitFile,
+		rs.Opts.runOpts.flakyReportFile,
+		rs.Opts.runOpts.profile,
+	} {
+		if path != "" {
+			wc.ignored = append(wc.ignored, fs.ResolveUnknownPath(repoRoot, path))
+		}
+	}
+	if ignore, err := gitignore.CompileIgnoreFile(repoRoot.UntypedJoin(".gitignore").ToString()); err == nil {
+		wc.gitignore = ignore
+	}
+	return wc
+}
+
+// packages returns the workspaces that have changed files, other than files that
+// are outputs of their tasks. reload is true if a package.json changed, or a file
+// outside of every workspace, such as turbo.json or the lockfile.
+func (wc *watchedChanges) packages(paths []turbopath.AbsoluteSystemPath) (pkgs util.Set, reload bool) {
+	pkgs = make(util.Set)
+	for _, path := range paths {
+		if wc.isIgnored(path) {
+			continue
+		}
+		relative, err := path.RelativeTo(wc.repoRoot)
+		if err != nil {
+			continue
+		}
+		file := relative.ToUnixPath().ToString()
+		pkg, ok := wc.workspaceOf(file)
+		if !ok {
+			return nil, true
+		}
+		if filepath.Base(file) == "package.json" {
+			return nil, true
+		}
+		if wc.isOutput(pkg, file) {
+			continue
+		}
+		pkgs.Add(pkg)
+	}
+	return pkgs, false
+}
+
+func (wc *watchedChanges) isIgnored(path turbopath.AbsoluteSystemPath) bool {
+	if path == wc.repoRoot {
+		return true
+	}
+	for _, ignored := range wc.ignored {
+		if path == ignored || path.HasPrefix(ignored) {
+			return true
+		}
+	}
+	relative, err := path.RelativeTo(wc.repoRoot)
+	if err != nil {
+		return true
+	}
+	file := relative.ToUnixPath().ToString()
+	for _, segment := range strings.Split(file, "/") {
+		for _, dir := range _watchIgnoredDirs {
+			if segment == dir {
+				return true
+			}
+		}
+	}
+	return wc.gitignore != nil && wc.gitignore.MatchesPath(file)
+}
+
+// workspaceOf returns the workspace that contains file, other than the root
+func (wc *watchedChanges) workspaceOf(file string) (string, bool) {
+	var match string
+	var matchDir string
+	for name, pkg := range wc.g.PackageInfos {
+		dir := pkg.Dir.ToUnixPath().ToString()
+		if name == util.RootPkgName || dir == "" || dir == "." {
+			continue
+		}
+		if strings.HasPrefix(file, dir+"/") && len(dir) > len(matchDir) {
+			match = name.(string)
+			matchDir = dir
+		}
+	}
+	return match, match != ""
+}
+
+// isOutput returns whether file matches the outputs of a task of pkg, which change
+// whenever the task runs
+func (wc *watchedChanges) isOutput(pkg string, file string) bool {
+	dir := wc.g.PackageInfos[pkg].Dir.ToUnixPath().ToString()
+	for taskName, taskDefinition := range wc.g.Pipeline {
+		if util.IsPackageTask(taskName) {
+			if taskPkg, _ := util.GetPackageTaskFromId(taskName); taskPkg != pkg {
+				continue
+			}
+		}
+		for _, output := range taskDefinition.Outputs.Inclusions {
+			if matches, err := doublestar.Match(dir+"/"+output, file); err == nil && matches {
+				return true
+			}
+		}
+	}
+	return false
+}
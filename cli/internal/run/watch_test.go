@@ -0,0 +1,78 @@
+package run
+
+import (
+	gocontext "context"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/vercel/turbo/cli/internal/core"
+	"github.com/vercel/turbo/cli/internal/filewatcher"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/util"
+	"gotest.tools/v3/assert"
+)
+
+func Test_watchedChanges(t *testing.T) {
+	repoRoot := turbopath.AbsoluteSystemPathFromUpstream(t.TempDir())
+	assert.NilError(t, repoRoot.UntypedJoin(".gitignore").WriteFile([]byte("coverage\n"), 0644), "WriteFile")
+	graph := &completeGraph{
+		PackageInfos: map[interface{}]*fs.PackageJSON{
+			util.RootPkgName: {Name: "root"},
+			"web":            {Name: "web", Dir: fs.UnsafeToAnchoredSystemPath(filepath.Join("apps", "web"))},
+			"ui":             {Name: "ui", Dir: fs.UnsafeToAnchoredSystemPath(filepath.Join("packages", "ui"))},
+			"ui-icons":       {Name: "ui-icons", Dir: fs.UnsafeToAnchoredSystemPath(filepath.Join("packages", "ui-icons"))},
+		},
+		Pipeline: fs.Pipeline{
+			"build":     fs.TaskDefinition{Outputs: fs.TaskOutputs{Inclusions: []string{"dist/**"}}},
+			"web#build": fs.TaskDefinition{Outputs: fs.TaskOutputs{Inclusions: []string{".next/**"}}},
+		},
+	}
+	rs := &runSpec{Opts: getDefaultOptions()}
+	rs.Opts.runOpts.junitFile = "junit.xml"
+	changes := newWatchedChanges(repoRoot, graph, rs)
+
+	pkgs, reload := changes.packages([]turbopath.AbsoluteSystemPath{
+		repoRoot.UntypedJoin("packages", "ui", "src", "button.tsx"),
+		repoRoot.UntypedJoin("packages", "ui-icons", "index.ts"),
+		// outputs of tasks
+		repoRoot.UntypedJoin("packages", "ui", "dist", "index.js"),
+		repoRoot.UntypedJoin("apps", "web", ".next", "build-manifest.json"),
+		// files written by turbo, or ignored by git
+		repoRoot.UntypedJoin("apps", "web", ".turbo", "turbo-build.log"),
+		repoRoot.UntypedJoin("apps", "web", "node_modules", "ui", "index.js"),
+		repoRoot.UntypedJoin("apps", "web", "coverage", "lcov.info"),
+		repoRoot.UntypedJoin("junit.xml"),
+	})
+	assert.Assert(t, !reload)
+	assert.DeepEqual(t, pkgs, util.SetFromStrings([]string{"ui", "ui-icons"}))
+
+	_, reload = changes.packages([]turbopath.AbsoluteSystemPath{repoRoot.UntypedJoin("apps", "web", "package.json")})
+	assert.Assert(t, reload, "expected a change to package.json to reload")
+	_, reload = changes.packages([]turbopath.AbsoluteSystemPath{repoRoot.UntypedJoin("turbo.json")})
+	assert.Assert(t, reload, "expected a change to turbo.json to reload")
+}
+
+func Test_watchState(t *testing.T) {
+	ws := newWatchState(hclog.NewNullLogger())
+	path := turbopath.AbsoluteSystemPathFromUpstream(t.TempDir())
+	ws.OnFileWatchEvent(filewatcher.Event{Path: path.UntypedJoin("a"), EventType: filewatcher.FileAdded})
+	ws.OnFileWatchEvent(filewatcher.Event{Path: path.UntypedJoin("a"), EventType: filewatcher.FileModified})
+	ws.OnFileWatchEvent(filewatcher.Event{Path: path.UntypedJoin("b"), EventType: filewatcher.FileModified})
+	paths, err := ws.settle(gocontext.Background())
+	assert.NilError(t, err, "settle")
+	assert.Equal(t, len(paths), 2)
+
+	ctx, cancel := gocontext.WithCancel(gocontext.Background())
+	cancel()
+	_, err = ws.settle(ctx)
+	assert.ErrorIs(t, err, gocontext.Canceled)
+
+	ws.record([]core.TaskResult{
+		{TaskID: "ui#build", Status: core.TaskSucceeded},
+		{TaskID: "web#build", Status: core.TaskSucceeded},
+	})
+	ws.record([]core.TaskResult{{TaskID: "web#build", Status: core.TaskCanceled}})
+	assert.DeepEqual(t, ws.succeeded, util.SetFromStrings([]string{"ui#build"}))
+}
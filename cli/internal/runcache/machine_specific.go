@@ -0,0 +1,184 @@
+package runcache
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+// ErrMachineSpecificOutputs is returned by SaveOutputs when outputs were not saved
+// because they embed absolute paths of the machine, which it has already warned about
+var ErrMachineSpecificOutputs = errors.New("outputs embed absolute paths of this machine")
+
+// _maxListedOutputs is the most files that a warning about machine-specific
+// outputs lists
+const _maxListedOutputs = 5
+
+// _scanBufferSize is how much of an output is read at once while scanning it
+const _scanBufferSize = 64 * 1024
+
+// _maxScannedOutputSize is the size above which outputs aren't scanned, so that
+// saving large outputs doesn't read them all again
+const _maxScannedOutputSize = 10 << 20
+
+// _binarySniffSize is how much of the start of an output is looked at for a NUL
+// byte, which marks it as binary, like git does. Binary outputs aren't scanned.
+const _binarySniffSize = 8000
+
+// machinePath is an absolute path that is specific to the machine that a task runs on
+type machinePath struct {
+	// name describes the path, such as "the repository root"
+	name string
+	// needle is the path with a trailing separator, so that only paths within it match
+	needle []byte
+}
+
+// machinePaths returns the paths whose appearance in an output means that the
+// output would be wrong when restored on another machine, or in another checkout.
+// Only the repository root is looked for: the home and temporary directories show
+// up in too many outputs that are fine to restore elsewhere, such as bundles that
+// mention /tmp/.
+func machinePaths(repoRoot turbopath.AbsoluteSystemPath) []machinePath {
+	var paths []machinePath
+	seen := make(map[string]bool)
+	add := func(name string, path string) {
+		if path == "" {
+			return
+		}
+		candidates := []string{path}
+		if resolved, err := filepath.EvalSymlinks(path); err == nil {
+			candidates = append(candidates, resolved)
+		}
+		for _, candidate := range candidates {
+			candidate = strings.TrimRight(candidate, `/\`)
+			// A filesystem root, such as "/" or "C:", would match any absolute path
+			if candidate == "" || filepath.VolumeName(candidate) == candidate {
+				continue
+			}
+			for _, form := range []string{candidate, filepath.ToSlash(candidate)} {
+				if seen[form] {
+					continue
+				}
+				seen[form] = true
+				paths = append(paths, machinePath{name: name, needle: []byte(form + separatorOf(form))})
+			}
+		}
+	}
+	add("the repository root", repoRoot.ToString())
+	return paths
+}
+
+// separatorOf returns the separator that an absolute path is written with
+func separatorOf(path string) string {
+	if strings.Contains(path, `\`) {
+		return `\`
+	}
+	return "/"
+}
+
+// machineSpecificOutput is an output that embeds a machine path
+type machineSpecificOutput struct {
+	file string
+	path machinePath
+}
+
+// findMachineSpecificOutputs returns the regular files among files that contain
+// any of paths, skipping skip, which is the task's log, and files that are too
+// large or binary
+func findMachineSpecificOutputs(files []string, skip string, paths []machinePath) ([]machineSpecificOutput, error) {
+	var found []machineSpecificOutput
+	for _, file := range files {
+		if file == skip {
+			continue
+		}
+		info, err := os.Lstat(file)
+		if err != nil {
+			return nil, err
+		}
+		if !info.Mode().IsRegular() || info.Size() > _maxScannedOutputSize {
+			continue
+		}
+		path, ok, err := scanForMachinePaths(file, paths)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			found = append(found, machineSpecificOutput{file: file, path: path})
+		}
+	}
+	return found, nil
+}
+
+// scanForMachinePaths returns the first of paths that the file contains, reading
+// it in chunks that overlap by enough for a path to span two of them. Nothing is
+// found in a binary file.
+func scanForMachinePaths(file string, paths []machinePath) (machinePath, bool, error) {
+	if len(paths) == 0 {
+		return machinePath{}, false, nil
+	}
+	overlap := 0
+	for _, path := range paths {
+		if len(path.needle) > overlap {
+			overlap = len(path.needle)
+		}
+	}
+	overlap--
+	f, err := os.Open(file)
+	if err != nil {
+		return machinePath{}, false, err
+	}
+	defer func() { _ = f.Close() }()
+	buf := make([]byte, overlap+_scanBufferSize)
+	kept := 0
+	for first := true; ; first = false {
+		n, err := io.ReadFull(f, buf[kept:])
+		window := buf[:kept+n]
+		if first && bytes.IndexByte(window[:minInt(len(window), _binarySniffSize)], 0) >= 0 {
+			return machinePath{}, false, nil
+		}
+		for _, path := range paths {
+			if bytes.Contains(window, path.needle) {
+				return path, true, nil
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return machinePath{}, false, nil
+		} else if err != nil {
+			return machinePath{}, false, err
+		}
+		kept = copy(buf, window[len(window)-overlap:])
+	}
+}
+
+// describeMachineSpecificOutputs lists the files that embed machine paths for a
+// warning, relative to the repository root
+func describeMachineSpecificOutputs(repoRoot turbopath.AbsoluteSystemPath, found []machineSpecificOutput) string {
+	descriptions := make([]string, 0, len(found))
+	for _, output := range found {
+		file := output.file
+		if relative, err := repoRoot.RelativePathString(file); err == nil {
+			file = filepath.ToSlash(relative)
+		}
+		descriptions = append(descriptions, fmt.Sprintf("%v (%v)", file, output.path.name))
+	}
+	sort.Strings(descriptions)
+	if len(descriptions) > _maxListedOutputs {
+		more := len(descriptions) - _maxListedOutputs
+		descriptions = append(descriptions[:_maxListedOutputs], fmt.Sprintf("%v more", more))
+	}
+	return strings.Join(descriptions, ", ")
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,64 @@
+package runcache
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"gotest.tools/v3/assert"
+)
+
+func TestFindMachineSpecificOutputs(t *testing.T) {
+	repoRoot := turbopath.AbsoluteSystemPathFromUpstream(t.TempDir())
+	paths := []machinePath{{name: "the repository root", needle: []byte(repoRoot.ToString() + string(filepath.Separator))}}
+	write := func(name string, contents string) string {
+		file := repoRoot.UntypedJoin(name)
+		assert.NilError(t, file.EnsureDir(), "EnsureDir")
+		assert.NilError(t, file.WriteFile([]byte(contents), 0644), "WriteFile")
+		return file.ToString()
+	}
+	clean := write("dist/clean.js", "require('./lib')")
+	embedded := write("dist/embedded.js", "require('"+repoRoot.UntypedJoin("src", "lib.js").ToString()+"')")
+	// The path spans two of the chunks that the file is read in, with only its last
+	// byte in the second
+	spanning := write("dist/spanning.js", strings.Repeat("x", _scanBufferSize)+repoRoot.UntypedJoin("a").ToString())
+	// The repository root alone, or a sibling directory that starts with it, is not a path within it
+	sibling := write("dist/sibling.js", repoRoot.ToString()+"-other/a "+repoRoot.ToString())
+	log := write(".turbo/turbo-build.log", repoRoot.UntypedJoin("src").ToString())
+	// Binary and large outputs aren't scanned
+	binary := write("dist/native.node", "\x00"+repoRoot.UntypedJoin("src").ToString())
+	large := write("dist/large.js", repoRoot.UntypedJoin("src").ToString()+strings.Repeat("x", _maxScannedOutputSize))
+
+	found, err := findMachineSpecificOutputs([]string{clean, embedded, spanning, sibling, log, binary, large}, log, paths)
+	assert.NilError(t, err)
+	assert.Equal(t, len(found), 2)
+	assert.Equal(t, found[0].file, embedded)
+	assert.Equal(t, found[1].file, spanning)
+	assert.Equal(t, describeMachineSpecificOutputs(repoRoot, found), "dist/embedded.js (the repository root), dist/spanning.js (the repository root)")
+}
+
+func TestDescribeMachineSpecificOutputsTruncates(t *testing.T) {
+	repoRoot := turbopath.AbsoluteSystemPathFromUpstream(t.TempDir())
+	var found []machineSpecificOutput
+	for _, name := range []string{"a", "b", "c", "d", "e", "f", "g"} {
+		found = append(found, machineSpecificOutput{file: repoRoot.UntypedJoin(name).ToString(), path: machinePath{name: "the repository root"}})
+	}
+	description := describeMachineSpecificOutputs(repoRoot, found)
+	assert.Assert(t, strings.HasSuffix(description, "e (the repository root), 2 more"), description)
+}
+
+func TestMachinePathsSkipsFilesystemRoot(t *testing.T) {
+	for _, path := range machinePaths(turbopath.AbsoluteSystemPath(string(filepath.Separator))) {
+		assert.Assert(t, string(path.needle) != string(filepath.Separator), path.name)
+	}
+}
+
+func TestMachinePathsOnlyRepoRoot(t *testing.T) {
+	repoRoot := turbopath.AbsoluteSystemPathFromUpstream(t.TempDir())
+	paths := machinePaths(repoRoot)
+	assert.Assert(t, len(paths) > 0)
+	for _, path := range paths {
+		assert.Equal(t, path.name, "the repository root")
+	}
+}
@@ -0,0 +1,119 @@
+package runcache
+
+import (
+	"context"
+	"sync"
+
+	"github.com/vercel/turbo/cli/internal/cache"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+// restorePool fetches the outputs of tasks from the cache ahead of the tasks, in
+// several workers, so that downloading, decompressing, and extracting the outputs of
+// cache hits overlap across tasks. A task whose outputs are being fetched waits for
+// them rather than fetching them again.
+type restorePool struct {
+	cache    cache.Cache
+	repoRoot turbopath.AbsoluteSystemPath
+	queue    chan *restore
+	wg       sync.WaitGroup
+
+	mu sync.Mutex
+	// restores are the restores queued or taken so far, by hash
+	restores map[string]*restore
+	stopped  bool
+}
+
+// restore is the fetch of the outputs of a single task
+type restore struct {
+	hash string
+	// claimed is set once a worker or the task itself fetches the outputs
+	claimed bool
+	// taken is set once the task has asked for its outputs
+	taken bool
+	done  chan struct{}
+
+	itemStatus cache.ItemStatus
+	duration   int
+	err        error
+}
+
+func newRestorePool(c cache.Cache, repoRoot turbopath.AbsoluteSystemPath, workers int) *restorePool {
+	p := &restorePool{
+		cache:    c,
+		repoRoot: repoRoot,
+		queue:    make(chan *restore),
+		restores: make(map[string]*restore),
+	}
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go p.run()
+	}
+	return p
+}
+
+// enqueue queues the outputs of the task with the given hash to be fetched, unless
+// they already were, or the task already fetched them. Returns false if ctx is
+// done before a worker is free.
+func (p *restorePool) enqueue(ctx context.Context, hash string) bool {
+	p.mu.Lock()
+	if _, ok := p.restores[hash]; ok || p.stopped {
+		p.mu.Unlock()
+		return true
+	}
+	r := &restore{hash: hash, done: make(chan struct{})}
+	p.restores[hash] = r
+	p.mu.Unlock()
+	select {
+	case p.queue <- r:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// take returns the restore of the outputs of the task with the given hash once it
+// is done, waiting for it if a worker is fetching them. Returns nil if the task has
+// to fetch them itself, in which case no worker will.
+func (p *restorePool) take(hash string) *restore {
+	p.mu.Lock()
+	r, ok := p.restores[hash]
+	if !ok {
+		p.restores[hash] = &restore{hash: hash, claimed: true, taken: true}
+		p.mu.Unlock()
+		return nil
+	}
+	if r.taken || !r.claimed {
+		r.claimed, r.taken = true, true
+		p.mu.Unlock()
+		return nil
+	}
+	r.taken = true
+	p.mu.Unlock()
+	<-r.done
+	return r
+}
+
+// stop waits for the fetches in progress, and drops the ones that haven't started
+func (p *restorePool) stop() {
+	p.mu.Lock()
+	p.stopped = true
+	p.mu.Unlock()
+	close(p.queue)
+	p.wg.Wait()
+}
+
+func (p *restorePool) run() {
+	defer p.wg.Done()
+	for r := range p.queue {
+		p.mu.Lock()
+		if r.claimed || p.stopped {
+			p.mu.Unlock()
+			continue
+		}
+		r.claimed = true
+		p.mu.Unlock()
+		r.itemStatus, _, r.duration, r.err = p.cache.Fetch(p.repoRoot, r.hash, nil)
+		close(r.done)
+	}
+}
@@ -0,0 +1,62 @@
+package runcache
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/cache"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"gotest.tools/v3/assert"
+)
+
+// blockingCache is a cache whose fetches hit, and wait for release
+type blockingCache struct {
+	cache.Cache
+	started chan string
+	release chan struct{}
+
+	mu      sync.Mutex
+	fetched []string
+}
+
+func (c *blockingCache) Fetch(anchor turbopath.AbsoluteSystemPath, hash string, files []string) (cache.ItemStatus, []turbopath.AnchoredSystemPath, int, error) {
+	c.mu.Lock()
+	c.fetched = append(c.fetched, hash)
+	c.mu.Unlock()
+	c.started <- hash
+	<-c.release
+	return cache.ItemStatus{Local: true}, nil, 42, nil
+}
+
+func TestRestorePoolOverlapsFetches(t *testing.T) {
+	c := &blockingCache{started: make(chan string, 2), release: make(chan struct{})}
+	p := newRestorePool(c, turbopath.AbsoluteSystemPath(""), 2)
+	ctx := context.Background()
+	assert.Assert(t, p.enqueue(ctx, "a"))
+	assert.Assert(t, p.enqueue(ctx, "b"))
+	// Both fetches are in progress before either is released
+	<-c.started
+	<-c.started
+	close(c.release)
+
+	r := p.take("a")
+	assert.Assert(t, r != nil)
+	assert.Assert(t, r.itemStatus.Hit())
+	assert.Equal(t, r.duration, 42)
+	// A second request for the same outputs fetches them again
+	assert.Assert(t, p.take("a") == nil)
+	p.stop()
+	assert.Equal(t, len(c.fetched), 2)
+}
+
+func TestRestorePoolSkipsTakenOutputs(t *testing.T) {
+	c := &blockingCache{started: make(chan string, 1), release: make(chan struct{})}
+	close(c.release)
+	p := newRestorePool(c, turbopath.AbsoluteSystemPath(""), 1)
+	// The task got to its outputs first, so they aren't fetched again
+	assert.Assert(t, p.take("a") == nil)
+	assert.Assert(t, p.enqueue(context.Background(), "a"))
+	p.stop()
+	assert.Equal(t, len(c.fetched), 0)
+}
@@ -7,14 +7,18 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/hashicorp/go-hclog"
 	"github.com/mitchellh/cli"
 	"github.com/spf13/pflag"
 	"github.com/vercel/turbo/cli/internal/cache"
+	"github.com/vercel/turbo/cli/internal/cacheitem"
 	"github.com/vercel/turbo/cli/internal/colorcache"
+	"github.com/vercel/turbo/cli/internal/errcode"
 	"github.com/vercel/turbo/cli/internal/fs"
 	"github.com/vercel/turbo/cli/internal/globby"
 	"github.com/vercel/turbo/cli/internal/logstreamer"
@@ -34,12 +38,20 @@ type Opts struct {
 	TaskOutputModeOverride *util.TaskOutputMode
 	LogReplayer            LogReplayer
 	OutputWatcher          OutputWatcher
+	// OnWarning is called with the warnings about tasks found while saving their
+	// outputs. If it is nil, they are printed.
+	OnWarning func(warning *errcode.Warning)
+	// ValidateOutputs is "warn" or "strict" to check that each outputs glob of a task
+	// matches some files, or empty to only warn when a task produced no outputs at all
+	ValidateOutputs string
 }
 
 // AddFlags adds the flags relevant to the runcache package to the given FlagSet
 func AddFlags(opts *Opts, flags *pflag.FlagSet) {
 	flags.BoolVar(&opts.SkipReads, "force", false, "Ignore the existing cache (to force execution).")
 	flags.BoolVar(&opts.SkipWrites, "no-cache", false, "Avoid saving task results to the cache. Useful for development/watch tasks.")
+	flags.BoolVar(&opts.SkipWrites, "cache-read-only", false, "Restore task results from the cache, but never save them. The same as --no-cache.")
+	flags.BoolVar(&opts.SkipReads, "cache-write-only", false, "Always execute tasks, but save their results to the cache. The same as --force.")
 
 	defaultTaskOutputMode, err := util.ToTaskOutputModeString(util.FullTaskOutput)
 	if err != nil {
@@ -51,11 +63,22 @@ func AddFlags(opts *Opts, flags *pflag.FlagSet) {
 		Usage: `Set type of process output logging. Use "full" to show
 all output. Use "hash-only" to show only turbo-computed
 task hashes. Use "new-only" to show only new output with
-only hashes for cached tasks. Use "none" to hide process
-output.`,
+only hashes for cached tasks. Use "errors-only" to show
+output only from failed tasks. Use "none" to hide process
+output. Tasks with an "outputMode" in turbo.json ignore
+this flag.`,
 		DefValue: defaultTaskOutputMode,
 		Value:    &taskOutputModeValue{opts: opts},
 	})
+	flags.AddFlag(&pflag.Flag{
+		Name: "validate-outputs",
+		Usage: `Check that each outputs glob of a task matches at least
+one file after it runs. Use "warn" to print a warning, or
+"strict" to fail the task. Defaults to "warn" when
+passed without a value.`,
+		NoOptDefVal: ValidateOutputsWarn,
+		Value:       &validateOutputsValue{opts: opts},
+	})
 	_ = flags.Bool("stream", true, "Unused")
 	if err := flags.MarkDeprecated("stream", "[WARNING] The --stream flag is unnecessary and has been deprecated. It will be removed in future versions of turbo."); err != nil {
 		// fail fast if we've misconfigured our flags
@@ -114,6 +137,12 @@ type RunCache struct {
 	logReplayer            LogReplayer
 	outputWatcher          OutputWatcher
 	colorCache             *colorcache.ColorCache
+	onWarning              func(warning *errcode.Warning)
+	validateOutputs        string
+	// machinePaths are the paths that make outputs specific to this machine
+	machinePaths []machinePath
+	// restores, if not nil, fetches the outputs of tasks ahead of them
+	restores *restorePool
 }
 
 // New returns a new instance of RunCache, wrapping the given cache
@@ -127,6 +156,9 @@ func New(cache cache.Cache, repoRoot turbopath.AbsoluteSystemPath, opts Opts, co
 		logReplayer:            opts.LogReplayer,
 		outputWatcher:          opts.OutputWatcher,
 		colorCache:             colorCache,
+		onWarning:              opts.OnWarning,
+		validateOutputs:        opts.ValidateOutputs,
+		machinePaths:           machinePaths(repoRoot),
 	}
 
 	if rc.logReplayer == nil {
@@ -138,6 +170,32 @@ func New(cache cache.Cache, repoRoot turbopath.AbsoluteSystemPath, opts Opts, co
 	return rc
 }
 
+// StartRestoring starts workers that fetch the outputs of the tasks queued with
+// RestoreAhead, so that restoring the outputs of cache hits overlaps across tasks
+func (rc *RunCache) StartRestoring(workers int) {
+	if workers > 0 && !rc.readsDisabled {
+		rc.restores = newRestorePool(rc.cache, rc.repoRoot, workers)
+	}
+}
+
+// StopRestoring waits for the outputs being fetched by the workers, and drops those
+// that haven't started. RestoreAhead must not be called afterwards.
+func (rc *RunCache) StopRestoring() {
+	if rc.restores != nil {
+		rc.restores.stop()
+		rc.restores = nil
+	}
+}
+
+// warn passes a warning to the OnWarning callback, or prints it if there is none
+func (rc *RunCache) warn(terminal cli.Ui, warning *errcode.Warning) {
+	if rc.onWarning != nil {
+		rc.onWarning(warning)
+		return
+	}
+	terminal.Warn(fmt.Sprintf("%s%s [%v]", ui.WARNING_PREFIX, color.YellowString(" %v", warning), warning.Code))
+}
+
 // TaskCache represents a single task's (package-task?) interface to the RunCache
 // and controls access to the task's outputs
 type TaskCache struct {
@@ -151,13 +209,14 @@ type TaskCache struct {
 }
 
 // RestoreOutputs attempts to restore output for the corresponding task from the cache.
-// Returns true if successful.
-func (tc TaskCache) RestoreOutputs(ctx context.Context, prefixedUI *cli.PrefixedUi, progressLogger hclog.Logger) (bool, error) {
+// Returns the cache it was restored from, which is a miss if it was not restored,
+// and how long the task took when its outputs were cached, if the cache knows.
+func (tc TaskCache) RestoreOutputs(ctx context.Context, prefixedUI *cli.PrefixedUi, progressLogger hclog.Logger) (cache.ItemStatus, time.Duration, error) {
 	if tc.cachingDisabled || tc.rc.readsDisabled {
-		if tc.taskOutputMode != util.NoTaskOutput {
+		if tc.showsStatus() {
 			prefixedUI.Output(fmt.Sprintf("cache bypass, force executing %s", ui.Dim(tc.hash)))
 		}
-		return false, nil
+		return cache.ItemStatus{}, 0, nil
 	}
 	changedOutputGlobs, err := tc.rc.outputWatcher.GetChangedOutputs(ctx, tc.hash, tc.repoRelativeGlobs.Inclusions)
 	if err != nil {
@@ -167,18 +226,20 @@ func (tc TaskCache) RestoreOutputs(ctx context.Context, prefixedUI *cli.Prefixed
 	}
 
 	hasChangedOutputs := len(changedOutputGlobs) > 0
+	var itemStatus cache.ItemStatus
+	var duration int
 	if hasChangedOutputs {
 		// Note that we currently don't use the output globs when restoring, but we could in the
 		// future to avoid doing unnecessary file I/O. We also need to pass along the exclusion
 		// globs as well.
-		hit, _, _, err := tc.rc.cache.Fetch(tc.rc.repoRoot, tc.hash, nil)
+		itemStatus, duration, err = tc.fetch()
 		if err != nil {
-			return false, err
-		} else if !hit {
-			if tc.taskOutputMode != util.NoTaskOutput {
+			return cache.ItemStatus{}, 0, err
+		} else if !itemStatus.Hit() {
+			if tc.showsStatus() {
 				prefixedUI.Output(fmt.Sprintf("cache miss, executing %s", ui.Dim(tc.hash)))
 			}
-			return false, nil
+			return cache.ItemStatus{}, 0, nil
 		}
 
 		if err := tc.rc.outputWatcher.NotifyOutputsWritten(ctx, tc.hash, tc.repoRelativeGlobs); err != nil {
@@ -187,6 +248,8 @@ func (tc TaskCache) RestoreOutputs(ctx context.Context, prefixedUI *cli.Prefixed
 		}
 	} else {
 		prefixedUI.Warn(fmt.Sprintf("Skipping cache check for %v, outputs have not changed since previous run.", tc.pt.TaskID))
+		// The outputs are already in place, as if they had been restored from the local cache
+		itemStatus = cache.ItemStatus{Local: true}
 	}
 
 	switch tc.taskOutputMode {
@@ -194,19 +257,88 @@ func (tc TaskCache) RestoreOutputs(ctx context.Context, prefixedUI *cli.Prefixed
 	case util.NewTaskOutput:
 		fallthrough
 	case util.HashTaskOutput:
-		prefixedUI.Info(fmt.Sprintf("cache hit, suppressing output %s", ui.Dim(tc.hash)))
+		prefixedUI.Info(fmt.Sprintf("cache hit (%s), suppressing output %s", itemStatus.Source(), ui.Dim(tc.hash)))
 	case util.FullTaskOutput:
 		progressLogger.Debug("log file", "path", tc.LogFileName)
-		prefixedUI.Info(fmt.Sprintf("cache hit, replaying output %s", ui.Dim(tc.hash)))
+		prefixedUI.Info(fmt.Sprintf("cache hit (%s), replaying output %s", itemStatus.Source(), ui.Dim(tc.hash)))
 		if tc.LogFileName.FileExists() {
 
 			tc.rc.logReplayer(progressLogger, prefixedUI, tc.LogFileName)
 		}
 	default:
-		// NoLogs, do not output anything
+		// NoLogs and ErrorLogs, do not output anything. A cache hit never failed.
 	}
 
-	return true, nil
+	return itemStatus, time.Duration(duration) * time.Millisecond, nil
+}
+
+// fetch restores the task's outputs from the cache, or waits for a worker that is
+// already restoring them
+func (tc TaskCache) fetch() (cache.ItemStatus, int, error) {
+	if tc.rc.restores != nil {
+		if r := tc.rc.restores.take(tc.hash); r != nil {
+			return r.itemStatus, r.duration, r.err
+		}
+	}
+	itemStatus, _, duration, err := tc.rc.cache.Fetch(tc.rc.repoRoot, tc.hash, nil)
+	return itemStatus, duration, err
+}
+
+// RestoreAhead queues the task's outputs to be restored by the workers started with
+// StartRestoring, before the task itself asks for them. Returns true if the outputs
+// are in the cache, or already in place.
+func (tc TaskCache) RestoreAhead(ctx context.Context) (bool, error) {
+	if tc.rc.restores == nil || tc.cachingDisabled {
+		return false, nil
+	}
+	changedOutputGlobs, err := tc.rc.outputWatcher.GetChangedOutputs(ctx, tc.hash, tc.repoRelativeGlobs.Inclusions)
+	if err == nil && len(changedOutputGlobs) == 0 {
+		return true, nil
+	}
+	itemStatus, err := tc.rc.cache.Exists(tc.hash)
+	if err != nil || !itemStatus.Hit() {
+		return false, err
+	}
+	return tc.rc.restores.enqueue(ctx, tc.hash), nil
+}
+
+// Prefetch restores the task's outputs ahead of time if they are only available from
+// the remote cache, so that a later run of the task is a local cache hit. Nothing is
+// printed. Returns the cache the outputs were restored from, which is a miss if they
+// were not restored.
+func (tc TaskCache) Prefetch(ctx context.Context) (cache.ItemStatus, error) {
+	if tc.cachingDisabled || tc.rc.readsDisabled {
+		return cache.ItemStatus{}, nil
+	}
+	itemStatus, err := tc.rc.cache.Exists(tc.hash)
+	if err != nil {
+		return cache.ItemStatus{}, err
+	}
+	if itemStatus.Local || !itemStatus.Remote {
+		// Restoring from the local cache is already fast
+		return cache.ItemStatus{}, nil
+	}
+	itemStatus, _, _, err = tc.rc.cache.Fetch(tc.rc.repoRoot, tc.hash, nil)
+	if err != nil || !itemStatus.Hit() {
+		return cache.ItemStatus{}, err
+	}
+	if err := tc.rc.outputWatcher.NotifyOutputsWritten(ctx, tc.hash, tc.repoRelativeGlobs); err != nil {
+		return cache.ItemStatus{}, err
+	}
+	return itemStatus, nil
+}
+
+// showsStatus returns true if cache status messages should be shown for this task
+func (tc TaskCache) showsStatus() bool {
+	return tc.taskOutputMode != util.NoTaskOutput && tc.taskOutputMode != util.ErrorTaskOutput
+}
+
+// ReplayFailedOutput shows the logs of a failed task whose output was hidden by
+// the errors-only output mode. The task's OutputWriter must be closed first.
+func (tc TaskCache) ReplayFailedOutput(logger hclog.Logger, prefixedUI *cli.PrefixedUi) {
+	if tc.taskOutputMode == util.ErrorTaskOutput && tc.LogFileName.FileExists() {
+		tc.rc.logReplayer(logger, prefixedUI, tc.LogFileName)
+	}
 }
 
 // nopWriteCloser is modeled after io.NopCloser, which is for Readers
@@ -230,12 +362,13 @@ func (fwc *fileWriterCloser) Close() error {
 }
 
 // OutputWriter creates a sink suitable for handling the output of the command associated
-// with this task.
-func (tc TaskCache) OutputWriter(prefix string) (io.WriteCloser, error) {
-	// an os.Stdout wrapper that will add prefixes before printing to stdout
-	stdoutWriter := logstreamer.NewPrettyStdoutWriter(prefix)
+// with this task. Output that is shown is written to stdout, with the given prefix.
+func (tc TaskCache) OutputWriter(prefix string, stdout io.Writer) (io.WriteCloser, error) {
+	// a stdout wrapper that will add prefixes before printing
+	stdoutWriter := logstreamer.NewPrettyWriter(stdout, prefix)
 
-	if tc.cachingDisabled || tc.rc.writesDisabled {
+	// errors-only still needs the log file, to show the output if the task fails
+	if (tc.cachingDisabled || tc.rc.writesDisabled) && tc.taskOutputMode != util.ErrorTaskOutput {
 		return nopWriteCloser{stdoutWriter}, nil
 	}
 	// Setup log file
@@ -253,7 +386,7 @@ func (tc TaskCache) OutputWriter(prefix string) (io.WriteCloser, error) {
 		file:  output,
 		bufio: bufWriter,
 	}
-	if tc.taskOutputMode == util.NoTaskOutput || tc.taskOutputMode == util.HashTaskOutput {
+	if tc.taskOutputMode == util.NoTaskOutput || tc.taskOutputMode == util.HashTaskOutput || tc.taskOutputMode == util.ErrorTaskOutput {
 		// only write to log file, not to stdout
 		fwc.Writer = bufWriter
 	} else {
@@ -265,17 +398,160 @@ func (tc TaskCache) OutputWriter(prefix string) (io.WriteCloser, error) {
 
 var _emptyIgnore []string
 
-// SaveOutputs is responsible for saving the outputs of task to the cache, after the task has completed
-func (tc TaskCache) SaveOutputs(ctx context.Context, logger hclog.Logger, terminal cli.Ui, duration int) error {
-	if tc.cachingDisabled || tc.rc.writesDisabled {
-		return nil
+// OutputSizeError is returned by SaveOutputs when the outputs of a task are larger
+// than its maxOutputSize, in which case they are not saved to the cache
+type OutputSizeError struct {
+	TaskID string
+	Size   int64
+	Limit  int64
+}
+
+func (e *OutputSizeError) Error() string {
+	return fmt.Sprintf("outputs of %v are %v, which exceeds the maxOutputSize of %v", e.TaskID, util.FormatByteSize(e.Size), util.FormatByteSize(e.Limit))
+}
+
+// outputSize returns the total size of the regular files among the given paths
+func outputSize(files []string) (int64, error) {
+	var size int64
+	for _, file := range files {
+		info, err := os.Lstat(file)
+		if err != nil {
+			return 0, err
+		}
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+	}
+	return size, nil
+}
+
+// collapseLinkedOutputs replaces the paths among files that were reached through
+// a symlinked directory within root with the symlink itself, so that the cache
+// records the link rather than copies of what it pointed to. The result is sorted.
+func collapseLinkedOutputs(root string, files []string) ([]string, error) {
+	isLink := make(map[string]bool)
+	collapsed := make(util.Set)
+	for _, file := range files {
+		outermost := file
+		for dir := filepath.Dir(file); dir != root && strings.HasPrefix(dir, root); dir = filepath.Dir(dir) {
+			link, ok := isLink[dir]
+			if !ok {
+				info, err := os.Lstat(dir)
+				if err != nil {
+					return nil, err
+				}
+				link = info.Mode()&os.ModeSymlink != 0
+				isLink[dir] = link
+			}
+			if link {
+				outermost = dir
+			}
+		}
+		collapsed.Add(outermost)
+	}
+	result := collapsed.UnsafeListOfStrings()
+	sort.Strings(result)
+	return result, nil
+}
+
+// checkLinkTargets returns an error for the first symlink among files whose target
+// is an absolute path, which the cache refuses to store
+func checkLinkTargets(repoRoot turbopath.AbsoluteSystemPath, files []string) error {
+	for _, file := range files {
+		info, err := os.Lstat(file)
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			continue
+		}
+		target, err := os.Readlink(file)
+		if err != nil {
+			return err
+		}
+		if cacheitem.IsAbsoluteLinkTarget(target) {
+			relativePath, err := repoRoot.RelativePathString(file)
+			if err != nil {
+				relativePath = file
+			}
+			return fmt.Errorf("%v -> %v: %w", relativePath, target, cacheitem.ErrAbsoluteLinkTarget)
+		}
+	}
+	return nil
+}
+
+// SaveOutputs is responsible for saving the outputs of task to the cache, after the task has completed.
+// It returns the total size of the outputs, or zero if caching is disabled.
+// If the outputs exceed the task's maxOutputSize, nothing is saved and an *OutputSizeError is returned.
+// If they embed absolute paths of this machine, nothing is saved, unless the task allows it, and
+// ErrMachineSpecificOutputs is returned once the warning is reported.
+// With --validate-outputs=strict, if some outputs globs match no files, nothing is saved and
+// a *MissingOutputsError is returned.
+func (tc TaskCache) SaveOutputs(ctx context.Context, logger hclog.Logger, terminal cli.Ui, duration int) (int64, error) {
+	if tc.cachingDisabled {
+		return 0, nil
+	}
+	if tc.rc.validateOutputs != "" {
+		unmatched, err := tc.unmatchedOutputs()
+		if err != nil {
+			return 0, err
+		}
+		if len(unmatched) > 0 {
+			missingErr := &MissingOutputsError{TaskID: tc.pt.TaskID, Globs: unmatched}
+			if tc.rc.validateOutputs == ValidateOutputsStrict {
+				return 0, missingErr
+			}
+			tc.rc.warn(terminal, errcode.NewWarning(errcode.MissingOutputs, missingErr))
+		}
+	}
+	if tc.rc.writesDisabled {
+		return 0, nil
 	}
 
 	logger.Debug("caching output", "outputs", tc.repoRelativeGlobs)
 
 	filesToBeCached, err := globby.GlobAll(tc.rc.repoRoot.ToStringDuringMigration(), tc.repoRelativeGlobs.Inclusions, tc.repoRelativeGlobs.Exclusions)
 	if err != nil {
-		return err
+		return 0, err
+	}
+	filesToBeCached, err = collapseLinkedOutputs(tc.rc.repoRoot.ToStringDuringMigration(), filesToBeCached)
+	if err != nil {
+		return 0, err
+	}
+	if err := checkLinkTargets(tc.rc.repoRoot, filesToBeCached); err != nil {
+		return 0, err
+	}
+
+	// --validate-outputs already checked each glob
+	if tc.rc.validateOutputs == "" && tc.pt.TaskDefinition.HasOutputs && len(tc.pt.TaskDefinition.Outputs.Inclusions) > 0 {
+		logFile := tc.rc.repoRoot.UntypedJoin(tc.pt.RepoRelativeLogFile()).ToString()
+		if len(filesToBeCached) == 0 || (len(filesToBeCached) == 1 && filesToBeCached[0] == logFile) {
+			tc.rc.warn(terminal, errcode.NewWarning(errcode.MissingOutputs, fmt.Errorf("%v produced none of its outputs (%v)", tc.pt.TaskID, strings.Join(tc.pt.TaskDefinition.Outputs.Inclusions, ", "))))
+		}
+	}
+
+	size, err := outputSize(filesToBeCached)
+	if err != nil {
+		return 0, err
+	}
+	if limit := tc.pt.TaskDefinition.MaxOutputSize; limit > 0 && size > limit {
+		return size, &OutputSizeError{TaskID: tc.pt.TaskID, Size: size, Limit: limit}
+	}
+	if action := tc.pt.TaskDefinition.MachineSpecificOutputs; action != fs.MachineSpecificOutputsAllow {
+		logFile := tc.rc.repoRoot.UntypedJoin(tc.pt.RepoRelativeLogFile()).ToString()
+		found, err := findMachineSpecificOutputs(filesToBeCached, logFile, tc.rc.machinePaths)
+		if err != nil {
+			return size, err
+		}
+		if len(found) > 0 {
+			files := describeMachineSpecificOutputs(tc.rc.repoRoot, found)
+			if action != fs.MachineSpecificOutputsSkip {
+				tc.rc.warn(terminal, errcode.NewWarning(errcode.MachineSpecificOutputs, fmt.Errorf("outputs of %v embed absolute paths of this machine, and will be wrong when restored elsewhere: %v", tc.pt.TaskID, files)))
+			} else {
+				tc.rc.warn(terminal, errcode.NewWarning(errcode.MachineSpecificOutputs, fmt.Errorf("not caching %v, because its outputs embed absolute paths of this machine, and would be wrong when restored elsewhere: %v. Set \"machineSpecificOutputs\" to \"allow\" for the task in turbo.json to cache them anyway", tc.pt.TaskID, files)))
+				return size, ErrMachineSpecificOutputs
+			}
+		}
 	}
 
 	relativePaths := make([]turbopath.AnchoredSystemPath, len(filesToBeCached))
@@ -291,7 +567,7 @@ func (tc TaskCache) SaveOutputs(ctx context.Context, logger hclog.Logger, termin
 	}
 
 	if err = tc.rc.cache.Put(tc.rc.repoRoot, tc.hash, duration, relativePaths); err != nil {
-		return err
+		return size, err
 	}
 	err = tc.rc.outputWatcher.NotifyOutputsWritten(ctx, tc.hash, tc.repoRelativeGlobs)
 	if err != nil {
@@ -300,13 +576,17 @@ func (tc TaskCache) SaveOutputs(ctx context.Context, logger hclog.Logger, termin
 		logger.Warn(fmt.Sprintf("Failed to mark outputs as cached for %v: %v", tc.pt.TaskID, err))
 		terminal.Warn(ui.Dim(fmt.Sprintf("Failed to mark outputs as cached for %v: %v", tc.pt.TaskID, err)))
 	}
-	return nil
+	return size, nil
 }
 
 // TaskCache returns a TaskCache instance, providing an interface to the underlying cache specific
 // to this run and the given PackageTask
 func (rc *RunCache) TaskCache(pt *nodes.PackageTask, hash string) TaskCache {
 	logFileName := rc.repoRoot.UntypedJoin(pt.RepoRelativeLogFile())
+	if logDir, ok := fs.WritableDir(rc.repoRoot, logFileName.Dir()); !ok {
+		// The package is on a read-only mount
+		logFileName = logDir.UntypedJoin(logFileName.Base())
+	}
 	hashableOutputs := pt.HashableOutputs()
 	repoRelativeGlobs := fs.TaskOutputs{
 		Inclusions: make([]string, len(hashableOutputs.Inclusions)),
@@ -320,8 +600,9 @@ func (rc *RunCache) TaskCache(pt *nodes.PackageTask, hash string) TaskCache {
 		repoRelativeGlobs.Exclusions[index] = filepath.Join(pt.Pkg.Dir.ToStringDuringMigration(), output)
 	}
 
+	// An outputMode set for the task in turbo.json takes precedence over --output-logs
 	taskOutputMode := pt.TaskDefinition.OutputMode
-	if rc.taskOutputModeOverride != nil {
+	if rc.taskOutputModeOverride != nil && !pt.TaskDefinition.HasOutputMode {
 		taskOutputMode = *rc.taskOutputModeOverride
 	}
 
@@ -0,0 +1,45 @@
+package runcache
+
+import (
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/cacheitem"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"gotest.tools/v3/assert"
+)
+
+func TestCollapseLinkedOutputs(t *testing.T) {
+	repoRoot := turbopath.AbsoluteSystemPathFromUpstream(t.TempDir())
+	target := repoRoot.UntypedJoin("dist", "real", "sub", "x.js")
+	assert.NilError(t, target.EnsureDir(), "EnsureDir")
+	assert.NilError(t, target.WriteFile([]byte("x"), 0644), "WriteFile")
+	link := repoRoot.UntypedJoin("dist", "link")
+	assert.NilError(t, link.Symlink("real"), "Symlink")
+	fileLink := repoRoot.UntypedJoin("dist", "x.js")
+	assert.NilError(t, fileLink.Symlink("real/sub/x.js"), "Symlink")
+
+	// Outputs reached through the symlinked directory are replaced by the link
+	files := []string{
+		repoRoot.UntypedJoin("dist", "link", "sub").ToString(),
+		repoRoot.UntypedJoin("dist", "link", "sub", "x.js").ToString(),
+		target.ToString(),
+		fileLink.ToString(),
+	}
+	collapsed, err := collapseLinkedOutputs(repoRoot.ToString(), files)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, collapsed, []string{link.ToString(), target.ToString(), fileLink.ToString()})
+}
+
+func TestCheckLinkTargets(t *testing.T) {
+	repoRoot := turbopath.AbsoluteSystemPathFromUpstream(t.TempDir())
+	relative := repoRoot.UntypedJoin("dist", "relative")
+	assert.NilError(t, relative.EnsureDir(), "EnsureDir")
+	assert.NilError(t, relative.Symlink("../src"), "Symlink")
+	assert.NilError(t, checkLinkTargets(repoRoot, []string{relative.ToString()}))
+
+	absolute := repoRoot.UntypedJoin("dist", "absolute")
+	assert.NilError(t, absolute.Symlink("/etc/hosts"), "Symlink")
+	err := checkLinkTargets(repoRoot, []string{relative.ToString(), absolute.ToString()})
+	assert.ErrorIs(t, err, cacheitem.ErrAbsoluteLinkTarget)
+	assert.ErrorContains(t, err, "absolute -> /etc/hosts")
+}
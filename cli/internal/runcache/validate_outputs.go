@@ -0,0 +1,69 @@
+package runcache
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"github.com/vercel/turbo/cli/internal/globby"
+)
+
+// Values for --validate-outputs
+const (
+	// ValidateOutputsWarn warns about outputs globs that matched no files
+	ValidateOutputsWarn = "warn"
+	// ValidateOutputsStrict fails tasks with outputs globs that matched no files
+	ValidateOutputsStrict = "strict"
+)
+
+// MissingOutputsError is returned by SaveOutputs with --validate-outputs=strict when
+// some of the outputs globs of a task matched no files, in which case nothing is saved
+type MissingOutputsError struct {
+	TaskID string
+	Globs  []string
+}
+
+func (e *MissingOutputsError) Error() string {
+	return fmt.Sprintf("%v produced no files matching %v", e.TaskID, strings.Join(e.Globs, ", "))
+}
+
+type validateOutputsValue struct {
+	opts *Opts
+}
+
+func (v *validateOutputsValue) String() string {
+	return v.opts.ValidateOutputs
+}
+
+func (v *validateOutputsValue) Set(value string) error {
+	switch value {
+	case ValidateOutputsWarn, ValidateOutputsStrict:
+		v.opts.ValidateOutputs = value
+		return nil
+	}
+	return fmt.Errorf("must be one of \"%v\"", v.Type())
+}
+
+func (v *validateOutputsValue) Type() string {
+	return ValidateOutputsWarn + "|" + ValidateOutputsStrict
+}
+
+var _ pflag.Value = &validateOutputsValue{}
+
+// unmatchedOutputs returns the outputs globs of the task, relative to its workspace,
+// that match no files once the exclusions are applied. The log file isn't one of them.
+func (tc TaskCache) unmatchedOutputs() ([]string, error) {
+	var unmatched []string
+	for _, output := range tc.pt.TaskDefinition.Outputs.Inclusions {
+		glob := filepath.Join(tc.pt.Pkg.Dir.ToStringDuringMigration(), output)
+		files, err := globby.GlobAll(tc.rc.repoRoot.ToStringDuringMigration(), []string{glob}, tc.repoRelativeGlobs.Exclusions)
+		if err != nil {
+			return nil, err
+		}
+		if len(files) == 0 {
+			unmatched = append(unmatched, output)
+		}
+	}
+	return unmatched, nil
+}
@@ -0,0 +1,41 @@
+package runcache
+
+import (
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/nodes"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"gotest.tools/v3/assert"
+)
+
+func TestUnmatchedOutputs(t *testing.T) {
+	repoRoot := turbopath.AbsoluteSystemPathFromUpstream(t.TempDir())
+	for _, name := range []string{"packages/a/dist/index.js", "packages/a/dist/index.js.map", "packages/a/.turbo/turbo-build.log"} {
+		file := repoRoot.UntypedJoin(name)
+		assert.NilError(t, file.EnsureDir(), "EnsureDir")
+		assert.NilError(t, file.WriteFile([]byte("contents"), 0644), "WriteFile")
+	}
+	pt := &nodes.PackageTask{
+		TaskID: "a#build",
+		Task:   "build",
+		Pkg:    &fs.PackageJSON{Dir: turbopath.AnchoredUnixPath("packages/a").ToSystemPath()},
+		TaskDefinition: &fs.TaskDefinition{
+			// Only the excluded source maps match "dist/**/*.map", and nothing matches "lib/**"
+			Outputs: fs.TaskOutputs{Inclusions: []string{"dist/**", "dist/**/*.map", "lib/**"}, Exclusions: []string{"dist/**/*.map"}},
+		},
+	}
+	rc := New(nil, repoRoot, Opts{ValidateOutputs: ValidateOutputsStrict}, nil)
+	unmatched, err := rc.TaskCache(pt, "the-hash").unmatchedOutputs()
+	assert.NilError(t, err, "unmatchedOutputs")
+	assert.DeepEqual(t, unmatched, []string{"dist/**/*.map", "lib/**"})
+	assert.Error(t, (&MissingOutputsError{TaskID: pt.TaskID, Globs: unmatched}), "a#build produced no files matching dist/**/*.map, lib/**")
+}
+
+func TestValidateOutputsValue(t *testing.T) {
+	opts := &Opts{}
+	value := &validateOutputsValue{opts: opts}
+	assert.NilError(t, value.Set("strict"))
+	assert.Equal(t, opts.ValidateOutputs, ValidateOutputsStrict)
+	assert.ErrorContains(t, value.Set("error"), `must be one of "warn|strict"`)
+}
@@ -21,44 +21,44 @@ type git struct {
 }
 
 // ChangedFiles returns a list of modified files since the given commit, optionally including untracked files.
+// Committed changes are found from the merge base of fromCommit and toCommit, so that only the changes on
+// toCommit's branch are found, even if fromCommit has moved ahead. Uncommitted and untracked files are only
+// changes if toCommit is HEAD, the commit that the work tree is based on.
 func (g *git) ChangedFiles(fromCommit string, toCommit string, includeUntracked bool, relativeTo string) ([]string, error) {
 	if relativeTo == "" {
 		relativeTo = g.repoRoot
 	}
 	relSuffix := []string{"--", relativeTo}
-	command := []string{"diff", "--name-only", toCommit}
+	includeWorkTree := toCommit == "HEAD"
+	var files []string
 
-	out, err := exec.Command("git", append(command, relSuffix...)...).CombinedOutput()
-	if err != nil {
-		return nil, errors.Wrapf(err, "finding changes relative to %v", relativeTo)
+	if includeWorkTree {
+		command := []string{"diff", "--name-only", toCommit}
+		out, err := g.command(append(command, relSuffix...)...).CombinedOutput()
+		if err != nil {
+			return nil, errors.Wrapf(err, "finding changes relative to %v", relativeTo)
+		}
+		files = append(files, strings.Split(string(out), "\n")...)
 	}
-	files := strings.Split(string(out), "\n")
-
 	if fromCommit != "" {
-		// Grab the diff from the merge-base to HEAD using ... syntax.  This ensures we have just
-		// the changes that have occurred on the current branch.
-		command = []string{"diff", "--name-only", fromCommit + "..." + toCommit}
-		out, err = exec.Command("git", append(command, relSuffix...)...).CombinedOutput()
+		mergeBase, err := g.mergeBase(fromCommit, toCommit)
+		if err != nil {
+			return nil, err
+		}
+		command := []string{"diff", "--name-only", mergeBase, toCommit}
+		out, err := g.command(append(command, relSuffix...)...).CombinedOutput()
 		if err != nil {
-			// Check if we can provide a better error message for non-existent commits.
-			// If we error on the check or can't find it, fall back to whatever error git
-			// reported.
-			if exists, err := commitExists(fromCommit); err == nil && !exists {
-				return nil, fmt.Errorf("commit %v does not exist", fromCommit)
-			}
 			return nil, errors.Wrapf(err, "git comparing with %v", fromCommit)
 		}
-		committedChanges := strings.Split(string(out), "\n")
-		files = append(files, committedChanges...)
+		files = append(files, strings.Split(string(out), "\n")...)
 	}
-	if includeUntracked {
-		command = []string{"ls-files", "--other", "--exclude-standard"}
-		out, err = exec.Command("git", append(command, relSuffix...)...).CombinedOutput()
+	if includeUntracked && includeWorkTree {
+		command := []string{"ls-files", "--other", "--exclude-standard"}
+		out, err := g.command(append(command, relSuffix...)...).CombinedOutput()
 		if err != nil {
 			return nil, errors.Wrap(err, "finding untracked files")
 		}
-		untracked := strings.Split(string(out), "\n")
-		files = append(files, untracked...)
+		files = append(files, strings.Split(string(out), "\n")...)
 	}
 	// git will report changed files relative to the worktree: re-relativize to relativeTo
 	normalized := make([]string, 0)
@@ -75,8 +75,47 @@ func (g *git) ChangedFiles(fromCommit string, toCommit string, includeUntracked
 	return normalized, nil
 }
 
-func commitExists(commit string) (bool, error) {
-	err := exec.Command("git", "cat-file", "-t", commit).Run()
+// command returns a git command that runs from the repository root, regardless of
+// the directory turbo was invoked from.
+func (g *git) command(args ...string) *exec.Cmd {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = g.repoRoot
+	return cmd
+}
+
+// mergeBase returns the best common ancestor of two commits
+func (g *git) mergeBase(a string, b string) (string, error) {
+	out, err := g.command("merge-base", a, b).Output()
+	if err == nil {
+		return strings.TrimSpace(string(out)), nil
+	}
+	// Check if we can provide a better error message for non-existent commits.
+	// If we error on the check or can't find it, fall back to whatever error git
+	// reported.
+	for _, commit := range []string{a, b} {
+		if exists, err := g.commitExists(commit); err == nil && !exists {
+			return "", fmt.Errorf("commit %v does not exist", commit)
+		}
+	}
+	exitErr := &exec.ExitError{}
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 1 {
+		return "", errors.Wrapf(err, "finding the merge base of %v and %v", a, b)
+	}
+	if g.isShallow() {
+		return "", fmt.Errorf("%v and %v have no common ancestor in this shallow clone. Fetch more history, such as with `git fetch --deepen=<depth>`, or `fetch-depth: 0` in GitHub Actions", a, b)
+	}
+	return "", fmt.Errorf("%v and %v have no common ancestor", a, b)
+}
+
+// isShallow returns true if the repository is a shallow clone, which is missing the
+// history before some commits
+func (g *git) isShallow() bool {
+	out, err := g.command("rev-parse", "--is-shallow-repository").Output()
+	return err == nil && strings.TrimSpace(string(out)) == "true"
+}
+
+func (g *git) commitExists(commit string) (bool, error) {
+	err := g.command("cat-file", "-t", commit).Run()
 	if err != nil {
 		exitErr := &exec.ExitError{}
 		if errors.As(err, &exitErr) && exitErr.ExitCode() == 128 {
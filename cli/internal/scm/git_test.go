@@ -0,0 +1,69 @@
+package scm
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func gitCmd(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com", "-c", "commit.gpgsign=false"}, args...)...)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v failed: %v %v", args, err, string(out))
+	}
+}
+
+func writeFile(t *testing.T, dir string, name string, contents string) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	assert.NilError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	assert.NilError(t, os.WriteFile(path, []byte(contents), 0644))
+}
+
+func changedFiles(t *testing.T, g *git, fromCommit string, toCommit string) []string {
+	t.Helper()
+	files, err := g.ChangedFiles(fromCommit, toCommit, true, "")
+	assert.NilError(t, err, "ChangedFiles")
+	sort.Strings(files)
+	return files
+}
+
+func TestChangedFilesFromMergeBase(t *testing.T) {
+	repoRoot := t.TempDir()
+	gitCmd(t, repoRoot, "init", "--initial-branch=main")
+	writeFile(t, repoRoot, "ui/index.ts", "export {}")
+	writeFile(t, repoRoot, "web/index.ts", "export {}")
+	gitCmd(t, repoRoot, "add", ".")
+	gitCmd(t, repoRoot, "commit", "-m", "initial")
+
+	// my-feature changes ui, and then main moves ahead with a change to web
+	gitCmd(t, repoRoot, "checkout", "-b", "my-feature")
+	writeFile(t, repoRoot, "ui/index.ts", "export const button = 1")
+	gitCmd(t, repoRoot, "commit", "-am", "change ui")
+	gitCmd(t, repoRoot, "checkout", "main")
+	writeFile(t, repoRoot, "web/index.ts", "export const page = 1")
+	gitCmd(t, repoRoot, "commit", "-am", "change web")
+	gitCmd(t, repoRoot, "checkout", "my-feature")
+	writeFile(t, repoRoot, "api/index.ts", "export {}")
+
+	g := &git{repoRoot: repoRoot}
+	assert.DeepEqual(t, changedFiles(t, g, "main", "HEAD"), []string{filepath.Join("api", "index.ts"), filepath.Join("ui", "index.ts")})
+	// Uncommitted changes aren't part of a range that ends at another commit
+	gitCmd(t, repoRoot, "checkout", "-b", "other")
+	assert.DeepEqual(t, changedFiles(t, g, "main", "my-feature"), []string{filepath.Join("ui", "index.ts")})
+	assert.DeepEqual(t, changedFiles(t, g, "my-feature", "main"), []string{filepath.Join("web", "index.ts")})
+
+	_, err := g.ChangedFiles("no-such-branch", "HEAD", true, "")
+	assert.ErrorContains(t, err, "commit no-such-branch does not exist")
+
+	gitCmd(t, repoRoot, "checkout", "--orphan", "unrelated")
+	gitCmd(t, repoRoot, "commit", "-m", "unrelated")
+	_, err = g.ChangedFiles("main", "HEAD", true, "")
+	assert.ErrorContains(t, err, "main and HEAD have no common ancestor")
+}
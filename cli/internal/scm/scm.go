@@ -7,7 +7,9 @@
 package scm
 
 import (
+	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/pkg/errors"
 
@@ -26,12 +28,37 @@ type SCM interface {
 // newGitSCM returns a new SCM instance for this repo root.
 // It returns nil if there is no known implementation there.
 func newGitSCM(repoRoot string) SCM {
-	if fs.PathExists(filepath.Join(repoRoot, ".git")) {
+	if isGitDir(filepath.Join(repoRoot, ".git")) {
 		return &git{repoRoot: repoRoot}
 	}
 	return nil
 }
 
+// isGitDir returns true if dotGit is a .git directory, or a .git file pointing at
+// one. Linked worktrees and submodules use a file containing "gitdir: <path>".
+func isGitDir(dotGit string) bool {
+	info, err := os.Stat(dotGit)
+	if err != nil {
+		return false
+	}
+	if info.IsDir() {
+		return true
+	}
+	contents, err := os.ReadFile(dotGit)
+	if err != nil {
+		return false
+	}
+	line := strings.TrimSpace(string(contents))
+	if !strings.HasPrefix(line, "gitdir:") {
+		return false
+	}
+	gitDir := strings.TrimSpace(strings.TrimPrefix(line, "gitdir:"))
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(filepath.Dir(dotGit), gitDir)
+	}
+	return fs.IsDirectory(gitDir)
+}
+
 // newFallback returns a new SCM instance for this repo root.
 // If there is no known implementation it returns a stub.
 func newFallback(repoRoot string) (SCM, error) {
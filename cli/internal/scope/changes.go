@@ -0,0 +1,132 @@
+package scope
+
+import (
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/pyr-sh/dag"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// Reasons that a filter on changed files, such as --since, selects a package
+const (
+	// ChangeReasonOwnFiles means files in the package's directory changed
+	ChangeReasonOwnFiles = "ownFiles"
+	// ChangeReasonDependency means files of a workspace that the package depends on changed
+	ChangeReasonDependency = "dependency"
+	// ChangeReasonLockfile means the lockfile changed, which affects every package
+	ChangeReasonLockfile = "lockfile"
+	// ChangeReasonGlobalDependency means turbo.json, the root package.json, or one of
+	// --global-deps changed, which affects every package
+	ChangeReasonGlobalDependency = "globalDependency"
+)
+
+// ChangeReason is one of the reasons that a package is considered changed
+type ChangeReason struct {
+	Reason string `json:"reason"`
+	// Dependency is the changed workspace that the package depends on, for ChangeReasonDependency
+	Dependency string `json:"dependency,omitempty"`
+	// Files are the changed files responsible, relative to the repository root
+	Files []string `json:"files"`
+}
+
+// ChangeReasons are the reasons that each package selected by a filter on changed
+// files is considered changed, by package name
+type ChangeReasons map[string][]ChangeReason
+
+// changeTracker records the changed files found while resolving filters, which can
+// look at several ranges of git refs
+type changeTracker struct {
+	mu sync.Mutex
+	// packageFiles are the changed files in the directory of each package
+	packageFiles map[interface{}]util.Set
+	lockfile     util.Set
+	globalDeps   util.Set
+}
+
+func newChangeTracker() *changeTracker {
+	return &changeTracker{
+		packageFiles: make(map[interface{}]util.Set),
+		lockfile:     make(util.Set),
+		globalDeps:   make(util.Set),
+	}
+}
+
+func (ct *changeTracker) recordGlobal(files []string, lockfile string) {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	for _, file := range files {
+		if lockfile != "" && filepath.ToSlash(file) == filepath.ToSlash(lockfile) {
+			ct.lockfile.Add(filepath.ToSlash(file))
+		} else {
+			ct.globalDeps.Add(filepath.ToSlash(file))
+		}
+	}
+}
+
+func (ct *changeTracker) recordPackages(packageFiles map[interface{}][]string) {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	for pkg, files := range packageFiles {
+		if ct.packageFiles[pkg] == nil {
+			ct.packageFiles[pkg] = make(util.Set)
+		}
+		for _, file := range files {
+			ct.packageFiles[pkg].Add(filepath.ToSlash(file))
+		}
+	}
+}
+
+// reasons explains why each of the selected packages is considered changed. Packages
+// that were selected for other reasons, such as being a dependency of a changed
+// package, have no reasons. It is nil if no filter looked at changed files.
+func (ct *changeTracker) reasons(selected util.Set, graph *dag.AcyclicGraph, packageInfos map[interface{}]*fs.PackageJSON) (ChangeReasons, error) {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	if len(ct.packageFiles) == 0 && ct.lockfile.Len() == 0 && ct.globalDeps.Len() == 0 {
+		return nil, nil
+	}
+	reasons := make(ChangeReasons)
+	for _, pkg := range selected.UnsafeListOfStrings() {
+		var pkgReasons []ChangeReason
+		if files, ok := ct.packageFiles[pkg]; ok {
+			pkgReasons = append(pkgReasons, ChangeReason{Reason: ChangeReasonOwnFiles, Files: sortedFiles(files)})
+		}
+		dependencies, err := graph.Ancestors(pkg)
+		if err != nil {
+			return nil, err
+		}
+		var changedDependencies []string
+		for dep := range dependencies {
+			name := dag.VertexName(dep)
+			if _, ok := packageInfos[name]; !ok || name == util.RootPkgName {
+				continue
+			}
+			if _, ok := ct.packageFiles[name]; ok {
+				changedDependencies = append(changedDependencies, name)
+			}
+		}
+		sort.Strings(changedDependencies)
+		for _, dep := range changedDependencies {
+			pkgReasons = append(pkgReasons, ChangeReason{Reason: ChangeReasonDependency, Dependency: dep, Files: sortedFiles(ct.packageFiles[dep])})
+		}
+		if ct.lockfile.Len() > 0 {
+			pkgReasons = append(pkgReasons, ChangeReason{Reason: ChangeReasonLockfile, Files: sortedFiles(ct.lockfile)})
+		}
+		if ct.globalDeps.Len() > 0 {
+			pkgReasons = append(pkgReasons, ChangeReason{Reason: ChangeReasonGlobalDependency, Files: sortedFiles(ct.globalDeps)})
+		}
+		if len(pkgReasons) > 0 {
+			reasons[pkg] = pkgReasons
+		}
+	}
+	return reasons, nil
+}
+
+func sortedFiles(files util.Set) []string {
+	sorted := files.UnsafeListOfStrings()
+	sort.Strings(sorted)
+	return sorted
+}
@@ -1,7 +1,9 @@
 package scope
 
 import (
+	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -41,6 +43,17 @@ func addLegacyFlags(opts *LegacyFilter, flags *pflag.FlagSet) {
 	flags.BoolVar(&opts.SkipDependents, "no-deps", false, "Exclude dependent task consumers from execution.")
 	flags.StringArrayVar(&opts.Entrypoints, "scope", nil, "Specify package(s) to act as entry points for task execution. Supports globs.")
 	flags.StringVar(&opts.Since, "since", "", _sinceHelp)
+	// The filter syntax expresses each of these, and combines them more flexibly
+	for flag, replacement := range map[string]string{
+		"include-dependencies": `--filter="<selector>..."`,
+		"scope":                `--filter="<selector>"`,
+		"since":                `--filter="[<ref>]"`,
+	} {
+		if err := flags.MarkDeprecated(flag, fmt.Sprintf("use %v instead, see https://turbo.build/repo/docs/core-concepts/monorepos/filtering", replacement)); err != nil {
+			// fail fast if we've misconfigured our flags
+			panic(err)
+		}
+	}
 }
 
 // Opts holds the options for how to select the entrypoint packages for a turbo run
@@ -60,7 +73,8 @@ entry points. The syntax mirrors pnpm's syntax, and
 additional documentation and examples can be found in
 turbo's documentation https://turbo.build/repo/docs/reference/command-line-reference#--filter
 --filter can be specified multiple times. Packages that
-match any filter will be included.`
+match any filter will be included. Use --filter=- to read
+filters from stdin, one per line.`
 	_ignoreHelp    = `Files to ignore when calculating changed files (i.e. --since). Supports globs.`
 	_globalDepHelp = `Specify glob of global filesystem dependencies to be hashed. Useful for .env and files
 in the root directory. Includes turbo.json, root package.json, and the root lockfile by default.`
@@ -74,6 +88,40 @@ func AddFlags(opts *Opts, flags *pflag.FlagSet) {
 	addLegacyFlags(&opts.LegacyFilter, flags)
 }
 
+// _stdinFilter is the value of --filter that reads filters from stdin
+const _stdinFilter = "-"
+
+// alias so we can mock in tests
+var stdin io.Reader = os.Stdin
+
+// readFilterPatterns replaces --filter=- with the filters read from reader, one per
+// line. Blank lines are skipped. The second return value is true if reader was used.
+func readFilterPatterns(patterns []string, reader io.Reader) ([]string, bool, error) {
+	var result []string
+	readStdin := false
+	for _, pattern := range patterns {
+		if pattern != _stdinFilter {
+			result = append(result, pattern)
+			continue
+		}
+		if readStdin {
+			// stdin has already been consumed
+			continue
+		}
+		readStdin = true
+		scanner := bufio.NewScanner(reader)
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" {
+				result = append(result, line)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, false, fmt.Errorf("failed to read filters from stdin: %w", err)
+		}
+	}
+	return result, readStdin, nil
+}
+
 // asFilterPatterns normalizes legacy selectors to filter syntax
 func (l *LegacyFilter) asFilterPatterns() []string {
 	var patterns []string
@@ -110,23 +158,41 @@ func (l *LegacyFilter) asFilterPatterns() []string {
 	return patterns
 }
 
+// HasFilters returns true if any packages were selected with --filter, or with the
+// legacy flags
+func (o *Opts) HasFilters() bool {
+	return len(o.FilterPatterns) > 0 || len(o.LegacyFilter.asFilterPatterns()) > 0
+}
+
 // ResolvePackages translates specified flags to a set of entry point packages for
 // the selected tasks. Returns the selected packages and whether or not the selected
 // packages represents a default "all packages".
 func ResolvePackages(opts *Opts, cwd string, scm scm.SCM, ctx *context.Context, tui cli.Ui, logger hclog.Logger) (util.Set, bool, error) {
+	filteredPkgs, isAllPackages, _, err := ResolvePackagesWithChanges(opts, cwd, scm, ctx, tui, logger)
+	return filteredPkgs, isAllPackages, err
+}
+
+// ResolvePackagesWithChanges is ResolvePackages, but also returns why each of the
+// selected packages is considered changed, if a filter looked at changed files
+func ResolvePackagesWithChanges(opts *Opts, cwd string, scm scm.SCM, ctx *context.Context, tui cli.Ui, logger hclog.Logger) (util.Set, bool, ChangeReasons, error) {
+	changes := newChangeTracker()
 	filterResolver := &scope_filter.Resolver{
 		Graph:                  &ctx.TopologicalGraph,
 		PackageInfos:           ctx.PackageInfos,
 		Cwd:                    cwd,
-		PackagesChangedInRange: opts.getPackageChangeFunc(scm, cwd, ctx.PackageInfos, ctx.PackageManager),
+		PackagesChangedInRange: opts.getPackageChangeFunc(scm, cwd, ctx.PackageInfos, ctx.PackageManager, changes),
+	}
+	filterPatterns, readStdin, err := readFilterPatterns(opts.FilterPatterns, stdin)
+	if err != nil {
+		return nil, false, nil, err
 	}
-	filterPatterns := opts.FilterPatterns
 	legacyFilterPatterns := opts.LegacyFilter.asFilterPatterns()
 	filterPatterns = append(filterPatterns, legacyFilterPatterns...)
-	isAllPackages := len(filterPatterns) == 0
+	// If stdin was empty, nothing was selected, rather than everything
+	isAllPackages := len(filterPatterns) == 0 && !readStdin
 	filteredPkgs, err := filterResolver.GetPackagesFromPatterns(filterPatterns)
 	if err != nil {
-		return nil, false, err
+		return nil, false, nil, err
 	}
 
 	if isAllPackages {
@@ -136,10 +202,14 @@ func ResolvePackages(opts *Opts, cwd string, scm scm.SCM, ctx *context.Context,
 		}
 	}
 	filteredPkgs.Delete(ctx.RootNode)
-	return filteredPkgs, isAllPackages, nil
+	reasons, err := changes.reasons(filteredPkgs, &ctx.TopologicalGraph, ctx.PackageInfos)
+	if err != nil {
+		return nil, false, nil, err
+	}
+	return filteredPkgs, isAllPackages, reasons, nil
 }
 
-func (o *Opts) getPackageChangeFunc(scm scm.SCM, cwd string, packageInfos map[interface{}]*fs.PackageJSON, packageManager *packagemanager.PackageManager) scope_filter.PackagesChangedInRange {
+func (o *Opts) getPackageChangeFunc(scm scm.SCM, cwd string, packageInfos map[interface{}]*fs.PackageJSON, packageManager *packagemanager.PackageManager, changes *changeTracker) scope_filter.PackagesChangedInRange {
 	return func(fromRef string, toRef string) (util.Set, error) {
 		// We could filter changed files at the git level, since it's possible
 		// that the changes we're interested in are scoped, but we need to handle
@@ -153,20 +223,32 @@ func (o *Opts) getPackageChangeFunc(scm scm.SCM, cwd string, packageInfos map[in
 			}
 			changedFiles = scmChangedFiles
 		}
-		if hasRepoGlobalFileChanged, err := repoGlobalFileHasChanged(o, getDefaultGlobalDeps(packageManager), changedFiles); err != nil {
+		globalFiles, err := changedGlobalFiles(o, getDefaultGlobalDeps(packageManager), changedFiles)
+		if err != nil {
 			return nil, err
-		} else if hasRepoGlobalFileChanged {
+		}
+		filteredChangedFiles, err := filterIgnoredFiles(o, changedFiles)
+		if err != nil {
+			return nil, err
+		}
+		packageFiles := getChangedPackageFiles(filteredChangedFiles, packageInfos)
+		changes.recordPackages(packageFiles)
+		if len(globalFiles) > 0 {
+			lockfile := ""
+			if packageManager != nil {
+				lockfile = packageManager.Lockfile
+			}
+			changes.recordGlobal(globalFiles, lockfile)
 			allPkgs := make(util.Set)
 			for pkg := range packageInfos {
 				allPkgs.Add(pkg)
 			}
 			return allPkgs, nil
 		}
-		filteredChangedFiles, err := filterIgnoredFiles(o, changedFiles)
-		if err != nil {
-			return nil, err
+		changedPkgs := make(util.Set)
+		for pkg := range packageFiles {
+			changedPkgs.Add(pkg)
 		}
-		changedPkgs := getChangedPackages(filteredChangedFiles, packageInfos)
 		return changedPkgs, nil
 	}
 }
@@ -185,20 +267,23 @@ func getDefaultGlobalDeps(packageManager *packagemanager.PackageManager) []strin
 	return defaultGlobalDeps
 }
 
-func repoGlobalFileHasChanged(opts *Opts, defaultGlobalDeps []string, changedFiles []string) (bool, error) {
+// changedGlobalFiles returns the changed files that are global dependencies, which
+// affect every package
+func changedGlobalFiles(opts *Opts, defaultGlobalDeps []string, changedFiles []string) ([]string, error) {
 	globalDepsGlob, err := filter.Compile(append(opts.GlobalDepPatterns, defaultGlobalDeps...))
 	if err != nil {
-		return false, errors.Wrap(err, "invalid global deps glob")
+		return nil, errors.Wrap(err, "invalid global deps glob")
 	}
 
+	var globalFiles []string
 	if globalDepsGlob != nil {
 		for _, file := range changedFiles {
 			if globalDepsGlob.Match(filepath.ToSlash(file)) {
-				return true, nil
+				globalFiles = append(globalFiles, file)
 			}
 		}
 	}
-	return false, nil
+	return globalFiles, nil
 }
 
 func filterIgnoredFiles(opts *Opts, changedFiles []string) ([]string, error) {
@@ -246,20 +331,21 @@ func fileInPackage(changedFile string, packagePath string) bool {
 	return false
 }
 
-func getChangedPackages(changedFiles []string, packageInfos map[interface{}]*fs.PackageJSON) util.Set {
-	changedPackages := make(util.Set)
+// getChangedPackageFiles returns the changed files of each package that has any
+func getChangedPackageFiles(changedFiles []string, packageInfos map[interface{}]*fs.PackageJSON) map[interface{}][]string {
+	changedPackages := make(map[interface{}][]string)
 	for _, changedFile := range changedFiles {
 		found := false
 		for pkgName, pkgInfo := range packageInfos {
 			if pkgName != util.RootPkgName && fileInPackage(changedFile, pkgInfo.Dir.ToStringDuringMigration()) {
-				changedPackages.Add(pkgName)
+				changedPackages[pkgName] = append(changedPackages[pkgName], changedFile)
 				found = true
 				break
 			}
 		}
 		if !found {
 			// Consider the root package to have changed
-			changedPackages.Add(util.RootPkgName)
+			changedPackages[util.RootPkgName] = append(changedPackages[util.RootPkgName], changedFile)
 		}
 	}
 	return changedPackages
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/hashicorp/go-hclog"
@@ -296,3 +297,157 @@ func TestResolvePackages(t *testing.T) {
 		})
 	}
 }
+
+func TestReadFilterPatterns(t *testing.T) {
+	testCases := []struct {
+		name          string
+		patterns      []string
+		stdin         string
+		expected      []string
+		expectedStdin bool
+	}{
+		{
+			name:     "no stdin",
+			patterns: []string{"web", "...docs"},
+			stdin:    "unused\n",
+			expected: []string{"web", "...docs"},
+		},
+		{
+			name:          "stdin in place",
+			patterns:      []string{"web", "-", "!ui"},
+			stdin:         "libA\n\n  ./libs/*  \n",
+			expected:      []string{"web", "libA", "./libs/*", "!ui"},
+			expectedStdin: true,
+		},
+		{
+			name:          "stdin only read once",
+			patterns:      []string{"-", "-"},
+			stdin:         "libA",
+			expected:      []string{"libA"},
+			expectedStdin: true,
+		},
+		{
+			name:          "empty stdin",
+			patterns:      []string{"-"},
+			stdin:         "",
+			expected:      nil,
+			expectedStdin: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			patterns, readStdin, err := readFilterPatterns(tc.patterns, strings.NewReader(tc.stdin))
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if !reflect.DeepEqual(patterns, tc.expected) {
+				t.Errorf("readFilterPatterns got %v, want %v", patterns, tc.expected)
+			}
+			if readStdin != tc.expectedStdin {
+				t.Errorf("readStdin got %v, want %v", readStdin, tc.expectedStdin)
+			}
+		})
+	}
+}
+
+func TestResolvePackagesFromEmptyStdin(t *testing.T) {
+	original := stdin
+	stdin = strings.NewReader("")
+	defer func() { stdin = original }()
+	graph := dag.AcyclicGraph{}
+	graph.Add("app0")
+	pkgs, isAllPackages, err := ResolvePackages(&Opts{FilterPatterns: []string{"-"}}, filepath.FromSlash("/dummy/repo/root"), &mockSCM{}, &context.Context{
+		PackageInfos: map[interface{}]*fs.PackageJSON{
+			"app0": {Dir: turbopath.AnchoredUnixPath("app/app0").ToSystemPath()},
+		},
+		PackageNames:     []string{"app0"},
+		TopologicalGraph: graph,
+	}, ui.Default(), hclog.Default())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if isAllPackages || len(pkgs) != 0 {
+		t.Errorf("expected no packages to be selected, got %v", pkgs)
+	}
+}
+
+func TestResolvePackagesWithChanges(t *testing.T) {
+	// web -> ui, docs
+	graph := dag.AcyclicGraph{}
+	graph.Add("web")
+	graph.Add("ui")
+	graph.Add("docs")
+	graph.Connect(dag.BasicEdge("web", "ui"))
+	ctx := &context.Context{
+		PackageInfos: map[interface{}]*fs.PackageJSON{
+			"web":  {Dir: turbopath.AnchoredUnixPath("apps/web").ToSystemPath()},
+			"ui":   {Dir: turbopath.AnchoredUnixPath("packages/ui").ToSystemPath()},
+			"docs": {Dir: turbopath.AnchoredUnixPath("apps/docs").ToSystemPath()},
+		},
+		PackageNames:     []string{"web", "ui", "docs"},
+		PackageManager:   &packagemanager.PackageManager{Lockfile: "package-lock.json"},
+		TopologicalGraph: graph,
+	}
+	testCases := []struct {
+		name     string
+		filters  []string
+		changed  []string
+		expected ChangeReasons
+	}{
+		{
+			name:    "own files and dependencies",
+			filters: []string{"...[HEAD^]"},
+			changed: []string{"packages/ui/src/button.tsx", "apps/web/page.tsx", "packages/ui/index.ts"},
+			expected: ChangeReasons{
+				"ui": {
+					{Reason: ChangeReasonOwnFiles, Files: []string{"packages/ui/index.ts", "packages/ui/src/button.tsx"}},
+				},
+				"web": {
+					{Reason: ChangeReasonOwnFiles, Files: []string{"apps/web/page.tsx"}},
+					{Reason: ChangeReasonDependency, Dependency: "ui", Files: []string{"packages/ui/index.ts", "packages/ui/src/button.tsx"}},
+				},
+			},
+		},
+		{
+			name:    "lockfile and global dependencies",
+			filters: []string{"[HEAD^]"},
+			changed: []string{"package-lock.json", "turbo.json", "apps/docs/README.md"},
+			expected: ChangeReasons{
+				"docs": {
+					{Reason: ChangeReasonOwnFiles, Files: []string{"apps/docs/README.md"}},
+					{Reason: ChangeReasonLockfile, Files: []string{"package-lock.json"}},
+					{Reason: ChangeReasonGlobalDependency, Files: []string{"turbo.json"}},
+				},
+				"ui": {
+					{Reason: ChangeReasonLockfile, Files: []string{"package-lock.json"}},
+					{Reason: ChangeReasonGlobalDependency, Files: []string{"turbo.json"}},
+				},
+				"web": {
+					{Reason: ChangeReasonLockfile, Files: []string{"package-lock.json"}},
+					{Reason: ChangeReasonGlobalDependency, Files: []string{"turbo.json"}},
+				},
+			},
+		},
+		{
+			name:     "no filter on changed files",
+			filters:  []string{"web"},
+			changed:  []string{"apps/web/page.tsx"},
+			expected: nil,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			changed := make([]string, len(tc.changed))
+			for index, path := range tc.changed {
+				changed[index] = filepath.FromSlash(path)
+			}
+			_, _, reasons, err := ResolvePackagesWithChanges(&Opts{FilterPatterns: tc.filters}, filepath.FromSlash("/dummy/repo/root"), &mockSCM{changed: changed}, ctx, ui.Default(), hclog.Default())
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if !reflect.DeepEqual(reasons, tc.expected) {
+				t.Errorf("ResolvePackagesWithChanges reasons got %v, want %v", reasons, tc.expected)
+			}
+		})
+	}
+}
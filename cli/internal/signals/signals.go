@@ -13,28 +13,48 @@ type Watcher struct {
 	doneCh  chan struct{}
 	closed  bool
 	mu      sync.Mutex
-	closers []func()
+	closers []*closer
 }
 
-// AddOnClose registers a cleanup handler to run when a signal is received
-func (w *Watcher) AddOnClose(closer func()) {
+type closer struct {
+	fn func()
+}
+
+// AddOnClose registers a cleanup handler to run when a signal is received. It
+// returns a function that unregisters the handler, for cleanup that is only needed
+// while something is running, such as an iteration of --watch.
+func (w *Watcher) AddOnClose(fn func()) func() {
 	w.mu.Lock()
 	defer w.mu.Unlock()
-	w.closers = append(w.closers, closer)
+	c := &closer{fn: fn}
+	w.closers = append(w.closers, c)
+	return func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		for i, registered := range w.closers {
+			if registered == c {
+				w.closers = append(w.closers[:i], w.closers[i+1:]...)
+				return
+			}
+		}
+	}
 }
 
 // Close runs the cleanup handlers registered with this watcher
 func (w *Watcher) Close() {
 	w.mu.Lock()
-	defer w.mu.Unlock()
 	if w.closed {
+		w.mu.Unlock()
 		return
 	}
 	w.closed = true
-	for _, closer := range w.closers {
-		closer()
-	}
+	closers := w.closers
 	w.closers = nil
+	// Handlers can unregister themselves, or others, while they run
+	w.mu.Unlock()
+	for _, c := range closers {
+		c.fn()
+	}
 	close(w.doneCh)
 }
 
@@ -0,0 +1,20 @@
+package signals
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestWatcherRemovesClosers(t *testing.T) {
+	w := NewWatcher()
+	var ran []string
+	w.AddOnClose(func() { ran = append(ran, "kept") })
+	remove := w.AddOnClose(func() { ran = append(ran, "removed") })
+	remove()
+	// Removing twice is harmless
+	remove()
+	w.Close()
+	<-w.Done()
+	assert.DeepEqual(t, ran, []string{"kept"})
+}
@@ -0,0 +1,150 @@
+package snapshot
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/vercel/turbo/cli/internal/cmdutil"
+	"github.com/vercel/turbo/cli/internal/errcode"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+type diffOpts struct {
+	json bool
+}
+
+// GetCmd returns the snapshot command, which records and compares the inputs to turbo's hashes
+func GetCmd(helper *cmdutil.Helper) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "snapshot",
+		Short: "Record and compare the inputs to turbo's hashes",
+		Long: `Record and compare the inputs to turbo's hashes.
+
+A snapshot holds the hash of every file in every workspace, the global
+dependencies, the environment variables that turbo hashes, and the definition
+of every task. Comparing two snapshots explains which of these changed, such as
+when tasks miss the cache unexpectedly between two commits or machines.`,
+		SilenceUsage:          true,
+		SilenceErrors:         true,
+		DisableFlagsInUseLine: true,
+	}
+	cmd.AddCommand(saveCmd(helper))
+	cmd.AddCommand(diffCmd(helper))
+	return cmd
+}
+
+func saveCmd(helper *cmdutil.Helper) *cobra.Command {
+	return &cobra.Command{
+		Use:           "save <file>",
+		Short:         "Save a snapshot of the inputs to turbo's hashes",
+		Args:          cobra.ExactArgs(1),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, err := helper.GetCmdBase(cmd.Flags())
+			if err != nil {
+				return err
+			}
+			snapshot, err := Take(base.RepoRoot, base.TurboVersion)
+			if err != nil {
+				base.LogError("snapshot failed: %w", err)
+				return err
+			}
+			path := fs.ResolveUnknownPath(base.RepoRoot, args[0])
+			if err := snapshot.Write(path); err != nil {
+				base.LogError("failed to write snapshot: %w", err)
+				return err
+			}
+			files := len(snapshot.GlobalFiles)
+			for _, pkg := range snapshot.Packages {
+				files += len(pkg.Files)
+			}
+			base.UI.Info(util.Sprintf("${GREY}>>> Saved a snapshot of %v files in %v workspaces to %v${RESET}", files, len(snapshot.Packages), path))
+			return nil
+		},
+	}
+}
+
+func diffCmd(helper *cmdutil.Helper) *cobra.Command {
+	opts := &diffOpts{}
+	cmd := &cobra.Command{
+		Use:   "diff <before> [<after>]",
+		Short: "Compare two snapshots, or a snapshot and the current state",
+		Long: `Compare two snapshots, or a snapshot and the current state.
+
+Every input that was added, removed, or changed between the snapshots is
+listed. Without <after>, the snapshot is compared to the current state of the
+monorepo.`,
+		Args:          cobra.RangeArgs(1, 2),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, err := helper.GetCmdBase(cmd.Flags())
+			if err != nil {
+				return err
+			}
+			if err := diff(base, opts, args); err != nil {
+				base.LogError("snapshot diff failed: %w", err)
+				return err
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&opts.json, "json", false, "Print the changes as JSON")
+	return cmd
+}
+
+func diff(base *cmdutil.CmdBase, opts *diffOpts, args []string) error {
+	before, err := Read(fs.ResolveUnknownPath(base.RepoRoot, args[0]))
+	if err != nil {
+		return errcode.Wrap(errcode.InvalidArguments, err)
+	}
+	var after *Snapshot
+	if len(args) > 1 {
+		after, err = Read(fs.ResolveUnknownPath(base.RepoRoot, args[1]))
+		if err != nil {
+			return errcode.Wrap(errcode.InvalidArguments, err)
+		}
+	} else if after, err = Take(base.RepoRoot, base.TurboVersion); err != nil {
+		return err
+	}
+
+	changes := Diff(before, after)
+	if opts.json {
+		if changes == nil {
+			changes = []Change{}
+		}
+		bytes, err := json.MarshalIndent(changes, "", "  ")
+		if err != nil {
+			return err
+		}
+		base.UI.Output(string(bytes))
+		return nil
+	}
+	if before.TurboVersion != after.TurboVersion {
+		// Hashes can change between versions of turbo regardless of any inputs
+		base.UI.Warn(fmt.Sprintf("The snapshots were taken with different versions of turbo: %v and %v", before.TurboVersion, after.TurboVersion))
+	}
+	if len(changes) == 0 {
+		base.UI.Output("No inputs changed")
+		return nil
+	}
+	for _, change := range changes {
+		color := "${YELLOW}"
+		switch change.Action() {
+		case "added":
+			color = "${GREEN}"
+		case "removed":
+			color = "${RED}"
+		}
+		base.UI.Output(util.Sprintf("%v%v${RESET}", color, change))
+	}
+	if len(changes) == 1 {
+		base.UI.Output(util.Sprintf("${BOLD}1 input changed${RESET}"))
+	} else {
+		base.UI.Output(util.Sprintf("${BOLD}%v inputs changed${RESET}", len(changes)))
+	}
+	return nil
+}
@@ -0,0 +1,344 @@
+// Package snapshot records the inputs that turbo hashes across a whole monorepo,
+// and compares two such records, to explain why hashes changed between them
+package snapshot
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/vercel/turbo/cli/internal/context"
+	"github.com/vercel/turbo/cli/internal/env"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/globby"
+	"github.com/vercel/turbo/cli/internal/hashing"
+	"github.com/vercel/turbo/cli/internal/inference"
+	"github.com/vercel/turbo/cli/internal/taskhash"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// Snapshot is the state of everything that goes into turbo's hashes at a point in time
+type Snapshot struct {
+	TurboVersion string    `json:"turboVersion"`
+	CreatedAt    time.Time `json:"createdAt"`
+	// Commit is the git commit checked out, if any
+	Commit string `json:"commit,omitempty"`
+	// GlobalFiles maps the files in globalDependencies, and the package manager's
+	// files, to their hashes
+	GlobalFiles map[string]string `json:"globalFiles"`
+	// Env maps the environment variables that turbo hashes to a digest of their
+	// value, so that the snapshot doesn't contain secrets. Unset variables are omitted.
+	Env map[string]string `json:"env"`
+	// Pipeline maps each task in turbo.json to a hash of its definition
+	Pipeline map[string]string `json:"pipeline"`
+	// Packages maps the name of each workspace to its inputs
+	Packages map[string]*Package `json:"packages"`
+}
+
+// Package is the state of a single workspace
+type Package struct {
+	Path string `json:"path"`
+	// ExternalDepsHash is the hash of the workspace's resolved external dependencies
+	ExternalDepsHash string `json:"externalDepsHash"`
+	// Files maps the files of the workspace, relative to it, to their hashes. The
+	// files of the root workspace exclude those of other workspaces.
+	Files map[string]string `json:"files"`
+}
+
+// Take records a snapshot of the monorepo at repoRoot
+func Take(repoRoot turbopath.AbsoluteSystemPath, turboVersion string) (*Snapshot, error) {
+	rootPackageJSON, err := fs.ReadPackageJSON(repoRoot.UntypedJoin("package.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read package.json: %w", err)
+	}
+	turboJSON, err := fs.ReadTurboConfig(repoRoot, rootPackageJSON)
+	if err != nil {
+		return nil, err
+	}
+	ctx, turboJSON, err := context.BuildPackageGraphWithConfig(repoRoot, rootPackageJSON, turboJSON)
+	if err != nil {
+		var warnings *context.Warnings
+		if !errors.As(err, &warnings) {
+			return nil, err
+		}
+	}
+
+	snapshot := &Snapshot{
+		TurboVersion: turboVersion,
+		CreatedAt:    time.Now().UTC(),
+		Commit:       headCommit(repoRoot),
+		Env:          make(map[string]string),
+		Pipeline:     make(map[string]string),
+		Packages:     make(map[string]*Package),
+	}
+
+	snapshot.GlobalFiles, err = globalFileHashes(repoRoot, ctx, turboJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	envNames := make(util.Set)
+	envNames.Add("VERCEL_ANALYTICS_ID")
+	for _, name := range turboJSON.GlobalEnv {
+		envNames.Add(name)
+	}
+	for taskID, taskDefinition := range turboJSON.Pipeline {
+		hash, err := fs.HashObject(taskDefinition)
+		if err != nil {
+			return nil, err
+		}
+		snapshot.Pipeline[taskID] = hash
+		for _, name := range taskDefinition.EnvVarDependencies {
+			envNames.Add(name)
+		}
+	}
+	var envPrefixes []string
+	for _, pkg := range ctx.PackageInfos {
+		if framework := inference.InferFramework(pkg); framework != nil && framework.EnvPrefix != "" {
+			envPrefixes = append(envPrefixes, framework.EnvPrefix)
+		}
+	}
+	pairs := env.GetHashableEnvPairs(envNames.UnsafeListOfStrings(), envPrefixes)
+	for _, pair := range os.Environ() {
+		if name, _, _ := strings.Cut(pair, "="); strings.Contains(name, "THASH") {
+			pairs = append(pairs, pair)
+		}
+	}
+	for _, pair := range pairs {
+		name, _, _ := strings.Cut(pair, "=")
+		if value, ok := os.LookupEnv(name); ok {
+			snapshot.Env[name] = digest(value)
+		}
+	}
+
+	var pkgDirs []string
+	for name, pkg := range ctx.PackageInfos {
+		if name != util.RootPkgName {
+			pkgDirs = append(pkgDirs, pkg.Dir.ToUnixPath().ToString()+"/")
+		}
+	}
+	for name, pkg := range ctx.PackageInfos {
+		files, err := taskhash.PackageFileHashes(pkg, repoRoot, turboJSON.HashExclude)
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash the files of %v: %w", name, err)
+		}
+		snapshotPkg := &Package{
+			Path:             pkg.Dir.ToUnixPath().ToString(),
+			ExternalDepsHash: pkg.ExternalDepsHash,
+			Files:            make(map[string]string, len(files)),
+		}
+		for file, hash := range files {
+			if name == util.RootPkgName && hasAnyPrefix(file.ToString(), pkgDirs) {
+				continue
+			}
+			snapshotPkg.Files[file.ToString()] = hash
+		}
+		snapshot.Packages[fmt.Sprintf("%v", name)] = snapshotPkg
+	}
+	return snapshot, nil
+}
+
+// globalFileHashes hashes the files that every task depends on
+func globalFileHashes(repoRoot turbopath.AbsoluteSystemPath, ctx *context.Context, turboJSON *fs.TurboJSON) (map[string]string, error) {
+	globalDeps := make(util.Set)
+	if len(turboJSON.GlobalDeps) > 0 {
+		ignores, err := ctx.PackageManager.GetWorkspaceIgnores(repoRoot)
+		if err != nil {
+			return nil, err
+		}
+		files, err := globby.GlobFiles(repoRoot.ToStringDuringMigration(), turboJSON.GlobalDeps, ignores)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			globalDeps.Add(file)
+		}
+	}
+	for _, file := range []string{ctx.PackageManager.Specfile, ctx.PackageManager.Lockfile} {
+		if path := repoRoot.UntypedJoin(file); path.FileExists() {
+			globalDeps.Add(path.ToStringDuringMigration())
+		}
+	}
+	paths := make([]turbopath.AbsoluteSystemPath, 0, globalDeps.Len())
+	for _, file := range globalDeps.UnsafeListOfStrings() {
+		paths = append(paths, turbopath.AbsoluteSystemPathFromUpstream(file))
+	}
+	hashes, err := hashing.GetHashableDeps(repoRoot, paths)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash global dependencies: %w", err)
+	}
+	globalFiles := make(map[string]string, len(hashes))
+	for file, hash := range hashes {
+		globalFiles[file.ToString()] = hash
+	}
+	return globalFiles, nil
+}
+
+// headCommit returns the commit checked out at repoRoot, or "" if it isn't in git
+func headCommit(repoRoot turbopath.AbsoluteSystemPath) string {
+	cmd := exec.Command("git", "rev-parse", "HEAD")
+	cmd.Dir = repoRoot.ToString()
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func digest(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Write saves the snapshot to path as JSON
+func (s *Snapshot) Write(path turbopath.AbsoluteSystemPath) error {
+	bytes, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := path.EnsureDir(); err != nil {
+		return err
+	}
+	return path.WriteFile(append(bytes, '\n'), 0644)
+}
+
+// Read loads a snapshot saved with Write
+func Read(path turbopath.AbsoluteSystemPath) (*Snapshot, error) {
+	bytes, err := path.ReadFile()
+	if err != nil {
+		return nil, err
+	}
+	snapshot := &Snapshot{}
+	if err := json.Unmarshal(bytes, snapshot); err != nil {
+		return nil, fmt.Errorf("%v is not a snapshot: %w", path, err)
+	}
+	return snapshot, nil
+}
+
+// ChangeKind is the kind of input that a Change is to
+type ChangeKind string
+
+const (
+	// ChangeGlobalFile is a change to a file that every task depends on
+	ChangeGlobalFile ChangeKind = "global file"
+	// ChangeEnv is a change to the value of an environment variable
+	ChangeEnv ChangeKind = "env"
+	// ChangeTask is a change to the definition of a task in turbo.json
+	ChangeTask ChangeKind = "task"
+	// ChangePackage is a workspace being added or removed
+	ChangePackage ChangeKind = "package"
+	// ChangeExternalDeps is a change to the resolved external dependencies of a workspace
+	ChangeExternalDeps ChangeKind = "dependencies"
+	// ChangeFile is a change to a file in a workspace
+	ChangeFile ChangeKind = "file"
+)
+
+// Change is a single input that differs between two snapshots
+type Change struct {
+	Kind ChangeKind `json:"kind"`
+	// Package is the workspace that the change is in, for changes to workspaces
+	Package string `json:"package,omitempty"`
+	// Name identifies the changed input, e.g. the path of a file or the name of an
+	// environment variable
+	Name string `json:"name,omitempty"`
+	// Before and After are the hashes of the input. Before is empty for added
+	// inputs, and After for removed ones.
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+}
+
+// Action describes what happened to the input
+func (c Change) Action() string {
+	switch {
+	case c.Before == "":
+		return "added"
+	case c.After == "":
+		return "removed"
+	default:
+		return "changed"
+	}
+}
+
+// String describes the change as a single line
+func (c Change) String() string {
+	subject := string(c.Kind)
+	if c.Name != "" {
+		subject += " " + c.Name
+	}
+	if c.Package != "" && c.Kind != ChangePackage {
+		subject = c.Package + ": " + subject
+	}
+	return fmt.Sprintf("%v %v", subject, c.Action())
+}
+
+// Diff returns the inputs that differ from before to after, ordered by kind and name
+func Diff(before *Snapshot, after *Snapshot) []Change {
+	var changes []Change
+	changes = append(changes, diffHashes(ChangeGlobalFile, "", before.GlobalFiles, after.GlobalFiles)...)
+	changes = append(changes, diffHashes(ChangeEnv, "", before.Env, after.Env)...)
+	changes = append(changes, diffHashes(ChangeTask, "", before.Pipeline, after.Pipeline)...)
+
+	names := make(util.Set)
+	for name := range before.Packages {
+		names.Add(name)
+	}
+	for name := range after.Packages {
+		names.Add(name)
+	}
+	sortedNames := names.UnsafeListOfStrings()
+	sort.Strings(sortedNames)
+	for _, name := range sortedNames {
+		beforePkg, afterPkg := before.Packages[name], after.Packages[name]
+		if beforePkg == nil || afterPkg == nil {
+			change := Change{Kind: ChangePackage, Package: name, Name: name}
+			if beforePkg != nil {
+				change.Before = beforePkg.Path
+			}
+			if afterPkg != nil {
+				change.After = afterPkg.Path
+			}
+			changes = append(changes, change)
+			continue
+		}
+		if beforePkg.ExternalDepsHash != afterPkg.ExternalDepsHash {
+			changes = append(changes, Change{Kind: ChangeExternalDeps, Package: name, Before: beforePkg.ExternalDepsHash, After: afterPkg.ExternalDepsHash})
+		}
+		changes = append(changes, diffHashes(ChangeFile, name, beforePkg.Files, afterPkg.Files)...)
+	}
+	return changes
+}
+
+func diffHashes(kind ChangeKind, pkg string, before map[string]string, after map[string]string) []Change {
+	var changes []Change
+	for name, beforeHash := range before {
+		if afterHash := after[name]; afterHash != beforeHash {
+			changes = append(changes, Change{Kind: kind, Package: pkg, Name: name, Before: beforeHash, After: afterHash})
+		}
+	}
+	for name, afterHash := range after {
+		if _, ok := before[name]; !ok {
+			changes = append(changes, Change{Kind: kind, Package: pkg, Name: name, After: afterHash})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Name < changes[j].Name
+	})
+	return changes
+}
@@ -0,0 +1,103 @@
+package snapshot
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"gotest.tools/v3/assert"
+)
+
+func writeFiles(t *testing.T, repoRoot turbopath.AbsoluteSystemPath, files map[string]string) {
+	t.Helper()
+	for name, contents := range files {
+		path := repoRoot.UntypedJoin(name)
+		assert.NilError(t, path.EnsureDir(), name)
+		assert.NilError(t, path.WriteFile([]byte(contents), 0644), name)
+	}
+}
+
+func TestTakeAndDiff(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	writeFiles(t, repoRoot, map[string]string{
+		"package.json":              `{"name": "root", "packageManager": "npm@8.19.2", "workspaces": ["packages/*"]}`,
+		"turbo.json":                `{"globalDependencies": ["tsconfig.json"], "globalEnv": ["SNAPSHOT_TEST_GLOBAL"], "pipeline": {"build": {"env": ["SNAPSHOT_TEST_BUILD"]}}}`,
+		"tsconfig.json":             `{}`,
+		"packages/ui/package.json":  `{"name": "ui"}`,
+		"packages/ui/index.ts":      `export const a = 1`,
+		"packages/web/package.json": `{"name": "web", "dependencies": {"ui": "*"}}`,
+	})
+	t.Setenv("SNAPSHOT_TEST_GLOBAL", "secret")
+
+	before, err := Take(repoRoot, "1.0.0")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, sortedKeys(before.Packages), []string{"//", "ui", "web"})
+	assert.Equal(t, before.Packages["ui"].Path, "packages/ui")
+	assert.DeepEqual(t, sortedKeys(before.Packages["ui"].Files), []string{"index.ts", "package.json"})
+	// The root workspace doesn't include the files of other workspaces
+	assert.DeepEqual(t, sortedKeys(before.Packages["//"].Files), []string{"package.json", "tsconfig.json", "turbo.json"})
+	assert.DeepEqual(t, sortedKeys(before.GlobalFiles), []string{"package.json", "tsconfig.json"})
+	assert.Equal(t, before.Env["SNAPSHOT_TEST_GLOBAL"], digest("secret"))
+	assert.DeepEqual(t, sortedKeys(before.Pipeline), []string{"build"})
+
+	path := fs.AbsoluteSystemPathFromUpstream(t.TempDir()).UntypedJoin("snapshot.json")
+	assert.NilError(t, before.Write(path))
+	read, err := Read(path)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, Diff(read, before), []Change(nil))
+
+	writeFiles(t, repoRoot, map[string]string{
+		"turbo.json":           `{"globalDependencies": ["tsconfig.json"], "globalEnv": ["SNAPSHOT_TEST_GLOBAL"], "pipeline": {"build": {"env": ["SNAPSHOT_TEST_BUILD"], "outputs": ["dist/**"]}}}`,
+		"tsconfig.json":        `{"strict": true}`,
+		"packages/ui/index.ts": `export const a = 2`,
+		"packages/ui/new.ts":   `export const b = 1`,
+	})
+	t.Setenv("SNAPSHOT_TEST_GLOBAL", "rotated")
+	t.Setenv("SNAPSHOT_TEST_BUILD", "1")
+
+	after, err := Take(repoRoot, "1.0.0")
+	assert.NilError(t, err)
+	var descriptions []string
+	for _, change := range Diff(before, after) {
+		descriptions = append(descriptions, change.String())
+	}
+	assert.DeepEqual(t, descriptions, []string{
+		"global file tsconfig.json changed",
+		"env SNAPSHOT_TEST_BUILD added",
+		"env SNAPSHOT_TEST_GLOBAL changed",
+		"task build changed",
+		"//: file tsconfig.json changed",
+		"//: file turbo.json changed",
+		"ui: file index.ts changed",
+		"ui: file new.ts added",
+	})
+}
+
+func TestDiffPackages(t *testing.T) {
+	before := &Snapshot{Packages: map[string]*Package{
+		"docs": {Path: "apps/docs", Files: map[string]string{"index.md": "a"}},
+		"ui":   {Path: "packages/ui", ExternalDepsHash: "1", Files: map[string]string{"index.ts": "b", "old.ts": "c"}},
+	}}
+	after := &Snapshot{Packages: map[string]*Package{
+		"ui":  {Path: "packages/ui", ExternalDepsHash: "2", Files: map[string]string{"index.ts": "b"}},
+		"web": {Path: "apps/web", Files: map[string]string{"index.ts": "d"}},
+	}}
+	assert.DeepEqual(t, Diff(before, after), []Change{
+		{Kind: ChangePackage, Package: "docs", Name: "docs", Before: "apps/docs"},
+		{Kind: ChangeExternalDeps, Package: "ui", Before: "1", After: "2"},
+		{Kind: ChangeFile, Package: "ui", Name: "old.ts", Before: "c"},
+		{Kind: ChangePackage, Package: "web", Name: "web", After: "apps/web"},
+	})
+	assert.Equal(t, Diff(before, after)[0].String(), "package docs removed")
+	assert.Equal(t, Diff(before, after)[1].String(), "ui: dependencies changed")
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,55 @@
+package taskhash
+
+import "github.com/vercel/turbo/cli/internal/fs"
+
+// Schema describes what goes into the hashes of tasks, and how they are
+// calculated, so that other tools can reimplement them
+type Schema struct {
+	// Version is fs.HashSchemaVersion
+	Version     int           `json:"version"`
+	Algorithm   string        `json:"algorithm"`
+	Encoding    string        `json:"encoding"`
+	Global      []SchemaField `json:"global"`
+	Task        []SchemaField `json:"task"`
+	Passthrough string        `json:"passthrough"`
+}
+
+// SchemaField is one of the values that go into a hash, in the order they are formatted in
+type SchemaField struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// HashSchema describes the current hash schema. The fields of Global and Task
+// must match those of the global hash and taskHashInputs, in order.
+func HashSchema() Schema {
+	return Schema{
+		Version:   fs.HashSchemaVersion,
+		Algorithm: "xxHash64 of the encoded fields, as lowercase hex",
+		Encoding: "The fields are formatted with the %v verb of Go's fmt package, in order, separated by spaces, " +
+			"inside {} for the global hash and &{} for a task hash. Strings are unquoted, slices are formatted as [a b], " +
+			"maps as map[k:v] sorted by key, and structs as {} with their fields in order.",
+		Global: []SchemaField{
+			{"globalFileHashMap", "The git object hash of each of globalDependencies, the root dotenv files of --env, and, without a parsed lockfile, the root package.json and lockfile, by path relative to the repository root"},
+			{"rootExternalDepsHash", "The hash of the external dependencies of the root workspace, as resolved in the lockfile"},
+			{"hashedSortedEnvPairs", "NAME=value for each of globalEnv, VERCEL_ANALYTICS_ID, and the variables of the environment whose names contain THASH, sorted"},
+			{"globalCacheKey", "A constant string, which changes to invalidate every hash"},
+			{"pipeline", "The pipeline of turbo.json"},
+			{"environment", "The value of --env"},
+			{"strictEnv", "Whether --env-mode is strict"},
+			{"hashSchemaVersion", "The version of this schema"},
+		},
+		Task: []SchemaField{
+			{"hashOfFiles", "The hash of a map of the git object hash of each of the inputs of the task, or of the files of its workspace that git doesn't ignore, apart from hashExclude, and of its dotenv files of --env, by path relative to the workspace"},
+			{"externalDepsHash", "The hash of the external dependencies of the workspace, as resolved in the lockfile"},
+			{"task", "The name of the task, without the workspace"},
+			{"outputs", "The outputs of the task, relative to the workspace, as {[inclusions] [exclusions]}, with .turbo/turbo-<task>.log first among the inclusions"},
+			{"passThruArgs", "The arguments after -- that are passed to the task"},
+			{"hashableEnvPairs", "NAME=value for each of the env of the task, and the variables with the prefix of its inferred framework, sorted"},
+			{"globalHash", "The global hash"},
+			{"taskDependencyHashes", "The hashes of the tasks it depends on, or of the matching files of those in its outputOf, sorted and deduplicated"},
+			{"hashCommandOutput", "The hash of the stdout of its hashCommand"},
+		},
+		Passthrough: "A passthrough task is hashed with only hashOfFiles, externalDepsHash, task, globalHash, and taskDependencyHashes set, and the other fields empty",
+	}
+}
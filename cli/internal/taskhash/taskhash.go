@@ -4,7 +4,10 @@
 package taskhash
 
 import (
+	"bytes"
 	"fmt"
+	"os/exec"
+	"runtime"
 	"sort"
 	"strings"
 	"sync"
@@ -15,6 +18,7 @@ import (
 	"github.com/vercel/turbo/cli/internal/doublestar"
 	"github.com/vercel/turbo/cli/internal/env"
 	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/globby"
 	"github.com/vercel/turbo/cli/internal/hashing"
 	"github.com/vercel/turbo/cli/internal/inference"
 	"github.com/vercel/turbo/cli/internal/nodes"
@@ -36,16 +40,25 @@ type Tracker struct {
 	mu                  sync.RWMutex
 	packageInputsHashes packageFileHashes
 	packageTaskHashes   map[string]string // taskID -> hash
+	hashCommandOutputs  map[string]string // hashCommand -> hash of its stdout
+	environment         string            // selects the dotenv files hashed with each package
+	hashExclude         []string          // globs of files left out of package hashes
+	repoRoot            turbopath.AbsoluteSystemPath
 }
 
 // NewTracker creates a tracker for package-inputs combinations and package-task combinations.
-func NewTracker(rootNode string, globalHash string, pipeline fs.Pipeline, packageInfos map[interface{}]*fs.PackageJSON) *Tracker {
+// If environment isn't empty, its dotenv files are hashed with each package, whether or
+// not they match the task's inputs. Files matching hashExclude aren't hashed.
+func NewTracker(rootNode string, globalHash string, pipeline fs.Pipeline, packageInfos map[interface{}]*fs.PackageJSON, environment string, hashExclude []string) *Tracker {
 	return &Tracker{
-		rootNode:          rootNode,
-		globalHash:        globalHash,
-		pipeline:          pipeline,
-		packageInfos:      packageInfos,
-		packageTaskHashes: make(map[string]string),
+		rootNode:           rootNode,
+		globalHash:         globalHash,
+		pipeline:           pipeline,
+		packageInfos:       packageInfos,
+		packageTaskHashes:  make(map[string]string),
+		hashCommandOutputs: make(map[string]string),
+		environment:        environment,
+		hashExclude:        hashExclude,
 	}
 }
 
@@ -79,17 +92,15 @@ func safeCompileIgnoreFile(filepath string) (*gitignore.GitIgnore, error) {
 	return gitignore.CompileIgnoreLines([]string{}...), nil
 }
 
-func (pfs *packageFileSpec) hash(pkg *fs.PackageJSON, repoRoot turbopath.AbsoluteSystemPath) (string, error) {
-	hashObject, pkgDepsErr := hashing.GetPackageDeps(repoRoot, &hashing.PackageDepsOptions{
-		PackagePath:   pkg.Dir,
-		InputPatterns: pfs.inputs,
-	})
-	if pkgDepsErr != nil {
-		manualHashObject, err := manuallyHashPackage(pkg, pfs.inputs, repoRoot)
-		if err != nil {
+func (pfs *packageFileSpec) hash(pkg *fs.PackageJSON, repoRoot turbopath.AbsoluteSystemPath, environment string, hashExclude []string) (string, error) {
+	hashObject, err := hashPackageFiles(pkg, pfs.inputs, repoRoot, hashExclude)
+	if err != nil {
+		return "", err
+	}
+	if environment != "" {
+		if err := hashDotEnvFiles(hashObject, pkg, repoRoot, environment); err != nil {
 			return "", err
 		}
-		hashObject = manualHashObject
 	}
 	hashOfFiles, otherErr := fs.HashObject(hashObject)
 	if otherErr != nil {
@@ -98,7 +109,48 @@ func (pfs *packageFileSpec) hash(pkg *fs.PackageJSON, repoRoot turbopath.Absolut
 	return hashOfFiles, nil
 }
 
-func manuallyHashPackage(pkg *fs.PackageJSON, inputs []string, rootPath turbopath.AbsoluteSystemPath) (map[turbopath.AnchoredUnixPath]string, error) {
+// PackageFileHashes returns the hash of every file in pkg that the hash of its tasks
+// covers, when they don't set inputs
+func PackageFileHashes(pkg *fs.PackageJSON, repoRoot turbopath.AbsoluteSystemPath, hashExclude []string) (map[turbopath.AnchoredUnixPath]string, error) {
+	return hashPackageFiles(pkg, nil, repoRoot, hashExclude)
+}
+
+func hashPackageFiles(pkg *fs.PackageJSON, inputs []string, repoRoot turbopath.AbsoluteSystemPath, hashExclude []string) (map[turbopath.AnchoredUnixPath]string, error) {
+	hashObject, pkgDepsErr := hashing.GetPackageDeps(repoRoot, &hashing.PackageDepsOptions{
+		PackagePath:     pkg.Dir,
+		InputPatterns:   inputs,
+		ExcludePatterns: hashExclude,
+	})
+	if pkgDepsErr != nil {
+		return manuallyHashPackage(pkg, inputs, repoRoot, hashExclude)
+	}
+	return hashObject, nil
+}
+
+// hashDotEnvFiles adds the package's dotenv files for environment to hashObject. They
+// are usually ignored by git, and so otherwise left out.
+func hashDotEnvFiles(hashObject map[turbopath.AnchoredUnixPath]string, pkg *fs.PackageJSON, repoRoot turbopath.AbsoluteSystemPath, environment string) error {
+	pkgDir := repoRoot.UntypedJoin(pkg.Dir.ToStringDuringMigration())
+	var files []turbopath.AbsoluteSystemPath
+	for _, name := range env.DotEnvFiles(environment) {
+		if file := pkgDir.UntypedJoin(name); file.FileExists() {
+			files = append(files, file)
+		}
+	}
+	if len(files) == 0 {
+		return nil
+	}
+	hashes, err := hashing.GetHashableDeps(repoRoot, files)
+	if err != nil {
+		return fmt.Errorf("failed to hash dotenv files of %v: %w", pkg.Name, err)
+	}
+	for file, hash := range hashes {
+		hashObject[file] = hash
+	}
+	return nil
+}
+
+func manuallyHashPackage(pkg *fs.PackageJSON, inputs []string, rootPath turbopath.AbsoluteSystemPath, hashExclude []string) (map[turbopath.AnchoredUnixPath]string, error) {
 	hashObject := make(map[turbopath.AnchoredUnixPath]string)
 	// Instead of implementing all gitignore properly, we hack it. We only respect .gitignore in the root and in
 	// the directory of a package.
@@ -134,6 +186,9 @@ func manuallyHashPackage(pkg *fs.PackageJSON, inputs []string, rootPath turbopat
 						return nil
 					}
 				}
+				if repoPath, err := convertedName.RelativeTo(rootPath); err == nil && hashing.IsHashExcluded(hashExclude, repoPath.ToUnixPath()) {
+					return nil
+				}
 				hash, err := fs.GitLikeHashFile(convertedName.ToString())
 				if err != nil {
 					return fmt.Errorf("could not hash file %v. \n%w", convertedName.ToString(), err)
@@ -151,6 +206,44 @@ func manuallyHashPackage(pkg *fs.PackageJSON, inputs []string, rootPath turbopat
 	return hashObject, nil
 }
 
+// runHashCommand executes a pipeline task's hashCommand from the repository root
+// and returns the hash of its stdout
+func runHashCommand(command string, repoRoot turbopath.AbsoluteSystemPath) (string, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+	cmd.Dir = repoRoot.ToString()
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("hashCommand \"%v\" failed: %w\n%v", command, err, stderr.String())
+	}
+	return fs.HashObject(string(out))
+}
+
+// calculateHashCommandOutputs runs each unique hashCommand in the pipeline once
+func (th *Tracker) calculateHashCommandOutputs(commands util.Set, repoRoot turbopath.AbsoluteSystemPath) error {
+	hashErrs := &errgroup.Group{}
+	for _, command := range commands.UnsafeListOfStrings() {
+		command := command
+		hashErrs.Go(func() error {
+			hash, err := runHashCommand(command, repoRoot)
+			if err != nil {
+				return err
+			}
+			th.mu.Lock()
+			th.hashCommandOutputs[command] = hash
+			th.mu.Unlock()
+			return nil
+		})
+	}
+	return hashErrs.Wait()
+}
+
 // packageFileHashes is a map from a package and optional input globs to the hash of
 // the matched files in the package.
 type packageFileHashes map[packageFileHashKey]string
@@ -158,7 +251,9 @@ type packageFileHashes map[packageFileHashKey]string
 // CalculateFileHashes hashes each unique package-inputs combination that is present
 // in the task graph. Must be called before calculating task hashes.
 func (th *Tracker) CalculateFileHashes(allTasks []dag.Vertex, workerCount int, repoRoot turbopath.AbsoluteSystemPath) error {
+	th.repoRoot = repoRoot
 	hashTasks := make(util.Set)
+	hashCommands := make(util.Set)
 
 	for _, v := range allTasks {
 		taskID, ok := v.(string)
@@ -184,6 +279,13 @@ func (th *Tracker) CalculateFileHashes(allTasks []dag.Vertex, workerCount int, r
 		}
 
 		hashTasks.Add(pfs)
+		if taskDefinition.HashCommand != "" {
+			hashCommands.Add(taskDefinition.HashCommand)
+		}
+	}
+
+	if err := th.calculateHashCommandOutputs(hashCommands, repoRoot); err != nil {
+		return err
 	}
 
 	hashes := make(map[packageFileHashKey]string)
@@ -197,7 +299,7 @@ func (th *Tracker) CalculateFileHashes(allTasks []dag.Vertex, workerCount int, r
 				if !ok {
 					return fmt.Errorf("cannot find package %v", packageFileSpec.pkg)
 				}
-				hash, err := packageFileSpec.hash(pkg, repoRoot)
+				hash, err := packageFileSpec.hash(pkg, repoRoot, th.environment, th.hashExclude)
 				if err != nil {
 					return err
 				}
@@ -230,9 +332,10 @@ type taskHashInputs struct {
 	hashableEnvPairs     []string
 	globalHash           string
 	taskDependencyHashes []string
+	hashCommandOutput    string
 }
 
-func (th *Tracker) calculateDependencyHashes(dependencySet dag.Set) ([]string, error) {
+func (th *Tracker) calculateDependencyHashes(packageTask *nodes.PackageTask, dependencySet dag.Set) ([]string, error) {
 	dependencyHashSet := make(util.Set)
 
 	rootPrefix := th.rootNode + util.TaskDelimiter
@@ -249,6 +352,14 @@ func (th *Tracker) calculateDependencyHashes(dependencySet dag.Set) ([]string, e
 		if strings.HasPrefix(dependencyTask, rootPrefix) {
 			continue
 		}
+		if globs, ok := packageTask.TaskDefinition.OutputSubset(packageTask.TaskID, dependencyTask); ok {
+			subsetHash, err := th.outputSubsetHash(dependencyTask, globs)
+			if err != nil {
+				return nil, err
+			}
+			dependencyHashSet.Add(subsetHash)
+			continue
+		}
 		dependencyHash, ok := th.packageTaskHashes[dependencyTask]
 		if !ok {
 			return nil, fmt.Errorf("missing hash for dependent task: %v", dependencyTask)
@@ -260,6 +371,79 @@ func (th *Tracker) calculateDependencyHashes(dependencySet dag.Set) ([]string, e
 	return dependenciesHashList, nil
 }
 
+// outputSubsetHash hashes the files matching globs in the workspace of dependencyTask,
+// which a task consumes through outputOf. Only these files, and not the hash of
+// dependencyTask, affect the hash of the task, so it isn't invalidated by changes to
+// dependencyTask that leave them as they were.
+func (th *Tracker) outputSubsetHash(dependencyTask string, globs []string) (string, error) {
+	pkgName, _ := util.GetPackageTaskFromId(dependencyTask)
+	pkg, ok := th.packageInfos[pkgName]
+	if !ok {
+		return "", fmt.Errorf("cannot find package %v", pkgName)
+	}
+	var inclusions []string
+	var exclusions []string
+	for _, glob := range globs {
+		if strings.HasPrefix(glob, "!") {
+			exclusions = append(exclusions, glob[1:])
+		} else {
+			inclusions = append(inclusions, glob)
+		}
+	}
+	pkgDir := th.repoRoot.UntypedJoin(pkg.Dir.ToStringDuringMigration())
+	files, err := globby.GlobFiles(pkgDir.ToString(), inclusions, exclusions)
+	if err != nil {
+		return "", fmt.Errorf("failed to find outputs of %v: %w", dependencyTask, err)
+	}
+	paths := make([]turbopath.AbsoluteSystemPath, len(files))
+	for i, file := range files {
+		paths[i] = turbopath.AbsoluteSystemPathFromUpstream(file)
+	}
+	manifest := map[turbopath.AnchoredUnixPath]string{}
+	if len(paths) > 0 {
+		manifest, err = hashing.GetHashableDeps(th.repoRoot, paths)
+		if err != nil {
+			return "", fmt.Errorf("failed to hash outputs of %v: %w", dependencyTask, err)
+		}
+	}
+	return fs.HashObject(manifest)
+}
+
+// calculatePassthroughHash calculates the hash for a passthrough task, which is never
+// executed. Its dependents consume the package's source, so only the package's files
+// and dependencies contribute to the hash, and not the configuration of the task.
+func (th *Tracker) calculatePassthroughHash(packageTask *nodes.PackageTask, hashOfFiles string, dependencySet dag.Set) (string, error) {
+	taskDependencyHashes, err := th.calculateDependencyHashes(packageTask, dependencySet)
+	if err != nil {
+		return "", err
+	}
+	hash, err := fs.HashObject(&taskHashInputs{
+		hashOfFiles:          hashOfFiles,
+		externalDepsHash:     packageTask.Pkg.ExternalDepsHash,
+		task:                 packageTask.Task,
+		globalHash:           th.globalHash,
+		taskDependencyHashes: taskDependencyHashes,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to hash task %v: %v", packageTask.TaskID, err)
+	}
+	th.mu.Lock()
+	th.packageTaskHashes[packageTask.TaskID] = hash
+	th.mu.Unlock()
+	return hash, nil
+}
+
+// TaskHashes returns the hashes of the package-tasks calculated so far, by task ID
+func (th *Tracker) TaskHashes() map[string]string {
+	th.mu.RLock()
+	defer th.mu.RUnlock()
+	hashes := make(map[string]string, len(th.packageTaskHashes))
+	for taskID, hash := range th.packageTaskHashes {
+		hashes[taskID] = hash
+	}
+	return hashes
+}
+
 // CalculateTaskHash calculates the hash for package-task combination. It is threadsafe, provided
 // that it has previously been called on its task-graph dependencies. File hashes must be calculated
 // first.
@@ -271,6 +455,9 @@ func (th *Tracker) CalculateTaskHash(packageTask *nodes.PackageTask, dependencyS
 	if !ok {
 		return "", fmt.Errorf("cannot find package-file hash for %v", pkgFileHashKey)
 	}
+	if packageTask.TaskDefinition.Passthrough {
+		return th.calculatePassthroughHash(packageTask, hashOfFiles, dependencySet)
+	}
 
 	var envPrefixes []string
 	framework := inference.InferFramework(packageTask.Pkg)
@@ -282,10 +469,19 @@ func (th *Tracker) CalculateTaskHash(packageTask *nodes.PackageTask, dependencyS
 
 	hashableEnvPairs := env.GetHashableEnvPairs(packageTask.TaskDefinition.EnvVarDependencies, envPrefixes)
 	outputs := packageTask.HashableOutputs()
-	taskDependencyHashes, err := th.calculateDependencyHashes(dependencySet)
+	taskDependencyHashes, err := th.calculateDependencyHashes(packageTask, dependencySet)
 	if err != nil {
 		return "", err
 	}
+	hashCommandOutput := ""
+	if hashCommand := packageTask.TaskDefinition.HashCommand; hashCommand != "" {
+		th.mu.RLock()
+		hashCommandOutput, ok = th.hashCommandOutputs[hashCommand]
+		th.mu.RUnlock()
+		if !ok {
+			return "", fmt.Errorf("cannot find output of hashCommand \"%v\" for %v", hashCommand, packageTask.TaskID)
+		}
+	}
 	// log any auto detected env vars
 	logger.Debug(fmt.Sprintf("task hash env vars for %s:%s", packageTask.PackageName, packageTask.Task), "vars", hashableEnvPairs)
 
@@ -298,6 +494,7 @@ func (th *Tracker) CalculateTaskHash(packageTask *nodes.PackageTask, dependencyS
 		hashableEnvPairs:     hashableEnvPairs,
 		globalHash:           th.globalHash,
 		taskDependencyHashes: taskDependencyHashes,
+		hashCommandOutput:    hashCommandOutput,
 	})
 	if err != nil {
 		return "", fmt.Errorf("failed to hash task %v: %v", packageTask.TaskID, hash)
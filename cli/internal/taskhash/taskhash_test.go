@@ -2,10 +2,14 @@ package taskhash
 
 import (
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 
+	"github.com/hashicorp/go-hclog"
+	"github.com/pyr-sh/dag"
 	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/nodes"
 	"github.com/vercel/turbo/cli/internal/turbopath"
 )
 
@@ -83,7 +87,7 @@ func Test_manuallyHashPackage(t *testing.T) {
 	pkg := &fs.PackageJSON{
 		Dir: pkgName,
 	}
-	hashes, err := manuallyHashPackage(pkg, []string{}, repoRoot)
+	hashes, err := manuallyHashPackage(pkg, []string{}, repoRoot, nil)
 	if err != nil {
 		t.Fatalf("failed to calculate manual hashes: %v", err)
 	}
@@ -110,7 +114,7 @@ func Test_manuallyHashPackage(t *testing.T) {
 	}
 
 	count = 0
-	justFileHashes, err := manuallyHashPackage(pkg, []string{filepath.FromSlash("**/*file")}, repoRoot)
+	justFileHashes, err := manuallyHashPackage(pkg, []string{filepath.FromSlash("**/*file")}, repoRoot, nil)
 	if err != nil {
 		t.Fatalf("failed to calculate manual hashes: %v", err)
 	}
@@ -135,3 +139,153 @@ func Test_manuallyHashPackage(t *testing.T) {
 		t.Errorf("found extra hashes in %v", hashes)
 	}
 }
+
+func Test_manuallyHashPackageHashExclude(t *testing.T) {
+	repoRoot := turbopath.AbsoluteSystemPathFromUpstream(t.TempDir())
+	for _, name := range []string{"libA/index.js", "libA/vendor/big.js", "libA/vendor/.turbo-hash"} {
+		path := repoRoot.UntypedJoin(filepath.FromSlash(name))
+		if err := path.EnsureDir(); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := path.WriteFile([]byte(name), 0644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+	pkg := &fs.PackageJSON{
+		Dir: turbopath.AnchoredSystemPath("libA"),
+	}
+	hashes, err := manuallyHashPackage(pkg, []string{}, repoRoot, []string{"libA/vendor/**"})
+	if err != nil {
+		t.Fatalf("failed to calculate manual hashes: %v", err)
+	}
+	if len(hashes) != 2 || hashes["index.js"] == "" || hashes["vendor/.turbo-hash"] == "" {
+		t.Errorf("expected only index.js and the stamp to be hashed, got %v", hashes)
+	}
+}
+
+func Test_runHashCommand(t *testing.T) {
+	repoRoot := turbopath.AbsoluteSystemPathFromUpstream(t.TempDir())
+
+	first, err := runHashCommand("echo one", repoRoot)
+	if err != nil {
+		t.Fatalf("failed to run hashCommand: %v", err)
+	}
+	again, err := runHashCommand("echo one", repoRoot)
+	if err != nil {
+		t.Fatalf("failed to run hashCommand: %v", err)
+	}
+	if first != again {
+		t.Errorf("expected identical output to hash the same, got %v and %v", first, again)
+	}
+	second, err := runHashCommand("echo two", repoRoot)
+	if err != nil {
+		t.Fatalf("failed to run hashCommand: %v", err)
+	}
+	if first == second {
+		t.Errorf("expected different output to hash differently, both got %v", first)
+	}
+
+	_, err = runHashCommand("exit 1", repoRoot)
+	if err == nil {
+		t.Error("expected a failing hashCommand to return an error")
+	}
+}
+
+func Test_CalculateTaskHashPassthrough(t *testing.T) {
+	pkg := &fs.PackageJSON{Name: "ui", Dir: "packages/ui"}
+	tracker := NewTracker("___ROOT___", "global-hash", fs.Pipeline{}, map[interface{}]*fs.PackageJSON{"ui": pkg}, "", nil)
+	tracker.packageInputsHashes = packageFileHashes{"ui#": "files-hash"}
+	hashTask := func(taskDefinition fs.TaskDefinition, args []string) string {
+		t.Helper()
+		hash, err := tracker.CalculateTaskHash(&nodes.PackageTask{
+			TaskID:         "ui#build",
+			Task:           "build",
+			PackageName:    "ui",
+			Pkg:            pkg,
+			TaskDefinition: &taskDefinition,
+		}, dag.Set{}, hclog.NewNullLogger(), args)
+		if err != nil {
+			t.Fatalf("failed to hash task: %v", err)
+		}
+		return hash
+	}
+
+	passthrough := hashTask(fs.TaskDefinition{Passthrough: true}, nil)
+	configured := hashTask(fs.TaskDefinition{
+		Passthrough:        true,
+		EnvVarDependencies: []string{"HOME"},
+		Outputs:            fs.TaskOutputs{Inclusions: []string{"dist/**"}},
+	}, []string{"--watch"})
+	if passthrough != configured {
+		t.Errorf("expected the configuration of a passthrough task not to affect its hash, got %v and %v", passthrough, configured)
+	}
+	if regular := hashTask(fs.TaskDefinition{}, nil); regular == passthrough {
+		t.Errorf("expected passthrough and regular tasks to hash differently, both got %v", regular)
+	}
+
+	tracker.packageInputsHashes = packageFileHashes{"ui#": "changed-files-hash"}
+	if changed := hashTask(fs.TaskDefinition{Passthrough: true}, nil); changed == passthrough {
+		t.Errorf("expected changes to the package's files to change the hash, both got %v", changed)
+	}
+}
+
+func Test_CalculateTaskHashOutputOf(t *testing.T) {
+	repoRoot := turbopath.AbsoluteSystemPathFromUpstream(t.TempDir())
+	writeFile := func(path string, contents string) {
+		t.Helper()
+		file := repoRoot.UntypedJoin(path)
+		if err := file.EnsureDir(); err != nil {
+			t.Fatal(err)
+		}
+		if err := file.WriteFile([]byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile("packages/ui/dist/types/index.d.ts", "export declare const a: string;")
+	writeFile("packages/ui/dist/index.js", "export const a = 'a';")
+
+	ui := &fs.PackageJSON{Name: "ui", Dir: "packages/ui"}
+	web := &fs.PackageJSON{Name: "web", Dir: "apps/web"}
+	tracker := NewTracker("___ROOT___", "global-hash", fs.Pipeline{}, map[interface{}]*fs.PackageJSON{"ui": ui, "web": web}, "", nil)
+	tracker.repoRoot = repoRoot
+	tracker.packageInputsHashes = packageFileHashes{"web#": "files-hash"}
+	taskDefinition := fs.TaskDefinition{OutputOf: map[string][]string{"^build": {"dist/types/**"}}}
+	hashTask := func(uiBuildHash string) string {
+		t.Helper()
+		tracker.packageTaskHashes["ui#build"] = uiBuildHash
+		hash, err := tracker.CalculateTaskHash(&nodes.PackageTask{
+			TaskID:         "web#typecheck",
+			Task:           "typecheck",
+			PackageName:    "web",
+			Pkg:            web,
+			TaskDefinition: &taskDefinition,
+		}, dag.Set{"ui#build": "ui#build"}, hclog.NewNullLogger(), nil)
+		if err != nil {
+			t.Fatalf("failed to hash task: %v", err)
+		}
+		return hash
+	}
+
+	first := hashTask("build-hash")
+	writeFile("packages/ui/dist/index.js", "export const a = 'b';")
+	if second := hashTask("other-build-hash"); second != first {
+		t.Errorf("expected changes outside of the consumed outputs not to affect the hash, got %v and %v", first, second)
+	}
+	writeFile("packages/ui/dist/types/index.d.ts", "export declare const a: number;")
+	if third := hashTask("other-build-hash"); third == first {
+		t.Errorf("expected changes to the consumed outputs to affect the hash, both got %v", first)
+	}
+}
+
+func TestHashSchemaDescribesTaskHashInputs(t *testing.T) {
+	inputs := reflect.TypeOf(taskHashInputs{})
+	schema := HashSchema()
+	if len(schema.Task) != inputs.NumField() {
+		t.Fatalf("HashSchema() describes %v task fields, taskHashInputs has %v", len(schema.Task), inputs.NumField())
+	}
+	for i, field := range schema.Task {
+		if field.Name != inputs.Field(i).Name {
+			t.Errorf("HashSchema() task field %v is %v, want %v", i, field.Name, inputs.Field(i).Name)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package typescript
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/vercel/turbo/cli/internal/cmdutil"
+	"github.com/vercel/turbo/cli/internal/context"
+	"github.com/vercel/turbo/cli/internal/errcode"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+type referencesOpts struct {
+	check bool
+	json  bool
+}
+
+// GetCmd returns the typescript command, which keeps TypeScript projects in step
+// with the package graph
+func GetCmd(helper *cmdutil.Helper) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "typescript",
+		Short: "Keep TypeScript project references in step with the package graph",
+		Long: `Keep TypeScript project references in step with the package graph.
+
+Tasks with "typescript": true in turbo.json run tsc --build in every workspace
+with a tsconfig.json, in the order of the package graph, and cache the
+.tsbuildinfo files alongside the other outputs. tsc only type-checks a project
+against the projects it references, so each tsconfig.json has to reference the
+workspaces it depends on.`,
+		SilenceUsage:          true,
+		SilenceErrors:         true,
+		DisableFlagsInUseLine: true,
+	}
+	cmd.AddCommand(referencesCmd(helper))
+	return cmd
+}
+
+func referencesCmd(helper *cmdutil.Helper) *cobra.Command {
+	opts := &referencesOpts{}
+	cmd := &cobra.Command{
+		Use:   "references",
+		Short: "Set the references of each tsconfig.json to the workspaces it depends on",
+		Long: `Set the references of each tsconfig.json to the workspaces it depends on.
+
+Every workspace with a tsconfig.json references the workspaces with a
+tsconfig.json that it depends on, in package.json, sorted by path. References to
+projects that aren't workspaces are kept, as are the comments and the order of
+keys in each file.`,
+		Args:          cobra.NoArgs,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, err := helper.GetCmdBase(cmd.Flags())
+			if err != nil {
+				return err
+			}
+			if err := references(base, opts); err != nil {
+				base.LogError("%w", err)
+				return err
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&opts.check, "check", false, "Fail if any references are out of date, without writing them")
+	cmd.Flags().BoolVar(&opts.json, "json", false, "Print the updated references as JSON")
+	return cmd
+}
+
+func references(base *cmdutil.CmdBase, opts *referencesOpts) error {
+	rootPackageJSON, err := fs.ReadPackageJSON(base.RepoRoot.UntypedJoin("package.json"))
+	if err != nil {
+		return errcode.Wrap(errcode.InvalidPackageJSON, fmt.Errorf("failed to read package.json: %w", err))
+	}
+	turboJSON, err := fs.ReadTurboConfig(base.RepoRoot, rootPackageJSON)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return errcode.Wrap(errcode.InvalidTurboJSON, err)
+	}
+	ctx, _, err := context.BuildPackageGraphWithConfig(base.RepoRoot, rootPackageJSON, turboJSON)
+	if err != nil {
+		var warnings *context.Warnings
+		if !errors.As(err, &warnings) {
+			return errcode.Wrap(errcode.InvalidPackageGraph, err)
+		}
+		base.Logger.Warn("issues occurred when constructing package graph", "warnings", err)
+	}
+
+	updates, contents, err := References(ctx, base.RepoRoot)
+	if err != nil {
+		return err
+	}
+	if !opts.check {
+		paths := make([]string, 0, len(contents))
+		for path := range contents {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		for _, path := range paths {
+			if err := writeConfig(turbopath.AbsoluteSystemPath(path), contents[path]); err != nil {
+				return err
+			}
+		}
+	}
+
+	if opts.json {
+		bytes, err := json.MarshalIndent(updates, "", "  ")
+		if err != nil {
+			return err
+		}
+		base.UI.Output(string(bytes))
+	} else if len(updates) == 0 {
+		base.UI.Output("The references of every tsconfig.json are up to date")
+	} else {
+		for _, update := range updates {
+			base.UI.Output(util.Sprintf("${GREY}•${RESET} %v ${GREY}(%v)${RESET}: %v", update.Path, update.Workspace, formatReferences(update.References)))
+		}
+		base.UI.Output("")
+		if opts.check {
+			base.UI.Output(util.Sprintf("${BOLD}Would update the references above${RESET}"))
+		} else {
+			base.UI.Output(util.Sprintf("${BOLD}Updated the references above${RESET}"))
+		}
+	}
+	if opts.check && len(updates) > 0 {
+		return fmt.Errorf("references are out of date, run turbo typescript references to update them")
+	}
+	return nil
+}
+
+func formatReferences(references []string) string {
+	if len(references) == 0 {
+		return "no references"
+	}
+	return strings.Join(references, ", ")
+}
+
+// writeConfig writes a tsconfig.json, keeping its mode
+func writeConfig(path turbopath.AbsoluteSystemPath, contents []byte) error {
+	info, err := path.Lstat()
+	if err != nil {
+		return err
+	}
+	return path.WriteFile(contents, info.Mode())
+}
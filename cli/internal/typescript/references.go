@@ -0,0 +1,151 @@
+package typescript
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"github.com/vercel/turbo/cli/internal/context"
+	"github.com/vercel/turbo/cli/internal/jsonedit"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// Update is a tsconfig.json whose "references" don't match the package graph
+type Update struct {
+	Workspace string `json:"workspace"`
+	// Path is the tsconfig.json, relative to the root of the monorepo
+	Path string `json:"path"`
+	// References are the paths that the tsconfig.json references once it is updated
+	References []string `json:"references"`
+}
+
+// References returns the workspaces whose tsconfig.json doesn't reference the
+// TypeScript projects of exactly the workspaces they depend on, along with their
+// updated contents. References to projects that aren't workspaces are kept.
+func References(ctx *context.Context, repoRoot turbopath.AbsoluteSystemPath) ([]*Update, map[string][]byte, error) {
+	projectDirs := make(map[string]string)
+	workspaceDirs := make(util.Set)
+	for key, pkg := range ctx.PackageInfos {
+		if key == util.RootPkgName {
+			continue
+		}
+		name := key.(string)
+		dir := pkg.Dir.RestoreAnchor(repoRoot)
+		workspaceDirs.Add(dir.ToString())
+		if dir.UntypedJoin(ConfigName).FileExists() {
+			projectDirs[name] = dir.ToString()
+		}
+	}
+	names := make([]string, 0, len(projectDirs))
+	for name := range projectDirs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	updates := []*Update{}
+	contents := make(map[string][]byte)
+	for _, name := range names {
+		dir := projectDirs[name]
+		configPath := filepath.Join(dir, ConfigName)
+		content, err := turbopath.AbsoluteSystemPath(configPath).ReadFile()
+		if err != nil {
+			return nil, nil, err
+		}
+		existing, err := parseConfig(content)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read %v: %w", configPath, err)
+		}
+
+		var references []string
+		var current []string
+		for _, ref := range existing.References {
+			current = append(current, ref.Path)
+			target := filepath.Join(dir, filepath.FromSlash(ref.Path))
+			if filepath.Base(target) == ConfigName {
+				target = filepath.Dir(target)
+			}
+			if !workspaceDirs.Includes(target) {
+				references = append(references, ref.Path)
+			}
+		}
+		var dependencies []string
+		for dep := range ctx.TopologicalGraph.DownEdges(name) {
+			depDir, ok := projectDirs[dep.(string)]
+			if !ok {
+				continue
+			}
+			relative, err := filepath.Rel(dir, depDir)
+			if err != nil {
+				return nil, nil, err
+			}
+			dependencies = append(dependencies, filepath.ToSlash(relative))
+		}
+		sort.Strings(dependencies)
+		references = append(references, dependencies...)
+		if equal(current, references) {
+			continue
+		}
+
+		edited, err := setReferences(content, references)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to update %v: %w", configPath, err)
+		}
+		relativePath, err := filepath.Rel(repoRoot.ToString(), configPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		if references == nil {
+			references = []string{}
+		}
+		updates = append(updates, &Update{
+			Workspace:  name,
+			Path:       path.Clean(filepath.ToSlash(relativePath)),
+			References: references,
+		})
+		contents[configPath] = edited
+	}
+	return updates, contents, nil
+}
+
+// setReferences sets the top-level "references" of a tsconfig.json, keeping its
+// comments and the order of its keys
+func setReferences(content []byte, paths []string) ([]byte, error) {
+	s := jsonedit.NewScanner(content)
+	references := make([]reference, len(paths))
+	for i, p := range paths {
+		references[i] = reference{Path: p}
+	}
+	marshalled, err := json.Marshal(references)
+	if err != nil {
+		return nil, err
+	}
+	indent := s.IndentUnit(s.Root())
+	formatted := &bytes.Buffer{}
+	if err := json.Indent(formatted, marshalled, "", indent); err != nil {
+		return nil, err
+	}
+	value, ok, err := s.FindKey(s.Root(), "references")
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return jsonedit.Splice(content, value, jsonedit.IndentValue(formatted.String(), indent)), nil
+	}
+	return s.AppendElement(s.Root(), `"references": `+formatted.String())
+}
+
+func equal(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,91 @@
+package typescript
+
+import (
+	"testing"
+
+	"github.com/pyr-sh/dag"
+	"github.com/vercel/turbo/cli/internal/context"
+	"github.com/vercel/turbo/cli/internal/core"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/util"
+	"gotest.tools/v3/assert"
+)
+
+func Test_setReferences(t *testing.T) {
+	testCases := []struct {
+		name    string
+		content string
+		paths   []string
+		want    string
+	}{
+		{
+			name:    "adds missing references",
+			content: "{\n  // the project\n  \"compilerOptions\": {}\n}\n",
+			paths:   []string{"../ui"},
+			want:    "{\n  // the project\n  \"compilerOptions\": {},\n  \"references\": [\n    {\n      \"path\": \"../ui\"\n    }\n  ]\n}\n",
+		},
+		{
+			name:    "replaces existing references",
+			content: "{\n    \"references\": [{\"path\": \"../old\"}],\n    \"include\": [\"src\"]\n}\n",
+			paths:   []string{"../a", "../b"},
+			want:    "{\n    \"references\": [\n        {\n            \"path\": \"../a\"\n        },\n        {\n            \"path\": \"../b\"\n        }\n    ],\n    \"include\": [\"src\"]\n}\n",
+		},
+		{
+			name:    "empties references",
+			content: `{"references": [{"path": "../old"}]}`,
+			paths:   nil,
+			want:    `{"references": []}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := setReferences([]byte(tc.content), tc.paths)
+			assert.NilError(t, err)
+			assert.Equal(t, string(got), tc.want)
+		})
+	}
+}
+
+func Test_References(t *testing.T) {
+	repoRoot := turbopath.AbsoluteSystemPathFromUpstream(t.TempDir())
+	ctx := &context.Context{
+		PackageInfos: make(map[interface{}]*fs.PackageJSON),
+		RootNode:     core.ROOT_NODE_NAME,
+	}
+	// web -> ui -> config, web -> eslint-config, and config has no tsconfig.json
+	for _, name := range []string{"web", "ui", "config", "eslint-config"} {
+		ctx.PackageInfos[name] = &fs.PackageJSON{Name: name, Dir: turbopath.AnchoredSystemPath("packages/" + name)}
+		ctx.TopologicalGraph.Add(name)
+	}
+	ctx.PackageInfos[util.RootPkgName] = &fs.PackageJSON{}
+	ctx.TopologicalGraph.Connect(dag.BasicEdge("web", "ui"))
+	ctx.TopologicalGraph.Connect(dag.BasicEdge("web", "eslint-config"))
+	ctx.TopologicalGraph.Connect(dag.BasicEdge("ui", "config"))
+	ctx.TopologicalGraph.Connect(dag.BasicEdge("config", core.ROOT_NODE_NAME))
+	ctx.TopologicalGraph.Connect(dag.BasicEdge("eslint-config", core.ROOT_NODE_NAME))
+
+	files := map[string]string{
+		// A reference to a project that isn't a workspace is kept, and the stale
+		// reference to config is dropped
+		"packages/web/tsconfig.json":           `{"references": [{"path": "./scripts"}, {"path": "../config/tsconfig.json"}]}`,
+		"packages/ui/tsconfig.json":            `{"compilerOptions": {"composite": true}}`,
+		"packages/eslint-config/tsconfig.json": `{"references": []}`,
+	}
+	for name, content := range files {
+		path := repoRoot.UntypedJoin(name)
+		assert.NilError(t, path.EnsureDir())
+		assert.NilError(t, path.WriteFile([]byte(content), 0644))
+	}
+
+	updates, contents, err := References(ctx, repoRoot)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, updates, []*Update{
+		{Workspace: "web", Path: "packages/web/tsconfig.json", References: []string{"./scripts", "../eslint-config", "../ui"}},
+	})
+	assert.Equal(t, len(contents), 1)
+	edited := contents[repoRoot.UntypedJoin("packages", "web", "tsconfig.json").ToString()]
+	config, err := parseConfig(edited)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, config.References, []reference{{Path: "./scripts"}, {Path: "../eslint-config"}, {Path: "../ui"}})
+}
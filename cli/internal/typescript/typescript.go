@@ -0,0 +1,222 @@
+// Package typescript integrates turbo with TypeScript project references. A task
+// with "typescript": true in turbo.json runs `tsc --build` in the workspaces that
+// have a tsconfig.json, after the same task in their dependencies, and caches the
+// .tsbuildinfo files that make the next build incremental.
+package typescript
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"muzzammil.xyz/jsonc"
+)
+
+// ConfigName is the name of the config of the TypeScript project of a workspace
+const ConfigName = "tsconfig.json"
+
+// BuildCommand runs a task with "typescript": true in the workspaces that have no
+// script for it
+const BuildCommand = "tsc --build"
+
+// _trailingCommaPattern matches the trailing commas that tsconfig.json files allow.
+// It can match inside a string, which is unlikely in the options that matter here.
+var _trailingCommaPattern = regexp.MustCompile(`,(\s*[}\]])`)
+
+// _maxExtendsDepth guards against a cycle of tsconfig.json files that extend each other
+const _maxExtendsDepth = 10
+
+type tsconfig struct {
+	Extends         interface{} `json:"extends"`
+	References      []reference `json:"references"`
+	CompilerOptions struct {
+		OutDir          *string `json:"outDir"`
+		DeclarationDir  *string `json:"declarationDir"`
+		RootDir         *string `json:"rootDir"`
+		TSBuildInfoFile *string `json:"tsBuildInfoFile"`
+	} `json:"compilerOptions"`
+}
+
+type reference struct {
+	Path string `json:"path"`
+}
+
+// compilerOptions are the options that decide where `tsc --build` writes files, as
+// absolute paths, since each is relative to the config that sets it
+type compilerOptions struct {
+	outDir          string
+	declarationDir  string
+	rootDir         string
+	tsBuildInfoFile string
+}
+
+// parseConfig parses a tsconfig.json, which can have comments and trailing commas
+func parseConfig(data []byte) (*tsconfig, error) {
+	var config tsconfig
+	if err := json.Unmarshal(_trailingCommaPattern.ReplaceAll(jsonc.ToJSON(data), []byte("$1")), &config); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// readOptions reads the compiler options of the tsconfig.json at path, following the
+// configs it extends by relative path. Configs extended from packages are skipped.
+func readOptions(path turbopath.AbsoluteSystemPath, depth int) (*compilerOptions, error) {
+	if depth > _maxExtendsDepth {
+		return nil, fmt.Errorf("too many levels of \"extends\"")
+	}
+	data, err := path.ReadFile()
+	if err != nil {
+		return nil, err
+	}
+	config, err := parseConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %v: %w", path, err)
+	}
+	dir := path.Dir()
+	options := &compilerOptions{}
+	var extends []string
+	switch value := config.Extends.(type) {
+	case string:
+		extends = []string{value}
+	case []interface{}:
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				extends = append(extends, s)
+			}
+		}
+	}
+	// Later configs in an array of extends override earlier ones
+	for _, extend := range extends {
+		if !strings.HasPrefix(extend, ".") {
+			continue
+		}
+		extendedPath := dir.UntypedJoin(filepath.FromSlash(extend))
+		if !extendedPath.FileExists() && !strings.HasSuffix(extend, ".json") {
+			// tsc also looks for the config with a .json extension
+			extendedPath = dir.UntypedJoin(filepath.FromSlash(extend) + ".json")
+		}
+		extended, err := readOptions(extendedPath, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		options.override(extended)
+	}
+	resolve := func(value *string) string {
+		if value == nil {
+			return ""
+		}
+		return dir.UntypedJoin(filepath.FromSlash(*value)).ToString()
+	}
+	options.override(&compilerOptions{
+		outDir:          resolve(config.CompilerOptions.OutDir),
+		declarationDir:  resolve(config.CompilerOptions.DeclarationDir),
+		rootDir:         resolve(config.CompilerOptions.RootDir),
+		tsBuildInfoFile: resolve(config.CompilerOptions.TSBuildInfoFile),
+	})
+	return options, nil
+}
+
+// override replaces the options that other sets
+func (o *compilerOptions) override(other *compilerOptions) {
+	for _, option := range []struct {
+		value    *string
+		override string
+	}{
+		{&o.outDir, other.outDir},
+		{&o.declarationDir, other.declarationDir},
+		{&o.rootDir, other.rootDir},
+		{&o.tsBuildInfoFile, other.tsBuildInfoFile},
+	} {
+		if option.override != "" {
+			*option.value = option.override
+		}
+	}
+}
+
+// buildInfoFile returns the path of the .tsbuildinfo file of the project whose
+// config is at configPath, in the same way as tsc
+func (o *compilerOptions) buildInfoFile(configPath turbopath.AbsoluteSystemPath) string {
+	if o.tsBuildInfoFile != "" {
+		return o.tsBuildInfoFile
+	}
+	withoutExtension := strings.TrimSuffix(configPath.ToString(), filepath.Ext(configPath.ToString()))
+	if o.outDir == "" {
+		return withoutExtension + ".tsbuildinfo"
+	}
+	if o.rootDir != "" {
+		if relative, err := filepath.Rel(o.rootDir, withoutExtension); err == nil {
+			return filepath.Join(o.outDir, relative) + ".tsbuildinfo"
+		}
+	}
+	return filepath.Join(o.outDir, filepath.Base(withoutExtension)) + ".tsbuildinfo"
+}
+
+// Projects reads the TypeScript projects of workspaces, once each
+type Projects struct {
+	repoRoot turbopath.AbsoluteSystemPath
+
+	mu      sync.Mutex
+	outputs map[turbopath.AnchoredSystemPath]projectOutputs
+}
+
+type projectOutputs struct {
+	globs  []string
+	exists bool
+	err    error
+}
+
+// NewProjects returns Projects for the workspaces of the repository at repoRoot
+func NewProjects(repoRoot turbopath.AbsoluteSystemPath) *Projects {
+	return &Projects{
+		repoRoot: repoRoot,
+		outputs:  make(map[turbopath.AnchoredSystemPath]projectOutputs),
+	}
+}
+
+// Outputs returns the globs, relative to the workspace in dir, of the files that
+// `tsc --build` writes for the workspace: its .tsbuildinfo file, and its outDir and
+// declarationDir. Files outside of the workspace are left out. Returns false if
+// the workspace has no tsconfig.json.
+func (p *Projects) Outputs(dir turbopath.AnchoredSystemPath) ([]string, bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if outputs, ok := p.outputs[dir]; ok {
+		return outputs.globs, outputs.exists, outputs.err
+	}
+	globs, exists, err := p.readOutputs(dir)
+	p.outputs[dir] = projectOutputs{globs: globs, exists: exists, err: err}
+	return globs, exists, err
+}
+
+func (p *Projects) readOutputs(dir turbopath.AnchoredSystemPath) ([]string, bool, error) {
+	workspaceDir := dir.RestoreAnchor(p.repoRoot)
+	configPath := workspaceDir.UntypedJoin(ConfigName)
+	if !configPath.FileExists() {
+		return nil, false, nil
+	}
+	options, err := readOptions(configPath, 0)
+	if err != nil {
+		return nil, true, err
+	}
+	var globs []string
+	add := func(path string, glob string) {
+		relative, err := filepath.Rel(workspaceDir.ToString(), path)
+		if err != nil || relative == "." || relative == ".." || strings.HasPrefix(relative, ".."+string(filepath.Separator)) {
+			return
+		}
+		globs = append(globs, filepath.ToSlash(relative)+glob)
+	}
+	add(options.buildInfoFile(configPath), "")
+	if options.outDir != "" {
+		add(options.outDir, "/**")
+	}
+	if options.declarationDir != "" {
+		add(options.declarationDir, "/**")
+	}
+	return globs, true, nil
+}
@@ -0,0 +1,93 @@
+package typescript
+
+import (
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"gotest.tools/v3/assert"
+)
+
+func Test_Outputs(t *testing.T) {
+	testCases := []struct {
+		name   string
+		files  map[string]string
+		want   []string
+		exists bool
+	}{
+		{
+			name:   "no tsconfig.json",
+			files:  map[string]string{},
+			exists: false,
+		},
+		{
+			name: "build info next to the config",
+			files: map[string]string{
+				"packages/ui/tsconfig.json": `{"compilerOptions": {"composite": true}}`,
+			},
+			want:   []string{"tsconfig.tsbuildinfo"},
+			exists: true,
+		},
+		{
+			name: "build info in outDir, relative to rootDir",
+			files: map[string]string{
+				"packages/ui/tsconfig.json": `{
+					// comments and trailing commas are allowed
+					"compilerOptions": {"outDir": "dist", "rootDir": ".", "declarationDir": "types",},
+				}`,
+			},
+			want:   []string{"dist/tsconfig.tsbuildinfo", "dist/**", "types/**"},
+			exists: true,
+		},
+		{
+			name: "config outside of rootDir",
+			files: map[string]string{
+				"packages/ui/tsconfig.json": `{"compilerOptions": {"outDir": "dist", "rootDir": "src"}}`,
+			},
+			// tsc resolves the config relative to rootDir, which leads out of outDir
+			want:   []string{"tsconfig.tsbuildinfo", "dist/**"},
+			exists: true,
+		},
+		{
+			name: "build info in outDir, without rootDir",
+			files: map[string]string{
+				"packages/ui/tsconfig.json": `{"compilerOptions": {"outDir": "dist"}}`,
+			},
+			want:   []string{"dist/tsconfig.tsbuildinfo", "dist/**"},
+			exists: true,
+		},
+		{
+			name: "options from an extended config",
+			files: map[string]string{
+				"tsconfig.base.json":        `{"compilerOptions": {"outDir": "base-dist", "tsBuildInfoFile": "shared.tsbuildinfo"}}`,
+				"packages/ui/tsconfig.json": `{"extends": "../../tsconfig.base", "compilerOptions": {"outDir": "lib"}}`,
+			},
+			// tsBuildInfoFile is relative to the config that sets it, so it is outside
+			// of the workspace
+			want:   []string{"lib/**"},
+			exists: true,
+		},
+		{
+			name: "explicit tsBuildInfoFile",
+			files: map[string]string{
+				"packages/ui/tsconfig.json": `{"extends": ["@tsconfig/node18"], "compilerOptions": {"tsBuildInfoFile": ".turbo/tsc.tsbuildinfo"}}`,
+			},
+			want:   []string{".turbo/tsc.tsbuildinfo"},
+			exists: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repoRoot := turbopath.AbsoluteSystemPathFromUpstream(t.TempDir())
+			for name, content := range tc.files {
+				path := repoRoot.UntypedJoin(name)
+				assert.NilError(t, path.EnsureDir())
+				assert.NilError(t, path.WriteFile([]byte(content), 0644))
+			}
+			projects := NewProjects(repoRoot)
+			outputs, exists, err := projects.Outputs(turbopath.AnchoredSystemPath("packages/ui"))
+			assert.NilError(t, err)
+			assert.Equal(t, exists, tc.exists)
+			assert.DeepEqual(t, outputs, tc.want)
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package util
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// _byteSizeUnits are the suffixes accepted by ParseByteSize, longest first so
+// that "MB" is matched before "B". Units are powers of 1024.
+var _byteSizeUnits = []struct {
+	suffix     string
+	multiplier int64
+}{
+	{"KB", 1 << 10},
+	{"MB", 1 << 20},
+	{"GB", 1 << 30},
+	{"TB", 1 << 40},
+	{"K", 1 << 10},
+	{"M", 1 << 20},
+	{"G", 1 << 30},
+	{"T", 1 << 40},
+	{"B", 1},
+}
+
+// ParseByteSize parses a size such as "512", "100KB", or "1.5GB" into a number of bytes
+func ParseByteSize(raw string) (int64, error) {
+	value := strings.ToUpper(strings.TrimSpace(raw))
+	multiplier := int64(1)
+	for _, unit := range _byteSizeUnits {
+		if strings.HasSuffix(value, unit.suffix) {
+			value = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix))
+			multiplier = unit.multiplier
+			break
+		}
+	}
+	size, err := strconv.ParseFloat(value, 64)
+	// ParseFloat accepts "NaN" and "Inf", and a large size can overflow int64 once multiplied
+	bytes := size * float64(multiplier)
+	if err != nil || math.IsNaN(size) || size < 0 || bytes >= math.MaxInt64 {
+		return 0, fmt.Errorf("invalid size %q, expected a number of bytes optionally followed by KB, MB, GB, or TB", raw)
+	}
+	return int64(bytes), nil
+}
+
+// FormatByteSize renders a number of bytes in the largest unit that ParseByteSize accepts
+func FormatByteSize(size int64) string {
+	switch {
+	case size >= 1<<40:
+		return fmt.Sprintf("%.1fTB", float64(size)/(1<<40))
+	case size >= 1<<30:
+		return fmt.Sprintf("%.1fGB", float64(size)/(1<<30))
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1fMB", float64(size)/(1<<20))
+	case size >= 1<<10:
+		return fmt.Sprintf("%.1fKB", float64(size)/(1<<10))
+	}
+	return fmt.Sprintf("%dB", size)
+}
@@ -0,0 +1,40 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseByteSize(t *testing.T) {
+	cases := []struct {
+		Input    string
+		Expected int64
+	}{
+		{"512", 512},
+		{"512B", 512},
+		{"100KB", 100 * 1024},
+		{"100kb", 100 * 1024},
+		{"1.5GB", 3 * 512 * 1024 * 1024},
+		{"20 MB", 20 * 1024 * 1024},
+		{"2M", 2 * 1024 * 1024},
+	}
+	for _, tc := range cases {
+		t.Run(tc.Input, func(t *testing.T) {
+			size, err := ParseByteSize(tc.Input)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.Expected, size)
+		})
+	}
+
+	for _, input := range []string{"", "MB", "-1KB", "ten", "NaN", "nanKB", "Inf", "+InfGB", "-Inf", "1e300", "9000000TB"} {
+		_, err := ParseByteSize(input)
+		assert.Error(t, err, input)
+	}
+}
+
+func TestFormatByteSize(t *testing.T) {
+	assert.Equal(t, "512B", FormatByteSize(512))
+	assert.Equal(t, "1.5KB", FormatByteSize(1536))
+	assert.Equal(t, "20.0MB", FormatByteSize(20*1024*1024))
+}
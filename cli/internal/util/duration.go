@@ -0,0 +1,35 @@
+package util
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// _longDurationUnits are the units that ParseDuration accepts on top of those of
+// time.ParseDuration
+var _longDurationUnits = map[string]time.Duration{
+	"d": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour,
+}
+
+// ParseDuration parses a duration such as "90m" or "12h" as time.ParseDuration
+// does, and also accepts days and weeks, such as "30d" or "2w"
+func ParseDuration(raw string) (time.Duration, error) {
+	value := strings.TrimSpace(raw)
+	for suffix, unit := range _longDurationUnits {
+		if number := strings.TrimSuffix(value, suffix); number != value {
+			count, err := strconv.ParseFloat(number, 64)
+			if err != nil || count < 0 {
+				break
+			}
+			return time.Duration(count * float64(unit)), nil
+		}
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration < 0 {
+		return 0, fmt.Errorf("invalid duration %q, expected a number followed by a unit such as m, h, d, or w", raw)
+	}
+	return duration, nil
+}
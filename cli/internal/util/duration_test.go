@@ -0,0 +1,33 @@
+package util
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDuration(t *testing.T) {
+	cases := []struct {
+		Input    string
+		Expected time.Duration
+	}{
+		{"90m", 90 * time.Minute},
+		{"12h", 12 * time.Hour},
+		{"30d", 30 * 24 * time.Hour},
+		{"1.5d", 36 * time.Hour},
+		{"2w", 14 * 24 * time.Hour},
+	}
+	for _, tc := range cases {
+		t.Run(tc.Input, func(t *testing.T) {
+			duration, err := ParseDuration(tc.Input)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.Expected, duration)
+		})
+	}
+
+	for _, input := range []string{"", "d", "-1d", "30", "ten days"} {
+		_, err := ParseDuration(input)
+		assert.Error(t, err, input)
+	}
+}
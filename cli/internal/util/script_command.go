@@ -0,0 +1,132 @@
+package util
+
+import (
+	"strings"
+)
+
+// _shellOperators are characters that, when unquoted, require a real shell to interpret
+const _shellOperators = "|&;<>()`\\*?[]{}~#!\n"
+
+// SplitScriptCommand splits a package.json script into an argv that can be executed
+// without a shell. Single and double quotes are supported, as are $NAME and ${NAME}
+// environment variable references, which are expanded using getenv. If the script
+// uses any other shell syntax, such as pipes, redirects, globs, subshells, or
+// leading variable assignments, ok is false and the script should be run by a shell.
+func SplitScriptCommand(command string, getenv func(string) string) (argv []string, ok bool) {
+	var word strings.Builder
+	inWord := false
+	// quoted tracks whether the current word contained quotes, in which case an
+	// empty word is still an argument
+	quoted := false
+	endWord := func() {
+		if inWord && (quoted || word.Len() > 0) {
+			argv = append(argv, word.String())
+		}
+		word.Reset()
+		inWord = false
+		quoted = false
+	}
+
+	for i := 0; i < len(command); i++ {
+		c := command[i]
+		switch {
+		case c == ' ' || c == '\t':
+			endWord()
+		case c == '\'':
+			end := strings.IndexByte(command[i+1:], '\'')
+			if end == -1 {
+				return nil, false
+			}
+			word.WriteString(command[i+1 : i+1+end])
+			inWord, quoted = true, true
+			i += end + 1
+		case c == '"':
+			inWord, quoted = true, true
+			closed := false
+			for i++; i < len(command); i++ {
+				c = command[i]
+				if c == '"' {
+					closed = true
+					break
+				}
+				if c == '`' || c == '\\' {
+					return nil, false
+				}
+				if c == '$' {
+					value, n, ok := expandVariable(command[i:], getenv)
+					if !ok {
+						return nil, false
+					}
+					word.WriteString(value)
+					i += n - 1
+					continue
+				}
+				word.WriteByte(c)
+			}
+			if !closed {
+				return nil, false
+			}
+		case c == '$':
+			value, n, ok := expandVariable(command[i:], getenv)
+			// Unquoted expansions are subject to word splitting and globbing
+			if !ok || strings.ContainsAny(value, " \t\n*?[") {
+				return nil, false
+			}
+			word.WriteString(value)
+			inWord = true
+			i += n - 1
+		case c == '=' && !quoted && len(argv) == 0:
+			// a leading NAME=value assigns an environment variable
+			return nil, false
+		case strings.IndexByte(_shellOperators, c) != -1:
+			return nil, false
+		default:
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+	endWord()
+	if len(argv) == 0 {
+		return nil, false
+	}
+	return argv, true
+}
+
+// expandVariable expands a $NAME or ${NAME} reference at the start of s, returning
+// the value and the number of bytes consumed.
+func expandVariable(s string, getenv func(string) string) (string, int, bool) {
+	if len(s) > 1 && s[1] == '{' {
+		end := strings.IndexByte(s, '}')
+		if end == -1 || !isVariableName(s[2:end]) {
+			return "", 0, false
+		}
+		return getenv(s[2:end]), end + 1, true
+	}
+	n := 1
+	for n < len(s) && isVariableNameChar(s[n], n == 1) {
+		n++
+	}
+	if n == 1 {
+		return "", 0, false
+	}
+	return getenv(s[1:n]), n, true
+}
+
+func isVariableName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if !isVariableNameChar(name[i], i == 0) {
+			return false
+		}
+	}
+	return true
+}
+
+func isVariableNameChar(c byte, first bool) bool {
+	if c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') {
+		return true
+	}
+	return !first && c >= '0' && c <= '9'
+}
@@ -0,0 +1,54 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitScriptCommand(t *testing.T) {
+	env := map[string]string{
+		"NODE_ENV": "production",
+		"SPACED":   "a b",
+		"EMPTY":    "",
+	}
+	getenv := func(name string) string {
+		return env[name]
+	}
+	cases := []struct {
+		Name     string
+		Command  string
+		Expected []string
+		OK       bool
+	}{
+		{"simple", "tsc", []string{"tsc"}, true},
+		{"args", "tsc  --build\ttsconfig.json", []string{"tsc", "--build", "tsconfig.json"}, true},
+		{"single quotes", "echo 'hello $NODE_ENV'", []string{"echo", "hello $NODE_ENV"}, true},
+		{"double quotes", `echo "hello $NODE_ENV"`, []string{"echo", "hello production"}, true},
+		{"braces", "next build --mode=${NODE_ENV}", []string{"next", "build", "--mode=production"}, true},
+		{"empty quoted arg", `node script.js ""`, []string{"node", "script.js", ""}, true},
+		{"empty unquoted expansion", "node script.js $EMPTY", []string{"node", "script.js"}, true},
+		{"quoted expansion with spaces", `echo "$SPACED"`, []string{"echo", "a b"}, true},
+		{"unquoted expansion with spaces", "echo $SPACED", nil, false},
+		{"and", "tsc && jest", nil, false},
+		{"pipe", "cat file | grep x", nil, false},
+		{"redirect", "echo hi > out.txt", nil, false},
+		{"glob", "rm -rf dist/*", nil, false},
+		{"subshell", "echo $(pwd)", nil, false},
+		{"backticks", "echo `pwd`", nil, false},
+		{"escape", `echo a\ b`, nil, false},
+		{"assignment", "NODE_ENV=test jest", nil, false},
+		{"positional", "echo $1", nil, false},
+		{"default value", "echo ${NODE_ENV:-dev}", nil, false},
+		{"unterminated quote", "echo 'oops", nil, false},
+		{"empty", "  ", nil, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			argv, ok := SplitScriptCommand(tc.Command, getenv)
+			assert.Equal(t, tc.OK, ok)
+			assert.Equal(t, tc.Expected, argv)
+		})
+	}
+}
@@ -1,5 +1,7 @@
 package util
 
+import "context"
+
 // Semaphore is a wrapper around a channel to provide
 // utility methods to clarify that we are treating the
 // channel as a semaphore
@@ -21,6 +23,18 @@ func (s Semaphore) Acquire() {
 	s <- struct{}{}
 }
 
+// AcquireContext is used to acquire an available slot.
+// Blocks until available, or until ctx is done, in which
+// case it returns ctx.Err() without acquiring a slot.
+func (s Semaphore) AcquireContext(ctx context.Context) error {
+	select {
+	case s <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 // TryAcquire is used to do a non-blocking acquire.
 // Returns a bool indicating success
 func (s Semaphore) TryAcquire() bool {
@@ -1,6 +1,9 @@
 package util
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
 
 // CachingStatus represents the api server's perspective
 // on whether remote caching should be allowed
@@ -45,3 +48,7 @@ type CacheDisabledError struct {
 func (cd *CacheDisabledError) Error() string {
 	return cd.Message
 }
+
+// ErrTooManyFailures is returned from requests to the Remote Cache once enough of them
+// have failed that turbo stops sending them, and continues with the local cache only
+var ErrTooManyFailures = errors.New("skipping HTTP Request, too many failures have occurred")
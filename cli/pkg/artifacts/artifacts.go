@@ -0,0 +1,415 @@
+// Package artifacts is a client for the Remote Cache artifact API. It is used by
+// turbo itself, and can be used by other tools that need to read or write the
+// same artifacts, such as custom uploaders or cache warmers.
+//
+// Artifacts are addressed by the hash of the task that produced them. Their
+// contents are opaque to this package; turbo stores zstd-compressed tarballs.
+package artifacts
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ErrNotFound is returned when the requested artifact is not in the Remote Cache
+var ErrNotFound = errors.New("artifact not found")
+
+// ErrExists is returned by PutArtifact when the store already has an artifact for
+// the hash and only creates artifacts, rather than replacing them
+var ErrExists = errors.New("artifact already exists")
+
+// APIError is returned when the Remote Cache responds with an unexpected status
+type APIError struct {
+	StatusCode int
+	// Code and Message are populated from the response body, if it has them
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error implements error.Error
+func (e *APIError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("remote cache responded with %v: %v", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("remote cache responded with %v", e.StatusCode)
+}
+
+// Metadata describes an artifact
+type Metadata struct {
+	// Duration is how long, in milliseconds, the task that produced the artifact took
+	Duration int
+	// Tag is the signature of the artifact, if artifact signing is in use
+	Tag string
+	// Digest is the content digest of the artifact, in the form "sha256:<hex>".
+	// It is empty if the Remote Cache did not report one.
+	Digest string
+	// KeyID identifies the key that encrypted the artifact, if artifact encryption
+	// is in use
+	KeyID string
+	// PlaintextDigest is the digest of the artifact before it was encrypted, if
+	// artifact encryption is in use. Unlike Digest, it's the same for every
+	// upload of the same contents.
+	PlaintextDigest string
+	// HashSchema is the version of the hash schema that the artifact's hash was
+	// calculated with, or 0 if the client that uploaded it didn't report one
+	HashSchema int
+}
+
+// Artifact is an artifact downloaded from the Remote Cache. Callers must close Body.
+type Artifact struct {
+	Metadata
+	Body io.ReadCloser
+}
+
+// API is the set of operations on artifacts. It is implemented by Client, and
+// exists so that consumers can substitute a fake in tests.
+type API interface {
+	// ArtifactExists returns true if the Remote Cache has an artifact for hash
+	ArtifactExists(ctx context.Context, hash string) (bool, error)
+	// ArtifactMetadata returns the metadata of the artifact for hash, without
+	// downloading it. It returns ErrNotFound if there is no such artifact.
+	ArtifactMetadata(ctx context.Context, hash string) (*Metadata, error)
+	// FetchArtifact downloads the artifact for hash, streaming its contents.
+	// It returns ErrNotFound if there is no such artifact.
+	FetchArtifact(ctx context.Context, hash string) (*Artifact, error)
+	// PutArtifact uploads the contents of body as the artifact for hash
+	PutArtifact(ctx context.Context, hash string, body io.Reader, metadata Metadata) error
+}
+
+// Doer sends HTTP requests. *http.Client satisfies it.
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Config holds the endpoint and credentials for a Client
+type Config struct {
+	// BaseURL is the URL of the API, e.g. "https://vercel.com/api"
+	BaseURL string
+	// Token is sent as a bearer token with every request
+	Token string
+	// TeamID and TeamSlug select the team whose artifacts are accessed
+	TeamID   string
+	TeamSlug string
+	// UserAgent is sent with every request, if set
+	UserAgent string
+	// UsePreflight precedes each request with an OPTIONS request, for
+	// Remote Caches that sit behind a CORS proxy
+	UsePreflight bool
+	// HTTPClient sends requests. It defaults to http.DefaultClient.
+	HTTPClient Doer
+	// MultipartThreshold is the size above which artifacts are transferred in
+	// parts, in parallel. It defaults to 64 MiB.
+	MultipartThreshold int64
+	// PartSize is the smallest size of each part. Parts grow for large artifacts,
+	// so that none has more than 1000 parts. It defaults to 16 MiB.
+	PartSize int64
+	// PartConcurrency is how many parts are transferred at once. It defaults to 8.
+	PartConcurrency int
+}
+
+// Client is a client for the Remote Cache artifact API
+type Client struct {
+	config Config
+}
+
+var _ API = &Client{}
+
+// New creates a Client
+func New(config Config) *Client {
+	if config.HTTPClient == nil {
+		config.HTTPClient = http.DefaultClient
+	}
+	if config.MultipartThreshold <= 0 {
+		config.MultipartThreshold = _defaultMultipartThreshold
+	}
+	if config.PartSize <= 0 {
+		config.PartSize = _defaultPartSize
+	}
+	if config.PartConcurrency <= 0 {
+		config.PartConcurrency = _defaultPartConcurrency
+	}
+	return &Client{config: config}
+}
+
+// ArtifactExists implements API.ArtifactExists
+func (c *Client) ArtifactExists(ctx context.Context, hash string) (bool, error) {
+	resp, err := c.do(ctx, http.MethodHead, url.PathEscape(hash), nil, nil, "Authorization, User-Agent")
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, newAPIError(resp)
+	}
+}
+
+// ArtifactMetadata implements API.ArtifactMetadata
+func (c *Client) ArtifactMetadata(ctx context.Context, hash string) (*Metadata, error) {
+	resp, err := c.do(ctx, http.MethodHead, url.PathEscape(hash), nil, nil, "Authorization, User-Agent")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return metadataFromHeader(resp.Header)
+	case http.StatusNotFound:
+		return nil, ErrNotFound
+	default:
+		return nil, newAPIError(resp)
+	}
+}
+
+// metadataFromHeader reads the metadata of an artifact from the headers of a
+// response for it
+func metadataFromHeader(header http.Header) (*Metadata, error) {
+	metadata := &Metadata{
+		Tag:             header.Get("x-artifact-tag"),
+		Digest:          header.Get("x-artifact-digest"),
+		KeyID:           header.Get("x-artifact-key-id"),
+		PlaintextDigest: header.Get("x-artifact-plaintext-digest"),
+	}
+	var err error
+	if duration := header.Get("x-artifact-duration"); duration != "" {
+		metadata.Duration, err = strconv.Atoi(duration)
+		if err != nil {
+			return nil, fmt.Errorf("invalid x-artifact-duration header: %w", err)
+		}
+	}
+	if hashSchema := header.Get("x-artifact-hash-schema"); hashSchema != "" {
+		metadata.HashSchema, err = strconv.Atoi(hashSchema)
+		if err != nil {
+			return nil, fmt.Errorf("invalid x-artifact-hash-schema header: %w", err)
+		}
+	}
+	return metadata, nil
+}
+
+// FetchArtifact implements API.FetchArtifact. The first MultipartThreshold bytes of
+// the artifact are requested as a range. If the Remote Cache has more, the rest is
+// downloaded in parts, in parallel, as the body is read. If the Remote Cache reports
+// the artifact's digest, reading the body to the end fails if it doesn't match.
+func (c *Client) FetchArtifact(ctx context.Context, hash string) (*Artifact, error) {
+	path := url.PathEscape(hash)
+	headers := http.Header{}
+	headers.Set("Range", fmt.Sprintf("bytes=0-%v", c.config.MultipartThreshold-1))
+	resp, err := c.do(ctx, http.MethodGet, path, nil, headers, "Authorization, User-Agent, Range")
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		// Only an empty artifact has no first byte
+		_ = resp.Body.Close()
+		resp, err = c.do(ctx, http.MethodGet, path, nil, nil, "Authorization, User-Agent")
+		if err != nil {
+			return nil, err
+		}
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		_ = resp.Body.Close()
+		return nil, ErrNotFound
+	} else if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		defer func() { _ = resp.Body.Close() }()
+		return nil, newAPIError(resp)
+	}
+	metadata, err := metadataFromHeader(resp.Header)
+	if err != nil {
+		_ = resp.Body.Close()
+		return nil, err
+	}
+	artifact := &Artifact{
+		Metadata: *metadata,
+		Body:     resp.Body,
+	}
+	if resp.StatusCode == http.StatusPartialContent {
+		start, end, size, err := parseContentRange(resp.Header.Get("Content-Range"))
+		if err == nil && start != 0 {
+			err = fmt.Errorf("requested the start of the artifact, got bytes %v-%v/%v", start, end, size)
+		}
+		if err != nil {
+			_ = resp.Body.Close()
+			return nil, err
+		}
+		if end+1 < size {
+			artifact.Body = c.fetchParts(ctx, path, artifact.Body, end+1, size)
+		}
+	}
+	artifact.Body = VerifyDigest(artifact.Body, artifact.Digest)
+	return artifact, nil
+}
+
+// VerifyDigest returns body, checking as it's read that it has the given digest,
+// in the form "sha256:<hex>". The Read that reaches the end of body fails if the
+// digest doesn't match, so callers that stream it must read it to the end before
+// trusting it. body is returned as is if digest is empty, or if it's already checked.
+func VerifyDigest(body io.ReadCloser, digest string) io.ReadCloser {
+	if digest == "" {
+		return body
+	}
+	if verified, ok := body.(*verifiedBody); ok && verified.expected == digest {
+		return body
+	}
+	return &verifiedBody{ReadCloser: body, hash: sha256.New(), expected: digest}
+}
+
+// verifiedBody checks the digest of an artifact as it is read
+type verifiedBody struct {
+	io.ReadCloser
+	hash     hash.Hash
+	expected string
+}
+
+// Read implements io.Reader.Read
+func (vb *verifiedBody) Read(p []byte) (int, error) {
+	n, err := vb.ReadCloser.Read(p)
+	_, _ = vb.hash.Write(p[:n])
+	if err == io.EOF {
+		if actual := "sha256:" + hex.EncodeToString(vb.hash.Sum(nil)); actual != vb.expected {
+			return n, fmt.Errorf("downloaded artifact has digest %v, expected %v", actual, vb.expected)
+		}
+	}
+	return n, err
+}
+
+// PutArtifact implements API.PutArtifact. If body is a *bytes.Reader, *bytes.Buffer,
+// or *strings.Reader, its length is sent. Otherwise the upload is chunked. A
+// *bytes.Reader or *strings.Reader larger than MultipartThreshold is uploaded in
+// parts, in parallel, if the Remote Cache supports it.
+func (c *Client) PutArtifact(ctx context.Context, hash string, body io.Reader, metadata Metadata) error {
+	if sized, ok := body.(sizedReaderAt); ok && sized.Size() > c.config.MultipartThreshold {
+		err := c.putParts(ctx, url.PathEscape(hash), sized, metadata)
+		if !errors.Is(err, errPartsUnsupported) {
+			return err
+		}
+		body = io.NewSectionReader(sized, 0, sized.Size())
+	}
+	headers := http.Header{}
+	headers.Set("Content-Type", "application/octet-stream")
+	headers.Set("x-artifact-duration", strconv.Itoa(metadata.Duration))
+	if metadata.Tag != "" {
+		headers.Set("x-artifact-tag", metadata.Tag)
+	}
+	if metadata.Digest != "" {
+		headers.Set("x-artifact-digest", metadata.Digest)
+	}
+	if metadata.KeyID != "" {
+		headers.Set("x-artifact-key-id", metadata.KeyID)
+	}
+	if metadata.PlaintextDigest != "" {
+		headers.Set("x-artifact-plaintext-digest", metadata.PlaintextDigest)
+	}
+	if metadata.HashSchema != 0 {
+		headers.Set("x-artifact-hash-schema", strconv.Itoa(metadata.HashSchema))
+	}
+	resp, err := c.do(ctx, http.MethodPut, url.PathEscape(hash), body, headers, "Content-Type, x-artifact-duration, Authorization, User-Agent, x-artifact-tag, x-artifact-digest, x-artifact-key-id, x-artifact-plaintext-digest, x-artifact-hash-schema")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return newAPIError(resp)
+	}
+	return nil
+}
+
+// artifactURL returns the URL of path, which is relative to the artifacts endpoint
+func (c *Client) artifactURL(path string) string {
+	params := url.Values{}
+	if strings.HasPrefix(c.config.TeamID, "team_") {
+		params.Add("teamId", c.config.TeamID)
+	}
+	if c.config.TeamSlug != "" {
+		params.Add("slug", c.config.TeamSlug)
+	}
+	// only add a ? if it's actually needed (makes logging cleaner)
+	encoded := params.Encode()
+	if encoded != "" {
+		encoded = "?" + encoded
+	}
+	return c.config.BaseURL + "/v8/artifacts/" + path + encoded
+}
+
+func (c *Client) do(ctx context.Context, method string, path string, body io.Reader, headers http.Header, preflightHeaders string) (*http.Response, error) {
+	requestURL := c.artifactURL(path)
+	allowAuth := true
+	if c.config.UsePreflight {
+		var err error
+		requestURL, allowAuth, err = c.preflight(ctx, requestURL, method, preflightHeaders)
+		if err != nil {
+			return nil, fmt.Errorf("pre-flight request failed: %w", err)
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, requestURL, body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cache URL: %w", err)
+	}
+	if section, ok := body.(*io.SectionReader); ok {
+		// http.NewRequest only knows the length of in-memory readers
+		req.ContentLength = section.Size()
+	}
+	for key, values := range headers {
+		req.Header[key] = values
+	}
+	if allowAuth {
+		req.Header.Set("Authorization", "Bearer "+c.config.Token)
+	}
+	if c.config.UserAgent != "" {
+		req.Header.Set("User-Agent", c.config.UserAgent)
+	}
+	return c.config.HTTPClient.Do(req)
+}
+
+// preflight sends an OPTIONS request for requestURL, and returns the URL to use after
+// following any redirects, and whether the Authorization header is allowed
+func (c *Client) preflight(ctx context.Context, requestURL string, method string, requestHeaders string) (string, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodOptions, requestURL, nil)
+	if err != nil {
+		return "", false, fmt.Errorf("invalid cache URL: %w", err)
+	}
+	if c.config.UserAgent != "" {
+		req.Header.Set("User-Agent", c.config.UserAgent)
+	}
+	req.Header.Set("Access-Control-Request-Method", method)
+	req.Header.Set("Access-Control-Request-Headers", requestHeaders)
+	req.Header.Set("Authorization", "Bearer "+c.config.Token)
+	resp, err := c.config.HTTPClient.Do(req)
+	if resp == nil {
+		return "", false, err
+	}
+	// If there is a response, ignore any error and let the actual request report problems
+	defer func() { _ = resp.Body.Close() }()
+	if locationURL, err := resp.Location(); err == nil {
+		requestURL = locationURL.String()
+	} else {
+		requestURL = resp.Request.URL.String()
+	}
+	allowedHeaders := strings.ToLower(resp.Header.Get("Access-Control-Allow-Headers"))
+	return requestURL, strings.Contains(allowedHeaders, "authorization"), nil
+}
+
+func newAPIError(resp *http.Response) *APIError {
+	apiErr := &APIError{}
+	if raw, err := ioutil.ReadAll(resp.Body); err == nil {
+		if err := json.Unmarshal(raw, apiErr); err != nil {
+			apiErr.Message = strings.TrimSpace(string(raw))
+		}
+	}
+	apiErr.StatusCode = resp.StatusCode
+	return apiErr
+}
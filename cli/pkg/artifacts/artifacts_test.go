@@ -0,0 +1,297 @@
+package artifacts
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"gotest.tools/v3/assert"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	stored := make(map[string][]byte)
+	tags := make(map[string]string)
+	digests := make(map[string]string)
+	keyIDs := make(map[string]string)
+	plaintextDigests := make(map[string]string)
+	schemas := make(map[string]string)
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Authorization") != "Bearer my-token" {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"code": "forbidden", "message": "bad token"}`))
+			return
+		}
+		if req.URL.Query().Get("teamId") != "team_123" {
+			t.Errorf("teamId got %v, want team_123", req.URL.Query().Get("teamId"))
+		}
+		hash := strings.TrimPrefix(req.URL.Path, "/v8/artifacts/")
+		switch req.Method {
+		case http.MethodPut:
+			body, err := ioutil.ReadAll(req.Body)
+			assert.NilError(t, err, "ReadAll")
+			stored[hash] = body
+			tags[hash] = req.Header.Get("x-artifact-tag")
+			digests[hash] = req.Header.Get("x-artifact-digest")
+			keyIDs[hash] = req.Header.Get("x-artifact-key-id")
+			plaintextDigests[hash] = req.Header.Get("x-artifact-plaintext-digest")
+			schemas[hash] = req.Header.Get("x-artifact-hash-schema")
+			w.WriteHeader(http.StatusAccepted)
+		case http.MethodGet, http.MethodHead:
+			body, ok := stored[hash]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Header().Set("x-artifact-duration", "500")
+			w.Header().Set("x-artifact-tag", tags[hash])
+			w.Header().Set("x-artifact-digest", digests[hash])
+			w.Header().Set("x-artifact-key-id", keyIDs[hash])
+			w.Header().Set("x-artifact-plaintext-digest", plaintextDigests[hash])
+			if schemas[hash] != "" {
+				w.Header().Set("x-artifact-hash-schema", schemas[hash])
+			}
+			_, _ = w.Write(body)
+		}
+	}))
+}
+
+func TestClient(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	client := New(Config{BaseURL: ts.URL, Token: "my-token", TeamID: "team_123"})
+	ctx := context.Background()
+
+	exists, err := client.ArtifactExists(ctx, "some-hash")
+	assert.NilError(t, err, "ArtifactExists")
+	assert.Equal(t, exists, false)
+	_, err = client.FetchArtifact(ctx, "some-hash")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = client.ArtifactMetadata(ctx, "some-hash")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	sum := sha256.Sum256([]byte("contents"))
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+	err = client.PutArtifact(ctx, "some-hash", bytes.NewReader([]byte("contents")), Metadata{Duration: 500, Tag: "signature", Digest: digest, KeyID: "key-1", PlaintextDigest: "sha256:plain", HashSchema: 1})
+	assert.NilError(t, err, "PutArtifact")
+
+	exists, err = client.ArtifactExists(ctx, "some-hash")
+	assert.NilError(t, err, "ArtifactExists")
+	assert.Equal(t, exists, true)
+	metadata, err := client.ArtifactMetadata(ctx, "some-hash")
+	assert.NilError(t, err, "ArtifactMetadata")
+	assert.DeepEqual(t, *metadata, Metadata{Duration: 500, Tag: "signature", Digest: digest, KeyID: "key-1", PlaintextDigest: "sha256:plain", HashSchema: 1})
+	artifact, err := client.FetchArtifact(ctx, "some-hash")
+	assert.NilError(t, err, "FetchArtifact")
+	defer func() { _ = artifact.Body.Close() }()
+	body, err := ioutil.ReadAll(artifact.Body)
+	assert.NilError(t, err, "ReadAll")
+	assert.Equal(t, string(body), "contents")
+	assert.Equal(t, artifact.Duration, 500)
+	assert.Equal(t, artifact.Tag, "signature")
+	assert.Equal(t, artifact.Digest, digest)
+	assert.Equal(t, artifact.KeyID, "key-1")
+	assert.Equal(t, artifact.HashSchema, 1)
+
+	// The digest is checked as the artifact is read
+	err = client.PutArtifact(ctx, "other-hash", bytes.NewReader([]byte("contents")), Metadata{Digest: "sha256:" + strings.Repeat("0", 64)})
+	assert.NilError(t, err, "PutArtifact")
+	mismatched, err := client.FetchArtifact(ctx, "other-hash")
+	assert.NilError(t, err, "FetchArtifact")
+	defer func() { _ = mismatched.Body.Close() }()
+	_, err = ioutil.ReadAll(mismatched.Body)
+	assert.ErrorContains(t, err, "downloaded artifact has digest "+digest)
+}
+
+func TestClientAPIError(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	client := New(Config{BaseURL: ts.URL, Token: "wrong-token", TeamID: "team_123"})
+
+	_, err := client.FetchArtifact(context.Background(), "some-hash")
+	var apiErr *APIError
+	assert.Assert(t, errors.As(err, &apiErr))
+	assert.Equal(t, apiErr.StatusCode, http.StatusForbidden)
+	assert.Equal(t, apiErr.Code, "forbidden")
+	assert.Equal(t, apiErr.Message, "bad token")
+}
+
+// newMultipartTestServer serves artifacts with support for ranges and, if
+// acceptParts is set, for uploads in parts
+func newMultipartTestServer(t *testing.T, acceptParts bool) (*httptest.Server, map[string][]byte, *int32) {
+	var mu sync.Mutex
+	stored := make(map[string][]byte)
+	parts := make(map[string][]byte)
+	var partRequests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		path := strings.TrimPrefix(req.URL.Path, "/v8/artifacts/")
+		hash, partPath, isPart := strings.Cut(path, "/")
+		if isPart {
+			if !acceptParts {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			body, err := ioutil.ReadAll(req.Body)
+			assert.NilError(t, err, "ReadAll")
+			switch req.Method {
+			case http.MethodPut:
+				atomic.AddInt32(&partRequests, 1)
+				sum := sha256.Sum256(body)
+				if req.Header.Get("x-artifact-part-digest") != "sha256:"+hex.EncodeToString(sum[:]) {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				parts[hash+"/"+strings.TrimPrefix(partPath, "parts/")] = body
+				w.WriteHeader(http.StatusAccepted)
+			case http.MethodPost:
+				var manifest struct {
+					Parts []artifactPart `json:"parts"`
+				}
+				assert.NilError(t, json.Unmarshal(body, &manifest), "Unmarshal")
+				var artifact []byte
+				for _, part := range manifest.Parts {
+					artifact = append(artifact, parts[fmt.Sprintf("%v/%v", hash, part.Number)]...)
+				}
+				stored[hash] = artifact
+				w.WriteHeader(http.StatusCreated)
+			}
+			return
+		}
+		switch req.Method {
+		case http.MethodPut:
+			body, err := ioutil.ReadAll(req.Body)
+			assert.NilError(t, err, "ReadAll")
+			stored[hash] = body
+			w.WriteHeader(http.StatusAccepted)
+		case http.MethodGet:
+			body, ok := stored[hash]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			sum := sha256.Sum256(body)
+			w.Header().Set("x-artifact-digest", "sha256:"+hex.EncodeToString(sum[:]))
+			http.ServeContent(w, req, "", time.Time{}, bytes.NewReader(body))
+		}
+	}))
+	return ts, stored, &partRequests
+}
+
+func TestClientMultipart(t *testing.T) {
+	contents := bytes.Repeat([]byte("0123456789abcdef"), 100)
+	for _, acceptParts := range []bool{true, false} {
+		t.Run(fmt.Sprintf("acceptParts=%v", acceptParts), func(t *testing.T) {
+			ts, stored, partRequests := newMultipartTestServer(t, acceptParts)
+			defer ts.Close()
+			client := New(Config{BaseURL: ts.URL, Token: "my-token", MultipartThreshold: 300, PartSize: 128, PartConcurrency: 3})
+			ctx := context.Background()
+
+			err := client.PutArtifact(ctx, "some-hash", bytes.NewReader(contents), Metadata{Duration: 500})
+			assert.NilError(t, err, "PutArtifact")
+			assert.DeepEqual(t, stored["some-hash"], contents)
+			if acceptParts {
+				assert.Equal(t, *partRequests, int32(13))
+			} else {
+				assert.Equal(t, *partRequests, int32(0))
+			}
+
+			artifact, err := client.FetchArtifact(ctx, "some-hash")
+			assert.NilError(t, err, "FetchArtifact")
+			body, err := ioutil.ReadAll(artifact.Body)
+			assert.NilError(t, err, "ReadAll")
+			assert.NilError(t, artifact.Body.Close(), "Close")
+			assert.DeepEqual(t, body, contents)
+		})
+	}
+}
+
+func TestClientFetchVerifiesParts(t *testing.T) {
+	contents := bytes.Repeat([]byte("0123456789abcdef"), 100)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		// The digest is of different contents
+		w.Header().Set("x-artifact-digest", "sha256:"+strings.Repeat("0", 64))
+		http.ServeContent(w, req, "", time.Time{}, bytes.NewReader(contents))
+	}))
+	defer ts.Close()
+	client := New(Config{BaseURL: ts.URL, MultipartThreshold: 300, PartSize: 128})
+
+	artifact, err := client.FetchArtifact(context.Background(), "some-hash")
+	assert.NilError(t, err, "FetchArtifact")
+	defer func() { _ = artifact.Body.Close() }()
+	body, err := ioutil.ReadAll(artifact.Body)
+	assert.ErrorContains(t, err, "downloaded artifact has digest")
+	// Everything up to the end of the artifact is read before the digest is known
+	assert.DeepEqual(t, body, contents)
+}
+
+func TestClientFetchStreamsParts(t *testing.T) {
+	contents := bytes.Repeat([]byte("0123456789abcdef"), 100)
+	firstRangeRead := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !strings.HasPrefix(req.Header.Get("Range"), "bytes=0-") {
+			// Later parts aren't served until the first range has been read
+			<-firstRangeRead
+		}
+		http.ServeContent(w, req, "", time.Time{}, bytes.NewReader(contents))
+	}))
+	defer ts.Close()
+	client := New(Config{BaseURL: ts.URL, MultipartThreshold: 300, PartSize: 128, PartConcurrency: 2})
+
+	artifact, err := client.FetchArtifact(context.Background(), "some-hash")
+	assert.NilError(t, err, "FetchArtifact")
+	first := make([]byte, 300)
+	_, err = io.ReadFull(artifact.Body, first)
+	assert.NilError(t, err, "ReadFull")
+	assert.DeepEqual(t, first, contents[:300])
+	close(firstRangeRead)
+	rest, err := ioutil.ReadAll(artifact.Body)
+	assert.NilError(t, err, "ReadAll")
+	assert.DeepEqual(t, rest, contents[300:])
+	assert.NilError(t, artifact.Body.Close(), "Close")
+}
+
+func TestClientFetchPartsClose(t *testing.T) {
+	contents := bytes.Repeat([]byte("0123456789abcdef"), 100)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.ServeContent(w, req, "", time.Time{}, bytes.NewReader(contents))
+	}))
+	defer ts.Close()
+	client := New(Config{BaseURL: ts.URL, MultipartThreshold: 300, PartSize: 128, PartConcurrency: 2})
+
+	// Closing the body before the end stops the remaining downloads
+	artifact, err := client.FetchArtifact(context.Background(), "some-hash")
+	assert.NilError(t, err, "FetchArtifact")
+	_, err = io.ReadFull(artifact.Body, make([]byte, 10))
+	assert.NilError(t, err, "ReadFull")
+	assert.NilError(t, artifact.Body.Close(), "Close")
+}
+
+func TestSplit(t *testing.T) {
+	client := New(Config{PartSize: 10})
+	parts := client.split(5, 30, 2)
+	assert.DeepEqual(t, parts, []artifactPart{
+		{Number: 2, Size: 10, offset: 5},
+		{Number: 3, Size: 10, offset: 15},
+		{Number: 4, Size: 5, offset: 25},
+	}, cmp.AllowUnexported(artifactPart{}))
+
+	// Parts grow so that there are never more than _maxParts of them
+	parts = client.split(0, 100*_maxParts+1, 1)
+	assert.Equal(t, len(parts), 991)
+	assert.Equal(t, parts[0].Size, int64(101))
+}
@@ -0,0 +1,328 @@
+package artifacts
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	_defaultMultipartThreshold = 64 * 1024 * 1024
+	_defaultPartSize           = 16 * 1024 * 1024
+	_defaultPartConcurrency    = 8
+	// _maxParts bounds the number of parts of an artifact, by growing its part size
+	_maxParts = 1000
+)
+
+// errPartsUnsupported is returned when the Remote Cache does not accept artifacts in parts
+var errPartsUnsupported = errors.New("the remote cache does not support multipart uploads")
+
+// sizedReaderAt is implemented by *bytes.Reader and *strings.Reader
+type sizedReaderAt interface {
+	io.ReaderAt
+	Size() int64
+}
+
+// artifactPart describes one part of an artifact transferred in parts
+type artifactPart struct {
+	// Number is the position of the part in the artifact, starting at 1
+	Number int `json:"number"`
+	// Size is the length of the part in bytes
+	Size int64 `json:"size"`
+	// Digest is the content digest of the part, in the form "sha256:<hex>"
+	Digest string `json:"digest"`
+
+	offset int64
+}
+
+// split divides the bytes of an artifact from offset to size into parts of at least
+// PartSize bytes, numbered from first
+func (c *Client) split(offset int64, size int64, first int) []artifactPart {
+	partSize := c.config.PartSize
+	if minimum := (size - offset + _maxParts - 1) / _maxParts; minimum > partSize {
+		partSize = minimum
+	}
+	var parts []artifactPart
+	for number := first; offset < size; number++ {
+		part := artifactPart{Number: number, offset: offset, Size: partSize}
+		if offset+partSize > size {
+			part.Size = size - offset
+		}
+		parts = append(parts, part)
+		offset += part.Size
+	}
+	return parts
+}
+
+// putParts uploads body as the artifact at path in parts, each with its own digest,
+// and then asks the Remote Cache to assemble them. It returns errPartsUnsupported if
+// the Remote Cache rejects the first part because it doesn't know about parts.
+//
+//	PUT  /v8/artifacts/<hash>/parts/<number>  with x-artifact-part-digest
+//	POST /v8/artifacts/<hash>/parts           with the list of parts, as JSON
+func (c *Client) putParts(ctx context.Context, path string, body sizedReaderAt, metadata Metadata) error {
+	parts := c.split(0, body.Size(), 1)
+	// The first part goes alone, so that nothing else is sent if parts are unsupported
+	if err := c.putPart(ctx, path, body, &parts[0]); err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) {
+			switch apiErr.StatusCode {
+			case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+				return errPartsUnsupported
+			}
+		}
+		return err
+	}
+	err := c.eachPart(ctx, parts[1:], func(ctx context.Context, part *artifactPart) error {
+		return c.putPart(ctx, path, body, part)
+	})
+	if err != nil {
+		return err
+	}
+
+	manifest, err := json.Marshal(struct {
+		Parts []artifactPart `json:"parts"`
+	}{parts})
+	if err != nil {
+		return err
+	}
+	headers := http.Header{}
+	headers.Set("Content-Type", "application/json")
+	headers.Set("x-artifact-duration", strconv.Itoa(metadata.Duration))
+	if metadata.Tag != "" {
+		headers.Set("x-artifact-tag", metadata.Tag)
+	}
+	if metadata.Digest != "" {
+		headers.Set("x-artifact-digest", metadata.Digest)
+	}
+	if metadata.KeyID != "" {
+		headers.Set("x-artifact-key-id", metadata.KeyID)
+	}
+	if metadata.PlaintextDigest != "" {
+		headers.Set("x-artifact-plaintext-digest", metadata.PlaintextDigest)
+	}
+	if metadata.HashSchema != 0 {
+		headers.Set("x-artifact-hash-schema", strconv.Itoa(metadata.HashSchema))
+	}
+	resp, err := c.do(ctx, http.MethodPost, path+"/parts", bytes.NewReader(manifest), headers, "Content-Type, x-artifact-duration, Authorization, User-Agent, x-artifact-tag, x-artifact-digest, x-artifact-key-id, x-artifact-plaintext-digest, x-artifact-hash-schema")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return newAPIError(resp)
+	}
+	return nil
+}
+
+// putPart uploads one part of body, and records its digest in part
+func (c *Client) putPart(ctx context.Context, path string, body io.ReaderAt, part *artifactPart) error {
+	digest := sha256.New()
+	if _, err := io.Copy(digest, io.NewSectionReader(body, part.offset, part.Size)); err != nil {
+		return err
+	}
+	part.Digest = "sha256:" + hex.EncodeToString(digest.Sum(nil))
+	headers := http.Header{}
+	headers.Set("Content-Type", "application/octet-stream")
+	headers.Set("x-artifact-part-digest", part.Digest)
+	resp, err := c.do(ctx, http.MethodPut, fmt.Sprintf("%v/parts/%v", path, part.Number), io.NewSectionReader(body, part.offset, part.Size), headers, "Content-Type, Authorization, User-Agent, x-artifact-part-digest")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return newAPIError(resp)
+	}
+	return nil
+}
+
+// fetchParts returns a body for the artifact at path that reads its first range
+// from first, and then the rest, from offset to size, in parts. Parts are
+// downloaded in parallel ahead of the reader and held in memory, at most
+// PartConcurrency at a time, so that the artifact can be restored while it is
+// still arriving, without writing it to disk first.
+func (c *Client) fetchParts(ctx context.Context, path string, first io.ReadCloser, offset int64, size int64) io.ReadCloser {
+	ctx, cancel := context.WithCancel(ctx)
+	parts := c.split(offset, size, 2)
+	pr := &partReader{
+		first:     first,
+		firstSize: offset,
+		current:   first,
+		parts:     make([]*fetchedPart, len(parts)),
+		slots:     make(chan struct{}, c.config.PartConcurrency),
+		cancel:    cancel,
+	}
+	for i := range pr.parts {
+		pr.parts[i] = &fetchedPart{ready: make(chan struct{})}
+	}
+	pr.wg.Add(1)
+	go func() {
+		defer pr.wg.Done()
+		for i, part := range parts {
+			// A slot is freed once the reader has moved past a part, which bounds
+			// how far downloads get ahead of it
+			select {
+			case pr.slots <- struct{}{}:
+			case <-ctx.Done():
+				for _, fetched := range pr.parts[i:] {
+					fetched.err = ctx.Err()
+					close(fetched.ready)
+				}
+				return
+			}
+			pr.wg.Add(1)
+			go func(part artifactPart, fetched *fetchedPart) {
+				defer pr.wg.Done()
+				defer close(fetched.ready)
+				fetched.data, fetched.err = c.fetchPart(ctx, path, part, size)
+			}(part, pr.parts[i])
+		}
+	}()
+	return pr
+}
+
+// fetchedPart is a part of an artifact that is being downloaded. Its fields are
+// set once ready is closed.
+type fetchedPart struct {
+	ready chan struct{}
+	data  []byte
+	err   error
+}
+
+// partReader reads an artifact downloaded in parts, in order
+type partReader struct {
+	first     io.ReadCloser
+	firstSize int64
+	// read is the number of bytes read from the current part
+	read    int64
+	current io.Reader
+	parts   []*fetchedPart
+	next    int
+	slots   chan struct{}
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	// err is returned by every Read after a part fails
+	err error
+}
+
+// Read implements io.Reader.Read
+func (pr *partReader) Read(p []byte) (int, error) {
+	if pr.err != nil {
+		return 0, pr.err
+	}
+	for {
+		n, err := pr.current.Read(p)
+		pr.read += int64(n)
+		if err != io.EOF {
+			return n, err
+		} else if n > 0 {
+			// Move on to the next part in the next Read, rather than wait for it now
+			return n, nil
+		}
+		if pr.next == 0 && pr.read != pr.firstSize {
+			pr.err = fmt.Errorf("part 1 of the artifact has %v bytes, expected %v", pr.read, pr.firstSize)
+			return n, pr.err
+		}
+		if pr.next > 0 {
+			// The reader is done with the previous part
+			pr.parts[pr.next-1].data = nil
+			<-pr.slots
+		}
+		if pr.next == len(pr.parts) {
+			return n, io.EOF
+		}
+		fetched := pr.parts[pr.next]
+		<-fetched.ready
+		if fetched.err != nil {
+			pr.err = fetched.err
+			return n, pr.err
+		}
+		pr.current = bytes.NewReader(fetched.data)
+		pr.next++
+	}
+}
+
+// Close implements io.Closer.Close. Downloads of the remaining parts are canceled.
+func (pr *partReader) Close() error {
+	pr.cancel()
+	err := pr.first.Close()
+	pr.wg.Wait()
+	return err
+}
+
+// fetchPart downloads one part of the artifact at path
+func (c *Client) fetchPart(ctx context.Context, path string, part artifactPart, size int64) ([]byte, error) {
+	end := part.offset + part.Size - 1
+	headers := http.Header{}
+	headers.Set("Range", fmt.Sprintf("bytes=%v-%v", part.offset, end))
+	resp, err := c.do(ctx, http.MethodGet, path, nil, headers, "Authorization, User-Agent, Range")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusPartialContent {
+		return nil, newAPIError(resp)
+	}
+	gotStart, gotEnd, gotSize, err := parseContentRange(resp.Header.Get("Content-Range"))
+	if err != nil {
+		return nil, err
+	} else if gotStart != part.offset || gotEnd != end || gotSize != size {
+		return nil, fmt.Errorf("requested bytes %v-%v/%v of the artifact, got %v-%v/%v", part.offset, end, size, gotStart, gotEnd, gotSize)
+	}
+	data := make([]byte, 0, part.Size)
+	buf := bytes.NewBuffer(data)
+	if _, err := io.Copy(buf, resp.Body); err != nil {
+		return nil, err
+	} else if int64(buf.Len()) != part.Size {
+		return nil, fmt.Errorf("part %v of the artifact has %v bytes, expected %v", part.Number, buf.Len(), part.Size)
+	}
+	return buf.Bytes(), nil
+}
+
+// eachPart calls fn for each of parts, with up to PartConcurrency calls at once,
+// and returns the first error
+func (c *Client) eachPart(ctx context.Context, parts []artifactPart, fn func(ctx context.Context, part *artifactPart) error) error {
+	g, gctx := errgroup.WithContext(ctx)
+	queue := make(chan *artifactPart)
+	g.Go(func() error {
+		defer close(queue)
+		for i := range parts {
+			select {
+			case queue <- &parts[i]:
+			case <-gctx.Done():
+				return nil
+			}
+		}
+		return nil
+	})
+	for i := 0; i < c.config.PartConcurrency; i++ {
+		g.Go(func() error {
+			for part := range queue {
+				if err := fn(gctx, part); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+	return g.Wait()
+}
+
+// parseContentRange parses the Content-Range header of a 206 response
+func parseContentRange(header string) (int64, int64, int64, error) {
+	var start, end, size int64
+	if _, err := fmt.Sscanf(header, "bytes %d-%d/%d", &start, &end, &size); err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid Content-Range header %q", header)
+	}
+	return start, end, size, nil
+}
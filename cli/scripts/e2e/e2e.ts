@@ -325,7 +325,7 @@ function runSmokeTests<T>(
 
       assert.ok(
         sinceCommandSecondRunOutput.includes(
-          `b:build: cache hit, suppressing output ${getHashFromOutput(
+          `b:build: cache hit (local), suppressing output ${getHashFromOutput(
             sinceCommandSecondRunOutput,
             "b#build"
           )}`
@@ -335,7 +335,7 @@ function runSmokeTests<T>(
 
       assert.ok(
         sinceCommandSecondRunOutput.includes(
-          `a:test: cache hit, suppressing output ${getHashFromOutput(
+          `a:test: cache hit (local), suppressing output ${getHashFromOutput(
             sinceCommandSecondRunOutput,
             "a#test"
           )}`
@@ -363,7 +363,7 @@ function runSmokeTests<T>(
       );
       assert.ok(
         lintOutput.includes(
-          `a:lint: cache hit, suppressing output ${getHashFromOutput(
+          `a:lint: cache hit (local), suppressing output ${getHashFromOutput(
             lintOutput,
             "a#lint"
           )}`
@@ -391,7 +391,7 @@ function runSmokeTests<T>(
       );
       assert.ok(
         secondLintRun.includes(
-          `a:lint: cache hit, suppressing output ${getHashFromOutput(
+          `a:lint: cache hit (local), suppressing output ${getHashFromOutput(
             secondLintRun,
             "a#lint"
           )}`
@@ -492,7 +492,7 @@ function runSmokeTests<T>(
       );
       assert.ok(
         secondPass.includes(
-          `//:special: cache hit, suppressing output ${getHashFromOutput(
+          `//:special: cache hit (local), suppressing output ${getHashFromOutput(
             secondPass,
             "//#special"
           )}`
@@ -27,6 +27,31 @@ export interface Schema {
    */
   globalEnv?: string[];
 
+  /**
+   * A list of file globs, relative to the repository root, of files that are
+   * left out of the hash of the workspace that contains them, such as vendored
+   * code. Files named .turbo-hash are always hashed, so that changing one changes
+   * the hash of the tree that it stands in for.
+   *
+   * @default []
+   */
+  hashExclude?: string[];
+
+  /**
+   * Scan the source of each workspace for imports of other workspaces that its
+   * package.json doesn't depend on, by name, by path, or through the path
+   * aliases of its tsconfig.json or jsconfig.json. "warn" reports them, and
+   * "infer" also treats them as dependencies.
+   */
+  undeclaredImports?: "warn" | "infer";
+
+  /**
+   * An http or https URL that the summary of each run is POSTed to as JSON once
+   * it's over, in the format that `turbo run --summarize` writes. Set
+   * TURBO_NOTIFY_WEBHOOK_TOKEN to send it with a bearer token.
+   */
+  notifyWebhook?: string;
+
   /**
    * An object representing the task dependency graph of your project. turbo interprets
    * these conventions to properly schedule, execute, and cache the outputs of tasks in
@@ -48,6 +73,26 @@ export interface Schema {
    * @default {}
    */
   remoteCache?: RemoteCache;
+
+  /**
+   * A list of directories, relative to this turbo.json, that each contain a
+   * monorepo with its own package.json, lockfile, and turbo.json.
+   *
+   * turbo combines their workspaces into a single graph. Each root's pipeline
+   * applies only to its own workspaces, and workspaces may depend on workspaces
+   * in other roots.
+   *
+   * @default []
+   */
+  roots?: string[];
+
+  /**
+   * Defaults for `turbo run` flags. Each is used when the matching flag isn't
+   * passed, so flags on the command line still take precedence.
+   *
+   * @default {}
+   */
+  defaults?: RunDefaults;
 }
 
 export interface Pipeline {
@@ -63,10 +108,36 @@ export interface Pipeline {
    * package level (e.g. "a package's test and lint commands depend on build being
    * completed first").
    *
+   * Items of the form tag:<tag> refer to every task with that tag (e.g. "tag:codegen").
+   *
    * @default []
    */
   dependsOn?: string[];
 
+  /**
+   * Tasks that this task only consumes some of the outputs of, written as in
+   * dependsOn, mapped to globs of those outputs relative to the dependency's
+   * workspace.
+   *
+   * Only the matching files, rather than the hash of the dependency, are part of
+   * this task's hash. A dependency with "cache": false doesn't have to complete:
+   * this task starts once the matching files have been written.
+   *
+   * @default {}
+   */
+  outputOf?: Record<string, string[]>;
+
+  /**
+   * Tasks that this task runs after when they are part of the same run, written
+   * as in dependsOn, without depending on them.
+   *
+   * They aren't added to the run, aren't part of this task's hash, and this task
+   * still runs if they fail.
+   *
+   * @default []
+   */
+  orderOnly?: string[];
+
   /**
    * A list of environment variables, **not** prefixed with $ (e.g. $GITHUB_TOKEN), that this task depends on.
    *
@@ -80,6 +151,9 @@ export interface Pipeline {
    * Note: turbo automatically logs stderr/stdout to .turbo/run-<task>.log. This file is
    * always treated as a cacheable artifact and never needs to be specified.
    *
+   * Patterns that start with ! exclude the matching files, and folders with
+   * everything in them, from the outputs, such as "!dist/**\/*.map".
+   *
    * Passing an empty array can be used to tell turbo that a task is a side-effect and
    * thus doesn't emit any filesystem artifacts (e.g. like a linter), but you still want
    * to cache its logs (and treat them like an artifact).
@@ -108,17 +182,215 @@ export interface Pipeline {
    */
   inputs?: string[];
 
+  /**
+   * A shell command whose output is included in this task's hash.
+   *
+   * The command runs once per `turbo run` from the repository root. Changes to
+   * its standard output will cause a cache miss, which is useful for inputs that
+   * aren't files in the package, such as a toolchain version.
+   *
+   * @default ""
+   */
+  hashCommand?: string;
+
+  /**
+   * Whether this task has nothing to do because dependents consume the package's
+   * source directly, such as the build of an internal package that isn't compiled.
+   *
+   * A passthrough task is never run. Dependents are invalidated by changes to the
+   * package's files, but not by the task's env or outputs. Requires "cache": false.
+   *
+   * @default false
+   */
+  passthrough?: boolean;
+
+  /**
+   * Whether this task builds TypeScript projects with project references.
+   *
+   * In each workspace with a tsconfig.json, the task depends on the same task in
+   * the workspace's dependencies, runs `tsc --build` if package.json has no script
+   * for it, and caches the project's .tsbuildinfo file, outDir, and declarationDir.
+   * Without outputs, nothing else is cached.
+   *
+   * @default false
+   */
+  typescript?: boolean;
+
+  /**
+   * Whether this task is long-running and never completes, such as a dev server
+   * or a watcher. Requires "cache": false.
+   *
+   * Other tasks can only depend on a persistent task through outputOf. A persistent
+   * or cached task that depends on it through dependsOn is an error.
+   *
+   * @default false
+   */
+  persistent?: boolean;
+
+  /**
+   * How many more times to run this task after it fails, before the failure is
+   * reported, and how long to wait before each retry, such as "5s".
+   *
+   * @default { "count": 0 }
+   */
+  retries?: {
+    count: number;
+    delay?: string;
+  };
+
+  /**
+   * Glob patterns, relative to the workspace, of reports such as coverage or bundle
+   * analysis. Once the task completes, matching files are copied into a directory
+   * for the run, .turbo/artifacts/<run ID> unless --artifacts-dir is set.
+   *
+   * Artifacts are not cached unless they are also outputs.
+   *
+   * @default []
+   */
+  artifacts?: string[];
+
+  /**
+   * A list of tags for this task, such as "e2e" or "slow".
+   *
+   * Tags can be used to select or skip tasks with `turbo run --filter-task=tag:<tag>`
+   * and `--filter-task=!tag:<tag>`, and as tag:<tag> in dependsOn.
+   *
+   * @default []
+   */
+  tags?: string[];
+
+  /**
+   * The largest total size of this task's outputs that will be saved to the cache,
+   * such as "100MB". Accepts a number of bytes optionally followed by KB, MB, GB, or TB.
+   *
+   * @default no limit
+   */
+  maxOutputSize?: string;
+
+  /**
+   * What to do when the outputs of this task exceed maxOutputSize. Use "warn" to
+   * skip caching the outputs with a warning. Use "fail" to fail the task.
+   *
+   * @default warn
+   */
+  maxOutputSizeAction?: "warn" | "fail";
+
+  /**
+   * What to do when the outputs of this task embed absolute paths of the machine
+   * that ran it, such as the repository root. Use "skip" to not cache the
+   * outputs, with a warning. Use "warn" to cache them with a warning. Use "allow"
+   * to not check the outputs.
+   *
+   * @default warn
+   */
+  machineSpecificOutputs?: "skip" | "warn" | "allow";
+
+  /**
+   * Fail this task if it writes anything to stderr, even if it exits successfully.
+   *
+   * @default false
+   */
+  failOnStderr?: boolean;
+
+  /**
+   * Regular expressions that fail this task if they match a line of its output,
+   * even if it exits successfully. For tools that report errors but exit with 0.
+   *
+   * @default []
+   */
+  failOnOutput?: string[];
+
+  /**
+   * The most instances of this task, across all workspaces, that run at once.
+   * Applies in addition to --concurrency.
+   *
+   * @default no limit
+   */
+  maxParallel?: number;
+
+  /**
+   * Tasks with the same concurrency group share a single maxParallel limit. If
+   * maxParallel isn't set, the tasks in the group run one at a time.
+   */
+  concurrencyGroup?: string;
+
   /**
    * The style of output for this task. Use "full" to display the entire output of
    * the task. Use "hash-only" to show only the computed task hashes. Use "new-only" to
    * show the full output of cache misses and the computed hashes for cache hits. Use
-   * "none" to hide task output.
+   * "errors-only" to show output only if the task fails. Use "none" to hide task output.
+   *
+   * When set, this takes precedence over the `--output-logs` flag.
    *
    * @default full
    */
   outputMode?: string;
 }
 
+export interface RunDefaults {
+  /**
+   * The default for `--cache-dir`, relative to the root of the repository.
+   */
+  cacheDir?: string;
+
+  /**
+   * The default for `--cache-max-size`, such as "5GB".
+   */
+  cacheMaxSize?: string;
+
+  /**
+   * The default for `--concurrency`: a number of tasks, or a percentage of CPU
+   * cores such as "50%".
+   */
+  concurrency?: number | string;
+
+  /**
+   * The default for `--output-logs`. Tasks with an `outputMode` ignore it.
+   */
+  outputLogs?: "full" | "hash-only" | "new-only" | "errors-only" | "none";
+
+  /**
+   * The defaults for `--warnings`, applied in order: "strict", "default", or
+   * "silence:<code>".
+   */
+  warnings?: string[];
+
+  /**
+   * The default for `--env-mode`: whether tasks can read every environment
+   * variable, or only those that are part of their hash.
+   */
+  envMode?: "loose" | "strict";
+
+  /**
+   * The default for `--environment`: the name of the environment whose dotenv
+   * files are loaded into tasks, such as "development".
+   */
+  environment?: string;
+
+  /**
+   * Run requested tasks that aren't in the pipeline in the workspaces that have a
+   * script for them, without caching, rather than failing. See --allow-missing-tasks.
+   *
+   * @default false
+   */
+  allowMissingTasks?: boolean;
+
+  /**
+   * Don't count persistent tasks, such as dev servers, against the concurrency
+   * limit. See --persistent-outside-concurrency.
+   *
+   * @default false
+   */
+  persistentOutsideConcurrency?: boolean;
+
+  /**
+   * How the output of tasks that run concurrently is ordered. See --log-order.
+   *
+   * @default "auto"
+   */
+  logOrder?: "auto" | "stream" | "grouped";
+}
+
 export interface RemoteCache {
   /**
    * Indicates if signature verification is enabled for requests to the remote cache. When
@@ -129,4 +401,26 @@ export interface RemoteCache {
    * @default false
    */
   signature?: boolean;
+
+  /**
+   * Indicates if artifacts are encrypted before they're uploaded to the remote cache. When
+   * `true`, Turborepo encrypts every uploaded artifact with AES-256-GCM using the base64-encoded
+   * key in the environment variable `TURBO_REMOTE_CACHE_ENCRYPTION_KEY`, or printed by the
+   * command in `TURBO_REMOTE_CACHE_ENCRYPTION_KEY_COMMAND`, and decrypts downloaded artifacts.
+   * Keys that were rotated out can still decrypt artifacts when they're listed in
+   * `TURBO_REMOTE_CACHE_ENCRYPTION_PREVIOUS_KEYS`.
+   *
+   * @default false
+   */
+  encryption?: boolean;
+
+  /**
+   * Where to store artifacts instead of the Remote Cache API. Amazon S3 and services with an
+   * S3-compatible API are supported as `s3://<bucket>/<prefix>`, where the region and endpoint
+   * can be given as the `region` and `endpoint` query parameters, Google Cloud Storage as
+   * `gs://<bucket>/<prefix>`, and Azure Blob Storage as
+   * `azblob://<account>/<container>/<prefix>`. The environment variable `TURBO_CACHE` takes
+   * precedence over this option.
+   */
+  provider?: string;
 }